@@ -1,25 +1,63 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/oklog/ulid/v2"
 	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
 	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/calibrate"
+	"github.com/rotationalio/ensign-benchmarks/pkg/chaos"
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
+	"github.com/rotationalio/ensign-benchmarks/pkg/commit"
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+	"github.com/rotationalio/ensign-benchmarks/pkg/consistency"
+	"github.com/rotationalio/ensign-benchmarks/pkg/consume"
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/rotationalio/ensign-benchmarks/pkg/e2e"
+	"github.com/rotationalio/ensign-benchmarks/pkg/emulator"
+	"github.com/rotationalio/ensign-benchmarks/pkg/estimate"
+	"github.com/rotationalio/ensign-benchmarks/pkg/export"
+	"github.com/rotationalio/ensign-benchmarks/pkg/identity"
+	"github.com/rotationalio/ensign-benchmarks/pkg/limits"
+	"github.com/rotationalio/ensign-benchmarks/pkg/lock"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/monitor"
 	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/preflight"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ramp"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ratelimit"
+	"github.com/rotationalio/ensign-benchmarks/pkg/replay"
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/rotationalio/ensign-benchmarks/pkg/retention"
+	"github.com/rotationalio/ensign-benchmarks/pkg/scale"
+	"github.com/rotationalio/ensign-benchmarks/pkg/seek"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
 	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/ensign-benchmarks/pkg/teardown"
+	"github.com/rotationalio/ensign-benchmarks/pkg/tunables"
 	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
 	"github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
-	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/grpc/connectivity"
 )
 
 func main() {
@@ -57,14 +95,246 @@ func main() {
 			Value:   "benchmarks",
 			Usage:   "specify the topic to perform the benchmarks on",
 		},
+		&cli.StringFlag{
+			Name:    "topic-id",
+			Aliases: []string{"T"},
+			Usage:   "bind the benchmark to a topic ID rather than resolving the topic name",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"O"},
+			Value:   report.OutputJSON,
+			Usage:   "output mode for benchmark results (json, jsonl, text, github, or email)",
+			EnvVars: []string{"ENBENCH_OUTPUT"},
+		},
+		&cli.StringFlag{
+			Name:    "out",
+			Usage:   "the file to append the jsonl output to as the benchmark runs (stdout by default)",
+			EnvVars: []string{"ENBENCH_OUT"},
+		},
+		&cli.StringFlag{
+			Name:  "baseline",
+			Usage: "path to the JSON results of a previous run to report deltas from",
+		},
+		&cli.StringFlag{
+			Name:    "max-bytes",
+			Aliases: []string{"B"},
+			Usage:   "terminate the run once this volume of payload has been published (e.g. 50GB)",
+		},
+		&cli.StringFlag{
+			Name:  "histogram",
+			Usage: "export latency histograms with these buckets (e.g. exp:100us,2,20, linear:5ms,5ms,20, or 1ms,10ms,100ms)",
+		},
+		&cli.StringFlag{
+			Name:  "phases",
+			Usage: "relative widths of the run phases to summarize latencies in (default: 10,80,10 for start, middle, end)",
+		},
+		&cli.DurationFlag{
+			Name:  "warmup",
+			Usage: "exclude operations started within this duration of the start of the run from the latency summary",
+		},
+		&cli.DurationFlag{
+			Name:  "cooldown",
+			Usage: "exclude operations started within this duration of the end of the run from the latency summary",
+		},
+		&cli.StringFlag{
+			Name:  "max-cost",
+			Usage: "terminate the run with partial results at the first of duration, events, or bytes (e.g. duration=30m,events=1000000,bytes=50GB)",
+		},
+		&cli.BoolFlag{
+			Name:  "accuracy",
+			Usage: "abort timing sensitive runs if the host clock is not synchronized",
+		},
+		&cli.BoolFlag{
+			Name:  "calibrate",
+			Usage: "measure the loopback and status RPC latency floor before timing sensitive runs",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-preflight",
+			Usage: "do not verify publish and subscribe access with a canary event before the run",
+		},
+		&cli.DurationFlag{
+			Name:  "max-clock-error",
+			Value: clock.DefaultMaxError,
+			Usage: "the maximum estimated host clock error tolerated in accuracy mode",
+		},
+		&cli.BoolFlag{
+			Name:  "minimal-metadata",
+			Usage: "strip the app, version, and counter metadata from generated events",
+		},
+		&cli.StringFlag{
+			Name:  "payload",
+			Usage: fmt.Sprintf("generate event data with a payload generator instead of random bytes (%s)", strings.Join(workload.PayloadNames(), ", ")),
+		},
+		&cli.StringFlag{
+			Name:  "schema",
+			Usage: "the name:kind fields of json, csv, and protobuf payload documents (kinds: string, int, float, bool, time, text)",
+		},
+		&cli.BoolFlag{
+			Name:  "local-emulator",
+			Usage: "run the benchmark against an in-process emulator instead of an Ensign server",
+		},
+		&cli.StringFlag{
+			Name:    "store",
+			Value:   store.DefaultPath(),
+			Usage:   "path to the sqlite database that records the history of benchmark runs",
+			EnvVars: []string{"ENBENCH_STORE"},
+		},
+		&cli.BoolFlag{
+			Name:  "no-store",
+			Usage: "do not record the run in the results store",
+		},
+		&cli.Int64Flag{
+			Name:  "seed",
+			Usage: "seed the random workload generators to reproduce a run (default: random)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "label",
+			Aliases: []string{"L"},
+			Usage:   "label the run with a key=value pair in its manifest and the results store",
+		},
+		&cli.StringFlag{
+			Name:    "write-manifest",
+			Usage:   "write a manifest to this path after the run that can be used to reproduce it",
+			EnvVars: []string{"ENBENCH_MANIFEST"},
+		},
+		&cli.StringFlag{
+			Name:    "config",
+			Usage:   "path to a yaml file of named benchmark profiles to run",
+			EnvVars: []string{"ENBENCH_CONFIG"},
+		},
+		&cli.BoolFlag{
+			Name:  "cleanup",
+			Usage: "destroy the topics created for the run once it is over",
+		},
+		&cli.DurationFlag{
+			Name:  "assert-p99",
+			Usage: "fail the run if its p99 latency exceeds this duration",
+		},
+		&cli.Float64Flag{
+			Name:  "assert-throughput",
+			Usage: "fail the run if its throughput is less than this many events per second",
+		},
+		&cli.Float64Flag{
+			Name:  "assert-error-rate",
+			Usage: "fail the run if more than this fraction of its events failed or timed out (e.g. 0.01)",
+		},
+		&cli.StringFlag{
+			Name:    "pushgateway",
+			Usage:   "push the metrics of the run to the Prometheus Pushgateway at this url",
+			EnvVars: []string{"ENBENCH_PUSHGATEWAY"},
+		},
+		&cli.BoolFlag{
+			Name:    "lock",
+			Usage:   "take a lockfile for the endpoint and topic so that concurrent runs on this host do not overlap",
+			EnvVars: []string{"ENBENCH_LOCK"},
+		},
+		&cli.StringFlag{
+			Name:    "lock-dir",
+			Value:   lock.DefaultDir(),
+			Usage:   "the directory of the lockfiles shared by the runs on this host",
+			EnvVars: []string{"ENBENCH_LOCK_DIR"},
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "run even if another run holds the lock of the endpoint and topic",
+		},
+	}
+	app.After = func(c *cli.Context) error {
+		if c.Bool("cleanup") {
+			cleanupTopics()
+		}
+
+		if err := runLock.Release(); err != nil {
+			log.Warn().Err(err).Msg("could not release the run lock")
+		}
+		runLock, lockStatus = nil, nil
+
+		if emu != nil {
+			emu.Close()
+			emu = nil
+		}
+		return nil
 	}
 	app.Commands = []*cli.Command{
+		{
+			Name:      "run",
+			Usage:     "run a profile from the config or reproduce a previous run from its manifest",
+			ArgsUsage: "[profile]",
+			Action:    rerun,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "manifest",
+					Aliases: []string{"m"},
+					Usage:   "the path to the manifest written by the run to reproduce",
+				},
+			},
+		},
+		{
+			Name:      "schedule",
+			Usage:     "run a profile from the config periodically, recording and pushing the metrics of each run",
+			ArgsUsage: "profile",
+			Action:    schedule,
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:  "every",
+					Usage: "the interval between the starts of the runs (e.g. 6h) if the profile does not specify one",
+				},
+				&cli.IntFlag{
+					Name:  "runs",
+					Usage: "stop after this many runs (0 to run until interrupted)",
+				},
+			},
+		},
+		{
+			Name:   "smoke",
+			Usage:  "run a miniature version of each benchmark against the local emulator",
+			Before: configure,
+			Action: runSmoke,
+		},
+		{
+			Name:   "estimate",
+			Usage:  "estimate the duration and data volume of a run before running it",
+			Before: configure,
+			Action: runEstimate,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "benchmark",
+					Aliases: []string{"b"},
+					Usage:   "the benchmark whose stored runs are used as throughput priors",
+					Value:   "blast",
+				},
+				&cli.StringFlag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to send at the server, e.g. 10M",
+				},
+				&cli.StringFlag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size of the payloads to send, e.g. 8KB",
+				},
+				&cli.Float64Flag{
+					Name:  "rate",
+					Usage: "the events per second the run is limited to (as fast as possible by default)",
+				},
+				&cli.DurationFlag{
+					Name:    "interval",
+					Aliases: []string{"i"},
+					Usage:   "the interval between sustain events if no rate is specified",
+				},
+			},
+		},
 		{
 			Name:   "blast",
 			Usage:  "run a blast benchmark",
 			Before: configure,
 			Action: runBlast,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "parquet",
+					Usage: "export the raw latency samples to a parquet file at this path (zstd compressed if it ends in .zst)",
+				},
 				&cli.Uint64Flag{
 					Name:    "operations",
 					Aliases: []string{"N"},
@@ -75,304 +345,3980 @@ func main() {
 					Aliases: []string{"S"},
 					Usage:   "the size in bytes of the payloads to send",
 				},
+				&cli.IntFlag{
+					Name:    "reservoir",
+					Aliases: []string{"R"},
+					Usage:   "retain a uniform random sample of this many raw latencies in the results",
+				},
+				&cli.Float64Flag{
+					Name:  "rate",
+					Usage: "publish at this many events per second instead of as fast as possible",
+				},
+				&cli.IntFlag{
+					Name:  "streams",
+					Usage: "publish on this many streams, each with its own connection",
+					Value: 1,
+				},
+				&cli.IntFlag{
+					Name:  "connections",
+					Usage: "spread the streams across this many connections (one per stream by default)",
+				},
+				&cli.BoolFlag{
+					Name:  "progress",
+					Usage: "display the events sent and acked, throughput, and rolling p99 latency every second",
+				},
+				&cli.BoolFlag{
+					Name:  "pre-dial",
+					Usage: "connect and verify every stream in parallel before the measurement starts",
+				},
+				&cli.Float64Flag{
+					Name:    "malformed",
+					Aliases: []string{"m"},
+					Usage:   "fraction of events to replace with malformed or boundary-case events",
+				},
+				&cli.StringFlag{
+					Name:    "workload",
+					Aliases: []string{"w"},
+					Usage:   fmt.Sprintf("publish events from a registered workload (%s) or replay a pb or jsonl testdata file with file:<path> instead of random bytes", strings.Join(workload.Names(), ", ")),
+				},
+				&cli.StringFlag{
+					Name:  "topics",
+					Usage: "publish round-robin across these topics with optional weights (e.g. orders:3,payments), creating missing topics",
+				},
+				&cli.Uint64Flag{
+					Name:  "warmup-events",
+					Usage: "publish this many events before the measurement starts (unlike --warmup these events are never measured)",
+				},
+				&cli.DurationFlag{
+					Name:  "warmup-duration",
+					Usage: "publish events for this long before the measurement starts",
+				},
 			},
 		},
 		{
-			Name:   "sustain",
-			Usage:  "run a sustain benchmark",
+			Name:   "e2e",
+			Usage:  "measure publish-to-delivery latency by subscribing to the benchmark topic",
 			Before: configure,
-			Action: runSustain,
+			Action: runE2E,
 			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to send at the server",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
 				&cli.DurationFlag{
-					Name:    "interval",
-					Aliases: []string{"i"},
-					Value:   1250 * time.Millisecond,
-					Usage:   "the interval between publishing events",
+					Name:  "drain-timeout",
+					Usage: "how long to wait for acks and deliveries after all events are published",
+					Value: e2e.DefaultDrainTimeout,
 				},
+			},
+		},
+		{
+			Name:   "consume",
+			Usage:  "pre-fill the benchmark topic and measure how fast a subscriber drains it",
+			Before: configure,
+			Action: runConsume,
+			Flags: []cli.Flag{
 				&cli.Uint64Flag{
 					Name:    "operations",
 					Aliases: []string{"N"},
-					Value:   0,
-					Usage:   "the maximum number of events to publish (0 runs until stopped)",
+					Usage:   "the number of events to pre-fill the topic with",
 				},
 				&cli.Int64Flag{
 					Name:    "data-size",
 					Aliases: []string{"S"},
-					Value:   256,
 					Usage:   "the size in bytes of the payloads to send",
 				},
+				&cli.DurationFlag{
+					Name:  "fill-timeout",
+					Usage: "how long to wait for the pre-filled events to be acked",
+					Value: consume.DefaultFillTimeout,
+				},
+				&cli.DurationFlag{
+					Name:  "idle-timeout",
+					Usage: "stop draining if no events are received for this duration",
+					Value: consume.DefaultIdleTimeout,
+				},
+				&cli.IntFlag{
+					Name:  "ack-workers",
+					Usage: "ack the received events on this many goroutines separate from the receive loop (0 acks in the receive loop)",
+				},
+				&cli.IntFlag{
+					Name:  "ack-queue",
+					Usage: "the capacity of the queue of received events awaiting an ack worker",
+					Value: consume.DefaultAckQueue,
+				},
+				&cli.StringFlag{
+					Name:    "recv-rate",
+					Aliases: []string{"r"},
+					Usage:   "cap the subscriber bandwidth to emulate a slow reader (bytes/sec, e.g. 512KB)",
+				},
 			},
 		},
 		{
-			Name:   "listen",
-			Usage:  "listen for events on the specified topic",
+			Name:   "commit",
+			Usage:  "compare how the ack frequency of a consumer group affects throughput and redeliveries",
 			Before: configure,
-			Action: listen,
+			Action: runCommit,
 			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to fill the topic with",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
 				&cli.StringSliceFlag{
-					Name:    "topic",
-					Aliases: []string{"t"},
-					Usage:   "specify the topics to subscribe to",
+					Name:  "policy",
+					Usage: "the commit policies to compare (event, count:N, or interval:duration)",
+					Value: cli.NewStringSlice(commit.DefaultPolicies...),
+				},
+				&cli.Uint64Flag{
+					Name:  "reconnect-every",
+					Usage: "reconnect to the consumer group after this many new events (0 to never reconnect)",
+					Value: commit.DefaultReconnectEvery,
+				},
+				&cli.DurationFlag{
+					Name:  "fill-timeout",
+					Usage: "how long to wait for the filled events to be acked",
+					Value: commit.DefaultFillTimeout,
+				},
+				&cli.DurationFlag{
+					Name:  "idle-timeout",
+					Usage: "stop draining a policy if no events are received for this duration",
+					Value: commit.DefaultIdleTimeout,
 				},
 			},
 		},
 		{
-			Name:   "check",
-			Usage:  "check that the benchmarks can run successfully",
+			Name:   "seek",
+			Usage:  "replay the history of the benchmark topic from an offset and measure time to catch up",
 			Before: configure,
-			Action: check,
+			Action: runSeek,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to fill the topic with before the replay",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+				&cli.Uint64Flag{
+					Name:  "offset",
+					Usage: "the number of events at the start of the topic to skip",
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Usage: "replay at most this many events after the offset (0 replays up to the head of the topic)",
+				},
+				&cli.BoolFlag{
+					Name:  "no-fill",
+					Usage: "replay the existing history of the topic without publishing events first",
+				},
+				&cli.DurationFlag{
+					Name:  "fill-timeout",
+					Usage: "how long to wait for the events of the fill to be acked",
+					Value: seek.DefaultFillTimeout,
+				},
+			},
 		},
 		{
-			Name:      "mktopic",
-			Usage:     "create the specified topic(s) in your project",
-			ArgsUsage: "topic [topic ...]",
-			Before:    configure,
-			Action:    createTopic,
+			Name:   "cache",
+			Usage:  "compare the replay of just published events (warm caches) with a replay after an idle delay or of older history (cold reads)",
+			Before: configure,
+			Action: runCache,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to fill the topic with before the warm replay",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+				&cli.DurationFlag{
+					Name:  "delay",
+					Usage: "how long to leave the topic idle between the warm and cold replays",
+					Value: seek.DefaultCacheDelay,
+				},
+				&cli.BoolFlag{
+					Name:  "history",
+					Usage: "read the oldest events of the topic from the cold offset for the cold replay instead of rereading the fill",
+				},
+				&cli.Uint64Flag{
+					Name:  "cold-offset",
+					Usage: "the offset of the older history read by the cold replay with --history",
+				},
+				&cli.DurationFlag{
+					Name:  "fill-timeout",
+					Usage: "how long to wait for the events of the fill to be acked",
+					Value: seek.DefaultFillTimeout,
+				},
+			},
 		},
 		{
-			Name:   "testdata",
-			Usage:  "generate testdata with duplicates",
-			Action: mktestdata,
+			Name:   "query",
+			Usage:  "run EnSQL queries against the benchmark topic at increasing concurrency and measure query latency and rows per second",
+			Before: configure,
+			Action: runQuery,
 			Flags: []cli.Flag{
-				&cli.IntFlag{
-					Name:    "size",
-					Aliases: []string{"s"},
-					Usage:   "number of events to generate for the test",
-					Value:   1000,
+				&cli.StringSliceFlag{
+					Name:    "query",
+					Aliases: []string{"q"},
+					Usage:   fmt.Sprintf("an EnSQL query to run, %s is replaced by the topic name (repeat for several queries)", seek.TopicPlaceholder),
+					Value:   cli.NewStringSlice(seek.DefaultQueries...),
+				},
+				&cli.IntSliceFlag{
+					Name:    "concurrency",
+					Aliases: []string{"c"},
+					Usage:   "the number of concurrent query workers at each level",
+					Value:   cli.NewIntSlice(seek.DefaultQueryConcurrency...),
 				},
 				&cli.IntFlag{
-					Name:    "num-keys",
-					Aliases: []string{"k"},
-					Usage:   "specify the number of available keys to add to events",
-					Value:   20,
+					Name:  "repeat",
+					Usage: "the number of times each worker runs every query at each level",
+					Value: seek.DefaultQueryRepeat,
 				},
-				&cli.Float64Flag{
-					Name:    "new-key-prob",
-					Aliases: []string{"p"},
-					Usage:   "specify the probability a new key value is generated",
-					Value:   0.6,
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to fill the topic with before the queries",
 				},
-				&cli.Float64Flag{
-					Name:    "duplicate-prob",
-					Aliases: []string{"d"},
-					Usage:   "specify the probability of duplicates in the dataset",
-					Value:   0.1,
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
 				},
-				&cli.StringFlag{
-					Name:    "out",
-					Aliases: []string{"o"},
-					Usage:   "the location to write the data out to",
-					Value:   "events.pb.json",
+				&cli.BoolFlag{
+					Name:  "no-fill",
+					Usage: "query the existing history of the topic without publishing events first",
+				},
+				&cli.DurationFlag{
+					Name:  "fill-timeout",
+					Usage: "how long to wait for the events of the fill to be acked",
+					Value: seek.DefaultFillTimeout,
 				},
 			},
 		},
+		{
+			Name:   "consistency",
+			Usage:  "continuously probe that published events can be read back immediately",
+			Before: configure,
+			Action: runConsistency,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "from",
+					Usage: "read events back from a new subscription (latest) or the topic history (earliest)",
+					Value: consistency.FromLatest,
+				},
+				&cli.DurationFlag{
+					Name:    "interval",
+					Aliases: []string{"i"},
+					Usage:   "the interval between probes",
+					Value:   consistency.DefaultInterval,
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Usage: "how long each probe waits for its event to be acked and read back",
+					Value: consistency.DefaultTimeout,
+				},
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of probes to run (0 runs until stopped)",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads of the probe events",
+				},
+			},
+		},
+		{
+			Name:   "sustain",
+			Usage:  "run a sustain benchmark",
+			Before: configure,
+			Action: runSustain,
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:    "interval",
+					Aliases: []string{"i"},
+					Value:   1250 * time.Millisecond,
+					Usage:   "the interval between publishing events",
+				},
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Value:   0,
+					Usage:   "the maximum number of events to publish (0 runs until stopped)",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Value:   256,
+					Usage:   "the size in bytes of the payloads to send",
+				},
+				&cli.Uint64Flag{
+					Name:    "backoff",
+					Aliases: []string{"b"},
+					Value:   0,
+					Usage:   "pause publishing until acked when more than this many events are in-flight (0 disables)",
+				},
+				&cli.DurationFlag{
+					Name:  "drain-timeout",
+					Usage: "how long to wait for acks after publishing stops",
+					Value: sustain.DefaultDrainTimeout,
+				},
+				&cli.DurationFlag{
+					Name:  "window",
+					Usage: "the width of the windows of the latency time series",
+					Value: stats.DefaultWindow,
+				},
+				&cli.DurationFlag{
+					Name:  "tail-threshold",
+					Usage: "publish a probe after any event slower than this to tell transient hiccups from sustained degradation",
+				},
+				&cli.Uint64Flag{
+					Name:  "warmup-events",
+					Usage: "publish this many events before the measurement starts (unlike --warmup these events are never measured)",
+				},
+				&cli.DurationFlag{
+					Name:  "warmup-duration",
+					Usage: "publish events for this long before the measurement starts",
+				},
+				&cli.StringFlag{
+					Name:  "jitter",
+					Usage: "randomize the interval between events by a percentage (e.g. 30%) or with exponential (poisson) arrivals",
+				},
+				&cli.BoolFlag{
+					Name:  "token-refresh",
+					Usage: "record access token refreshes and the latency of events published around them",
+				},
+				&cli.DurationFlag{
+					Name:  "token-check",
+					Usage: "how often to check the credentials with a status rpc so that expired tokens are refreshed",
+					Value: sustain.DefaultTokenCheck,
+				},
+				&cli.DurationFlag{
+					Name:  "refresh-window",
+					Usage: "events sent within this long of a token refresh are reported as adjacent to it",
+					Value: sustain.DefaultRefreshWindow,
+				},
+				&cli.StringFlag{
+					Name:  "chaos",
+					Usage: "inject faults into the publish stream to measure recovery, e.g. delay=5%,max-delay=250ms,drop=1%,close=0.1%",
+				},
+				&cli.StringFlag{
+					Name:    "workload",
+					Aliases: []string{"w"},
+					Usage:   fmt.Sprintf("publish events from a registered workload (%s) or replay a pb or jsonl testdata file with file:<path> instead of random bytes", strings.Join(workload.Names(), ", ")),
+				},
+			},
+		},
+		{
+			Name:   "retention",
+			Usage:  "publish beyond the retention limit of a topic and correlate latency with topic size",
+			Before: configure,
+			Action: runRetention,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "parquet",
+					Usage: "export the per-window time series to a parquet file at this path (zstd compressed if it ends in .zst)",
+				},
+				&cli.Uint64Flag{
+					Name:     "retention",
+					Aliases:  []string{"r"},
+					Usage:    "the retention limit of the topic in bytes",
+					Required: true,
+				},
+				&cli.Float64Flag{
+					Name:    "overshoot",
+					Aliases: []string{"x"},
+					Value:   retention.DefaultOvershoot,
+					Usage:   "publish until this multiple of the retention limit has been published",
+				},
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events to blast in each window",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+			},
+		},
+		{
+			Name:   "replay",
+			Usage:  "publish a stream of pre-generated events from a file or stdin",
+			Before: configure,
+			Action: runReplay,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "file",
+					Aliases:  []string{"f"},
+					Usage:    "path to the event stream to replay or - to read from stdin",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    "format",
+					Aliases: []string{"F"},
+					Usage:   "format of the event stream (jsonl or pb), inferred from the file extension if omitted",
+				},
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "stop after publishing this many events (default all events in the stream)",
+				},
+			},
+		},
+		{
+			Name:   "ratelimits",
+			Usage:  "probe increasing publish rates to discover server or project rate limits",
+			Before: configure,
+			Action: runRateProbe,
+			Flags: []cli.Flag{
+				&cli.Float64Flag{
+					Name:  "start-rate",
+					Usage: "the publish rate (events/sec) of the first step",
+					Value: limits.DefaultStartRate,
+				},
+				&cli.Float64Flag{
+					Name:  "max-rate",
+					Usage: "stop probing once this publish rate (events/sec) is reached",
+					Value: limits.DefaultMaxRate,
+				},
+				&cli.Float64Flag{
+					Name:  "factor",
+					Usage: "multiply the publish rate by this factor after every step",
+					Value: limits.DefaultRateFactor,
+				},
+				&cli.DurationFlag{
+					Name:  "step",
+					Usage: "the length of time to publish at each rate",
+					Value: limits.DefaultStepDuration,
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "the fraction of failed publishes in a step that indicates throttling",
+					Value: limits.DefaultThrottleThreshold,
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+			},
+		},
+		{
+			Name:   "limits",
+			Usage:  "probe the maximum event payload and metadata sizes accepted by the server",
+			Before: configure,
+			Action: runSizeProbe,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "min-size",
+					Usage: "the size of the first event published (e.g. 1KB)",
+					Value: "1KiB",
+				},
+				&cli.StringFlag{
+					Name:  "max-size",
+					Usage: "the largest size probed (e.g. 64MiB)",
+					Value: "64MiB",
+				},
+				&cli.StringFlag{
+					Name:  "precision",
+					Usage: "stop bisecting once the limit is known within this size",
+					Value: "1KiB",
+				},
+				&cli.DurationFlag{
+					Name:  "reply-timeout",
+					Usage: "how long to wait for the server to reply to each event before it is rejected",
+					Value: limits.DefaultReplyTimeout,
+				},
+				&cli.BoolFlag{
+					Name:  "skip-metadata",
+					Usage: "only probe the payload size limit",
+				},
+			},
+		},
+		{
+			Name:   "teardown",
+			Usage:  "measure how quickly publish streams tear down when canceled mid-stream",
+			Before: configure,
+			Action: runTeardown,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "trials",
+					Usage: "the number of publish streams to open and cancel",
+					Value: teardown.DefaultTrials,
+				},
+				&cli.Uint64Flag{
+					Name:  "burst",
+					Usage: "the number of events to send on each stream before canceling it",
+					Value: teardown.DefaultBurst,
+				},
+				&cli.DurationFlag{
+					Name:  "delay",
+					Usage: "wait this long after sending the burst before canceling the stream",
+				},
+				&cli.DurationFlag{
+					Name:  "grace",
+					Usage: "the time to wait for a canceled stream to tear down",
+					Value: teardown.DefaultGrace,
+				},
+				&cli.DurationFlag{
+					Name:  "settle",
+					Usage: "the time to wait for commits before checking the topic for committed events",
+					Value: teardown.DefaultSettle,
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+			},
+		},
+		{
+			Name:   "scale",
+			Usage:  "create thousands of topics in steps and measure how topic lookups and publish latency scale",
+			Before: configure,
+			Action: runScale,
+			Flags: []cli.Flag{
+				&cli.IntSliceFlag{
+					Name:  "steps",
+					Usage: "the cumulative number of topics to create at each step",
+					Value: cli.NewIntSlice(scale.DefaultSteps...),
+				},
+				&cli.IntFlag{
+					Name:  "lookups",
+					Usage: "the number of random topics to look up by name at each step",
+					Value: scale.DefaultLookups,
+				},
+				&cli.IntFlag{
+					Name:  "trickle",
+					Usage: "the number of events to publish to every topic at each step",
+					Value: scale.DefaultTrickle,
+				},
+				&cli.DurationFlag{
+					Name:  "ack-timeout",
+					Usage: "how long to wait for the ack of each trickle event",
+					Value: scale.DefaultAckTimeout,
+				},
+				&cli.BoolFlag{
+					Name:  "keep-topics",
+					Usage: "do not destroy the topics created by the run",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+			},
+		},
+		{
+			Name:   "ramp",
+			Usage:  "increase the publish rate in steps until a latency SLO is violated",
+			Before: configure,
+			Action: runRamp,
+			Flags: []cli.Flag{
+				&cli.Float64Flag{
+					Name:  "start-rate",
+					Usage: "the publish rate (events/sec) of the first step",
+					Value: ramp.DefaultStartRate,
+				},
+				&cli.Float64Flag{
+					Name:  "increment",
+					Usage: "increase the publish rate (events/sec) by this amount after every step",
+					Value: ramp.DefaultIncrement,
+				},
+				&cli.Float64Flag{
+					Name:  "max-rate",
+					Usage: "stop ramping once this publish rate (events/sec) is reached",
+					Value: ramp.DefaultMaxRate,
+				},
+				&cli.DurationFlag{
+					Name:  "step",
+					Usage: "the length of time to publish at each rate",
+					Value: ramp.DefaultStepDuration,
+				},
+				&cli.DurationFlag{
+					Name:  "slo",
+					Usage: "the maximum publish-to-ack latency at the percentile for a step to pass",
+					Value: ramp.DefaultSLO,
+				},
+				&cli.Float64Flag{
+					Name:  "percentile",
+					Usage: "the latency percentile (0-100) that is checked against the slo",
+					Value: ramp.DefaultPercentile,
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "the maximum fraction of events in a step that may fail for the step to pass",
+					Value: ramp.DefaultThreshold,
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+			},
+		},
+		{
+			Name:   "aimd",
+			Usage:  "adjust the number of in-flight publishes (AIMD) to maximize throughput under a latency ceiling",
+			Before: configure,
+			Action: runAIMD,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "start-concurrency",
+					Usage: "the number of in-flight publishes allowed in the first window",
+					Value: ramp.DefaultStartConcurrency,
+				},
+				&cli.IntFlag{
+					Name:  "max-concurrency",
+					Usage: "never allow more than this number of in-flight publishes",
+					Value: ramp.DefaultMaxConcurrency,
+				},
+				&cli.Float64Flag{
+					Name:  "increase",
+					Usage: "add this amount to the concurrency after every window within the ceiling",
+					Value: ramp.DefaultIncrease,
+				},
+				&cli.Float64Flag{
+					Name:  "decrease",
+					Usage: "multiply the concurrency by this factor after every congested window",
+					Value: ramp.DefaultDecrease,
+				},
+				&cli.DurationFlag{
+					Name:  "window",
+					Usage: "the length of time between concurrency adjustments",
+					Value: ramp.DefaultWindow,
+				},
+				&cli.DurationFlag{
+					Name:  "duration",
+					Usage: "the length of time to run the controller for",
+					Value: ramp.DefaultAIMDDuration,
+				},
+				&cli.DurationFlag{
+					Name:  "ceiling",
+					Usage: "the maximum publish-to-ack latency at the percentile before the window is congested",
+					Value: ramp.DefaultSLO,
+				},
+				&cli.Float64Flag{
+					Name:  "percentile",
+					Usage: "the latency percentile (0-100) that is checked against the ceiling",
+					Value: ramp.DefaultPercentile,
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+			},
+		},
+		{
+			Name:   "coordinator",
+			Usage:  "coordinate a blast benchmark run by agents on multiple hosts",
+			Before: configure,
+			Action: runCoordinator,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "addr",
+					Usage: "the address to serve the coordinator control api on",
+					Value: ":7780",
+				},
+				&cli.IntFlag{
+					Name:     "agents",
+					Aliases:  []string{"n"},
+					Usage:    "the number of producer agents that must join before the run starts",
+					Required: true,
+				},
+				&cli.IntFlag{
+					Name:  "consumers",
+					Usage: "the number of consumer agents that receive the events of the producers",
+				},
+				&cli.StringFlag{
+					Name:  "consumer-topic",
+					Usage: "the topic the consumers subscribe to (the producer topic by default)",
+				},
+				&cli.DurationFlag{
+					Name:  "lead",
+					Usage: "how far in the future to schedule the start once all agents have joined",
+					Value: distributed.DefaultLead,
+				},
+				&cli.Uint64Flag{
+					Name:    "operations",
+					Aliases: []string{"N"},
+					Usage:   "the number of events each agent sends at the server",
+				},
+				&cli.Int64Flag{
+					Name:    "data-size",
+					Aliases: []string{"S"},
+					Usage:   "the size in bytes of the payloads to send",
+				},
+				&cli.StringFlag{
+					Name:    "workload",
+					Aliases: []string{"w"},
+					Usage:   fmt.Sprintf("publish events from a registered workload instead of random bytes (%s)", strings.Join(workload.Names(), ", ")),
+				},
+			},
+		},
+		{
+			Name:   "agent",
+			Usage:  "join a coordinator and run its blast benchmark or consume its events from this host",
+			Before: configure,
+			Action: runAgent,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "coordinator",
+					Usage:    "the address of the coordinator control api",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "role",
+					Usage: "request the producer or consumer role (assigned by the coordinator by default)",
+				},
+				&cli.DurationFlag{
+					Name:  "idle-timeout",
+					Usage: "stop consuming if no events are received for this duration once the producers complete",
+					Value: distributed.DefaultIdleTimeout,
+				},
+			},
+		},
+		{
+			Name:   "listen",
+			Usage:  "listen for events on the specified topic",
+			Before: configure,
+			Action: listen,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "topic",
+					Aliases: []string{"t"},
+					Usage:   "specify the topics to subscribe to",
+				},
+				&cli.StringFlag{
+					Name:    "recv-rate",
+					Aliases: []string{"r"},
+					Usage:   "cap the consumer bandwidth to emulate a slow reader (bytes/sec, e.g. 512KB)",
+				},
+				&cli.DurationFlag{
+					Name:    "stats-interval",
+					Aliases: []string{"s"},
+					Usage:   "print a table of per-topic statistics at this interval instead of logging every event",
+				},
+				&cli.BoolFlag{
+					Name:  "pairs",
+					Usage: "analyze the delivery of the request/response pairs of the pairs workload and report it on exit",
+				},
+			},
+		},
+		{
+			Name:   "check",
+			Usage:  "check that the benchmarks can run successfully",
+			Before: configure,
+			Action: check,
+		},
+		{
+			Name:   "whoami",
+			Usage:  "authenticate with the configured credentials and print the token claims",
+			Before: configure,
+			Action: whoami,
+		},
+		{
+			Name:      "mktopic",
+			Usage:     "create the specified topic(s) in your project",
+			ArgsUsage: "topic [topic ...]",
+			Before:    configure,
+			Action:    createTopic,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "dedup",
+					Usage: "the deduplication strategy of the topic (none, strict, datagram, key_grouped, unique_key, or unique_field)",
+				},
+				&cli.StringFlag{
+					Name:  "dedup-offset",
+					Usage: "keep the earliest or latest of the duplicate events (default: the server default)",
+				},
+				&cli.StringSliceFlag{
+					Name:  "dedup-keys",
+					Usage: "the metadata keys or data fields compared by the key_grouped, unique_key, and unique_field strategies",
+				},
+				&cli.StringFlag{
+					Name:  "sharding",
+					Usage: "the sharding strategy of the topic (no_sharding, consistent_key_hash, random, or publisher_ordering)",
+				},
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the created topics and their policies as json",
+				},
+			},
+		},
+		{
+			Name:      "rmtopic",
+			Usage:     "destroy the specified topic(s) and all of their events",
+			ArgsUsage: "topic [topic ...]",
+			Before:    configure,
+			Action:    destroyTopics,
+		},
+		{
+			Name:   "testdata",
+			Usage:  "generate testdata with duplicates",
+			Action: mktestdata,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "workload",
+					Aliases: []string{"w"},
+					Usage:   fmt.Sprintf("the registered workload to generate events from (%s)", strings.Join(workload.Names(), ", ")),
+					Value:   workload.RandomDuplicatesWorkload,
+				},
+				&cli.IntFlag{
+					Name:    "size",
+					Aliases: []string{"s"},
+					Usage:   "number of events to generate for the test",
+					Value:   1000,
+				},
+				&cli.IntFlag{
+					Name:    "num-keys",
+					Aliases: []string{"k"},
+					Usage:   "specify the number of available keys to add to events",
+					Value:   20,
+				},
+				&cli.Float64Flag{
+					Name:    "new-key-prob",
+					Aliases: []string{"p"},
+					Usage:   "specify the probability a new key value is generated",
+					Value:   0.6,
+				},
+				&cli.Float64Flag{
+					Name:    "duplicate-prob",
+					Aliases: []string{"d"},
+					Usage:   "specify the probability of duplicates in the dataset",
+					Value:   0.1,
+				},
+				&cli.StringFlag{
+					Name:  "distribution",
+					Usage: "the distribution keys and duplicates are selected from (uniform or zipf)",
+					Value: workload.Uniform,
+				},
+				&cli.Float64Flag{
+					Name:  "zipf-s",
+					Usage: "the exponent of the zipf distribution, larger values produce hotter keys (must be > 1)",
+					Value: workload.DefaultZipfS,
+				},
+				&cli.Float64Flag{
+					Name:  "error-rate",
+					Usage: "the fraction of log lines that are errors outside of bursts (logs workload)",
+					Value: workload.DefaultErrorRate,
+				},
+				&cli.Float64Flag{
+					Name:  "burst-error-rate",
+					Usage: "the fraction of log lines that are errors during an error burst (logs workload)",
+					Value: workload.DefaultBurstErrorRate,
+				},
+				&cli.Float64Flag{
+					Name:  "burst-prob",
+					Usage: "the probability that each log line starts an error burst, 0 disables bursts (logs workload)",
+					Value: workload.DefaultBurstProb,
+				},
+				&cli.IntFlag{
+					Name:  "burst-length",
+					Usage: "the number of log lines in an error burst (logs workload)",
+					Value: workload.DefaultBurstLength,
+				},
+				&cli.StringFlag{
+					Name:    "out",
+					Aliases: []string{"o"},
+					Usage:   "the location to write the data out to (zstd compressed if it ends in .zst)",
+					Value:   "events.pb.json",
+				},
+				&cli.StringFlag{
+					Name:    "format",
+					Aliases: []string{"f"},
+					Usage:   "the format to write the events in (pb, jsonl, or json), inferred from the extension of the output by default",
+				},
+				&cli.StringFlag{
+					Name:  "index",
+					Usage: "also write an index of the payload hashes to their occurrence counts and positions to this location",
+				},
+			},
+		},
+		{
+			Name:      "compare",
+			Usage:     "compare the throughput and latencies of two runs and fail on regressions",
+			ArgsUsage: "baseline current",
+			Action:    compare,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "preset",
+					Usage: "compare the latest stored runs of two versions instead of two runs (sdk or server), the args are the baseline and current versions (default the two most recent)",
+				},
+				&cli.StringFlag{
+					Name:    "benchmark",
+					Aliases: []string{"b"},
+					Usage:   "the benchmark whose stored runs are compared by the preset",
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "the maximum percent regression allowed for any compared metric",
+					Value: report.DefaultThreshold,
+				},
+				&cli.StringFlag{
+					Name:  "thresholds",
+					Usage: "per-metric percent thresholds (e.g. p99=5,throughput=10)",
+				},
+			},
+		},
+		{
+			Name:      "gate",
+			Usage:     "check a stored run against the baseline of its benchmark and fail on regressions",
+			ArgsUsage: "[run]",
+			Action:    gate,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "benchmark",
+					Aliases: []string{"b"},
+					Usage:   "gate the latest stored run of this benchmark if no run is specified",
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "the maximum percent regression from the baseline allowed for any compared metric",
+					Value: report.DefaultThreshold,
+				},
+				&cli.StringFlag{
+					Name:  "thresholds",
+					Usage: "per-metric percent regression budgets (e.g. p99=5,throughput=10)",
+				},
+				&cli.BoolFlag{
+					Name:  "update-baseline-on-pass",
+					Usage: "promote the run to the baseline of its benchmark if it passes all budgets",
+				},
+			},
+		},
+		{
+			Name:  "results",
+			Usage: "manage the history of benchmark runs in the results store",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "list the recorded benchmark runs from newest to oldest",
+					Action: listResults,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "benchmark",
+							Aliases: []string{"b"},
+							Usage:   "only list runs of the specified benchmark",
+						},
+						&cli.IntFlag{
+							Name:    "limit",
+							Aliases: []string{"l"},
+							Usage:   "the maximum number of runs to list",
+							Value:   20,
+						},
+					},
+				},
+				{
+					Name:   "trend",
+					Usage:  "print the trend of a metric across server releases or other groups of runs",
+					Action: trendResults,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "metric",
+							Aliases:  []string{"m"},
+							Usage:    "the flattened metric name or its last component (e.g. latencies.p99 or p99)",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:    "group-by",
+							Aliases: []string{"g"},
+							Usage:   "the experiment parameter to group runs by (e.g. server_version, server_id, sdk_version)",
+							Value:   store.ServerVersion,
+						},
+						&cli.StringFlag{
+							Name:    "benchmark",
+							Aliases: []string{"b"},
+							Usage:   "the benchmark whose runs are trended",
+							Value:   "blast",
+						},
+						&cli.IntFlag{
+							Name:    "limit",
+							Aliases: []string{"l"},
+							Usage:   "only include this many of the most recent runs (0 for all runs)",
+						},
+					},
+				},
+				{
+					Name:      "show",
+					Usage:     "show the configuration and metrics of a recorded run",
+					ArgsUsage: "id",
+					Action:    showResult,
+				},
+				{
+					Name:      "baseline",
+					Usage:     "show the baseline run of a benchmark and how it was promoted",
+					ArgsUsage: "benchmark",
+					Action:    showBaseline,
+				},
+				{
+					Name:   "prune",
+					Usage:  "remove old runs from the results store",
+					Action: pruneResults,
+					Flags: []cli.Flag{
+						&cli.IntFlag{
+							Name:  "keep-last",
+							Usage: "keep only this many of the most recent runs of each benchmark and label set",
+						},
+						&cli.DurationFlag{
+							Name:  "max-age",
+							Usage: "remove runs that are older than this duration",
+							Value: store.DefaultMaxAge,
+						},
+						&cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "list the runs that would be removed without removing them",
+						},
+					},
+				},
+				{
+					Name:   "export",
+					Usage:  "export recorded runs as newline delimited JSON",
+					Action: exportResults,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "benchmark",
+							Aliases: []string{"b"},
+							Usage:   "only export runs of the specified benchmark",
+						},
+						&cli.StringFlag{
+							Name:    "out",
+							Aliases: []string{"o"},
+							Usage:   "write the runs to a file instead of stdout (zstd compressed if it ends in .zst)",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal().Err(err).Msg("could not start cli app")
+	}
+}
+
+var (
+	conf        *options.Options
+	labels      map[string]string
+	emu         *emulator.Emulator
+	clockHealth *clock.Health
+	canary      *preflight.Result
+	floor       *calibrate.Floor
+	runLock     *lock.Lock
+	lockStatus  *lock.Status
+	stream      *report.JSONL
+	assertions  []report.Assertion // evaluated against the results once the run is over
+	created     []ulid.ULID        // the topics created by the run that are destroyed on cleanup
+
+	// The version of the config and the reports of a schedule, which are not reset by
+	// the scheduled runs
+	configVersion string
+	scheduled     *digest
+)
+
+// Resets the state of the previous run before a nested run so that the jsonl output,
+// assertions, and created topics of one scheduled run do not leak into the next. The
+// lock and the emulator are released by the After hook of the nested run and the
+// config version and reports of a schedule are kept.
+func resetRunState() {
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			log.Warn().Err(err).Msg("could not close the jsonl output of the previous run")
+		}
+	}
+
+	conf, labels, stream, assertions, created = nil, nil, nil, nil, nil
+	clockHealth, canary, floor = nil, nil, nil
+}
+
+func configure(c *cli.Context) error {
+	conf = options.New()
+	if creds := c.String("credentials"); creds != "" {
+		conf.Credentials = creds
+	}
+	if endpoint := c.String("endpoint"); endpoint != "" {
+		conf.Endpoint = endpoint
+	}
+	if authURL := c.String("auth-url"); authURL != "" {
+		conf.AuthURL = authURL
+	}
+	if topic := c.String("topic"); topic != "" {
+		conf.Topic = topic
+	}
+	if maxBytes := c.String("max-bytes"); maxBytes != "" {
+		var err error
+		if conf.MaxBytes, err = options.ParseBytes(maxBytes); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	if maxCost := c.String("max-cost"); maxCost != "" {
+		duration, events, bytes, err := options.ParseCost(maxCost)
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		// The stricter of the byte limits is enforced if both are specified
+		conf.MaxDuration, conf.MaxEvents = duration, events
+		if bytes > 0 && (conf.MaxBytes == 0 || bytes < conf.MaxBytes) {
+			conf.MaxBytes = bytes
+		}
+	}
+	if buckets := c.String("histogram"); buckets != "" {
+		var err error
+		if stats.HistogramBuckets, err = stats.ParseBuckets(buckets); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	if path := c.String("config"); path != "" {
+		// The report section of the config standardizes the latency summaries of the run
+		config, err := options.LoadConfig(path)
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		if config.Report != nil {
+			if err = config.Report.Apply(); err != nil {
+				return cli.Exit(err, 1)
+			}
+		}
+	}
+	if phases := c.String("phases"); phases != "" {
+		var err error
+		if conf.Phases, err = stats.ParsePhases(phases); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	conf.Warmup, conf.Cooldown = c.Duration("warmup"), c.Duration("cooldown")
+	if conf.Warmup < 0 || conf.Cooldown < 0 {
+		return cli.Exit("the warmup and cooldown must not be negative", 1)
+	}
+	if topicID := c.String("topic-id"); topicID != "" {
+		if _, err := ulid.Parse(topicID); err != nil {
+			return cli.Exit(fmt.Errorf("could not parse topic id: %w", err), 1)
+		}
+		conf.TopicID = topicID
+	}
+	conf.MinimalMetadata = c.Bool("minimal-metadata")
+
+	// The assertions are evaluated once the run is over so that it can be used as a CI gate
+	assertions = nil
+	if p99 := c.Duration("assert-p99"); p99 > 0 {
+		assertions = append(assertions, report.AssertMax("p99", p99))
+	}
+	if throughput := c.Float64("assert-throughput"); throughput > 0 {
+		assertions = append(assertions, report.Assertion{Metric: "throughput", Limit: throughput})
+	}
+	if c.IsSet("assert-error-rate") {
+		rate := c.Float64("assert-error-rate")
+		if rate < 0 || rate > 1 {
+			return cli.Exit("the asserted error rate must be a fraction between 0 and 1", 1)
+		}
+		assertions = append(assertions, report.Assertion{Metric: report.ErrorRate, Limit: rate, Max: true})
+	}
+	if c.Duration("assert-p99") < 0 || c.Float64("assert-throughput") < 0 {
+		return cli.Exit("the asserted p99 latency and throughput must not be negative", 1)
+	}
+
+	// Validate the payload up front since event factories are created by the benchmarks
+	conf.Payload, conf.Schema = c.String("payload"), c.String("schema")
+	if _, err := workload.NewPayload(conf.Payload, conf.Schema, 0); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	// A random seed is chosen if not specified so that it can be recorded and reproduced
+	if conf.Seed = c.Int64("seed"); conf.Seed == 0 {
+		conf.Seed = time.Now().UnixNano()
+	}
+
+	labels = make(map[string]string)
+	for _, label := range c.StringSlice("label") {
+		key, val, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return cli.Exit(fmt.Errorf("could not parse label %q: expected key=value", label), 1)
+		}
+		labels[key] = val
+	}
+	if c.Bool("local-emulator") || c.Command.Name == "smoke" {
+		startEmulator()
+	}
+	return nil
+}
+
+// Starts the in-process emulator and connects all benchmark clients to it.
+func startEmulator() {
+	if emu == nil {
+		emu = emulator.New(conf.Topic)
+	}
+	conf.Mock = emu.Mock()
+	conf.Endpoint = emulator.ServerID
+	conf.TopicID = ""
+	log.Info().Str("topic", conf.Topic).Msg("running against the local emulator")
+}
+
+// Checks the host clock before timing sensitive runs; in accuracy mode an unhealthy
+// clock aborts the run, otherwise a warning is logged. The health is recorded in the
+// experiment metadata of the report.
+func checkClock(c *cli.Context) (err error) {
+	if clockHealth, err = clock.Check(); err != nil {
+		return cli.Exit(fmt.Errorf("could not check host clock: %w", err), 1)
+	}
+
+	if err = clockHealth.Validate(c.Duration("max-clock-error")); err != nil {
+		if c.Bool("accuracy") {
+			return cli.Exit(err, 1)
+		}
+		log.Warn().Err(err).Msg("host clock is unhealthy, cross-host latencies may be inaccurate")
+	}
+
+	if !clockHealth.Supported {
+		log.Debug().Msg("host clock synchronization status is unavailable")
+	}
+
+	if c.Bool("calibrate") {
+		return calibrateFloor()
+	}
+	return nil
+}
+
+// Measures the minimum achievable round trip on the host and to the server so that the
+// latencies of the run can be interpreted against the floor recorded in the report.
+func calibrateFloor() (err error) {
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer client.Close()
+
+	if floor, err = calibrate.Measure(context.Background(), client, calibrate.DefaultSamples); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	log.Info().Dur("loopback", floor.Loopback.Fastest()).Dur("server", floor.Server.Fastest()).Msg("latency floor calibrated")
+	return nil
+}
+
+// Publishes and consumes a canary event on the benchmark topic before the measurement
+// phase so that permission and reachability problems fail fast with a specific error.
+func checkPreflight(c *cli.Context) (err error) {
+	// The lock is taken first so that the canary does not interfere with another run
+	if err = lockRun(c); err != nil {
+		return err
+	}
+
+	if c.Bool("skip-preflight") {
+		return nil
+	}
+
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer client.Close()
+
+	if canary, err = preflight.Probe(context.Background(), client, conf.TopicRef()); err != nil {
+		return cli.Exit(fmt.Errorf("preflight check failed: %w", err), 1)
+	}
+
+	log.Info().Dur("ack", canary.Ack).Dur("delivery", canary.Delivery).Msg("preflight canary round trip succeeded")
+	return nil
+}
+
+// Takes the lock of the endpoint and topic if enabled so that another run on the host
+// that is using the same topic is detected. A forced run proceeds without the lock and
+// the contention is recorded in the experiment metadata of the report. Runs against the
+// local emulator cannot interfere with each other and are not locked.
+func lockRun(c *cli.Context) (err error) {
+	if !c.Bool("lock") || emu != nil || runLock != nil {
+		return nil
+	}
+
+	path := lock.Path(c.String("lock-dir"), conf.Endpoint, conf.Topic)
+	if runLock, lockStatus, err = lock.Acquire(path, c.Command.Name, c.Bool("force")); err != nil {
+		if errors.Is(err, lock.ErrLocked) {
+			return cli.Exit(fmt.Errorf("%w; use --force to run anyway", err), 1)
+		}
+		return cli.Exit(fmt.Errorf("could not take the run lock: %w", err), 1)
+	}
+
+	switch {
+	case lockStatus.Forced:
+		log.Warn().Str("holder", lockStatus.Holder.String()).Msg("another run holds the lock of the topic; results may be affected by the concurrent run")
+	case lockStatus.Stale != nil:
+		log.Debug().Str("holder", lockStatus.Stale.String()).Msg("replaced the stale lock of a run that has exited")
+	}
+	return nil
+}
+
+// Reproduces a previous run by running the app with the command line arguments in the
+// manifest; the flags that control where the run is recorded are passed through.
+func rerun(c *cli.Context) (err error) {
+	if c.NArg() > 0 {
+		return runProfile(c)
+	}
+
+	if !c.IsSet("manifest") {
+		return cli.Exit("specify a profile to run or the manifest of a run to reproduce", 1)
+	}
+
+	var manifest *options.Manifest
+	if manifest, err = options.LoadManifest(c.String("manifest")); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if manifest.ClientVersion != benchmarks.Version() {
+		log.Warn().Str("manifest", manifest.ClientVersion).Str("client", benchmarks.Version()).Msg("manifest was written by a different client version")
+	}
+
+	log.Info().Strs("args", manifest.Args()).Msg("reproducing run from manifest")
+	return runArgs(c, manifest, "store", "no-store", "write-manifest")
+}
+
+// Runs the named profile from the benchmark config; the global flags that only affect
+// where the run executes or is recorded may be specified on the command line.
+func runProfile(c *cli.Context) (err error) {
+	if c.NArg() > 1 || c.IsSet("manifest") {
+		return cli.Exit("specify either a single profile or a manifest to run", 1)
+	}
+
+	var manifest *options.Manifest
+	if manifest, err = profileManifest(c, c.Args().First()); err != nil {
+		return err
+	}
+
+	log.Info().Str("profile", c.Args().First()).Strs("args", manifest.Args()).Msg("running benchmark profile")
+	return runArgs(c, manifest, profileOverrides...)
+}
+
+// The global flags that may override the flags of a profile on the command line.
+var profileOverrides = []string{"store", "no-store", "write-manifest", "local-emulator", "output", "credentials", "config", "pushgateway", "lock", "lock-dir", "force"}
+
+// Loads the manifest of the named profile from the benchmark config.
+func profileManifest(c *cli.Context, name string) (_ *options.Manifest, err error) {
+	if !c.IsSet("config") {
+		return nil, cli.Exit("specify the benchmark config with --config to run a profile", 1)
+	}
+
+	var config *options.Config
+	if config, err = options.LoadConfig(c.String("config")); err != nil {
+		return nil, cli.Exit(err, 1)
+	}
+
+	var profile *options.Profile
+	if profile, err = config.Profile(name); err != nil {
+		return nil, cli.Exit(err, 1)
+	}
+	return profile.Manifest(name), nil
+}
+
+// Runs the named profile periodically so that the performance of a deployment can be
+// monitored without an external scheduler. Each run is recorded in the results store
+// and its metrics are pushed to the Pushgateway if one is specified. The config is
+// watched so that the profile and its interval can be changed without restarting the
+// schedule; each run records the version of the config in its experiment metadata. The
+// deltas of each run are from the previous run of the profile and if the output is
+// email, the reports of the runs are sent in a single email when the schedule stops. A
+// run that fails is logged and does not stop the schedule. If a run takes longer than
+// the interval, the runs that were missed are skipped.
+func schedule(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify the profile to run on a schedule", 1)
+	}
+
+	if c.Duration("every") < 0 {
+		return cli.Exit("the schedule interval must be positive", 1)
+	}
+
+	if c.Int("runs") < 0 {
+		return cli.Exit("the number of runs must not be negative", 1)
+	}
+
+	if !c.IsSet("config") {
+		return cli.Exit("specify the benchmark config with --config to run a profile", 1)
+	}
+
+	// Check the profile up front rather than failing every run of the schedule
+	sched := &profileSchedule{name: c.Args().First(), every: c.Duration("every"), reloaded: make(chan struct{}, 1)}
+	var watcher *options.Watcher
+	if watcher, err = options.NewWatcher(c.String("config"), options.DefaultWatchInterval, sched.reload); err != nil {
+		return cli.Exit(err, 1)
+	}
+	<-sched.reloaded
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Watch(ctx)
+
+	if c.Bool("no-store") || c.String("store") == "" {
+		log.Warn().Msg("scheduled runs will not be recorded in the results store")
+	}
+
+	// Check the email configuration before the runs rather than when the schedule stops
+	if isEmail(c) {
+		if _, err = report.New(c.String("output")); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	// Failed runs exit the process by default but should not stop the schedule
+	handler := c.App.ExitErrHandler
+	c.App.ExitErrHandler = func(*cli.Context, error) {}
+	defer func() { c.App.ExitErrHandler = handler }()
+
+	scheduled = &digest{profile: sched.name}
+	defer func() { configVersion, scheduled = "", nil }()
+
+	// The schedule stops after the current run if interrupted; the run itself is also
+	// interrupted by the signal so that its partial results are recorded.
+	quit := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	signal.Notify(quit, os.Interrupt)
+	defer close(quit)
+	defer signal.Stop(quit)
+
+	go func() {
+		if _, ok := <-quit; ok {
+			signal.Stop(quit)
+			log.Warn().Msg("interrupted, stopping the schedule after the current run")
+			close(stopped)
+		}
+	}()
+
+	last := time.Now()
+runs:
+	for run := 1; c.Int("runs") == 0 || run <= c.Int("runs"); run++ {
+		var profile *options.Profile
+		profile, _, configVersion = sched.active()
+
+		manifest := profile.Manifest(sched.name)
+		if c.IsSet("label") {
+			manifest.Global["label"] = append(manifest.Global["label"], c.StringSlice("label")...)
+		}
+
+		log.Info().Str("profile", sched.name).Int("run", run).Str("config_version", configVersion).Strs("args", manifest.Args()).Msg("running scheduled benchmark profile")
+		if err = runArgs(c, manifest, profileOverrides...); err != nil {
+			log.Error().Err(err).Str("profile", sched.name).Int("run", run).Msg("scheduled run failed")
+		}
+
+		if run == c.Int("runs") {
+			break
+		}
+
+		// Skip the runs that were missed while the run was in progress; the next run is
+		// rescheduled if the interval of the profile changes while waiting for it.
+		next, skipped := sched.next(last)
+		if skipped > 0 {
+			log.Warn().Int("skipped", skipped).Msg("run took longer than the schedule interval")
+		}
+
+	wait:
+		for {
+			log.Info().Time("next", next).Msg("waiting for the next scheduled run")
+			select {
+			case <-time.After(time.Until(next)):
+				break wait
+			case <-sched.reloaded:
+				next, _ = sched.next(last)
+			case <-stopped:
+				break runs
+			}
+		}
+		last = next
+	}
+	return scheduled.send(c)
+}
+
+// Collects the reports of the runs of a schedule so that they are emailed together in a
+// single summary once the schedule stops, rather than sending an email for every run.
+type digest struct {
+	profile string
+	reports []*report.Report
+}
+
+func (d *digest) Write(rep *report.Report) error {
+	d.reports = append(d.reports, rep)
+	return nil
+}
+
+// Returns the metrics of the previous run of the profile from the results store as the
+// baseline of a scheduled run; if the runs are not stored, the previous run of the
+// schedule is used. Returns nil if the profile has not been run before.
+func (d *digest) baseline(c *cli.Context, benchmark string) (_ benchmarks.Metrics, err error) {
+	if c.Bool("no-store") || c.String("store") == "" {
+		for i := len(d.reports) - 1; i >= 0; i-- {
+			if d.reports[i].Benchmark == benchmark {
+				return d.reports[i].Metrics, nil
+			}
+		}
+		return nil, nil
+	}
+
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var runs []*store.Run
+	if runs, err = db.List(benchmark, 0); err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs {
+		if run.Labels["profile"] == d.profile {
+			previous := make(metrics.Metrics)
+			if err = json.Unmarshal(run.Metrics, &previous); err != nil {
+				return nil, err
+			}
+			return previous, nil
+		}
+	}
+	return nil, nil
+}
+
+// Emails the reports of the schedule in a single summary if the output is email.
+func (d *digest) send(c *cli.Context) (err error) {
+	if len(d.reports) == 0 || !isEmail(c) {
+		return nil
+	}
+
+	var out report.Writer
+	if out, err = report.New(c.String("output")); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if err = out.(*report.Email).Send(d.reports...); err != nil {
+		return cli.Exit(fmt.Errorf("could not email the scheduled runs: %w", err), 1)
+	}
+	log.Info().Int("runs", len(d.reports)).Msg("emailed the results of the scheduled runs")
+	return nil
+}
+
+// Returns true if the reports are emailed.
+func isEmail(c *cli.Context) bool {
+	return strings.EqualFold(c.String("output"), report.OutputEmail)
+}
+
+// The profile of a schedule from the active version of the config, which is replaced
+// by the watcher of the config when the config changes.
+type profileSchedule struct {
+	sync.Mutex
+	name     string
+	every    time.Duration // the interval of the command if the profile does not set one
+	profile  *options.Profile
+	interval time.Duration
+	version  string
+	reloaded chan struct{} // signaled when a new version of the config is loaded
+}
+
+// Reloads the profile from a new version of the config; if the config is invalid or no
+// longer has a valid profile, the previous version remains active.
+func (s *profileSchedule) reload(data []byte, version string) (err error) {
+	var config *options.Config
+	if config, err = options.ParseConfig(data); err != nil {
+		return err
+	}
+
+	var profile *options.Profile
+	if profile, err = config.Profile(s.name); err != nil {
+		return err
+	}
+
+	interval := profile.Every
+	if interval == 0 {
+		interval = s.every
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("specify the interval of profile %q with --every or in the config", s.name)
+	}
+
+	s.Lock()
+	s.profile, s.interval, s.version = profile, interval, version
+	s.Unlock()
+
+	select {
+	case s.reloaded <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Returns the active profile, its interval, and the version of its config.
+func (s *profileSchedule) active() (*options.Profile, time.Duration, string) {
+	s.Lock()
+	defer s.Unlock()
+	return s.profile, s.interval, s.version
+}
+
+// Returns the first start of a run after now at the active interval from the last run,
+// and the number of runs that were skipped since they were missed.
+func (s *profileSchedule) next(last time.Time) (next time.Time, skipped int) {
+	_, every, _ := s.active()
+	for next = last.Add(every); !next.After(time.Now()); next = next.Add(every) {
+		skipped++
+	}
+	return next, skipped
+}
+
+// Runs the command of the manifest, overriding the manifest with the specified global
+// flags if they were set on the command line.
+func runArgs(c *cli.Context, manifest *options.Manifest, overrides ...string) error {
+	if manifest.Global == nil {
+		manifest.Global = make(map[string][]string)
+	}
+
+	for _, name := range overrides {
+		if c.IsSet(name) {
+			manifest.Global[name] = []string{fmt.Sprint(c.Value(name))}
+		}
+	}
+
+	resetRunState()
+	args := append([]string{c.App.Name}, manifest.Args()...)
+	return c.App.RunContext(c.Context, args)
+}
+
+// Runs every benchmark with a small workload against the emulator so that changes to
+// the harness can be validated end-to-end without access to an Ensign server.
+func runSmoke(c *cli.Context) (err error) {
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	conf.Operations = 100
+	conf.DataSize = 256
+	conf.Interval = time.Millisecond
+
+	mini := *conf
+	mini.Operations = 10
+	mini.Retention = 4 * conf.Operations * uint64(conf.DataSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	b := blast.New(conf)
+	e := e2e.New(conf)
+	e.DrainTimeout = 10 * time.Second
+	s := sustain.New(&mini)
+	r := retention.New(&mini)
+	d := consume.New(conf)
+	d.IdleTimeout = time.Second
+	k := seek.New(conf)
+	w := seek.NewCache(conf)
+	w.Delay = 0
+	q := seek.NewQuery(conf)
+	q.Concurrency, q.Repeat = []int{1, 2}, 1
+	rp := ramp.New(conf)
+	rp.StartRate, rp.Increment, rp.MaxRate = 100, 100, 200
+	rp.Step, rp.SLO = 100*time.Millisecond, time.Second
+	ai := ramp.NewAIMD(conf)
+	ai.MaxConcurrency, ai.Window, ai.Duration = 4, 50*time.Millisecond, 200*time.Millisecond
+	ai.Ceiling = time.Second
+	cm := commit.New(conf)
+	cm.ReconnectEvery, cm.IdleTimeout = 30, time.Second
+	td := teardown.New(conf)
+	td.Trials, td.Burst, td.Settle = 2, 20, 10*time.Millisecond
+	sc := scale.New(conf)
+	sc.Steps, sc.Lookups, sc.Trickle = []int{5, 10}, 5, 1
+	cs := consistency.New(&mini)
+	cs.Interval = time.Millisecond
+	rl := limits.NewRateProbe(conf)
+	rl.StartRate, rl.MaxRate, rl.Step = 100, 200, 100*time.Millisecond
+	sz := limits.NewSizeProbe(conf)
+	sz.MaxSize, sz.Precision = 64*1024, 1024
+
+	var source *workload.StreamReader
+	if source, err = smokeStream(int(mini.Operations)); err != nil {
+		return cli.Exit(fmt.Errorf("replay smoke test failed: %w", err), 1)
+	}
+	re := replay.New(&mini, source)
+
+	benches := []struct {
+		name    string
+		run     func(context.Context) error
+		results func() (benchmarks.Metrics, error)
+	}{
+		{"blast", b.Run, b.Results},
+		{"e2e", e.Run, e.Results},
+		{"sustain", s.Run, s.Results},
+		{"retention", r.Run, r.Results},
+		{"consume", d.Run, d.Results},
+		{"seek", k.Run, k.Results},
+		{"cache", w.Run, w.Results},
+		{"query", q.Run, q.Results},
+		{"ramp", rp.Run, rp.Results},
+		{"aimd", ai.Run, ai.Results},
+		{"commit", cm.Run, cm.Results},
+		{"teardown", td.Run, td.Results},
+		{"scale", sc.Run, sc.Results},
+		{"consistency", cs.Run, cs.Results},
+		{"replay", re.Run, re.Results},
+		{"ratelimits", rl.Run, rl.Results},
+		{"limits", sz.Run, sz.Results},
+	}
+
+	results := make(metrics.Metrics, len(benches))
+	for _, bench := range benches {
+		started := time.Now()
+		if err = bench.run(ctx); err != nil {
+			return cli.Exit(fmt.Errorf("%s smoke test failed: %w", bench.name, err), 1)
+		}
+
+		if results[bench.name], err = bench.results(); err != nil {
+			return cli.Exit(fmt.Errorf("%s smoke test failed: %w", bench.name, err), 1)
+		}
+		log.Info().Str("benchmark", bench.name).Dur("duration", time.Since(started)).Msg("smoke test passed")
+	}
+
+	results["dropped"] = emu.Dropped()
+	return writeReport(c, &report.Report{Benchmark: "smoke", Metrics: results})
+}
+
+// Returns a stream of ticker events for the replay smoke test.
+func smokeStream(n int) (_ *workload.StreamReader, err error) {
+	buf := &bytes.Buffer{}
+	var writer *workload.StreamWriter
+	if writer, err = workload.NewStreamWriter(buf, workload.FormatPB); err != nil {
+		return nil, err
+	}
+
+	ticker := workload.NewTicker(workload.DefaultTickerSymbols, workload.NewRand(conf.Seed))
+	for i := 0; i < n; i++ {
+		if err = writer.Write(ticker.Next()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return workload.NewStreamReader(buf, workload.FormatPB)
+}
+
+func runEstimate(c *cli.Context) (err error) {
+	if ops := c.String("operations"); ops != "" {
+		if conf.Operations, err = options.ParseCount(ops); err != nil {
+			return cli.Exit(fmt.Errorf("could not parse --operations: %w", err), 1)
+		}
+	}
+
+	if size := c.String("data-size"); size != "" {
+		var n uint64
+		if n, err = options.ParseBytes(size); err != nil {
+			return cli.Exit(fmt.Errorf("could not parse --data-size: %w", err), 1)
+		}
+		conf.DataSize = int64(n)
+	}
+
+	if conf.Operations == 0 || conf.DataSize <= 0 {
+		return cli.Exit("the operations and data size must be positive", 1)
+	}
+
+	if conf.Rate = c.Float64("rate"); conf.Rate < 0 {
+		return cli.Exit("the rate must not be negative", 1)
+	}
+
+	if interval := c.Duration("interval"); interval > 0 {
+		conf.Interval = interval
+	}
+
+	// Priors are optional so the estimate is made without them if the store is unusable
+	benchmark := c.String("benchmark")
+	var prior *estimate.Prior
+	if !c.Bool("no-store") {
+		var runs []*store.Run
+		if runs, err = listRuns(c.String("store"), benchmark); err != nil {
+			log.Warn().Err(err).Msg("could not read throughput priors from the results store")
+		}
+		prior = estimate.Priors(runs, conf.Endpoint, conf.DataSize)
+	}
+
+	var out report.Writer
+	if out, err = report.New(c.String("output")); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if err = out.Write(&report.Report{Benchmark: "estimate", Metrics: estimate.New(benchmark, conf, prior).Metrics()}); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return nil
+}
+
+// Lists the stored runs of the benchmark from newest to oldest.
+func listRuns(path, benchmark string) (_ []*store.Run, err error) {
+	var db *store.SQLite
+	if db, err = store.Open(path); err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.List(benchmark, 0)
+}
+
+func runBlast(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if topics := c.String("topics"); topics != "" {
+		if conf.TopicID != "" {
+			return cli.Exit("specify either a topic id or multiple topics, not both", 1)
+		}
+
+		if conf.Topics, err = options.ParseTargets(topics); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+	conf.Reservoir = c.Int("reservoir")
+	if err = configureWorkload(c); err != nil {
+		return err
+	}
+
+	if err = configureWarmup(c); err != nil {
+		return err
+	}
+
+	if conf.Malformed = c.Float64("malformed"); conf.Malformed < 0 || conf.Malformed > 1 {
+		return cli.Exit("malformed fraction must be between 0 and 1", 1)
+	}
+
+	if conf.Rate = c.Float64("rate"); conf.Rate < 0 {
+		return cli.Exit("the publish rate must not be negative", 1)
+	}
+
+	conf.Streams, conf.Connections = c.Int("streams"), c.Int("connections")
+	if err = conf.CheckStreams(); err != nil {
+		return cli.Exit(err, 1)
+	}
+	conf.PreDial = c.Bool("pre-dial")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	b := blast.New(conf)
+	defer func() { created = append(created, b.Created()...) }()
+
+	// Samples are exported as they are measured rather than retained for the export
+	var (
+		f       io.WriteCloser
+		samples *export.SampleWriter
+	)
+	if path := c.String("parquet"); path != "" {
+		if f, err = compress.Create(path); err != nil {
+			return cli.Exit(fmt.Errorf("could not export samples: %w", err), 1)
+		}
+		defer f.Close()
+
+		if samples, err = export.NewSampleWriter(f); err != nil {
+			return cli.Exit(fmt.Errorf("could not export samples: %w", err), 1)
+		}
+		b.OnSample = samples.Write
+	}
+
+	stopProgress := func() {}
+	if c.Bool("progress") {
+		stopProgress = showProgress(b)
+	}
+
+	// The harness stops the run if interrupted and reports the partial results of a panic
+	var results benchmarks.Metrics
+	results, err = benchmarks.Run(ctx, b)
+	stopProgress()
+	if err != nil {
+		return exitRun(c, "blast", results, err)
+	}
+
+	if samples != nil {
+		if err = samples.Close(); err != nil {
+			return cli.Exit(fmt.Errorf("could not export samples: %w", err), 1)
+		}
+
+		// The end of a compressed stream is only flushed when the file is closed
+		if err = f.Close(); err != nil {
+			return cli.Exit(fmt.Errorf("could not export samples: %w", err), 1)
+		}
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "blast", Metrics: results})
+}
+
+// The exit code of a run that panicked, distinct from the exit code of a failed run so
+// that scripts can tell that the partial results of the run were still reported.
+const exitPanicked = 3
+
+// The exit code of a run that completed but failed one or more of its assertions.
+const exitAssertions = 4
+
+// A benchmark that is run by runRecovered rather than by the benchmark harness; it must
+// be a monitor so that every command dumps its interim statistics on SIGUSR1.
+type recoverable interface {
+	benchmarks.Monitor
+	Run(context.Context) error
+	Results() (benchmarks.Metrics, error)
+}
+
+// Runs the benchmark, recovering a panic of the run so that the data of a long run is
+// not lost as described by exitRun. The progress of the benchmark is dumped whenever
+// the process receives SIGUSR1 until the run is over.
+func runRecovered(ctx context.Context, c *cli.Context, name string, b recoverable) error {
+	defer benchmarks.DumpOnSignal(name, b)()
+	partial, err := benchmarks.Recover(ctx, b.Run, b.Results)
+	return exitRun(c, name, partial, err)
+}
+
+// Returns the exit of a benchmark run that failed. If the run panicked, the stack of the
+// panic is logged and the partial results of the run are reported with a panicked exit
+// reason before exiting with the panicked exit code.
+func exitRun(c *cli.Context, name string, partial benchmarks.Metrics, err error) error {
+	var panicked *benchmarks.PanicError
+	if !errors.As(err, &panicked) {
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		return nil
+	}
+
+	log.Error().Str("benchmark", name).Interface("panic", panicked.Value).Str("stack", string(panicked.Stack)).Msg("benchmark panicked, reporting partial results")
+	if partial == nil {
+		log.Error().Err(err).Msg("could not collect the partial results of the run")
+		return cli.Exit(panicked, exitPanicked)
+	}
+
+	if err = writeReport(c, &report.Report{Benchmark: name, Metrics: partial}); err != nil {
+		log.Error().Err(err).Msg("could not report the partial results of the run")
+	}
+	return cli.Exit(panicked, exitPanicked)
+}
+
+func writeReport(c *cli.Context, rep *report.Report) (err error) {
+	if path := c.String("baseline"); path != "" && rep.Baseline == nil {
+		if rep.Baseline, err = report.LoadMetrics(path); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	// The deltas of a scheduled run are from the previous run of the profile
+	if scheduled != nil && rep.Baseline == nil {
+		if rep.Baseline, err = scheduled.baseline(c, rep.Benchmark); err != nil {
+			log.Warn().Err(err).Msg("could not load the previous run of the scheduled profile")
+		}
+	}
+
+	// Record the host clock health, host tunables, preflight check, and latency floor with the experiment metadata
+	if m, ok := rep.Metrics.(metrics.Metrics); ok {
+		experiment, ok := m["experiment"].(map[string]interface{})
+		if !ok {
+			experiment = make(map[string]interface{})
+			m["experiment"] = experiment
+		}
+
+		if clockHealth != nil {
+			experiment["clock"] = clockHealth
+		}
+
+		if _, ok := experiment["tunables"]; !ok {
+			experiment["tunables"] = tunables.Capture()
+		}
+
+		if canary != nil {
+			experiment["preflight"] = canary
+		}
+
+		if floor != nil {
+			experiment["calibration"] = floor
+		}
+
+		if lockStatus != nil {
+			experiment["lock"] = lockStatus
+		}
+
+		if emu != nil {
+			experiment["local_emulator"] = true
+		}
+
+		if configVersion != "" {
+			experiment["config_version"] = configVersion
+		}
+
+		// Record the client SDK so that runs can be grouped by SDK version for comparison
+		experiment["sdk_version"] = benchmarks.SDKVersion()
+		experiment["sdk_release"] = benchmarks.SDKRelease()
+
+		if _, ok := experiment["guard"]; !ok && (conf.MaxDuration > 0 || conf.MaxEvents > 0 || conf.MaxBytes > 0) {
+			experiment["guard"] = conf.Guard()
+		}
+	}
+
+	// Execute any custom result processors before the report is written.
+	if err = report.Process(rep); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	// Failed assertions are included in the report as violations
+	var outcomes []report.Outcome
+	if outcomes, err = report.Assert(rep.Metrics, assertions); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	var failed int
+	for _, outcome := range outcomes {
+		if !outcome.Passed {
+			rep.Violations = append(rep.Violations, outcome.Violation())
+			failed++
+		}
+	}
+
+	// A nil jsonl stream must not be assigned to the writer since it would not be nil
+	var out report.Writer
+	var jsonl *report.JSONL
+	if jsonl, err = jsonlOutput(c, rep.Benchmark); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if jsonl != nil {
+		out = jsonl
+	} else if scheduled != nil && isEmail(c) {
+		out = scheduled
+	} else if out, err = report.New(c.String("output")); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if err = out.Write(rep); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	manifest := runManifest(c, rep)
+	if path := c.String("write-manifest"); path != "" {
+		if err = manifest.Write(path); err != nil {
+			return cli.Exit(fmt.Errorf("could not write manifest: %w", err), 1)
+		}
+	}
+
+	// Failing to record the run does not fail the benchmark since the report is written
+	if !c.Bool("no-store") && c.String("store") != "" {
+		if err := recordRun(c.String("store"), rep, manifest); err != nil {
+			log.Warn().Err(err).Msg("could not record run in results store")
+		}
+	}
+
+	// Failing to push the metrics of the run also does not fail the benchmark
+	if url := c.String("pushgateway"); url != "" {
+		if err := report.NewPushgateway(url, labels).Write(rep); err != nil {
+			log.Warn().Err(err).Msg("could not push metrics to the pushgateway")
+		}
+	}
+
+	if len(outcomes) > 0 {
+		fmt.Fprintf(os.Stderr, "\nassertions: %d passed, %d failed\n", len(outcomes)-failed, failed)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(os.Stderr, "  %s\n", outcome)
+		}
+	}
+
+	if failed > 0 {
+		return cli.Exit(fmt.Errorf("%d of %d assertion(s) failed", failed, len(outcomes)), exitAssertions)
+	}
+	return nil
+}
+
+// Returns the jsonl output of the run if the jsonl output mode is selected, opening it
+// the first time it is requested so that a benchmark can append its intervals as it
+// runs and the final results are appended to the same output. Returns nil otherwise.
+func jsonlOutput(c *cli.Context, benchmark string) (_ *report.JSONL, err error) {
+	if !strings.EqualFold(c.String("output"), report.OutputJSONL) {
+		return nil, nil
+	}
+
+	if stream == nil {
+		if path := c.String("out"); path != "" {
+			if stream, err = report.OpenJSONL(path, benchmark); err != nil {
+				return nil, err
+			}
+		} else {
+			stream = report.NewJSONL(os.Stdout, benchmark)
+		}
+	}
+	return stream, nil
+}
+
+// Returns a callback that appends each interval of the run to the jsonl output; the
+// run is not interrupted if an interval cannot be written.
+func appendInterval[T any](out *report.JSONL) func(T) {
+	return func(interval T) {
+		if err := out.Interval(interval); err != nil {
+			log.Warn().Err(err).Msg("could not append interval to jsonl output")
+		}
+	}
+}
+
+// Creates the manifest of the run from the effective value of every flag along with the
+// resolved options and the versions reported with the experiment metadata.
+func runManifest(c *cli.Context, rep *report.Report) *options.Manifest {
+	manifest := &options.Manifest{
+		Command:       c.Command.Name,
+		Global:        flagValues(c, c.App.Flags),
+		Flags:         flagValues(c, c.Command.Flags),
+		Options:       conf,
+		Labels:        labels,
+		ClientVersion: benchmarks.Version(),
+		Created:       time.Now(),
+	}
+
+	// Record the resolved seed so that randomly seeded runs are also reproduced
+	manifest.Global["seed"] = []string{strconv.FormatInt(conf.Seed, 10)}
+
+	if m, ok := rep.Metrics.(metrics.Metrics); ok {
+		if experiment, ok := m["experiment"].(map[string]interface{}); ok {
+			manifest.ServerVersion, _ = experiment["server_version"].(string)
+			manifest.Seeds, _ = experiment["seeds"].(map[string]int64)
+		}
+	}
+	return manifest
+}
+
+// Returns the effective value of every flag so that the command line can be reproduced;
+// empty values and the flags that control where the run is recorded are skipped.
+func flagValues(c *cli.Context, flags []cli.Flag) map[string][]string {
+	values := make(map[string][]string, len(flags))
+	for _, flag := range flags {
+		name := flag.Names()[0]
+		switch name {
+		case "help", "version", "store", "no-store", "write-manifest":
+			continue
+		}
+
+		if _, ok := flag.(*cli.StringSliceFlag); ok {
+			if vals := c.StringSlice(name); len(vals) > 0 {
+				values[name] = vals
+			}
+			continue
+		}
+
+		if val := fmt.Sprint(c.Value(name)); val != "" {
+			values[name] = []string{val}
+		}
+	}
+	return values
+}
+
+// Records the manifest and metrics of the benchmark run in the results store.
+func recordRun(path string, rep *report.Report, manifest *options.Manifest) (err error) {
+	var db *store.SQLite
+	if db, err = store.Open(path); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	run := &store.Run{Benchmark: rep.Benchmark, Labels: labels}
+	if run.Config, err = json.Marshal(manifest); err != nil {
+		return err
+	}
+
+	if run.Metrics, err = json.Marshal(rep.Metrics); err != nil {
+		return err
+	}
+
+	if err = db.Save(run); err != nil {
+		return err
+	}
+	log.Debug().Str("id", run.ID).Str("store", path).Msg("run recorded in results store")
+	return nil
+}
+
+func runRetention(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+	conf.Retention = c.Uint64("retention")
+	conf.Overshoot = c.Float64("overshoot")
+
+	b := retention.New(conf)
+
+	var out *report.JSONL
+	if out, err = jsonlOutput(c, "retention"); err != nil {
+		return cli.Exit(err, 1)
+	}
+	if out != nil {
+		b.OnWindow = appendInterval[*retention.Window](out)
+	}
+
+	if err = runRecovered(context.Background(), c, "retention", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if path := c.String("parquet"); path != "" {
+		windows := b.Windows()
+		rows := make([]export.Interval, 0, len(windows))
+		for _, w := range windows {
+			row := export.LatencyInterval("retention", uint64(w.Index), w.Latencies)
+			row.Bytes = int64(w.Published)
+			rows = append(rows, row)
+		}
+
+		if err = export.WriteFile(path, func(w io.Writer) error {
+			return export.WriteIntervals(w, rows)
+		}); err != nil {
+			return cli.Exit(fmt.Errorf("could not export windows: %w", err), 1)
+		}
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "retention", Metrics: results})
+}
+
+func runReplay(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	path := c.String("file")
+	format := c.String("format")
+	if format == "" {
+		if format = workload.FormatFromPath(path); format == "" {
+			format = workload.FormatJSONL
+		}
+	}
+
+	// Compressed event streams are decompressed transparently, including from stdin
+	var in io.ReadCloser
+	if path == "-" {
+		in, err = compress.NewReader(os.Stdin)
+	} else {
+		in, err = compress.Open(path)
+	}
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer in.Close()
+
+	var source *workload.StreamReader
+	if source, err = workload.NewStreamReader(in, format); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	conf.Operations = c.Uint64("operations")
+	b := replay.New(conf, source)
+	if err = runRecovered(context.Background(), c, "replay", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "replay", Metrics: results})
+}
+
+func runRateProbe(c *cli.Context) (err error) {
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := limits.NewRateProbe(conf)
+	b.StartRate = c.Float64("start-rate")
+	b.MaxRate = c.Float64("max-rate")
+	b.Factor = c.Float64("factor")
+	b.Step = c.Duration("step")
+	b.Threshold = c.Float64("threshold")
+
+	if err = runRecovered(context.Background(), c, "ratelimits", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "ratelimits", Metrics: results})
+}
+
+func runSizeProbe(c *cli.Context) (err error) {
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	b := limits.NewSizeProbe(conf)
+	for flag, size := range map[string]*int{"min-size": &b.MinSize, "max-size": &b.MaxSize, "precision": &b.Precision} {
+		var n uint64
+		if n, err = options.ParseBytes(c.String(flag)); err != nil {
+			return cli.Exit(fmt.Errorf("could not parse --%s: %w", flag, err), 1)
+		}
+		*size = int(n)
+	}
+	b.Timeout = c.Duration("reply-timeout")
+	b.Metadata = !c.Bool("skip-metadata")
+
+	if err = runRecovered(context.Background(), c, "limits", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "limits", Metrics: results})
+}
+
+func runTeardown(c *cli.Context) (err error) {
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := teardown.New(conf)
+	b.Trials = c.Int("trials")
+	b.Burst = c.Uint64("burst")
+	b.Delay = c.Duration("delay")
+	b.Grace = c.Duration("grace")
+	b.Settle = c.Duration("settle")
+
+	if err = runRecovered(context.Background(), c, "teardown", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "teardown", Metrics: results})
+}
+
+func runScale(c *cli.Context) (err error) {
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := scale.New(conf)
+	b.Steps = c.IntSlice("steps")
+	b.Lookups = c.Int("lookups")
+	b.Trickle = c.Int("trickle")
+	b.AckTimeout = c.Duration("ack-timeout")
+	b.Keep = c.Bool("keep-topics")
+	if err = runRecovered(context.Background(), c, "scale", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "scale", Metrics: results})
+}
+
+func runRamp(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := ramp.New(conf)
+	b.StartRate = c.Float64("start-rate")
+	b.Increment = c.Float64("increment")
+	b.MaxRate = c.Float64("max-rate")
+	b.Step = c.Duration("step")
+	b.SLO = c.Duration("slo")
+	b.Percentile = c.Float64("percentile")
+	b.Threshold = c.Float64("threshold")
+
+	if err = runRecovered(context.Background(), c, "ramp", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "ramp", Metrics: results})
+}
+
+func runAIMD(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := ramp.NewAIMD(conf)
+	b.StartConcurrency = c.Int("start-concurrency")
+	b.MaxConcurrency = c.Int("max-concurrency")
+	b.Increase = c.Float64("increase")
+	b.Decrease = c.Float64("decrease")
+	b.Window = c.Duration("window")
+	b.Duration = c.Duration("duration")
+	b.Ceiling = c.Duration("ceiling")
+	b.Percentile = c.Float64("percentile")
+
+	var out *report.JSONL
+	if out, err = jsonlOutput(c, "aimd"); err != nil {
+		return cli.Exit(err, 1)
+	}
+	if out != nil {
+		b.OnWindow = appendInterval[*ramp.Window](out)
+	}
+
+	if err = runRecovered(context.Background(), c, "aimd", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "aimd", Metrics: results})
+}
+
+func runCoordinator(c *cli.Context) (err error) {
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+	conf.Workload = c.String("workload")
+
+	if c.Int("agents") < 1 {
+		return cli.Exit("at least one agent is required", 1)
+	}
+
+	if c.Int("consumers") < 0 {
+		return cli.Exit("the number of consumers must not be negative", 1)
+	}
+
+	consumer := *conf
+	if topic := c.String("consumer-topic"); topic != "" {
+		consumer.Topic, consumer.TopicID = topic, ""
+	}
+
+	var lis net.Listener
+	if lis, err = net.Listen("tcp", c.String("addr")); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	coord := distributed.NewScenario(conf, &consumer, c.Int("agents"), c.Int("consumers"), c.Duration("lead"))
+	go func() {
+		if err := coord.Serve(lis); err != nil {
+			log.Error().Err(err).Msg("coordinator control api stopped")
+		}
+	}()
+	defer coord.Stop()
+	defer benchmarks.DumpOnSignal("distributed", coord)()
+
+	if err = coord.Wait(context.Background()); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	var results benchmarks.Metrics
+	if results, err = coord.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "distributed", Metrics: results})
+}
+
+func runAgent(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	agent := distributed.NewAgent(c.String("coordinator"), conf)
+	agent.Role = c.String("role")
+	agent.IdleTimeout = c.Duration("idle-timeout")
+	if err = agent.Run(ctx); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return nil
+}
+
+func runE2E(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := e2e.New(conf)
+	b.DrainTimeout = c.Duration("drain-timeout")
+	if err = runRecovered(context.Background(), c, "e2e", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "e2e", Metrics: results})
+}
+
+func runConsume(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	if c.Int("ack-workers") < 0 || c.Int("ack-queue") < 0 {
+		return cli.Exit("the number of ack workers and the ack queue capacity must not be negative", 1)
+	}
+
+	b := consume.New(conf)
+	b.FillTimeout = c.Duration("fill-timeout")
+	b.IdleTimeout = c.Duration("idle-timeout")
+	b.AckWorkers, b.AckQueue = c.Int("ack-workers"), c.Int("ack-queue")
+	if b.RecvRate, err = recvRate(c); err != nil {
+		return err
+	}
+	if err = runRecovered(context.Background(), c, "consume", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "consume", Metrics: results})
+}
+
+func runCommit(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := commit.New(conf)
+	b.Policies = nil
+	for _, name := range c.StringSlice("policy") {
+		var policy commit.Policy
+		if policy, err = commit.ParsePolicy(name); err != nil {
+			return cli.Exit(err, 1)
+		}
+		b.Policies = append(b.Policies, policy)
+	}
+	b.ReconnectEvery = c.Uint64("reconnect-every")
+	b.FillTimeout = c.Duration("fill-timeout")
+	b.IdleTimeout = c.Duration("idle-timeout")
+	if err = runRecovered(context.Background(), c, "commit", b); err != nil {
+		return err
+	}
+
+	if err = b.WriteTable(os.Stderr); err != nil {
+		log.Warn().Err(err).Msg("could not write commit policy comparison")
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "commit", Metrics: results})
+}
+
+func runSeek(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := seek.New(conf)
+	b.Offset, b.Limit = c.Uint64("offset"), c.Uint64("limit")
+	b.Fill = !c.Bool("no-fill")
+	b.FillTimeout = c.Duration("fill-timeout")
+	if err = runRecovered(context.Background(), c, "seek", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "seek", Metrics: results})
+}
+
+func runCache(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	if c.Duration("delay") < 0 {
+		return cli.Exit("the delay before the cold replay must not be negative", 1)
+	}
+
+	if c.IsSet("cold-offset") && !c.Bool("history") {
+		return cli.Exit("the cold offset is only used with --history", 1)
+	}
+
+	b := seek.NewCache(conf)
+	b.Delay = c.Duration("delay")
+	b.History, b.ColdOffset = c.Bool("history"), c.Uint64("cold-offset")
+	b.FillTimeout = c.Duration("fill-timeout")
+	if err = runRecovered(context.Background(), c, "cache", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "cache", Metrics: results})
+}
+
+func runQuery(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	if n := c.Uint64("operations"); n > 0 {
+		conf.Operations = n
+	}
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	if c.Int("repeat") < 1 {
+		return cli.Exit("each query must be repeated at least once", 1)
+	}
+
+	for _, workers := range c.IntSlice("concurrency") {
+		if workers < 1 {
+			return cli.Exit("every concurrency level must have at least one worker", 1)
+		}
+	}
+
+	b := seek.NewQuery(conf)
+	b.Queries, b.Concurrency = c.StringSlice("query"), c.IntSlice("concurrency")
+	b.Repeat = c.Int("repeat")
+	b.Fill = !c.Bool("no-fill")
+	b.FillTimeout = c.Duration("fill-timeout")
+	if err = runRecovered(context.Background(), c, "query", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "query", Metrics: results})
+}
+
+func runConsistency(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	conf.Operations = c.Uint64("operations")
+	if s := c.Int64("data-size"); s > 0 {
+		conf.DataSize = s
+	}
+
+	b := consistency.New(conf)
+	b.From = c.String("from")
+	b.Interval = c.Duration("interval")
+	b.Timeout = c.Duration("timeout")
+	if err = runRecovered(context.Background(), c, "consistency", b); err != nil {
+		return err
+	}
+
+	var results benchmarks.Metrics
+	if results, err = b.Results(); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "consistency", Metrics: results})
+}
+
+// Configures the events published before the measurement of a blast or sustain run.
+func configureWarmup(c *cli.Context) error {
+	conf.WarmupEvents = c.Uint64("warmup-events")
+	if conf.WarmupDuration = c.Duration("warmup-duration"); conf.WarmupDuration < 0 {
+		return cli.Exit("the warmup duration must not be negative", 1)
+	}
+	return nil
+}
+
+// Validates the workload up front since event factories are created by the benchmarks;
+// replayed testdata files are opened to ensure that they contain events.
+func configureWorkload(c *cli.Context) (err error) {
+	if conf.Workload = c.String("workload"); conf.Workload == "" {
+		return nil
+	}
+
+	if conf.Payload != "" {
+		return cli.Exit("a payload cannot be used with a workload, the workload generates its own events", 1)
+	}
+
+	var gen workload.Generator
+	if gen, err = workload.Get(conf.Workload, 0); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if replay, ok := gen.(*workload.FileReplay); ok {
+		replay.Close()
+	}
+	return nil
+}
+
+func runSustain(c *cli.Context) (err error) {
+	if err = checkClock(c); err != nil {
+		return err
+	}
+
+	if err = checkPreflight(c); err != nil {
+		return err
+	}
+
+	conf.Interval = c.Duration("interval")
+	conf.Operations = c.Uint64("operations")
+	conf.DataSize = c.Int64("data-size")
+	conf.Backoff = c.Uint64("backoff")
+	if conf.Jitter = c.String("jitter"); conf.Jitter != "" {
+		if _, err = options.ParseJitter(conf.Jitter); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	if conf.Window = c.Duration("window"); conf.Window <= 0 {
+		return cli.Exit("the time series window must be positive", 1)
+	}
+	if conf.TailThreshold = c.Duration("tail-threshold"); conf.TailThreshold < 0 {
+		return cli.Exit("the tail threshold must not be negative", 1)
+	}
+	if err = configureWarmup(c); err != nil {
+		return err
+	}
+	if err = configureWorkload(c); err != nil {
+		return err
+	}
+
+	b := sustain.New(conf)
+	b.DrainTimeout = c.Duration("drain-timeout")
+	if c.Bool("token-refresh") {
+		if b.TokenCheck, b.RefreshWindow = c.Duration("token-check"), c.Duration("refresh-window"); b.TokenCheck <= 0 || b.RefreshWindow < 0 {
+			return cli.Exit("the token check interval must be positive and the refresh window must not be negative", 1)
+		}
+
+		if b.Tokens, err = identity.NewTokenMonitor(context.Background(), conf); err != nil {
+			return cli.Exit(fmt.Errorf("could not login to monitor token refreshes: %w", err), 1)
+		}
+
+		// The run must outlast the access token for a refresh to be observed
+		expires, lifetime := b.Tokens.Expires()
+		if planned := time.Duration(conf.Operations) * conf.Interval; conf.Operations > 0 && planned < time.Until(expires) {
+			log.Warn().Dur("planned", planned).Dur("token_lifetime", lifetime).Time("expires", expires).Msg("the run may end before the access token expires")
+		}
+	}
+
+	if spec := c.String("chaos"); spec != "" {
+		var faults chaos.Faults
+		if faults, err = chaos.ParseFaults(spec); err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		// Faults are injected beneath the credentials so the monitor authenticates the client
+		if b.Tokens == nil {
+			if b.Tokens, err = identity.NewTokenMonitor(context.Background(), conf); err != nil {
+				return cli.Exit(fmt.Errorf("could not login to inject faults: %w", err), 1)
+			}
+		}
+		b.Chaos = chaos.New(faults, conf.Seed)
+	}
+
+	var out *report.JSONL
+	if out, err = jsonlOutput(c, "sustain"); err != nil {
+		return cli.Exit(err, 1)
+	}
+	if out != nil {
+		b.OnWindow = appendInterval[*stats.Window](out)
+	}
+
+	var results benchmarks.Metrics
+	if results, err = benchmarks.Run(context.Background(), b); err != nil {
+		return exitRun(c, "sustain", results, err)
+	}
+
+	return writeReport(c, &report.Report{Benchmark: "sustain", Metrics: results})
+}
+
+func listen(c *cli.Context) (err error) {
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	// If a receive rate is specified, throttle the consumer to emulate a slow reader
+	var limiter *ratelimit.Limiter
+	var bps float64
+	if bps, err = recvRate(c); err != nil {
+		return err
+	}
+	if bps > 0 {
+		limiter = ratelimit.New(bps, 0)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+
+	var sub *ensign.Subscription
+	topics := c.StringSlice("topic")
+	if sub, err = client.Subscribe(topics...); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	// Track the events received on each topic, resolving the topic names for display
+	names := make(map[string]string, len(topics))
+	for _, topic := range topics {
+		if topicID, err := client.TopicID(ctx, topic); err == nil {
+			names[topicID] = topic
+		}
+	}
+	topicStats := monitor.New(names)
+
+	// Track the events received so that interim statistics can be dumped on request
+	progress := &stats.Progress{}
+	progress.Start()
+	defer benchmarks.DumpOnSignal("listen", monitorFunc(func() map[string]interface{} {
+		snap := progress.Snapshot()
+		snap["topics"] = topicStats.Snapshot()
+		return snap
+	}))()
+
+	// If a stats interval is specified, periodically print the per-topic statistics
+	var table <-chan time.Time
+	interval := c.Duration("stats-interval")
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		table = ticker.C
+	}
+
+	// Count the number of times the connection drops while the reader is consuming; the
+	// client of the local emulator does not have a connection to watch.
+	if conf.Mock == nil {
+		go func() {
+			state := client.ConnState()
+			for client.WaitForConnStateChange(ctx, state) {
+				state = client.ConnState()
+				if state == connectivity.TransientFailure || state == connectivity.Idle {
+					progress.Add("disconnects", 1)
+					log.Warn().Str("state", state.String()).Msg("subscriber disconnected")
+				}
+			}
+		}()
+	}
+
+	// Report how faithfully the request/response pairs were delivered when listening stops
+	var pairs *workload.PairAnalyzer
+	if c.Bool("pairs") {
+		pairs = workload.NewPairAnalyzer()
+		defer func() {
+			if err == nil {
+				err = writePairs(c, pairs, topics, progress)
+			}
+		}()
+	}
+
+	var throttled time.Duration
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		nbytes := progress.Get("bytes")
+		log.Info().
+			Uint64("events", progress.Get("events")).
+			Uint64("bytes", nbytes).
+			Dur("elapsed", elapsed).
+			Float64("bytes_per_sec", float64(nbytes)/elapsed.Seconds()).
+			Dur("throttled", throttled).
+			Uint64("disconnects", progress.Get("disconnects")).
+			Msg("listen complete")
+
+		if interval > 0 || len(topics) > 1 {
+			topicStats.WriteTable(os.Stderr)
+		}
+	}()
+
+	for {
+		select {
+		case <-quit:
+			return nil
+		case <-table:
+			if err := topicStats.WriteTable(os.Stderr); err != nil {
+				log.Warn().Err(err).Msg("could not write topic statistics")
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				progress.Add("disconnects", 1)
+				log.Warn().Msg("subscription closed by the server")
+				return nil
+			}
+
+			if limiter != nil {
+				var wait time.Duration
+				if wait, err = limiter.WaitN(ctx, len(event.Data)); err != nil {
+					return nil
+				}
+				throttled += wait
+				progress.Set("throttled_ms", uint64(throttled/time.Millisecond))
+			}
+
+			received := time.Now()
+			progress.Add("events", 1)
+			progress.Add("bytes", uint64(len(event.Data)))
+			topicStats.Record(event.TopicID(), len(event.Data), received)
+
+			if pairs != nil {
+				pairs.Observe(event.Metadata, received)
+			}
+
+			lgc := zerolog.Dict()
+			for key, val := range event.Metadata {
+				lgc.Str(key, val)
+			}
+
+			// Individual events are only logged at debug level when printing the table
+			logevt := log.Info()
+			if interval > 0 {
+				logevt = log.Debug()
+			}
+
+			logevt.
+				Dict("metadata", lgc).
+				Str("type", fmt.Sprintf("%s v%s", event.Type.Name, event.Type.Semver())).
+				Str("mimetype", event.Mimetype.MimeType()).
+				Int("data_size", len(event.Data)).
+				Time("created", event.Created).
+				Msg("event recv")
+
+			if _, err := event.Ack(); err != nil {
+				log.Error().Err(err).Msg("could not ack event")
+			}
+		}
+	}
+}
+
+// Parses the receive rate that caps the bandwidth of a consumer, in bytes per second;
+// zero is returned if the rate is not specified.
+func recvRate(c *cli.Context) (_ float64, err error) {
+	rate := c.String("recv-rate")
+	if rate == "" {
+		return 0, nil
+	}
+
+	var bps uint64
+	if bps, err = options.ParseBytes(rate); err != nil {
+		return 0, cli.Exit(fmt.Errorf("could not parse recv-rate: %w", err), 1)
+	}
+
+	if bps == 0 {
+		return 0, cli.Exit("recv-rate must be greater than zero", 1)
+	}
+	return float64(bps), nil
+}
+
+// Reports the analysis of the request/response pairs delivered to the listener.
+func writePairs(c *cli.Context, pairs *workload.PairAnalyzer, topics []string, progress *stats.Progress) error {
+	if pairs.Pairs() == 0 {
+		log.Warn().Msg("no complete request/response pairs were delivered; was the topic published with the pairs workload?")
+	}
+
+	results := metrics.Metrics{
+		"events": progress.Get("events"),
+		"pairs":  pairs,
+		"experiment": map[string]interface{}{
+			"client_version": benchmarks.Version(),
+			"endpoint":       conf.Endpoint,
+			"topics":         topics,
+			"recv_rate":      c.String("recv-rate"),
+		},
+	}
+	return writeReport(c, &report.Report{Benchmark: "pairs", Metrics: results})
+}
+
+// Allows ordinary functions to report interim statistics for commands that are not
+// implemented as benchmarks.
+type monitorFunc func() map[string]interface{}
+
+func (f monitorFunc) Progress() map[string]interface{} {
+	return f()
+}
+
+func check(c *cli.Context) (err error) {
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output := make(map[string]string)
+
+	var state *api.ServiceState
+	if state, err = client.Status(ctx); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	output["endpoint"] = conf.Endpoint
+	output["status"] = state.Status.String()
+	output["version"] = state.Version
+	output["topic"] = conf.Topic
+
+	var exists bool
+	if exists, err = client.TopicExists(ctx, conf.Topic); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	output["topic exists"] = fmt.Sprintf("%t", exists)
+
+	var data []byte
+	if data, err = json.MarshalIndent(output, "", "  "); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+func whoami(c *cli.Context) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var claims *identity.Claims
+	if claims, err = identity.Authenticate(ctx, conf); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	expires := claims.Expires()
+	output := map[string]interface{}{
+		"auth_url":    conf.AuthURL,
+		"subject":     claims.Subject,
+		"org_id":      claims.OrgID,
+		"project_id":  claims.ProjectID,
+		"permissions": claims.Permissions,
+		"missing":     claims.Missing(),
+		"expires":     expires.Format(time.RFC3339),
+		"expires_in":  time.Until(expires).Round(time.Second).String(),
+	}
+
+	var data []byte
+	if data, err = json.MarshalIndent(output, "", "  "); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+func createTopic(c *cli.Context) (err error) {
+	if c.NArg() == 0 {
+		return cli.Exit("specify the name of the topic(s) to create", 1)
+	}
+
+	// Parse the topic policies before creating any topics
+	var dedup *api.Deduplication
+	if strategy := c.String("dedup"); strategy != "" {
+		if dedup, err = options.ParseDeduplication(strategy, c.String("dedup-offset"), c.StringSlice("dedup-keys")); err != nil {
+			return cli.Exit(err, 1)
+		}
+	} else if c.IsSet("dedup-offset") || c.IsSet("dedup-keys") {
+		return cli.Exit("the deduplication offset and keys require a --dedup strategy", 1)
+	}
+
+	var sharding api.ShardingStrategy
+	if strategy := c.String("sharding"); strategy != "" {
+		if sharding, err = options.ParseSharding(strategy); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	created := make([]string, 0, c.NArg())
+	for i := 0; i < c.NArg(); i++ {
+		var topicID string
+		topic := c.Args().Get(i)
+		if topicID, err = client.CreateTopic(ctx, topic); err != nil {
+			return cli.Exit(err, 1)
+		}
+		created = append(created, topicID)
+
+		if dedup != nil {
+			if _, err = client.SetTopicDeduplicationPolicy(ctx, topicID, dedup.Strategy, dedup.Offset, append(dedup.Keys, dedup.Fields...)); err != nil {
+				return cli.Exit(fmt.Errorf("could not set the deduplication policy of topic %s: %w", topic, err), 1)
+			}
+		}
+
+		if sharding != api.ShardingStrategy_UNKNOWN {
+			if _, err = client.SetTopicShardingStrategy(ctx, topicID, sharding); err != nil {
+				return cli.Exit(fmt.Errorf("could not set the sharding strategy of topic %s: %w", topic, err), 1)
+			}
+		}
+
+		if !c.Bool("json") {
+			log.Printf("topic %s created with id %s\n", topic, topicID)
+		}
+	}
+
+	if !c.Bool("json") {
+		return nil
+	}
+
+	// The created topics are listed so that the policies applied by the server are shown
+	var topics []*api.Topic
+	if topics, err = client.ListTopics(ctx); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	listed := make(map[string]*api.Topic, len(topics))
+	for _, topic := range topics {
+		var topicID ulid.ULID
+		if err = topicID.UnmarshalBinary(topic.Id); err == nil {
+			listed[topicID.String()] = topic
+		}
+	}
+
+	output := make([]map[string]interface{}, 0, len(created))
+	for _, topicID := range created {
+		if topic, ok := listed[topicID]; ok {
+			output = append(output, topicOutput(topic))
+		}
+	}
+
+	var data []byte
+	if data, err = json.MarshalIndent(output, "", "  "); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+// Returns the json output of a topic with its policies named by their strategies.
+func topicOutput(topic *api.Topic) map[string]interface{} {
+	var topicID ulid.ULID
+	topicID.UnmarshalBinary(topic.Id)
+
+	output := map[string]interface{}{
+		"id":     topicID.String(),
+		"name":   topic.Name,
+		"shards": topic.Shards,
+		"status": topic.Status.String(),
+	}
+
+	if topic.Created != nil {
+		output["created"] = topic.Created.AsTime().Format(time.RFC3339)
+	}
+
+	if dedup := topic.Deduplication; dedup != nil {
+		policy := map[string]interface{}{
+			"strategy": strings.ToLower(dedup.Strategy.String()),
+			"offset":   strings.ToLower(strings.TrimPrefix(dedup.Offset.String(), "OFFSET_")),
+		}
+		if len(dedup.Keys) > 0 {
+			policy["keys"] = dedup.Keys
+		}
+		if len(dedup.Fields) > 0 {
+			policy["fields"] = dedup.Fields
+		}
+		output["deduplication"] = policy
+	}
+
+	// The sharding strategy of the topic is the strategy of its current placement
+	if n := len(topic.Placements); n > 0 {
+		output["sharding"] = strings.ToLower(topic.Placements[n-1].Sharding.String())
+	}
+	return output
+}
+
+func destroyTopics(c *cli.Context) (err error) {
+	if c.NArg() == 0 {
+		return cli.Exit("specify the name or id of the topic(s) to destroy", 1)
+	}
+
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, topic := range c.Args().Slice() {
+		// Topics may be specified by ID, e.g. to destroy a topic that has been renamed
+		topicID := topic
+		if _, err = ulid.Parse(topic); err != nil {
+			if topicID, err = client.TopicID(ctx, topic); err != nil {
+				return cli.Exit(fmt.Errorf("could not resolve topic %s: %w", topic, err), 1)
+			}
+		}
+
+		var state api.TopicState
+		if state, err = client.DestroyTopic(ctx, topicID); err != nil {
+			return cli.Exit(fmt.Errorf("could not destroy topic %s: %w", topic, err), 1)
+		}
+
+		log.Info().Str("topic", topic).Str("topic_id", topicID).Str("state", state.String()).Msg("topic destroyed")
+	}
+	return nil
+}
+
+// Destroys the topics created by the run so that repeated runs do not leave benchmark
+// events behind; failures are logged since the results of the run have been written.
+func cleanupTopics() {
+	if len(created) == 0 {
+		return
+	}
+
+	client, err := ensign.New(conf.Ensign()...)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not connect to clean up topics")
+		return
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, topicID := range created {
+		if _, err = client.DestroyTopic(ctx, topicID.String()); err != nil {
+			log.Warn().Err(err).Str("topic_id", topicID.String()).Msg("could not destroy topic created for the run")
+			continue
+		}
+		log.Info().Str("topic_id", topicID.String()).Msg("destroyed topic created for the run")
+	}
+	created = nil
+}
+
+func mktestdata(c *cli.Context) (err error) {
+	nEvents := c.Int("size")
+	nKeys := c.Int("num-keys")
+	newKeyProb := c.Float64("new-key-prob")
+	dupProb := c.Float64("duplicate-prob")
+	out := c.String("out")
+
+	// Pretty-printed JSON arrays are written unless a stream format is requested
+	format := c.String("format")
+	if format == "" {
+		if format = workload.FormatFromPath(out); format == "" {
+			format = workload.FormatJSON
+		}
+	}
+
+	index := workload.NewPayloadIndex()
+
+	// The duplicates and logs workloads are configured from the command line flags
+	name := c.String("workload")
+	if name != workload.RandomDuplicatesWorkload && (c.IsSet("distribution") || c.IsSet("zipf-s")) {
+		return cli.Exit(fmt.Errorf("the distribution can only be configured for the %s workload", workload.RandomDuplicatesWorkload), 1)
+	}
+
+	if name != workload.LogsWorkload {
+		for _, flag := range []string{"error-rate", "burst-error-rate", "burst-prob", "burst-length"} {
+			if c.IsSet(flag) {
+				return cli.Exit(fmt.Errorf("--%s can only be configured for the %s workload", flag, workload.LogsWorkload), 1)
+			}
+		}
+	}
+
+	var gen workload.Generator
+	switch name {
+	case workload.RandomDuplicatesWorkload:
+		dups := workload.NewRandomDuplicates(nKeys, newKeyProb, dupProb, workload.NewRand(0))
+		if err = dups.SetDistribution(c.String("distribution"), c.Float64("zipf-s")); err != nil {
+			return cli.Exit(err, 1)
+		}
+		gen = dups
+	case workload.LogsWorkload:
+		errorRate := c.Float64("error-rate")
+		if errorRate < 0 || errorRate > 1 {
+			return cli.Exit("the error rate must be between 0 and 1", 1)
+		}
+
+		logs := workload.NewLogLines(errorRate, workload.NewRand(0))
+		if err = logs.SetBursts(c.Float64("burst-error-rate"), c.Float64("burst-prob"), c.Int("burst-length")); err != nil {
+			return cli.Exit(err, 1)
+		}
+		gen = logs
+	default:
+		if gen, err = workload.Get(name, 0); err != nil {
+			return cli.Exit(err, 1)
+		}
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal().Err(err).Msg("could not start cli app")
+	var f io.WriteCloser
+	if f, err = compress.Create(out); err != nil {
+		return cli.Exit(err, 1)
 	}
-}
+	defer f.Close()
 
-var conf *options.Options
+	// Events are written as they are generated so the dataset is never held in memory
+	var stream *workload.StreamWriter
+	if stream, err = workload.NewStreamWriter(f, format); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-func configure(c *cli.Context) error {
-	conf = options.New()
-	if creds := c.String("credentials"); creds != "" {
-		conf.Credentials = creds
+	for i := 0; i < nEvents; i++ {
+		event := gen.Next()
+		if err = index.Add(event); err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		if err = stream.Write(event); err != nil {
+			return cli.Exit(err, 1)
+		}
 	}
-	if endpoint := c.String("endpoint"); endpoint != "" {
-		conf.Endpoint = endpoint
+
+	if err = stream.Close(); err != nil {
+		return cli.Exit(err, 1)
 	}
-	if authURL := c.String("auth-url"); authURL != "" {
-		conf.AuthURL = authURL
+
+	if err = f.Close(); err != nil {
+		return cli.Exit(err, 1)
+	}
+	log.Info().Int("events", nEvents).Str("format", format).Str("out", out).Msg("testdata written")
+
+	if path := c.String("index"); path != "" {
+		if err = writeIndex(path, index); err != nil {
+			return cli.Exit(err, 1)
+		}
+		log.Info().Int("events", index.Events).Int("unique", index.Unique).Int("duplicates", index.Duplicates()).Str("index", path).Msg("payload index written")
 	}
 	return nil
 }
 
-func runBlast(c *cli.Context) (err error) {
-	if n := c.Uint64("operations"); n > 0 {
-		conf.Operations = n
+// Writes the payload index of a generated dataset (zstd compressed if it ends in .zst).
+func writeIndex(path string, index *workload.PayloadIndex) (err error) {
+	var f io.WriteCloser
+	if f, err = compress.Create(path); err != nil {
+		return err
 	}
-	if s := c.Int64("data-size"); s > 0 {
-		conf.DataSize = s
+	defer f.Close()
+
+	if err = index.Write(f); err != nil {
+		return err
 	}
+	return f.Close()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+func compare(c *cli.Context) (err error) {
+	preset := c.String("preset")
+	if preset == "" && c.NArg() != 2 {
+		return cli.Exit("specify the baseline and current runs as JSON results files or results store ids", 1)
+	}
 
-	b := blast.New(conf)
-	if err = b.Run(ctx); err != nil {
+	var thresholds report.Thresholds
+	if thresholds, err = report.ParseThresholds(c.String("thresholds"), c.Float64("threshold")); err != nil {
 		return cli.Exit(err, 1)
 	}
 
-	var results benchmarks.Metrics
-	if results, err = b.Results(); err != nil {
-		return cli.Exit(err, 1)
+	var baseline, current benchmarks.Metrics
+	if preset != "" {
+		if baseline, current, err = loadPreset(c, preset); err != nil {
+			return cli.Exit(err, 1)
+		}
+	} else {
+		if baseline, err = loadRun(c, c.Args().Get(0)); err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		if current, err = loadRun(c, c.Args().Get(1)); err != nil {
+			return cli.Exit(err, 1)
+		}
 	}
 
-	var data []byte
-	if data, err = json.Marshal(results); err != nil {
+	var comparison *report.Comparison
+	if comparison, err = report.Compare(current, baseline, thresholds); err != nil {
 		return cli.Exit(err, 1)
 	}
 
-	fmt.Println(string(data))
+	for _, delta := range comparison.Deltas {
+		fmt.Println(delta)
+	}
+
+	if len(comparison.Regressions) > 0 {
+		for _, regression := range comparison.Regressions {
+			fmt.Fprintln(os.Stderr, regression)
+		}
+		return cli.Exit(fmt.Errorf("%d metric(s) regressed beyond their thresholds", len(comparison.Regressions)), 1)
+	}
 	return nil
 }
 
-func runSustain(c *cli.Context) (err error) {
-	conf.Interval = c.Duration("interval")
-	conf.Operations = c.Uint64("operations")
-	conf.DataSize = c.Int64("data-size")
+func gate(c *cli.Context) (err error) {
+	var thresholds report.Thresholds
+	if thresholds, err = report.ParseThresholds(c.String("thresholds"), c.Float64("threshold")); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-	b := sustain.New(conf)
-	if err = b.Run(context.Background()); err != nil {
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer db.Close()
+
+	// Gate the specified run or the latest run of the benchmark
+	var run *store.Run
+	benchmark := c.String("benchmark")
+	switch {
+	case c.NArg() == 1:
+		if run, err = db.Get(c.Args().First()); err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		if benchmark != "" && run.Benchmark != benchmark {
+			return cli.Exit(fmt.Errorf("run %s is a %s run, not a %s run", run.ID, run.Benchmark, benchmark), 1)
+		}
+	case c.NArg() == 0 && benchmark != "":
+		var runs []*store.Run
+		if runs, err = db.List(benchmark, 1); err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		if len(runs) == 0 {
+			return cli.Exit(fmt.Errorf("no %s runs have been recorded", benchmark), 1)
+		}
+		run = runs[0]
+	default:
+		return cli.Exit("specify the run to gate or the benchmark whose latest run is gated", 1)
+	}
+
+	current := make(metrics.Metrics)
+	if err = json.Unmarshal(run.Metrics, &current); err != nil {
+		return cli.Exit(fmt.Errorf("could not parse metrics of run %s: %w", run.ID, err), 1)
+	}
+
+	// A run that was stopped early cannot pass since its measurements are partial
+	var violations []report.Violation
+	if reason, ok := current["exit_reason"].(string); ok && reason != benchmarks.ExitCompleted {
+		violations = append(violations, report.Violation{Metric: "exit_reason", Message: fmt.Sprintf("the run did not complete (%s)", reason)})
+	}
+
+	var baseline *store.Baseline
+	if baseline, err = db.Baseline(run.Benchmark); err != nil && !errors.Is(err, store.ErrNoBaseline) {
 		return cli.Exit(err, 1)
 	}
+
+	switch {
+	case baseline == nil:
+		fmt.Fprintf(os.Stderr, "no %s baseline has been promoted, run %s is not compared\n", run.Benchmark, run.ID)
+	case baseline.RunID == run.ID:
+		fmt.Fprintf(os.Stderr, "run %s is the %s baseline\n", run.ID, run.Benchmark)
+	default:
+		var previous metrics.Metrics
+		if previous, err = loadRun(c, baseline.RunID); err != nil {
+			return cli.Exit(fmt.Errorf("could not load the %s baseline: %w", run.Benchmark, err), 1)
+		}
+
+		var comparison *report.Comparison
+		if comparison, err = report.Compare(current, previous, thresholds); err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		fmt.Fprintf(os.Stderr, "baseline: %s run %s (promoted %s)\n", run.Benchmark, baseline.RunID, baseline.Promoted.Local().Format(time.RFC3339))
+		for _, delta := range comparison.Deltas {
+			fmt.Println(delta)
+		}
+		violations = append(violations, comparison.Regressions...)
+	}
+
+	if len(violations) > 0 {
+		for _, violation := range violations {
+			fmt.Fprintln(os.Stderr, violation)
+		}
+		return cli.Exit(fmt.Errorf("run %s failed %d budget(s)", run.ID, len(violations)), 1)
+	}
+
+	if !c.Bool("update-baseline-on-pass") || (baseline != nil && baseline.RunID == run.ID) {
+		return nil
+	}
+
+	promoted := &store.Baseline{Benchmark: run.Benchmark, RunID: run.ID, Provenance: provenance(c, baseline)}
+	if err = db.Promote(promoted); err != nil {
+		return cli.Exit(fmt.Errorf("could not promote run %s: %w", run.ID, err), 1)
+	}
+	fmt.Fprintf(os.Stderr, "promoted run %s to the %s baseline\n", run.ID, run.Benchmark)
 	return nil
 }
 
-func listen(c *cli.Context) (err error) {
-	var client *ensign.Client
-	if client, err = ensign.New(conf.Ensign()...); err != nil {
-		return cli.Exit(err, 1)
+// The CI environment variables recorded with the provenance of a promoted baseline.
+var provenanceEnv = []string{"GITHUB_REPOSITORY", "GITHUB_SHA", "GITHUB_REF", "GITHUB_RUN_ID", "GITHUB_ACTOR"}
+
+// Describes how a run that passed the gate was promoted: the baseline it was gated
+// against, the budgets it passed, and the host and CI job that promoted it.
+func provenance(c *cli.Context, baseline *store.Baseline) map[string]string {
+	prov := map[string]string{
+		"promoted_by": "gate",
+		"threshold":   strconv.FormatFloat(c.Float64("threshold"), 'f', -1, 64),
+		"client":      benchmarks.Version(),
 	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	if baseline != nil {
+		prov["gated_against"] = baseline.RunID
+	}
 
-	var sub *ensign.Subscription
-	topics := c.StringSlice("topic")
-	if sub, err = client.Subscribe(topics...); err != nil {
-		return cli.Exit(err, 1)
+	if thresholds := c.String("thresholds"); thresholds != "" {
+		prov["thresholds"] = thresholds
 	}
 
-	for {
-		select {
-		case <-quit:
-			return nil
-		case event := <-sub.C:
-			lgc := zerolog.Dict()
-			for key, val := range event.Metadata {
-				lgc.Str(key, val)
-			}
+	if host, err := os.Hostname(); err == nil {
+		prov["host"] = host
+	}
 
-			log.Info().
-				Dict("metadata", lgc).
-				Str("type", fmt.Sprintf("%s v%s", event.Type.Name, event.Type.Semver())).
-				Str("mimetype", event.Mimetype.MimeType()).
-				Int("data_size", len(event.Data)).
-				Time("created", event.Created).
-				Msg("event recv")
+	for _, key := range provenanceEnv {
+		if val := os.Getenv(key); val != "" {
+			prov[strings.ToLower(key)] = val
+		}
+	}
+	return prov
+}
 
-			if _, err := event.Ack(); err != nil {
-				log.Error().Err(err).Msg("could not ack event")
-			}
+// Loads the latest stored runs of the baseline and current versions for a compare preset,
+// e.g. the latest blast runs built with two different versions of the client SDK. If no
+// versions are specified the two most recently run versions are compared.
+func loadPreset(c *cli.Context, preset string) (baseline, current metrics.Metrics, err error) {
+	key, ok := report.ComparePresets[preset]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown compare preset %q", preset)
+	}
+
+	benchmark := c.String("benchmark")
+	if benchmark == "" {
+		return nil, nil, errors.New("specify the benchmark whose stored runs are compared by the preset")
+	}
+
+	if c.NArg() != 0 && c.NArg() != 2 {
+		return nil, nil, errors.New("specify both the baseline and current versions or neither")
+	}
+
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	var runs []*store.Run
+	if runs, err = db.List(benchmark, 0); err != nil {
+		return nil, nil, err
+	}
+
+	groups := store.GroupBy(runs, key)
+	index := make(map[string]*store.Group, len(groups))
+	for _, group := range groups {
+		index[group.Value] = group
+	}
+
+	var base, cur *store.Group
+	if c.NArg() == 2 {
+		if base, ok = index[c.Args().Get(0)]; !ok {
+			return nil, nil, fmt.Errorf("no stored %s runs with %s %s", benchmark, key, c.Args().Get(0))
 		}
+
+		if cur, ok = index[c.Args().Get(1)]; !ok {
+			return nil, nil, fmt.Errorf("no stored %s runs with %s %s", benchmark, key, c.Args().Get(1))
+		}
+	} else {
+		if len(groups) < 2 {
+			return nil, nil, fmt.Errorf("stored %s runs were recorded with %d %s(s), at least two are required to compare", benchmark, len(groups), key)
+		}
+		cur, base = groups[0], groups[1]
+	}
+
+	for _, group := range []struct {
+		role  string
+		group *store.Group
+	}{{"baseline", base}, {"current", cur}} {
+		fmt.Fprintf(os.Stderr, "%s: %s %s run %s (latest of %d runs)\n", group.role, key, group.group.Value, group.group.Latest().ID, len(group.group.Runs))
+	}
+
+	if baseline, err = loadRun(c, base.Latest().ID); err != nil {
+		return nil, nil, err
+	}
+
+	if current, err = loadRun(c, cur.Latest().ID); err != nil {
+		return nil, nil, err
 	}
+	return baseline, current, nil
 }
 
-func check(c *cli.Context) (err error) {
-	var client *ensign.Client
-	if client, err = ensign.New(conf.Ensign()...); err != nil {
-		return cli.Exit(err, 1)
+// Loads the metrics of a run from a JSON results file or, if no file exists at the
+// path, from the results store by run id.
+func loadRun(c *cli.Context, ref string) (_ metrics.Metrics, err error) {
+	if _, err = os.Stat(ref); err == nil {
+		return report.LoadMetrics(ref)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return nil, err
+	}
+	defer db.Close()
 
-	output := make(map[string]string)
+	var run *store.Run
+	if run, err = db.Get(ref); err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
 
-	var state *api.ServiceState
-	if state, err = client.Status(ctx); err != nil {
+	m := make(metrics.Metrics)
+	if err = json.Unmarshal(run.Metrics, &m); err != nil {
+		return nil, fmt.Errorf("could not parse metrics of run %s: %w", run.ID, err)
+	}
+	return m, nil
+}
+
+func listResults(c *cli.Context) (err error) {
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
 		return cli.Exit(err, 1)
 	}
+	defer db.Close()
 
-	output["endpoint"] = conf.Endpoint
-	output["status"] = state.Status.String()
-	output["version"] = state.Version
-	output["topic"] = conf.Topic
+	var runs []*store.Run
+	if runs, err = db.List(c.String("benchmark"), c.Int("limit")); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-	var exists bool
-	if exists, err = client.TopicExists(ctx, conf.Topic); err != nil {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tBENCHMARK\tCREATED\tLABELS")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.ID, run.Benchmark, run.Created.Local().Format(time.RFC3339), run.LabelSet())
+	}
+	return w.Flush()
+}
+
+func trendResults(c *cli.Context) (err error) {
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
 		return cli.Exit(err, 1)
 	}
+	defer db.Close()
 
-	output["topic exists"] = fmt.Sprintf("%t", exists)
+	var runs []*store.Run
+	if runs, err = db.List(c.String("benchmark"), c.Int("limit")); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-	var data []byte
-	if data, err = json.MarshalIndent(output, "", "  "); err != nil {
+	groupBy := c.String("group-by")
+	groups := store.GroupBy(runs, groupBy)
+	if len(groups) == 0 {
+		return cli.Exit(fmt.Errorf("no %s runs recorded a %s", c.String("benchmark"), groupBy), 1)
+	}
+
+	var points []*report.TrendPoint
+	if points, err = report.Trend(groups, c.String("metric")); err != nil {
 		return cli.Exit(err, 1)
 	}
 
-	fmt.Println(string(data))
-	return nil
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tRUNS\tFIRST\tLAST\tMEAN\tMIN\tMAX\tCHANGE\n", strings.ToUpper(groupBy))
+	for i, point := range points {
+		change := "-"
+		if i > 0 {
+			change = fmt.Sprintf("%+.2f%%", point.Percent)
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			point.Group, point.Runs,
+			point.First.Local().Format(time.DateOnly), point.Last.Local().Format(time.DateOnly),
+			point.Format(point.Mean), point.Format(point.Min), point.Format(point.Max), change,
+		)
+	}
+	return w.Flush()
 }
 
-func createTopic(c *cli.Context) (err error) {
-	var client *ensign.Client
-	if client, err = ensign.New(conf.Ensign()...); err != nil {
+func showResult(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify the id of the run to show", 1)
+	}
+
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
 		return cli.Exit(err, 1)
 	}
+	defer db.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	var run *store.Run
+	if run, err = db.Get(c.Args().First()); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-	for i := 0; i < c.NArg(); i++ {
-		var topicID string
-		topic := c.Args().Get(i)
-		if topicID, err = client.CreateTopic(ctx, topic); err != nil {
-			return cli.Exit(err, 1)
-		}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(run); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return nil
+}
+
+func showBaseline(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify the benchmark whose baseline is shown", 1)
+	}
 
-		log.Printf("topic %s created with id %s\n", topic, topicID)
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer db.Close()
+
+	var baseline *store.Baseline
+	if baseline, err = db.Baseline(c.Args().First()); err != nil {
+		return cli.Exit(err, 1)
 	}
 
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(baseline); err != nil {
+		return cli.Exit(err, 1)
+	}
 	return nil
 }
 
-func mktestdata(c *cli.Context) (err error) {
-	nEvents := c.Int("size")
-	nKeys := c.Int("num-keys")
-	newKeyProb := c.Float64("new-key-prob")
-	dupProb := c.Float64("duplicate-prob")
-	out := c.String("out")
+func pruneResults(c *cli.Context) (err error) {
+	policy := store.PrunePolicy{KeepLast: c.Int("keep-last"), MaxAge: c.Duration("max-age")}
+	if err = policy.Validate(); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-	pbjson := protojson.MarshalOptions{
-		UseProtoNames:   true,
-		UseEnumNumbers:  false,
-		EmitUnpopulated: false,
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return cli.Exit(err, 1)
 	}
+	defer db.Close()
 
-	data := make([]map[string]interface{}, 0, nEvents)
-	gen := workload.NewRandomDuplicates(nKeys, newKeyProb, dupProb)
+	var runs []*store.Run
+	if runs, err = db.List("", 0); err != nil {
+		return cli.Exit(err, 1)
+	}
 
-	for i := 0; i < nEvents; i++ {
-		event := gen.Next()
+	// The baselines of the benchmarks are kept regardless of the policy
+	baselines := make(map[string]string)
+	for _, run := range runs {
+		if _, ok := baselines[run.Benchmark]; ok {
+			continue
+		}
 
-		var serial []byte
-		if serial, err = pbjson.Marshal(event); err != nil {
+		var baseline *store.Baseline
+		if baseline, err = db.Baseline(run.Benchmark); err != nil && !errors.Is(err, store.ErrNoBaseline) {
 			return cli.Exit(err, 1)
 		}
 
-		obj := make(map[string]interface{})
-		if err = json.Unmarshal(serial, &obj); err != nil {
-			return cli.Exit(err, 1)
+		baselines[run.Benchmark] = ""
+		if baseline != nil {
+			baselines[run.Benchmark] = baseline.RunID
+		}
+	}
+
+	remove := policy.Prune(runs, time.Now())
+	ids := make([]string, 0, len(remove))
+	for _, run := range remove {
+		if baselines[run.Benchmark] == run.ID {
+			continue
 		}
+		ids = append(ids, run.ID)
+		fmt.Printf("%s\t%s\t%s\n", run.ID, run.Benchmark, run.Created.Local().Format(time.RFC3339))
+	}
 
-		data = append(data, obj)
+	if c.Bool("dry-run") {
+		fmt.Printf("%d of %d runs would be removed\n", len(ids), len(runs))
+		return nil
 	}
 
-	var f *os.File
-	if f, err = os.Create(out); err != nil {
+	if err = db.Delete(ids...); err != nil {
 		return cli.Exit(err, 1)
 	}
-	defer f.Close()
+	fmt.Printf("removed %d of %d runs\n", len(ids), len(runs))
+	return nil
+}
 
-	encoder := json.NewEncoder(f)
-	encoder.SetIndent("", "  ")
-	if err = encoder.Encode(data); err != nil {
+func exportResults(c *cli.Context) (err error) {
+	var db *store.SQLite
+	if db, err = store.Open(c.String("store")); err != nil {
+		return cli.Exit(err, 1)
+	}
+	defer db.Close()
+
+	var runs []*store.Run
+	if runs, err = db.List(c.String("benchmark"), 0); err != nil {
 		return cli.Exit(err, 1)
 	}
 
+	var w io.WriteCloser = os.Stdout
+	if path := c.String("out"); path != "" {
+		if w, err = compress.Create(path); err != nil {
+			return cli.Exit(err, 1)
+		}
+		defer w.Close()
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, run := range runs {
+		if err = encoder.Encode(run); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	if w != os.Stdout {
+		if err = w.Close(); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
 	return nil
 }
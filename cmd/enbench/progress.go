@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+)
+
+// How often the live progress of a run is displayed.
+const progressInterval = time.Second
+
+// Displays the progress of the benchmark on stderr every second until the returned
+// function is called: the events sent and acked, the throughput of acks since the last
+// update, and the rolling p99 latency. On a terminal the line is updated in place,
+// otherwise a line is written for every update so that logs of the run remain readable.
+func showProgress(mon benchmarks.Monitor) (stop func()) {
+	tty := isTerminal(os.Stderr)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		var acks uint64
+		last := time.Now()
+		for {
+			select {
+			case now := <-ticker.C:
+				snap := mon.Progress()
+				current, _ := snap["acks"].(uint64)
+				if current < acks {
+					acks = 0
+				}
+				rate := float64(current-acks) / now.Sub(last).Seconds()
+				acks, last = current, now
+
+				line := formatProgress(snap, rate)
+				if tty {
+					fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+				} else {
+					fmt.Fprintln(os.Stderr, line)
+				}
+			case <-done:
+				if tty {
+					fmt.Fprintln(os.Stderr)
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Formats a progress snapshot of a benchmark; the counters that the benchmark does not
+// report are omitted.
+func formatProgress(snap map[string]interface{}, rate float64) string {
+	line := fmt.Sprintf("[%s]", snap["elapsed"])
+	if sent, ok := snap["sent"].(uint64); ok {
+		if total, ok := snap["operations"].(uint64); ok && total > 0 {
+			line += fmt.Sprintf(" sent %d/%d (%.1f%%)", sent, total, 100*float64(sent)/float64(total))
+		} else {
+			line += fmt.Sprintf(" sent %d", sent)
+		}
+	}
+
+	acks, _ := snap["acks"].(uint64)
+	line += fmt.Sprintf(" acks %d", acks)
+	if nacks, ok := snap["nacks"].(uint64); ok && nacks > 0 {
+		line += fmt.Sprintf(" nacks %d", nacks)
+	}
+
+	line += fmt.Sprintf(" | %.0f events/s", rate)
+	if p99, ok := snap["rolling_p99"]; ok {
+		line += fmt.Sprintf(" | p99 %s", p99)
+	}
+	return line
+}
+
+// Returns true if the file is a terminal rather than a pipe or a regular file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+
+	"github.com/rs/zerolog/log"
 )
 
 // Benchmark is an interface for running a benchmark test against a system and getting
@@ -28,11 +30,18 @@ type Benchmark interface {
 	// Returns the results of the benchmark.
 	Results() (Metrics, error)
 
-	// Access the workload and the client
+	// Access the workload and the client; the workload generates the events of the
+	// benchmark outside of a run, e.g. to inspect or export them.
 	Client() Client
 	Workload() Workload
 }
 
+// Monitor is implemented by long-running benchmarks that can report interim statistics
+// while they are running, e.g. when an operator requests a stats dump from a headless run.
+type Monitor interface {
+	Progress() map[string]interface{}
+}
+
 // Metrics contains the results of a benchmark and is essentially a collection of
 // named measurements. Every benchmark has at most one collection of metrics that is
 // used to serialize results either to disk or over the network.
@@ -83,38 +92,71 @@ type Workload interface {
 }
 
 // Run is the primary entrypoint and conducts a single benchmark test. This function
-// connects the client and prepares the workload before executing the benchmark, then
-// releases the workload and closes the client and returns the metrics. This function
-// also listens for OS signals such as interrupt to stop the benchmark in the middle of
-// a run and works to respect the deadlines in the given context.
+// connects the client before executing the benchmark, then closes the client and
+// returns the metrics. The workload is not prepared since the benchmark generates its
+// events while it runs; the workload generates the same events outside of a run, e.g.
+// to inspect or export them, and must be prepared and released by its caller. This
+// function also listens for OS signals such as interrupt to stop the benchmark in the
+// middle of a run and works to respect the deadlines in the given context; benchmarks
+// that are a Monitor dump their progress when the process receives SIGUSR1. If the run
+// panics, the partial results of the run are returned along with the *PanicError as by
+// Recover.
 func Run(ctx context.Context, bench Benchmark) (_ Metrics, err error) {
-	// Connect the client and prepare the workload
+	// Connect the client and ensure that it is closed when the function is done
 	if err = bench.Client().Connect(); err != nil {
 		return nil, err
 	}
+	defer bench.Client().Close()
 
-	if err = bench.Workload().Prepare(); err != nil {
-		return nil, err
+	// Listen for OS signals to stop the benchmark run until the run is over
+	defer StopOnInterrupt(bench)()
+	if mon, ok := bench.(Monitor); ok {
+		defer DumpOnSignal(bench.String(), mon)()
 	}
 
-	// Ensure that the client and the workload are cleaned up when the function is done.
-	defer func() {
-		bench.Workload().Release()
-		bench.Client().Close()
-	}()
+	// Execute the benchmark
+	var partial Metrics
+	if partial, err = Recover(ctx, bench.Run, bench.Results); err != nil {
+		return partial, err
+	}
 
-	// Listen for OS signals to stop the benchmark run
+	return bench.Results()
+}
+
+// StopOnInterrupt stops the benchmark when the process is interrupted so that the
+// results of the partial run are still reported; a second interrupt terminates the
+// process immediately. The returned function stops listening for the interrupt.
+func StopOnInterrupt(bench interface{ Stop(context.Context) error }) (stop func()) {
 	quit := make(chan os.Signal, 1)
+	done := make(chan struct{})
 	signal.Notify(quit, os.Interrupt)
+
 	go func() {
-		<-quit
-		bench.Stop(ctx)
+		select {
+		case <-quit:
+			signal.Stop(quit)
+			log.Warn().Msg("interrupted, stopping the benchmark (interrupt again to exit immediately)")
+			if err := bench.Stop(context.Background()); err != nil {
+				log.Error().Err(err).Msg("could not stop the benchmark")
+			}
+		case <-done:
+		}
 	}()
 
-	// Execute the benchmark
-	if err = bench.Run(ctx); err != nil {
-		return nil, err
+	return func() {
+		signal.Stop(quit)
+		close(done)
 	}
-
-	return bench.Results()
 }
+
+// Exit reasons are recorded in the results of a benchmark to describe why the run
+// terminated, e.g. whether it completed all operations or was stopped by a limit.
+const (
+	ExitCompleted   = "completed"
+	ExitInterrupted = "interrupted"
+	ExitCanceled    = "canceled"
+	ExitPanicked    = "panicked"
+	ExitMaxBytes    = "max_bytes"
+	ExitMaxEvents   = "max_events"
+	ExitMaxDuration = "max_duration"
+)
@@ -0,0 +1,120 @@
+/*
+Package benchtest provides adapters so that the enbench workloads can be invoked from
+standard go test -bench harnesses. Each helper maps b.N to the number of operations of
+the benchmark and reports the enbench measurements as custom benchmark metrics, so that
+Ensign SDK developers can track client-side performance with familiar Go tooling:
+
+	func BenchmarkBlast(b *testing.B) {
+		benchtest.Blast(b, benchtest.Options(b))
+	}
+
+Benchmarks that require an Ensign server are skipped unless credentials are available
+either in a credentials file specified by $ENBENCH_CREDENTIALS or in the environment.
+*/
+package benchtest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/e2e"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+)
+
+// Environment variables used to configure benchmarks run from go test.
+const (
+	EnvCredentials = "ENBENCH_CREDENTIALS"
+	EnvEndpoint    = "ENSIGN_ENDPOINT"
+	EnvAuthURL     = "ENSIGN_AUTH_URL"
+	EnvTopic       = "ENBENCH_TOPIC"
+)
+
+// Options loads the benchmark options from the environment, skipping the benchmark if
+// no credentials are available to connect to an Ensign server.
+func Options(b testing.TB) *options.Options {
+	opts := options.New()
+	opts.Credentials = os.Getenv(EnvCredentials)
+	opts.Endpoint = os.Getenv(EnvEndpoint)
+	opts.AuthURL = os.Getenv(EnvAuthURL)
+
+	if topic := os.Getenv(EnvTopic); topic != "" {
+		opts.Topic = topic
+	}
+
+	if opts.Credentials == "" && os.Getenv(ensign.EnvClientID) == "" {
+		b.Skipf("set $%s or $%s to run benchmarks against an Ensign server", EnvCredentials, ensign.EnvClientID)
+	}
+	return opts
+}
+
+// Blast runs a blast benchmark publishing b.N events and reports the throughput and
+// mean ack latency of the run. Note that the benchmark timer (ns/op) includes
+// connecting to Ensign and generating events; the events/s metric is computed only
+// from the publish phase of the blast and should be used to track throughput.
+func Blast(b *testing.B, opts *options.Options) {
+	conf := *opts
+	conf.Operations = uint64(b.N)
+
+	bench := blast.New(&conf)
+	b.SetBytes(conf.DataSize)
+	b.ResetTimer()
+
+	if err := bench.Run(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+
+	latencies := bench.Latencies()
+	b.ReportMetric(latencies.Throughput(), "events/s")
+	b.ReportMetric(float64(latencies.Mean().Nanoseconds()), "ns/ack")
+	b.ReportMetric(float64(latencies.Timeouts()), "timeouts")
+}
+
+// E2E runs an end-to-end benchmark publishing b.N events and reports the mean ack and
+// delivery latencies of the run along with the number of events that were not delivered.
+func E2E(b *testing.B, opts *options.Options) {
+	conf := *opts
+	conf.Operations = uint64(b.N)
+
+	bench := e2e.New(&conf)
+	b.SetBytes(conf.DataSize)
+	b.ResetTimer()
+
+	if err := bench.Run(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportMetric(float64(bench.AckLatencies().Mean().Nanoseconds()), "ns/ack")
+	b.ReportMetric(float64(bench.DeliveryLatencies().Mean().Nanoseconds()), "ns/delivery")
+	b.ReportMetric(float64(uint64(b.N)-bench.DeliveryLatencies().N()), "undelivered")
+}
+
+// BlastEvents benchmarks the client-side cost of creating the wrapped events that are
+// published by the blast benchmark with the specified payload size.
+func BlastEvents(b *testing.B, size int) {
+	factory := blast.MakeEventFactory(size, ulid.Make())
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		factory()
+	}
+}
+
+// SustainEvents benchmarks the client-side cost of creating the events that are
+// published by the sustain benchmark with the specified payload size.
+func SustainEvents(b *testing.B, size int) {
+	factory := sustain.MakeEventFactory(size)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		factory()
+	}
+}
@@ -0,0 +1,26 @@
+package benchtest_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/benchtest"
+)
+
+func BenchmarkBlast(b *testing.B) {
+	benchtest.Blast(b, benchtest.Options(b))
+}
+
+func BenchmarkE2E(b *testing.B) {
+	benchtest.E2E(b, benchtest.Options(b))
+}
+
+func BenchmarkBlastEvents(b *testing.B) {
+	b.Run("Small", func(b *testing.B) { benchtest.BlastEvents(b, 256) })
+	b.Run("Medium", func(b *testing.B) { benchtest.BlastEvents(b, 8192) })
+	b.Run("Large", func(b *testing.B) { benchtest.BlastEvents(b, 65536) })
+}
+
+func BenchmarkSustainEvents(b *testing.B) {
+	b.Run("Small", func(b *testing.B) { benchtest.SustainEvents(b, 256) })
+	b.Run("Medium", func(b *testing.B) { benchtest.SustainEvents(b, 8192) })
+}
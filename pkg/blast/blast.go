@@ -13,13 +13,18 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
 	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
 	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/placement"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ratelimit"
 	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
 	"github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	"github.com/rs/zerolog"
@@ -39,101 +44,592 @@ func init() {
 // that fires off a workload with a fixed number of requests and measures the amount of
 // time that the server responds to all requests.
 type Blast struct {
+	mu            sync.Mutex // guards the measurements of the run while replies are received
 	opts          *options.Options
-	client        *ensign.Client
+	conn          *benchmarks.EnsignClient
+	client        *ensign.Client // the connected client of conn
 	topicID       ulid.ULID
+	targets       []*Target // the topics of a multi-topic run
 	pubs          api.Ensign_PublishClient
+	pool          []*publisher  // the publish streams of the run, beginning with pubs
+	breakdown     *Breakdown    // the phases of the publish latencies of the run
+	conns         []*connection // the connections of the pool, beginning with client
+	predial       time.Duration // the time taken to pre-dial the streams of the pool
 	subs          api.Ensign_SubscribeClient
 	started       time.Time
 	duration      time.Duration
+	sending       time.Duration
 	events        uint64
 	failures      uint64
-	latencies     []time.Duration
+	operations    uint64 // the number of operations measured by the last run
+	series        *stats.Series
+	service       *stats.Series // the latencies from the actual send of a paced run
+	verifier      *verifier
+	lag           time.Duration // the furthest a paced run fell behind its schedule
+	latest        time.Duration // the latest offset of an operation that has been observed
+	stalls        uint64        // the number of times the publisher waited for the generator
+	wireSize      float64       // the mean serialized size of the published events
+	wire          uint64        // the total serialized size of the published events
+	reservoir     *stats.Reservoir
+	malformed     MalformedResults
+	streamErrors  []string
 	serverVersion string
 	serverID      string
+	exitReason    string
+	placement     *placement.Placement
+	progress      stats.Progress
+	setup         time.Duration
+	warmup        stats.WarmupPhase
+	streams       context.Context    // the context of the publish streams
+	abort         context.CancelFunc // cancels the publish streams if replies are not drained in time
+	run           *runState          // the run in progress, guarded by mu so that it can be stopped
+	interrupted   atomic.Bool        // set if the benchmark is stopped before the run starts
+
+	// Barrier is called after the requests are generated and immediately before the
+	// first request is sent; it blocks until the measurement window should open, e.g.
+	// so that distributed agents start publishing at the same time.
+	Barrier func(context.Context) error
+
+	// OnSample is called with the latency of every operation as soon as its reply is
+	// received, e.g. to export the raw samples without retaining them; operations that
+	// were never replied to are reported with a zero latency once the run is over.
+	OnSample func(time.Duration)
+
+	// Clock timestamps the operations of the run and computes their latencies; by
+	// default the host clock, it can be replaced by a fake clock in tests.
+	Clock clock.Clock
+
+	// DrainTimeout is how long a stopped run waits for the replies to the events that
+	// were already sent before the remaining operations are recorded as timeouts.
+	DrainTimeout time.Duration
 }
 
+// DefaultDrainTimeout is how long a stopped run waits for outstanding replies.
+const DefaultDrainTimeout = 10 * time.Second
+
+// Blast can be run by the generic benchmark harness.
+var _ benchmarks.Benchmark = &Blast{}
+
 func New(opts *options.Options) *Blast {
-	return &Blast{opts: opts}
+	b := &Blast{opts: opts, Clock: clock.Real, DrainTimeout: DefaultDrainTimeout}
+	b.conn = benchmarks.NewEnsignClient(func() (*ensign.Client, error) {
+		return ensign.New(b.opts.Ensign()...)
+	})
+	return b
+}
+
+func (b *Blast) String() string {
+	return "blast"
 }
 
 // Note: this is prototype trash-pumpkin code.
 func (b *Blast) Run(ctx context.Context) (err error) {
+	b.interrupted.Store(false)
 	if err = b.Prepare(ctx); err != nil {
 		return err
 	}
 	defer b.Close()
 
-	// Setup workload
+	// Connect the publish streams of a multi-stream run before the measurement starts
+	if err = b.dialPool(ctx); err != nil {
+		return err
+	}
+
+	// Warm up the publish stream and the server before the requests are generated
+	if err = b.warmUp(ctx); err != nil {
+		return fmt.Errorf("warmup failed: %w", err)
+	}
+
+	// Setup workload, limiting the number of operations to the byte budget if specified
 	N := b.opts.Operations
+	b.exitReason = benchmarks.ExitCompleted
+	if b.opts.MaxBytes > 0 && b.opts.DataSize > 0 {
+		if budget := b.opts.MaxBytes / uint64(b.opts.DataSize); budget < N {
+			N = budget
+			b.exitReason = benchmarks.ExitMaxBytes
+		}
+	}
+
+	if b.opts.MaxEvents > 0 && b.opts.MaxEvents < N {
+		N = b.opts.MaxEvents
+		b.exitReason = benchmarks.ExitMaxEvents
+	}
+
 	b.events = 0
 	b.failures = 0
-	b.latencies = make([]time.Duration, N)
-
-	factory := MakeEventFactory(int(b.opts.DataSize), b.topicID)
+	b.verifier = newVerifier()
+	b.mu.Lock()
+	b.series, b.latest = stats.NewSeries(b.opts.Warmup, b.opts.Cooldown), 0
+	b.mu.Unlock()
+	b.service, b.lag = nil, 0
+	if b.opts.Rate > 0 {
+		b.service = stats.NewSeries(b.opts.Warmup, b.opts.Cooldown)
+	}
+	for _, target := range b.targets {
+		target.Events, target.Failures = 0, 0
+		target.series = stats.NewSeries(b.opts.Warmup, b.opts.Cooldown)
+	}
+	b.reservoir = nil
+	if b.opts.Reservoir > 0 {
+		if b.opts.Seed != 0 {
+			b.reservoir = stats.NewSeededReservoir(b.opts.Reservoir, b.opts.Seed)
+		} else {
+			b.reservoir = stats.NewReservoir(b.opts.Reservoir)
+		}
+	}
 
-	sentat := make([]time.Time, N)
-	recvat := make([]time.Time, N)
-	requests := make([]*api.PublisherRequest, N)
-	responses := make([]*api.PublisherReply, N)
+	b.streamErrors = nil
+	b.breakdown = newBreakdown()
 
-	for i := uint64(0); i < N; i++ {
-		requests[i] = &api.PublisherRequest{
-			Embed: &api.PublisherRequest_Event{
-				Event: factory(),
-			},
-		}
+	// Requests are generated while the benchmark runs so that the memory used does not
+	// depend on the number of operations; the run starts once the generator has gotten
+	// ahead of the publisher, recording how long it takes.
+	setup := b.Clock.Now()
+	var gen *generator
+	if gen, err = b.generate(N); err != nil {
+		return err
 	}
+	defer gen.Stop()
+	gen.Wait()
+	b.setup = b.Clock.Since(setup)
+	log.Debug().Dur("setup", b.setup).Uint64("operations", N).Msg("blast generator primed")
 
 	log.Info().
 		Str("topic", b.opts.Topic).
 		Str("topic_id", b.topicID.String()).
 		Str("server_id", b.serverID).
+		Int("topics", len(b.topicIDs())).
 		Msg("blast benchmark starting")
 
+	if b.Barrier != nil {
+		if err = b.Barrier(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Paced runs publish at a fixed rate; the bucket allows the senders to catch up by a
+	// tenth of a second of events if they fall behind, e.g. while blocked on a stream,
+	// but starts empty so that the run does not begin with a burst.
+	run := &runState{gen: gen, start: make(chan struct{}), done: make(chan struct{}), failed: -1}
+	b.mu.Lock()
+	b.run = run
+	b.mu.Unlock()
+	defer close(run.done)
+
+	if b.interrupted.Load() {
+		run.stop(benchmarks.ExitInterrupted)
+	}
+
+	if b.opts.Rate > 0 {
+		run.limiter = ratelimit.New(b.opts.Rate, b.opts.Rate/10)
+		run.limiter.Reserve(int(b.opts.Rate / 10))
+	}
+
+	// Every stream has its own sender and receiver; the bound on the operations awaiting
+	// replies is shared between the streams.
 	var wg sync.WaitGroup
-	wg.Add(2)
-
-	b.started = time.Now()
-	go func() {
-		defer wg.Done()
-		for i, req := range requests {
-			if err := b.pubs.Send(req); err != nil {
-				log.Error().Err(err).Int("index", i).Msg("benchmark failed to send")
+	window := maxInflight / len(b.pool)
+	for _, pub := range b.pool {
+		pub.series = stats.NewSeries(b.opts.Warmup, b.opts.Cooldown)
+		inflight := make(chan struct{}, window)
+		wg.Add(2)
+		go func(pub *publisher) {
+			defer wg.Done()
+			b.send(ctx, run, pub, inflight)
+		}(pub)
+		go func(pub *publisher) {
+			defer wg.Done()
+			b.receive(run, pub, inflight)
+		}(pub)
+	}
+
+	b.progress.SetClock(b.Clock)
+	b.progress.Start()
+	b.progress.Set("operations", N)
+	b.mu.Lock()
+	b.started = b.Clock.Now()
+	b.mu.Unlock()
+	close(run.start)
+	wg.Wait()
+	b.duration = b.Clock.Since(b.started)
+	b.sending = run.sending
+
+	run.Lock()
+	if run.reason != "" {
+		b.exitReason = run.reason
+	}
+	run.Unlock()
+
+	for _, err := range run.errors {
+		b.streamErrors = append(b.streamErrors, err.Error())
+	}
+
+	// Only report the operations that were sent if the run was stopped by the guard;
+	// operations that could not be sent because of a stream error are timeouts.
+	sent := run.sent.Load()
+	b.operations, b.wireSize, b.wire = sent, 0, run.wire.Load()
+	if sent > 0 {
+		b.wireSize = float64(run.wire.Load()) / float64(sent)
+	}
+
+	// Operations that were never replied to are recorded as timeouts
+	for _, op := range b.verifier.remaining() {
+		b.observe(op, nil, time.Time{})
+	}
+
+	if run.failed >= 0 {
+		unsent := run.unsent + N - gen.index
+		b.operations = sent + unsent
+		for i := uint64(0); i < unsent; i++ {
+			b.observe(operation{sent: b.started.Add(b.sending), stream: run.failed, target: -1}, nil, time.Time{})
+		}
+	}
+
+	if v := b.verifier; v.missing > 0 || v.duplicated > 0 || v.unknown > 0 || v.reordered > 0 {
+		log.Warn().
+			Uint64("acks_missing", v.missing).
+			Uint64("acks_duplicated", v.duplicated).
+			Uint64("acks_unknown", v.unknown).
+			Uint64("out_of_order", v.reordered).
+			Msg("blast replies failed verification")
+	}
+
+	b.stalls = gen.stalls
+	b.series.Close(b.sending)
+	for _, pub := range b.pool {
+		pub.series.Close(b.sending)
+	}
+	if b.service != nil {
+		b.service.Close(b.sending)
+	}
+	b.updateTargets()
+
+	// Re-raise a panic of the generator now that the measurements of the partial run
+	// are complete so that they can be recovered and reported by the caller
+	if p := gen.Panicked(); p != nil {
+		panic(p)
+	}
+	return nil
+}
+
+// The maximum number of operations that may await a reply from the server; the sender
+// blocks until replies are received once the limit is reached.
+const maxInflight = 1 << 16
+
+// ProgressWindow is the period over which the rolling latency of the progress is computed.
+const ProgressWindow = time.Second
+
+// The state shared by the senders and receivers of the streams of a run.
+type runState struct {
+	sync.Mutex
+	gen     *generator
+	limiter *ratelimit.Limiter
+	start   chan struct{} // closed once the measurement window opens
+	done    chan struct{} // closed once the run is over
+	stopped atomic.Bool   // set once the guard is reached or the run is stopped
+	aborted atomic.Bool   // set if the streams were canceled before the replies were drained
+	reason  string        // the exit reason if the run was stopped early
+	sent    atomic.Uint64
+	wire    atomic.Uint64
+	sending time.Duration // the time from the start of the run until the last send
+	unsent  uint64        // the operations that could not be sent because of a send error
+	failed  int           // the last stream that could not send an operation, -1 if none
+	errors  []error
+}
+
+// Stops the senders of the run, returning false if the run was already stopped.
+func (r *runState) stop(reason string) bool {
+	if !r.stopped.CompareAndSwap(false, true) {
+		return false
+	}
+
+	r.Lock()
+	r.reason = reason
+	r.Unlock()
+	return true
+}
+
+// Records an error on a stream of the run.
+func (r *runState) fail(err error) {
+	r.Lock()
+	r.errors = append(r.errors, err)
+	r.Unlock()
+}
+
+// Sends the requests of the workload on the stream until the workload is exhausted, the
+// guard is reached, or a send fails; the streams of a run take requests from the
+// generator as they are ready to send so that a slow stream does not hold up the run.
+func (b *Blast) send(ctx context.Context, run *runState, pub *publisher, inflight chan<- struct{}) {
+	defer close(inflight)
+	<-run.start
+	defer func() {
+		run.Lock()
+		if sending := b.Clock.Since(b.started); sending > run.sending {
+			run.sending = sending
+		}
+		run.Unlock()
+	}()
+
+	// Streams that were not pre-dialed are connected once the run has started
+	if pub.stream == nil {
+		if err := b.dial(ctx, pub); err != nil {
+			log.Error().Err(err).Msg("benchmark failed to dial publish stream")
+			run.fail(err)
+			run.Lock()
+			run.failed = pub.index
+			run.Unlock()
+			return
+		}
+	}
+
+	for {
+		// If the duration guard is reached, stop sending and close the stream so that
+		// the receiver stops once the replies for the sent events have been received.
+		if reason := b.opts.ExhaustedAfter(b.Clock.Since(b.started), run.sent.Load()); reason != "" || run.stopped.Load() {
+			if reason != "" {
+				run.stop(reason)
+			}
+			if err := pub.stream.CloseSend(); err != nil {
+				log.Warn().Err(err).Msg("could not close publisher after the run was stopped")
+			}
+			return
+		}
+
+		run.Lock()
+		req, kind, target, ok := run.gen.Next()
+		seq := run.gen.index - 1
+		run.Unlock()
+		if !ok {
+			return
+		}
+
+		var scheduled time.Time
+		if run.limiter != nil {
+			if _, err := run.limiter.Wait(ctx); err != nil {
+				run.fail(fmt.Errorf("send %d: %w", seq, err))
+				run.Lock()
+				run.unsent++
+				run.failed = pub.index
+				run.Unlock()
 				return
 			}
-			sentat[i] = time.Now()
+			scheduled = b.started.Add(time.Duration(float64(seq) / b.opts.Rate * float64(time.Second)))
 		}
-	}()
 
-	go func() {
-		defer wg.Done()
-		for i := uint64(0); i < N; i++ {
-			rep, err := b.pubs.Recv()
+		// The operation is expected before it is sent so that its reply cannot be
+		// received first, so the latency includes the time taken to serialize and send
+		// the event. The event is serialized before it is written to the stream so that
+		// the phases of the send can be measured separately.
+		id := req.GetEvent().GetLocalId()
+		op := operation{seq: seq, sent: b.Clock.Now(), scheduled: scheduled, stream: pub.index, kind: kind, target: target, phases: &phases{}}
+		msg := b.serialize(pub.stream, req)
+		b.verifier.expect(id, op)
+		writing := b.Clock.Now()
+		if err := pub.stream.SendMsg(msg); err != nil {
+			b.verifier.forget(id)
+			log.Error().Err(err).Uint64("index", seq).Int("stream", pub.index).Msg("benchmark failed to send")
+			run.fail(fmt.Errorf("send %d: %w", seq, err))
+			run.Lock()
+			run.unsent++
+			run.failed = pub.index
+			run.Unlock()
+			return
+		}
+
+		written := b.Clock.Now()
+		op.phases.write(written, b.Clock.Now())
+		if elapsed := written.Sub(writing); elapsed > 0 {
+			b.breakdown.Send.Update(elapsed)
+		}
+
+		// Blocks if too many operations are awaiting replies to bound the memory used
+		inflight <- struct{}{}
+		run.sent.Add(1)
+		run.wire.Add(uint64(len(req.GetEvent().GetEvent())))
+		b.progress.Add("sent", 1)
+	}
+}
+
+// Receives the replies to the operations sent on the stream. Replies are correlated
+// with the operations awaiting them by local ID; a reply that does not match an
+// operation (e.g. a duplicate) does not account for an operation.
+func (b *Blast) receive(run *runState, pub *publisher, inflight <-chan struct{}) {
+	i, done := uint64(0), false
+	for range inflight {
+		for !done {
+			rep, err := pub.stream.Recv()
 			if err != nil {
-				log.Error().Err(err).Uint64("index", i).Msg("benchmark failed to recv")
-				return
+				// No more replies will be received, the remaining operations are missing;
+				// the end of the stream is expected if the run was stopped by the guard,
+				// and the stream is canceled if a stopped run could not be drained in time.
+				done = true
+				if (!run.stopped.Load() || !errors.Is(err, io.EOF)) && !run.aborted.Load() {
+					log.Error().Err(err).Uint64("index", i).Int("stream", pub.index).Msg("benchmark failed to recv")
+					run.fail(fmt.Errorf("recv %d: %w", i, err))
+				}
+				break
+			}
+
+			switch {
+			case rep.GetAck() != nil:
+				b.progress.Add("acks", 1)
+			case rep.GetNack() != nil:
+				b.progress.Add("nacks", 1)
+			}
+
+			if op, ok := b.verifier.match(replyID(rep)); ok {
+				b.observe(op, rep, b.Clock.Now())
+				i++
+				break
 			}
-			responses[i] = rep
-			recvat[i] = time.Now()
 		}
-	}()
+	}
+}
 
-	wg.Wait()
-	b.duration = time.Since(b.started)
+// An operation that has been sent to the server and is awaiting a reply.
+type operation struct {
+	seq       uint64 // the index of the operation in the order it was sent
+	sent      time.Time
+	scheduled time.Time // when a paced operation should have been sent, zero if not paced
+	stream    int       // the index of the publish stream the operation was sent on
+	kind      string    // the kind of malformed event that was sent, if any
+	target    int       // the index of the target of a multi-topic run, -1 if unknown
+	phases    *phases   // the client-side phases of the operation, nil if not recorded
+}
 
-	// TODO: correlate requests and responses to ensure ordering from server is correct
-	for i, recv := range recvat {
-		b.latencies[i] = recv.Sub(sentat[i])
+// Returns the local ID of the event that the reply is for.
+func replyID(rep *api.PublisherReply) []byte {
+	switch {
+	case rep.GetAck() != nil:
+		return rep.GetAck().Id
+	case rep.GetNack() != nil:
+		return rep.GetNack().Id
 	}
 	return nil
 }
 
+// Records the outcome of an operation as soon as its reply is received; a nil reply is
+// recorded as a timeout. The latency of a paced operation is measured from when it was
+// scheduled rather than when it was sent so that a stalled sender does not hide the
+// delays of the operations it failed to send on time (coordinated omission).
+func (b *Blast) observe(op operation, rep *api.PublisherReply, recv time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !op.scheduled.IsZero() {
+		if lag := op.sent.Sub(op.scheduled); lag > b.lag {
+			b.lag = lag
+		}
+	}
+
+	start := op.sent
+	if !op.scheduled.IsZero() && op.scheduled.Before(op.sent) {
+		start = op.scheduled
+	}
+
+	offset := start.Sub(b.started)
+	if offset > b.latest {
+		b.latest = offset
+	}
+
+	var latency time.Duration
+	if rep != nil {
+		latency = recv.Sub(start)
+	}
+
+	if b.service != nil {
+		var service time.Duration
+		if rep != nil {
+			service = recv.Sub(op.sent)
+		}
+		b.service.Add(op.sent.Sub(b.started), service)
+	}
+
+	b.series.Add(offset, latency)
+	b.breakdownReply(op, rep, recv)
+	if op.stream >= 0 && op.stream < len(b.pool) {
+		b.pool[op.stream].series.Add(offset, latency)
+	}
+	if b.reservoir != nil {
+		b.reservoir.Update(latency)
+	}
+
+	if b.OnSample != nil {
+		b.OnSample(latency)
+	}
+
+	var target *Target
+	if len(b.targets) > 0 && op.target >= 0 {
+		target = b.targets[op.target]
+		target.series.Add(offset, latency)
+	}
+
+	switch {
+	case rep.GetAck() != nil:
+		b.events++
+		if target != nil {
+			target.Events++
+		}
+	case rep.GetNack() != nil:
+		b.failures++
+		if target != nil {
+			target.Failures++
+		}
+	}
+
+	if op.kind != "" && rep != nil {
+		b.malformed.Record(op.kind, rep)
+	}
+}
+
+// Stop the run in progress: the senders stop sending and close their streams and the
+// run waits up to the drain timeout for the replies to the events that were already
+// sent, recording the replies that are not received as timeouts, so that the results of
+// the partial run can still be reported. If the run has not started yet it stops as soon
+// as it starts. Stop blocks until the run is over or the context is done.
+func (b *Blast) Stop(ctx context.Context) error {
+	b.interrupted.Store(true)
+
+	b.mu.Lock()
+	run := b.run
+	b.mu.Unlock()
+	if run == nil {
+		return nil
+	}
+
+	if run.stop(benchmarks.ExitInterrupted) {
+		log.Info().Uint64("sent", run.sent.Load()).Msg("blast benchmark stopped, draining replies")
+		go b.drain(run)
+	}
+
+	select {
+	case <-run.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Cancels the publish streams of a stopped run if the replies are not drained in time.
+func (b *Blast) drain(run *runState) {
+	timeout := time.NewTimer(b.DrainTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-run.done:
+	case <-timeout.C:
+		log.Warn().Dur("timeout", b.DrainTimeout).Msg("blast replies were not drained in time, closing publish streams")
+		run.aborted.Store(true)
+		b.abort()
+	}
+}
+
 func (b *Blast) Prepare(ctx context.Context) (err error) {
-	// Initialize the client
-	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+	// Initialize the client unless it was connected by the benchmark harness
+	if err = b.conn.Connect(); err != nil {
 		return err
 	}
+	b.client = b.conn.Ensign()
 
 	// Get the server version
 	var rep *api.ServiceState
@@ -142,18 +638,40 @@ func (b *Blast) Prepare(ctx context.Context) (err error) {
 	}
 	b.serverVersion = rep.Version
 
-	// Get the topic ID for the specified topic
-	// TODO: create topic if it doesn't exist
-	var id string
-	if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
-		return err
+	// Get the topic ID for the specified topic, skipping name resolution if the topic
+	// ID was specified directly. Multi-topic runs create the topics that don't exist.
+	// TODO: create the benchmark topic if it does not exist
+	b.targets = nil
+	if len(b.opts.Topics) > 0 {
+		if err = b.resolveTargets(ctx); err != nil {
+			return err
+		}
+	} else {
+		id := b.opts.TopicID
+		if id == "" {
+			if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+				return err
+			}
+		}
+
+		if b.topicID, err = ulid.Parse(id); err != nil {
+			return err
+		}
 	}
 
-	if b.topicID, err = ulid.Parse(id); err != nil {
-		return err
+	// Record the nodes the topics are placed on if the API exposes placements
+	var placements map[string]*placement.Placement
+	if placements, err = placement.Lookup(ctx, b.client, b.topicIDs()...); err != nil {
+		log.Warn().Err(err).Msg("could not lookup topic placement")
+	}
+	b.placement = placements[b.topicID.String()]
+	for _, target := range b.targets {
+		target.Placement = placements[target.ID.String()]
 	}
 
-	// Open the publish and subscribe streams
+	// Open the publish and subscribe streams; the publish streams can be canceled if the
+	// run is stopped and the replies to the events that were sent are not drained.
+	b.streams, b.abort = context.WithCancel(context.Background())
 	clientID := fmt.Sprintf("benchmarks-%s", ulid.Make())
 	if err = b.openPublisher(clientID); err != nil {
 		return err
@@ -167,34 +685,8 @@ func (b *Blast) Prepare(ctx context.Context) (err error) {
 }
 
 func (b *Blast) openPublisher(clientID string) (err error) {
-	if b.pubs, err = b.client.PublishStream(context.Background()); err != nil {
-		return err
-	}
-
-	req := &api.PublisherRequest{
-		Embed: &api.PublisherRequest_OpenStream{
-			OpenStream: &api.OpenStream{
-				ClientId: clientID,
-			},
-		},
-	}
-
-	if err = b.pubs.Send(req); err != nil {
-		return err
-	}
-
-	var rep *api.PublisherReply
-	if rep, err = b.pubs.Recv(); err != nil {
-		return err
-	}
-
-	var ready *api.StreamReady
-	if ready = rep.GetReady(); ready == nil {
-		return errors.New("did not get publisher ready message")
-	}
-
-	b.serverID = ready.ServerId
-	return nil
+	b.pubs, b.serverID, err = openPublishStream(b.streams, b.client, clientID)
+	return err
 }
 
 func (b *Blast) openSubscriber(clientID string) (err error) {
@@ -202,11 +694,17 @@ func (b *Blast) openSubscriber(clientID string) (err error) {
 		return err
 	}
 
+	ids := b.topicIDs()
+	topics := make([]string, 0, len(ids))
+	for _, id := range ids {
+		topics = append(topics, id.String())
+	}
+
 	req := &api.SubscribeRequest{
 		Embed: &api.SubscribeRequest_Subscription{
 			Subscription: &api.Subscription{
 				ClientId: clientID,
-				Topics:   []string{b.topicID.String()},
+				Topics:   topics,
 			},
 		},
 	}
@@ -247,9 +745,11 @@ func (b *Blast) Close() {
 		}
 	}
 
-	if err := b.client.Close(); err != nil {
+	b.closePool()
+	if err := b.conn.Close(); err != nil {
 		log.Error().Err(err).Msg("could not close ensign client")
 	}
+	b.abort()
 
 	log.Info().Msg("blast benchmark completed")
 }
@@ -259,32 +759,199 @@ func (b *Blast) Results() (benchmarks.Metrics, error) {
 	results["events"] = b.events
 	results["failures"] = b.failures
 
-	latencies := &stats.Latencies{}
-	latencies.Update(b.latencies...)
-	latencies.SetDuration(b.duration)
+	latencies := b.Latencies()
 	results["latencies"] = latencies
+	results["normalized"] = stats.Normalize(latencies, b.opts.DataSize)
+	results["phases"] = b.series.Phases(b.opts.Phases, b.sending)
+
+	// Paced runs also report the latencies from the actual send of each event, which
+	// omit the time that events spent waiting for a stalled sender
+	if b.service != nil {
+		results["service_latencies"] = b.service.Latencies(b.duration)
+		results["target_rate"] = b.opts.Rate
+		results["schedule_lag"] = b.lag.String()
+		if secs := b.sending.Seconds(); secs > 0 {
+			results["offered_rate"] = float64(b.operations) / secs
+		}
+	}
 
-	// TODO: this is a hack just to get a number in for now
-	results["bandwidth"] = float64(b.opts.DataSize*int64(b.opts.Operations)) / b.duration.Seconds()
+	if b.reservoir != nil {
+		results["reservoir"] = b.reservoir
+	}
+
+	if b.malformed != nil {
+		results["malformed"] = b.malformed
+	}
+	results["stream_errors"] = len(b.streamErrors)
+	results["acks_missing"] = b.verifier.missing
+	results["acks_duplicated"] = b.verifier.duplicated
+	results["acks_unknown"] = b.verifier.unknown
+	results["out_of_order"] = b.verifier.reordered
+	results["exit_reason"] = b.exitReason
+
+	// Request generation is excluded from the latencies and throughput of the run
+	// unless the generator could not keep up with the publisher
+	results["setup_duration"] = b.setup.String()
+	results["generator_stalls"] = b.stalls
+	if b.opts.Warming() {
+		results["warmup"] = &b.warmup
+	}
+
+	// All requests on a publish stream are served by the node that opened the stream
+	nodes := make(placement.Breakdown)
+	for _, pub := range b.pool {
+		nodes.Append(pub.serverID, pub.series.All())
+	}
+	results["nodes"] = nodes
+	results["node_asymmetry"] = nodes.Asymmetry()
+
+	// Multi-stream runs report the time taken to connect the streams after the primary
+	if len(b.pool) > 1 {
+		results["streams"] = len(b.pool)
+		results["connections"] = len(b.conns)
+		results["dial_latencies"] = b.dialLatencies()
+		if b.opts.PreDial {
+			results["predial_duration"] = b.predial.String()
+		}
+	}
+
+	results["bandwidth"] = b.Bandwidth()
+	results["wire_size"] = b.wireSize
+	if b.breakdown != nil {
+		results["breakdown"] = b.breakdown
+	}
+
+	// Multi-topic runs also report the events and latencies of each topic
+	if len(b.targets) > 0 {
+		topics := make(map[string]*Target, len(b.targets))
+		for _, target := range b.targets {
+			topics[target.Topic] = target
+		}
+		results["topics"] = topics
+	}
 
 	// TODO: these things are params that need to be output with the results but not metrics
 	results["experiment"] = map[string]interface{}{
-		"client_version": benchmarks.Version(),
-		"server_version": b.serverVersion,
-		"server_id":      b.serverID,
-		"endpoint":       b.opts.Endpoint,
-		"operations":     b.opts.Operations,
-		"data_size":      b.opts.DataSize,
+		"client_version":   benchmarks.Version(),
+		"server_version":   b.serverVersion,
+		"server_id":        b.serverID,
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"malformed":        b.opts.Malformed,
+		"rate":             b.opts.Rate,
+		"streams":          b.opts.Streams,
+		"connections":      b.opts.Connections,
+		"pre_dial":         b.opts.PreDial,
+		"max_bytes":        b.opts.MaxBytes,
+		"guard":            b.opts.Guard(),
+		"payload":          b.opts.Payload,
+		"schema":           b.opts.Schema,
+		"workload":         b.opts.Workload,
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"seed":             b.opts.Seed,
+		"warmup_events":    b.opts.WarmupEvents,
+		"warmup_duration":  b.opts.WarmupDuration.String(),
+		"placement":        b.placement,
+		"topics":           b.opts.Topics,
 	}
 
 	return results, nil
 }
 
-func (b *Blast) Client() (_ *ensign.Client, err error) {
-	if b.client == nil {
-		if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
-			return nil, err
+// Latencies returns the distribution of publish-to-ack latencies from the last run,
+// excluding the operations sent during the warmup and cooldown of the run. The latencies
+// of a paced run are measured from when each event was scheduled to be sent.
+func (b *Blast) Latencies() *stats.Latencies {
+	if b.series == nil {
+		return &stats.Latencies{}
+	}
+	return b.series.Latencies(b.duration)
+}
+
+// Histogram returns the histogram of every publish-to-ack latency observed so far in the
+// current run, including warmup and cooldown; it is safe to call while the run is in
+// progress so that the histogram of an interval can be computed with Sub.
+func (b *Blast) Histogram() *stats.Percentiles {
+	b.mu.Lock()
+	series := b.series
+	b.mu.Unlock()
+
+	if series == nil {
+		return &stats.Percentiles{}
+	}
+	return series.All().Histogram()
+}
+
+// Bandwidth returns the serialized bytes of the events sent by the last run over the
+// time spent sending them, excluding the setup of the run and the wait for the last acks.
+func (b *Blast) Bandwidth() *stats.Bandwidth {
+	bandwidth := &stats.Bandwidth{}
+	bandwidth.Sent(b.wire)
+	bandwidth.SetDuration(b.sending)
+	return bandwidth
+}
+
+// Counts returns the number of events acked and nacked in the last run.
+func (b *Blast) Counts() (events, failures uint64) {
+	return b.events, b.failures
+}
+
+// ExitReason returns the reason that the last run of the benchmark stopped.
+func (b *Blast) ExitReason() string {
+	return b.exitReason
+}
+
+// Progress returns the number of events sent and acked so far while the benchmark runs
+// along with the rolling p99 latency of the most recent operations: those started within
+// the progress window of the latest operation that has been acked or timed out. Paced
+// runs that fall behind their schedule start operations at their scheduled time, so the
+// window is not anchored to the current time.
+func (b *Blast) Progress() map[string]interface{} {
+	snap := b.progress.Snapshot()
+
+	b.mu.Lock()
+	series, latest := b.series, b.latest
+	b.mu.Unlock()
+
+	if series != nil && latest > 0 {
+		recent := series.Since(latest - ProgressWindow)
+		snap["rolling_p99"] = recent.Percentile(99).String()
+	}
+	return snap
+}
+
+// Client returns the client of the benchmark, which is connected when the run is
+// prepared if it has not been connected by the benchmark harness.
+func (b *Blast) Client() benchmarks.Client {
+	return b.conn
+}
+
+// Workload returns the events of the configured payload or workload to inspect or export
+// them; the run generates its own events in parallel so the workload must be prepared
+// and released by the caller. The events are generated for the topic of the last run,
+// which is resolved when the run is prepared.
+func (b *Blast) Workload() benchmarks.Workload {
+	name := b.opts.Workload
+	if name == "" {
+		if name = b.opts.Payload; name == "" {
+			name = "random"
 		}
 	}
-	return b.client, nil
+
+	return &benchmarks.Events[*api.EventWrapper]{
+		Name: name,
+		N:    b.opts.Operations,
+		Factory: func() (func() *api.EventWrapper, io.Closer, error) {
+			if b.opts.Workload != "" {
+				return MakeWorkloadFactory(b.opts.Workload, b.topicID, b.opts.Seed)
+			}
+
+			factory, err := NewEventFactory(b.opts, b.topicID)
+			return factory, workload.Closer(nil), err
+		},
+	}
 }
@@ -0,0 +1,88 @@
+package blast
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"google.golang.org/grpc"
+)
+
+// Breakdown splits the publish latency of the run into its client and server phases so
+// that a slow run can be attributed to the client or the server: serializing the event,
+// writing it to the stream, and the round trip from the write until its reply is
+// received. If the server reports when events were committed, the one-way time from the
+// write until the commit is also reported; it is measured against the server's clock so
+// it includes the offset between the clocks of the hosts. Unlike the latencies of the
+// run, the phases are recorded for the events of the warmup and cooldown as well.
+type Breakdown struct {
+	Serialize *stats.Latencies `json:"serialize"`
+	Send      *stats.Latencies `json:"send"`
+	Server    *stats.Latencies `json:"server"`
+	Commit    *stats.Latencies `json:"commit,omitempty"`
+}
+
+func newBreakdown() *Breakdown {
+	return &Breakdown{
+		Serialize: &stats.Latencies{},
+		Send:      &stats.Latencies{},
+		Server:    &stats.Latencies{},
+	}
+}
+
+// The client-side phases of an operation. The write is recorded by the sender once the
+// event has been written to the stream, which may be after the reply was received; the
+// server phases of such operations are not recorded.
+type phases struct {
+	written atomic.Int64 // when the event was written to the stream by the clock of the run
+	wall    atomic.Int64 // when the event was written to the stream by the host clock
+}
+
+// Records when the event was written to the stream.
+func (p *phases) write(written, wall time.Time) {
+	p.wall.Store(wall.UnixNano())
+	p.written.Store(written.UnixNano())
+}
+
+// Serializes the request for the stream, recording the time taken, so that the time
+// spent writing it to the stream can be measured on its own. If the request cannot be
+// prepared it is returned as is to be serialized by the stream.
+func (b *Blast) serialize(stream grpc.ClientStream, req *api.PublisherRequest) interface{} {
+	started := b.Clock.Now()
+	msg := &grpc.PreparedMsg{}
+	if err := msg.Encode(stream, req); err != nil {
+		return req
+	}
+
+	if elapsed := b.Clock.Since(started); elapsed > 0 {
+		b.breakdown.Serialize.Update(elapsed)
+	}
+	return msg
+}
+
+// Records the server phases of an operation once its reply is received; must be called
+// with the lock of the benchmark.
+func (b *Blast) breakdownReply(op operation, rep *api.PublisherReply, recv time.Time) {
+	if op.phases == nil || rep == nil {
+		return
+	}
+
+	written := op.phases.written.Load()
+	if written == 0 {
+		return
+	}
+
+	if server := recv.Sub(time.Unix(0, written)); server > 0 {
+		b.breakdown.Server.Update(server)
+	}
+
+	if committed := rep.GetAck().GetCommitted(); committed != nil {
+		if commit := committed.AsTime().Sub(time.Unix(0, op.phases.wall.Load())); commit > 0 {
+			if b.breakdown.Commit == nil {
+				b.breakdown.Commit = &stats.Latencies{}
+			}
+			b.breakdown.Commit.Update(commit)
+		}
+	}
+}
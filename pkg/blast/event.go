@@ -3,24 +3,48 @@ package blast
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
-	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type EventFactory func() *api.EventWrapper
 
 func MakeEventFactory(size int, topicID ulid.ULID) EventFactory {
-	count := uint64(0)
-	version := benchmarks.Version()
-	etype := &api.Type{
-		Name:         "Random",
-		MajorVersion: 1,
+	return makeEventFactory(size, topicID, 0, false, &workload.Random{})
+}
+
+// MakeMinimalEventFactory creates events without any metadata; the local ID of the
+// event wrapper is sufficient to correlate the server responses.
+func MakeMinimalEventFactory(size int, topicID ulid.ULID) EventFactory {
+	return makeEventFactory(size, topicID, 0, true, &workload.Random{})
+}
+
+// NewEventFactory returns the event factory for the data size, payload, and metadata
+// mode of the options.
+func NewEventFactory(opts *options.Options, topicID ulid.ULID) (_ EventFactory, err error) {
+	var payload workload.Payload
+	if payload, err = workload.NewPayload(opts.Payload, opts.Schema, opts.Seed); err != nil {
+		return nil, err
 	}
+	return makeEventFactory(int(opts.DataSize), topicID, 0, opts.MinimalMetadata, payload), nil
+}
+
+// Creates an event factory whose counter metadata begins after the specified offset so
+// that multiple factories can generate disjoint portions of a single workload. If
+// minimal is true the events are generated without metadata. The data of the events is
+// generated by the payload, which must not be shared with other factories.
+func makeEventFactory(size int, topicID ulid.ULID, offset uint64, minimal bool, payload workload.Payload) EventFactory {
+	count := offset
+	version := benchmarks.Version()
+	etype := payload.Type()
+	mime := payload.Mimetype()
 
 	entropy := ulid.Monotonic(rand.Reader, 0)
 	idgen := func() ulid.ULID {
@@ -35,15 +59,18 @@ func MakeEventFactory(size int, topicID ulid.ULID) EventFactory {
 	return func() *api.EventWrapper {
 		count++
 		event := &api.Event{
-			Data: generateRandomBytes(size),
-			Metadata: map[string]string{
+			Data:     payload.Next(size),
+			Mimetype: mime,
+			Type:     etype,
+			Created:  timestamppb.Now(),
+		}
+
+		if !minimal {
+			event.Metadata = map[string]string{
 				"app":     "enbench",
 				"counter": fmt.Sprintf("%x", count),
 				"version": version,
-			},
-			Mimetype: mimetype.ApplicationOctetStream,
-			Type:     etype,
-			Created:  timestamppb.Now(),
+			}
 		}
 
 		localID := idgen()
@@ -66,3 +93,26 @@ func generateRandomBytes(n int) (b []byte) {
 	}
 	return b
 }
+
+// MakeWorkloadFactory returns an event factory that publishes the events generated by
+// the named workload from the workload registry rather than random bytes. Server-side
+// wrapper fields populated by the workload (e.g. IDs and offsets) are discarded. If the
+// seed is not zero the workload generates the same events for the same seed. The closer
+// of the workload must be closed once the events have been generated.
+func MakeWorkloadFactory(name string, topicID ulid.ULID, seed int64) (_ EventFactory, _ io.Closer, err error) {
+	var gen workload.Generator
+	if gen, err = workload.Get(name, seed); err != nil {
+		return nil, nil, err
+	}
+
+	entropy := ulid.Monotonic(rand.Reader, 0)
+	return func() *api.EventWrapper {
+		generated := gen.Next()
+		localID := ulid.MustNew(ulid.Timestamp(time.Now()), entropy)
+		return &api.EventWrapper{
+			TopicId: topicID.Bytes(),
+			LocalId: localID.Bytes(),
+			Event:   generated.Event,
+		}
+	}, workload.Closer(gen), nil
+}
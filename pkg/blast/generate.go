@@ -0,0 +1,284 @@
+package blast
+
+import (
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Workloads smaller than this number of events are generated by a single worker since
+// the overhead of coordinating the workers outweighs the benefit.
+const minParallelGenerate = 4096
+
+// The number of requests generated at a time by a worker and the number of batches that
+// each worker may generate ahead of the publisher. Only these batches are held in memory
+// so the memory used by the workload does not depend on the number of operations.
+const (
+	generateBatch = 1024
+	generateAhead = 4
+)
+
+// A contiguous run of the publish requests of the workload.
+type batch struct {
+	requests []*api.PublisherRequest
+	kinds    []string // the kind of malformed event of each request if failures are injected
+	targets  []int    // the index of the target of each request of a multi-topic run
+}
+
+// Generates the publish requests of the workload lazily while the benchmark runs. The
+// batches of the workload are generated round-robin by GOMAXPROCS workers, each with its
+// own payload, and are returned in order so that the counter metadata of every event is
+// its index in the workload after the counter offset, exactly as if the whole workload
+// had been generated up front. Workloads from the workload registry are stateful (e.g.
+// duplicate keys or random walks) and are always generated by a single worker.
+type generator struct {
+	N       uint64
+	workers []chan *batch
+	done    chan struct{}
+	primed  sync.WaitGroup
+	current *batch
+	index   uint64 // the index of the next request in the workload
+	stalls  uint64 // the number of times a batch was not ready when it was needed
+	failed  atomic.Pointer[benchmarks.PanicError]
+}
+
+// Starts the workers that generate the requests of the workload; the generator must be
+// stopped once the run is over. If failure injection is enabled, the kind of malformed
+// event of each request is returned with the request.
+func (b *Blast) generate(N uint64) (gen *generator, err error) {
+	b.malformed = nil
+	if b.opts.Malformed > 0 {
+		b.malformed = make(MalformedResults)
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if b.opts.Workload != "" || N < minParallelGenerate {
+		workers = 1
+	}
+
+	// Create the payloads and workload factories up front so that errors are returned
+	// immediately rather than from a worker.
+	payloads := make([]workload.Payload, workers)
+	factories := make([]EventFactory, workers)
+	closers := make([]io.Closer, workers)
+	for w := range payloads {
+		closers[w] = workload.Closer(nil)
+		if b.opts.Workload != "" {
+			if factories[w], closers[w], err = MakeWorkloadFactory(b.opts.Workload, b.topicID, b.opts.Seed); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if payloads[w], err = b.payload(uint64(w)); err != nil {
+			return nil, err
+		}
+	}
+
+	var schedule []int
+	if len(b.targets) > 0 {
+		schedule = options.Schedule(b.opts.Topics)
+	}
+
+	gen = &generator{N: N, workers: make([]chan *batch, workers), done: make(chan struct{})}
+	gen.primed.Add(workers)
+	for w := range gen.workers {
+		gen.workers[w] = make(chan *batch, generateAhead)
+		go b.generateBatches(gen, w, payloads[w], factories[w], closers[w], schedule)
+	}
+	return gen, nil
+}
+
+// Generates every batch of the workload that is assigned to the worker in order; the
+// closer of the workload factory is closed once the worker is done with the factory.
+func (b *Blast) generateBatches(gen *generator, w int, payload workload.Payload, factory EventFactory, closer io.Closer, schedule []int) {
+	out := gen.workers[w]
+	defer close(out)
+	defer closer.Close()
+
+	sent := 0
+	defer func() {
+		if sent < generateAhead {
+			gen.primed.Done()
+		}
+	}()
+
+	// A panic while generating the workload, e.g. in an event factory, ends the workload
+	// early; the panic is re-raised by the run once the partial run is over.
+	defer func() {
+		if r := recover(); r != nil {
+			gen.failed.CompareAndSwap(nil, benchmarks.Recovered(r))
+		}
+	}()
+
+	// The factory of a random workload is replaced for every batch so that the counter
+	// of the batch begins at the index of the batch; the malformed factory wraps the
+	// current factory so that malformed kinds keep rotating across batches.
+	valid := func() *api.EventWrapper { return factory() }
+
+	// Malformed factories are not safe for concurrent use so each worker has its own
+	var (
+		malformed MalformedFactory
+		rnd       *rand.Rand
+	)
+	if b.malformed != nil {
+		if b.opts.Workload != "" {
+			malformed = MakeMalformedFactory(int(b.opts.DataSize), b.topicID)
+		} else {
+			malformed = malformedFrom(valid)
+		}
+
+		if b.opts.Seed != 0 {
+			rnd = rand.New(rand.NewSource(b.opts.Seed))
+		}
+	}
+
+	stride := uint64(len(gen.workers)) * generateBatch
+	for start := uint64(w) * generateBatch; start < gen.N; start += stride {
+		end := start + generateBatch
+		if end > gen.N {
+			end = gen.N
+		}
+
+		// Seeded runs generate the same payloads and select the same malformed events for
+		// the same batches regardless of the number of workers.
+		if b.opts.Seed != 0 {
+			index := start / generateBatch
+			if rnd != nil {
+				rnd.Seed(b.opts.Seed + int64(index))
+			}
+
+			// The payload of the first batch was created when the generator started
+			if payload != nil && start != uint64(w)*generateBatch {
+				var err error
+				if payload, err = b.payload(index); err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		if payload != nil {
+			factory = makeEventFactory(int(b.opts.DataSize), b.topicID, b.opts.CounterOffset+start, b.opts.MinimalMetadata, payload)
+		}
+
+		bt := &batch{requests: make([]*api.PublisherRequest, 0, end-start)}
+		if malformed != nil {
+			bt.kinds = make([]string, end-start)
+		}
+		if schedule != nil {
+			bt.targets = make([]int, end-start)
+		}
+
+		for i := start; i < end; i++ {
+			var event *api.EventWrapper
+			if malformed != nil && random(rnd) < b.opts.Malformed {
+				bt.kinds[i-start], event = malformed()
+			} else {
+				event = factory()
+			}
+
+			// Malformed events are not reassigned to the targets of a multi-topic run
+			// since some kinds of malformed event deliberately reference an invalid topic.
+			if schedule != nil {
+				target := schedule[i%uint64(len(schedule))]
+				bt.targets[i-start] = target
+				if bt.kinds == nil || bt.kinds[i-start] == "" {
+					event.TopicId = b.targets[target].ID.Bytes()
+				}
+			}
+
+			bt.requests = append(bt.requests, &api.PublisherRequest{
+				Embed: &api.PublisherRequest_Event{
+					Event: event,
+				},
+			})
+		}
+
+		select {
+		case out <- bt:
+		case <-gen.done:
+			return
+		}
+
+		if sent++; sent == generateAhead {
+			gen.primed.Done()
+		}
+	}
+}
+
+// Returns a payload for the batch with the index; seeded runs derive the seed of the
+// payload from the index of the batch so that the workload is the same for the seed.
+func (b *Blast) payload(index uint64) (workload.Payload, error) {
+	seed := b.opts.Seed
+	if seed != 0 {
+		seed += int64(index)
+	}
+	return workload.NewPayload(b.opts.Payload, b.opts.Schema, seed)
+}
+
+// Wait blocks until every worker has generated the batches it may generate ahead of the
+// publisher, so that the start of the run is not measured while the workers catch up.
+func (g *generator) Wait() {
+	g.primed.Wait()
+}
+
+// Next returns the next request of the workload along with the kind of malformed event
+// and the index of the target of the request; false is returned once the workload has
+// been exhausted or a worker has panicked. Next must not be called concurrently.
+func (g *generator) Next() (req *api.PublisherRequest, kind string, target int, ok bool) {
+	if g.index >= g.N {
+		return nil, "", 0, false
+	}
+
+	i := int(g.index % generateBatch)
+	if i == 0 {
+		out := g.workers[(g.index/generateBatch)%uint64(len(g.workers))]
+		var ok bool
+		select {
+		case g.current, ok = <-out:
+		default:
+			g.stalls++
+			g.current, ok = <-out
+		}
+
+		if !ok {
+			return nil, "", 0, false
+		}
+	}
+	g.index++
+
+	req = g.current.requests[i]
+	g.current.requests[i] = nil
+	if g.current.kinds != nil {
+		kind = g.current.kinds[i]
+	}
+	if g.current.targets != nil {
+		target = g.current.targets[i]
+	}
+	return req, kind, target, true
+}
+
+// Panicked returns the panic of a worker that ended the workload early, if any.
+func (g *generator) Panicked() *benchmarks.PanicError {
+	return g.failed.Load()
+}
+
+// Stop the workers; batches that have not been published are discarded.
+func (g *generator) Stop() {
+	close(g.done)
+}
+
+// Returns a random float from the seeded source if specified, otherwise the global source.
+func random(rnd *rand.Rand) float64 {
+	if rnd != nil {
+		return rnd.Float64()
+	}
+	return rand.Float64()
+}
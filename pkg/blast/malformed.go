@@ -0,0 +1,131 @@
+package blast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// Kinds of malformed or boundary-case events that are injected into the workload.
+const (
+	MalformedEmptyData     = "empty_data"
+	MalformedHugeMetadata  = "huge_metadata"
+	MalformedMimetype      = "invalid_mimetype"
+	MalformedMissingType   = "missing_type"
+	MalformedMissingLocal  = "missing_local_id"
+	MalformedCorruptedWrap = "corrupted_event"
+)
+
+// MalformedKinds are injected round-robin by the malformed event factory.
+var MalformedKinds = []string{
+	MalformedEmptyData,
+	MalformedHugeMetadata,
+	MalformedMimetype,
+	MalformedMissingType,
+	MalformedMissingLocal,
+	MalformedCorruptedWrap,
+}
+
+// The huge metadata case adds this many keys with values of the specified size.
+const (
+	hugeMetadataKeys = 1024
+	hugeMetadataSize = 1024
+)
+
+type MalformedFactory func() (string, *api.EventWrapper)
+
+// MakeMalformedFactory returns a factory that creates a malformed or boundary-case
+// event for each call, cycling through all of the MalformedKinds. The kind of event is
+// returned with the event so the server response can be attributed to the case.
+func MakeMalformedFactory(size int, topicID ulid.ULID) MalformedFactory {
+	return malformedFrom(MakeEventFactory(size, topicID))
+}
+
+// Creates a malformed factory that corrupts events from the valid factory, sharing its
+// counter so that malformed and valid events have unique counter metadata.
+func malformedFrom(valid EventFactory) MalformedFactory {
+	count := 0
+	return func() (kind string, wrap *api.EventWrapper) {
+		kind = MalformedKinds[count%len(MalformedKinds)]
+		count++
+
+		wrap = valid()
+		if kind == MalformedCorruptedWrap {
+			// Flip the bytes of the serialized event so that it can't be unmarshaled
+			for i := range wrap.Event {
+				wrap.Event[i] ^= 0xff
+			}
+			return kind, wrap
+		}
+
+		if kind == MalformedMissingLocal {
+			wrap.LocalId = nil
+			return kind, wrap
+		}
+
+		event, err := wrap.Unwrap()
+		if err != nil {
+			panic(err)
+		}
+
+		switch kind {
+		case MalformedEmptyData:
+			event.Data = nil
+		case MalformedHugeMetadata:
+			value := strings.Repeat("x", hugeMetadataSize)
+			for i := 0; i < hugeMetadataKeys; i++ {
+				event.Metadata[fmt.Sprintf("key%04d", i)] = value
+			}
+		case MalformedMimetype:
+			event.Mimetype = mimetype.MIME(0x7fffffff)
+		case MalformedMissingType:
+			event.Type = nil
+		}
+
+		if err = wrap.Wrap(event); err != nil {
+			panic(err)
+		}
+		return kind, wrap
+	}
+}
+
+// MalformedResults records how the server responded to each kind of malformed event.
+type MalformedResults map[string]*MalformedCounts
+
+// MalformedCounts are the server responses for a single kind of malformed event.
+type MalformedCounts struct {
+	Sent      uint64            `json:"sent"`
+	Acked     uint64            `json:"acked"`
+	Nacked    uint64            `json:"nacked"`
+	NackCodes map[string]uint64 `json:"nack_codes,omitempty"`
+	NoReply   uint64            `json:"no_reply"`
+}
+
+func (m MalformedResults) counts(kind string) *MalformedCounts {
+	counts, ok := m[kind]
+	if !ok {
+		counts = &MalformedCounts{NackCodes: make(map[string]uint64)}
+		m[kind] = counts
+	}
+	return counts
+}
+
+// Record the server response for a kind of malformed event; a nil reply indicates
+// that the stream errored or was closed before the reply could be received.
+func (m MalformedResults) Record(kind string, rep *api.PublisherReply) {
+	counts := m.counts(kind)
+	counts.Sent++
+
+	switch {
+	case rep.GetAck() != nil:
+		counts.Acked++
+	case rep.GetNack() != nil:
+		counts.Nacked++
+		counts.NackCodes[rep.GetNack().Code.String()]++
+	default:
+		counts.NoReply++
+	}
+}
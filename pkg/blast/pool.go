@@ -0,0 +1,189 @@
+package blast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// A publish stream of the run. The primary stream is opened on the client of the
+// benchmark when it is prepared; the other streams of a multi-stream run are opened on
+// the connections of the pool so that the events of the run are spread across
+// connections. Unless the pool is pre-dialed, the other streams are dialed by their
+// sender once the run starts.
+type publisher struct {
+	index    int
+	conn     *connection
+	stream   api.Ensign_PublishClient
+	serverID string
+	series   *stats.Series
+	dialed   time.Duration // the time taken to connect the client and open the stream
+}
+
+// A client connection of the pool that is shared by the streams assigned to it. The
+// first connection is the client of the benchmark; the others are connected by the
+// first stream that is dialed on them.
+type connection struct {
+	once   sync.Once
+	client *ensign.Client
+	err    error
+}
+
+// Connects the client if it has not been connected, verifying the connection with a
+// status request.
+func (c *connection) connect(ctx context.Context, opts ...ensign.Option) (*ensign.Client, error) {
+	c.once.Do(func() {
+		if c.client, c.err = ensign.New(opts...); c.err != nil {
+			return
+		}
+		_, c.err = c.client.Status(ctx)
+	})
+	return c.client, c.err
+}
+
+// Returns the publish streams of the run, dialing the streams after the primary stream
+// in parallel if the pool is pre-dialed so that connection establishment is not part of
+// the measurements of the run.
+func (b *Blast) dialPool(ctx context.Context) (err error) {
+	n := b.opts.Streams
+	if n < 1 {
+		n = 1
+	}
+
+	// Each stream has its own connection unless fewer connections are specified
+	c := b.opts.Connections
+	if c < 1 || c > n {
+		c = n
+	}
+
+	b.conns = make([]*connection, c)
+	b.conns[0] = &connection{client: b.client}
+	b.conns[0].once.Do(func() {})
+	for i := 1; i < c; i++ {
+		b.conns[i] = &connection{}
+	}
+
+	b.pool = make([]*publisher, n)
+	b.pool[0] = &publisher{conn: b.conns[0], stream: b.pubs, serverID: b.serverID}
+	for i := 1; i < n; i++ {
+		b.pool[i] = &publisher{index: i, conn: b.conns[i%c]}
+	}
+
+	b.predial = 0
+	if !b.opts.PreDial || n == 1 {
+		return nil
+	}
+
+	started := b.Clock.Now()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for _, pub := range b.pool[1:] {
+		wg.Add(1)
+		go func(pub *publisher) {
+			defer wg.Done()
+			errs[pub.index] = b.dial(ctx, pub)
+		}(pub)
+	}
+	wg.Wait()
+	b.predial = b.Clock.Since(started)
+
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("could not pre-dial publish streams: %w", err)
+	}
+
+	log.Debug().Int("streams", n).Int("connections", c).Dur("duration", b.predial).Msg("blast publish streams pre-dialed")
+	return nil
+}
+
+// Connects the connection of the publisher if it is not already connected and opens a
+// publish stream on it.
+func (b *Blast) dial(ctx context.Context, pub *publisher) (err error) {
+	started := b.Clock.Now()
+	var client *ensign.Client
+	if client, err = pub.conn.connect(ctx, b.opts.Ensign()...); err != nil {
+		return fmt.Errorf("stream %d: %w", pub.index, err)
+	}
+
+	clientID := fmt.Sprintf("benchmarks-%s", ulid.Make())
+	if pub.stream, pub.serverID, err = openPublishStream(b.streams, client, clientID); err != nil {
+		return fmt.Errorf("stream %d: %w", pub.index, err)
+	}
+
+	pub.dialed = b.Clock.Since(started)
+	return nil
+}
+
+// Opens a publish stream and waits for the server to signal that the stream is ready,
+// returning the ID of the server node that the stream is connected to.
+func openPublishStream(ctx context.Context, client *ensign.Client, clientID string) (stream api.Ensign_PublishClient, serverID string, err error) {
+	if stream, err = client.PublishStream(ctx); err != nil {
+		return nil, "", err
+	}
+
+	req := &api.PublisherRequest{
+		Embed: &api.PublisherRequest_OpenStream{
+			OpenStream: &api.OpenStream{
+				ClientId: clientID,
+			},
+		},
+	}
+
+	if err = stream.Send(req); err != nil {
+		return nil, "", err
+	}
+
+	var rep *api.PublisherReply
+	if rep, err = stream.Recv(); err != nil {
+		return nil, "", err
+	}
+
+	var ready *api.StreamReady
+	if ready = rep.GetReady(); ready == nil {
+		return nil, "", errors.New("did not get publisher ready message")
+	}
+	return stream, ready.ServerId, nil
+}
+
+// Closes the streams and connections of the pool other than the primary stream and the
+// client of the benchmark.
+func (b *Blast) closePool() {
+	for _, pub := range b.pool {
+		if pub.index == 0 || pub.stream == nil {
+			continue
+		}
+
+		if err := pub.stream.CloseSend(); err != nil && err != io.EOF {
+			log.Error().Err(err).Int("stream", pub.index).Msg("could not close publisher")
+		}
+	}
+
+	for i, conn := range b.conns {
+		if i == 0 || conn.client == nil {
+			continue
+		}
+
+		if err := conn.client.Close(); err != nil {
+			log.Error().Err(err).Int("connection", i).Msg("could not close ensign client")
+		}
+	}
+}
+
+// Returns the distribution of the time taken to dial each stream after the primary.
+func (b *Blast) dialLatencies() *stats.Latencies {
+	latencies := &stats.Latencies{}
+	for _, pub := range b.pool {
+		if pub.dialed > 0 {
+			latencies.Update(pub.dialed)
+		}
+	}
+	return latencies
+}
@@ -0,0 +1,135 @@
+package blast
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/placement"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// Target is a topic that a multi-topic blast publishes to along with the outcome of the
+// events that were assigned to it during the last run.
+type Target struct {
+	options.Target
+	ID        ulid.ULID
+	Created   bool // true if the topic did not exist and was created by the benchmark
+	Placement *placement.Placement
+	Events    uint64
+	Failures  uint64
+	Latencies *stats.Latencies
+
+	series *stats.Series
+}
+
+// Serializes the target into a JSON map for the per-topic results.
+func (t *Target) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["topic_id"] = t.ID.String()
+	data["weight"] = t.Weight
+	data["created"] = t.Created
+	data["events"] = t.Events
+	data["failures"] = t.Failures
+	data["latencies"] = t.Latencies
+	if t.Placement != nil {
+		data["placement"] = t.Placement
+	}
+	return json.Marshal(data)
+}
+
+// Resolves the topic IDs of the targets of a multi-topic run, creating any topics that
+// do not exist yet. The first target is used as the benchmark topic when a single topic
+// is required, e.g. to report the placement of the run.
+func (b *Blast) resolveTargets(ctx context.Context) (err error) {
+	b.targets = make([]*Target, 0, len(b.opts.Topics))
+	for _, conf := range b.opts.Topics {
+		target := &Target{Target: conf}
+		if target.Weight < 1 {
+			target.Weight = 1
+		}
+
+		if target.ID, target.Created, err = resolveTopic(ctx, b.client, conf.Topic); err != nil {
+			return err
+		}
+		b.targets = append(b.targets, target)
+	}
+
+	b.topicID = b.targets[0].ID
+	return nil
+}
+
+// Returns the ID of the topic, creating the topic if it does not exist. If the create
+// fails because another client (e.g. another distributed agent) created the topic
+// first then the ID of the topic that was created is returned instead.
+func resolveTopic(ctx context.Context, client *ensign.Client, name string) (topicID ulid.ULID, created bool, err error) {
+	var exists bool
+	if exists, err = client.TopicExists(ctx, name); err != nil {
+		return topicID, false, err
+	}
+
+	var id string
+	if exists {
+		if id, err = client.TopicID(ctx, name); err != nil {
+			return topicID, false, err
+		}
+	} else {
+		if id, err = client.CreateTopic(ctx, name); err != nil {
+			var lookupErr error
+			if id, lookupErr = client.TopicID(ctx, name); lookupErr != nil {
+				return topicID, false, err
+			}
+		} else {
+			created = true
+			log.Info().Str("topic", name).Str("topic_id", id).Msg("created blast topic")
+		}
+	}
+
+	if topicID, err = ulid.Parse(id); err != nil {
+		return topicID, false, err
+	}
+	return topicID, created, nil
+}
+
+// Returns the IDs of the topics that the benchmark publishes to.
+func (b *Blast) topicIDs() []ulid.ULID {
+	if len(b.targets) == 0 {
+		return []ulid.ULID{b.topicID}
+	}
+
+	ids := make([]ulid.ULID, 0, len(b.targets))
+	for _, target := range b.targets {
+		ids = append(ids, target.ID)
+	}
+	return ids
+}
+
+// Computes the per-topic latencies of a multi-topic run from the operations that were
+// assigned to each target, excluding the warmup and cooldown of the run.
+func (b *Blast) updateTargets() {
+	for _, target := range b.targets {
+		target.series.Close(b.sending)
+		target.Latencies = target.series.Latencies(b.duration)
+	}
+}
+
+// Created returns the IDs of the topics that did not exist and were created by the
+// benchmark, e.g. so that they can be destroyed once the run is over.
+func (b *Blast) Created() []ulid.ULID {
+	ids := make([]ulid.ULID, 0, len(b.targets))
+	for _, target := range b.targets {
+		if target.Created {
+			ids = append(ids, target.ID)
+		}
+	}
+	return ids
+}
+
+// Targets returns the topics published to by the last multi-topic run along with the
+// per-topic results of the run; nil is returned if the run used a single topic.
+func (b *Blast) Targets() []*Target {
+	return b.targets
+}
@@ -0,0 +1,124 @@
+package blast
+
+import (
+	"sort"
+	"sync"
+)
+
+// The number of replied local IDs that are remembered to detect duplicate replies; a
+// duplicate of an older reply is counted as an unknown reply.
+const verifyRecent = maxInflight
+
+// Verifies that every event published by a run receives exactly one reply in the order
+// that the events were sent on each stream. Replies are correlated with the operations
+// that are awaiting them by the local ID of the event, so the latency of an operation is
+// correct even if the server replies out of order. Events without a local ID (e.g.
+// malformed events) are matched to replies without an ID in the order they were sent.
+// Only the operations in flight and a bounded number of recent replies are retained.
+type verifier struct {
+	sync.Mutex
+	pending    map[string]operation
+	anonymous  []operation         // operations without a local ID, in the order they were sent
+	recent     map[string]struct{} // the local IDs of recently replied operations
+	ring       []string
+	next       int
+	highest    map[int]uint64 // one more than the highest sequence replied to on each stream
+	missing    uint64         // the number of operations that were never replied to
+	duplicated uint64         // the number of replies for operations that were already replied to
+	unknown    uint64         // the number of replies that do not match any operation
+	reordered  uint64         // the number of replies received after a reply to a later operation on the same stream
+}
+
+func newVerifier() *verifier {
+	return &verifier{
+		pending: make(map[string]operation),
+		highest: make(map[int]uint64),
+		recent:  make(map[string]struct{}, verifyRecent),
+		ring:    make([]string, verifyRecent),
+	}
+}
+
+// Expect a reply for the operation; must be called before the event is sent so that
+// the reply cannot be received before the operation is expected.
+func (v *verifier) expect(id []byte, op operation) {
+	v.Lock()
+	defer v.Unlock()
+
+	if len(id) == 0 {
+		v.anonymous = append(v.anonymous, op)
+		return
+	}
+	v.pending[string(id)] = op
+}
+
+// Forget an operation whose event could not be sent.
+func (v *verifier) forget(id []byte) {
+	v.Lock()
+	defer v.Unlock()
+
+	if len(id) == 0 {
+		if n := len(v.anonymous); n > 0 {
+			v.anonymous = v.anonymous[:n-1]
+		}
+		return
+	}
+	delete(v.pending, string(id))
+}
+
+// Match a reply to the operation that is awaiting it; false is returned if the reply is
+// a duplicate or does not match any operation.
+func (v *verifier) match(id []byte) (op operation, ok bool) {
+	v.Lock()
+	defer v.Unlock()
+
+	if len(id) == 0 {
+		if len(v.anonymous) == 0 {
+			v.unknown++
+			return op, false
+		}
+		op, v.anonymous = v.anonymous[0], v.anonymous[1:]
+	} else {
+		key := string(id)
+		if op, ok = v.pending[key]; !ok {
+			if _, ok = v.recent[key]; ok {
+				v.duplicated++
+			} else {
+				v.unknown++
+			}
+			return op, false
+		}
+
+		delete(v.pending, key)
+		if evicted := v.ring[v.next]; evicted != "" {
+			delete(v.recent, evicted)
+		}
+		v.ring[v.next], v.recent[key] = key, struct{}{}
+		v.next = (v.next + 1) % len(v.ring)
+	}
+
+	// The streams of a run send concurrently so the order is only verified per stream
+	if op.seq+1 < v.highest[op.stream] {
+		v.reordered++
+	} else {
+		v.highest[op.stream] = op.seq + 1
+	}
+	return op, true
+}
+
+// Returns the operations that were never replied to in the order they were sent,
+// counting them as missing.
+func (v *verifier) remaining() []operation {
+	v.Lock()
+	defer v.Unlock()
+
+	ops := make([]operation, 0, len(v.pending)+len(v.anonymous))
+	for _, op := range v.pending {
+		ops = append(ops, op)
+	}
+	ops = append(ops, v.anonymous...)
+	sort.Slice(ops, func(i, j int) bool { return ops[i].seq < ops[j].seq })
+
+	v.pending, v.anonymous = make(map[string]operation), nil
+	v.missing += uint64(len(ops))
+	return ops
+}
@@ -0,0 +1,74 @@
+package blast
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// The number of warmup events sent on the stream before waiting for their replies.
+const warmupBatch = 100
+
+// Publishes events on the publish stream in batches until the warmup event count or
+// duration is reached so that stream setup and server-side warm-up are not included in
+// the measurements. Every batch waits for its replies before the next batch is sent so
+// that no warmup replies are outstanding when the benchmark starts.
+func (b *Blast) warmUp(ctx context.Context) (err error) {
+	b.warmup = stats.WarmupPhase{}
+	if !b.opts.Warming() {
+		return nil
+	}
+
+	var factory EventFactory
+	if factory, err = NewEventFactory(b.opts, b.topicID); err != nil {
+		return err
+	}
+	topics := b.topicIDs()
+
+	started := b.Clock.Now()
+	defer func() {
+		b.warmup.Duration = b.Clock.Since(started)
+	}()
+
+	for !b.opts.WarmedUpAfter(b.Clock.Since(started), b.warmup.Events) {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		batch := uint64(warmupBatch)
+		if b.opts.WarmupEvents > 0 && b.opts.WarmupEvents-b.warmup.Events < batch {
+			batch = b.opts.WarmupEvents - b.warmup.Events
+		}
+
+		for i := uint64(0); i < batch; i++ {
+			event := factory()
+			event.TopicId = topics[int(b.warmup.Events)%len(topics)].Bytes()
+			if err = b.pubs.Send(&api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: event}}); err != nil {
+				return err
+			}
+			b.warmup.Events++
+		}
+
+		for i := uint64(0); i < batch; i++ {
+			var rep *api.PublisherReply
+			if rep, err = b.pubs.Recv(); err != nil {
+				return err
+			}
+
+			if rep.GetAck() == nil && rep.GetNack() == nil {
+				return errors.New("unexpected reply to warmup event")
+			}
+			b.warmup.Ack(rep.GetAck() != nil)
+		}
+	}
+
+	log.Info().
+		Uint64("events", b.warmup.Events).
+		Uint64("nacks", b.warmup.Nacked).
+		Dur("duration", b.Clock.Since(started)).
+		Msg("blast warmup complete")
+	return nil
+}
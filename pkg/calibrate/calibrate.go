@@ -0,0 +1,148 @@
+/*
+Package calibrate measures the latency floor of the host and the connection to the
+server before a run so that single-digit-millisecond publish latencies can be
+interpreted in context. The loopback floor is the round trip of a single byte over a
+TCP connection to localhost, i.e. the cost of the host network stack and scheduler
+alone. The server floor is the round trip of a status RPC on the benchmark client,
+which is the minimum achievable round trip to the server since it does no work.
+*/
+package calibrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+)
+
+// DefaultSamples is the number of round trips measured for each floor.
+const DefaultSamples = 100
+
+// Status RPCs that take longer than this are counted as timeouts.
+const statusTimeout = time.Second
+
+// Floor is the distribution of the round trips measured for each floor; the fastest
+// round trip of each distribution is the floor.
+type Floor struct {
+	Loopback *stats.Latencies
+	Server   *stats.Latencies
+}
+
+// Measure the loopback and server floors with the specified number of round trips each.
+func Measure(ctx context.Context, client *ensign.Client, samples int) (floor *Floor, err error) {
+	if samples < 1 {
+		samples = DefaultSamples
+	}
+
+	floor = &Floor{}
+	if floor.Loopback, err = Loopback(samples); err != nil {
+		return nil, fmt.Errorf("could not measure loopback floor: %w", err)
+	}
+
+	if floor.Server, err = Status(ctx, client, samples); err != nil {
+		return nil, fmt.Errorf("could not measure server floor: %w", err)
+	}
+	return floor, nil
+}
+
+// Loopback measures round trips of a single byte over a TCP connection to localhost.
+func Loopback(samples int) (latencies *stats.Latencies, err error) {
+	var sock net.Listener
+	if sock, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	defer sock.Close()
+
+	// Echo every byte received on the accepted connection until it is closed
+	echoed := make(chan error, 1)
+	go func() {
+		conn, err := sock.Accept()
+		if err != nil {
+			echoed <- err
+			return
+		}
+		defer conn.Close()
+
+		_, err = io.Copy(conn, conn)
+		echoed <- err
+	}()
+
+	var conn net.Conn
+	if conn, err = net.Dial("tcp", sock.Addr().String()); err != nil {
+		return nil, err
+	}
+
+	latencies = &stats.Latencies{}
+	buf := make([]byte, 1)
+	started := time.Now()
+	for i := 0; i < samples; i++ {
+		sent := time.Now()
+		if _, err = conn.Write(buf); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if _, err = io.ReadFull(conn, buf); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		latencies.Update(time.Since(sent))
+	}
+	latencies.SetDuration(time.Since(started))
+
+	conn.Close()
+	if err = <-echoed; err != nil && !errors.Is(err, net.ErrClosed) {
+		return nil, err
+	}
+	return latencies, nil
+}
+
+// Status measures round trips of status RPCs on the client; an RPC that does not return
+// within a second is counted as a timeout rather than blocking calibration.
+func Status(ctx context.Context, client *ensign.Client, samples int) (latencies *stats.Latencies, err error) {
+	latencies = &stats.Latencies{}
+	started := time.Now()
+	for i := 0; i < samples; i++ {
+		rctx, cancel := context.WithTimeout(ctx, statusTimeout)
+		sent := time.Now()
+		_, err = client.Status(rctx)
+		cancel()
+
+		switch {
+		case err == nil:
+			latencies.Update(time.Since(sent))
+		case errors.Is(rctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+			latencies.Update(0)
+		default:
+			return nil, err
+		}
+	}
+	latencies.SetDuration(time.Since(started))
+	return latencies, nil
+}
+
+// Overhead returns the amount of the server floor that is not explained by the
+// loopback floor, i.e. the cost of the RPC stack and the network path to the server.
+func (f *Floor) Overhead() time.Duration {
+	if overhead := f.Server.Fastest() - f.Loopback.Fastest(); overhead > 0 {
+		return overhead
+	}
+	return 0
+}
+
+// Serializes the floor into a JSON map with durations as strings.
+func (f *Floor) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["loopback_floor"] = f.Loopback.Fastest().String()
+	data["server_floor"] = f.Server.Fastest().String()
+	data["rpc_overhead"] = f.Overhead().String()
+	data["loopback"] = f.Loopback
+	data["server"] = f.Server
+	return json.Marshal(data)
+}
@@ -0,0 +1,324 @@
+/*
+Package chaos injects faults into the streams of an Ensign client so that benchmarks
+can measure how the client behaves and how long it takes to recover when a stream is
+slow, loses messages, or is disconnected, rather than only measuring the happy path.
+*/
+package chaos
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxDelay is the longest delay injected if a maximum delay is not specified.
+const DefaultMaxDelay = 100 * time.Millisecond
+
+// The kinds of faults that are injected.
+const (
+	KindDelay = "delay"
+	KindDrop  = "drop"
+	KindClose = "close"
+)
+
+var (
+	ErrInjected       = status.Error(codes.Unavailable, "stream closed by fault injection")
+	ErrAuthentication = errors.New("fault injection replaces the authentication of the client, authenticate it with a token monitor")
+)
+
+// Faults are the probabilities that a message sent on a stream is delayed, dropped, or
+// that the stream is closed instead of sending the message. At most one fault is
+// injected per message, so the probabilities must not add up to more than one.
+type Faults struct {
+	Delay    float64       // the probability that a message is delayed before it is sent
+	MaxDelay time.Duration // delays are uniformly distributed up to the maximum delay
+	Drop     float64       // the probability that a message is silently discarded
+	Close    float64       // the probability that the stream is closed on a send
+}
+
+// ParseFaults parses a fault injection configuration such as
+// "delay=5%,max-delay=250ms,drop=1%,close=0.1%" where the probabilities are specified
+// as percentages or as fractions. Omitted faults are not injected.
+func ParseFaults(s string) (faults Faults, err error) {
+	faults.MaxDelay = DefaultMaxDelay
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return Faults{}, fmt.Errorf("could not parse fault %q: expected key=value", part)
+		}
+
+		val = strings.TrimSpace(val)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case KindDelay:
+			faults.Delay, err = parseProbability(val)
+		case "max-delay":
+			if faults.MaxDelay, err = time.ParseDuration(val); err == nil && faults.MaxDelay <= 0 {
+				err = fmt.Errorf("the maximum delay %q must be positive", val)
+			}
+		case KindDrop:
+			faults.Drop, err = parseProbability(val)
+		case KindClose:
+			faults.Close, err = parseProbability(val)
+		default:
+			return Faults{}, fmt.Errorf("unknown fault %q (expected delay, max-delay, drop, or close)", key)
+		}
+
+		if err != nil {
+			return Faults{}, err
+		}
+	}
+
+	if faults.Delay+faults.Drop+faults.Close > 1 {
+		return Faults{}, errors.New("the fault probabilities must not add up to more than 100%")
+	}
+	return faults, nil
+}
+
+func parseProbability(s string) (p float64, err error) {
+	num, percent := strings.CutSuffix(s, "%")
+	if p, err = strconv.ParseFloat(num, 64); err != nil {
+		return 0, fmt.Errorf("could not parse fault probability %q", s)
+	}
+
+	if percent {
+		p /= 100
+	}
+
+	if p < 0 || p > 1 {
+		return 0, fmt.Errorf("fault probability %q must be between 0%% and 100%%", s)
+	}
+	return p, nil
+}
+
+// Enabled returns true if any faults are injected.
+func (f Faults) Enabled() bool {
+	return f.Delay > 0 || f.Drop > 0 || f.Close > 0
+}
+
+func (f Faults) String() string {
+	return fmt.Sprintf("delay=%g%%,max-delay=%s,drop=%g%%,close=%g%%", f.Delay*100, f.MaxDelay, f.Drop*100, f.Close*100)
+}
+
+// Injector is a stream interceptor that injects faults into the messages sent on the
+// streams of a client. The first message of every stream, which opens the stream with
+// the server, is never faulted so that the client is always able to reconnect. When a
+// stream is closed the injector measures the recovery time of the client: the time
+// until a reply is received on a stream of the same method that was opened after the
+// fault. This object is thread-safe.
+type Injector struct {
+	sync.Mutex
+	faults      Faults
+	rnd         *rand.Rand
+	injected    map[string]uint64
+	delayed     time.Duration        // the total delay injected
+	down        map[string]time.Time // the time of the first unrecovered close of each method
+	recoveries  *stats.Latencies
+	unrecovered uint64 // closes that occurred while the method was already recovering
+}
+
+// New creates an injector of the faults; if the seed is zero the faults are randomized
+// from the current time, otherwise the same faults are injected into the same messages.
+func New(faults Faults, seed int64) *Injector {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &Injector{
+		faults:     faults,
+		rnd:        rand.New(rand.NewSource(seed)),
+		injected:   make(map[string]uint64),
+		down:       make(map[string]time.Time),
+		recoveries: &stats.Latencies{},
+	}
+}
+
+// Option configures an Ensign client to inject faults into its streams. Because the
+// default dial options of the SDK are replaced by any custom dial options, a client that
+// authenticates must be configured with the dial options of an identity.TokenMonitor
+// before this option is applied. Note that a mock reuses the connection it was first
+// dialed with, so a mock must be reset with the DialOptions instead.
+func (i *Injector) Option() ensign.Option {
+	return func(o *ensign.Options) error {
+		if len(o.Dialing) == 0 {
+			if !o.Testing && !o.NoAuthentication {
+				return ErrAuthentication
+			}
+
+			transport := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+			if o.Testing || o.Insecure {
+				transport = grpc.WithTransportCredentials(insecure.NewCredentials())
+			}
+			o.Dialing = []grpc.DialOption{transport, grpc.WithUserAgent(fmt.Sprintf(ensign.UserAgent, ensign.VersionMajor))}
+		}
+
+		o.Dialing = append(o.Dialing, grpc.WithChainStreamInterceptor(i.StreamInterceptor))
+		return nil
+	}
+}
+
+// DialOptions returns the gRPC dial options of an insecure connection with faults.
+func (i *Injector) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainStreamInterceptor(i.StreamInterceptor),
+	}
+}
+
+// StreamInterceptor wraps every stream opened by the client to inject faults.
+func (i *Injector) StreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (_ grpc.ClientStream, err error) {
+	// The stream is canceled when it is closed by a fault so the server sees it close
+	ctx, cancel := context.WithCancel(ctx)
+
+	var inner grpc.ClientStream
+	if inner, err = streamer(ctx, desc, cc, method, opts...); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &stream{ClientStream: inner, injector: i, method: method, opened: time.Now(), cancel: cancel}, nil
+}
+
+// Selects the fault to inject into a message, if any.
+func (i *Injector) draw(method string) (kind string, delay time.Duration) {
+	i.Lock()
+	defer i.Unlock()
+
+	r := i.rnd.Float64()
+	switch {
+	case r < i.faults.Close:
+		kind = KindClose
+		if _, recovering := i.down[method]; recovering {
+			i.unrecovered++
+		} else {
+			i.down[method] = time.Now()
+		}
+	case r < i.faults.Close+i.faults.Drop:
+		kind = KindDrop
+	case r < i.faults.Close+i.faults.Drop+i.faults.Delay:
+		kind = KindDelay
+		delay = time.Duration(i.rnd.Int63n(int64(i.faults.MaxDelay)) + 1)
+		i.delayed += delay
+	default:
+		return "", 0
+	}
+
+	i.injected[kind]++
+	return kind, delay
+}
+
+// Records the recovery of the method if the stream was opened after it was closed.
+func (i *Injector) received(s *stream) {
+	i.Lock()
+	defer i.Unlock()
+
+	if closed, ok := i.down[s.method]; ok && s.opened.After(closed) {
+		recovery := time.Since(closed)
+		i.recoveries.Update(recovery)
+		delete(i.down, s.method)
+		log.Debug().Str("method", s.method).Dur("recovery", recovery).Msg("client recovered from injected stream close")
+	}
+}
+
+// Injected returns the number of faults of each kind injected so far.
+func (i *Injector) Injected() map[string]uint64 {
+	i.Lock()
+	defer i.Unlock()
+
+	injected := make(map[string]uint64, len(i.injected))
+	for kind, n := range i.injected {
+		injected[kind] = n
+	}
+	return injected
+}
+
+// Recoveries returns the distribution of the time it took the client to recover from
+// the injected stream closes, and the number of closes it has not yet recovered from.
+func (i *Injector) Recoveries() (recoveries *stats.Latencies, unrecovered uint64) {
+	i.Lock()
+	defer i.Unlock()
+
+	recoveries = &stats.Latencies{}
+	recoveries.Append(i.recoveries)
+	return recoveries, i.unrecovered + uint64(len(i.down))
+}
+
+// Serializes the injected faults and the recoveries of the client into a JSON map.
+func (i *Injector) MarshalJSON() ([]byte, error) {
+	recoveries, unrecovered := i.Recoveries()
+
+	i.Lock()
+	defer i.Unlock()
+
+	data := make(map[string]interface{})
+	data["faults"] = i.faults.String()
+	data["delays"] = i.injected[KindDelay]
+	data["drops"] = i.injected[KindDrop]
+	data["closes"] = i.injected[KindClose]
+	data["total_delay"] = i.delayed.String()
+	data["recoveries"] = recoveries
+	data["unrecovered"] = unrecovered
+	return json.Marshal(data)
+}
+
+// A client stream that injects faults into the messages sent on it.
+type stream struct {
+	grpc.ClientStream
+	injector *Injector
+	method   string
+	opened   time.Time
+	cancel   context.CancelFunc
+	sent     uint64 // gRPC does not allow concurrent sends so this is not locked
+	closed   atomic.Bool
+}
+
+func (s *stream) SendMsg(m interface{}) error {
+	if s.closed.Load() {
+		return ErrInjected
+	}
+
+	if s.sent++; s.sent > 1 {
+		switch kind, delay := s.injector.draw(s.method); kind {
+		case KindDelay:
+			time.Sleep(delay)
+		case KindDrop:
+			return nil
+		case KindClose:
+			s.closed.Store(true)
+			s.cancel()
+			return ErrInjected
+		}
+	}
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *stream) RecvMsg(m interface{}) (err error) {
+	if err = s.ClientStream.RecvMsg(m); err != nil {
+		if s.closed.Load() {
+			return ErrInjected
+		}
+		return err
+	}
+
+	s.injector.received(s)
+	return nil
+}
@@ -0,0 +1,96 @@
+package chaos_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/chaos"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestParseFaults(t *testing.T) {
+	faults, err := chaos.ParseFaults("delay=5%, max-delay=250ms, drop=0.01,close=0.1%")
+	require.NoError(t, err)
+	require.Equal(t, chaos.Faults{Delay: 0.05, MaxDelay: 250 * time.Millisecond, Drop: 0.01, Close: 0.001}, faults)
+	require.True(t, faults.Enabled())
+
+	faults, err = chaos.ParseFaults("")
+	require.NoError(t, err)
+	require.False(t, faults.Enabled())
+	require.Equal(t, chaos.DefaultMaxDelay, faults.MaxDelay)
+
+	for _, spec := range []string{"delay", "jitter=5%", "drop=150%", "close=-1", "max-delay=0s", "delay=60%,drop=60%"} {
+		_, err = chaos.ParseFaults(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestInjector(t *testing.T) {
+	// Every message after the first is closed, so every stream is closed on its second send
+	injector := chaos.New(chaos.Faults{Close: 1}, 42)
+
+	first, _, err := open(injector)
+	require.NoError(t, err)
+	require.NoError(t, first.SendMsg("open"), "expected the first message of a stream to not be faulted")
+	require.NoError(t, first.RecvMsg(nil))
+	require.ErrorIs(t, first.SendMsg("event"), chaos.ErrInjected)
+	require.ErrorIs(t, first.SendMsg("event"), chaos.ErrInjected, "expected the stream to remain closed")
+	require.ErrorIs(t, first.RecvMsg(nil), chaos.ErrInjected)
+
+	recoveries, unrecovered := injector.Recoveries()
+	require.Zero(t, recoveries.N())
+	require.Equal(t, uint64(1), unrecovered)
+
+	// The client recovers once it receives a reply on a new stream
+	time.Sleep(time.Millisecond)
+	second, _, err := open(injector)
+	require.NoError(t, err)
+	require.NoError(t, second.SendMsg("open"))
+	require.NoError(t, second.RecvMsg(nil))
+
+	recoveries, unrecovered = injector.Recoveries()
+	require.Equal(t, uint64(1), recoveries.N())
+	require.GreaterOrEqual(t, recoveries.Fastest(), time.Millisecond)
+	require.Zero(t, unrecovered)
+	require.Equal(t, map[string]uint64{chaos.KindClose: 1}, injector.Injected())
+
+	// Dropped messages are never sent
+	injector = chaos.New(chaos.Faults{Drop: 1}, 42)
+	stream, inner, err := open(injector)
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg("open"))
+	require.NoError(t, stream.SendMsg("event"))
+	require.Equal(t, 1, inner.sent)
+}
+
+// Opens a stream on a fake server through the injector.
+func open(injector *chaos.Injector) (stream grpc.ClientStream, inner *fake, err error) {
+	stream, err = injector.StreamInterceptor(context.Background(), &grpc.StreamDesc{}, nil, "/ensign.v1beta1.Ensign/Publish", func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+		inner = &fake{ctx: ctx}
+		return inner, nil
+	})
+	return stream, inner, err
+}
+
+// A stream that replies to every message until its context is canceled.
+type fake struct {
+	grpc.ClientStream
+	ctx  context.Context
+	sent int
+}
+
+func (f *fake) SendMsg(interface{}) error {
+	f.sent++
+	return nil
+}
+
+func (f *fake) RecvMsg(interface{}) error {
+	if err := f.ctx.Err(); err != nil {
+		return errors.Join(io.ErrUnexpectedEOF, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package benchmarks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+)
+
+// How often Exec checks a published event for an ack.
+const execPoll = time.Millisecond
+
+// EnsignClient adapts the Ensign client of a benchmark to the Client interface so that
+// the benchmark can be run by the generic Run harness. The client is dialed when it is
+// connected; benchmarks that are run directly connect the client when they prepare the
+// run and close it when the run is over, in which case closing it again does nothing.
+type EnsignClient struct {
+	dial   func() (*ensign.Client, error)
+	client *ensign.Client
+}
+
+// PublishRequest is the request executed by an EnsignClient: the event is published to
+// the topic, which may be a topic name or ID, and Exec returns once it is acked.
+type PublishRequest struct {
+	Topic string
+	Event *ensign.Event
+}
+
+// NewEnsignClient returns a client that is dialed with the function when connected.
+func NewEnsignClient(dial func() (*ensign.Client, error)) *EnsignClient {
+	return &EnsignClient{dial: dial}
+}
+
+func (c *EnsignClient) String() string {
+	return "ensign"
+}
+
+// Connect dials the Ensign client if it is not already connected.
+func (c *EnsignClient) Connect() (err error) {
+	if c.client != nil {
+		return nil
+	}
+
+	if c.client, err = c.dial(); err != nil {
+		c.client = nil
+		return err
+	}
+	return nil
+}
+
+// Ensign returns the connected Ensign client, or nil if the client is not connected.
+func (c *EnsignClient) Ensign() *ensign.Client {
+	return c.client
+}
+
+// Exec publishes the event of a *PublishRequest and waits for the server to ack it,
+// returning the acked event or an error if the event is nacked or the context is done.
+func (c *EnsignClient) Exec(ctx context.Context, req interface{}) (_ interface{}, err error) {
+	if c.client == nil {
+		return nil, errors.New("the ensign client is not connected")
+	}
+
+	pub, ok := req.(*PublishRequest)
+	if !ok {
+		return nil, fmt.Errorf("cannot execute %T, a publish request is required", req)
+	}
+
+	if err = c.client.Publish(pub.Topic, pub.Event); err != nil {
+		return nil, err
+	}
+
+	poll := time.NewTicker(execPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-poll.C:
+			if acked, err := pub.Event.Acked(); acked || err != nil {
+				return pub.Event, err
+			}
+
+			if nacked, err := pub.Event.Nacked(); nacked {
+				if err == nil {
+					err = errors.New("event was nacked")
+				}
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close the Ensign client if it is connected.
+func (c *EnsignClient) Close() (err error) {
+	if c.client == nil {
+		return nil
+	}
+
+	err = c.client.Close()
+	c.client = nil
+	return err
+}
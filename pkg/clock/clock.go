@@ -0,0 +1,83 @@
+/*
+Package clock checks the health of the host clock before timing-sensitive benchmark
+runs. Latencies are computed from monotonic clock readings, but cross-host comparisons
+(e.g. publish-to-delivery latency measured on different machines) also depend on the
+wall clock being synchronized, so the synchronization state is recorded with the run.
+*/
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultMaxError is the largest estimated clock error tolerated in accuracy mode.
+const DefaultMaxError = 100 * time.Millisecond
+
+var (
+	ErrUnsynchronized = errors.New("host clock is not synchronized")
+	ErrNotMonotonic   = errors.New("time readings do not carry a monotonic clock")
+	ErrUnsupported    = errors.New("clock synchronization status is not available on this platform")
+)
+
+// Health describes the synchronization state of the host clock at the time of a check.
+type Health struct {
+	Checked   time.Time     `json:"checked"`
+	Supported bool          `json:"supported"`
+	Synced    bool          `json:"synced"`
+	MaxError  time.Duration `json:"max_error"`
+	EstError  time.Duration `json:"est_error"`
+	Monotonic bool          `json:"monotonic"`
+}
+
+// Check the health of the host clock. If the synchronization status cannot be read on
+// this platform, Supported is false and the synchronization fields are zero valued.
+func Check() (health *Health, err error) {
+	now := time.Now()
+	health = &Health{
+		Checked:   now.Round(0),
+		Monotonic: IsMonotonic(now),
+	}
+
+	if err = readSync(health); err != nil {
+		if errors.Is(err, ErrUnsupported) {
+			return health, nil
+		}
+		return nil, err
+	}
+
+	health.Supported = true
+	return health, nil
+}
+
+// Validate returns an error if the clock is not trustworthy for timing sensitive runs:
+// the clock must be synchronized (if the status is available) with an estimated maximum
+// error below the specified threshold and time readings must be monotonic.
+func (h *Health) Validate(maxError time.Duration) error {
+	if !h.Monotonic {
+		return ErrNotMonotonic
+	}
+
+	if !h.Supported {
+		return nil
+	}
+
+	if !h.Synced {
+		return ErrUnsynchronized
+	}
+
+	if maxError > 0 && h.MaxError > maxError {
+		return fmt.Errorf("host clock max error %s exceeds threshold %s", h.MaxError, maxError)
+	}
+	return nil
+}
+
+// IsMonotonic reports if the timestamp carries a monotonic clock reading, which is
+// required for durations computed with Sub or Since to be immune to wall clock steps.
+// Timestamps lose their monotonic reading when rounded, truncated, serialized, or
+// converted to another location.
+func IsMonotonic(ts time.Time) bool {
+	return strings.Contains(ts.String(), " m=")
+}
@@ -0,0 +1,61 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	health, err := clock.Check()
+	require.NoError(t, err)
+	require.True(t, health.Monotonic)
+	require.False(t, clock.IsMonotonic(health.Checked), "checked timestamp should be a wall clock reading")
+}
+
+func TestValidate(t *testing.T) {
+	health := &clock.Health{Supported: true, Synced: true, MaxError: 20 * time.Millisecond, Monotonic: true}
+	require.NoError(t, health.Validate(clock.DefaultMaxError))
+	require.Error(t, health.Validate(10*time.Millisecond))
+
+	health.Synced = false
+	require.ErrorIs(t, health.Validate(clock.DefaultMaxError), clock.ErrUnsynchronized)
+
+	health.Supported = false
+	require.NoError(t, health.Validate(clock.DefaultMaxError))
+
+	health.Monotonic = false
+	require.ErrorIs(t, health.Validate(clock.DefaultMaxError), clock.ErrNotMonotonic)
+}
+
+func TestIsMonotonic(t *testing.T) {
+	now := time.Now()
+	require.True(t, clock.IsMonotonic(now))
+	require.False(t, clock.IsMonotonic(now.Round(0)))
+	require.False(t, clock.IsMonotonic(now.UTC()))
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start, 0)
+	require.Equal(t, start, fake.Now())
+	require.Equal(t, start, fake.Now(), "expected a clock without a step not to move")
+
+	fake.Advance(time.Second)
+	require.Equal(t, time.Second, fake.Since(start))
+
+	fake.Set(start)
+	require.Equal(t, time.Duration(0), fake.Since(start))
+
+	// Every reading advances a stepping clock
+	fake = clock.NewFake(start, time.Millisecond)
+	sent := fake.Now()
+	require.Equal(t, start, sent)
+	require.Equal(t, time.Millisecond, fake.Since(sent))
+	require.Equal(t, 2*time.Millisecond, fake.Since(sent))
+
+	fake.Advance(time.Second)
+	require.Equal(t, start.Add(1003*time.Millisecond), fake.Now())
+}
@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by the benchmarks to timestamp operations and to
+// compute latencies, so that tests can replace the host clock with a Fake clock.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+}
+
+// Real is the host clock, whose readings carry a monotonic clock reading.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+// Fake is a clock that only moves when it is told to, for deterministic tests of the
+// measurements made with it. If the step is nonzero the clock is advanced by the step
+// after every reading so that consecutive readings are distinct; note that a benchmark
+// measured with a clock that does not move records every latency as a timeout. This
+// object is thread-safe.
+type Fake struct {
+	sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewFake returns a fake clock that starts at the specified time and is advanced by
+// step after every reading.
+func NewFake(start time.Time, step time.Duration) *Fake {
+	return &Fake{now: start, step: step}
+}
+
+// Now returns the current time of the clock, advancing the clock by the step.
+func (f *Fake) Now() time.Time {
+	f.Lock()
+	defer f.Unlock()
+	now := f.now
+	f.now = f.now.Add(f.step)
+	return now
+}
+
+// Since returns the time elapsed since t, reading the clock like Now.
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Advance the clock by the specified duration without reading it.
+func (f *Fake) Advance(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set the current time of the clock without reading it.
+func (f *Fake) Set(now time.Time) {
+	f.Lock()
+	defer f.Unlock()
+	f.now = now
+}
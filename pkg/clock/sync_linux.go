@@ -0,0 +1,29 @@
+//go:build linux
+
+package clock
+
+import (
+	"syscall"
+	"time"
+)
+
+// Kernel clock status flag indicating the clock is unsynchronized (see adjtimex(2)).
+const staUnsync = 0x0040
+
+// Read the kernel NTP state with a read-only adjtimex call.
+func readSync(health *Health) (err error) {
+	var (
+		tx    syscall.Timex
+		state int
+	)
+
+	if state, err = syscall.Adjtimex(&tx); err != nil {
+		return err
+	}
+
+	// TIME_ERROR (5) is returned if the clock is unsynchronized
+	health.Synced = state != 5 && tx.Status&staUnsync == 0
+	health.MaxError = time.Duration(tx.Maxerror) * time.Microsecond
+	health.EstError = time.Duration(tx.Esterror) * time.Microsecond
+	return nil
+}
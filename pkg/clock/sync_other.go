@@ -0,0 +1,7 @@
+//go:build !linux
+
+package clock
+
+func readSync(*Health) error {
+	return ErrUnsupported
+}
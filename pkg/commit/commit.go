@@ -0,0 +1,600 @@
+/*
+Package commit implements a benchmark of how often a consumer commits its position in a
+topic. Ensign tracks the position of a consumer group by the events its consumers ack
+rather than by an explicit offset commit, so the commit frequency of a consumer is how
+often it sends the acks of the events it has processed. The topic is filled once and
+then drained by a new at-least-once consumer group for each commit policy: every event
+is acked as it is received, acks are buffered and sent every N events, or buffered acks
+are flushed on an interval. The consumer disconnects without sending its buffered acks
+every time it has received a fixed number of new events and then reconnects to its
+group, so the events that were delivered but not committed are redelivered. The policies
+are compared by the drain throughput and the number of redelivered events.
+*/
+package commit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// The metadata key that identifies the events published by the benchmark.
+const LocalIDKey = "local_id"
+
+// Defaults for the commit benchmark.
+const (
+	DefaultFillTimeout    = 30 * time.Second
+	DefaultIdleTimeout    = 5 * time.Second
+	DefaultReconnectEvery = 1000
+)
+
+// DefaultPolicies are the commit policies compared if none are specified.
+var DefaultPolicies = []string{"event", "count:100", "interval:100ms"}
+
+// How often the fill phase checks in-flight events for acks.
+const ackPoll = time.Millisecond
+
+// Policy determines when a consumer sends the acks of the events it has received. If
+// neither the count nor the interval is set, acks are only sent once the drain is done.
+type Policy struct {
+	Name     string
+	Count    int           // send the buffered acks once this many events are buffered
+	Interval time.Duration // send the buffered acks on this interval
+}
+
+// ParsePolicy parses a commit policy: "event" acks every event, "count:N" acks every N
+// events, and "interval:D" acks the events received during each interval of duration D.
+func ParsePolicy(s string) (policy Policy, err error) {
+	policy.Name = strings.ToLower(strings.TrimSpace(s))
+	kind, arg, _ := strings.Cut(policy.Name, ":")
+
+	switch kind {
+	case "event":
+		policy.Count = 1
+	case "count":
+		if policy.Count, err = strconv.Atoi(arg); err != nil || policy.Count < 1 {
+			return policy, fmt.Errorf("invalid commit policy %q: the count must be a positive integer", s)
+		}
+	case "interval":
+		if policy.Interval, err = time.ParseDuration(arg); err != nil || policy.Interval <= 0 {
+			return policy, fmt.Errorf("invalid commit policy %q: the interval must be a positive duration", s)
+		}
+	default:
+		return policy, fmt.Errorf("unknown commit policy %q", s)
+	}
+	return policy, nil
+}
+
+// Commit fills a topic and drains it with a consumer group for each commit policy.
+type Commit struct {
+	opts      *options.Options
+	client    *ensign.Client
+	topicID   ulid.ULID
+	groups    []*api.ConsumerGroup // the consumer group of each policy
+	published map[string]uint64    // the publish order of the events committed by the fill
+	nacks     uint64
+	filling   time.Duration
+	results   []*PolicyResult
+	reason    string
+	progress  stats.Progress
+
+	// Policies are the commit policies to compare, drained in order.
+	Policies []Policy
+
+	// ReconnectEvery is the number of new events the consumer receives before it
+	// disconnects and reconnects to its group; if zero the consumer never reconnects.
+	ReconnectEvery uint64
+
+	// FillTimeout is how long to wait for the filled events to be acked.
+	FillTimeout time.Duration
+
+	// IdleTimeout ends the drain of a policy if no events are received for this duration.
+	IdleTimeout time.Duration
+}
+
+// PolicyResult records the drain of the topic by the consumer group of a policy.
+type PolicyResult struct {
+	Policy      Policy
+	Group       string
+	Received    uint64        // the number of distinct filled events received
+	Delivered   uint64        // the number of events delivered including redeliveries
+	Redelivered uint64        // the number of deliveries of events that were already received
+	Foreign     uint64        // the number of events received that were not published by the fill
+	Acks        uint64        // the number of acks sent
+	Commits     uint64        // the number of times buffered acks were sent
+	Discarded   uint64        // the number of buffered acks that were not sent before a disconnect
+	Connects    uint64        // the number of times the consumer connected to the group
+	Duration    time.Duration // the time from the first connect to the last event received
+}
+
+// EventsPerSec returns the rate at which distinct events were received.
+func (r *PolicyResult) EventsPerSec() float64 {
+	if secs := r.Duration.Seconds(); secs > 0 {
+		return float64(r.Received) / secs
+	}
+	return 0
+}
+
+// RedeliveryRatio returns the number of redeliveries per distinct event received.
+func (r *PolicyResult) RedeliveryRatio() float64 {
+	if r.Received == 0 {
+		return 0
+	}
+	return float64(r.Redelivered) / float64(r.Received)
+}
+
+// Serializes the result into a JSON map with durations as strings.
+func (r *PolicyResult) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["policy"] = r.Policy.Name
+	data["group"] = r.Group
+	data["received"] = r.Received
+	data["delivered"] = r.Delivered
+	data["redelivered"] = r.Redelivered
+	data["redelivery_ratio"] = r.RedeliveryRatio()
+	data["foreign"] = r.Foreign
+	data["acks"] = r.Acks
+	data["commits"] = r.Commits
+	data["discarded_acks"] = r.Discarded
+	data["connects"] = r.Connects
+	data["duration"] = r.Duration.String()
+	data["events_per_sec"] = r.EventsPerSec()
+	return json.Marshal(data)
+}
+
+func New(opts *options.Options) *Commit {
+	b := &Commit{
+		opts:           opts,
+		ReconnectEvery: DefaultReconnectEvery,
+		FillTimeout:    DefaultFillTimeout,
+		IdleTimeout:    DefaultIdleTimeout,
+	}
+
+	for _, name := range DefaultPolicies {
+		policy, _ := ParsePolicy(name)
+		b.Policies = append(b.Policies, policy)
+	}
+	return b
+}
+
+func (b *Commit) Run(ctx context.Context) (err error) {
+	if len(b.Policies) == 0 {
+		return errors.New("specify at least one commit policy to benchmark")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	// Subscriptions require topic IDs so resolve the topic name if necessary
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	// Connect each group before the fill so that the groups begin at the filled events
+	run := ulid.Make().String()
+	b.groups = make([]*api.ConsumerGroup, 0, len(b.Policies))
+	for i := range b.Policies {
+		group := &api.ConsumerGroup{
+			Name:     fmt.Sprintf("enbench-commit-%s-%d", run, i),
+			Delivery: api.DeliverySemantic_AT_LEAST_ONCE,
+		}
+
+		var stream api.Ensign_SubscribeClient
+		var cancel context.CancelFunc
+		if stream, cancel, err = b.connect(ctx, group); err != nil {
+			return fmt.Errorf("could not create consumer group: %w", err)
+		}
+		stream.CloseSend()
+		cancel()
+		b.groups = append(b.groups, group)
+	}
+
+	b.reason = benchmarks.ExitCompleted
+	if err = b.fill(ctx); err != nil {
+		return err
+	}
+
+	if len(b.published) == 0 {
+		return errors.New("no events were committed to the topic during the fill")
+	}
+
+	log.Info().
+		Str("topic", b.opts.Topic).
+		Str("topic_id", b.topicID.String()).
+		Int("events", len(b.published)).
+		Dur("fill", b.filling).
+		Int("policies", len(b.Policies)).
+		Msg("topic filled, draining consumer groups")
+
+	b.results = make([]*PolicyResult, 0, len(b.Policies))
+	b.progress.Start()
+	b.progress.Set("events", uint64(len(b.published)))
+	for i, policy := range b.Policies {
+		var result *PolicyResult
+		if result, err = b.drain(ctx, policy, b.groups[i]); err != nil {
+			return err
+		}
+		b.results = append(b.results, result)
+		b.progress.Add("policies", 1)
+
+		log.Info().
+			Str("policy", policy.Name).
+			Uint64("received", result.Received).
+			Uint64("redelivered", result.Redelivered).
+			Dur("duration", result.Duration).
+			Msg("commit policy drained")
+
+		if b.reason != benchmarks.ExitCompleted {
+			break
+		}
+	}
+	return nil
+}
+
+// Publishes the events to the topic and waits for the server to ack them.
+func (b *Commit) fill(ctx context.Context) (err error) {
+	N := b.opts.Operations
+	if b.opts.MaxEvents > 0 && b.opts.MaxEvents < N {
+		N = b.opts.MaxEvents
+	}
+
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	inflight := make(map[string]*ensign.Event, N)
+	sequence := make(map[string]uint64, N)
+	b.published = make(map[string]uint64, N)
+	b.nacks = 0
+
+	started := time.Now()
+	defer func() {
+		b.filling = time.Since(started)
+	}()
+
+	for i := uint64(0); i < N; i++ {
+		event := factory()
+		if err = b.client.Publish(b.topicID.String(), event); err != nil {
+			return err
+		}
+		inflight[event.Metadata[LocalIDKey]] = event
+		sequence[event.Metadata[LocalIDKey]] = i
+	}
+
+	poll := time.NewTicker(ackPoll)
+	defer poll.Stop()
+	timeout := time.After(b.FillTimeout)
+
+	for len(inflight) > 0 {
+		select {
+		case <-poll.C:
+			for localID, event := range inflight {
+				if acked, _ := event.Acked(); acked {
+					b.published[localID] = sequence[localID]
+					delete(inflight, localID)
+					continue
+				}
+
+				if nacked, _ := event.Nacked(); nacked {
+					b.nacks++
+					delete(inflight, localID)
+				}
+			}
+		case <-timeout:
+			log.Warn().Int("unacked", len(inflight)).Msg("commit fill timeout exceeded")
+			return nil
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Drains the topic with the consumer group of the policy, reconnecting to the group
+// every time the reconnect number of new events has been received, until every filled
+// event has been received or no events arrive within the idle timeout.
+func (b *Commit) drain(ctx context.Context, policy Policy, group *api.ConsumerGroup) (result *PolicyResult, err error) {
+	result = &PolicyResult{Policy: policy, Group: group.Name}
+	seen := make(map[string]struct{}, len(b.published))
+	started := time.Now()
+	last := started
+
+	defer func() {
+		result.Duration = last.Sub(started)
+	}()
+
+	for done := false; !done; {
+		if reason := b.opts.Exhausted(started, result.Received); reason != "" {
+			b.reason = reason
+			return result, nil
+		}
+
+		if done, err = b.consume(ctx, policy, group, result, seen, &last); err != nil {
+			if ctx.Err() != nil {
+				b.reason = benchmarks.ExitCanceled
+			}
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Consumes events from a single connection to the consumer group, sending acks as
+// dictated by the policy. The consumer disconnects without sending the buffered acks
+// once the reconnect number of new events has been received; true is returned once
+// the drain is over.
+func (b *Commit) consume(ctx context.Context, policy Policy, group *api.ConsumerGroup, result *PolicyResult, seen map[string]struct{}, last *time.Time) (done bool, err error) {
+	var stream api.Ensign_SubscribeClient
+	var cancel context.CancelFunc
+	if stream, cancel, err = b.connect(ctx, group); err != nil {
+		return false, err
+	}
+	defer cancel()
+	result.Connects++
+
+	pending := make([][]byte, 0, policy.Count)
+	commit := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		for _, id := range pending {
+			if err := stream.Send(&api.SubscribeRequest{Embed: &api.SubscribeRequest_Ack{Ack: &api.Ack{Id: id}}}); err != nil {
+				return err
+			}
+		}
+		result.Acks += uint64(len(pending))
+		result.Commits++
+		pending = pending[:0]
+		return nil
+	}
+
+	var tick <-chan time.Time
+	if policy.Interval > 0 {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	idle := time.NewTimer(b.IdleTimeout)
+	defer idle.Stop()
+
+	events, errs := receive(stream)
+	received := uint64(0)
+	for {
+		select {
+		case event := <-events:
+			*last = time.Now()
+			result.Delivered++
+			pending = append(pending, event.Id)
+
+			var localID string
+			if unwrapped, err := event.Unwrap(); err == nil {
+				localID = unwrapped.Metadata[LocalIDKey]
+			}
+
+			if _, ok := b.published[localID]; !ok {
+				result.Foreign++
+			} else if _, ok := seen[localID]; ok {
+				result.Redelivered++
+			} else {
+				seen[localID] = struct{}{}
+				result.Received++
+				received++
+				b.progress.Add("received", 1)
+			}
+
+			if policy.Count > 0 && len(pending) >= policy.Count {
+				if err = commit(); err != nil {
+					return false, err
+				}
+			}
+
+			// Commit the remaining acks so that the group is caught up once drained
+			if len(seen) == len(b.published) {
+				if err = commit(); err != nil {
+					return false, err
+				}
+				b.disconnect(stream, events, errs)
+				return true, nil
+			}
+
+			if b.ReconnectEvery > 0 && received >= b.ReconnectEvery {
+				result.Discarded += uint64(len(pending))
+				b.disconnect(stream, events, errs)
+				return false, nil
+			}
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(b.IdleTimeout)
+		case <-tick:
+			if err = commit(); err != nil {
+				return false, err
+			}
+		case <-idle.C:
+			log.Warn().Str("policy", policy.Name).Int("missing", len(b.published)-len(seen)).Msg("commit idle timeout exceeded")
+			result.Discarded += uint64(len(pending))
+			return true, nil
+		case err = <-errs:
+			return false, err
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// Closes the send side of the stream and waits for the server to end the stream so that
+// the acks that were already sent are committed before the consumer reconnects; events
+// delivered in the meantime are discarded and will be redelivered.
+func (b *Commit) disconnect(stream api.Ensign_SubscribeClient, events <-chan *api.EventWrapper, errs <-chan error) {
+	if err := stream.CloseSend(); err != nil {
+		return
+	}
+
+	timeout := time.NewTimer(b.IdleTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-events:
+		case <-errs:
+			return
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+// Opens a subscribe stream to the consumer group and waits for the server to signal
+// that the stream is ready; canceling the returned function drops the connection.
+func (b *Commit) connect(ctx context.Context, group *api.ConsumerGroup) (stream api.Ensign_SubscribeClient, cancel context.CancelFunc, err error) {
+	var sctx context.Context
+	sctx, cancel = context.WithCancel(ctx)
+	if stream, err = b.client.SubscribeStream(sctx); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	req := &api.SubscribeRequest{
+		Embed: &api.SubscribeRequest_Subscription{
+			Subscription: &api.Subscription{
+				ClientId: fmt.Sprintf("benchmarks-%s", ulid.Make()),
+				Topics:   []string{b.topicID.String()},
+				Group:    group,
+			},
+		},
+	}
+
+	if err = stream.Send(req); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	var rep *api.SubscribeReply
+	if rep, err = stream.Recv(); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if rep.GetReady() == nil {
+		cancel()
+		return nil, nil, errors.New("did not get subscriber ready message")
+	}
+	return stream, cancel, nil
+}
+
+// Receives events from the stream in a separate go routine so that the consumer can
+// select on events, commit intervals, and the idle timeout. The go routine exits once
+// the stream is canceled.
+func receive(stream api.Ensign_SubscribeClient) (<-chan *api.EventWrapper, <-chan error) {
+	events := make(chan *api.EventWrapper)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			rep, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if event := rep.GetEvent(); event != nil {
+				select {
+				case events <- event:
+				case <-stream.Context().Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, errs
+}
+
+// Progress returns the number of events received and policies drained so far.
+func (b *Commit) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+func (b *Commit) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["events"] = len(b.published)
+	results["nacks"] = b.nacks
+	results["fill_duration"] = b.filling.String()
+	results["policies"] = b.results
+	results["exit_reason"] = b.reason
+
+	names := make([]string, 0, len(b.Policies))
+	for _, policy := range b.Policies {
+		names = append(names, policy.Name)
+	}
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"policies":         names,
+		"reconnect_every":  b.ReconnectEvery,
+		"fill_timeout":     b.FillTimeout.String(),
+		"idle_timeout":     b.IdleTimeout.String(),
+	}
+	return results, nil
+}
+
+// PolicyResults returns the result of the drain of each commit policy by the last run.
+func (b *Commit) PolicyResults() []*PolicyResult {
+	return b.results
+}
+
+// WriteTable writes a table comparing the throughput and redeliveries of the policies.
+func (b *Commit) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "POLICY\tEVENTS/SEC\tRECEIVED\tREDELIVERED\tRATIO\tACKS\tCOMMITS\tCONNECTS\tDURATION\t")
+	for _, result := range b.results {
+		fmt.Fprintf(tw, "%s\t%.2f\t%d\t%d\t%.3f\t%d\t%d\t%d\t%s\t\n",
+			result.Policy.Name,
+			result.EventsPerSec(),
+			result.Received,
+			result.Redelivered,
+			result.RedeliveryRatio(),
+			result.Acks,
+			result.Commits,
+			result.Connects,
+			result.Duration,
+		)
+	}
+	return tw.Flush()
+}
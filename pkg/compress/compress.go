@@ -0,0 +1,140 @@
+/*
+Package compress reads and writes the persisted raw data of benchmark runs with zstd
+compression so that the samples and time series of multi-hundred-million-sample runs
+remain storable. Files are compressed when their path has the zstd extension and are
+written as a stream so that memory remains bounded regardless of the size of the run.
+Readers detect zstd frames by their magic number so that analysis commands accept
+compressed and uncompressed inputs transparently.
+*/
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Extension of the paths that are written with zstd compression.
+const Extension = ".zst"
+
+// The magic number at the start of every zstd frame.
+var magic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// IsCompressed returns true if the path should be written with zstd compression.
+func IsCompressed(path string) bool {
+	return strings.HasSuffix(path, Extension)
+}
+
+// Create the file at path, compressing the data written to it with zstd if the path has
+// the zstd extension. The file must be closed to flush the end of the stream.
+func Create(path string) (_ io.WriteCloser, err error) {
+	var f *os.File
+	if f, err = os.Create(path); err != nil {
+		return nil, err
+	}
+
+	if !IsCompressed(path) {
+		return f, nil
+	}
+
+	var enc *zstd.Encoder
+	if enc, err = zstd.NewWriter(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &writer{Encoder: enc, file: f}, nil
+}
+
+// Open the file at path, decompressing it if it is zstd compressed.
+func Open(path string) (_ io.ReadCloser, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	var r *reader
+	if r, err = newReader(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.file = f
+	return r, nil
+}
+
+// NewReader returns a reader of r that decompresses the data if it is zstd compressed;
+// closing the reader releases the decoder but does not close r.
+func NewReader(r io.Reader) (_ io.ReadCloser, err error) {
+	return newReader(r)
+}
+
+// ReadFile reads the file at path, decompressing it if it is zstd compressed.
+func ReadFile(path string) (_ []byte, err error) {
+	var r io.ReadCloser
+	if r, err = Open(path); err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func newReader(src io.Reader) (_ *reader, err error) {
+	buf := bufio.NewReader(src)
+	r := &reader{Reader: buf}
+
+	// A short input cannot be a zstd frame and is returned as is
+	var header []byte
+	if header, err = buf.Peek(len(magic)); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.Equal(header, magic) {
+		if r.dec, err = zstd.NewReader(buf, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+		r.Reader = r.dec
+	}
+	return r, nil
+}
+
+type writer struct {
+	*zstd.Encoder
+	file   *os.File
+	closed bool
+}
+
+// Close flushes the end of the zstd stream and closes the file; closing the writer more
+// than once does not write another frame so Close can also be deferred.
+func (w *writer) Close() (err error) {
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	if err = w.Encoder.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+type reader struct {
+	io.Reader
+	dec  *zstd.Decoder
+	file *os.File
+}
+
+// Close releases the decoder and closes the file if the reader was opened from a path.
+func (r *reader) Close() error {
+	if r.dec != nil {
+		r.dec.Close()
+	}
+
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package compress_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte(strings.Repeat(`{"latency_ns":1250000,"timeout":false}`+"\n", 10000))
+
+	for _, name := range []string{"samples.jsonl", "samples.jsonl.zst"} {
+		path := filepath.Join(dir, name)
+
+		w, err := compress.Create(path)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		if compress.IsCompressed(path) {
+			require.Less(t, len(raw), len(data)/10, "compressed file should be smaller than the data")
+			require.ErrorIs(t, w.Close(), os.ErrClosed, "closing twice should not write another frame")
+		} else {
+			require.Equal(t, data, raw)
+		}
+
+		read, err := compress.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, data, read, "%s should be read transparently", name)
+
+		r, err := compress.NewReader(bytes.NewReader(raw))
+		require.NoError(t, err)
+		read, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, data, read, "%s should be decompressed from a stream", name)
+	}
+
+	// Inputs shorter than the zstd magic number are returned as is
+	r, err := compress.NewReader(strings.NewReader("{}"))
+	require.NoError(t, err)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(read))
+}
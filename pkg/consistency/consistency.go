@@ -0,0 +1,447 @@
+/*
+Package consistency implements a lightweight read-your-writes probe rather than a full
+benchmark. In a loop, the probe publishes a single event and immediately tries to read
+it back, either from a subscription opened just before the publish (latest) or from the
+history of the topic after the publish is acked (earliest), recording how often the
+event is read back and how long it takes to become visible. The probe is meant to run
+continuously at a low rate alongside other workloads to detect consistency regressions.
+*/
+package consistency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// Where a probe reads its event back from.
+const (
+	FromLatest   = "latest"   // a new subscription opened before the event is published
+	FromEarliest = "earliest" // the topic history read after the event is acked
+)
+
+const (
+	DefaultInterval = time.Second
+	DefaultTimeout  = 5 * time.Second
+)
+
+// ProbeIDKey is the metadata key of the unique ID used to recognize a probe event.
+const ProbeIDKey = "probe_id"
+
+// How often a probe checks for the ack and how often the history is queried again
+// while the event is not yet visible.
+const (
+	ackPoll   = time.Millisecond
+	queryPoll = 10 * time.Millisecond
+)
+
+// Consistency publishes probe events and reads them back until it is stopped.
+type Consistency struct {
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	probes   uint64
+	reads    uint64 // the number of probes whose event was read back
+	missed   uint64 // the number of acked probes whose event was not read back in time
+	nacks    uint64
+	unacked  uint64 // the number of probes that were not acked in time
+	failures uint64 // the number of probes that failed to publish, subscribe, or query
+	acks     *stats.Latencies
+	delays   *stats.Latencies // the time from publishing to reading the event back
+	started  time.Time
+	duration time.Duration
+	reason   string
+	progress stats.Progress
+
+	// From is where the probe events are read back from, latest or earliest.
+	From string
+
+	// Interval is the time between the start of consecutive probes.
+	Interval time.Duration
+
+	// Timeout is how long a probe waits for its event to be acked and read back.
+	Timeout time.Duration
+}
+
+func New(opts *options.Options) *Consistency {
+	return &Consistency{opts: opts, From: FromLatest, Interval: DefaultInterval, Timeout: DefaultTimeout}
+}
+
+// Run probes the topic every interval until the configured number of probes have run,
+// the run limits are reached, or the probe is interrupted; if the number of operations
+// is zero the probe runs until it is interrupted. Probes that fail are recorded rather
+// than stopping the run so that transient errors show up in the success rate.
+func (b *Consistency) Run(ctx context.Context) (err error) {
+	if b.From != FromLatest && b.From != FromEarliest {
+		return fmt.Errorf("unknown consistency probe mode %q, must be latest or earliest", b.From)
+	}
+
+	if b.Interval <= 0 || b.Timeout <= 0 {
+		return errors.New("the probe interval and timeout must be positive")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+
+	b.acks, b.delays = &stats.Latencies{}, &stats.Latencies{}
+	b.probes, b.reads, b.missed, b.nacks, b.unacked, b.failures = 0, 0, 0, 0, 0, 0
+	b.reason = benchmarks.ExitCompleted
+
+	log.Info().
+		Str("topic", b.opts.Topic).
+		Str("topic_id", b.topicID.String()).
+		Str("from", b.From).
+		Dur("interval", b.Interval).
+		Msg("probing read-your-writes consistency")
+
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+
+	b.progress.Start()
+	b.started = time.Now()
+	defer func() {
+		b.duration = time.Since(b.started)
+	}()
+
+probing:
+	for {
+		if N := b.opts.Operations; N > 0 && b.probes >= N {
+			break
+		}
+
+		if reason := b.opts.Exhausted(b.started, b.probes); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		if err = b.probe(ctx, factory()); err != nil {
+			if ctx.Err() != nil {
+				b.reason = benchmarks.ExitCanceled
+				return ctx.Err()
+			}
+			b.failures++
+			b.progress.Add("failures", 1)
+			log.Warn().Err(err).Uint64("probe", b.probes).Msg("consistency probe failed")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			b.reason = benchmarks.ExitInterrupted
+			break probing
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+
+	log.Info().Uint64("probes", b.probes).Uint64("reads", b.reads).Msg("consistency probe complete")
+	return nil
+}
+
+// Publishes a single event and reads it back, recording the outcome of the probe. An
+// error is returned if the probe could not be performed at all.
+func (b *Consistency) probe(ctx context.Context, event *ensign.Event) (err error) {
+	pctx, cancel := context.WithTimeout(ctx, b.Timeout)
+	defer cancel()
+
+	probeID := ulid.Make().String()
+	if event.Metadata == nil {
+		event.Metadata = make(ensign.Metadata)
+	}
+	event.Metadata[ProbeIDKey] = probeID
+
+	b.probes++
+	b.progress.Add("probes", 1)
+
+	var read *reader
+	switch b.From {
+	case FromLatest:
+		if read, err = b.subscribe(pctx); err != nil {
+			return err
+		}
+	case FromEarliest:
+		if read, err = b.history(pctx); err != nil {
+			return err
+		}
+	}
+	defer read.close()
+
+	sent := time.Now()
+	if err = b.client.Publish(b.topicID.String(), event); err != nil {
+		return err
+	}
+
+	// The ack and the delivery are awaited together since a subscriber may receive the
+	// event before the publisher observes the ack.
+	var acked, delivered bool
+	poll := time.NewTicker(ackPoll)
+	defer poll.Stop()
+
+	for !acked || !delivered {
+		select {
+		case <-poll.C:
+			if acked {
+				continue
+			}
+
+			if ok, _ := event.Acked(); ok {
+				acked = true
+				b.acks.Update(time.Since(sent))
+			} else if nacked, _ := event.Nacked(); nacked {
+				b.nacks++
+				b.progress.Add("nacks", 1)
+				return nil
+			}
+		case id := <-read.events:
+			if id == probeID && !delivered {
+				delivered = true
+				b.delays.Update(time.Since(sent))
+			}
+		case err = <-read.errs:
+			return err
+		case <-pctx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			switch {
+			case !acked:
+				b.unacked++
+				b.progress.Add("unacked", 1)
+			default:
+				b.missed++
+				b.progress.Add("missed", 1)
+			}
+			return nil
+		}
+
+		// The history can only be read once the event is committed
+		if acked && !delivered && read.start != nil {
+			read.start()
+			read.start = nil
+		}
+	}
+
+	b.reads++
+	b.progress.Add("reads", 1)
+	return nil
+}
+
+// A reader delivers the probe IDs of the events it reads back from the topic.
+type reader struct {
+	events <-chan string
+	errs   <-chan error
+	start  func() // starts reading, if reading must wait until the event is acked
+	close  func()
+}
+
+// Opens a new subscription to the topic that must deliver the event published after it.
+func (b *Consistency) subscribe(ctx context.Context) (_ *reader, err error) {
+	sctx, cancel := context.WithCancel(ctx)
+	var stream api.Ensign_SubscribeClient
+	if stream, err = b.client.SubscribeStream(sctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	req := &api.SubscribeRequest{
+		Embed: &api.SubscribeRequest_Subscription{
+			Subscription: &api.Subscription{
+				ClientId: fmt.Sprintf("benchmarks-%s", ulid.Make()),
+				Topics:   []string{b.topicID.String()},
+			},
+		},
+	}
+
+	if err = stream.Send(req); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var rep *api.SubscribeReply
+	if rep, err = stream.Recv(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if rep.GetReady() == nil {
+		cancel()
+		return nil, errors.New("did not get subscriber ready message")
+	}
+
+	events := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			rep, err := stream.Recv()
+			if err != nil {
+				if sctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+
+			if wrapper := rep.GetEvent(); wrapper != nil {
+				var probeID string
+				if event, err := wrapper.Unwrap(); err == nil {
+					probeID = event.Metadata[ProbeIDKey]
+				}
+
+				select {
+				case events <- probeID:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return &reader{events: events, errs: errs, close: cancel}, nil
+}
+
+// Reads the history of the topic from the head before the event is published with
+// EnSQL queries, querying again until the event is visible or the probe times out.
+func (b *Consistency) history(ctx context.Context) (_ *reader, err error) {
+	var info *api.TopicInfo
+	if info, err = b.client.TopicInfo(ctx, b.topicID); err != nil {
+		return nil, err
+	}
+
+	qctx, cancel := context.WithCancel(ctx)
+	events := make(chan string)
+	errs := make(chan error, 1)
+	query := &api.Query{Query: fmt.Sprintf("SELECT * FROM %s OFFSET %d", b.opts.Topic, info.Events)}
+
+	read := &reader{events: events, errs: errs, close: cancel}
+	read.start = func() {
+		go func() {
+			for {
+				if err := b.query(qctx, query, events); err != nil {
+					if qctx.Err() == nil {
+						errs <- err
+					}
+					return
+				}
+
+				select {
+				case <-time.After(queryPoll):
+				case <-qctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return read, nil
+}
+
+// Sends the probe IDs of the events returned by the query until the cursor is exhausted.
+func (b *Consistency) query(ctx context.Context, query *api.Query, events chan<- string) (err error) {
+	var cursor *ensign.QueryCursor
+	if cursor, err = b.client.EnSQL(ctx, query); err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	for {
+		var event *ensign.Event
+		if event, err = cursor.FetchOne(); err != nil {
+			if errors.Is(err, ensign.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case events <- event.Metadata[ProbeIDKey]:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Progress returns the number of probes run so far and their outcomes.
+func (b *Consistency) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// SuccessRate returns the fraction of the probes whose event was read back.
+func (b *Consistency) SuccessRate() float64 {
+	if b.probes == 0 {
+		return 0
+	}
+	return float64(b.reads) / float64(b.probes)
+}
+
+func (b *Consistency) Results() (benchmarks.Metrics, error) {
+	if b.acks == nil {
+		return nil, errors.New("the consistency probe has not been run")
+	}
+
+	b.acks.SetDuration(b.duration)
+	b.delays.SetDuration(b.duration)
+
+	results := make(metrics.Metrics)
+	results["probes"] = b.probes
+	results["reads"] = b.reads
+	results["missed"] = b.missed
+	results["nacks"] = b.nacks
+	results["unacked"] = b.unacked
+	results["failures"] = b.failures
+	results["success_rate"] = b.SuccessRate()
+	results["ack_latencies"] = b.acks
+	results["read_delays"] = b.delays
+	results["duration"] = b.duration.String()
+	results["exit_reason"] = b.reason
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"from":             b.From,
+		"interval":         b.Interval.String(),
+		"timeout":          b.Timeout.String(),
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+	}
+	return results, nil
+}
@@ -0,0 +1,74 @@
+package consume
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultAckQueue is the capacity of the queue of received events awaiting an ack worker.
+const DefaultAckQueue = 1024
+
+// The ack workers pipeline the acks of the received events with the receive loop. The
+// receive loop enqueues every event and the workers ack them concurrently, recording
+// the latency of each ack; the depth of the queue is sampled on every enqueue so that
+// it shows whether the workers keep up with the deliveries of the subscription.
+type ackPool struct {
+	queue chan *ensign.Event
+	depth *stats.QueueDepth
+	acks  [][]time.Duration // the ack latencies recorded by each worker
+	last  []time.Time       // when each worker last acked an event
+	wg    sync.WaitGroup
+}
+
+func newAckPool(workers, capacity, expected int) *ackPool {
+	p := &ackPool{
+		queue: make(chan *ensign.Event, capacity),
+		depth: stats.NewQueueDepth(capacity),
+		acks:  make([][]time.Duration, workers),
+		last:  make([]time.Time, workers),
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		p.acks[i] = make([]time.Duration, 0, expected/workers+1)
+		go p.work(i)
+	}
+	return p
+}
+
+func (p *ackPool) work(worker int) {
+	defer p.wg.Done()
+	for event := range p.queue {
+		acking := time.Now()
+		if _, err := event.Ack(); err != nil {
+			log.Debug().Err(err).Msg("could not ack delivered event")
+		}
+		p.last[worker] = time.Now()
+		p.acks[worker] = append(p.acks[worker], p.last[worker].Sub(acking))
+	}
+}
+
+// Enqueues the event to be acked, blocking the receive loop if the queue is full.
+func (p *ackPool) ack(event *ensign.Event) {
+	p.depth.Update(len(p.queue))
+	p.queue <- event
+}
+
+// Waits for the workers to ack the queued events, returning the latencies of the acks
+// and when the last event was acked.
+func (p *ackPool) wait() (acks []time.Duration, last time.Time) {
+	close(p.queue)
+	p.wg.Wait()
+
+	for i := range p.acks {
+		acks = append(acks, p.acks[i]...)
+		if p.last[i].After(last) {
+			last = p.last[i]
+		}
+	}
+	return acks, last
+}
@@ -0,0 +1,440 @@
+/*
+Package consume implements a benchmark of the subscribe path. The topic is pre-filled
+with a fixed number of events while a subscription is open but not being read, so the
+events are queued for the subscriber; once every event has been acked by the server the
+subscriber drains the topic as fast as it can, measuring the delivery throughput and
+the latency of acking each event back to the server. The bandwidth of the subscriber
+may be capped to emulate a slow reader on a constrained downstream network, in which
+case the benchmark reports how often the connection and subscription were dropped.
+*/
+package consume
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ratelimit"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/connectivity"
+)
+
+// The metadata key that identifies the events published by the benchmark.
+const LocalIDKey = "local_id"
+
+// Default timeouts of the fill and drain phases.
+const (
+	DefaultFillTimeout = 30 * time.Second
+	DefaultIdleTimeout = 5 * time.Second
+)
+
+// How often the fill phase checks in-flight events for acks.
+const ackPoll = time.Millisecond
+
+// Consume pre-fills a topic and measures how fast a subscriber drains the events.
+type Consume struct {
+	opts      *options.Options
+	client    *ensign.Client
+	topicID   ulid.ULID
+	published map[string]uint64 // the publish order of the events committed by the fill
+	nacks     uint64
+	filling   time.Duration // the duration of the fill phase
+	received  uint64
+	foreign   uint64 // the number of events received that were not published by the fill
+	delivery  stats.Delivery
+	bytes     uint64
+	acks      []time.Duration
+	queue     *stats.QueueDepth // the depth of the ack queue if acks are pipelined
+	throttled time.Duration     // the time spent waiting for the receive rate limit
+	drops     uint64            // the number of times the server closed the subscription
+	conn      connStats
+	started   time.Time
+	duration  time.Duration
+	reason    string
+	progress  stats.Progress
+
+	// FillTimeout is how long to wait for the pre-filled events to be acked.
+	FillTimeout time.Duration
+
+	// IdleTimeout ends the drain if no events are received for this duration.
+	IdleTimeout time.Duration
+
+	// AckWorkers acks the received events on this many goroutines separate from the
+	// receive loop so that receiving and acking are pipelined; if zero, every event is
+	// acked by the receive loop before the next event is received.
+	AckWorkers int
+
+	// AckQueue is the capacity of the queue between the receive loop and the ack workers.
+	AckQueue int
+
+	// RecvRate caps the bandwidth of the subscriber in bytes per second to emulate a
+	// slow reader; if zero, events are received as fast as possible.
+	RecvRate float64
+}
+
+// The number of times the connection of the subscriber went down and came back up.
+type connStats struct {
+	disconnects uint64
+	reconnects  uint64
+}
+
+func New(opts *options.Options) *Consume {
+	return &Consume{opts: opts, FillTimeout: DefaultFillTimeout, IdleTimeout: DefaultIdleTimeout, AckQueue: DefaultAckQueue}
+}
+
+func (b *Consume) Run(ctx context.Context) (err error) {
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	// Subscriptions require topic IDs so resolve the topic name if necessary
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	// Subscribe before the fill so that the events are queued for the subscriber
+	var sub *ensign.Subscription
+	if sub, err = b.client.Subscribe(b.topicID.String()); err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	b.reason = benchmarks.ExitCompleted
+	if err = b.fill(ctx); err != nil {
+		return err
+	}
+
+	if len(b.published) == 0 {
+		return errors.New("no events were committed to the topic during the fill")
+	}
+
+	log.Info().
+		Str("topic", b.opts.Topic).
+		Str("topic_id", b.topicID.String()).
+		Int("events", len(b.published)).
+		Dur("fill", b.filling).
+		Msg("topic filled, draining subscription")
+
+	return b.drain(ctx, sub)
+}
+
+// Publishes the events to the topic and waits for the server to ack them.
+func (b *Consume) fill(ctx context.Context) (err error) {
+	N := b.opts.Operations
+	if b.opts.MaxEvents > 0 && b.opts.MaxEvents < N {
+		N = b.opts.MaxEvents
+		b.reason = benchmarks.ExitMaxEvents
+	}
+
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	inflight := make(map[string]*ensign.Event, N)
+	sequence := make(map[string]uint64, N)
+	b.published = make(map[string]uint64, N)
+	b.nacks = 0
+
+	started := time.Now()
+	defer func() {
+		b.filling = time.Since(started)
+	}()
+
+	volume := uint64(0)
+	for i := uint64(0); i < N; i++ {
+		if b.opts.MaxBytes > 0 && volume+uint64(b.opts.DataSize) > b.opts.MaxBytes {
+			b.reason = benchmarks.ExitMaxBytes
+			break
+		}
+
+		event := factory()
+		if err = b.client.Publish(b.topicID.String(), event); err != nil {
+			return err
+		}
+		inflight[event.Metadata[LocalIDKey]] = event
+		sequence[event.Metadata[LocalIDKey]] = i
+		volume += uint64(len(event.Data))
+	}
+
+	poll := time.NewTicker(ackPoll)
+	defer poll.Stop()
+	timeout := time.After(b.FillTimeout)
+
+	for len(inflight) > 0 {
+		select {
+		case <-poll.C:
+			for localID, event := range inflight {
+				if acked, _ := event.Acked(); acked {
+					b.published[localID] = sequence[localID]
+					delete(inflight, localID)
+					continue
+				}
+
+				if nacked, _ := event.Nacked(); nacked {
+					b.nacks++
+					delete(inflight, localID)
+				}
+			}
+		case <-timeout:
+			log.Warn().Int("unacked", len(inflight)).Msg("consume fill timeout exceeded")
+			return nil
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Reads events from the subscription as fast as possible, acking every event, until
+// all of the filled events have been received or no events arrive within the idle
+// timeout. The drain ends at the last received event so the idle wait is excluded; if
+// the acks are pipelined, it ends once the last received event has been acked.
+func (b *Consume) drain(ctx context.Context, sub *ensign.Subscription) (err error) {
+	b.received, b.foreign, b.bytes = 0, 0, 0
+	b.delivery = stats.Delivery{Published: uint64(len(b.published))}
+	b.acks = make([]time.Duration, 0, len(b.published))
+	b.queue = nil
+	b.throttled, b.drops = 0, 0
+	b.conn = connStats{}
+
+	var limiter *ratelimit.Limiter
+	if b.RecvRate > 0 {
+		limiter = ratelimit.New(b.RecvRate, 0)
+	}
+
+	var pool *ackPool
+	if b.AckWorkers > 0 {
+		pool = newAckPool(b.AckWorkers, b.AckQueue, len(b.published))
+		b.queue = pool.depth
+	}
+
+	seen := make(map[string]struct{}, len(b.published))
+	idle := time.NewTimer(b.IdleTimeout)
+	defer idle.Stop()
+
+	b.progress.Start()
+	b.progress.Set("events", uint64(len(b.published)))
+	b.started = time.Now()
+	last := b.started
+
+	// A slow reader may cause the server to drop the connection of the subscriber; mocked
+	// clients do not have a connection to watch.
+	watching, stop := context.WithCancel(ctx)
+	var watcher sync.WaitGroup
+	if b.opts.Mock == nil {
+		watcher.Add(1)
+		go func() {
+			defer watcher.Done()
+			b.watch(watching)
+		}()
+	}
+
+	defer func() {
+		stop()
+		watcher.Wait()
+	}()
+
+	// The drain ends once the workers have acked the events that were received
+	defer func() {
+		if pool != nil {
+			acks, acked := pool.wait()
+			b.acks = append(b.acks, acks...)
+			if acked.After(last) {
+				b.duration = acked.Sub(b.started)
+			}
+		}
+	}()
+
+	for len(seen) < len(b.published) {
+		if reason := b.opts.Exhausted(b.started, b.received); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				b.drops++
+				b.progress.Add("drops", 1)
+				log.Warn().Int("missing", len(b.published)-len(seen)).Msg("subscription closed by the server")
+				b.duration = last.Sub(b.started)
+				return nil
+			}
+
+			if limiter != nil {
+				wait, err := limiter.WaitN(ctx, len(event.Data))
+				if err != nil {
+					b.reason = benchmarks.ExitCanceled
+					return err
+				}
+				b.throttled += wait
+			}
+
+			if pool != nil {
+				pool.ack(event)
+				last = time.Now()
+			} else {
+				acking := time.Now()
+				if _, err := event.Ack(); err != nil {
+					log.Debug().Err(err).Msg("could not ack delivered event")
+				}
+				last = time.Now()
+				b.acks = append(b.acks, last.Sub(acking))
+			}
+
+			localID := event.Metadata[LocalIDKey]
+			sequence, ok := b.published[localID]
+			if !ok {
+				b.foreign++
+				continue
+			}
+
+			if _, ok := seen[localID]; ok {
+				b.delivery.Duplicates++
+			} else {
+				seen[localID] = struct{}{}
+				b.delivery.Deliver(sequence)
+				b.received++
+				b.bytes += uint64(len(event.Data))
+				b.progress.Add("received", 1)
+			}
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(b.IdleTimeout)
+		case <-idle.C:
+			log.Warn().Int("missing", len(b.published)-len(seen)).Msg("consume idle timeout exceeded")
+			b.duration = last.Sub(b.started)
+			return nil
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+
+	b.duration = last.Sub(b.started)
+	log.Info().Uint64("received", b.received).Dur("duration", b.duration).Msg("consume benchmark complete")
+	return nil
+}
+
+// Counts the number of times the connection of the client goes down and comes back up
+// until the context is done.
+func (b *Consume) watch(ctx context.Context) {
+	down := false
+	state := b.client.ConnState()
+	for b.client.WaitForConnStateChange(ctx, state) {
+		state = b.client.ConnState()
+		switch state {
+		case connectivity.TransientFailure, connectivity.Idle:
+			if !down {
+				down = true
+				atomic.AddUint64(&b.conn.disconnects, 1)
+				b.progress.Add("disconnects", 1)
+				log.Warn().Str("state", state.String()).Msg("subscriber disconnected")
+			}
+		case connectivity.Ready:
+			if down {
+				down = false
+				atomic.AddUint64(&b.conn.reconnects, 1)
+				b.progress.Add("reconnects", 1)
+				log.Info().Msg("subscriber reconnected")
+			}
+		}
+	}
+}
+
+// Progress returns the number of events received so far while draining the topic.
+func (b *Consume) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// AckLatencies returns the distribution of the time taken to ack each received event.
+func (b *Consume) AckLatencies() *stats.Latencies {
+	latencies := &stats.Latencies{}
+	latencies.Update(b.acks...)
+	latencies.SetDuration(b.duration)
+	return latencies
+}
+
+// Semantics returns the delivery semantics observed while draining the topic.
+func (b *Consume) Semantics() *stats.Delivery {
+	delivery := b.delivery
+	delivery.Foreign = b.foreign
+	return &delivery
+}
+
+// Received returns the number of filled events received by the subscriber.
+func (b *Consume) Received() uint64 {
+	return b.received
+}
+
+func (b *Consume) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["events"] = len(b.published)
+	results["nacks"] = b.nacks
+	results["received"] = b.received
+	results["missing"] = uint64(len(b.published)) - b.received
+	results["foreign"] = b.foreign
+	results["delivery_semantics"] = b.Semantics()
+	results["bytes"] = b.bytes
+	results["ack_latencies"] = b.AckLatencies()
+	results["disconnects"] = atomic.LoadUint64(&b.conn.disconnects)
+	results["reconnects"] = atomic.LoadUint64(&b.conn.reconnects)
+	results["drops"] = b.drops
+	if b.RecvRate > 0 {
+		results["throttled"] = b.throttled.String()
+	}
+	if b.queue != nil {
+		results["ack_queue"] = b.queue
+	}
+	results["fill_duration"] = b.filling.String()
+	results["duration"] = b.duration.String()
+	results["exit_reason"] = b.reason
+
+	if secs := b.duration.Seconds(); secs > 0 {
+		results["events_per_sec"] = float64(b.received) / secs
+	}
+
+	bandwidth := &stats.Bandwidth{}
+	bandwidth.Received(b.bytes)
+	bandwidth.SetDuration(b.duration)
+	results["bandwidth"] = bandwidth
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"fill_timeout":     b.FillTimeout.String(),
+		"idle_timeout":     b.IdleTimeout.String(),
+		"ack_workers":      b.AckWorkers,
+		"recv_rate":        b.RecvRate,
+	}
+	return results, nil
+}
@@ -0,0 +1,269 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/tunables"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Defaults for the agent's interval reports and clock offset estimation.
+const (
+	DefaultReportInterval = time.Second
+	DefaultClockSamples   = 8
+	DefaultIdleTimeout    = 5 * time.Second
+)
+
+// Agent runs the blast benchmark on a single host on behalf of a coordinator, or in a
+// scenario consumes the events published by the producer agents. The workload
+// configuration is received from the coordinator, but the connection to Ensign
+// (credentials, endpoints, or a local mock) is configured locally.
+type Agent struct {
+	addr   string
+	opts   *options.Options
+	conn   *grpc.ClientConn
+	worker string
+	role   string
+	offset ClockOffset
+
+	// Role requests a role from the coordinator; if empty the role is assigned.
+	Role string
+
+	// Interval is how often interval reports are sent while the benchmark runs.
+	Interval time.Duration
+
+	// Samples is the number of clock exchanges used to estimate the clock offset.
+	Samples int
+
+	// IdleTimeout ends a consumer if no events are received for this duration once the
+	// producers have completed, e.g. if some of the acked events are never delivered.
+	IdleTimeout time.Duration
+}
+
+// NewAgent creates an agent that connects to the coordinator at the address; the
+// options are used to connect to Ensign, the workload is set by the coordinator.
+func NewAgent(addr string, opts *options.Options) *Agent {
+	return &Agent{
+		addr:        addr,
+		opts:        opts,
+		Interval:    DefaultReportInterval,
+		Samples:     DefaultClockSamples,
+		IdleTimeout: DefaultIdleTimeout,
+	}
+}
+
+// Run registers the agent with the coordinator, waits at the start barrier, runs the
+// blast benchmark (or consumes the topic), and reports the results to the coordinator.
+func (a *Agent) Run(ctx context.Context) (err error) {
+	if a.conn, err = grpc.DialContext(ctx, a.addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	); err != nil {
+		return fmt.Errorf("could not connect to coordinator: %w", err)
+	}
+	defer a.conn.Close()
+
+	var conf *options.Options
+	if conf, err = a.register(ctx); err != nil {
+		return err
+	}
+
+	if a.offset, err = a.synchronize(ctx); err != nil {
+		return err
+	}
+	log.Info().Str("worker", a.worker).Dur("offset", a.offset.Offset).Dur("error", a.offset.Error).Msg("clock offset estimated")
+
+	var stream grpc.ClientStream
+	if stream, err = a.conn.NewStream(ctx, &serviceDesc.Streams[0], reportMethod); err != nil {
+		return fmt.Errorf("could not open report stream: %w", err)
+	}
+
+	var result *AgentResult
+	if a.role == RoleConsumer {
+		result = a.consume(ctx, conf)
+	} else {
+		result = a.produce(ctx, conf, stream)
+	}
+
+	if err = stream.SendMsg(&AgentReport{Worker: a.worker, Result: result}); err != nil {
+		return fmt.Errorf("could not send result to coordinator: %w", err)
+	}
+
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+
+	if err = stream.RecvMsg(&ReportReply{}); err != nil {
+		return fmt.Errorf("coordinator did not accept report: %w", err)
+	}
+
+	if result.Error != "" {
+		return fmt.Errorf("%s: %s", a.worker, result.Error)
+	}
+	return nil
+}
+
+// Runs the blast benchmark once the barrier is released, sending interval reports on
+// the stream while the benchmark runs.
+func (a *Agent) produce(ctx context.Context, conf *options.Options, stream grpc.ClientStream) *AgentResult {
+	var (
+		started time.Time
+		wg      sync.WaitGroup
+	)
+
+	bench := blast.New(conf)
+	stop := make(chan struct{})
+	bench.Barrier = func(ctx context.Context) (err error) {
+		rep := &ArriveReply{}
+		if err = a.conn.Invoke(ctx, arriveMethod, &ArriveRequest{Worker: a.worker, Offset: a.offset}, rep); err != nil {
+			return fmt.Errorf("could not arrive at start barrier: %w", err)
+		}
+
+		if started, err = WaitUntil(ctx, rep.StartAt, a.offset); err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.intervals(bench, stream, stop)
+		}()
+		return nil
+	}
+
+	result := &AgentResult{Role: RoleProducer}
+	if err := bench.Run(ctx); err != nil {
+		result.Error = err.Error()
+	}
+
+	close(stop)
+	wg.Wait()
+
+	// The measurement window started when the agent was released by the barrier
+	result.Started = started
+	if !started.IsZero() {
+		latencies := bench.Latencies()
+		result.Duration = latencies.Duration()
+		result.Latencies = latencies.State()
+		result.Events, result.Failures = bench.Counts()
+	}
+	return result
+}
+
+// Worker returns the name assigned to the agent by the coordinator.
+func (a *Agent) Worker() string {
+	return a.worker
+}
+
+// AssignedRole returns the role assigned to the agent by the coordinator.
+func (a *Agent) AssignedRole() string {
+	return a.role
+}
+
+// Registers with the coordinator and merges the local connection options into the
+// workload configuration received from the coordinator.
+func (a *Agent) register(ctx context.Context) (_ *options.Options, err error) {
+	req := &RegisterRequest{Role: a.Role, Tunables: tunables.Capture()}
+	if req.Hostname, err = os.Hostname(); err != nil {
+		log.Warn().Err(err).Msg("could not determine hostname")
+	}
+
+	rep := &RegisterReply{}
+	if err = a.conn.Invoke(ctx, registerMethod, req, rep); err != nil {
+		return nil, fmt.Errorf("could not register with coordinator: %w", err)
+	}
+
+	a.worker, a.role = rep.Worker, rep.Role
+	conf := rep.Options
+	if conf == nil {
+		conf = options.New()
+	}
+
+	conf.Credentials = a.opts.Credentials
+	conf.Mock = a.opts.Mock
+	if a.opts.Endpoint != "" {
+		conf.Endpoint = a.opts.Endpoint
+	}
+	if a.opts.AuthURL != "" {
+		conf.AuthURL = a.opts.AuthURL
+	}
+
+	log.Info().Str("worker", a.worker).Str("role", a.role).Str("addr", a.addr).Int64("seed", conf.Seed).Msg("registered with coordinator")
+	return conf, nil
+}
+
+// Estimates the offset of the coordinator's clock with multiple clock exchanges.
+func (a *Agent) synchronize(ctx context.Context) (_ ClockOffset, err error) {
+	samples := make([]ClockSample, 0, a.Samples)
+	for i := 0; i < a.Samples; i++ {
+		sample := ClockSample{Sent: time.Now()}
+		rep := &ClockReply{}
+		if err = a.conn.Invoke(ctx, clockMethod, &ClockRequest{Sent: sample.Sent}, rep); err != nil {
+			return ClockOffset{}, fmt.Errorf("could not exchange clock with coordinator: %w", err)
+		}
+
+		sample.Received = time.Now()
+		sample.RemoteRecv = rep.RemoteRecv
+		sample.RemoteSent = rep.RemoteSent
+		samples = append(samples, sample)
+	}
+	return EstimateOffset(samples...)
+}
+
+// Sends the number of events sent and acked during each interval until stopped along
+// with the histogram of the latencies observed during the interval, the difference of
+// the blast histogram since the previous report, so that the coordinator can merge the
+// histograms of all workers into the cluster p99. The final result is only sent after
+// this routine returns so the stream has a single sender.
+func (a *Agent) intervals(bench *blast.Blast, stream grpc.ClientStream, stop <-chan struct{}) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	var (
+		sequence    uint64
+		sent, acked uint64
+		observed    = &stats.Percentiles{}
+	)
+
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			progress := bench.Progress()
+			nsent, _ := progress["sent"].(uint64)
+			nacked, _ := progress["acks"].(uint64)
+
+			hist := bench.Histogram()
+			delta := hist.Sub(observed)
+
+			report := &IntervalReport{
+				Worker:    a.worker,
+				Sequence:  sequence,
+				Duration:  now.Sub(last),
+				Offered:   nsent - sent,
+				Acked:     nacked - acked,
+				P99:       delta.Percentile(99),
+				Histogram: delta,
+			}
+
+			if err := stream.SendMsg(&AgentReport{Worker: a.worker, Interval: report}); err != nil {
+				log.Warn().Err(err).Str("worker", a.worker).Msg("could not send interval report")
+				return
+			}
+
+			sequence++
+			sent, acked, last, observed = nsent, nacked, now, hist
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+)
+
+// IntervalReport is periodically sent by each worker to the coordinator while the
+// benchmark is running and summarizes the load generated during a single interval.
+type IntervalReport struct {
+	Worker   string        `json:"worker"`
+	Sequence uint64        `json:"sequence"` // the index of the interval since the start barrier
+	Duration time.Duration `json:"duration"` // the length of the interval
+	Offered  uint64        `json:"offered"`  // the number of events sent during the interval
+	Acked    uint64        `json:"acked"`    // the number of events acked during the interval
+	P99      time.Duration `json:"p99"`      // the worker's p99 ack latency during the interval
+
+	// The histogram of the ack latencies observed during the interval, if available.
+	Histogram *stats.Percentiles `json:"histogram,omitempty"`
+}
+
+// ClusterInterval is the cluster-wide aggregation of all worker reports for a single
+// interval. Percentiles cannot be combined exactly from per-worker summaries, so if
+// every worker reported the histogram of the interval the histograms are merged and the
+// cluster p99 is estimated from the merged histogram. Otherwise the cluster p99 is the
+// worst p99 reported by any worker, an upper bound of the true p99.
+type ClusterInterval struct {
+	Sequence     uint64        `json:"sequence"`
+	Workers      int           `json:"workers"`
+	OfferedRate  float64       `json:"offered_rate"`
+	AchievedRate float64       `json:"achieved_rate"`
+	P99          time.Duration `json:"p99"`
+	Merged       bool          `json:"merged"` // true if the p99 was estimated from the merged histograms
+}
+
+// Aggregator collects interval reports from workers so the coordinator can display
+// cluster-wide offered load, achieved throughput, and tail latency in real time.
+type Aggregator struct {
+	sync.RWMutex
+	intervals map[uint64]map[string]IntervalReport
+	latest    uint64
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{intervals: make(map[uint64]map[string]IntervalReport)}
+}
+
+// Add an interval report from a worker; a later report for the same worker and
+// sequence replaces the earlier report.
+func (a *Aggregator) Add(report IntervalReport) {
+	a.Lock()
+	defer a.Unlock()
+
+	interval, ok := a.intervals[report.Sequence]
+	if !ok {
+		interval = make(map[string]IntervalReport)
+		a.intervals[report.Sequence] = interval
+	}
+
+	interval[report.Worker] = report
+	if report.Sequence > a.latest {
+		a.latest = report.Sequence
+	}
+}
+
+// Interval returns the cluster-wide aggregation for the specified sequence.
+func (a *Aggregator) Interval(sequence uint64) ClusterInterval {
+	a.RLock()
+	defer a.RUnlock()
+	return a.interval(sequence)
+}
+
+func (a *Aggregator) interval(sequence uint64) ClusterInterval {
+	agg := ClusterInterval{Sequence: sequence}
+	merged, exact := &stats.Percentiles{}, true
+	for _, report := range a.intervals[sequence] {
+		agg.Workers++
+		if secs := report.Duration.Seconds(); secs > 0 {
+			agg.OfferedRate += float64(report.Offered) / secs
+			agg.AchievedRate += float64(report.Acked) / secs
+		}
+
+		if report.P99 > agg.P99 {
+			agg.P99 = report.P99
+		}
+
+		if report.Histogram != nil {
+			merged.Append(report.Histogram)
+		} else {
+			exact = false
+		}
+	}
+
+	if exact && merged.N() > 0 {
+		agg.P99, agg.Merged = merged.Percentile(99), true
+	}
+	return agg
+}
+
+// Latest returns the aggregation of the most recent interval reported by any worker.
+func (a *Aggregator) Latest() ClusterInterval {
+	a.RLock()
+	defer a.RUnlock()
+	return a.interval(a.latest)
+}
+
+// Intervals returns the aggregation of all intervals reported so far in order.
+func (a *Aggregator) Intervals() []ClusterInterval {
+	a.RLock()
+	defer a.RUnlock()
+
+	sequences := make([]uint64, 0, len(a.intervals))
+	for seq := range a.intervals {
+		sequences = append(sequences, seq)
+	}
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+	intervals := make([]ClusterInterval, 0, len(sequences))
+	for _, seq := range sequences {
+		intervals = append(intervals, a.interval(seq))
+	}
+	return intervals
+}
+
+// Display writes a line with the latest cluster-wide aggregation to the writer at the
+// specified frequency until the context is canceled.
+func (a *Aggregator) Display(ctx context.Context, w io.Writer, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	fmt.Fprintf(w, "%-10s %-8s %-14s %-14s %-12s\n", "interval", "workers", "offered (e/s)", "achieved (e/s)", "p99")
+	for {
+		select {
+		case <-ticker.C:
+			latest := a.Latest()
+			if latest.Workers == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "%-10d %-8d %-14.1f %-14.1f %-12s\n", latest.Sequence, latest.Workers, latest.OfferedRate, latest.AchievedRate, latest.P99)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
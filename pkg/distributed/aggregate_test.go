@@ -0,0 +1,66 @@
+package distributed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregator(t *testing.T) {
+	agg := distributed.NewAggregator()
+	require.Equal(t, 0, agg.Latest().Workers)
+
+	agg.Add(distributed.IntervalReport{Worker: "alpha", Sequence: 1, Duration: time.Second, Offered: 100, Acked: 90, P99: 12 * time.Millisecond})
+	agg.Add(distributed.IntervalReport{Worker: "bravo", Sequence: 1, Duration: 2 * time.Second, Offered: 400, Acked: 300, P99: 18 * time.Millisecond})
+	agg.Add(distributed.IntervalReport{Worker: "alpha", Sequence: 2, Duration: time.Second, Offered: 100, Acked: 100, P99: 9 * time.Millisecond})
+
+	interval := agg.Interval(1)
+	require.Equal(t, 2, interval.Workers)
+	require.Equal(t, 300.0, interval.OfferedRate)
+	require.Equal(t, 240.0, interval.AchievedRate)
+	require.Equal(t, 18*time.Millisecond, interval.P99)
+
+	latest := agg.Latest()
+	require.Equal(t, uint64(2), latest.Sequence)
+	require.Equal(t, 1, latest.Workers)
+
+	// A duplicate report replaces the original
+	agg.Add(distributed.IntervalReport{Worker: "alpha", Sequence: 2, Duration: time.Second, Offered: 200, Acked: 200, P99: 9 * time.Millisecond})
+	require.Equal(t, 200.0, agg.Latest().OfferedRate)
+
+	intervals := agg.Intervals()
+	require.Len(t, intervals, 2)
+	require.Equal(t, uint64(1), intervals[0].Sequence)
+	require.Equal(t, uint64(2), intervals[1].Sequence)
+}
+
+func TestAggregatorMerged(t *testing.T) {
+	agg := distributed.NewAggregator()
+	alpha, bravo, all := &stats.Percentiles{}, &stats.Percentiles{}, &stats.Percentiles{}
+	for i := 1; i <= 990; i++ {
+		alpha.Update(time.Millisecond)
+		all.Update(time.Millisecond)
+	}
+	for i := 1; i <= 10; i++ {
+		bravo.Update(100 * time.Millisecond)
+		all.Update(100 * time.Millisecond)
+	}
+
+	agg.Add(distributed.IntervalReport{Worker: "alpha", Sequence: 1, Duration: time.Second, Acked: 990, P99: alpha.Percentile(99), Histogram: alpha})
+	agg.Add(distributed.IntervalReport{Worker: "bravo", Sequence: 1, Duration: time.Second, Acked: 10, P99: bravo.Percentile(99), Histogram: bravo})
+
+	// The cluster p99 is computed from the merged histograms rather than the worst p99
+	interval := agg.Interval(1)
+	require.True(t, interval.Merged)
+	require.Equal(t, all.Percentile(99), interval.P99)
+	require.Less(t, interval.P99, bravo.Percentile(99))
+
+	// A worker without a histogram falls back to the worst p99
+	agg.Add(distributed.IntervalReport{Worker: "charlie", Sequence: 1, Duration: time.Second, P99: 50 * time.Millisecond})
+	interval = agg.Interval(1)
+	require.False(t, interval.Merged)
+	require.Equal(t, bravo.Percentile(99), interval.P99)
+}
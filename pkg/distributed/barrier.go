@@ -0,0 +1,146 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultLead is how far in the future the start of the measurement window is
+// scheduled once all workers have arrived at the barrier, allowing the start time to
+// propagate to every worker before the window opens.
+const DefaultLead = 2 * time.Second
+
+var (
+	ErrBarrierClosed  = errors.New("start barrier has already been released")
+	ErrUnknownWorker  = errors.New("worker has not arrived at the start barrier")
+	ErrBarrierPending = errors.New("start barrier has not been released")
+)
+
+// Barrier is used by the coordinator to start the measurement window of all workers at
+// the same time. Each worker arrives with its estimated clock offset; once the
+// expected number of workers have arrived the barrier is released with a start time
+// (in coordinator time) far enough in the future for all workers to receive it. Workers
+// report when they actually started so that the worst-case start skew can be reported.
+type Barrier struct {
+	sync.Mutex
+	expected int
+	lead     time.Duration
+	offsets  map[string]ClockOffset
+	started  map[string]time.Time
+	startAt  time.Time
+	released chan struct{}
+}
+
+// NewBarrier creates a start barrier that is released when the expected number of
+// workers have arrived, scheduling the start lead time after the last arrival.
+func NewBarrier(workers int, lead time.Duration) *Barrier {
+	if lead <= 0 {
+		lead = DefaultLead
+	}
+
+	return &Barrier{
+		expected: workers,
+		lead:     lead,
+		offsets:  make(map[string]ClockOffset, workers),
+		started:  make(map[string]time.Time, workers),
+		released: make(chan struct{}),
+	}
+}
+
+// Arrive registers a worker at the barrier along with its clock offset. When the last
+// expected worker arrives the barrier is released.
+func (b *Barrier) Arrive(worker string, offset ClockOffset) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.startAt.IsZero() {
+		return ErrBarrierClosed
+	}
+
+	b.offsets[worker] = offset
+	if len(b.offsets) >= b.expected {
+		b.startAt = time.Now().Add(b.lead)
+		close(b.released)
+	}
+	return nil
+}
+
+// Wait blocks until the barrier is released and returns the start time of the
+// measurement window in coordinator time.
+func (b *Barrier) Wait(ctx context.Context) (time.Time, error) {
+	select {
+	case <-b.released:
+		b.Lock()
+		defer b.Unlock()
+		return b.startAt, nil
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+}
+
+// Started records the time (in coordinator time) that a worker opened its window.
+func (b *Barrier) Started(worker string, at time.Time) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.offsets[worker]; !ok {
+		return ErrUnknownWorker
+	}
+	b.started[worker] = at
+	return nil
+}
+
+// Offset returns the clock offset that the worker arrived at the barrier with.
+func (b *Barrier) Offset(worker string) (offset ClockOffset, ok bool) {
+	b.Lock()
+	defer b.Unlock()
+	offset, ok = b.offsets[worker]
+	return offset, ok
+}
+
+// Skew returns the worst-case start skew of all workers that reported their start
+// time: the spread between the earliest and latest start plus the largest clock offset
+// error bound, since each reported start is only as accurate as its offset estimate.
+func (b *Barrier) Skew() (time.Duration, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.startAt.IsZero() {
+		return 0, ErrBarrierPending
+	}
+
+	var (
+		first, last time.Time
+		maxErr      time.Duration
+	)
+
+	for worker, at := range b.started {
+		if first.IsZero() || at.Before(first) {
+			first = at
+		}
+		if last.IsZero() || at.After(last) {
+			last = at
+		}
+		if err := b.offsets[worker].Error; err > maxErr {
+			maxErr = err
+		}
+	}
+
+	return last.Sub(first) + 2*maxErr, nil
+}
+
+// WaitUntil is called by a worker to sleep until the coordinator start time converted
+// to the worker's clock, returning the actual start time in coordinator time.
+func WaitUntil(ctx context.Context, startAt time.Time, offset ClockOffset) (time.Time, error) {
+	timer := time.NewTimer(time.Until(offset.ToLocal(startAt)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return offset.ToRemote(time.Now()), nil
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Package distributed implements the primitives required to run a benchmark across
+multiple worker processes on different hosts. Because latency measurements from
+different hosts are only comparable if all workers measure the same window, workers
+estimate the offset of their clock from the coordinator's clock and wait at a start
+barrier so that the measurement window begins within a small, measured skew.
+*/
+package distributed
+
+import (
+	"errors"
+	"time"
+)
+
+// ClockSample is a single round trip clock exchange between a worker and the
+// coordinator using the NTP convention: Sent and Received are measured with the
+// worker's clock while RemoteRecv and RemoteSent are measured with the coordinator's.
+type ClockSample struct {
+	Sent       time.Time `json:"sent"`        // worker time the request was sent (t0)
+	RemoteRecv time.Time `json:"remote_recv"` // coordinator time the request was received (t1)
+	RemoteSent time.Time `json:"remote_sent"` // coordinator time the reply was sent (t2)
+	Received   time.Time `json:"received"`    // worker time the reply was received (t3)
+}
+
+// Offset returns the estimated offset of the coordinator clock from the worker clock,
+// e.g. coordinator time = worker time + offset.
+func (s ClockSample) Offset() time.Duration {
+	return (s.RemoteRecv.Sub(s.Sent) + s.RemoteSent.Sub(s.Received)) / 2
+}
+
+// Delay returns the round trip network delay, excluding coordinator processing time.
+func (s ClockSample) Delay() time.Duration {
+	return s.Received.Sub(s.Sent) - s.RemoteSent.Sub(s.RemoteRecv)
+}
+
+// ClockOffset is the estimated offset of a worker's clock from the coordinator clock
+// along with the error bound of the estimate (half of the round trip delay).
+type ClockOffset struct {
+	Offset time.Duration `json:"offset"`
+	Error  time.Duration `json:"error"`
+}
+
+// ToRemote converts a timestamp from the worker's clock to the coordinator's clock.
+func (o ClockOffset) ToRemote(t time.Time) time.Time {
+	return t.Add(o.Offset)
+}
+
+// ToLocal converts a timestamp from the coordinator's clock to the worker's clock.
+func (o ClockOffset) ToLocal(t time.Time) time.Time {
+	return t.Add(-o.Offset)
+}
+
+// ErrNoSamples is returned when an offset is estimated without any clock samples.
+var ErrNoSamples = errors.New("at least one clock sample is required to estimate offset")
+
+// EstimateOffset computes the clock offset from multiple clock exchanges. The sample
+// with the minimum round trip delay is the least affected by queueing and asymmetric
+// network paths, so its offset is used and half of its delay is the error bound.
+func EstimateOffset(samples ...ClockSample) (_ ClockOffset, err error) {
+	if len(samples) == 0 {
+		return ClockOffset{}, ErrNoSamples
+	}
+
+	best := samples[0]
+	for _, sample := range samples[1:] {
+		if sample.Delay() < best.Delay() {
+			best = sample
+		}
+	}
+
+	delay := best.Delay()
+	if delay < 0 {
+		delay = 0
+	}
+	return ClockOffset{Offset: best.Offset(), Error: delay / 2}, nil
+}
@@ -0,0 +1,80 @@
+package distributed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateOffset(t *testing.T) {
+	_, err := distributed.EstimateOffset()
+	require.ErrorIs(t, err, distributed.ErrNoSamples)
+
+	// The coordinator clock is 250ms ahead of the worker clock
+	ahead := 250 * time.Millisecond
+	origin := time.Date(2023, 10, 14, 12, 0, 0, 0, time.UTC)
+	mksample := func(start time.Duration, out, proc, back time.Duration) distributed.ClockSample {
+		sent := origin.Add(start)
+		return distributed.ClockSample{
+			Sent:       sent,
+			RemoteRecv: sent.Add(out + ahead),
+			RemoteSent: sent.Add(out + proc + ahead),
+			Received:   sent.Add(out + proc + back),
+		}
+	}
+
+	symmetric := mksample(0, 10*time.Millisecond, time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, ahead, symmetric.Offset())
+	require.Equal(t, 20*time.Millisecond, symmetric.Delay())
+
+	// Asymmetric, slower samples should be ignored in favor of the fastest sample
+	offset, err := distributed.EstimateOffset(
+		mksample(time.Second, 80*time.Millisecond, time.Millisecond, 10*time.Millisecond),
+		symmetric,
+		mksample(2*time.Second, 30*time.Millisecond, 5*time.Millisecond, 60*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.Equal(t, ahead, offset.Offset)
+	require.Equal(t, 10*time.Millisecond, offset.Error)
+
+	local := origin.Add(time.Hour)
+	require.Equal(t, local.Add(ahead), offset.ToRemote(local))
+	require.Equal(t, local, offset.ToLocal(offset.ToRemote(local)))
+}
+
+func TestBarrier(t *testing.T) {
+	barrier := distributed.NewBarrier(2, 50*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := barrier.Skew()
+	require.ErrorIs(t, err, distributed.ErrBarrierPending)
+	require.ErrorIs(t, barrier.Started("alpha", time.Now()), distributed.ErrUnknownWorker)
+
+	offsets := map[string]distributed.ClockOffset{
+		"alpha": {Offset: 0, Error: time.Millisecond},
+		"bravo": {Offset: 0, Error: 2 * time.Millisecond},
+	}
+
+	require.NoError(t, barrier.Arrive("alpha", offsets["alpha"]))
+	require.NoError(t, barrier.Arrive("bravo", offsets["bravo"]))
+	require.ErrorIs(t, barrier.Arrive("charlie", distributed.ClockOffset{}), distributed.ErrBarrierClosed)
+
+	startAt, err := barrier.Wait(ctx)
+	require.NoError(t, err)
+
+	for worker, offset := range offsets {
+		started, err := distributed.WaitUntil(ctx, startAt, offset)
+		require.NoError(t, err)
+		require.False(t, started.Before(startAt), "worker started before the barrier start time")
+		require.NoError(t, barrier.Started(worker, started))
+	}
+
+	skew, err := barrier.Skew()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, skew, 4*time.Millisecond)
+	require.Less(t, skew, 50*time.Millisecond)
+}
@@ -0,0 +1,173 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// The metadata key of the blast event counter that attributes events to producers.
+const counterKey = "counter"
+
+// A delivery of an event observed by a consumer; the latency is measured from the
+// created timestamp of the event, which is in the clock of the producer, to the time
+// the event was received converted to coordinator time, so it must be corrected by the
+// clock offset of the producer.
+type delivery struct {
+	counter uint64
+	latency time.Duration
+}
+
+// Subscribes to the topic before the barrier is released and receives events until all
+// of the events acked by the producers have been delivered, or no events are received
+// within the idle timeout once the producers have completed.
+func (a *Agent) consume(ctx context.Context, conf *options.Options) *AgentResult {
+	result := &AgentResult{Role: RoleConsumer}
+
+	deliveries, producers, err := a.subscribe(ctx, conf, result)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	// Correct the latency of each delivery by the clock offset of its producer; events
+	// that cannot be attributed to a producer are assumed to be created in coordinator
+	// time, e.g. if the producers do not add counters to the event metadata.
+	latencies := &stats.Latencies{}
+	for _, d := range deliveries {
+		latency := d.latency
+		if producer, ok := attribute(producers, d.counter); ok {
+			latency -= producer.Offset.Offset
+		} else {
+			result.Uncorrelated++
+		}
+
+		// Clock offset errors must not turn a delivery into a timeout
+		if latency <= 0 {
+			latency = time.Nanosecond
+		}
+		latencies.Update(latency)
+	}
+
+	latencies.SetDuration(result.Duration)
+	result.Latencies = latencies.State()
+	result.Events = uint64(len(deliveries))
+	return result
+}
+
+func (a *Agent) subscribe(ctx context.Context, conf *options.Options, result *AgentResult) (deliveries []delivery, producers *DrainReply, err error) {
+	var client *ensign.Client
+	if client, err = ensign.New(conf.Ensign()...); err != nil {
+		return nil, nil, err
+	}
+	defer client.Close()
+
+	// Subscriptions require topic IDs so resolve the topic name if necessary
+	topicID := conf.TopicID
+	if topicID == "" {
+		if topicID, err = client.TopicID(ctx, conf.Topic); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var sub *ensign.Subscription
+	if sub, err = client.Subscribe(topicID); err != nil {
+		return nil, nil, err
+	}
+	defer sub.Close()
+
+	rep := &ArriveReply{}
+	if err = a.conn.Invoke(ctx, arriveMethod, &ArriveRequest{Worker: a.worker, Offset: a.offset}, rep); err != nil {
+		return nil, nil, fmt.Errorf("could not arrive at start barrier: %w", err)
+	}
+
+	if result.Started, err = WaitUntil(ctx, rep.StartAt, a.offset); err != nil {
+		return nil, nil, err
+	}
+
+	started := time.Now()
+	last := started
+	defer func() {
+		result.Duration = last.Sub(started)
+	}()
+
+	// Wait for the producers to complete while receiving events
+	drained := make(chan *DrainReply, 1)
+	failed := make(chan error, 1)
+	go func() {
+		rep := &DrainReply{}
+		if err := a.conn.Invoke(ctx, drainMethod, &DrainRequest{Worker: a.worker}, rep); err != nil {
+			failed <- fmt.Errorf("could not wait for producers to complete: %w", err)
+			return
+		}
+		drained <- rep
+	}()
+
+	deliveries = make([]delivery, 0, conf.Operations)
+	seen := make(map[string]struct{}, conf.Operations)
+
+	// The idle timeout only starts once the producers have completed
+	idle := time.NewTimer(a.IdleTimeout)
+	idle.Stop()
+	defer idle.Stop()
+
+	log.Info().Str("worker", a.worker).Str("topic", conf.Topic).Msg("consumer started")
+	for producers == nil || uint64(len(seen)) < producers.Published() {
+		select {
+		case event := <-sub.C:
+			received := time.Now()
+			if _, err := event.Ack(); err != nil {
+				log.Debug().Err(err).Msg("could not ack delivered event")
+			}
+
+			if _, ok := seen[event.ID()]; ok {
+				result.Duplicates++
+				continue
+			}
+			seen[event.ID()] = struct{}{}
+
+			d := delivery{latency: a.offset.ToRemote(received).Sub(event.Created)}
+			d.counter, _ = strconv.ParseUint(event.Metadata[counterKey], 16, 64)
+			deliveries = append(deliveries, d)
+			last = received
+
+			if producers != nil {
+				idle.Reset(a.IdleTimeout)
+			}
+		case producers = <-drained:
+			idle.Reset(a.IdleTimeout)
+		case err = <-failed:
+			return deliveries, nil, err
+		case <-idle.C:
+			log.Warn().
+				Str("worker", a.worker).
+				Uint64("missing", producers.Published()-uint64(len(seen))).
+				Msg("consumer idle timeout exceeded")
+			return deliveries, producers, nil
+		case <-ctx.Done():
+			return deliveries, producers, ctx.Err()
+		}
+	}
+
+	log.Info().Str("worker", a.worker).Int("received", len(seen)).Uint64("duplicates", result.Duplicates).Msg("consumer complete")
+	return deliveries, producers, nil
+}
+
+// Returns the producer whose counter range contains the counter.
+func attribute(producers *DrainReply, counter uint64) (_ ProducerSummary, ok bool) {
+	if producers == nil || counter == 0 {
+		return ProducerSummary{}, false
+	}
+
+	for _, producer := range producers.Producers {
+		if counter > producer.CounterOffset && counter <= producer.CounterOffset+producer.Operations {
+			return producer, true
+		}
+	}
+	return ProducerSummary{}, false
+}
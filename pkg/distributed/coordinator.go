@@ -0,0 +1,574 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/tunables"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDisplayInterval is how often the live cluster-wide aggregation is displayed.
+const DefaultDisplayInterval = time.Second
+
+// Coordinator orchestrates a blast run across multiple agents. Agents register with the
+// coordinator to receive the workload configuration, estimate their clock offset, and
+// wait at the start barrier so that every agent opens its measurement window at the
+// same time. While the agents run, their interval reports are aggregated into a live
+// cluster-wide view; when they complete, their latencies are merged into a single
+// distribution with Append.
+//
+// In a scenario some of the agents are consumers rather than producers: consumers
+// subscribe to the topic before the barrier is released and receive the events of all
+// of the producers until the producers have completed and every acked event has been
+// delivered, so that the results combine the publish latencies of the producers with
+// the end-to-end delivery latencies measured by the consumers.
+type Coordinator struct {
+	sync.Mutex
+	opts       *options.Options
+	consumer   *options.Options // the configuration of the consumers of a scenario
+	expected   int
+	producers  int
+	consumers  int
+	roles      map[string]string // worker names to the role of the agent
+	drained    chan struct{}     // closed when all of the producers have completed
+	barrier    *Barrier
+	aggregator *Aggregator
+	workers    map[string]string // worker names to the hostname of the agent
+	tunables   map[string]*tunables.Tunables
+	configs    map[string]*options.Options
+	results    map[string]*AgentResult
+	done       chan struct{}
+	srv        *grpc.Server
+
+	// Display is where the live cluster-wide aggregation is written while the agents
+	// run (stderr by default); if nil the live aggregation is not displayed.
+	Display io.Writer
+}
+
+// NewCoordinator creates a coordinator that distributes the workload in the options to
+// the specified number of agents. Each agent publishes the full number of operations.
+func NewCoordinator(opts *options.Options, agents int, lead time.Duration) *Coordinator {
+	return NewScenario(opts, nil, agents, 0, lead)
+}
+
+// NewScenario creates a coordinator of a run with the specified number of producers,
+// which publish the workload in the producer options, and consumers, which subscribe
+// to the topic in the consumer options (the producer options if nil). All of the agents
+// are started together at the barrier.
+func NewScenario(producer, consumer *options.Options, producers, consumers int, lead time.Duration) *Coordinator {
+	if consumer == nil {
+		consumer = producer
+	}
+
+	agents := producers + consumers
+	c := &Coordinator{
+		opts:       producer,
+		consumer:   consumer,
+		expected:   agents,
+		producers:  producers,
+		consumers:  consumers,
+		roles:      make(map[string]string, agents),
+		drained:    make(chan struct{}),
+		barrier:    NewBarrier(agents, lead),
+		aggregator: NewAggregator(),
+		workers:    make(map[string]string, agents),
+		tunables:   make(map[string]*tunables.Tunables, agents),
+		configs:    make(map[string]*options.Options, agents),
+		results:    make(map[string]*AgentResult, agents),
+		done:       make(chan struct{}),
+		Display:    os.Stderr,
+	}
+
+	if producers == 0 {
+		close(c.drained)
+	}
+	return c
+}
+
+// Serve the control API on the listener until Stop is called.
+func (c *Coordinator) Serve(lis net.Listener) error {
+	c.Lock()
+	c.srv = grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	c.srv.RegisterService(&serviceDesc, c)
+	srv := c.srv
+	c.Unlock()
+
+	log.Info().Str("addr", lis.Addr().String()).Int("agents", c.expected).Msg("coordinator waiting for agents")
+	return srv.Serve(lis)
+}
+
+// Stop the control API server.
+func (c *Coordinator) Stop() {
+	c.Lock()
+	defer c.Unlock()
+	if c.srv != nil {
+		c.srv.Stop()
+	}
+}
+
+// Wait blocks until every agent has reported its result, displaying the live
+// cluster-wide aggregation once the agents have started.
+func (c *Coordinator) Wait(ctx context.Context) error {
+	if c.Display != nil {
+		go func() {
+			if _, err := c.barrier.Wait(ctx); err != nil {
+				return
+			}
+
+			display, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() {
+				<-c.done
+				cancel()
+			}()
+			c.aggregator.Display(display, c.Display, DefaultDisplayInterval)
+		}()
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Progress returns the number of agents that have registered and completed so far along
+// with the latest cluster-wide interval reported by the agents.
+func (c *Coordinator) Progress() map[string]interface{} {
+	c.Lock()
+	defer c.Unlock()
+	return map[string]interface{}{
+		"expected":   c.expected,
+		"registered": len(c.workers),
+		"completed":  len(c.results),
+		"interval":   c.aggregator.Latest(),
+	}
+}
+
+// Aggregator returns the aggregation of the interval reports sent by the agents.
+func (c *Coordinator) Aggregator() *Aggregator {
+	return c.aggregator
+}
+
+// Register assigns the agent a worker name and returns the workload configuration of
+// the worker. Each worker is assigned a distinct seed derived from the run's seed and
+// a disjoint range of event counters so that the merged workload has no collisions.
+// Agents that do not request a role are assigned the producer role until all of the
+// expected producers have registered.
+func (c *Coordinator) Register(ctx context.Context, in *RegisterRequest) (*RegisterReply, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.workers) >= c.expected {
+		return nil, status.Error(codes.ResourceExhausted, "all expected agents have already registered")
+	}
+
+	role := in.Role
+	producers, consumers := c.count(RoleProducer), c.count(RoleConsumer)
+	switch role {
+	case "":
+		role = RoleProducer
+		if producers >= c.producers {
+			role = RoleConsumer
+		}
+	case RoleProducer:
+		if producers >= c.producers {
+			return nil, status.Error(codes.ResourceExhausted, "all expected producers have already registered")
+		}
+	case RoleConsumer:
+		if consumers >= c.consumers {
+			return nil, status.Error(codes.ResourceExhausted, "all expected consumers have already registered")
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", role)
+	}
+
+	var (
+		worker string
+		conf   options.Options
+	)
+
+	if role == RoleProducer {
+		worker = fmt.Sprintf("agent-%d", producers)
+		if c.scenario() {
+			worker = fmt.Sprintf("%s-%d", RoleProducer, producers)
+		}
+
+		conf = *c.opts
+		conf.Seed = DeriveSeed(c.opts.Seed, producers)
+		conf.CounterOffset = c.opts.CounterOffset + uint64(producers)*c.opts.Operations
+	} else {
+		worker = fmt.Sprintf("%s-%d", RoleConsumer, consumers)
+		conf = *c.consumer
+	}
+
+	c.workers[worker] = in.Hostname
+	c.tunables[worker] = in.Tunables
+	c.roles[worker] = role
+	c.configs[worker] = &conf
+	log.Info().Str("worker", worker).Str("role", role).Str("hostname", in.Hostname).Int64("seed", conf.Seed).Msg("agent registered")
+	return &RegisterReply{Worker: worker, Role: role, Options: &conf}, nil
+}
+
+// Returns the number of registered agents with the role. Must be called with the lock.
+func (c *Coordinator) count(role string) (n int) {
+	for _, r := range c.roles {
+		if r == role {
+			n++
+		}
+	}
+	return n
+}
+
+// Returns true if the run has consumers as well as producers.
+func (c *Coordinator) scenario() bool {
+	return c.consumers > 0
+}
+
+// Seeds returns the seed assigned to each registered worker.
+func (c *Coordinator) Seeds() map[string]int64 {
+	c.Lock()
+	defer c.Unlock()
+
+	seeds := make(map[string]int64, len(c.configs))
+	for worker, conf := range c.configs {
+		seeds[worker] = conf.Seed
+	}
+	return seeds
+}
+
+// DeriveSeed returns the seed of the worker at the specified index from the seed of the
+// run by mixing the two with the splitmix64 finalizer, so that workers with adjacent
+// indices have uncorrelated random sequences. A zero seed means the run is unseeded so
+// the workers are unseeded as well.
+func DeriveSeed(seed int64, index int) int64 {
+	if seed == 0 {
+		return 0
+	}
+
+	z := uint64(seed) + uint64(index+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+
+	// Zero is reserved for unseeded runs
+	if z == 0 {
+		z = 1
+	}
+	return int64(z)
+}
+
+// Clock replies to an NTP-style clock exchange with the coordinator's time.
+func (c *Coordinator) Clock(ctx context.Context, in *ClockRequest) (*ClockReply, error) {
+	rep := &ClockReply{RemoteRecv: time.Now()}
+	rep.RemoteSent = time.Now()
+	return rep, nil
+}
+
+// Arrive registers the agent at the start barrier and blocks until it is released.
+func (c *Coordinator) Arrive(ctx context.Context, in *ArriveRequest) (_ *ArriveReply, err error) {
+	if !c.registered(in.Worker) {
+		return nil, status.Error(codes.NotFound, "unknown worker")
+	}
+
+	if err = c.barrier.Arrive(in.Worker, in.Offset); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	rep := &ArriveReply{}
+	if rep.StartAt, err = c.barrier.Wait(ctx); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return rep, nil
+}
+
+// Drain blocks until all of the producers have completed and replies with a summary of
+// the events published by each producer so that the consumer knows how many events to
+// expect and can attribute every event it receives to the producer that published it.
+func (c *Coordinator) Drain(ctx context.Context, in *DrainRequest) (_ *DrainReply, err error) {
+	if !c.registered(in.Worker) {
+		return nil, status.Error(codes.NotFound, "unknown worker")
+	}
+
+	select {
+	case <-c.drained:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	rep := &DrainReply{Producers: make([]ProducerSummary, 0, c.producers)}
+	for _, worker := range c.sorted(RoleProducer) {
+		conf := c.configs[worker]
+		summary := ProducerSummary{
+			Worker:        worker,
+			CounterOffset: conf.CounterOffset,
+			Operations:    conf.Operations,
+			Events:        c.results[worker].Events,
+		}
+		summary.Offset, _ = c.barrier.Offset(worker)
+		rep.Producers = append(rep.Producers, summary)
+	}
+	return rep, nil
+}
+
+// Returns the sorted names of the workers with the role that have reported results.
+// Must be called with the lock held.
+func (c *Coordinator) sorted(role string) []string {
+	workers := make([]string, 0, len(c.results))
+	for worker := range c.results {
+		if c.roles[worker] == role {
+			workers = append(workers, worker)
+		}
+	}
+	sort.Strings(workers)
+	return workers
+}
+
+// Report receives the interval reports and the final result of an agent.
+func (c *Coordinator) Report(stream grpc.ServerStream) error {
+	for {
+		in := &AgentReport{}
+		if err := stream.RecvMsg(in); err != nil {
+			if errors.Is(err, io.EOF) {
+				return stream.SendMsg(&ReportReply{})
+			}
+			return err
+		}
+
+		if !c.registered(in.Worker) {
+			return status.Error(codes.NotFound, "unknown worker")
+		}
+
+		if in.Interval != nil {
+			in.Interval.Worker = in.Worker
+			c.aggregator.Add(*in.Interval)
+		}
+
+		if in.Result != nil {
+			c.complete(in.Worker, in.Result)
+		}
+	}
+}
+
+func (c *Coordinator) registered(worker string) bool {
+	c.Lock()
+	defer c.Unlock()
+	_, ok := c.workers[worker]
+	return ok
+}
+
+// Records the final result of an agent, closing done when all agents have completed.
+func (c *Coordinator) complete(worker string, result *AgentResult) {
+	if !result.Started.IsZero() {
+		if err := c.barrier.Started(worker, result.Started); err != nil {
+			log.Warn().Err(err).Str("worker", worker).Msg("could not record agent start time")
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.results[worker]; ok {
+		return
+	}
+
+	c.results[worker] = result
+	log.Info().Str("worker", worker).Uint64("events", result.Events).Str("error", result.Error).Msg("agent completed")
+
+	if c.roles[worker] == RoleProducer && len(c.sorted(RoleProducer)) == c.producers {
+		close(c.drained)
+	}
+
+	if len(c.results) == c.expected {
+		close(c.done)
+	}
+}
+
+// Results merges the latencies of all agents into a cluster-wide distribution and
+// reports the per-agent results, the start skew, and the live interval aggregations.
+// The latencies are the publish latencies of the producers; in a scenario the delivery
+// latencies of the consumers are merged separately and the end-to-end section compares
+// the events delivered to each consumer with the events acked by the producers.
+func (c *Coordinator) Results() (benchmarks.Metrics, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.results) == 0 {
+		return nil, errors.New("no agents have reported results")
+	}
+
+	results := make(metrics.Metrics)
+	latencies := &stats.Latencies{}
+	deliveries := &stats.Latencies{}
+	agents := make(map[string]interface{}, len(c.results))
+	errs := make([]string, 0)
+	seeds := make(map[string]int64, len(c.results))
+
+	var (
+		events, failures     uint64
+		producing, consuming window
+		consumers            []*AgentResult
+	)
+
+	workers := make([]string, 0, len(c.results))
+	for worker := range c.results {
+		workers = append(workers, worker)
+	}
+	sort.Strings(workers)
+
+	for _, worker := range workers {
+		result := c.results[worker]
+		role := c.roles[worker]
+
+		agent := map[string]interface{}{
+			"hostname": c.workers[worker],
+			"events":   result.Events,
+			"failures": result.Failures,
+			"started":  result.Started,
+			"duration": result.Duration.String(),
+		}
+
+		if c.scenario() {
+			agent["role"] = role
+		}
+
+		// Host tuning differences between agents often explain differences in results
+		if host := c.tunables[worker]; host != nil {
+			agent["tunables"] = host
+		}
+
+		if conf, ok := c.configs[worker]; ok && role == RoleProducer {
+			agent["seed"] = conf.Seed
+			agent["counter_offset"] = conf.CounterOffset
+			seeds[worker] = conf.Seed
+		}
+
+		if result.Error != "" {
+			agent["error"] = result.Error
+			errs = append(errs, fmt.Sprintf("%s: %s", worker, result.Error))
+		}
+
+		var agentLatencies *stats.Latencies
+		if result.Latencies != nil {
+			agentLatencies = result.Latencies.Latencies()
+			agent["latencies"] = agentLatencies
+		}
+
+		// The windows span from the first agent start to the last agent finish
+		if role == RoleConsumer {
+			agent["duplicates"] = result.Duplicates
+			agent["uncorrelated"] = result.Uncorrelated
+			consumers = append(consumers, result)
+			consuming.add(result)
+			if agentLatencies != nil {
+				deliveries.Append(agentLatencies)
+			}
+		} else {
+			events += result.Events
+			failures += result.Failures
+			producing.add(result)
+			if agentLatencies != nil {
+				latencies.Append(agentLatencies)
+			}
+		}
+		agents[worker] = agent
+	}
+
+	latencies.SetDuration(producing.duration())
+	results["events"] = events
+	results["failures"] = failures
+	results["latencies"] = latencies
+	results["agents"] = agents
+	results["errors"] = errs
+	results["intervals"] = c.aggregator.Intervals()
+
+	if c.scenario() {
+		deliveries.SetDuration(consuming.duration())
+		results["delivery_latencies"] = deliveries
+
+		// Every consumer is expected to receive every event acked by the producers
+		var delivered, missing, duplicates, uncorrelated uint64
+		for _, result := range consumers {
+			received := result.Events - result.Uncorrelated
+			if received < events {
+				missing += events - received
+			}
+			delivered += received
+			duplicates += result.Duplicates
+			uncorrelated += result.Uncorrelated
+		}
+
+		results["end_to_end"] = map[string]interface{}{
+			"published":    events,
+			"consumers":    len(consumers),
+			"delivered":    delivered,
+			"missing":      missing,
+			"duplicates":   duplicates,
+			"uncorrelated": uncorrelated,
+		}
+	}
+
+	if skew, err := c.barrier.Skew(); err == nil {
+		results["start_skew"] = skew.String()
+	}
+
+	experiment := map[string]interface{}{
+		"client_version": benchmarks.Version(),
+		"topic":          c.opts.Topic,
+		"operations":     c.opts.Operations,
+		"data_size":      c.opts.DataSize,
+		"agents":         c.expected,
+		"workload":       c.opts.Workload,
+		"guard":          c.opts.Guard(),
+		"seed":           c.opts.Seed,
+		"seeds":          seeds,
+	}
+
+	if c.scenario() {
+		experiment["producers"] = c.producers
+		experiment["consumers"] = c.consumers
+		experiment["consumer_topic"] = c.consumer.Topic
+	}
+	results["experiment"] = experiment
+	return results, nil
+}
+
+// The measurement window of a group of agents, from the first start to the last finish.
+type window struct {
+	first, last time.Time
+}
+
+func (w *window) add(result *AgentResult) {
+	if result.Started.IsZero() {
+		return
+	}
+
+	if w.first.IsZero() || result.Started.Before(w.first) {
+		w.first = result.Started
+	}
+	if end := result.Started.Add(result.Duration); end.After(w.last) {
+		w.last = end
+	}
+}
+
+func (w *window) duration() time.Duration {
+	return w.last.Sub(w.first)
+}
@@ -0,0 +1,92 @@
+package distributed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveSeed(t *testing.T) {
+	require.Zero(t, distributed.DeriveSeed(0, 3), "unseeded runs should have unseeded workers")
+
+	seen := make(map[int64]struct{})
+	for i := 0; i < 64; i++ {
+		seed := distributed.DeriveSeed(42, i)
+		require.NotZero(t, seed)
+		require.NotEqual(t, int64(42), seed)
+		require.Equal(t, seed, distributed.DeriveSeed(42, i), "derived seeds should be deterministic")
+
+		_, ok := seen[seed]
+		require.False(t, ok, "derived seeds should be distinct")
+		seen[seed] = struct{}{}
+	}
+}
+
+func TestRegisterAssignsWorkerSpaces(t *testing.T) {
+	opts := options.New()
+	opts.Seed = 42
+	opts.Operations = 100
+
+	coord := distributed.NewCoordinator(opts, 2, time.Second)
+	alpha, err := coord.Register(context.Background(), &distributed.RegisterRequest{Hostname: "alpha"})
+	require.NoError(t, err)
+	bravo, err := coord.Register(context.Background(), &distributed.RegisterRequest{Hostname: "bravo"})
+	require.NoError(t, err)
+
+	require.Equal(t, distributed.DeriveSeed(42, 0), alpha.Options.Seed)
+	require.Equal(t, distributed.DeriveSeed(42, 1), bravo.Options.Seed)
+	require.Equal(t, uint64(0), alpha.Options.CounterOffset)
+	require.Equal(t, uint64(100), bravo.Options.CounterOffset)
+
+	// The workload configuration of the run is not modified
+	require.Equal(t, int64(42), opts.Seed)
+	require.Zero(t, opts.CounterOffset)
+
+	seeds := coord.Seeds()
+	require.Equal(t, map[string]int64{alpha.Worker: alpha.Options.Seed, bravo.Worker: bravo.Options.Seed}, seeds)
+
+	_, err = coord.Register(context.Background(), &distributed.RegisterRequest{Hostname: "charlie"})
+	require.Error(t, err, "only the expected number of agents can register")
+}
+
+func TestRegisterAssignsRoles(t *testing.T) {
+	opts := options.New()
+	opts.Operations = 100
+
+	consumer := options.New()
+	consumer.Topic = "deliveries"
+
+	ctx := context.Background()
+	coord := distributed.NewScenario(opts, consumer, 2, 1, time.Second)
+
+	// Agents that request a role are assigned it until the role is full
+	charlie, err := coord.Register(ctx, &distributed.RegisterRequest{Hostname: "charlie", Role: distributed.RoleConsumer})
+	require.NoError(t, err)
+	require.Equal(t, distributed.RoleConsumer, charlie.Role)
+	require.Equal(t, "consumer-0", charlie.Worker)
+	require.Equal(t, "deliveries", charlie.Options.Topic)
+
+	_, err = coord.Register(ctx, &distributed.RegisterRequest{Hostname: "delta", Role: distributed.RoleConsumer})
+	require.Error(t, err, "only the expected number of consumers can register")
+
+	_, err = coord.Register(ctx, &distributed.RegisterRequest{Hostname: "echo", Role: "observer"})
+	require.Error(t, err, "unknown roles cannot register")
+
+	// Agents that do not request a role fill the remaining producers
+	alpha, err := coord.Register(ctx, &distributed.RegisterRequest{Hostname: "alpha"})
+	require.NoError(t, err)
+	require.Equal(t, distributed.RoleProducer, alpha.Role)
+	require.Equal(t, "producer-0", alpha.Worker)
+
+	bravo, err := coord.Register(ctx, &distributed.RegisterRequest{Hostname: "bravo", Role: distributed.RoleProducer})
+	require.NoError(t, err)
+	require.Equal(t, "producer-1", bravo.Worker)
+	require.Equal(t, uint64(100), bravo.Options.CounterOffset, "producers are assigned disjoint counters")
+
+	_, err = coord.Register(ctx, &distributed.RegisterRequest{Hostname: "foxtrot"})
+	require.Error(t, err, "only the expected number of agents can register")
+}
@@ -0,0 +1,188 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/tunables"
+	"google.golang.org/grpc"
+)
+
+// The control API between the coordinator and its agents is a small gRPC service whose
+// messages are encoded as JSON so that it can be served without generated protocol
+// buffer code; the messages are Go structs shared by the coordinator and the agents.
+const (
+	serviceName    = "enbench.distributed.v1.Coordinator"
+	registerMethod = "/" + serviceName + "/Register"
+	clockMethod    = "/" + serviceName + "/Clock"
+	arriveMethod   = "/" + serviceName + "/Arrive"
+	reportMethod   = "/" + serviceName + "/Report"
+	drainMethod    = "/" + serviceName + "/Drain"
+)
+
+// The roles of the agents in a scenario: producers run the blast benchmark while
+// consumers subscribe to the topic and measure the delivery of the produced events.
+const (
+	RoleProducer = "producer"
+	RoleConsumer = "consumer"
+)
+
+// RegisterRequest is sent by an agent to join the run.
+type RegisterRequest struct {
+	Hostname string             `json:"hostname"`
+	Role     string             `json:"role,omitempty"` // if empty the coordinator assigns the role
+	Tunables *tunables.Tunables `json:"tunables,omitempty"`
+}
+
+// RegisterReply assigns the agent its worker name, role, and workload configuration.
+type RegisterReply struct {
+	Worker  string           `json:"worker"`
+	Role    string           `json:"role"`
+	Options *options.Options `json:"options"`
+}
+
+// ClockRequest and ClockReply implement a single NTP-style clock exchange.
+type ClockRequest struct {
+	Sent time.Time `json:"sent"`
+}
+
+type ClockReply struct {
+	RemoteRecv time.Time `json:"remote_recv"`
+	RemoteSent time.Time `json:"remote_sent"`
+}
+
+// ArriveRequest registers the agent at the start barrier with its clock offset; the
+// reply is sent when the barrier is released.
+type ArriveRequest struct {
+	Worker string      `json:"worker"`
+	Offset ClockOffset `json:"offset"`
+}
+
+type ArriveReply struct {
+	StartAt time.Time `json:"start_at"`
+}
+
+// DrainRequest is sent by a consumer once it has started; the reply is sent when all
+// of the producers have completed and describes the events that they published.
+type DrainRequest struct {
+	Worker string `json:"worker"`
+}
+
+type DrainReply struct {
+	Producers []ProducerSummary `json:"producers"`
+}
+
+// ProducerSummary describes the events published by a producer so that a consumer can
+// attribute the events it receives to the producer by their counter and correct the
+// delivery latency for the clock offset of the producer.
+type ProducerSummary struct {
+	Worker        string      `json:"worker"`
+	CounterOffset uint64      `json:"counter_offset"` // counters are in (offset, offset+operations]
+	Operations    uint64      `json:"operations"`
+	Offset        ClockOffset `json:"offset"`
+	Events        uint64      `json:"events"` // the number of events acked by the server
+}
+
+// Published returns the total number of events acked by the producers.
+func (r *DrainReply) Published() (events uint64) {
+	for _, producer := range r.Producers {
+		events += producer.Events
+	}
+	return events
+}
+
+// AgentReport is streamed by an agent to the coordinator while the benchmark runs; it
+// contains either an interval report or the final result of the agent.
+type AgentReport struct {
+	Worker   string          `json:"worker"`
+	Interval *IntervalReport `json:"interval,omitempty"`
+	Result   *AgentResult    `json:"result,omitempty"`
+}
+
+// AgentResult is the outcome of the benchmark run by a single agent.
+type AgentResult struct {
+	Role      string              `json:"role,omitempty"`
+	Started   time.Time           `json:"started"` // in coordinator time
+	Duration  time.Duration       `json:"duration"`
+	Events    uint64              `json:"events"`
+	Failures  uint64              `json:"failures"`
+	Latencies *stats.LatencyState `json:"latencies,omitempty"`
+	Error     string              `json:"error,omitempty"`
+
+	// Consumer results: the events received, the events received more than once, and
+	// the events that could not be attributed to a producer by their counter.
+	Duplicates   uint64 `json:"duplicates,omitempty"`
+	Uncorrelated uint64 `json:"uncorrelated,omitempty"`
+}
+
+type ReportReply struct{}
+
+// Implemented by the coordinator to serve the control API.
+type controlServer interface {
+	Register(context.Context, *RegisterRequest) (*RegisterReply, error)
+	Clock(context.Context, *ClockRequest) (*ClockReply, error)
+	Arrive(context.Context, *ArriveRequest) (*ArriveReply, error)
+	Drain(context.Context, *DrainRequest) (*DrainReply, error)
+	Report(grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*controlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Register", controlServer.Register),
+		unary("Clock", controlServer.Clock),
+		unary("Arrive", controlServer.Arrive),
+		unary("Drain", controlServer.Drain),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Report",
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(controlServer).Report(stream)
+			},
+		},
+	},
+}
+
+// Creates the method description of a unary RPC from the server method.
+func unary[Req, Rep any](name string, method func(controlServer, context.Context, *Req) (*Rep, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(Req)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			if interceptor == nil {
+				return method(srv.(controlServer), ctx, in)
+			}
+
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return method(srv.(controlServer), ctx, req.(*Req))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+// jsonCodec encodes the control API messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
@@ -0,0 +1,289 @@
+/*
+The e2e package implements a benchmark that measures end-to-end latency from the time
+an event is published until it is delivered to a subscriber of the same topic. Every
+published event embeds its local ID in its metadata so that events received on the
+subscribe stream can be correlated with their publish time. Publish-to-ack latencies
+are recorded separately so that the cost of delivery can be distinguished from the cost
+of committing the event on the server.
+*/
+package e2e
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// The metadata key that correlates published events with delivered events.
+const LocalIDKey = "local_id"
+
+// DefaultDrainTimeout is how long to wait for outstanding acks and deliveries after all
+// of the events have been published.
+const DefaultDrainTimeout = 30 * time.Second
+
+// How often in-flight events are checked for acks.
+const ackPoll = time.Millisecond
+
+// E2E publishes a fixed number of events to a topic while subscribed to the same topic
+// and measures both the publish-to-ack and publish-to-delivery latency of every event.
+type E2E struct {
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	tracker  *tracker
+	acks     []time.Duration
+	offsets  []time.Duration // the offset from the start of the run of each acked event
+	nacks    uint64
+	wire     uint64 // the total serialized size of the published events
+	started  time.Time
+	duration time.Duration
+	sending  time.Duration // the duration of the publish phase of the run
+	reason   string
+	progress stats.Progress
+
+	// DrainTimeout is how long to wait for acks and deliveries after publishing.
+	DrainTimeout time.Duration
+}
+
+func New(opts *options.Options) *E2E {
+	return &E2E{opts: opts, DrainTimeout: DefaultDrainTimeout}
+}
+
+func (b *E2E) Run(ctx context.Context) (err error) {
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	// Subscriptions require topic IDs so resolve the topic name if necessary
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	var sub *ensign.Subscription
+	if sub, err = b.client.Subscribe(b.topicID.String()); err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	N := b.opts.Operations
+	b.tracker = newTracker(int(N))
+	b.acks = make([]time.Duration, 0, N)
+	b.offsets = make([]time.Duration, 0, N)
+	b.nacks = 0
+	b.wire = 0
+	b.reason = benchmarks.ExitCompleted
+
+	// Consume deliveries concurrently with publishing; the subscription channel is
+	// never closed so the consumer is stopped when the benchmark completes.
+	done := make(chan struct{})
+	defer close(done)
+	go b.consume(sub, done)
+
+	log.Info().
+		Str("topic", b.opts.Topic).
+		Str("topic_id", b.topicID.String()).
+		Uint64("operations", N).
+		Msg("e2e benchmark starting")
+
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	inflight := make(map[string]*ensign.Event, N)
+	sentat := make(map[string]time.Time, N)
+
+	b.progress.Start()
+	b.progress.Set("operations", N)
+	b.started = time.Now()
+	defer func() {
+		b.duration = time.Since(b.started)
+	}()
+
+	published := uint64(0)
+	for i := uint64(0); i < N; i++ {
+		if err = ctx.Err(); err != nil {
+			b.reason = benchmarks.ExitCanceled
+			return err
+		}
+
+		if b.opts.MaxBytes > 0 && published+uint64(b.opts.DataSize) > b.opts.MaxBytes {
+			b.reason = benchmarks.ExitMaxBytes
+			break
+		}
+
+		if reason := b.opts.Exhausted(b.started, i); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		event := factory()
+		localID := event.Metadata[LocalIDKey]
+
+		sentat[localID] = time.Now()
+		b.tracker.publish(localID, sentat[localID])
+		if err = b.client.Publish(b.topicID.String(), event); err != nil {
+			return err
+		}
+
+		inflight[localID] = event
+		published += uint64(len(event.Data))
+		b.wire += uint64(sustain.WireSize(event))
+		b.progress.Add("published", 1)
+	}
+
+	b.sending = time.Since(b.started)
+
+	// Wait for all of the events to be acked and delivered
+	poll := time.NewTicker(ackPoll)
+	defer poll.Stop()
+	timeout := time.After(b.DrainTimeout)
+
+drain:
+	for len(inflight) > 0 || b.tracker.missing() > 0 {
+		select {
+		case <-poll.C:
+			b.collect(inflight, sentat)
+		case <-timeout:
+			log.Warn().
+				Int("unacked", len(inflight)).
+				Int("undelivered", b.tracker.missing()).
+				Msg("e2e drain timeout exceeded")
+			break drain
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+
+	log.Info().
+		Int("acks", len(b.acks)).
+		Uint64("nacks", b.nacks).
+		Int("delivered", b.tracker.delivered()).
+		Str("exit_reason", b.reason).
+		Msg("e2e benchmark complete")
+	return nil
+}
+
+// Receives events from the subscription, acking them and recording the delivery time
+// of the events published by this benchmark.
+func (b *E2E) consume(sub *ensign.Subscription, done <-chan struct{}) {
+	for {
+		select {
+		case event := <-sub.C:
+			received := time.Now()
+			if _, err := event.Ack(); err != nil {
+				log.Debug().Err(err).Msg("could not ack delivered event")
+			}
+
+			if b.tracker.deliver(event.Metadata[LocalIDKey], received) {
+				b.progress.Add("delivered", 1)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// Checks all in-flight events for acks or nacks, recording the ack latency of each
+// event at the time the resolution was observed.
+func (b *E2E) collect(inflight map[string]*ensign.Event, sentat map[string]time.Time) {
+	for localID, event := range inflight {
+		if acked, _ := event.Acked(); acked {
+			b.acks = append(b.acks, time.Since(sentat[localID]))
+			b.offsets = append(b.offsets, sentat[localID].Sub(b.started))
+			b.progress.Add("acks", 1)
+			delete(inflight, localID)
+			continue
+		}
+
+		if nacked, _ := event.Nacked(); nacked {
+			b.nacks++
+			b.progress.Add("nacks", 1)
+			delete(inflight, localID)
+		}
+	}
+}
+
+// Progress returns the number of events published, acked, and delivered so far.
+func (b *E2E) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// AckLatencies returns the distribution of publish-to-ack latencies from the last run,
+// excluding the events published during the warmup and cooldown of the run.
+func (b *E2E) AckLatencies() *stats.Latencies {
+	latencies := &stats.Latencies{}
+	latencies.UpdateTrimmed(b.opts.Warmup, b.opts.Cooldown, b.sending, b.offsets, b.acks)
+	latencies.SetDuration(b.duration)
+	return latencies
+}
+
+// DeliveryLatencies returns the distribution of publish-to-delivery latencies from the
+// last run; events that were never delivered or that were published during the warmup
+// and cooldown of the run are not included.
+func (b *E2E) DeliveryLatencies() *stats.Latencies {
+	latencies := &stats.Latencies{}
+	offsets, delivered := b.tracker.deliveries(b.started)
+	latencies.UpdateTrimmed(b.opts.Warmup, b.opts.Cooldown, b.sending, offsets, delivered)
+	latencies.SetDuration(b.duration)
+	return latencies
+}
+
+func (b *E2E) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["events"] = b.tracker.published()
+	results["acks"] = len(b.acks)
+	results["nacks"] = b.nacks
+	results["delivered"] = b.tracker.delivered()
+	results["undelivered"] = b.tracker.missing()
+	results["duplicates"], results["foreign"] = b.tracker.uncorrelated()
+	results["delivery_semantics"] = b.tracker.semantics()
+	results["ack_latencies"] = b.AckLatencies()
+	results["delivery_latencies"] = b.DeliveryLatencies()
+	results["ack_phases"] = stats.SplitPhases(b.opts.Phases, b.sending, b.offsets, b.acks)
+
+	offsets, latencies := b.tracker.deliveries(b.started)
+	results["delivery_phases"] = stats.SplitPhases(b.opts.Phases, b.sending, offsets, latencies)
+	results["exit_reason"] = b.reason
+	if n := b.tracker.published(); n > 0 {
+		results["wire_size"] = float64(b.wire) / float64(n)
+	}
+
+	bandwidth := &stats.Bandwidth{}
+	bandwidth.Sent(b.wire)
+	bandwidth.SetDuration(b.sending)
+	results["bandwidth"] = bandwidth
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"max_bytes":        b.opts.MaxBytes,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"drain_timeout":    b.DrainTimeout.String(),
+	}
+	return results, nil
+}
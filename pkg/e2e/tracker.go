@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+)
+
+// tracker correlates the publish time of events with the time that they are delivered
+// to the subscriber. It is safe for concurrent use by the publisher and the consumer.
+type tracker struct {
+	sync.Mutex
+	sent       map[string]time.Time
+	sequence   map[string]uint64 // the order in which the events were published
+	received   map[string]time.Duration
+	duplicates uint64 // events delivered more than once
+	foreign    uint64 // events delivered on the topic that were not published by the run
+	delivery   stats.Delivery
+}
+
+func newTracker(size int) *tracker {
+	return &tracker{
+		sent:     make(map[string]time.Time, size),
+		sequence: make(map[string]uint64, size),
+		received: make(map[string]time.Duration, size),
+	}
+}
+
+// Records the time that the event with the specified local ID was published.
+func (t *tracker) publish(localID string, at time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.sent[localID]; !ok {
+		t.sequence[localID] = uint64(len(t.sent))
+	}
+	t.sent[localID] = at
+}
+
+// Records the delivery of the event with the specified local ID, returning true if the
+// event was published by this run and has not been delivered before.
+func (t *tracker) deliver(localID string, at time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	sent, ok := t.sent[localID]
+	if !ok {
+		t.foreign++
+		return false
+	}
+
+	if _, ok := t.received[localID]; ok {
+		t.duplicates++
+		return false
+	}
+
+	t.received[localID] = at.Sub(sent)
+	t.delivery.Deliver(t.sequence[localID])
+	return true
+}
+
+func (t *tracker) published() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.sent)
+}
+
+func (t *tracker) delivered() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.received)
+}
+
+func (t *tracker) missing() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.sent) - len(t.received)
+}
+
+// Returns the number of redelivered events and events not published by this run.
+func (t *tracker) uncorrelated() (duplicates, foreign uint64) {
+	t.Lock()
+	defer t.Unlock()
+	return t.duplicates, t.foreign
+}
+
+// Returns the delivery semantics observed by the subscriber.
+func (t *tracker) semantics() *stats.Delivery {
+	t.Lock()
+	defer t.Unlock()
+
+	delivery := t.delivery
+	delivery.Published = uint64(len(t.sent))
+	delivery.Duplicates = t.duplicates
+	delivery.Foreign = t.foreign
+	return &delivery
+}
+
+// Returns the offset from the start of the run at which each delivered event was
+// published along with its publish-to-delivery latency.
+func (t *tracker) deliveries(started time.Time) (offsets, latencies []time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	offsets = make([]time.Duration, 0, len(t.received))
+	latencies = make([]time.Duration, 0, len(t.received))
+	for localID, latency := range t.received {
+		offsets = append(offsets, t.sent[localID].Sub(started))
+		latencies = append(latencies, latency)
+	}
+	return offsets, latencies
+}
@@ -0,0 +1,48 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := newTracker(3)
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker.publish("a", start)
+	tracker.publish("b", start.Add(10*time.Millisecond))
+	tracker.publish("c", start.Add(20*time.Millisecond))
+	require.Equal(t, 3, tracker.published())
+	require.Equal(t, 3, tracker.missing())
+
+	require.True(t, tracker.deliver("b", start.Add(35*time.Millisecond)))
+	require.True(t, tracker.deliver("a", start.Add(50*time.Millisecond)))
+	require.Equal(t, 2, tracker.delivered())
+	require.Equal(t, 1, tracker.missing())
+
+	// Redelivered events and events published by other clients are not correlated
+	require.False(t, tracker.deliver("a", start.Add(60*time.Millisecond)))
+	require.False(t, tracker.deliver("z", start.Add(60*time.Millisecond)))
+	require.False(t, tracker.deliver("", start.Add(60*time.Millisecond)))
+	duplicates, foreign := tracker.uncorrelated()
+	require.Equal(t, uint64(1), duplicates)
+	require.Equal(t, uint64(2), foreign)
+	require.Equal(t, 2, tracker.delivered())
+
+	// The delivery of a was reordered after b and c was lost
+	semantics := tracker.semantics()
+	require.Equal(t, uint64(3), semantics.Published)
+	require.Equal(t, uint64(2), semantics.Delivered)
+	require.Equal(t, uint64(1), semantics.Lost())
+	require.Equal(t, uint64(1), semantics.Duplicates)
+	require.Equal(t, uint64(1), semantics.Reordered)
+	require.Equal(t, uint64(2), semantics.Foreign)
+	require.Equal(t, stats.NoGuarantee, semantics.Guarantee())
+
+	offsets, latencies := tracker.deliveries(start)
+	require.ElementsMatch(t, []time.Duration{0, 10 * time.Millisecond}, offsets)
+	require.ElementsMatch(t, []time.Duration{25 * time.Millisecond, 50 * time.Millisecond}, latencies)
+}
@@ -0,0 +1,805 @@
+/*
+Package emulator implements a lightweight in-process Ensign broker on top of the mock
+server provided by the Ensign SDK so that the benchmarks can be run end-to-end without
+network access or credentials, e.g. as smoke tests of the harness in CI. The emulator
+commits published events in memory, acks them to the publisher, and forwards them to
+the subscribers of the topic. The most recent events of each topic are retained so that
+they can be replayed with simple EnSQL queries (SELECT * FROM topic OFFSET n LIMIT n)
+and redelivered to the consumer groups that have not committed them. Events are not
+persisted and measurements taken against the emulator only reflect the cost of the
+client and the in-memory gRPC transport.
+*/
+package emulator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/spaolacci/murmur3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ServerID is returned as the server ID of every stream opened on the emulator.
+const ServerID = "emulator"
+
+// The number of events buffered for each subscriber. If a subscriber does not keep up
+// with the publishers, events are dropped rather than blocking the publish stream.
+const subscriberBuffer = 8192
+
+// The number of committed events retained by each topic for EnSQL queries; once the
+// limit is exceeded the oldest half of the retained events is discarded.
+const historyLimit = 16384
+
+// How long Close waits for the mock server to stop gracefully.
+const shutdownTimeout = 5 * time.Second
+
+// Emulator is an in-memory Ensign broker that serves the Ensign API from a mock server.
+type Emulator struct {
+	sync.RWMutex
+
+	// Events larger than this many bytes are nacked as too large if it is not zero; it
+	// should be set before any events are published.
+	MaxEventSize int
+
+	mock    *mock.Ensign
+	topics  map[ulid.ULID]*topic
+	names   map[string]ulid.ULID
+	subs    map[*subscriber]struct{}
+	groups  map[string]*group
+	offset  uint64
+	dropped uint64
+	started time.Time
+	stop    chan struct{}
+}
+
+type topic struct {
+	id      ulid.ULID
+	name    string
+	events  uint64
+	bytes   uint64
+	created time.Time
+	history []*api.EventWrapper // the most recently committed events of the topic
+	trimmed uint64              // the number of events discarded from the history
+	dedup   *api.Deduplication  // the deduplication policy, which is recorded but not enforced
+	shard   api.ShardingStrategy
+}
+
+type subscriber struct {
+	topics    map[ulid.ULID]struct{}
+	events    chan *api.EventWrapper
+	group     *group
+	delivered map[string]position // events delivered to a group subscriber awaiting an ack
+}
+
+// The position of a delivered event in its topic.
+type position struct {
+	topic  ulid.ULID
+	offset uint64
+}
+
+// A consumer group tracks the events of each topic that have been committed by its
+// consumers. Groups with at-least-once or exactly-once semantics commit an event when
+// it is acked, other groups commit an event as soon as it is delivered. When a consumer
+// connects to a group, the retained events that the group has not committed are
+// replayed to it before any newly published events, so events that were delivered but
+// not acked before a consumer disconnected are redelivered. Consumers of the same group
+// each receive every event; the emulator does not balance events between them.
+type group struct {
+	acks    bool
+	offsets map[ulid.ULID]uint64              // every event at or before the offset of the topic is committed
+	acked   map[ulid.ULID]map[uint64]struct{} // the events committed after the offset of the topic
+}
+
+// New starts an emulator serving the Ensign API, creating the specified topics.
+func New(topics ...string) *Emulator {
+	emu := &Emulator{
+		mock:    mock.New(nil),
+		topics:  make(map[ulid.ULID]*topic),
+		names:   make(map[string]ulid.ULID),
+		subs:    make(map[*subscriber]struct{}),
+		groups:  make(map[string]*group),
+		started: time.Now(),
+		stop:    make(chan struct{}),
+	}
+
+	emu.mock.OnStatus = emu.status
+	emu.mock.OnTopicNames = emu.topicNames
+	emu.mock.OnListTopics = emu.listTopics
+	emu.mock.OnTopicExists = emu.topicExists
+	emu.mock.OnCreateTopic = emu.createTopic
+	emu.mock.OnDeleteTopic = emu.deleteTopic
+	emu.mock.OnSetTopicPolicy = emu.setTopicPolicy
+	emu.mock.OnInfo = emu.info
+	emu.mock.OnPublish = emu.publish
+	emu.mock.OnSubscribe = emu.subscribe
+	emu.mock.OnEnSQL = emu.query
+
+	for _, name := range topics {
+		emu.CreateTopic(name)
+	}
+
+	// The mock creates its client connection lazily without locking, so the connection
+	// is created up front to allow multiple clients to connect to the emulator at once.
+	if _, err := emu.mock.Client(context.Background()); err != nil {
+		panic(err)
+	}
+	return emu
+}
+
+// Mock returns the mock server to connect Ensign clients to with ensign.WithMock.
+func (e *Emulator) Mock() *mock.Ensign {
+	return e.mock
+}
+
+// CreateTopic returns the ID of the named topic, creating the topic if necessary.
+func (e *Emulator) CreateTopic(name string) ulid.ULID {
+	e.Lock()
+	defer e.Unlock()
+
+	if id, ok := e.names[name]; ok {
+		return id
+	}
+
+	t := &topic{id: ulid.Make(), name: name, created: time.Now()}
+	e.topics[t.id] = t
+	e.names[name] = t.id
+	return t.id
+}
+
+// Dropped returns the number of events that could not be delivered to a subscriber
+// because the subscriber was not reading events quickly enough.
+func (e *Emulator) Dropped() uint64 {
+	e.RLock()
+	defer e.RUnlock()
+	return e.dropped
+}
+
+// Close shuts down the mock server; the emulator cannot be used after it is closed.
+// Open publish and subscribe streams are ended cleanly so that the server can stop and
+// clients that are still connected do not attempt to reconnect to the mock. Streams
+// that are blocked by clients that never read (e.g. the blast subscriber) can prevent a
+// graceful stop, so Close gives up waiting for the server after the shutdown timeout.
+func (e *Emulator) Close() {
+	close(e.stop)
+
+	done := make(chan struct{})
+	go func() {
+		e.mock.Shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+	}
+}
+
+func (e *Emulator) status(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+	return &api.ServiceState{
+		Status:  api.ServiceState_HEALTHY,
+		Version: "emulator-" + benchmarks.Version(),
+		Uptime:  durationpb.New(time.Since(e.started)),
+	}, nil
+}
+
+func (e *Emulator) topicNames(context.Context, *api.PageInfo) (*api.TopicNamesPage, error) {
+	e.RLock()
+	defer e.RUnlock()
+
+	page := &api.TopicNamesPage{TopicNames: make([]*api.TopicName, 0, len(e.topics))}
+	for _, t := range e.topics {
+		page.TopicNames = append(page.TopicNames, &api.TopicName{
+			TopicId: t.id.String(),
+			Name:    hashName(t.name),
+		})
+	}
+	return page, nil
+}
+
+func (e *Emulator) listTopics(context.Context, *api.PageInfo) (*api.TopicsPage, error) {
+	e.RLock()
+	defer e.RUnlock()
+
+	page := &api.TopicsPage{Topics: make([]*api.Topic, 0, len(e.topics))}
+	for _, t := range e.topics {
+		page.Topics = append(page.Topics, t.proto())
+	}
+	return page, nil
+}
+
+func (e *Emulator) topicExists(_ context.Context, in *api.TopicName) (*api.TopicExistsInfo, error) {
+	e.RLock()
+	defer e.RUnlock()
+
+	_, exists := e.names[in.Name]
+	return &api.TopicExistsInfo{Query: in.Name, Exists: exists}, nil
+}
+
+func (e *Emulator) createTopic(_ context.Context, in *api.Topic) (*api.Topic, error) {
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "topic name is required")
+	}
+
+	id := e.CreateTopic(in.Name)
+
+	e.RLock()
+	defer e.RUnlock()
+	return e.topics[id].proto(), nil
+}
+
+// Destroys the topic and all of its events; archiving topics is not supported.
+func (e *Emulator) deleteTopic(_ context.Context, in *api.TopicMod) (*api.TopicStatus, error) {
+	id, err := ulid.Parse(in.Id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "could not parse topic id")
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	t, ok := e.topics[id]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "topic not found")
+	}
+
+	switch in.Operation {
+	case api.TopicMod_NOOP:
+		return &api.TopicStatus{Id: in.Id, State: api.TopicState_READY}, nil
+	case api.TopicMod_DESTROY:
+		delete(e.topics, id)
+		delete(e.names, t.name)
+		return &api.TopicStatus{Id: in.Id, State: api.TopicState_DELETING}, nil
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "the emulator does not support the %s operation", in.Operation)
+	}
+}
+
+// Records the deduplication policy and sharding strategy of the topic so that they are
+// reported with the topic; the emulator has a single shard and does not deduplicate.
+func (e *Emulator) setTopicPolicy(_ context.Context, in *api.TopicPolicy) (*api.TopicStatus, error) {
+	id, err := ulid.Parse(in.Id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "could not parse topic id")
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	t, ok := e.topics[id]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "topic not found")
+	}
+
+	if in.DeduplicationPolicy != nil {
+		t.dedup = in.DeduplicationPolicy
+	}
+	if in.ShardingStrategy != api.ShardingStrategy_UNKNOWN {
+		t.shard = in.ShardingStrategy
+	}
+	return &api.TopicStatus{Id: in.Id, State: api.TopicState_READY}, nil
+}
+
+func (e *Emulator) info(_ context.Context, in *api.InfoRequest) (*api.ProjectInfo, error) {
+	e.RLock()
+	defer e.RUnlock()
+
+	filter := make(map[ulid.ULID]struct{}, len(in.Topics))
+	for _, data := range in.Topics {
+		var id ulid.ULID
+		if err := id.UnmarshalBinary(data); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "could not parse topic id")
+		}
+		filter[id] = struct{}{}
+	}
+
+	info := &api.ProjectInfo{Topics: make([]*api.TopicInfo, 0, len(e.topics))}
+	for _, t := range e.topics {
+		if _, ok := filter[t.id]; len(filter) > 0 && !ok {
+			continue
+		}
+
+		info.NumTopics++
+		info.Events += t.events
+		info.DataSizeBytes += t.bytes
+		info.Topics = append(info.Topics, &api.TopicInfo{
+			TopicId:       t.id.Bytes(),
+			Events:        t.events,
+			DataSizeBytes: t.bytes,
+			Modified:      timestamppb.Now(),
+		})
+	}
+	return info, nil
+}
+
+func (e *Emulator) publish(stream api.Ensign_PublishServer) (err error) {
+	var msg *api.PublisherRequest
+	if msg, err = stream.Recv(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return status.Errorf(codes.Aborted, "stream canceled before initialization: %s", err)
+	}
+
+	var open *api.OpenStream
+	if open = msg.GetOpenStream(); open == nil {
+		return status.Error(codes.FailedPrecondition, "expected an open stream message for initialization")
+	}
+
+	ready := &api.StreamReady{ClientId: open.ClientId, ServerId: ServerID, Topics: e.topicMap()}
+	if err = stream.Send(&api.PublisherReply{Embed: &api.PublisherReply_Ready{Ready: ready}}); err != nil {
+		return err
+	}
+
+	msgs, errs := receive(stream.Recv)
+	for {
+		select {
+		case msg = <-msgs:
+		case err = <-errs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return status.Errorf(codes.Aborted, "publish stream aborted: %s", err)
+		case <-e.stop:
+			return nil
+		}
+
+		var event *api.EventWrapper
+		if event = msg.GetEvent(); event == nil {
+			return status.Error(codes.FailedPrecondition, "only events allowed after stream initialization")
+		}
+
+		if err = stream.Send(e.commit(event)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Commits the event to its topic and forwards it to subscribers, returning the ack or
+// nack that should be sent to the publisher.
+func (e *Emulator) commit(event *api.EventWrapper) *api.PublisherReply {
+	var topicID ulid.ULID
+	if err := topicID.UnmarshalBinary(event.TopicId); err != nil {
+		return nack(event, api.Nack_TOPIC_UNKNOWN, "could not parse topic id")
+	}
+
+	if _, err := event.Unwrap(); err != nil {
+		return nack(event, api.Nack_UNPROCESSED, "could not unwrap event")
+	}
+
+	if e.MaxEventSize > 0 && len(event.Event) > e.MaxEventSize {
+		return nack(event, api.Nack_MAX_EVENT_SIZE_EXCEEDED, "event exceeds the maximum event size")
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	t, ok := e.topics[topicID]
+	if !ok {
+		return nack(event, api.Nack_TOPIC_UNKNOWN, "topic does not exist")
+	}
+
+	e.offset++
+	t.events++
+	t.bytes += uint64(len(event.Event))
+
+	committed := &api.EventWrapper{
+		Id:        eventID(e.offset),
+		TopicId:   event.TopicId,
+		Offset:    t.events,
+		Epoch:     1,
+		Event:     event.Event,
+		Committed: timestamppb.Now(),
+		LocalId:   event.LocalId,
+	}
+
+	t.history = append(t.history, committed)
+	if len(t.history) > historyLimit {
+		t.trimmed += uint64(len(t.history) / 2)
+		t.history = append(t.history[:0:0], t.history[len(t.history)/2:]...)
+	}
+
+	for sub := range e.subs {
+		if _, ok := sub.topics[topicID]; !ok {
+			continue
+		}
+
+		select {
+		case sub.events <- committed:
+		default:
+			e.dropped++
+		}
+	}
+
+	return &api.PublisherReply{
+		Embed: &api.PublisherReply_Ack{
+			Ack: &api.Ack{Id: event.LocalId, Committed: committed.Committed},
+		},
+	}
+}
+
+func (e *Emulator) subscribe(stream api.Ensign_SubscribeServer) (err error) {
+	var msg *api.SubscribeRequest
+	if msg, err = stream.Recv(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return status.Errorf(codes.Aborted, "stream canceled before initialization: %s", err)
+	}
+
+	var subscription *api.Subscription
+	if subscription = msg.GetSubscription(); subscription == nil {
+		return status.Error(codes.FailedPrecondition, "expected a subscription to initialize the stream")
+	}
+
+	sub := &subscriber{
+		topics: make(map[ulid.ULID]struct{}, len(subscription.Topics)),
+		events: make(chan *api.EventWrapper, subscriberBuffer),
+	}
+
+	e.RLock()
+	for _, ref := range subscription.Topics {
+		id, perr := ulid.Parse(ref)
+		if perr != nil {
+			var ok bool
+			if id, ok = e.names[ref]; !ok {
+				e.RUnlock()
+				return status.Errorf(codes.NotFound, "unknown topic %q", ref)
+			}
+		}
+		sub.topics[id] = struct{}{}
+	}
+
+	// Subscribe to all topics if none were specified
+	if len(sub.topics) == 0 {
+		for id := range e.topics {
+			sub.topics[id] = struct{}{}
+		}
+	}
+	e.RUnlock()
+
+	ready := &api.StreamReady{ClientId: subscription.ClientId, ServerId: ServerID, Topics: e.topicMap()}
+	if err = stream.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_Ready{Ready: ready}}); err != nil {
+		return err
+	}
+
+	// Events that the consumer group has not committed are replayed before any newly
+	// published events; the replay is collected while registering the subscriber so
+	// that no events are missed or delivered twice between the replay and the stream.
+	e.Lock()
+	e.subs[sub] = struct{}{}
+	var replay []*api.EventWrapper
+	if subscription.Group != nil {
+		sub.group = e.group(subscription.Group)
+		sub.delivered = make(map[string]position)
+		replay = e.uncommitted(sub)
+	}
+	e.Unlock()
+
+	defer func() {
+		e.Lock()
+		delete(e.subs, sub)
+		e.Unlock()
+	}()
+
+	// Events are sent from a separate go routine because sends block once the flow
+	// control window is full if the client is not reading from the stream.
+	sent := make(chan error, 1)
+	go func() {
+		send := func(event *api.EventWrapper) error {
+			if sub.group != nil {
+				e.deliver(sub, event)
+			}
+			return stream.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_Event{Event: event}})
+		}
+
+		for _, event := range replay {
+			if err := send(event); err != nil {
+				sent <- err
+				return
+			}
+		}
+
+		for {
+			select {
+			case event := <-sub.events:
+				if err := send(event); err != nil {
+					sent <- err
+					return
+				}
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	// Acks and nacks are only tracked for consumer groups and are otherwise discarded;
+	// the stream is closed when the client stops sending or disconnects.
+	msgs, errs := receive(stream.Recv)
+	for {
+		select {
+		case msg := <-msgs:
+			if sub.group != nil {
+				e.acknowledge(sub, msg)
+			}
+		case err = <-sent:
+			return err
+		case err = <-errs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-e.stop:
+			return nil
+		}
+	}
+}
+
+// Returns the consumer group of the subscription, creating it if necessary. Groups are
+// identified by name if one is specified, otherwise by ID. Must be called with the lock.
+func (e *Emulator) group(in *api.ConsumerGroup) *group {
+	key := in.Name
+	if key == "" {
+		key = base64.RawURLEncoding.EncodeToString(in.Id)
+	}
+
+	g, ok := e.groups[key]
+	if !ok {
+		g = &group{
+			acks:    in.Delivery == api.DeliverySemantic_AT_LEAST_ONCE || in.Delivery == api.DeliverySemantic_EXACTLY_ONCE,
+			offsets: make(map[ulid.ULID]uint64),
+			acked:   make(map[ulid.ULID]map[uint64]struct{}),
+		}
+		e.groups[key] = g
+	}
+	return g
+}
+
+// Returns the retained events of the subscribed topics that have not been committed by
+// the consumer group of the subscriber. A group that has not consumed a topic before
+// starts at the end of the topic. Must be called with the lock.
+func (e *Emulator) uncommitted(sub *subscriber) (events []*api.EventWrapper) {
+	for id := range sub.topics {
+		t, ok := e.topics[id]
+		if !ok {
+			continue
+		}
+
+		offset, ok := sub.group.offsets[id]
+		if !ok {
+			sub.group.offsets[id] = t.events
+			continue
+		}
+
+		for _, event := range t.history {
+			if event.Offset <= offset {
+				continue
+			}
+			if _, ok := sub.group.acked[id][event.Offset]; !ok {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// Records the delivery of an event to a consumer group subscriber; the event is either
+// committed immediately or once it is acked, depending on the semantics of the group.
+func (e *Emulator) deliver(sub *subscriber, event *api.EventWrapper) {
+	var topicID ulid.ULID
+	if err := topicID.UnmarshalBinary(event.TopicId); err != nil {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	pos := position{topic: topicID, offset: event.Offset}
+	if !sub.group.acks {
+		sub.group.commit(pos)
+		return
+	}
+	sub.delivered[string(event.Id)] = pos
+}
+
+// Commits an event acked by a consumer group subscriber; nacked events are not
+// committed so they are redelivered the next time a consumer connects to the group.
+func (e *Emulator) acknowledge(sub *subscriber, msg *api.SubscribeRequest) {
+	var id []byte
+	switch {
+	case msg.GetAck() != nil:
+		id = msg.GetAck().Id
+	case msg.GetNack() != nil:
+		id = msg.GetNack().Id
+	default:
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	pos, ok := sub.delivered[string(id)]
+	if !ok {
+		return
+	}
+
+	delete(sub.delivered, string(id))
+	if msg.GetAck() != nil {
+		sub.group.commit(pos)
+	}
+}
+
+// Commits the event at the position, advancing the offset of the topic past every
+// contiguous committed event.
+func (g *group) commit(pos position) {
+	offset := g.offsets[pos.topic]
+	if pos.offset <= offset {
+		return
+	}
+
+	acked, ok := g.acked[pos.topic]
+	if !ok {
+		acked = make(map[uint64]struct{})
+		g.acked[pos.topic] = acked
+	}
+	acked[pos.offset] = struct{}{}
+
+	for {
+		if _, ok := acked[offset+1]; !ok {
+			break
+		}
+		delete(acked, offset+1)
+		offset++
+	}
+	g.offsets[pos.topic] = offset
+}
+
+// The subset of EnSQL supported by the emulator: every event of a topic (referenced by
+// name or ID) with an optional offset and limit.
+var selectAll = regexp.MustCompile(`(?i)^\s*SELECT\s+\*\s+FROM\s+"?([\w.-]+)"?(?:\s+OFFSET\s+(\d+))?(?:\s+LIMIT\s+(\d+))?\s*;?\s*$`)
+
+// Streams the retained events of a topic that match the query in commit order. The
+// offset counts all of the events committed to the topic including any that were
+// discarded from the history, so events skipped by trimming are not returned.
+func (e *Emulator) query(in *api.Query, stream api.Ensign_EnSQLServer) (err error) {
+	match := selectAll.FindStringSubmatch(in.Query)
+	if match == nil {
+		return status.Error(codes.Unimplemented, "the emulator only supports SELECT * FROM topic [OFFSET n] [LIMIT n] queries")
+	}
+
+	var offset, limit uint64
+	if match[2] != "" {
+		if offset, err = strconv.ParseUint(match[2], 10, 64); err != nil {
+			return status.Error(codes.InvalidArgument, "could not parse query offset")
+		}
+	}
+	if match[3] != "" {
+		if limit, err = strconv.ParseUint(match[3], 10, 64); err != nil {
+			return status.Error(codes.InvalidArgument, "could not parse query limit")
+		}
+	}
+
+	// Copy the matching events so that the stream is not sent while holding the lock
+	e.RLock()
+	id, perr := ulid.Parse(match[1])
+	if perr != nil {
+		var ok bool
+		if id, ok = e.names[match[1]]; !ok {
+			e.RUnlock()
+			return status.Errorf(codes.NotFound, "unknown topic %q", match[1])
+		}
+	}
+
+	t, ok := e.topics[id]
+	if !ok {
+		e.RUnlock()
+		return status.Errorf(codes.NotFound, "unknown topic %q", match[1])
+	}
+
+	var events []*api.EventWrapper
+	if offset < t.trimmed {
+		offset = t.trimmed
+	}
+	if start := offset - t.trimmed; start < uint64(len(t.history)) {
+		events = t.history[start:]
+	}
+	if limit > 0 && limit < uint64(len(events)) {
+		events = events[:limit]
+	}
+	events = append([]*api.EventWrapper(nil), events...)
+	e.RUnlock()
+
+	for _, event := range events {
+		if err = stream.Send(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Receives messages from a stream in a separate go routine so that handlers can select
+// on incoming messages and shutdown. The go routine exits when the handler returns and
+// the stream context is canceled.
+func receive[T any](recv func() (T, error)) (<-chan T, <-chan error) {
+	msgs := make(chan T)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	return msgs, errs
+}
+
+// Returns the map of topic names to topic IDs sent to clients when a stream is opened.
+func (e *Emulator) topicMap() map[string][]byte {
+	e.RLock()
+	defer e.RUnlock()
+
+	topics := make(map[string][]byte, len(e.topics))
+	for name, id := range e.names {
+		topics[name] = id.Bytes()
+	}
+	return topics
+}
+
+func (t *topic) proto() *api.Topic {
+	topic := &api.Topic{
+		Id:            t.id.Bytes(),
+		Name:          t.name,
+		Offset:        t.events,
+		Shards:        1,
+		Status:        api.TopicState_READY,
+		Deduplication: t.dedup,
+		Created:       timestamppb.New(t.created),
+		Modified:      timestamppb.Now(),
+	}
+
+	if t.shard != api.ShardingStrategy_UNKNOWN {
+		topic.Placements = []*api.Placement{{Epoch: 1, Sharding: t.shard}}
+	}
+	return topic
+}
+
+func nack(event *api.EventWrapper, code api.Nack_Code, msg string) *api.PublisherReply {
+	return &api.PublisherReply{
+		Embed: &api.PublisherReply_Nack{
+			Nack: &api.Nack{Id: event.LocalId, Code: code, Error: msg},
+		},
+	}
+}
+
+// Topic names are resolved by the SDK using the base64 encoded murmur3 hash of the name.
+func hashName(name string) string {
+	hash := murmur3.New128()
+	hash.Write([]byte(name))
+	return base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// Event IDs are 10 bytes long like the RLIDs assigned by the Ensign server.
+func eventID(seq uint64) []byte {
+	id := make([]byte, 10)
+	binary.BigEndian.PutUint64(id[2:], seq)
+	return id
+}
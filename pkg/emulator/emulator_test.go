@@ -0,0 +1,1261 @@
+package emulator_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/calibrate"
+	"github.com/rotationalio/ensign-benchmarks/pkg/chaos"
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
+	"github.com/rotationalio/ensign-benchmarks/pkg/commit"
+	"github.com/rotationalio/ensign-benchmarks/pkg/consistency"
+	"github.com/rotationalio/ensign-benchmarks/pkg/consume"
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/rotationalio/ensign-benchmarks/pkg/e2e"
+	"github.com/rotationalio/ensign-benchmarks/pkg/emulator"
+	"github.com/rotationalio/ensign-benchmarks/pkg/identity"
+	"github.com/rotationalio/ensign-benchmarks/pkg/limits"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/preflight"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ramp"
+	"github.com/rotationalio/ensign-benchmarks/pkg/scale"
+	"github.com/rotationalio/ensign-benchmarks/pkg/seek"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/ensign-benchmarks/pkg/teardown"
+	"github.com/rotationalio/ensign-benchmarks/pkg/tunables"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (*emulator.Emulator, *options.Options) {
+	emu := emulator.New(options.Topic)
+	t.Cleanup(emu.Close)
+
+	opts := options.New()
+	opts.Mock = emu.Mock()
+	opts.Operations = 100
+	opts.DataSize = 256
+	return emu, opts
+}
+
+func TestPreflight(t *testing.T) {
+	_, opts := setup(t)
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := preflight.Probe(ctx, client, opts.Topic)
+	require.NoError(t, err)
+	require.NotZero(t, result.Ack)
+	require.NotZero(t, result.Delivery)
+}
+
+func TestCalibrate(t *testing.T) {
+	_, opts := setup(t)
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	floor, err := calibrate.Measure(context.Background(), client, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), floor.Loopback.Count())
+	require.Equal(t, uint64(10), floor.Server.Count())
+	require.Zero(t, floor.Server.Timeouts())
+	require.NotZero(t, floor.Loopback.Fastest())
+	require.NotZero(t, floor.Server.Fastest())
+	require.GreaterOrEqual(t, floor.Overhead(), time.Duration(0))
+}
+
+func TestBlast(t *testing.T) {
+	_, opts := setup(t)
+
+	b := blast.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+
+	latencies := b.Latencies()
+	require.Equal(t, uint64(100), latencies.N())
+	require.Equal(t, uint64(0), latencies.Timeouts())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	for _, counter := range []string{"acks_missing", "acks_duplicated", "acks_unknown", "out_of_order"} {
+		require.Equal(t, uint64(0), results.Measurement(counter), counter)
+	}
+
+	bandwidth := b.Bandwidth()
+	require.Greater(t, bandwidth.BytesSent(), uint64(100*256))
+	require.Greater(t, bandwidth.SentMBPerSec(), 0.0)
+
+	progress := b.Progress()
+	require.Equal(t, uint64(100), progress["acks"])
+	require.Contains(t, progress, "rolling_p99")
+
+	// The latency of every event is broken down into its client and server phases
+	breakdown := results.Measurement("breakdown").(*blast.Breakdown)
+	for phase, latencies := range map[string]*stats.Latencies{"serialize": breakdown.Serialize, "send": breakdown.Send, "server": breakdown.Server} {
+		require.Equal(t, uint64(100), latencies.N(), phase)
+		require.Equal(t, uint64(0), latencies.Timeouts(), phase)
+	}
+	require.NotNil(t, breakdown.Commit, "the emulator reports when events are committed")
+	require.LessOrEqual(t, breakdown.Server.Mean(), latencies.Mean())
+}
+
+func TestBlastClock(t *testing.T) {
+	_, opts := setup(t)
+
+	// The duration guard must be checked against the fake clock, which is years behind
+	opts.MaxDuration = time.Hour
+
+	// Every reading of the fake clock moves it forward by a millisecond, so every
+	// latency is a whole number of milliseconds no matter how fast the emulator is.
+	b := blast.New(opts)
+	b.Clock = clock.NewFake(time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC), time.Millisecond)
+
+	var mu sync.Mutex
+	samples := make([]time.Duration, 0, 100)
+	b.OnSample = func(latency time.Duration) {
+		mu.Lock()
+		samples = append(samples, latency)
+		mu.Unlock()
+	}
+	require.NoError(t, b.Run(context.Background()))
+
+	require.Len(t, samples, 100)
+	for _, latency := range samples {
+		require.Greater(t, latency, time.Duration(0), "expected no timeouts")
+		require.Zero(t, latency%time.Millisecond, "expected latencies to be measured by the fake clock")
+	}
+
+	latencies := b.Latencies()
+	require.Equal(t, uint64(0), latencies.Timeouts())
+	require.Zero(t, latencies.Duration()%time.Millisecond)
+	require.Greater(t, latencies.Duration(), 200*time.Millisecond, "expected every send and reply to read the clock")
+}
+
+func TestBlastMalformed(t *testing.T) {
+	_, opts := setup(t)
+	opts.Malformed = 0.5
+	opts.Seed = 7
+
+	// Events without a local ID are matched to the nacks without an ID in order
+	b := blast.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Latencies().N())
+	require.Equal(t, uint64(0), b.Latencies().Timeouts())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), results.Measurement("acks_missing"))
+	require.Equal(t, uint64(0), results.Measurement("acks_unknown"))
+	require.Equal(t, uint64(0), results.Measurement("out_of_order"))
+
+	events, failures := b.Counts()
+	require.Equal(t, uint64(100), events+failures)
+	require.NotZero(t, failures)
+}
+
+func TestBlastStreaming(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 10000
+	opts.Seed = 42
+
+	// The workload spans many batches generated by several workers
+	b := blast.New(opts)
+	samples := 0
+	b.OnSample = func(time.Duration) { samples++ }
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(10000), b.Latencies().N())
+	require.Equal(t, 10000, samples)
+
+	events, failures := b.Counts()
+	require.Equal(t, uint64(10000), events)
+	require.Zero(t, failures)
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The events are published in the order of their counter metadata
+	cursor, err := client.EnSQL(context.Background(), &api.Query{Query: "SELECT * FROM benchmarks"})
+	require.NoError(t, err)
+	published, err := cursor.FetchAll()
+	require.NoError(t, err)
+	require.Len(t, published, 10000)
+	for i, event := range published {
+		require.Equal(t, fmt.Sprintf("%x", i+1), event.Metadata["counter"])
+	}
+}
+
+func TestBlastSeededWorkers(t *testing.T) {
+	// The seeded workload does not depend on the number of workers that generate it
+	publish := func(procs int) (data [][]byte) {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+		_, opts := setup(t)
+		opts.Operations = 5000
+		opts.Payload, opts.Seed = workload.JSONPayload, 42
+		opts.Schema = "id:string,amount:float,quantity:int,note:text"
+		require.NoError(t, blast.New(opts).Run(context.Background()))
+
+		client, err := ensign.New(opts.Ensign()...)
+		require.NoError(t, err)
+		defer client.Close()
+
+		cursor, err := client.EnSQL(context.Background(), &api.Query{Query: "SELECT * FROM benchmarks"})
+		require.NoError(t, err)
+		published, err := cursor.FetchAll()
+		require.NoError(t, err)
+		require.Len(t, published, 5000)
+
+		for _, event := range published {
+			data = append(data, event.Data)
+		}
+		return data
+	}
+
+	require.Equal(t, publish(1), publish(4))
+}
+
+func TestBlastRate(t *testing.T) {
+	_, opts := setup(t)
+	opts.Rate = 1000
+
+	b := blast.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Latencies().N())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, 1000.0, results.Measurement("target_rate"))
+	require.InDelta(t, 1000.0, results.Measurement("offered_rate"), 100.0)
+
+	service := results.Measurement("service_latencies").(*stats.Latencies)
+	require.Equal(t, uint64(100), service.N())
+}
+
+func TestBlastStop(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 100000
+	opts.Rate = 1000
+	opts.Streams = 2
+
+	b := blast.New(opts)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- b.Run(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		sent, _ := b.Progress()["sent"].(uint64)
+		return sent >= 50
+	}, 5*time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, b.Stop(ctx))
+	require.NoError(t, <-errc)
+
+	// The replies to the events that were sent are drained before the run is over
+	events, _ := b.Counts()
+	require.Less(t, events, opts.Operations)
+	require.Equal(t, events, b.Latencies().N())
+	require.Equal(t, uint64(0), b.Latencies().Timeouts())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, benchmarks.ExitInterrupted, results.Measurement("exit_reason"))
+
+	// Stopping a run that is over does nothing
+	require.NoError(t, b.Stop(ctx))
+}
+
+// A workload that panics after generating a fixed number of events.
+type panicky struct {
+	gen workload.Generator
+	n   int
+}
+
+func (p *panicky) Next() *api.EventWrapper {
+	if p.n++; p.n > 1100 {
+		panic("workload exhausted")
+	}
+	return p.gen.Next()
+}
+
+var registerPanicky sync.Once
+
+func TestBlastPanic(t *testing.T) {
+	registerPanicky.Do(func() {
+		workload.Register("panicky", func(rnd *rand.Rand) workload.Generator {
+			return &panicky{gen: workload.NewTicker(workload.DefaultTickerSymbols, rnd)}
+		})
+	})
+
+	_, opts := setup(t)
+	opts.Operations = 2048
+	opts.Workload = "panicky"
+
+	b := blast.New(opts)
+	err := benchmarks.Safely(func() error { return b.Run(context.Background()) })
+
+	var panicked *benchmarks.PanicError
+	require.ErrorAs(t, err, &panicked)
+	require.Equal(t, "workload exhausted", panicked.Value)
+	require.Contains(t, string(panicked.Stack), "panicky", "the stack of the worker should be captured")
+
+	// The batch of events generated before the panic is published and measured
+	events, _ := b.Counts()
+	require.Equal(t, uint64(1024), events)
+	require.Equal(t, uint64(1024), b.Latencies().N())
+
+	_, err = b.Results()
+	require.NoError(t, err)
+}
+
+func TestBlastStreams(t *testing.T) {
+	for _, tc := range []struct {
+		predial     bool
+		connections int
+	}{{false, 0}, {true, 0}, {false, 2}, {true, 2}} {
+		_, opts := setup(t)
+		opts.Streams = 4
+		opts.PreDial = tc.predial
+		opts.Connections = tc.connections
+
+		b := blast.New(opts)
+		require.NoError(t, b.Run(context.Background()))
+		require.Equal(t, uint64(100), b.Latencies().N())
+		require.Equal(t, uint64(0), b.Latencies().Timeouts())
+
+		results, err := b.Results()
+		require.NoError(t, err)
+		require.Equal(t, 4, results.Measurement("streams"))
+		require.Equal(t, uint64(3), results.Measurement("dial_latencies").(*stats.Latencies).N())
+
+		// Streams share the connections round robin if fewer connections are specified
+		connections := 4
+		if tc.connections > 0 {
+			connections = tc.connections
+		}
+		require.Equal(t, connections, results.Measurement("connections"))
+		require.Equal(t, uint64(0), results.Measurement("acks_missing"))
+
+		events, _ := b.Counts()
+		require.Equal(t, uint64(100), events)
+	}
+}
+
+func TestBlastWarmup(t *testing.T) {
+	emu, opts := setup(t)
+	opts.WarmupEvents = 150
+
+	b := blast.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Latencies().N(), "warmup events should not be measured")
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	warmup := results.Measurement("warmup").(*stats.WarmupPhase)
+	require.Equal(t, uint64(150), warmup.Events)
+	require.Equal(t, uint64(150), warmup.Acked)
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	info, err := client.TopicInfo(context.Background(), emu.CreateTopic(opts.Topic))
+	require.NoError(t, err)
+	require.Equal(t, uint64(250), info.Events)
+}
+
+func TestMultiTopicBlast(t *testing.T) {
+	_, opts := setup(t)
+	opts.Topics = []options.Target{{Topic: options.Topic, Weight: 1}, {Topic: "orders", Weight: 3}}
+
+	b := blast.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Latencies().N())
+
+	// The missing topic is created and receives three events for every benchmark event
+	targets := b.Targets()
+	require.Len(t, targets, 2)
+	require.False(t, targets[0].Created)
+	require.True(t, targets[1].Created)
+	require.Equal(t, uint64(25), targets[0].Events)
+	require.Equal(t, uint64(75), targets[1].Events)
+	require.Equal(t, uint64(75), targets[1].Latencies.N())
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	info, err := client.TopicInfo(context.Background(), targets[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(75), info.Events)
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Len(t, results.Measurement("topics"), 2)
+
+	// Only the topic created by the run is cleaned up
+	require.Equal(t, []ulid.ULID{targets[1].ID}, b.Created())
+	state, err := client.DestroyTopic(context.Background(), targets[1].ID.String())
+	require.NoError(t, err)
+	require.Equal(t, api.TopicState_DELETING, state)
+
+	exists, err := client.TopicExists(context.Background(), "orders")
+	require.NoError(t, err)
+	require.False(t, exists, "the destroyed topic should no longer exist")
+
+	_, err = client.DestroyTopic(context.Background(), targets[1].ID.String())
+	require.Error(t, err, "a destroyed topic cannot be destroyed again")
+}
+
+func TestE2E(t *testing.T) {
+	emu, opts := setup(t)
+
+	b := e2e.New(opts)
+	b.DrainTimeout = 5 * time.Second
+	require.NoError(t, b.Run(context.Background()))
+
+	require.Equal(t, uint64(100), b.AckLatencies().N())
+	require.Equal(t, uint64(100), b.DeliveryLatencies().N())
+	require.Equal(t, uint64(0), emu.Dropped())
+}
+
+func TestSustain(t *testing.T) {
+	emu, opts := setup(t)
+	opts.Operations = 10
+	opts.Interval = time.Millisecond
+
+	b := sustain.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(10), b.Progress()["published"])
+
+	// Sustain waits for the outstanding acks so every event has a latency
+	latencies := b.Latencies()
+	require.Equal(t, uint64(10), latencies.N())
+	require.Equal(t, uint64(0), latencies.Timeouts())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, benchmarks.ExitCompleted, results.(metrics.Metrics)["exit_reason"])
+	require.Len(t, results.Measurement("windows"), 1)
+
+	// Acks are resolved in the background and reported separately from the publishes
+	require.Equal(t, uint64(10), results.Measurement("ack_latencies").(*stats.Latencies).N())
+	require.Equal(t, uint64(10), results.Measurement("publish_latencies").(*stats.Latencies).N())
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	info, err := client.TopicInfo(context.Background(), emu.CreateTopic(opts.Topic))
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), info.Events)
+}
+
+func TestSustainPublishErrors(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 10
+	opts.Interval = time.Millisecond
+
+	// Events cannot be published to a topic the publish stream does not know about
+	opts.Topic, opts.TopicID = "missing", ""
+	b := sustain.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(10), b.Progress()["errors"])
+
+	// The events are counted as failures rather than waiting for acks that never arrive
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), results.Measurement("failures"))
+	require.Zero(t, b.Latencies().Timeouts())
+	require.Zero(t, results.Measurement("ack_latencies").(*stats.Latencies).N())
+}
+
+func TestSustainStop(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 0
+	opts.Interval = time.Millisecond
+
+	b := sustain.New(opts)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- b.Run(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		published, _ := b.Progress()["published"].(uint64)
+		return published >= 10
+	}, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, b.Stop(context.Background()))
+	require.NoError(t, <-errc)
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, benchmarks.ExitInterrupted, results.Measurement("exit_reason"))
+	require.Equal(t, uint64(0), b.Latencies().Timeouts())
+}
+
+func TestHarness(t *testing.T) {
+	_, opts := setup(t)
+	opts.Interval = time.Millisecond
+
+	for _, bench := range []benchmarks.Benchmark{blast.New(opts), sustain.New(opts)} {
+		workload := bench.Workload()
+		require.NoError(t, workload.Prepare(), bench.String())
+		require.True(t, workload.Next(), bench.String())
+		require.NotNil(t, workload.Value(), bench.String())
+		require.NoError(t, workload.Release(), bench.String())
+
+		results, err := benchmarks.Run(context.Background(), bench)
+		require.NoError(t, err, bench.String())
+		require.Equal(t, benchmarks.ExitCompleted, results.Measurement("exit_reason"), bench.String())
+
+		// The client is closed by the harness but can be connected again
+		require.NoError(t, bench.Client().Close(), bench.String())
+		require.NoError(t, bench.Client().Connect(), bench.String())
+		require.NoError(t, bench.Client().Close(), bench.String())
+	}
+}
+
+func TestSustainReplay(t *testing.T) {
+	emu, opts := setup(t)
+	opts.Operations = 10
+	opts.Interval = time.Millisecond
+
+	// Replay a testdata file with fewer events than the run so that it is looped
+	path := filepath.Join(t.TempDir(), "logs.pb")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	writer, err := workload.NewStreamWriter(f, workload.FormatPB)
+	require.NoError(t, err)
+
+	logs := workload.NewLogLines(workload.DefaultErrorRate, workload.NewRand(0))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, writer.Write(logs.Next()))
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, f.Close())
+
+	opts.Workload = workload.FilePrefix + path
+	b := sustain.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(10), b.Progress()["published"])
+	require.Equal(t, uint64(10), b.Latencies().N())
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	info, err := client.TopicInfo(context.Background(), emu.CreateTopic(opts.Topic))
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), info.Events)
+
+	// A replayed file that is missing fails the run rather than publishing other events
+	opts.Workload = workload.FilePrefix + filepath.Join(t.TempDir(), "missing.pb")
+	require.Error(t, sustain.New(opts).Run(context.Background()))
+}
+
+func TestSustainClock(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 10
+	opts.Interval = time.Millisecond
+
+	b := sustain.New(opts)
+	b.Clock = clock.NewFake(time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC), time.Second)
+	require.NoError(t, b.Run(context.Background()))
+
+	// Latencies are measured by the fake clock, not by how long the events took
+	latencies := b.Latencies()
+	require.Equal(t, uint64(10), latencies.N())
+	require.Equal(t, uint64(0), latencies.Timeouts())
+	require.GreaterOrEqual(t, latencies.Fastest(), time.Second)
+	require.Zero(t, latencies.Total()%time.Second)
+	require.Zero(t, latencies.Duration()%time.Second)
+}
+
+func TestSustainStream(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 50
+	opts.Interval = time.Millisecond
+	opts.Window = 10 * time.Millisecond
+
+	var windows []*stats.Window
+	b := sustain.New(opts)
+	b.OnWindow = func(w *stats.Window) {
+		windows = append(windows, w)
+	}
+	require.NoError(t, b.Run(context.Background()))
+	require.Greater(t, len(windows), 1)
+
+	// The streamed windows are contiguous and account for every event of the run
+	var events uint64
+	for i, w := range windows {
+		if i > 0 {
+			require.Equal(t, windows[i-1].End, w.Start)
+		}
+		require.LessOrEqual(t, w.Start, w.End)
+		events += w.Latencies.Count()
+	}
+	require.Zero(t, windows[0].Start)
+	require.Equal(t, uint64(50), events)
+}
+
+func TestSustainJitter(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 50
+	opts.Interval = time.Millisecond
+	opts.Jitter, opts.Seed = "exponential", 42
+
+	b := sustain.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(50), b.Latencies().N())
+
+	// Exponential intervals vary as much as their mean
+	results, err := b.Results()
+	require.NoError(t, err)
+	arrivals := results.Measurement("arrivals").(*stats.Latencies)
+	require.Equal(t, uint64(50), arrivals.N())
+	require.Greater(t, arrivals.StdDev(), arrivals.Mean()/2)
+}
+
+func TestSustainTokenRefresh(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 150
+	opts.Interval = 10 * time.Millisecond
+
+	// Access tokens expire within a second so the run crosses an expiry boundary
+	srv := quarterdeck(t, time.Second)
+	tokens, err := identity.Login(context.Background(), srv.URL, "client", "secret", true)
+	require.NoError(t, err)
+
+	_, err = opts.Mock.ResetClient(context.Background(), tokens.DialOptions()...)
+	require.NoError(t, err)
+
+	b := sustain.New(opts)
+	b.Tokens, b.TokenCheck = tokens, 20*time.Millisecond
+	b.RefreshWindow = 50 * time.Millisecond
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(150), b.Latencies().N())
+
+	refreshes := b.TokenRefreshes()
+	require.NotEmpty(t, refreshes.Refreshes)
+	require.Equal(t, uint64(len(refreshes.Refreshes)), refreshes.Blocked.N())
+	require.Zero(t, refreshes.Failures)
+	require.Equal(t, uint64(150), refreshes.Adjacent.N()+refreshes.Baseline.N())
+	require.NotZero(t, refreshes.Adjacent.N(), "expected events to be published around the refresh")
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	_, err = json.Marshal(results)
+	require.NoError(t, err)
+	require.IsType(t, &sustain.TokenRefreshes{}, results.Measurement("token_refresh"))
+}
+
+func TestSustainChaos(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 50
+	opts.Interval = time.Millisecond
+
+	// The mock client cannot reconnect a closed stream so only delays and drops are injected
+	injector := chaos.New(chaos.Faults{Delay: 0.2, MaxDelay: time.Millisecond, Drop: 0.2}, 7)
+	_, err := opts.Mock.ResetClient(context.Background(), injector.DialOptions()...)
+	require.NoError(t, err)
+
+	b := sustain.New(opts)
+	b.Chaos, b.DrainTimeout = injector, 100*time.Millisecond
+	require.NoError(t, b.Run(context.Background()))
+
+	injected := injector.Injected()
+	require.NotZero(t, injected[chaos.KindDelay])
+	require.NotZero(t, injected[chaos.KindDrop])
+
+	// Dropped events are never acked
+	latencies := b.Latencies()
+	require.Equal(t, uint64(50), latencies.Count())
+	require.Equal(t, injected[chaos.KindDrop], latencies.Timeouts())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	_, err = json.Marshal(results)
+	require.NoError(t, err)
+	require.Same(t, injector, results.Measurement("chaos"))
+}
+
+// Starts a fake Quarterdeck that issues access tokens with the specified lifetime.
+func quarterdeck(t *testing.T, lifetime time.Duration) *httptest.Server {
+	issue := func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		access := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			ID:        ulid.Make().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		})
+		refresh := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			ID:        ulid.Make().String(),
+			NotBefore: jwt.NewNumericDate(now.Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		})
+
+		tokens := make(map[string]string)
+		var err error
+		if tokens["access_token"], err = access.SignedString([]byte("secret")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if tokens["refresh_token"], err = refresh.SignedString([]byte("secret")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(tokens)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/authenticate", issue)
+	mux.HandleFunc("/v1/refresh", issue)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSustainTail(t *testing.T) {
+	emu, opts := setup(t)
+	opts.Operations = 10
+	opts.Interval = time.Millisecond
+	opts.TailThreshold = time.Nanosecond
+
+	// Every event is slower than the threshold so every probe is slow as well
+	b := sustain.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(10), b.Latencies().N(), "probes should not be measured")
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	tail := results.Measurement("tail").(*stats.Tail)
+	require.Equal(t, uint64(10), tail.Slow)
+	require.NotZero(t, tail.Probed)
+	require.Equal(t, tail.Probed, tail.Persistent)
+	require.NotZero(t, tail.Degraded())
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	info, err := client.TopicInfo(context.Background(), emu.CreateTopic(opts.Topic))
+	require.NoError(t, err)
+	require.Equal(t, 10+tail.Probed, info.Events)
+}
+
+func TestRamp(t *testing.T) {
+	_, opts := setup(t)
+
+	b := ramp.New(opts)
+	b.StartRate, b.Increment, b.MaxRate = 100, 100, 300
+	b.Step = 100 * time.Millisecond
+	b.SLO = time.Second
+	require.NoError(t, b.Run(context.Background()))
+	require.Len(t, b.Steps(), 3)
+	require.Greater(t, b.Sustainable(), 0.0)
+	require.Equal(t, uint64(3), b.Progress()["steps"])
+	require.Equal(t, uint64(300), b.Progress()["target_rate"])
+
+	// An unachievable SLO is violated by the first step
+	b.SLO = time.Nanosecond
+	require.NoError(t, b.Run(context.Background()))
+	require.Len(t, b.Steps(), 1)
+	require.True(t, b.Steps()[0].Violated)
+	require.Equal(t, 0.0, b.Sustainable())
+}
+
+func TestDistributed(t *testing.T) {
+	_, opts := setup(t)
+	opts.Seed = 42
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	coord := distributed.NewCoordinator(opts, 2, 100*time.Millisecond)
+	coord.Display = nil
+	go coord.Serve(lis)
+	defer coord.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Agents only need to know how to connect, the workload is sent by the coordinator
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		conn := options.New()
+		conn.Mock = opts.Mock
+		go func() {
+			errs <- distributed.NewAgent(lis.Addr().String(), conn).Run(ctx)
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-errs)
+	}
+	require.NoError(t, coord.Wait(ctx))
+	require.Equal(t, 2, coord.Progress()["completed"])
+
+	results, err := coord.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), results.Measurement("events"))
+	require.Equal(t, uint64(200), results.Measurement("latencies").(*stats.Latencies).N())
+	require.Len(t, results.Measurement("agents"), 2)
+	require.Contains(t, results.Measurement("agents"), "agent-1")
+	require.NotNil(t, results.Measurement("start_skew"))
+
+	// The host tunables of every agent are recorded with its results
+	agent := results.Measurement("agents").(map[string]interface{})["agent-0"].(map[string]interface{})
+	require.IsType(t, &tunables.Tunables{}, agent["tunables"])
+
+	// Each agent generates its workload from a distinct seed derived from the run's seed
+	seeds := results.Measurement("experiment").(map[string]interface{})["seeds"].(map[string]int64)
+	require.Len(t, seeds, 2)
+	require.NotEqual(t, seeds["agent-0"], seeds["agent-1"])
+}
+
+func TestDistributedScenario(t *testing.T) {
+	_, opts := setup(t)
+	opts.Seed = 42
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	coord := distributed.NewScenario(opts, nil, 2, 1, 100*time.Millisecond)
+	coord.Display = nil
+	go coord.Serve(lis)
+	defer coord.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 3)
+	for _, role := range []string{distributed.RoleConsumer, "", ""} {
+		conn := options.New()
+		conn.Mock = opts.Mock
+		agent := distributed.NewAgent(lis.Addr().String(), conn)
+		agent.Role = role
+		agent.IdleTimeout = time.Second
+		go func() {
+			errs <- agent.Run(ctx)
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-errs)
+	}
+	require.NoError(t, coord.Wait(ctx))
+
+	results, err := coord.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), results.Measurement("events"), "only producer events are counted as published")
+	require.Equal(t, uint64(200), results.Measurement("latencies").(*stats.Latencies).N())
+	require.Equal(t, uint64(200), results.Measurement("delivery_latencies").(*stats.Latencies).N())
+
+	// The consumer receives every event acked by both producers
+	e2e := results.Measurement("end_to_end").(map[string]interface{})
+	require.Equal(t, uint64(200), e2e["delivered"])
+	require.Equal(t, uint64(0), e2e["missing"])
+	require.Equal(t, uint64(0), e2e["uncorrelated"])
+
+	agents := results.Measurement("agents").(map[string]interface{})
+	require.Len(t, agents, 3)
+	require.Equal(t, distributed.RoleConsumer, agents["consumer-0"].(map[string]interface{})["role"])
+	require.Equal(t, distributed.RoleProducer, agents["producer-1"].(map[string]interface{})["role"])
+}
+
+func TestAIMD(t *testing.T) {
+	_, opts := setup(t)
+
+	b := ramp.NewAIMD(opts)
+	b.MaxConcurrency = 8
+	b.Window = 50 * time.Millisecond
+	b.Duration = 500 * time.Millisecond
+	b.Ceiling = time.Second
+	require.NoError(t, b.Run(context.Background()))
+	require.NotEmpty(t, b.Windows())
+
+	// Without congestion the limit grows by one every window up to the max
+	windows := b.Windows()
+	require.Equal(t, 1, windows[0].Concurrency)
+	require.LessOrEqual(t, windows[len(windows)-1].Concurrency, 8)
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.NotZero(t, results.Measurement("latencies").(*stats.Latencies).N())
+}
+
+func TestConsume(t *testing.T) {
+	_, opts := setup(t)
+
+	b := consume.New(opts)
+	b.IdleTimeout = time.Second
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Received())
+	require.Equal(t, uint64(100), b.AckLatencies().N())
+	require.Equal(t, stats.ExactlyOnce, b.Semantics().Guarantee())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), results.Measurement("missing"))
+	require.Equal(t, uint64(100*256), results.Measurement("bytes"))
+	require.Greater(t, results.Measurement("events_per_sec"), 0.0)
+	require.NotContains(t, results.(metrics.Metrics), "ack_queue")
+
+	// Pipelined acks are all recorded along with the depth of the ack queue
+	b = consume.New(opts)
+	b.IdleTimeout = time.Second
+	b.AckWorkers, b.AckQueue = 4, 8
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Received())
+	require.Equal(t, uint64(100), b.AckLatencies().N())
+
+	results, err = b.Results()
+	require.NoError(t, err)
+	queue, ok := results.(metrics.Metrics)["ack_queue"].(*stats.QueueDepth)
+	require.True(t, ok)
+	require.Equal(t, uint64(100), queue.N())
+	require.LessOrEqual(t, queue.Percentile(100), 8)
+
+	// A slow reader takes at least as long as the bandwidth cap allows after the burst
+	b = consume.New(opts)
+	b.IdleTimeout = time.Second
+	b.RecvRate = 100 * 256 / 2
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Received())
+
+	results, err = b.Results()
+	require.NoError(t, err)
+	throttled, err := time.ParseDuration(results.Measurement("throttled").(string))
+	require.NoError(t, err)
+	require.Greater(t, throttled, 800*time.Millisecond)
+	require.Equal(t, uint64(0), results.Measurement("drops"))
+	require.Equal(t, uint64(0), results.Measurement("disconnects"))
+}
+
+func TestCommit(t *testing.T) {
+	_, opts := setup(t)
+
+	b := commit.New(opts)
+	b.ReconnectEvery = 30
+	b.IdleTimeout = time.Second
+	b.Policies = nil
+	for _, name := range []string{"event", "count:1000"} {
+		policy, err := commit.ParsePolicy(name)
+		require.NoError(t, err)
+		b.Policies = append(b.Policies, policy)
+	}
+	require.NoError(t, b.Run(context.Background()))
+
+	results := b.PolicyResults()
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.Equal(t, uint64(100), result.Received, "policy %s", result.Policy.Name)
+		require.Equal(t, uint64(4), result.Connects, "policy %s", result.Policy.Name)
+	}
+
+	// Acks sent before a disconnect are committed, so acking every event prevents
+	// redeliveries while buffered acks that are never sent redeliver every earlier event
+	require.Zero(t, results[0].Redelivered)
+	require.Equal(t, uint64(100), results[0].Acks)
+	require.Equal(t, uint64(30+60+90), results[1].Redelivered)
+	require.Equal(t, uint64(30+60+90), results[1].Discarded)
+
+	_, err := commit.ParsePolicy("count:0")
+	require.Error(t, err)
+}
+
+func TestSeek(t *testing.T) {
+	_, opts := setup(t)
+
+	b := seek.New(opts)
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Replayed())
+
+	// The history now contains the events of both fills
+	b.Offset = 150
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(50), b.Replayed())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), results.Measurement("head"))
+	require.Equal(t, true, results.Measurement("caught_up"))
+	require.Equal(t, uint64(50*256), results.Measurement("bytes"))
+
+	// Without a fill there is no history to replay after the head of the topic
+	b.Fill, b.Offset = false, 200
+	require.Error(t, b.Run(context.Background()))
+}
+
+func TestCache(t *testing.T) {
+	_, opts := setup(t)
+
+	b := seek.NewCache(opts)
+	b.Delay = 10 * time.Millisecond
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Warm().Replayed())
+	require.Equal(t, uint64(100), b.Cold().Replayed())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, benchmarks.ExitCompleted, results.Measurement("exit_reason"))
+	require.Greater(t, results.Measurement("cold_slowdown"), 0.0)
+
+	warm, cold := results.(metrics.Metrics)["warm"].(metrics.Metrics), results.(metrics.Metrics)["cold"].(metrics.Metrics)
+	require.Equal(t, uint64(100), warm["events"])
+	require.Equal(t, uint64(100), cold["events"])
+	require.Equal(t, true, cold["caught_up"])
+	require.NotContains(t, cold, "experiment")
+
+	// The cold replay reads the oldest events of the topic rather than the fill; only
+	// the older events are read if there are fewer of them than the fill
+	b.History, b.ColdOffset = true, 50
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(100), b.Warm().Replayed())
+	require.Equal(t, uint64(50), b.Cold().Replayed())
+
+	b.ColdOffset = 200
+	require.Error(t, b.Run(context.Background()))
+}
+
+func TestConsistency(t *testing.T) {
+	_, opts := setup(t)
+	opts.Operations = 5
+
+	for _, from := range []string{consistency.FromLatest, consistency.FromEarliest} {
+		b := consistency.New(opts)
+		b.From = from
+		b.Interval = time.Millisecond
+		require.NoError(t, b.Run(context.Background()), "could not run %s probe", from)
+
+		results, err := b.Results()
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), results.Measurement("probes"))
+		require.Equal(t, uint64(5), results.Measurement("reads"), "not all %s probes were read back", from)
+		require.Equal(t, 1.0, results.Measurement("success_rate"))
+		require.Equal(t, uint64(5), results.Measurement("read_delays").(*stats.Latencies).N())
+	}
+
+	b := consistency.New(opts)
+	b.From = "middle"
+	require.Error(t, b.Run(context.Background()))
+}
+
+func TestQueryBenchmark(t *testing.T) {
+	_, opts := setup(t)
+
+	b := seek.NewQuery(opts)
+	b.Concurrency, b.Repeat = []int{1, 3}, 2
+	require.NoError(t, b.Run(context.Background()))
+
+	// Each level runs every query on every worker; the default queries return the whole
+	// topic, the first page, and no rows since the topic only has 100 events
+	levels := b.Levels()
+	require.Len(t, levels, 2)
+	require.Equal(t, uint64(6), levels[0].Queries)
+	require.Equal(t, uint64(18), levels[1].Queries)
+	require.Equal(t, uint64(2*200), levels[0].Rows)
+	require.Equal(t, uint64(6*200), levels[1].Rows)
+	require.Zero(t, levels[1].Failures)
+	require.Equal(t, 1.0, levels[0].Scaling)
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, benchmarks.ExitCompleted, results.Measurement("exit_reason"))
+	require.Equal(t, uint64(1600), results.Measurement("events"))
+	require.Greater(t, results.Measurement("rows_per_sec"), 0.0)
+
+	// Unsupported queries are counted as failures rather than stopping the run
+	b.Queries, b.Fill = []string{"SELECT * FROM {topic} LIMIT 10", "SELECT * FROM {topic} WHERE counter = 1"}, false
+	b.Concurrency = []int{2}
+	require.NoError(t, b.Run(context.Background()))
+	require.Equal(t, uint64(4), b.Levels()[0].Failures)
+	require.Equal(t, uint64(40), b.Levels()[0].Rows)
+
+	b.Queries = nil
+	require.Error(t, b.Run(context.Background()))
+}
+
+func TestQuery(t *testing.T) {
+	_, opts := setup(t)
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	b := seek.New(opts)
+	b.Offset = 99
+	require.NoError(t, b.Run(context.Background()))
+
+	cursor, err := client.EnSQL(context.Background(), &api.Query{Query: "SELECT * FROM benchmarks OFFSET 10 LIMIT 5"})
+	require.NoError(t, err)
+	events, err := cursor.FetchAll()
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+
+	_, err = client.EnSQL(context.Background(), &api.Query{Query: "SELECT * FROM benchmarks WHERE counter = 1"})
+	require.Error(t, err, "unsupported queries should not be silently ignored")
+}
+
+func TestTeardown(t *testing.T) {
+	_, opts := setup(t)
+
+	b := teardown.New(opts)
+	b.Trials, b.Burst = 3, 50
+	b.Settle = 10 * time.Millisecond
+	require.NoError(t, b.Run(context.Background()))
+	require.Len(t, b.Outcomes(), 3)
+
+	for _, trial := range b.Outcomes() {
+		require.True(t, trial.TornDown)
+		require.Equal(t, "Canceled", trial.Code)
+		require.Equal(t, uint64(50), trial.Sent)
+		require.True(t, trial.Verified)
+
+		// Every event is either acked or unresolved and no more events than were sent
+		// can be committed by the server
+		require.Equal(t, trial.Sent, trial.Acked+trial.Nacked+trial.Unresolved)
+		require.LessOrEqual(t, trial.Committed, trial.Sent)
+		require.GreaterOrEqual(t, trial.Committed, trial.Acked)
+	}
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), results.Measurement("sent"))
+	require.Equal(t, 0, results.Measurement("hung"))
+	require.Equal(t, uint64(3), results.Measurement("teardown_latencies").(*stats.Latencies).N())
+}
+
+func TestScale(t *testing.T) {
+	_, opts := setup(t)
+
+	b := scale.New(opts)
+	b.Steps, b.Lookups, b.Trickle = []int{10, 50}, 20, 2
+	require.NoError(t, b.Run(context.Background()))
+	require.Len(t, b.StepResults(), 2)
+
+	for _, step := range b.StepResults() {
+		// The benchmark topic exists in the project in addition to the created topics
+		require.Equal(t, step.Topics+1, step.Listed)
+		require.Equal(t, uint64(20), step.Lookup.N())
+		require.Equal(t, uint64(2*step.Topics), step.Publish.N())
+		require.Zero(t, step.Publish.Timeouts())
+	}
+	require.Equal(t, uint64(40), b.StepResults()[1].Create.N())
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, uint64(120), results.Measurement("events"))
+	require.Equal(t, 50, results.Measurement("topics_destroyed"))
+	require.Contains(t, results.Measurement("growth"), "lookup")
+
+	// Only the benchmark topic should remain once the created topics are destroyed
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	topics, err := client.ListTopics(context.Background())
+	require.NoError(t, err)
+	require.Len(t, topics, 1)
+
+	b.Steps = []int{10, 5}
+	require.Error(t, b.Run(context.Background()), "steps must be increasing")
+}
+
+func TestTopicPolicy(t *testing.T) {
+	emu, opts := setup(t)
+	topicID := emu.CreateTopic("policies").String()
+
+	client, err := ensign.New(opts.Ensign()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := context.Background()
+	_, err = client.SetTopicDeduplicationPolicy(ctx, topicID, api.Deduplication_UNIQUE_KEY, api.Deduplication_OFFSET_LATEST, []string{"counter"})
+	require.NoError(t, err)
+	_, err = client.SetTopicShardingStrategy(ctx, topicID, api.ShardingStrategy_RANDOM)
+	require.NoError(t, err)
+
+	// The policies are reported with the topic and are not reset by setting the other
+	topics, err := client.ListTopics(ctx)
+	require.NoError(t, err)
+
+	var topic *api.Topic
+	for _, listed := range topics {
+		if listed.Name == "policies" {
+			topic = listed
+		}
+	}
+	require.NotNil(t, topic)
+	require.Equal(t, api.Deduplication_UNIQUE_KEY, topic.Deduplication.Strategy)
+	require.Equal(t, []string{"counter"}, topic.Deduplication.Keys)
+	require.Len(t, topic.Placements, 1)
+	require.Equal(t, api.ShardingStrategy_RANDOM, topic.Placements[0].Sharding)
+
+	_, err = client.SetTopicShardingStrategy(ctx, ulid.Make().String(), api.ShardingStrategy_RANDOM)
+	require.Error(t, err)
+}
+
+func TestSizeProbe(t *testing.T) {
+	emu, opts := setup(t)
+	emu.MaxEventSize = 10000
+
+	b := limits.NewSizeProbe(opts)
+	b.MaxSize, b.Precision = 1<<20, 64
+	require.NoError(t, b.Run(context.Background()))
+	require.Len(t, b.Limits(), 2)
+
+	// The limit applies to the wrapped event so the discovered sizes are a little smaller
+	for _, limit := range b.Limits() {
+		require.True(t, limit.Detected, limit.Dimension)
+		require.Less(t, limit.Accepted, 10000)
+		require.Greater(t, limit.Accepted, 9000)
+		require.LessOrEqual(t, limit.Rejected-limit.Accepted, 64)
+		require.Contains(t, limit.Codes, api.Nack_MAX_EVENT_SIZE_EXCEEDED.String())
+	}
+
+	// Every size is accepted if the max size is below the limit
+	b.MaxSize, b.Metadata = 8000, false
+	require.NoError(t, b.Run(context.Background()))
+	require.Len(t, b.Limits(), 1)
+	require.False(t, b.Limits()[0].Detected)
+	require.Equal(t, 8000, b.Limits()[0].Accepted)
+
+	results, err := b.Results()
+	require.NoError(t, err)
+	require.Equal(t, 8000, results.Measurement("payload_max_accepted"))
+}
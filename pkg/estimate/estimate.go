@@ -0,0 +1,193 @@
+/*
+Package estimate projects the duration and data volume of a benchmark run from its
+parameters before it is run, so that a run that would exceed the time or data budget
+of a shared cluster can be resized before any events are published. Throughput priors
+are drawn from the runs recorded in the local results store when available so that
+runs that are not rate limited can be estimated as well.
+*/
+package estimate
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+)
+
+// PriorRuns is the maximum number of recent runs that the throughput prior is drawn from.
+const PriorRuns = 10
+
+// Prior is the throughput observed by previous runs of a benchmark against the same
+// endpoint with the nearest data size to the estimated run.
+type Prior struct {
+	Runs       int     `json:"runs"`
+	DataSize   int64   `json:"data_size"`
+	Throughput float64 `json:"throughput"`          // the mean events per second of the runs
+	WireSize   float64 `json:"wire_size,omitempty"` // the mean serialized size of the events
+}
+
+// The parts of the stored metrics of a run that are used as a prior.
+type observed struct {
+	Latencies struct {
+		Throughput float64 `json:"throughput"`
+	} `json:"latencies"`
+	WireSize   float64 `json:"wire_size"`
+	Experiment struct {
+		Endpoint      string `json:"endpoint"`
+		DataSize      int64  `json:"data_size"`
+		LocalEmulator bool   `json:"local_emulator"`
+	} `json:"experiment"`
+}
+
+// Priors computes the throughput prior of a run with the data size against the endpoint
+// from the stored runs, which must be ordered from newest to oldest. Runs against the
+// local emulator or another endpoint and runs that did not report a throughput are
+// ignored. If no run has the same data size, the runs with the nearest data size are
+// used since the throughput of a run depends on the size of its events. Returns nil if
+// there are no eligible runs.
+func Priors(runs []*store.Run, endpoint string, dataSize int64) *Prior {
+	var prior *Prior
+	var distance float64
+	var throughput, wire float64
+	for _, run := range runs {
+		var obs observed
+		if err := json.Unmarshal(run.Metrics, &obs); err != nil {
+			continue
+		}
+
+		if obs.Latencies.Throughput <= 0 || obs.Experiment.DataSize <= 0 || obs.Experiment.LocalEmulator || obs.Experiment.Endpoint != endpoint {
+			continue
+		}
+
+		// Data sizes are compared by their ratio since throughput scales with size
+		d := math.Abs(math.Log(float64(obs.Experiment.DataSize) / float64(dataSize)))
+		switch {
+		case prior == nil || d < distance:
+			prior, distance = &Prior{DataSize: obs.Experiment.DataSize}, d
+			throughput, wire = 0, 0
+		case obs.Experiment.DataSize != prior.DataSize || prior.Runs >= PriorRuns:
+			continue
+		}
+
+		prior.Runs++
+		throughput += obs.Latencies.Throughput
+		wire += obs.WireSize
+	}
+
+	if prior == nil {
+		return nil
+	}
+
+	prior.Throughput = throughput / float64(prior.Runs)
+	prior.WireSize = wire / float64(prior.Runs)
+	return prior
+}
+
+// Estimate is the projected outcome of a run. The throughput of the run is its target
+// rate, capped by the throughput of the prior if the prior is slower; if the run is not
+// rate limited and there is no prior, the throughput and duration of the run are
+// unknown and reported as zero. The cost guards of the options are applied so that the
+// estimate reflects the events that would be published before the run is stopped.
+type Estimate struct {
+	Benchmark    string
+	Operations   uint64
+	DataSize     int64
+	Rate         float64
+	Events       uint64
+	Throughput   float64
+	LimitedBy    string // either rate or prior
+	Duration     time.Duration
+	PayloadBytes uint64
+	WireBytes    uint64 // the serialized bytes if the prior reported the wire size
+	MBPerSec     float64
+	ExitReason   string
+	Prior        *Prior
+}
+
+// New estimates a run of the benchmark with the options. The rate of a sustain run is
+// derived from its interval if it is not specified.
+func New(benchmark string, opts *options.Options, prior *Prior) *Estimate {
+	e := &Estimate{
+		Benchmark:  benchmark,
+		Operations: opts.Operations,
+		DataSize:   opts.DataSize,
+		Rate:       opts.Rate,
+		Events:     opts.Operations,
+		ExitReason: benchmarks.ExitCompleted,
+		Prior:      prior,
+	}
+
+	if e.Rate == 0 && benchmark == "sustain" && opts.Interval > 0 {
+		e.Rate = float64(time.Second) / float64(opts.Interval)
+	}
+
+	// The event and byte guards are known before the run starts
+	if opts.MaxEvents > 0 && opts.MaxEvents < e.Events {
+		e.Events, e.ExitReason = opts.MaxEvents, benchmarks.ExitMaxEvents
+	}
+
+	if opts.MaxBytes > 0 && opts.DataSize > 0 {
+		if budget := opts.MaxBytes / uint64(opts.DataSize); budget < e.Events {
+			e.Events, e.ExitReason = budget, benchmarks.ExitMaxBytes
+		}
+	}
+
+	e.Throughput, e.LimitedBy = e.Rate, "rate"
+	if prior != nil && prior.Throughput > 0 && (e.Rate == 0 || prior.Throughput < e.Rate) {
+		e.Throughput, e.LimitedBy = prior.Throughput, "prior"
+	}
+
+	if e.Throughput > 0 {
+		e.Duration = time.Duration(float64(e.Events) / e.Throughput * float64(time.Second))
+		if opts.MaxDuration > 0 && e.Duration > opts.MaxDuration {
+			e.Events = uint64(e.Throughput * opts.MaxDuration.Seconds())
+			e.Duration, e.ExitReason = opts.MaxDuration, benchmarks.ExitMaxDuration
+		}
+	} else {
+		e.LimitedBy = ""
+	}
+
+	e.PayloadBytes = e.Events * uint64(e.DataSize)
+	size := float64(e.DataSize)
+	if prior != nil && prior.WireSize > 0 {
+		e.WireBytes = uint64(float64(e.Events) * prior.WireSize)
+		size = prior.WireSize
+	}
+	e.MBPerSec = e.Throughput * size / 1e6
+	return e
+}
+
+// Metrics returns the estimate as metrics so that it can be written by the reports.
+func (e *Estimate) Metrics() metrics.Metrics {
+	m := metrics.Metrics{
+		"operations":    e.Operations,
+		"events":        e.Events,
+		"payload_size":  e.DataSize,
+		"payload_bytes": e.PayloadBytes,
+		"exit_reason":   e.ExitReason,
+		"experiment": map[string]interface{}{
+			"benchmark": e.Benchmark,
+			"rate":      e.Rate,
+		},
+	}
+
+	if e.Throughput > 0 {
+		m["throughput"] = e.Throughput
+		m["limited_by"] = e.LimitedBy
+		m["duration"] = e.Duration.String()
+		m["mb_per_sec"] = e.MBPerSec
+	}
+
+	if e.WireBytes > 0 {
+		m["wire_bytes"] = e.WireBytes
+	}
+
+	if e.Prior != nil {
+		m["prior"] = e.Prior
+	}
+	return m
+}
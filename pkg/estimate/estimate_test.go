@@ -0,0 +1,91 @@
+package estimate_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/estimate"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	opts := options.New()
+	opts.Operations = 10000000
+	opts.DataSize = 8000
+	opts.Rate = 5000
+
+	e := estimate.New("blast", opts, nil)
+	require.Equal(t, uint64(10000000), e.Events)
+	require.Equal(t, 2000*time.Second, e.Duration)
+	require.Equal(t, uint64(80000000000), e.PayloadBytes)
+	require.Equal(t, 40.0, e.MBPerSec)
+	require.Equal(t, "rate", e.LimitedBy)
+	require.Equal(t, benchmarks.ExitCompleted, e.ExitReason)
+
+	// A slower prior caps the rate and the wire size of the prior is projected
+	prior := &estimate.Prior{Runs: 3, DataSize: 8000, Throughput: 2500, WireSize: 8100}
+	e = estimate.New("blast", opts, prior)
+	require.Equal(t, 4000*time.Second, e.Duration)
+	require.Equal(t, uint64(81000000000), e.WireBytes)
+	require.Equal(t, "prior", e.LimitedBy)
+
+	// The guards stop the run early
+	opts.MaxBytes = 8000 * 1000
+	e = estimate.New("blast", opts, nil)
+	require.Equal(t, uint64(1000), e.Events)
+	require.Equal(t, benchmarks.ExitMaxBytes, e.ExitReason)
+
+	opts.MaxBytes, opts.MaxDuration = 0, time.Minute
+	e = estimate.New("blast", opts, nil)
+	require.Equal(t, uint64(300000), e.Events)
+	require.Equal(t, time.Minute, e.Duration)
+	require.Equal(t, benchmarks.ExitMaxDuration, e.ExitReason)
+
+	// Without a rate or a prior the duration of the run is unknown
+	opts.Rate, opts.MaxDuration = 0, 0
+	e = estimate.New("blast", opts, nil)
+	require.Zero(t, e.Duration)
+	require.Equal(t, uint64(80000000000), e.PayloadBytes)
+	require.NotContains(t, e.Metrics(), "duration")
+
+	// Sustain runs are rate limited by their interval
+	opts.Interval = 10 * time.Millisecond
+	e = estimate.New("sustain", opts, nil)
+	require.Equal(t, 100.0, e.Rate)
+	require.Equal(t, 100000*time.Second, e.Duration)
+}
+
+func TestPriors(t *testing.T) {
+	run := func(endpoint string, size int64, throughput float64, emulated bool) *store.Run {
+		metrics := fmt.Sprintf(`{"latencies": {"throughput": %f}, "wire_size": %d, "experiment": {"endpoint": %q, "data_size": %d, "local_emulator": %t}}`, throughput, size+100, endpoint, size, emulated)
+		return &store.Run{Benchmark: "blast", Metrics: []byte(metrics)}
+	}
+
+	runs := []*store.Run{
+		run("ensign:443", 1024, 4000, false),
+		run("ensign:443", 8192, 3000, false),
+		run("ensign:443", 8192, 1000, false),
+		run("ensign:443", 8192, 9000, true),
+		run("staging:443", 8192, 9000, false),
+		run("ensign:443", 8192, 0, false),
+		{Benchmark: "blast"},
+	}
+
+	prior := estimate.Priors(runs, "ensign:443", 8000)
+	require.NotNil(t, prior)
+	require.Equal(t, 2, prior.Runs)
+	require.Equal(t, int64(8192), prior.DataSize)
+	require.Equal(t, 2000.0, prior.Throughput)
+	require.Equal(t, 8292.0, prior.WireSize)
+
+	// The nearest data size is used if no run has the same size
+	prior = estimate.Priors(runs, "ensign:443", 512)
+	require.Equal(t, int64(1024), prior.DataSize)
+	require.Equal(t, 1, prior.Runs)
+
+	require.Nil(t, estimate.Priors(runs, "localhost:5356", 8000))
+}
@@ -0,0 +1,68 @@
+package benchmarks
+
+import "io"
+
+// Events adapts the event factory of a benchmark to the Workload interface so that the
+// events of the benchmark can be generated outside of a run, e.g. to inspect or export
+// the workload. The factory is created when the workload is prepared and at most N
+// events are generated, or an unbounded number of events if N is zero. The closer of
+// the factory, e.g. of the file of a replayed workload, is closed when it is released.
+type Events[T any] struct {
+	Name    string
+	N       uint64
+	Factory func() (func() T, io.Closer, error)
+	next    func() T
+	closer  io.Closer
+	count   uint64
+	value   T
+}
+
+func (w *Events[T]) String() string {
+	return w.Name
+}
+
+// Prepare creates the event factory of the workload.
+func (w *Events[T]) Prepare() (err error) {
+	if err = w.Release(); err != nil {
+		return err
+	}
+
+	var (
+		next   func() T
+		closer io.Closer
+	)
+	if next, closer, err = w.Factory(); err != nil {
+		return err
+	}
+
+	w.next, w.closer, w.count = next, closer, 0
+	return nil
+}
+
+// Next generates the next event, returning false once N events have been generated or
+// if the workload has not been prepared.
+func (w *Events[T]) Next() bool {
+	if w.next == nil || (w.N > 0 && w.count >= w.N) {
+		return false
+	}
+
+	w.value = w.next()
+	w.count++
+	return true
+}
+
+// Value returns the last generated event.
+func (w *Events[T]) Value() interface{} {
+	return w.value
+}
+
+// Release the event factory and its closer.
+func (w *Events[T]) Release() (err error) {
+	if w.closer != nil {
+		err = w.closer.Close()
+	}
+
+	var zero T
+	w.next, w.closer, w.value = nil, nil, zero
+	return err
+}
@@ -0,0 +1,169 @@
+/*
+Package export writes the time series and raw samples of large benchmark runs to
+columnar files so they can be loaded efficiently into analysis tools such as DuckDB or
+Spark rather than exchanging multi-gigabyte JSON documents.
+*/
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// RowGroupSize is the target size in bytes of a parquet row group; rows are flushed
+// to the underlying writer once a row group is full so that memory remains bounded.
+const RowGroupSize = 64 * 1024 * 1024
+
+// Interval is a single row of an interval time series. Durations are stored as integer
+// nanoseconds so that they can be aggregated directly by analysis tools.
+type Interval struct {
+	Source     string  `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Sequence   int64   `parquet:"name=sequence, type=INT64"`
+	Start      int64   `parquet:"name=start, type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=NANOS"`
+	Duration   int64   `parquet:"name=duration_ns, type=INT64"`
+	Events     int64   `parquet:"name=events, type=INT64"`
+	Failures   int64   `parquet:"name=failures, type=INT64"`
+	Bytes      int64   `parquet:"name=bytes, type=INT64"`
+	Throughput float64 `parquet:"name=throughput, type=DOUBLE"`
+	Mean       int64   `parquet:"name=mean_ns, type=INT64"`
+	Fastest    int64   `parquet:"name=fastest_ns, type=INT64"`
+	Slowest    int64   `parquet:"name=slowest_ns, type=INT64"`
+	P99        int64   `parquet:"name=p99_ns, type=INT64"`
+}
+
+// Sample is a single raw latency measurement; a zero latency indicates the operation
+// did not receive a response and is recorded as a timeout.
+type Sample struct {
+	Index   int64 `parquet:"name=index, type=INT64"`
+	Latency int64 `parquet:"name=latency_ns, type=INT64"`
+	Timeout bool  `parquet:"name=timeout, type=BOOLEAN"`
+}
+
+// LatencyInterval creates an interval row from the latency statistics of a window; the
+// start timestamp and bytes of the interval must be set by the caller if known.
+func LatencyInterval(source string, sequence uint64, latencies *stats.Latencies) Interval {
+	return Interval{
+		Source:     source,
+		Sequence:   int64(sequence),
+		Duration:   int64(latencies.Duration()),
+		Events:     int64(latencies.N()),
+		Failures:   int64(latencies.Timeouts()),
+		Throughput: latencies.Throughput(),
+		Mean:       int64(latencies.Mean()),
+		Fastest:    int64(latencies.Fastest()),
+		Slowest:    int64(latencies.Slowest()),
+		P99:        int64(latencies.Percentile(99)),
+	}
+}
+
+// ClusterIntervals converts the cluster-wide aggregation of a distributed run into
+// interval rows.
+func ClusterIntervals(intervals []distributed.ClusterInterval) []Interval {
+	rows := make([]Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		rows = append(rows, Interval{
+			Source:     "cluster",
+			Sequence:   int64(interval.Sequence),
+			Throughput: interval.AchievedRate,
+			P99:        int64(interval.P99),
+		})
+	}
+	return rows
+}
+
+// WriteIntervals writes the interval time series to w as a zstd compressed parquet file.
+func WriteIntervals(w io.Writer, rows []Interval) (err error) {
+	var pw *writer.ParquetWriter
+	if pw, err = newWriter(w, new(Interval)); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err = pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
+
+// WriteSamples writes the raw latencies to w as a zstd compressed parquet file; rows are
+// streamed as row groups fill rather than materializing all rows in memory.
+func WriteSamples(w io.Writer, latencies []time.Duration) (err error) {
+	var sw *SampleWriter
+	if sw, err = NewSampleWriter(w); err != nil {
+		return err
+	}
+
+	for _, latency := range latencies {
+		sw.Write(latency)
+	}
+	return sw.Close()
+}
+
+// SampleWriter streams raw latencies to a zstd compressed parquet file as they are
+// measured so that the samples of a run do not have to be retained until it is over.
+type SampleWriter struct {
+	pw    *writer.ParquetWriter
+	index int64
+	err   error
+}
+
+// NewSampleWriter returns a writer of the raw latencies to w.
+func NewSampleWriter(w io.Writer) (_ *SampleWriter, err error) {
+	var pw *writer.ParquetWriter
+	if pw, err = newWriter(w, new(Sample)); err != nil {
+		return nil, err
+	}
+	return &SampleWriter{pw: pw}, nil
+}
+
+// Write the next latency; the first error is returned by Close and subsequent samples
+// are discarded so that Write can be used as a callback.
+func (s *SampleWriter) Write(latency time.Duration) {
+	if s.err != nil {
+		return
+	}
+
+	s.err = s.pw.Write(Sample{Index: s.index, Latency: int64(latency), Timeout: latency == 0})
+	s.index++
+}
+
+// Close flushes the remaining rows and the footer of the parquet file.
+func (s *SampleWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.pw.WriteStop()
+}
+
+// WriteFile creates the file at path and calls the write function, e.g. to export
+// samples with export.WriteFile(path, func(w io.Writer) error { ... }). If the path has
+// the zstd extension the whole file is compressed as it is written.
+func WriteFile(path string, write func(io.Writer) error) (err error) {
+	var f io.WriteCloser
+	if f, err = compress.Create(path); err != nil {
+		return err
+	}
+
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func newWriter(w io.Writer, obj interface{}) (pw *writer.ParquetWriter, err error) {
+	if pw, err = writer.NewParquetWriterFromWriter(w, obj, 4); err != nil {
+		return nil, err
+	}
+
+	pw.RowGroupSize = RowGroupSize
+	pw.CompressionType = parquet.CompressionCodec_ZSTD
+	return pw, nil
+}
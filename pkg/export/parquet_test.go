@@ -0,0 +1,72 @@
+package export_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/distributed"
+	"github.com/rotationalio/ensign-benchmarks/pkg/export"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteSamples(t *testing.T) {
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		if i%100 != 0 {
+			latencies[i] = time.Duration(i) * time.Microsecond
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, export.WriteSamples(buf, latencies))
+
+	rows := readRows[export.Sample](t, buf)
+	require.Len(t, rows, len(latencies))
+
+	require.Equal(t, export.Sample{Index: 0, Latency: 0, Timeout: true}, rows[0])
+	require.Equal(t, export.Sample{Index: 42, Latency: int64(42 * time.Microsecond)}, rows[42])
+	require.Equal(t, int64(len(latencies)-1), rows[len(rows)-1].Index)
+}
+
+func TestWriteIntervals(t *testing.T) {
+	latencies := &stats.Latencies{}
+	latencies.Update(10*time.Millisecond, 20*time.Millisecond, 0)
+	latencies.SetDuration(time.Second)
+
+	window := export.LatencyInterval("retention", 1, latencies)
+	window.Bytes = 2048
+
+	intervals := append([]export.Interval{window}, export.ClusterIntervals([]distributed.ClusterInterval{
+		{Sequence: 2, Workers: 3, AchievedRate: 1200, P99: 80 * time.Millisecond},
+	})...)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, export.WriteIntervals(buf, intervals))
+
+	rows := readRows[export.Interval](t, buf)
+	require.Equal(t, intervals, rows)
+
+	require.Equal(t, int64(2), rows[0].Events)
+	require.Equal(t, int64(1), rows[0].Failures)
+	require.Equal(t, int64(15*time.Millisecond), rows[0].Mean)
+	require.Positive(t, rows[0].P99, "the p99 of latency windows should be exported")
+	require.LessOrEqual(t, rows[0].P99, rows[0].Slowest)
+	require.Equal(t, "cluster", rows[1].Source)
+}
+
+func readRows[T any](t *testing.T, buf *bytes.Buffer) []T {
+	pf, err := buffer.NewBufferFile(buf.Bytes())
+	require.NoError(t, err)
+
+	pr, err := reader.NewParquetReader(pf, new(T), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+
+	rows := make([]T, pr.GetNumRows())
+	require.NoError(t, pr.Read(&rows))
+	return rows
+}
@@ -0,0 +1,98 @@
+/*
+Package identity authenticates the configured API key credentials with Quarterdeck and
+parses the claims from the access token. Permission mismatches are a frequent cause of
+failed benchmarks, so this package makes it possible to determine what the benchmark
+client is allowed to do before a run is started.
+*/
+package identity
+
+import (
+	"context"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rotationalio/go-ensign/auth"
+)
+
+// Permissions required by the benchmarks to run against a topic.
+const (
+	PermPublisher    = "publisher"
+	PermSubscriber   = "subscriber"
+	PermReadTopics   = "topics:read"
+	PermCreateTopics = "topics:create"
+)
+
+// Required is the set of permissions that the benchmarks expect the API key to have.
+var Required = []string{PermPublisher, PermSubscriber, PermReadTopics, PermCreateTopics}
+
+// Claims are the Quarterdeck access token claims relevant to running benchmarks.
+type Claims struct {
+	jwt.RegisteredClaims
+	OrgID       string   `json:"org,omitempty"`
+	ProjectID   string   `json:"project,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+var parser = &jwt.Parser{SkipClaimsValidation: true}
+
+// Authenticate logs into Quarterdeck using the credentials from the options (or the
+// environment) and returns the claims of the access token that was issued.
+func Authenticate(ctx context.Context, opts *options.Options) (_ *Claims, err error) {
+	var conf ensign.Options
+	if conf, err = ensign.NewOptions(opts.Ensign()...); err != nil {
+		return nil, err
+	}
+
+	var client *auth.Client
+	if client, err = auth.New(conf.AuthURL, conf.Insecure); err != nil {
+		return nil, err
+	}
+
+	var tokens *auth.Tokens
+	if tokens, err = client.Authenticate(ctx, &auth.APIKey{ClientID: conf.ClientID, ClientSecret: conf.ClientSecret}); err != nil {
+		return nil, err
+	}
+
+	return Parse(tokens.AccessToken)
+}
+
+// Parse the claims from an access token without verifying the signature; the token is
+// only inspected by the client, authorization is still performed by the server.
+func Parse(tks string) (claims *Claims, err error) {
+	claims = &Claims{}
+	if _, _, err = parser.ParseUnverified(tks, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// HasPermission checks if the claims contain the specified permission.
+func (c *Claims) HasPermission(permission string) bool {
+	for _, perm := range c.Permissions {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns any of the required benchmark permissions not held by the claims.
+func (c *Claims) Missing() []string {
+	missing := make([]string, 0)
+	for _, perm := range Required {
+		if !c.HasPermission(perm) {
+			missing = append(missing, perm)
+		}
+	}
+	return missing
+}
+
+// Expires returns the expiration timestamp of the access token.
+func (c *Claims) Expires() time.Time {
+	if c.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return c.ExpiresAt.Time
+}
@@ -0,0 +1,225 @@
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rotationalio/go-ensign/auth"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TokenMonitor authenticates the RPCs of an Ensign client in place of the SDK's own
+// interceptors so that every access token refresh can be observed. Like the SDK, the
+// credentials are checked before every RPC and expired access tokens are refreshed (or
+// the API key is reauthenticated) synchronously, blocking the RPC that needed them; the
+// monitor records when each refresh happened and how long the RPC was blocked.
+type TokenMonitor struct {
+	sync.Mutex
+	client    *auth.Client
+	insecure  bool
+	token     string
+	expires   time.Time
+	lifetime  time.Duration
+	refreshes []Refresh
+	failures  uint64
+}
+
+// Refresh records an access token refresh triggered by an RPC.
+type Refresh struct {
+	At       time.Time     // when the RPC requested credentials
+	Duration time.Duration // how long the RPC was blocked until the new token was issued
+	Expired  time.Time     // when the previous access token expired
+}
+
+// NewTokenMonitor logs into Quarterdeck with the API key credentials from the options
+// (or the environment).
+func NewTokenMonitor(ctx context.Context, opts *options.Options) (_ *TokenMonitor, err error) {
+	var conf ensign.Options
+	if conf, err = ensign.NewOptions(opts.Ensign()...); err != nil {
+		return nil, err
+	}
+	return Login(ctx, conf.AuthURL, conf.ClientID, conf.ClientSecret, conf.Insecure)
+}
+
+// Login to the Quarterdeck server at the URL with an API key; if insecure is true the
+// credentials can be sent to an Ensign server without TLS, e.g. a local emulator.
+func Login(ctx context.Context, authURL, clientID, clientSecret string, insecure bool) (m *TokenMonitor, err error) {
+	m = &TokenMonitor{insecure: insecure}
+	if m.client, err = auth.New(authURL, insecure); err != nil {
+		return nil, err
+	}
+
+	var creds credentials.PerRPCCredentials
+	if creds, err = m.client.Login(ctx, clientID, clientSecret); err != nil {
+		return nil, err
+	}
+
+	if _, err = m.update(ctx, creds); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Option configures an Ensign client to authenticate its RPCs with the monitor. The
+// dial options replace the defaults of the SDK, so the transport and user agent that
+// the SDK would use are configured as well. Note that a mock reuses the connection it
+// was first dialed with, so a mock must be reset with the DialOptions instead.
+func (m *TokenMonitor) Option() ensign.Option {
+	return func(o *ensign.Options) error {
+		o.Dialing = m.dialOptions(o.Testing || o.Insecure || m.insecure)
+		return nil
+	}
+}
+
+// DialOptions returns the gRPC dial options that authenticate RPCs with the monitor.
+func (m *TokenMonitor) DialOptions() []grpc.DialOption {
+	return m.dialOptions(m.insecure)
+}
+
+func (m *TokenMonitor) dialOptions(insecureTransport bool) []grpc.DialOption {
+	transport := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	if insecureTransport {
+		transport = grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+
+	return []grpc.DialOption{
+		transport,
+		grpc.WithUnaryInterceptor(m.UnaryInterceptor),
+		grpc.WithStreamInterceptor(m.StreamInterceptor),
+		grpc.WithUserAgent(fmt.Sprintf(ensign.UserAgent, ensign.VersionMajor)),
+	}
+}
+
+// UnaryInterceptor adds credentials to every unary RPC made by the client.
+func (m *TokenMonitor) UnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+	var creds credentials.PerRPCCredentials
+	if creds, err = m.Credentials(ctx); err != nil {
+		return err
+	}
+
+	opts = append(opts, grpc.PerRPCCredentials(creds))
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// StreamInterceptor adds credentials to every stream opened by the client.
+func (m *TokenMonitor) StreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (_ grpc.ClientStream, err error) {
+	var creds credentials.PerRPCCredentials
+	if creds, err = m.Credentials(ctx); err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, grpc.PerRPCCredentials(creds))
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+// Credentials returns the credentials for an RPC, refreshing the access token if it has
+// expired and recording the refresh. The auth client is not safe for concurrent use so
+// RPCs that request credentials at the same time wait for each other.
+func (m *TokenMonitor) Credentials(ctx context.Context) (creds credentials.PerRPCCredentials, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	requested := time.Now()
+	if creds, err = m.client.Credentials(ctx); err != nil {
+		m.failures++
+		return nil, err
+	}
+	blocked := time.Since(requested)
+
+	expired := m.expires
+	var refreshed bool
+	if refreshed, err = m.update(ctx, creds); err != nil {
+		m.failures++
+		return nil, err
+	}
+
+	if refreshed {
+		m.refreshes = append(m.refreshes, Refresh{At: requested, Duration: blocked, Expired: expired})
+		log.Debug().Dur("blocked", blocked).Time("expired", expired).Msg("access token refreshed")
+	}
+	return creds, nil
+}
+
+// Stores the access token of the credentials, returning true if it replaced a different
+// token, i.e. if the access token was refreshed. Must be called with the lock held.
+func (m *TokenMonitor) update(ctx context.Context, creds credentials.PerRPCCredentials) (refreshed bool, err error) {
+	var md map[string]string
+	if md, err = creds.GetRequestMetadata(ctx); err != nil {
+		return false, err
+	}
+
+	token := strings.TrimPrefix(md["Authorization"], "Bearer ")
+	if token == "" {
+		return false, errors.New("credentials do not contain an access token")
+	}
+
+	if token == m.token {
+		return false, nil
+	}
+
+	var claims *Claims
+	if claims, err = Parse(token); err != nil {
+		return false, err
+	}
+
+	refreshed = m.token != ""
+	m.token, m.expires = token, claims.Expires()
+	if claims.IssuedAt != nil {
+		m.lifetime = m.expires.Sub(claims.IssuedAt.Time)
+	}
+	return refreshed, nil
+}
+
+// Poll makes a status RPC on the client at the specified interval until the context is
+// canceled, so that the credentials are checked and expired tokens are refreshed while
+// the long running streams of the client do not make new RPCs.
+func (m *TokenMonitor) Poll(ctx context.Context, client *ensign.Client, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := client.Status(ctx); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Msg("could not check credentials with a status rpc")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Refreshes returns the access token refreshes observed so far.
+func (m *TokenMonitor) Refreshes() []Refresh {
+	m.Lock()
+	defer m.Unlock()
+
+	refreshes := make([]Refresh, len(m.refreshes))
+	copy(refreshes, m.refreshes)
+	return refreshes
+}
+
+// Failures returns the number of RPCs that could not obtain credentials.
+func (m *TokenMonitor) Failures() uint64 {
+	m.Lock()
+	defer m.Unlock()
+	return m.failures
+}
+
+// Expires returns when the current access token expires and the lifetime of the token
+// as issued by Quarterdeck.
+func (m *TokenMonitor) Expires() (expires time.Time, lifetime time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	return m.expires, m.lifetime
+}
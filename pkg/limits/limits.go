@@ -0,0 +1,6 @@
+/*
+Package limits implements probes that empirically discover the limits that the server
+or project enforce on clients (e.g. publish rate limits) so that users can plan their
+experiments within their quotas.
+*/
+package limits
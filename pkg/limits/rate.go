@@ -0,0 +1,295 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ratelimit"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults for the rate limit probe.
+const (
+	DefaultStartRate         = 100.0
+	DefaultMaxRate           = 10000.0
+	DefaultRateFactor        = 1.5
+	DefaultStepDuration      = 10 * time.Second
+	DefaultThrottleThreshold = 0.01
+)
+
+// How long to wait for acks of events published during a step once the step is over.
+const stepDrain = 5 * time.Second
+
+// RateProbe publishes to the topic at geometrically increasing rates for a brief step at
+// each rate until the server begins throttling the client or the maximum rate is
+// reached. A step is throttled if the server returns resource exhausted errors or if
+// the fraction of failed publishes exceeds the throttle threshold.
+type RateProbe struct {
+	StartRate float64
+	MaxRate   float64
+	Factor    float64
+	Step      time.Duration
+	Threshold float64
+
+	opts     *options.Options
+	client   *ensign.Client
+	steps    []*RateStep
+	limit    float64 // the highest rate that was not throttled
+	hit      float64 // the lowest rate that was throttled
+	reason   string
+	budget   *workload.Budget
+	progress stats.Progress
+}
+
+// RateStep records the outcome of publishing at a single target rate.
+type RateStep struct {
+	Target    float64           `json:"target_rate"`
+	Achieved  float64           `json:"achieved_rate"`
+	Published uint64            `json:"published"`
+	Acked     uint64            `json:"acked"`
+	Nacked    uint64            `json:"nacked"`
+	Errors    uint64            `json:"errors"`
+	Exhausted uint64            `json:"resource_exhausted"`
+	Timeouts  uint64            `json:"timeouts"` // events still unacked once the step was drained
+	Codes     map[string]uint64 `json:"codes,omitempty"`
+	Throttled bool              `json:"throttled"`
+}
+
+func NewRateProbe(opts *options.Options) *RateProbe {
+	return &RateProbe{
+		StartRate: DefaultStartRate,
+		MaxRate:   DefaultMaxRate,
+		Factor:    DefaultRateFactor,
+		Step:      DefaultStepDuration,
+		Threshold: DefaultThrottleThreshold,
+		opts:      opts,
+	}
+}
+
+func (b *RateProbe) Run(ctx context.Context) (err error) {
+	if b.StartRate <= 0 || b.MaxRate < b.StartRate {
+		return errors.New("the start rate must be positive and no greater than the max rate")
+	}
+
+	if b.Factor <= 1 {
+		return errors.New("the rate factor must be greater than one")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	// The probe cannot detect limits above the rate at which the client can generate events
+	warmup, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	b.budget = workload.MeasureBudget(workload.DefaultWarmup, b.MaxRate, func() { warmup() })
+	closer.Close()
+	b.budget.Check()
+
+	b.steps = make([]*RateStep, 0)
+	b.limit, b.hit = 0, 0
+	b.reason = benchmarks.ExitCompleted
+
+	started := time.Now()
+	b.progress.Start()
+	published := uint64(0)
+	for rate := b.StartRate; rate <= b.MaxRate; rate *= b.Factor {
+		if reason := b.opts.Exhausted(started, published); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		b.progress.Set("target_rate", uint64(rate))
+		var step *RateStep
+		if step, err = b.run(ctx, rate); err != nil {
+			return err
+		}
+		b.steps = append(b.steps, step)
+		published += step.Published
+		b.progress.Add("steps", 1)
+
+		log.Info().
+			Float64("target_rate", step.Target).
+			Float64("achieved_rate", step.Achieved).
+			Uint64("nacked", step.Nacked).
+			Uint64("errors", step.Errors).
+			Uint64("timeouts", step.Timeouts).
+			Bool("throttled", step.Throttled).
+			Msg("rate limit probe step complete")
+
+		if step.Throttled {
+			b.hit = step.Target
+			break
+		}
+		b.limit = step.Target
+		b.progress.Set("limit_rate", uint64(step.Target))
+	}
+	return nil
+}
+
+// Publishes at the target rate for the duration of a step and waits for acks.
+func (b *RateProbe) run(ctx context.Context, rate float64) (step *RateStep, err error) {
+	step = &RateStep{Target: rate, Codes: make(map[string]uint64)}
+	limiter := ratelimit.New(rate, rate/10)
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	inflight := make([]*ensign.Event, 0, int(rate*b.Step.Seconds()))
+
+	stepctx, cancel := context.WithTimeout(ctx, b.Step)
+	defer cancel()
+
+publish:
+	for {
+		if _, err = limiter.Wait(stepctx); err != nil {
+			break publish
+		}
+
+		event := factory()
+		step.Published++
+		b.progress.Add("published", 1)
+		if err = b.client.Publish(b.opts.TopicRef(), event); err != nil {
+			step.record(err, false)
+			b.progress.Add("errors", 1)
+			continue
+		}
+		inflight = append(inflight, event)
+	}
+
+	// The step is over when its context expires; any other error is returned
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(stepDrain)
+	for len(inflight) > 0 {
+		pending := inflight[:0]
+		for _, event := range inflight {
+			if acked, _ := event.Acked(); acked {
+				step.Acked++
+				continue
+			}
+
+			if nacked, nerr := event.Nacked(); nacked {
+				step.Nacked++
+				step.record(nerr, true)
+				continue
+			}
+			pending = append(pending, event)
+		}
+		inflight = pending
+
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The drain is not publishing time so the achieved rate is over the step duration
+	step.Timeouts = uint64(len(inflight))
+	step.Achieved = float64(step.Acked) / b.Step.Seconds()
+	step.Throttled = step.throttled(b.Threshold)
+	return step, nil
+}
+
+// Records the status code of a failed publish or the error of a nacked event.
+func (s *RateStep) record(err error, nack bool) {
+	if !nack {
+		s.Errors++
+	}
+
+	if err == nil {
+		return
+	}
+
+	code := status.Code(err)
+	if code == codes.ResourceExhausted {
+		s.Exhausted++
+	}
+
+	key := code.String()
+	if _, ok := status.FromError(err); !ok {
+		key = err.Error()
+	}
+	s.Codes[key]++
+}
+
+// Failures returns the number of events published during the step that were not acked,
+// whether the publish failed, the event was nacked, or the ack timed out.
+func (s *RateStep) Failures() uint64 {
+	return s.Published - s.Acked
+}
+
+// A step is throttled if the server signals resource exhaustion or if the fraction of
+// publishes that were not acked exceeds the threshold.
+func (s *RateStep) throttled(threshold float64) bool {
+	if s.Exhausted > 0 {
+		return true
+	}
+
+	if s.Published == 0 {
+		return false
+	}
+	return float64(s.Failures())/float64(s.Published) > threshold
+}
+
+// Progress returns the number of steps completed and events published so far along with
+// the target rate of the current step and the highest rate that was not throttled.
+func (b *RateProbe) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+func (b *RateProbe) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["steps"] = b.steps
+	results["detected"] = b.hit > 0
+	results["max_unthrottled_rate"] = b.limit
+	results["throttled_rate"] = b.hit
+
+	maxAchieved := 0.0
+	for _, step := range b.steps {
+		if step.Achieved > maxAchieved {
+			maxAchieved = step.Achieved
+		}
+	}
+	results["max_achieved_rate"] = maxAchieved
+	results["exit_reason"] = b.reason
+
+	experiment := map[string]interface{}{
+		"client_version":     benchmarks.Version(),
+		"endpoint":           b.opts.Endpoint,
+		"topic":              b.opts.Topic,
+		"data_size":          b.opts.DataSize,
+		"start_rate":         b.StartRate,
+		"max_rate":           b.MaxRate,
+		"factor":             b.Factor,
+		"step":               b.Step.String(),
+		"throttle_threshold": b.Threshold,
+		"guard":              b.opts.Guard(),
+		"generation":         b.budget,
+		"minimal_metadata":   b.opts.MinimalMetadata,
+	}
+
+	// The wire size is measured from an event of the workload of the run
+	if event, err := sustain.SampleEvent(b.opts); err == nil {
+		experiment["wire_size"] = sustain.WireSize(event)
+	}
+	results["experiment"] = experiment
+	return results, nil
+}
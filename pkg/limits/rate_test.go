@@ -0,0 +1,46 @@
+package limits
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateStepThrottled(t *testing.T) {
+	step := &RateStep{Published: 1000, Acked: 1000, Codes: make(map[string]uint64)}
+	require.False(t, step.throttled(DefaultThrottleThreshold))
+
+	// Nacks below the threshold do not indicate throttling
+	for i := 0; i < 5; i++ {
+		step.Nacked++
+		step.Acked--
+		step.record(errors.New("[INTERNAL] could not commit event"), true)
+	}
+	require.Equal(t, uint64(0), step.Errors)
+	require.Equal(t, uint64(5), step.Codes["[INTERNAL] could not commit event"])
+	require.False(t, step.throttled(DefaultThrottleThreshold))
+
+	// Publish errors above the threshold indicate throttling
+	for i := 0; i < 10; i++ {
+		step.Acked--
+		step.record(status.Error(codes.Unavailable, "try again later"), false)
+	}
+	require.Equal(t, uint64(10), step.Codes["Unavailable"])
+	require.Equal(t, uint64(15), step.Failures())
+	require.True(t, step.throttled(DefaultThrottleThreshold))
+
+	// Events that were never acked or nacked count as failures
+	step = &RateStep{Published: 1000, Acked: 980, Timeouts: 20, Codes: make(map[string]uint64)}
+	require.True(t, step.throttled(DefaultThrottleThreshold))
+
+	// Any resource exhausted error indicates throttling
+	step = &RateStep{Published: 1000, Acked: 999, Codes: make(map[string]uint64)}
+	step.record(status.Error(codes.ResourceExhausted, "rate limit exceeded"), false)
+	require.Equal(t, uint64(1), step.Exhausted)
+	require.True(t, step.throttled(DefaultThrottleThreshold))
+
+	require.False(t, (&RateStep{}).throttled(DefaultThrottleThreshold))
+}
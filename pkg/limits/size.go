@@ -0,0 +1,379 @@
+package limits
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Defaults for the size limit probe.
+const (
+	DefaultMinSize      = 1024
+	DefaultMaxSize      = 64 * 1024 * 1024
+	DefaultPrecision    = 1024
+	DefaultReplyTimeout = 10 * time.Second
+)
+
+// Dimensions of the events probed by the size limit probe.
+const (
+	PayloadDimension  = "payload"
+	MetadataDimension = "metadata"
+)
+
+// The size of the data of the events published to probe the metadata size limit.
+const metadataProbeData = 64
+
+// SizeProbe discovers the largest event payload and metadata the server accepts by
+// publishing single events of doubling sizes until an event is rejected and then
+// bisecting between the largest accepted and the smallest rejected size. Each event is
+// published on its own stream so that a stream that is aborted by an oversized message
+// does not affect the next attempt. An event is rejected if it is nacked, if the stream
+// returns an error, or if no reply is received within the reply timeout.
+type SizeProbe struct {
+	MinSize   int           // the size in bytes of the first event published
+	MaxSize   int           // the largest size in bytes that is probed
+	Precision int           // stop bisecting once the limit is known within this many bytes
+	Timeout   time.Duration // how long to wait for the reply to each event
+	Metadata  bool          // probe the metadata size limit as well as the payload limit
+
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	limits   []*SizeLimit
+	reason   string
+	progress stats.Progress
+}
+
+// SizeLimit records the outcome of probing a single dimension of the events.
+type SizeLimit struct {
+	Dimension string            // the part of the event that was probed, payload or metadata
+	Accepted  int               // the largest size that was accepted
+	Rejected  int               // the smallest size that was rejected
+	Detected  bool              // false if every size up to the max size was accepted
+	Codes     map[string]uint64 // the number of rejections by the code returned
+	Attempts  []*SizeAttempt    // every event published, in order
+	Slowest   time.Duration     // the slowest reply to an accepted event
+	Duration  time.Duration     // the time taken to probe the dimension
+}
+
+// Serializes the limit into a JSON map with durations as strings.
+func (l *SizeLimit) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["dimension"] = l.Dimension
+	data["max_accepted"] = l.Accepted
+	data["detected"] = l.Detected
+	data["codes"] = l.Codes
+	data["attempts"] = l.Attempts
+	data["slowest_accept"] = l.Slowest.String()
+	data["duration"] = l.Duration.String()
+
+	if l.Detected {
+		data["min_rejected"] = l.Rejected
+		data["precision"] = l.Rejected - l.Accepted
+	}
+	return json.Marshal(data)
+}
+
+// SizeAttempt records the outcome of publishing a single event of the specified size.
+type SizeAttempt struct {
+	Size     int    `json:"size"`
+	Accepted bool   `json:"accepted"`
+	Code     string `json:"code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func NewSizeProbe(opts *options.Options) *SizeProbe {
+	return &SizeProbe{
+		MinSize:   DefaultMinSize,
+		MaxSize:   DefaultMaxSize,
+		Precision: DefaultPrecision,
+		Timeout:   DefaultReplyTimeout,
+		Metadata:  true,
+		opts:      opts,
+	}
+}
+
+func (b *SizeProbe) Run(ctx context.Context) (err error) {
+	if b.MinSize < 1 || b.MaxSize < b.MinSize {
+		return errors.New("the min size must be positive and no greater than the max size")
+	}
+
+	if b.Precision < 1 {
+		return errors.New("the precision must be positive")
+	}
+
+	if b.Timeout <= 0 {
+		return errors.New("the reply timeout must be positive")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	dimensions := []string{PayloadDimension}
+	if b.Metadata {
+		dimensions = append(dimensions, MetadataDimension)
+	}
+
+	b.limits = make([]*SizeLimit, 0, len(dimensions))
+	b.reason = benchmarks.ExitCompleted
+	b.progress.Start()
+	for _, dimension := range dimensions {
+		var limit *SizeLimit
+		if limit, err = b.probe(ctx, dimension); err != nil {
+			if ctx.Err() != nil {
+				b.reason = benchmarks.ExitCanceled
+			}
+			return fmt.Errorf("probe %s size: %w", dimension, err)
+		}
+		b.limits = append(b.limits, limit)
+		b.progress.Add("dimensions", 1)
+
+		log.Info().
+			Str("dimension", dimension).
+			Int("max_accepted", limit.Accepted).
+			Int("min_rejected", limit.Rejected).
+			Bool("detected", limit.Detected).
+			Msg("size limit probe complete")
+	}
+	return nil
+}
+
+// Doubles the size of the dimension until an event is rejected, then bisects between
+// the largest accepted and smallest rejected sizes until the limit is within precision.
+func (b *SizeProbe) probe(ctx context.Context, dimension string) (limit *SizeLimit, err error) {
+	limit = &SizeLimit{Dimension: dimension, Codes: make(map[string]uint64)}
+	started := time.Now()
+
+	attempt := func(size int) (accepted bool, err error) {
+		var result *SizeAttempt
+		var reply time.Duration
+		if result, reply, err = b.publish(ctx, dimension, size); err != nil {
+			return false, err
+		}
+
+		limit.Attempts = append(limit.Attempts, result)
+		b.progress.Add("attempts", 1)
+		b.progress.Set("size", uint64(size))
+		if !result.Accepted {
+			b.progress.Add("rejected", 1)
+			limit.Codes[result.Code]++
+		} else if reply > limit.Slowest {
+			limit.Slowest = reply
+		}
+
+		log.Debug().
+			Str("dimension", dimension).
+			Int("size", size).
+			Bool("accepted", result.Accepted).
+			Str("code", result.Code).
+			Msg("size probe attempt")
+		return result.Accepted, nil
+	}
+
+	lo, hi := 0, 0
+	for size := b.MinSize; ; size *= 2 {
+		if size > b.MaxSize {
+			size = b.MaxSize
+		}
+
+		var accepted bool
+		if accepted, err = attempt(size); err != nil {
+			return nil, err
+		}
+
+		if !accepted {
+			hi = size
+			break
+		}
+
+		if lo = size; size >= b.MaxSize {
+			break
+		}
+	}
+
+	for hi > 0 && hi-lo > b.Precision {
+		mid := lo + (hi-lo)/2
+		var accepted bool
+		if accepted, err = attempt(mid); err != nil {
+			return nil, err
+		}
+
+		if accepted {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	limit.Accepted, limit.Rejected, limit.Detected = lo, hi, hi > 0
+	limit.Duration = time.Since(started)
+	return limit, nil
+}
+
+// Publishes a single event with the dimension of the specified size on a new stream and
+// waits for the reply. Errors are only returned if the probe cannot continue; rejections
+// of the event by the server are recorded in the attempt.
+func (b *SizeProbe) publish(parent context.Context, dimension string, size int) (result *SizeAttempt, reply time.Duration, err error) {
+	result = &SizeAttempt{Size: size}
+
+	ctx, cancel := context.WithTimeout(parent, b.Timeout)
+	defer cancel()
+
+	var stream api.Ensign_PublishClient
+	if stream, err = b.open(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	var event *api.EventWrapper
+	if event, err = b.event(dimension, size); err != nil {
+		return nil, 0, err
+	}
+
+	sent := time.Now()
+	if err = stream.Send(&api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: event}}); err == nil {
+		var rep *api.PublisherReply
+		if rep, err = stream.Recv(); err == nil {
+			reply = time.Since(sent)
+			switch {
+			case rep.GetAck() != nil:
+				result.Accepted = true
+			case rep.GetNack() != nil:
+				result.Code = rep.GetNack().Code.String()
+				result.Error = rep.GetNack().Error
+			default:
+				result.Code = "unexpected reply"
+			}
+			stream.CloseSend()
+			return result, reply, nil
+		}
+	}
+
+	// The stream was aborted by the server (e.g. the message exceeded the gRPC limit)
+	if parent.Err() != nil {
+		return nil, 0, parent.Err()
+	}
+
+	result.Code, result.Error = status.Code(err).String(), status.Convert(err).Message()
+	return result, 0, nil
+}
+
+// Opens a publish stream and waits for the server to signal that the stream is ready.
+func (b *SizeProbe) open(ctx context.Context) (stream api.Ensign_PublishClient, err error) {
+	if stream, err = b.client.PublishStream(ctx); err != nil {
+		return nil, err
+	}
+
+	req := &api.PublisherRequest{
+		Embed: &api.PublisherRequest_OpenStream{
+			OpenStream: &api.OpenStream{
+				ClientId: fmt.Sprintf("benchmarks-%s", ulid.Make()),
+			},
+		},
+	}
+
+	if err = stream.Send(req); err != nil {
+		return nil, err
+	}
+
+	var rep *api.PublisherReply
+	if rep, err = stream.Recv(); err != nil {
+		return nil, err
+	}
+
+	if rep.GetReady() == nil {
+		return nil, errors.New("did not get publisher ready message")
+	}
+	return stream, nil
+}
+
+// Returns an event whose payload or metadata value is the specified number of bytes.
+func (b *SizeProbe) event(dimension string, size int) (_ *api.EventWrapper, err error) {
+	payload := &workload.Random{}
+	event := &api.Event{
+		Mimetype: payload.Mimetype(),
+		Type:     payload.Type(),
+		Created:  timestamppb.Now(),
+	}
+
+	switch dimension {
+	case PayloadDimension:
+		event.Data = payload.Next(size)
+	case MetadataDimension:
+		event.Data = payload.Next(metadataProbeData)
+		event.Metadata = map[string]string{"probe": strings.Repeat("x", size)}
+	default:
+		return nil, fmt.Errorf("unknown size dimension %q", dimension)
+	}
+
+	wrap := &api.EventWrapper{
+		TopicId: b.topicID.Bytes(),
+		LocalId: ulid.Make().Bytes(),
+	}
+	if err = wrap.Wrap(event); err != nil {
+		return nil, err
+	}
+	return wrap, nil
+}
+
+// Progress returns the number of dimensions probed and events published so far along
+// with the size of the last event published.
+func (b *SizeProbe) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// Limits returns the limits discovered for each dimension by the last run.
+func (b *SizeProbe) Limits() []*SizeLimit {
+	return b.limits
+}
+
+func (b *SizeProbe) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["limits"] = b.limits
+	for _, limit := range b.limits {
+		results[limit.Dimension+"_max_accepted"] = limit.Accepted
+		results[limit.Dimension+"_detected"] = limit.Detected
+	}
+	results["exit_reason"] = b.reason
+
+	results["experiment"] = map[string]interface{}{
+		"client_version": benchmarks.Version(),
+		"endpoint":       b.opts.Endpoint,
+		"topic":          b.opts.Topic,
+		"topic_id":       b.topicID.String(),
+		"resolved_by_id": b.opts.TopicID != "",
+		"min_size":       b.MinSize,
+		"max_size":       b.MaxSize,
+		"precision":      b.Precision,
+		"reply_timeout":  b.Timeout.String(),
+		"metadata":       b.Metadata,
+	}
+	return results, nil
+}
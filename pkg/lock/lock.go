@@ -0,0 +1,172 @@
+/*
+Package lock coordinates benchmark runs on the same host so that two runs do not
+unknowingly load the same topic of the same endpoint at the same time and corrupt each
+other's measurements. A run takes a lockfile named for the endpoint and topic that
+records which process holds it; a second run that finds the lock held by a live
+process either aborts or, when forced, proceeds and records that the run was
+concurrent with another. Lockfiles left behind by runs that exited without releasing
+them are replaced.
+*/
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when the lock is held by another live run.
+var ErrLocked = errors.New("the topic is locked by another benchmark run")
+
+// Holder identifies the run that holds a lock.
+type Holder struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+func (h *Holder) String() string {
+	return fmt.Sprintf("%s (pid %d on %s since %s)", h.Command, h.PID, h.Host, h.Started.Format(time.RFC3339))
+}
+
+// Status describes how the lock was taken, and is recorded in the experiment metadata so
+// that concurrent runs can be identified in the reports.
+type Status struct {
+	Path      string  `json:"path"`
+	Acquired  bool    `json:"acquired"`
+	Contended bool    `json:"contended"`        // another live run held the lock
+	Forced    bool    `json:"forced"`           // the run proceeded although the lock was held
+	Stale     *Holder `json:"stale,omitempty"`  // the run that left behind the replaced lockfile
+	Holder    *Holder `json:"holder,omitempty"` // the live run that held the lock
+}
+
+// Lock is a lockfile held by the current process.
+type Lock struct {
+	path   string
+	holder Holder
+}
+
+// Path returns the lockfile of the topic of the endpoint in the directory.
+func Path(dir, endpoint, topic string) string {
+	return filepath.Join(dir, sanitize(endpoint)+"_"+sanitize(topic)+".lock")
+}
+
+// DefaultDir returns the directory of the lockfiles shared by the runs on the host.
+func DefaultDir() string {
+	return filepath.Join(os.TempDir(), "enbench")
+}
+
+var unsafe = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func sanitize(s string) string {
+	if s = strings.Trim(unsafe.ReplaceAllString(s, "_"), "_"); s == "" {
+		return "default"
+	}
+	return s
+}
+
+// Acquire the lockfile for the command. If another live run holds the lock ErrLocked
+// is returned unless the lock is forced, in which case the run proceeds without the
+// lock and the status records the holder. The status is returned even on error so that
+// the holder can be reported.
+func Acquire(path, command string, force bool) (_ *Lock, status *Status, err error) {
+	status = &Status{Path: path}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, status, err
+	}
+
+	host, _ := os.Hostname()
+	lock := &Lock{path: path, holder: Holder{PID: os.Getpid(), Host: host, Command: command, Started: time.Now()}}
+
+	// A stale lockfile is removed and creating the lockfile is retried once
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = lock.create(); err == nil {
+			status.Acquired = true
+			return lock, status, nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, status, err
+		}
+
+		var holder *Holder
+		if holder, err = read(path); err != nil {
+			return nil, status, err
+		}
+
+		if holder.Host == host && !alive(holder.PID) {
+			status.Stale = holder
+			if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, status, err
+			}
+			continue
+		}
+
+		status.Contended, status.Holder = true, holder
+		if !force {
+			return nil, status, fmt.Errorf("%w: %s", ErrLocked, holder)
+		}
+
+		status.Forced = true
+		return nil, status, nil
+	}
+	return nil, status, fmt.Errorf("could not acquire lockfile %s", path)
+}
+
+// Creates the lockfile if it does not exist, recording the holder.
+func (l *Lock) create() (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644); err != nil {
+		return err
+	}
+
+	if err = json.NewEncoder(f).Encode(&l.holder); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+	return f.Close()
+}
+
+// Reads the holder of the lockfile; a lockfile that cannot be parsed, e.g. because it is
+// still being written, is treated as held by an unknown run.
+func read(path string) (_ *Holder, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	holder := &Holder{}
+	if err = json.Unmarshal(data, holder); err != nil {
+		return &Holder{Command: "unknown"}, nil
+	}
+	return holder, nil
+}
+
+// Release the lock by removing the lockfile if it is still held by this process. A nil
+// lock is released without error so that forced runs can release unconditionally.
+func (l *Lock) Release() (err error) {
+	if l == nil {
+		return nil
+	}
+
+	var holder *Holder
+	if holder, err = read(l.path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if holder.PID != l.holder.PID || holder.Host != l.holder.Host || !holder.Started.Equal(l.holder.Started) {
+		return nil
+	}
+	return os.Remove(l.path)
+}
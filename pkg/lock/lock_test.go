@@ -0,0 +1,64 @@
+package lock_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/lock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	path := lock.Path(t.TempDir(), "bench.ensign.world:443", "bench/topic")
+	require.Equal(t, "bench.ensign.world_443_bench_topic.lock", filepath.Base(path))
+
+	first, status, err := lock.Acquire(path, "blast", false)
+	require.NoError(t, err)
+	require.True(t, status.Acquired)
+	require.False(t, status.Contended)
+
+	// A second run on the same topic is refused unless it is forced
+	_, status, err = lock.Acquire(path, "sustain", false)
+	require.ErrorIs(t, err, lock.ErrLocked)
+	require.True(t, status.Contended)
+	require.Equal(t, "blast", status.Holder.Command)
+	require.Equal(t, os.Getpid(), status.Holder.PID)
+
+	forced, status, err := lock.Acquire(path, "sustain", true)
+	require.NoError(t, err)
+	require.Nil(t, forced)
+	require.True(t, status.Forced)
+	require.False(t, status.Acquired)
+	require.NoError(t, forced.Release(), "forced runs do not hold the lock")
+	require.FileExists(t, path)
+
+	require.NoError(t, first.Release())
+	require.NoFileExists(t, path)
+	require.NoError(t, first.Release(), "releasing twice should not error")
+
+	// A lockfile left behind by a process that no longer exists is replaced
+	host, _ := os.Hostname()
+	data, err := json.Marshal(&lock.Holder{PID: 1 << 30, Host: host, Command: "e2e", Started: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	replaced, status, err := lock.Acquire(path, "blast", false)
+	require.NoError(t, err)
+	require.True(t, status.Acquired)
+	require.NotNil(t, status.Stale)
+	require.Equal(t, "e2e", status.Stale.Command)
+
+	// The lockfile of another run is not removed on release
+	data, err = json.Marshal(&lock.Holder{PID: 1, Host: "other", Command: "e2e", Started: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	require.NoError(t, replaced.Release())
+	require.FileExists(t, path)
+
+	_, status, err = lock.Acquire(path, "blast", false)
+	require.ErrorIs(t, err, lock.ErrLocked, "locks held on other hosts are never stale")
+	require.Equal(t, "other", status.Holder.Host)
+}
@@ -22,6 +22,11 @@ func (m Metrics) Measurement(name string) interface{} {
 	return m[name]
 }
 
+// Set the named measurement, e.g. to annotate the results of a run with how it ended.
+func (m Metrics) Set(name string, value interface{}) {
+	m[name] = value
+}
+
 func (m Metrics) MarshalJSON() ([]byte, error) {
 	v := map[string]interface{}(m)
 	return json.Marshal(v)
@@ -0,0 +1,149 @@
+/*
+Package monitor tracks the traffic received on each topic of a multi-topic subscription
+so that the listen command can be used as a lightweight topic traffic monitor. For each
+topic the monitor records the number of events and bytes received, the receive rate
+over the whole run and since the last report, and the distribution of the time between
+consecutive events (inter-arrival times).
+*/
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+)
+
+// Monitor records per-topic receive statistics; it is safe for concurrent use.
+type Monitor struct {
+	sync.Mutex
+	names    map[string]string // topic IDs to topic names for display
+	topics   map[string]*Topic
+	started  time.Time
+	reported time.Time
+}
+
+// Topic holds the receive statistics of a single topic.
+type Topic struct {
+	ID       string
+	Name     string
+	Events   uint64
+	Bytes    uint64
+	First    time.Time
+	Last     time.Time
+	Arrivals *stats.Latencies // the time between consecutive events on the topic
+	interval uint64           // the number of events received since the last report
+}
+
+// New creates a monitor; names maps topic IDs to the names displayed in the table and
+// may be nil, in which case topics are displayed by ID.
+func New(names map[string]string) *Monitor {
+	now := time.Now()
+	if names == nil {
+		names = make(map[string]string)
+	}
+	return &Monitor{
+		names:    names,
+		topics:   make(map[string]*Topic),
+		started:  now,
+		reported: now,
+	}
+}
+
+// Record an event of the specified size received on the topic at the specified time.
+func (m *Monitor) Record(topicID string, size int, at time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	topic, ok := m.topics[topicID]
+	if !ok {
+		name := m.names[topicID]
+		if name == "" {
+			name = topicID
+		}
+		topic = &Topic{ID: topicID, Name: name, First: at, Arrivals: &stats.Latencies{}}
+		m.topics[topicID] = topic
+	} else if gap := at.Sub(topic.Last); gap > 0 {
+		topic.Arrivals.Update(gap)
+	}
+
+	topic.Events++
+	topic.Bytes += uint64(size)
+	topic.Last = at
+	topic.interval++
+}
+
+// WriteTable writes a table of the per-topic statistics sorted by topic name and resets
+// the interval counters used to compute the rate since the last report.
+func (m *Monitor) WriteTable(w io.Writer) error {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(m.started).Seconds()
+	interval := now.Sub(m.reported).Seconds()
+	m.reported = now
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TOPIC\tEVENTS\tBYTES\tRATE\tINTERVAL RATE\tMEAN GAP\tP99 GAP\tMAX GAP\t")
+	for _, topic := range m.sorted() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f/s\t%.2f/s\t%s\t%s\t%s\t\n",
+			topic.Name,
+			topic.Events,
+			topic.Bytes,
+			rate(topic.Events, elapsed),
+			rate(topic.interval, interval),
+			topic.Arrivals.Mean(),
+			topic.Arrivals.Percentile(99),
+			topic.Arrivals.Slowest(),
+		)
+		topic.interval = 0
+	}
+	return tw.Flush()
+}
+
+// Snapshot returns the per-topic statistics keyed by topic name for interim reports.
+func (m *Monitor) Snapshot() map[string]interface{} {
+	m.Lock()
+	defer m.Unlock()
+
+	elapsed := time.Since(m.started).Seconds()
+	snap := make(map[string]interface{}, len(m.topics))
+	for _, topic := range m.topics {
+		snap[topic.Name] = map[string]interface{}{
+			"topic_id": topic.ID,
+			"events":   topic.Events,
+			"bytes":    topic.Bytes,
+			"rate":     rate(topic.Events, elapsed),
+			"arrivals": topic.Arrivals,
+		}
+	}
+	return snap
+}
+
+// Topics returns the statistics of every topic that events were received on.
+func (m *Monitor) Topics() []*Topic {
+	m.Lock()
+	defer m.Unlock()
+	return m.sorted()
+}
+
+func (m *Monitor) sorted() []*Topic {
+	topics := make([]*Topic, 0, len(m.topics))
+	for _, topic := range m.topics {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics
+}
+
+func rate(n uint64, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(n) / seconds
+}
@@ -0,0 +1,46 @@
+package monitor_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/monitor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor(t *testing.T) {
+	mon := monitor.New(map[string]string{"01H0": "orders"})
+	start := time.Now()
+
+	// Orders arrive every 10ms, the unnamed topic receives a single event
+	for i := 0; i < 5; i++ {
+		mon.Record("01H0", 100, start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+	mon.Record("01H1", 42, start)
+
+	topics := mon.Topics()
+	require.Len(t, topics, 2)
+
+	require.Equal(t, "01H1", topics[0].Name, "topics without a name are displayed by id")
+	require.Equal(t, uint64(1), topics[0].Events)
+	require.Equal(t, uint64(0), topics[0].Arrivals.N())
+
+	require.Equal(t, "orders", topics[1].Name)
+	require.Equal(t, uint64(5), topics[1].Events)
+	require.Equal(t, uint64(500), topics[1].Bytes)
+	require.Equal(t, uint64(4), topics[1].Arrivals.N())
+	require.InDelta(t, 10*time.Millisecond, topics[1].Arrivals.Mean(), float64(time.Microsecond))
+
+	snap := mon.Snapshot()
+	require.Contains(t, snap, "orders")
+	require.Contains(t, snap, "01H1")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, mon.WriteTable(buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], "INTERVAL RATE")
+	require.Contains(t, lines[2], "orders")
+}
@@ -0,0 +1,67 @@
+package options
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+var countUnits = map[string]uint64{
+	"":  1,
+	"k": 1e3,
+	"m": 1e6,
+	"b": 1e9,
+	"g": 1e9,
+}
+
+// ParseBytes parses a human readable byte size such as 50GB, 8KiB, or 1024 into the
+// number of bytes. Decimal (KB, MB, GB, TB) and binary (KiB, MiB, GiB, TiB) units are
+// supported case-insensitively; a number without units is interpreted as bytes.
+func ParseBytes(s string) (_ uint64, err error) {
+	return parseUnits(s, byteUnits, "byte size")
+}
+
+// ParseCount parses a human readable count such as 10M, 2.5k, or 1000 into a number,
+// e.g. of operations. The suffixes k, M, and B (or G) are thousands, millions, and
+// billions and are matched case-insensitively.
+func ParseCount(s string) (_ uint64, err error) {
+	return parseUnits(s, countUnits, "count")
+}
+
+func parseUnits(s string, units map[string]uint64, kind string) (_ uint64, err error) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+
+	num, unit := s, ""
+	if idx >= 0 {
+		num, unit = s[:idx], strings.TrimSpace(s[idx:])
+	}
+
+	multiplier, ok := units[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown %s unit %q", kind, unit)
+	}
+
+	var val float64
+	if val, err = strconv.ParseFloat(num, 64); err != nil {
+		return 0, fmt.Errorf("could not parse %s %q", kind, s)
+	}
+
+	return uint64(val * float64(multiplier)), nil
+}
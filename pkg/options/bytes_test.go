@@ -0,0 +1,59 @@
+package options_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytes(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected uint64
+	}{
+		{"1024", 1024},
+		{"512B", 512},
+		{"8KB", 8000},
+		{"8KiB", 8192},
+		{"50GB", 50000000000},
+		{"50 gb", 50000000000},
+		{"1.5MiB", 1572864},
+		{"2TiB", 2199023255552},
+	}
+
+	for _, tc := range testCases {
+		actual, err := options.ParseBytes(tc.in)
+		require.NoError(t, err, "could not parse %q", tc.in)
+		require.Equal(t, tc.expected, actual, "unexpected bytes for %q", tc.in)
+	}
+
+	for _, in := range []string{"", "GB", "12parsecs", "1.2.3MB"} {
+		_, err := options.ParseBytes(in)
+		require.Error(t, err, "expected error parsing %q", in)
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected uint64
+	}{
+		{"1000", 1000},
+		{"10M", 10000000},
+		{"2.5k", 2500},
+		{"1B", 1000000000},
+		{"3 g", 3000000000},
+	}
+
+	for _, tc := range testCases {
+		actual, err := options.ParseCount(tc.in)
+		require.NoError(t, err, "could not parse %q", tc.in)
+		require.Equal(t, tc.expected, actual, "unexpected count for %q", tc.in)
+	}
+
+	for _, in := range []string{"", "M", "10MB", "1.2.3k"} {
+		_, err := options.ParseCount(in)
+		require.Error(t, err, "expected error parsing %q", in)
+	}
+}
@@ -0,0 +1,100 @@
+package options
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+)
+
+// ParseCost parses a combined cost guard such as "duration=30m,events=1000000,bytes=50GB"
+// into the maximum duration, events, and bytes of a run. Any of the limits may be
+// omitted; omitted limits are returned as zero and are not enforced.
+func ParseCost(s string) (duration time.Duration, events, bytes uint64, err error) {
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return 0, 0, 0, fmt.Errorf("could not parse cost guard %q: expected key=value", part)
+		}
+
+		val = strings.TrimSpace(val)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "duration":
+			if duration, err = time.ParseDuration(val); err != nil {
+				return 0, 0, 0, fmt.Errorf("could not parse cost guard duration %q", val)
+			}
+		case "events":
+			if events, err = strconv.ParseUint(val, 10, 64); err != nil {
+				return 0, 0, 0, fmt.Errorf("could not parse cost guard events %q", val)
+			}
+		case "bytes":
+			if bytes, err = ParseBytes(val); err != nil {
+				return 0, 0, 0, err
+			}
+		default:
+			return 0, 0, 0, fmt.Errorf("unknown cost guard %q (expected duration, events, or bytes)", key)
+		}
+	}
+	return duration, events, bytes, nil
+}
+
+// Exhausted returns the exit reason if a run that started at the specified time and has
+// published the specified number of events has reached its duration or event guard,
+// otherwise an empty string is returned. The byte guard is checked by each benchmark
+// since the number of bytes published depends on the benchmark's workload.
+func (o Options) Exhausted(started time.Time, events uint64) string {
+	return o.ExhaustedAfter(time.Since(started), events)
+}
+
+// ExhaustedAfter is like Exhausted but takes the time elapsed since the run started,
+// e.g. when the run is timed by a clock other than the host clock.
+func (o Options) ExhaustedAfter(elapsed time.Duration, events uint64) string {
+	switch {
+	case o.MaxDuration > 0 && elapsed >= o.MaxDuration:
+		return benchmarks.ExitMaxDuration
+	case o.MaxEvents > 0 && events >= o.MaxEvents:
+		return benchmarks.ExitMaxEvents
+	default:
+		return ""
+	}
+}
+
+// Warming returns true if events should be published before the run is measured.
+func (o Options) Warming() bool {
+	return o.WarmupEvents > 0 || o.WarmupDuration > 0
+}
+
+// WarmedUp returns true once a warmup that started at the specified time and has
+// published the specified number of events has reached its event count or duration.
+func (o Options) WarmedUp(started time.Time, events uint64) bool {
+	return o.WarmedUpAfter(time.Since(started), events)
+}
+
+// WarmedUpAfter is like WarmedUp but takes the time elapsed since the warmup started.
+func (o Options) WarmedUpAfter(elapsed time.Duration, events uint64) bool {
+	switch {
+	case !o.Warming():
+		return true
+	case o.WarmupEvents > 0 && events >= o.WarmupEvents:
+		return true
+	case o.WarmupDuration > 0 && elapsed >= o.WarmupDuration:
+		return true
+	default:
+		return false
+	}
+}
+
+// Guard returns the cost guard configuration of the run to record with the results.
+func (o Options) Guard() map[string]interface{} {
+	return map[string]interface{}{
+		"max_duration": o.MaxDuration.String(),
+		"max_events":   o.MaxEvents,
+		"max_bytes":    o.MaxBytes,
+	}
+}
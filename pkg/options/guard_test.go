@@ -0,0 +1,63 @@
+package options_test
+
+import (
+	"testing"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCost(t *testing.T) {
+	duration, events, bytes, err := options.ParseCost("duration=30m, events=1000000, bytes=50GB")
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Minute, duration)
+	require.Equal(t, uint64(1000000), events)
+	require.Equal(t, uint64(50e9), bytes)
+
+	duration, events, bytes, err = options.ParseCost("Events=10")
+	require.NoError(t, err)
+	require.Zero(t, duration)
+	require.Equal(t, uint64(10), events)
+	require.Zero(t, bytes)
+
+	for _, spec := range []string{"duration", "duration=forever", "events=-1", "bytes=10XB", "cost=10"} {
+		_, _, _, err = options.ParseCost(spec)
+		require.Error(t, err, "expected %q to fail", spec)
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	opts := options.New()
+	started := time.Now()
+	require.Empty(t, opts.Exhausted(started.Add(-24*time.Hour), 1e9))
+
+	opts.MaxEvents = 100
+	require.Empty(t, opts.Exhausted(started, 99))
+	require.Equal(t, benchmarks.ExitMaxEvents, opts.Exhausted(started, 100))
+
+	opts.MaxDuration = time.Minute
+	require.Empty(t, opts.Exhausted(started, 0))
+	require.Equal(t, benchmarks.ExitMaxDuration, opts.Exhausted(started.Add(-time.Minute), 0))
+	require.Empty(t, opts.ExhaustedAfter(59*time.Second, 0))
+	require.Equal(t, benchmarks.ExitMaxDuration, opts.ExhaustedAfter(time.Minute, 0))
+}
+
+func TestWarmedUp(t *testing.T) {
+	opts := options.New()
+	started := time.Now()
+	require.False(t, opts.Warming())
+	require.True(t, opts.WarmedUp(started, 0))
+
+	opts.WarmupEvents = 100
+	require.True(t, opts.Warming())
+	require.False(t, opts.WarmedUp(started, 99))
+	require.True(t, opts.WarmedUp(started, 100))
+
+	// The warmup ends at whichever of the count or duration is reached first
+	opts.WarmupDuration = time.Minute
+	require.False(t, opts.WarmedUp(started, 10))
+	require.True(t, opts.WarmedUp(started.Add(-time.Minute), 10))
+	require.True(t, opts.WarmedUp(started, 100))
+}
@@ -0,0 +1,73 @@
+package options
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Jitter randomizes the interval between published events so that the arrivals at the
+// server are not perfectly regular. A uniform jitter samples each interval uniformly
+// within the fraction of the mean interval, e.g. ±30%; an exponential jitter samples
+// exponentially distributed intervals with the mean interval, i.e. Poisson arrivals.
+type Jitter struct {
+	Fraction    float64 // the maximum deviation of a uniform jitter as a fraction of the interval
+	Exponential bool
+}
+
+// ParseJitter parses a jitter specified as a percentage (30%), a fraction (0.3), or as
+// exp, exponential, or poisson for exponential inter-arrival times. An empty string or
+// zero disables the jitter.
+func ParseJitter(s string) (jitter Jitter, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "none":
+		return jitter, nil
+	case "exp", "exponential", "poisson":
+		return Jitter{Exponential: true}, nil
+	}
+
+	num, percent := strings.CutSuffix(s, "%")
+	if jitter.Fraction, err = strconv.ParseFloat(strings.TrimPrefix(num, "±"), 64); err != nil {
+		return Jitter{}, fmt.Errorf("could not parse jitter %q", s)
+	}
+
+	if percent {
+		jitter.Fraction /= 100
+	}
+
+	if jitter.Fraction < 0 || jitter.Fraction > 1 {
+		return Jitter{}, fmt.Errorf("jitter %q must be between 0%% and 100%% of the interval", s)
+	}
+	return jitter, nil
+}
+
+// Enabled returns true if the intervals are randomized.
+func (j Jitter) Enabled() bool {
+	return j.Exponential || j.Fraction > 0
+}
+
+// Next samples the next interval with the specified mean.
+func (j Jitter) Next(rnd *rand.Rand, mean time.Duration) time.Duration {
+	switch {
+	case j.Exponential:
+		return time.Duration(rnd.ExpFloat64() * float64(mean))
+	case j.Fraction > 0:
+		return time.Duration(float64(mean) * (1 + j.Fraction*(2*rnd.Float64()-1)))
+	default:
+		return mean
+	}
+}
+
+func (j Jitter) String() string {
+	switch {
+	case j.Exponential:
+		return "exponential"
+	case j.Fraction > 0:
+		return fmt.Sprintf("±%g%%", j.Fraction*100)
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,58 @@
+package options_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJitter(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected options.Jitter
+	}{
+		{"", options.Jitter{}},
+		{"none", options.Jitter{}},
+		{"30%", options.Jitter{Fraction: 0.3}},
+		{"±50%", options.Jitter{Fraction: 0.5}},
+		{"0.25", options.Jitter{Fraction: 0.25}},
+		{"exp", options.Jitter{Exponential: true}},
+		{"Poisson", options.Jitter{Exponential: true}},
+	}
+
+	for _, tc := range testCases {
+		actual, err := options.ParseJitter(tc.in)
+		require.NoError(t, err, "could not parse %q", tc.in)
+		require.Equal(t, tc.expected, actual, "unexpected jitter for %q", tc.in)
+	}
+
+	for _, in := range []string{"150%", "-0.1", "uniform"} {
+		_, err := options.ParseJitter(in)
+		require.Error(t, err, "expected %q to be invalid", in)
+	}
+}
+
+func TestJitterNext(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	mean := 10 * time.Millisecond
+
+	require.Equal(t, mean, options.Jitter{}.Next(rnd, mean))
+
+	uniform := options.Jitter{Fraction: 0.3}
+	for i := 0; i < 1000; i++ {
+		gap := uniform.Next(rnd, mean)
+		require.GreaterOrEqual(t, gap, 7*time.Millisecond)
+		require.LessOrEqual(t, gap, 13*time.Millisecond)
+	}
+
+	// Exponential intervals have the mean interval on average
+	var total time.Duration
+	exp := options.Jitter{Exponential: true}
+	for i := 0; i < 10000; i++ {
+		total += exp.Next(rnd, mean)
+	}
+	require.InEpsilon(t, float64(mean), float64(total/10000), 0.05)
+}
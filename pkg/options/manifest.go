@@ -0,0 +1,80 @@
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+)
+
+// Manifest captures everything required to reproduce a benchmark run as closely as
+// possible: the command along with the effective value of every global and command
+// flag, the resolved options including the random seed, the client and server versions,
+// and the labels of the run. Registered workloads are defined by the client version, so
+// the seed and the workload name recreate the same sequence of generated events. The
+// seeds derived for each worker of a distributed run are recorded by worker name.
+type Manifest struct {
+	Command       string              `json:"command"`
+	Global        map[string][]string `json:"global,omitempty"`
+	Flags         map[string][]string `json:"flags,omitempty"`
+	Options       *Options            `json:"options"`
+	Labels        map[string]string   `json:"labels,omitempty"`
+	Seeds         map[string]int64    `json:"seeds,omitempty"`
+	ClientVersion string              `json:"client_version"`
+	ServerVersion string              `json:"server_version,omitempty"`
+	Created       time.Time           `json:"created"`
+}
+
+// LoadManifest reads a manifest written by a previous run, decompressing it if needed.
+func LoadManifest(path string) (_ *Manifest, err error) {
+	var data []byte
+	if data, err = compress.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %w", err)
+	}
+
+	if m.Command == "" {
+		return nil, fmt.Errorf("manifest %s does not specify a command", path)
+	}
+	return m, nil
+}
+
+// Write the manifest as indented JSON to the specified path.
+func (m *Manifest) Write(path string) (err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(m, "", "  "); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Args returns the command line arguments that reproduce the run: the global flags
+// followed by the command and the command flags, each sorted by flag name.
+func (m *Manifest) Args() []string {
+	args := flagArgs(m.Global)
+	args = append(args, m.Command)
+	return append(args, flagArgs(m.Flags)...)
+}
+
+func flagArgs(flags map[string][]string) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(flags))
+	for _, name := range names {
+		for _, val := range flags[name] {
+			args = append(args, fmt.Sprintf("--%s=%s", name, val))
+		}
+	}
+	return args
+}
@@ -0,0 +1,48 @@
+package options_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	opts := options.New()
+	opts.Seed = 42
+
+	m := &options.Manifest{
+		Command: "blast",
+		Global: map[string][]string{
+			"topic": {"benchmarks"},
+			"seed":  {"42"},
+			"label": {"env=staging", "region=us-east"},
+		},
+		Flags:         map[string][]string{"operations": {"100"}, "data-size": {"256"}},
+		Options:       opts,
+		Labels:        map[string]string{"env": "staging", "region": "us-east"},
+		ClientVersion: "0.3",
+		Created:       time.Now().Truncate(time.Second),
+	}
+
+	expected := []string{
+		"--label=env=staging", "--label=region=us-east", "--seed=42", "--topic=benchmarks",
+		"blast", "--data-size=256", "--operations=100",
+	}
+	require.Equal(t, expected, m.Args())
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, m.Write(path))
+
+	loaded, err := options.LoadManifest(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, loaded.Args())
+	require.Equal(t, int64(42), loaded.Options.Seed)
+	require.True(t, m.Created.Equal(loaded.Created))
+
+	require.NoError(t, (&options.Manifest{}).Write(path))
+	_, err = options.LoadManifest(path)
+	require.Error(t, err, "a manifest without a command cannot be reproduced")
+}
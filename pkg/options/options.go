@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/rotationalio/go-ensign"
+	"github.com/rotationalio/go-ensign/mock"
 )
 
 // Reasonable defaults for benchmark options
@@ -16,12 +17,78 @@ const (
 
 type Options struct {
 	Topic       string        `json:"topic" yaml:"topic"`
+	TopicID     string        `json:"topic_id,omitempty" yaml:"topic_id,omitempty"`
 	Endpoint    string        `json:"endpoint" yaml:"endpoint"`
 	AuthURL     string        `json:"auth_url" yaml:"auth_url"`
 	Credentials string        `json:"-" yaml:"-"`
 	Operations  uint64        `json:"operations" yaml:"operations"`
 	DataSize    int64         `json:"data_size" yaml:"data_size"`
 	Interval    time.Duration `json:"interval" yaml:"interval"`
+	Reservoir   int           `json:"reservoir,omitempty" yaml:"reservoir,omitempty"`
+	Phases      []float64     `json:"phases,omitempty" yaml:"phases,omitempty"`
+	Warmup      time.Duration `json:"warmup,omitempty" yaml:"warmup,omitempty"`
+	Cooldown    time.Duration `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`
+	Window      time.Duration `json:"window,omitempty" yaml:"window,omitempty"`
+	Backoff     uint64        `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	Malformed   float64       `json:"malformed,omitempty" yaml:"malformed,omitempty"`
+	Retention   uint64        `json:"retention,omitempty" yaml:"retention,omitempty"`
+	Overshoot   float64       `json:"overshoot,omitempty" yaml:"overshoot,omitempty"`
+	MaxBytes    uint64        `json:"max_bytes,omitempty" yaml:"max_bytes,omitempty"`
+	MaxEvents   uint64        `json:"max_events,omitempty" yaml:"max_events,omitempty"`
+	MaxDuration time.Duration `json:"max_duration,omitempty" yaml:"max_duration,omitempty"`
+	Workload    string        `json:"workload,omitempty" yaml:"workload,omitempty"`
+
+	// Generate event data with a payload generator (e.g. json or text) to exercise
+	// compression and mimetype handling instead of publishing random bytes. Structured
+	// payloads generate documents with the fields of the schema.
+	Payload string `json:"payload,omitempty" yaml:"payload,omitempty"`
+	Schema  string `json:"schema,omitempty" yaml:"schema,omitempty"`
+
+	// Publish blast events at this many events per second rather than as fast as
+	// possible; latencies are measured from when each event was scheduled to be sent.
+	Rate float64 `json:"rate,omitempty" yaml:"rate,omitempty"`
+
+	// Publish blast events on this many streams, each on its own connection unless fewer
+	// connections are specified, in which case the streams are assigned to connections
+	// round robin and multiplexed over them. Unless the streams are pre-dialed, the
+	// connections are established once the run has started. Pre-dialing connects and
+	// verifies the streams in parallel before the measurement.
+	Streams     int  `json:"streams,omitempty" yaml:"streams,omitempty"`
+	Connections int  `json:"connections,omitempty" yaml:"connections,omitempty"`
+	PreDial     bool `json:"pre_dial,omitempty" yaml:"pre_dial,omitempty"`
+
+	// Randomize the interval between sustain events, e.g. by ±30% or with exponential
+	// inter-arrival times, rather than publishing on a perfectly regular ticker.
+	Jitter string `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+
+	// Publish a follow-up probe event whenever an event's latency exceeds this threshold
+	// to tell transient per-event hiccups from sustained degradation; zero disables it.
+	TailThreshold time.Duration `json:"tail_threshold,omitempty" yaml:"tail_threshold,omitempty"`
+
+	// Publish events before the measurement of the run starts so that stream setup,
+	// TLS handshakes, and server-side cache warm-up are not measured. The warmup ends
+	// when either the number of events have been published or the duration elapses.
+	WarmupEvents   uint64        `json:"warmup_events,omitempty" yaml:"warmup_events,omitempty"`
+	WarmupDuration time.Duration `json:"warmup_duration,omitempty" yaml:"warmup_duration,omitempty"`
+
+	// Publish across multiple topics rather than the benchmark topic; topics that do
+	// not exist are created before the run.
+	Topics []Target `json:"topics,omitempty" yaml:"topics,omitempty"`
+
+	// Seed of the random number generators used to create workloads so that the events
+	// of a run can be reproduced; recorded in the run manifest.
+	Seed int64 `json:"seed,omitempty" yaml:"seed,omitempty"`
+
+	// The counter metadata of generated events begins after this offset so that the
+	// workers of a distributed run generate disjoint counters.
+	CounterOffset uint64 `json:"counter_offset,omitempty" yaml:"counter_offset,omitempty"`
+
+	// Strip the app, version, and counter metadata from generated events so that the
+	// measurements of tiny payloads are not dominated by metadata bytes.
+	MinimalMetadata bool `json:"minimal_metadata,omitempty" yaml:"minimal_metadata,omitempty"`
+
+	// Connect to an in-process mock server (e.g. the local emulator) instead of Ensign.
+	Mock *mock.Ensign `json:"-" yaml:"-"`
 }
 
 func New() *Options {
@@ -33,7 +100,20 @@ func New() *Options {
 	}
 }
 
+// TopicRef returns the topic ID if one was specified to bind the benchmark to a topic
+// without name resolution, otherwise the topic name is returned.
+func (o Options) TopicRef() string {
+	if o.TopicID != "" {
+		return o.TopicID
+	}
+	return o.Topic
+}
+
 func (o Options) Ensign() []ensign.Option {
+	if o.Mock != nil {
+		return []ensign.Option{ensign.WithMock(o.Mock)}
+	}
+
 	opts := make([]ensign.Option, 0, 3)
 	if o.Credentials != "" {
 		opts = append(opts, ensign.WithLoadCredentials(o.Credentials))
@@ -0,0 +1,77 @@
+package options
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// ParseDeduplication parses the name of a topic deduplication strategy, e.g. strict or
+// unique-key, along with the offset position of the duplicates that is kept (earliest
+// or latest) and the keys or fields that the strategy compares. The key grouped and
+// unique key strategies require metadata keys and the unique field strategy requires
+// data fields; the other strategies do not accept either.
+func ParseDeduplication(strategy, offset string, keysOrFields []string) (_ *api.Deduplication, err error) {
+	policy := &api.Deduplication{}
+	if policy.Strategy, err = parseEnum[api.Deduplication_Strategy](strategy, "", api.Deduplication_Strategy_value); err != nil {
+		return nil, fmt.Errorf("could not parse deduplication strategy: %w", err)
+	}
+
+	if offset != "" {
+		if policy.Offset, err = parseEnum[api.Deduplication_OffsetPosition](offset, "OFFSET_", api.Deduplication_OffsetPosition_value); err != nil {
+			return nil, fmt.Errorf("could not parse deduplication offset: %w", err)
+		}
+	}
+
+	switch policy.Strategy {
+	case api.Deduplication_KEY_GROUPED, api.Deduplication_UNIQUE_KEY:
+		if len(keysOrFields) == 0 {
+			return nil, fmt.Errorf("the %s deduplication strategy requires metadata keys", policy.Strategy)
+		}
+		policy.Keys = keysOrFields
+	case api.Deduplication_UNIQUE_FIELD:
+		if len(keysOrFields) == 0 {
+			return nil, fmt.Errorf("the %s deduplication strategy requires data fields", policy.Strategy)
+		}
+		policy.Fields = keysOrFields
+	default:
+		if len(keysOrFields) > 0 {
+			return nil, fmt.Errorf("the %s deduplication strategy does not use keys or fields", policy.Strategy)
+		}
+	}
+	return policy, nil
+}
+
+// ParseSharding parses the name of a topic sharding strategy, e.g. consistent-key-hash.
+func ParseSharding(s string) (_ api.ShardingStrategy, err error) {
+	var strategy api.ShardingStrategy
+	if strategy, err = parseEnum[api.ShardingStrategy](s, "", api.ShardingStrategy_value); err != nil {
+		return 0, fmt.Errorf("could not parse sharding strategy: %w", err)
+	}
+	return strategy, nil
+}
+
+// Parses the case-insensitive name of a protocol buffer enum with dashes or underscores
+// between words, with or without the common prefix of the names. The unknown value of
+// the enum is not accepted.
+func parseEnum[E ~int32](s, prefix string, values map[string]int32) (E, error) {
+	name := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "-", "_"))
+	if !strings.HasPrefix(name, prefix) {
+		name = prefix + name
+	}
+
+	if val, ok := values[name]; ok && val != 0 {
+		return E(val), nil
+	}
+
+	names := make([]string, 0, len(values))
+	for name, val := range values {
+		if val != 0 {
+			names = append(names, strings.ToLower(strings.TrimPrefix(name, prefix)))
+		}
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown value %q (expected one of %s)", s, strings.Join(names, ", "))
+}
@@ -0,0 +1,47 @@
+package options_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeduplication(t *testing.T) {
+	policy, err := options.ParseDeduplication("strict", "latest", nil)
+	require.NoError(t, err)
+	require.Equal(t, api.Deduplication_STRICT, policy.Strategy)
+	require.Equal(t, api.Deduplication_OFFSET_LATEST, policy.Offset)
+
+	policy, err = options.ParseDeduplication("Unique-Key", "", []string{"counter"})
+	require.NoError(t, err)
+	require.Equal(t, api.Deduplication_UNIQUE_KEY, policy.Strategy)
+	require.Equal(t, api.Deduplication_OFFSET_UNKNOWN, policy.Offset, "the server default offset should be used")
+	require.Equal(t, []string{"counter"}, policy.Keys)
+
+	policy, err = options.ParseDeduplication("unique_field", "OFFSET_EARLIEST", []string{"id"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"id"}, policy.Fields)
+	require.Empty(t, policy.Keys)
+
+	for _, args := range [][]string{{"", ""}, {"unknown", ""}, {"bogus", ""}, {"strict", "middle"}, {"key_grouped", ""}, {"unique_field", ""}} {
+		_, err := options.ParseDeduplication(args[0], args[1], nil)
+		require.Error(t, err, "expected %q to be invalid", args)
+	}
+
+	_, err = options.ParseDeduplication("datagram", "", []string{"counter"})
+	require.Error(t, err, "strategies that do not compare keys should not accept them")
+}
+
+func TestParseSharding(t *testing.T) {
+	strategy, err := options.ParseSharding("consistent-key-hash")
+	require.NoError(t, err)
+	require.Equal(t, api.ShardingStrategy_CONSISTENT_KEY_HASH, strategy)
+
+	_, err = options.ParseSharding("unknown")
+	require.Error(t, err)
+
+	_, err = options.ParseSharding("round_robin")
+	require.ErrorContains(t, err, "no_sharding, publisher_ordering, random")
+}
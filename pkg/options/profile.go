@@ -0,0 +1,158 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfileCommand is the benchmark run by a profile that does not specify one.
+const DefaultProfileCommand = "blast"
+
+// Config is a benchmark configuration file of named profiles so that reproducible
+// benchmark definitions can be checked into version control, e.g.
+//
+//	profiles:
+//	  nightly:
+//	    endpoint: ensign.rotational.app:443
+//	    topic: benchmarks
+//	    operations: 100000
+//	    data_size: 1024
+//	    concurrency: 8
+//	    rate: 5000
+//	    every: 6h
+//	report:
+//	  percentiles: [50, 90, 99, 99.9, 99.99]
+//	  derived:
+//	    tail_ratio: p99/p50
+//	    apdex: apdex:50ms
+//
+// The report section standardizes the latency summaries of every run that is made
+// with the config, whether or not a profile is run.
+type Config struct {
+	Profiles map[string]*Profile `yaml:"profiles"`
+	Report   *Report             `yaml:"report,omitempty"`
+}
+
+// Profile is a named benchmark definition. Zero values are not set so the defaults of
+// the command apply. The concurrency of a profile is the number of publish streams and
+// any flags of the command that do not have a field are specified by name. The interval
+// of a profile is how often it is run by the schedule command, which is reloaded when
+// the config changes; if it is not set the interval of the command is used.
+type Profile struct {
+	Command     string            `yaml:"command,omitempty"`
+	Endpoint    string            `yaml:"endpoint,omitempty"`
+	Topic       string            `yaml:"topic,omitempty"`
+	Operations  uint64            `yaml:"operations,omitempty"`
+	DataSize    int64             `yaml:"data_size,omitempty"`
+	Concurrency int               `yaml:"concurrency,omitempty"`
+	Rate        float64           `yaml:"rate,omitempty"`
+	Flags       map[string]string `yaml:"flags,omitempty"`
+	Every       time.Duration     `yaml:"every,omitempty"`
+}
+
+// LoadConfig reads a benchmark configuration file, returning an error if it does not
+// define any profiles or a profile is invalid.
+func LoadConfig(path string) (_ *Config, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	var conf *Config
+	if conf, err = ParseConfig(data); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// ParseConfig parses the contents of a benchmark configuration file, e.g. when the file
+// is reloaded by a Watcher, and validates it as LoadConfig does.
+func ParseConfig(data []byte) (_ *Config, err error) {
+	conf := &Config{}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("could not parse config: %w", err)
+	}
+
+	if len(conf.Profiles) == 0 && conf.Report == nil {
+		return nil, errors.New("the config does not define any profiles")
+	}
+
+	if conf.Report != nil {
+		if _, _, err = conf.Report.Parse(); err != nil {
+			return nil, fmt.Errorf("report: %w", err)
+		}
+	}
+
+	for name, profile := range conf.Profiles {
+		if profile == nil {
+			return nil, fmt.Errorf("profile %q is empty", name)
+		}
+
+		if profile.Concurrency < 0 || profile.Rate < 0 || profile.Every < 0 {
+			return nil, fmt.Errorf("profile %q: concurrency, rate, and interval must not be negative", name)
+		}
+	}
+	return conf, nil
+}
+
+// Profile returns the named profile or an error that lists the available profiles.
+func (c *Config) Profile(name string) (_ *Profile, err error) {
+	if profile, ok := c.Profiles[name]; ok {
+		return profile, nil
+	}
+	return nil, fmt.Errorf("unknown profile %q (available: %v)", name, c.Names())
+}
+
+// Names returns the names of the profiles in sorted order.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Manifest returns a manifest that runs the profile so that a profile is run the same
+// way as a previous run is reproduced; the profile is labeled with its name.
+func (p *Profile) Manifest(name string) *Manifest {
+	m := &Manifest{
+		Command: p.Command,
+		Global:  map[string][]string{"label": {"profile=" + name}},
+		Flags:   make(map[string][]string),
+		Labels:  map[string]string{"profile": name},
+	}
+
+	if m.Command == "" {
+		m.Command = DefaultProfileCommand
+	}
+
+	if p.Endpoint != "" {
+		m.Global["endpoint"] = []string{p.Endpoint}
+	}
+	if p.Topic != "" {
+		m.Global["topic"] = []string{p.Topic}
+	}
+
+	for name, val := range p.Flags {
+		m.Flags[name] = []string{val}
+	}
+	if p.Operations > 0 {
+		m.Flags["operations"] = []string{fmt.Sprint(p.Operations)}
+	}
+	if p.DataSize > 0 {
+		m.Flags["data-size"] = []string{fmt.Sprint(p.DataSize)}
+	}
+	if p.Concurrency > 0 {
+		m.Flags["streams"] = []string{fmt.Sprint(p.Concurrency)}
+	}
+	if p.Rate > 0 {
+		m.Flags["rate"] = []string{fmt.Sprint(p.Rate)}
+	}
+	return m
+}
@@ -0,0 +1,97 @@
+package options_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench.yaml")
+	data := []byte(`profiles:
+  nightly:
+    endpoint: ensign.rotational.app:443
+    topic: nightly
+    operations: 100000
+    data_size: 1024
+    concurrency: 8
+    rate: 5000
+    flags:
+      pre-dial: "true"
+  seek:
+    command: seek
+    operations: 50
+    every: 6h
+`)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	config, err := options.LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"nightly", "seek"}, config.Names())
+
+	profile, err := config.Profile("nightly")
+	require.NoError(t, err)
+
+	expected := []string{
+		"--endpoint=ensign.rotational.app:443", "--label=profile=nightly", "--topic=nightly",
+		"blast", "--data-size=1024", "--operations=100000", "--pre-dial=true", "--rate=5000", "--streams=8",
+	}
+	require.Equal(t, expected, profile.Manifest("nightly").Args())
+
+	profile, err = config.Profile("seek")
+	require.NoError(t, err)
+	require.Equal(t, []string{"--label=profile=seek", "seek", "--operations=50"}, profile.Manifest("seek").Args())
+	require.Equal(t, 6*time.Hour, profile.Every)
+
+	// The contents of a reloaded config are parsed the same way as the file
+	config, err = options.ParseConfig(data)
+	require.NoError(t, err)
+	require.Equal(t, []string{"nightly", "seek"}, config.Names())
+
+	_, err = config.Profile("missing")
+	require.EqualError(t, err, `unknown profile "missing" (available: [nightly seek])`)
+
+	require.NoError(t, os.WriteFile(path, []byte("profiles: {}\n"), 0644))
+	_, err = options.LoadConfig(path)
+	require.Error(t, err, "a config without profiles should not be loaded")
+
+	require.NoError(t, os.WriteFile(path, []byte("profiles:\n  bad:\n    rate: -1\n"), 0644))
+	_, err = options.LoadConfig(path)
+	require.Error(t, err, "a profile with a negative rate should not be loaded")
+
+	_, err = options.ParseConfig([]byte("profiles:\n  bad:\n    every: -1h\n"))
+	require.Error(t, err, "a profile with a negative interval should not be loaded")
+}
+
+func TestConfigReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench.yaml")
+	data := []byte(`report:
+  percentiles: [50, 99, 99.99]
+  derived:
+    tail_ratio: p99/p50
+    apdex: apdex:50ms
+`)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	// A config may only standardize the reports of the runs made with it
+	config, err := options.LoadConfig(path)
+	require.NoError(t, err)
+	require.Empty(t, config.Profiles)
+
+	defaults := stats.ReportedPercentiles
+	t.Cleanup(func() { stats.ReportedPercentiles, stats.DerivedMetrics = defaults, nil })
+
+	require.NoError(t, config.Report.Apply())
+	require.Equal(t, []stats.ReportedPercentile{{Name: "p50", Value: 50}, {Name: "p99", Value: 99}, {Name: "p99_99", Value: 99.99}}, stats.ReportedPercentiles)
+	require.Len(t, stats.DerivedMetrics, 2)
+	require.Equal(t, "apdex", stats.DerivedMetrics[0].Name, "derived metrics should be ordered by name")
+
+	require.NoError(t, os.WriteFile(path, []byte("report:\n  derived:\n    tail: p99\n"), 0644))
+	_, err = options.LoadConfig(path)
+	require.Error(t, err, "a config with an invalid derived metric should not be loaded")
+}
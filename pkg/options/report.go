@@ -0,0 +1,60 @@
+package options
+
+import (
+	"sort"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+)
+
+// Report configures the summary of the latency distributions in the results: the
+// percentiles that are reported and the metrics derived from the distributions, keyed
+// by the name that they are reported under, e.g. "tail_ratio: p99/p50" or
+// "apdex: apdex:50ms". Percentiles that are not specified are reported as usual.
+type Report struct {
+	Percentiles []float64         `yaml:"percentiles,omitempty"`
+	Derived     map[string]string `yaml:"derived,omitempty"`
+}
+
+// Parse the percentiles and derived metrics of the report; the derived metrics are
+// ordered by name. Nil percentiles are returned if the report does not specify them.
+func (r *Report) Parse() (percentiles []stats.ReportedPercentile, derived []stats.Derived, err error) {
+	if len(r.Percentiles) > 0 {
+		if percentiles, err = stats.ParsePercentiles(r.Percentiles); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	names := make([]string, 0, len(r.Derived))
+	for name := range r.Derived {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var d stats.Derived
+		if d, err = stats.ParseDerived(name, r.Derived[name]); err != nil {
+			return nil, nil, err
+		}
+		derived = append(derived, d)
+	}
+	return percentiles, derived, nil
+}
+
+// Apply the report to the latencies reported by the benchmarks; must be called before
+// the benchmark is run.
+func (r *Report) Apply() (err error) {
+	var (
+		percentiles []stats.ReportedPercentile
+		derived     []stats.Derived
+	)
+
+	if percentiles, derived, err = r.Parse(); err != nil {
+		return err
+	}
+
+	if percentiles != nil {
+		stats.ReportedPercentiles = percentiles
+	}
+	stats.DerivedMetrics = derived
+	return nil
+}
@@ -0,0 +1,18 @@
+package options
+
+import "errors"
+
+// CheckStreams validates the number of blast publish streams and the connections they
+// are multiplexed over. At least one stream is required; zero connections means that
+// each stream has its own connection, otherwise there may be at most one connection
+// per stream.
+func (o Options) CheckStreams() error {
+	if o.Streams < 1 {
+		return errors.New("at least one publish stream is required")
+	}
+
+	if o.Connections < 0 || o.Connections > o.Streams {
+		return errors.New("the number of connections must be between 0 (one connection per stream) and the number of streams")
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package options_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStreams(t *testing.T) {
+	testCases := []struct {
+		streams     int
+		connections int
+		valid       bool
+	}{
+		{0, 0, false},
+		{1, 0, true},
+		{1, 1, true},
+		{1, 2, false},
+		{4, -1, false},
+		{4, 0, true},
+		{4, 1, true},
+		{4, 4, true},
+		{4, 5, false},
+	}
+
+	for _, tc := range testCases {
+		opts := options.Options{Streams: tc.streams, Connections: tc.connections}
+		err := opts.CheckStreams()
+		if tc.valid {
+			require.NoError(t, err, "expected %d connections over %d streams to be valid", tc.connections, tc.streams)
+		} else {
+			require.Error(t, err, "expected %d connections over %d streams to be invalid", tc.connections, tc.streams)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Target is a topic published to by a multi-topic benchmark along with the relative
+// weight of the events it receives; a zero weight is treated as a weight of one.
+type Target struct {
+	Topic  string `json:"topic" yaml:"topic"`
+	Weight int    `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// ParseTargets parses a comma separated list of topic names with optional weights, e.g.
+// "orders:3,payments,refunds:1" publishes three order events for each payment and refund.
+func ParseTargets(s string) (targets []Target, err error) {
+	seen := make(map[string]struct{})
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		target := Target{Weight: 1}
+		name, weight, ok := strings.Cut(part, ":")
+		if target.Topic = strings.TrimSpace(name); target.Topic == "" {
+			return nil, fmt.Errorf("could not parse topic %q: missing topic name", part)
+		}
+
+		if ok {
+			if target.Weight, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil || target.Weight < 1 {
+				return nil, fmt.Errorf("could not parse topic %q: weight must be a positive integer", part)
+			}
+		}
+
+		if _, ok := seen[target.Topic]; ok {
+			return nil, fmt.Errorf("topic %q specified more than once", target.Topic)
+		}
+		seen[target.Topic] = struct{}{}
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("no topics specified")
+	}
+	return targets, nil
+}
+
+// Schedule returns the order in which events are assigned to the targets as indices
+// into the targets. The schedule is a smooth weighted round-robin, so events are
+// interleaved across the topics rather than published to each topic in bursts, and the
+// schedule repeats once its length (the sum of the weights) is exhausted.
+func Schedule(targets []Target) []int {
+	total := 0
+	weights := make([]int, len(targets))
+	for i, target := range targets {
+		if weights[i] = target.Weight; weights[i] < 1 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+
+	schedule := make([]int, 0, total)
+	current := make([]int, len(targets))
+	for len(schedule) < total {
+		best := 0
+		for i := range current {
+			current[i] += weights[i]
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, best)
+	}
+	return schedule
+}
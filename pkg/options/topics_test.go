@@ -0,0 +1,27 @@
+package options_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := options.ParseTargets("orders:3, payments ,refunds:1")
+	require.NoError(t, err)
+	require.Equal(t, []options.Target{{"orders", 3}, {"payments", 1}, {"refunds", 1}}, targets)
+
+	for _, in := range []string{"", ",", ":2", "orders:0", "orders:x", "orders,orders:2"} {
+		_, err := options.ParseTargets(in)
+		require.Error(t, err, "expected %q to be invalid", in)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	schedule := options.Schedule([]options.Target{{"a", 3}, {"b", 1}, {"c", 0}})
+	require.Len(t, schedule, 5)
+
+	// Heavier topics are interleaved with the lighter topics rather than sent in bursts
+	require.Equal(t, []int{0, 1, 0, 2, 0}, schedule)
+}
@@ -0,0 +1,119 @@
+package options
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultWatchInterval is how often the configuration file is checked for changes.
+const DefaultWatchInterval = 5 * time.Second
+
+// ReloadFunc is called with the contents and version of the configuration file when
+// it changes; if an error is returned the previous configuration remains active.
+type ReloadFunc func(data []byte, version string) error
+
+// Watcher polls a configuration file and reloads it when its contents change so that
+// long-running processes such as the benchmark daemon can pick up new schedules and
+// parameters without a restart. The version of the configuration is the truncated
+// SHA256 hash of the file contents, which is recorded in the metadata of later runs.
+type Watcher struct {
+	sync.RWMutex
+	path     string
+	interval time.Duration
+	reload   ReloadFunc
+	modified time.Time
+	version  string
+}
+
+// NewWatcher loads the configuration file and calls reload with its initial contents,
+// returning an error if the file cannot be read or the initial reload fails.
+func NewWatcher(path string, interval time.Duration, reload ReloadFunc) (w *Watcher, err error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w = &Watcher{path: path, interval: interval, reload: reload}
+	if _, err = w.Check(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Version returns the version of the currently active configuration.
+func (w *Watcher) Version() string {
+	w.RLock()
+	defer w.RUnlock()
+	return w.version
+}
+
+// Check the configuration file for changes, reloading it if the contents have changed
+// and returning true if a new version was loaded.
+func (w *Watcher) Check() (_ bool, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(w.path); err != nil {
+		return false, err
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	// Avoid reading the file if it hasn't been modified since the last check
+	if !w.modified.IsZero() && info.ModTime().Equal(w.modified) {
+		return false, nil
+	}
+
+	var data []byte
+	if data, err = os.ReadFile(w.path); err != nil {
+		return false, err
+	}
+
+	version := Version(data)
+	w.modified = info.ModTime()
+	if version == w.version {
+		return false, nil
+	}
+
+	if err = w.reload(data, version); err != nil {
+		return false, err
+	}
+
+	w.version = version
+	return true, nil
+}
+
+// Watch the configuration file until the context is canceled. Errors reloading the
+// configuration are logged and the previous configuration remains active.
+func (w *Watcher) Watch(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			prev := w.Version()
+			changed, err := w.Check()
+			if err != nil {
+				log.Error().Err(err).Str("path", w.path).Msg("could not reload configuration")
+				continue
+			}
+
+			if changed {
+				log.Info().Str("path", w.path).Str("previous", prev).Str("version", w.Version()).Msg("configuration reloaded")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Version computes the version of configuration data.
+func Version(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
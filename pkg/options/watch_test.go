@@ -0,0 +1,58 @@
+package options_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("topic: alpha\n"), 0644))
+
+	var loaded []string
+	reload := func(data []byte, version string) error {
+		if string(data) == "invalid\n" {
+			return errors.New("could not parse config")
+		}
+		loaded = append(loaded, string(data))
+		return nil
+	}
+
+	watcher, err := options.NewWatcher(path, time.Second, reload)
+	require.NoError(t, err)
+	require.Equal(t, []string{"topic: alpha\n"}, loaded)
+	require.Equal(t, options.Version([]byte("topic: alpha\n")), watcher.Version())
+
+	// No changes to the file should not trigger a reload
+	changed, err := watcher.Check()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Change the file and ensure the modification time is updated
+	require.NoError(t, os.WriteFile(path, []byte("topic: bravo\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	changed, err = watcher.Check()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, options.Version([]byte("topic: bravo\n")), watcher.Version())
+
+	// An invalid config should not replace the active version
+	version := watcher.Version()
+	require.NoError(t, os.WriteFile(path, []byte("invalid\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+
+	_, err = watcher.Check()
+	require.Error(t, err)
+	require.Equal(t, version, watcher.Version())
+	require.Len(t, loaded, 2)
+
+	_, err = options.NewWatcher(filepath.Join(t.TempDir(), "missing.yaml"), 0, reload)
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+/*
+Package placement records which Ensign nodes are assigned to serve the topics used in a
+benchmark and breaks down latency results by the node that served each request so that
+node-level performance asymmetry can be detected in multi-topic and multi-node runs.
+*/
+package placement
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Placement describes the most recent node assignment of a topic reported by the API.
+type Placement struct {
+	TopicID  string `json:"topic_id"`
+	Topic    string `json:"topic"`
+	Epoch    uint64 `json:"epoch"`
+	Sharding string `json:"sharding"`
+	Nodes    []Node `json:"nodes"`
+}
+
+// Node is a server that a topic has been placed on.
+type Node struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Shard    uint64 `json:"shard"`
+}
+
+// Lookup the placements of the specified topics. Topics that are not returned by the
+// API or that have no placements are omitted from the result.
+func Lookup(ctx context.Context, client *ensign.Client, topicIDs ...ulid.ULID) (_ map[string]*Placement, err error) {
+	var topics []*api.Topic
+	if topics, err = client.ListTopics(ctx); err != nil {
+		return nil, err
+	}
+
+	placements := make(map[string]*Placement, len(topicIDs))
+	for _, topic := range topics {
+		for _, topicID := range topicIDs {
+			if !bytes.Equal(topic.Id, topicID.Bytes()) || len(topic.Placements) == 0 {
+				continue
+			}
+
+			// Use the placement with the latest epoch
+			latest := topic.Placements[0]
+			for _, p := range topic.Placements[1:] {
+				if p.Epoch > latest.Epoch {
+					latest = p
+				}
+			}
+
+			placement := &Placement{
+				TopicID:  topicID.String(),
+				Topic:    topic.Name,
+				Epoch:    latest.Epoch,
+				Sharding: latest.Sharding.String(),
+				Nodes:    make([]Node, 0, len(latest.Nodes)),
+			}
+
+			for _, node := range latest.Nodes {
+				placement.Nodes = append(placement.Nodes, Node{
+					ID:       node.Id,
+					Hostname: node.Hostname,
+					Region:   node.Region.String(),
+					Shard:    node.Shard,
+				})
+			}
+			placements[placement.TopicID] = placement
+		}
+	}
+	return placements, nil
+}
+
+// Breakdown groups latencies by the node that served the requests.
+type Breakdown map[string]*stats.Latencies
+
+// Add latency samples for the specified serving node.
+func (b Breakdown) Add(node string, latencies ...time.Duration) {
+	if node == "" {
+		node = "unknown"
+	}
+
+	if _, ok := b[node]; !ok {
+		b[node] = &stats.Latencies{}
+	}
+	b[node].Update(latencies...)
+}
+
+// Append a distribution of latencies for the specified serving node.
+func (b Breakdown) Append(node string, latencies *stats.Latencies) {
+	if node == "" {
+		node = "unknown"
+	}
+
+	if _, ok := b[node]; !ok {
+		b[node] = &stats.Latencies{}
+	}
+	b[node].Append(latencies)
+}
+
+// Asymmetry returns the ratio of the slowest node's mean latency to the fastest node's
+// mean latency; 1.0 means all nodes performed identically. If fewer than two nodes
+// served requests then 1.0 is returned.
+func (b Breakdown) Asymmetry() float64 {
+	var slowest, fastest time.Duration
+	for _, latencies := range b {
+		mean := latencies.Mean()
+		if mean > slowest {
+			slowest = mean
+		}
+		if fastest == 0 || mean < fastest {
+			fastest = mean
+		}
+	}
+
+	if len(b) < 2 || fastest == 0 {
+		return 1.0
+	}
+	return float64(slowest) / float64(fastest)
+}
+
+func (b Breakdown) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]*stats.Latencies(b))
+}
@@ -0,0 +1,25 @@
+package placement_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/placement"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakdown(t *testing.T) {
+	breakdown := make(placement.Breakdown)
+	require.Equal(t, 1.0, breakdown.Asymmetry())
+
+	breakdown.Add("ensign-0", 10*time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, 1.0, breakdown.Asymmetry())
+
+	breakdown.Add("ensign-1", 30*time.Millisecond, 20*time.Millisecond)
+	breakdown.Add("", time.Millisecond)
+
+	require.Len(t, breakdown, 3)
+	require.Contains(t, breakdown, "unknown")
+	require.Equal(t, uint64(2), breakdown["ensign-1"].N())
+	require.InDelta(t, 25.0, breakdown.Asymmetry(), 1e-9)
+}
@@ -0,0 +1,25 @@
+package preflight
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify(t *testing.T) {
+	err := classify(status.Error(codes.PermissionDenied, "missing publisher permission"), ErrPublishDenied)
+	require.ErrorIs(t, err, ErrPublishDenied)
+	require.EqualError(t, err, "not authorized to publish to the topic: missing publisher permission")
+
+	err = classify(status.Error(codes.Unauthenticated, "invalid token"), ErrSubscribeDenied)
+	require.ErrorIs(t, err, ErrSubscribeDenied)
+
+	other := errors.New("connection refused")
+	require.Equal(t, other, classify(other, ErrPublishDenied))
+
+	unavailable := status.Error(codes.Unavailable, "server unavailable")
+	require.Equal(t, unavailable, classify(unavailable, ErrPublishDenied))
+}
@@ -0,0 +1,139 @@
+/*
+Package preflight verifies that a benchmark can publish to and consume from its topic
+before the measurement phase begins by sending a single canary event end-to-end. This
+allows authorization and reachability problems to fail fast with a specific error rather
+than surfacing as nacks or stream errors in the middle of a run.
+*/
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultTimeout is the maximum amount of time to wait for the canary to round trip.
+const DefaultTimeout = 30 * time.Second
+
+// How often the canary is checked for an ack from the publish stream.
+const ackPoll = 10 * time.Millisecond
+
+// The metadata key that identifies the canary on the subscribe stream.
+const canaryKey = "preflight"
+
+var (
+	ErrPublishDenied   = errors.New("not authorized to publish to the topic")
+	ErrSubscribeDenied = errors.New("not authorized to subscribe to the topic")
+	ErrCanaryNacked    = errors.New("canary event was rejected by the server")
+	ErrNoAck           = errors.New("canary event was not acked before the timeout")
+	ErrNoDelivery      = errors.New("canary event was not delivered to the subscriber before the timeout")
+)
+
+// Result describes a successful round trip of the canary event.
+type Result struct {
+	Topic    string        `json:"topic"`
+	Ack      time.Duration `json:"ack_latency"`
+	Delivery time.Duration `json:"delivery_latency"`
+}
+
+// Probe subscribes to the topic, publishes a canary event, and waits for the canary to
+// be acked by the publish stream and delivered on the subscribe stream. The topic may be
+// a topic name or ID. If the context does not have a deadline, DefaultTimeout is used.
+func Probe(ctx context.Context, client *ensign.Client, topic string) (result *Result, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	// Subscriptions require topic IDs so resolve the topic name if necessary
+	topicID := topic
+	if _, perr := ulid.Parse(topic); perr != nil {
+		if topicID, err = client.TopicID(ctx, topic); err != nil {
+			return nil, classify(err, ErrSubscribeDenied)
+		}
+	}
+
+	var sub *ensign.Subscription
+	if sub, err = client.Subscribe(topicID); err != nil {
+		return nil, classify(err, ErrSubscribeDenied)
+	}
+	defer sub.Close()
+
+	canaryID := ulid.Make().String()
+	canary := &ensign.Event{
+		Data:     []byte("enbench preflight canary"),
+		Metadata: map[string]string{"app": "enbench", canaryKey: canaryID},
+		Mimetype: mimetype.TextPlain,
+		Type:     &api.Type{Name: "Canary", MajorVersion: 1},
+	}
+
+	result = &Result{Topic: topic}
+	started := time.Now()
+	if err = client.Publish(topic, canary); err != nil {
+		return nil, classify(err, ErrPublishDenied)
+	}
+
+	// Wait for the canary to be acked by the server
+	poll := time.NewTicker(ackPoll)
+	defer poll.Stop()
+
+ack:
+	for {
+		select {
+		case <-poll.C:
+			var acked, nacked bool
+			if acked, err = canary.Acked(); acked {
+				result.Ack = time.Since(started)
+				break ack
+			}
+
+			if nacked, err = canary.Nacked(); nacked {
+				return nil, fmt.Errorf("%w: %s", ErrCanaryNacked, err)
+			}
+
+			if err != nil {
+				return nil, classify(err, ErrPublishDenied)
+			}
+		case <-ctx.Done():
+			return nil, ErrNoAck
+		}
+	}
+
+	// Wait for the canary to be delivered to the subscriber, acking any events received
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil, ErrNoDelivery
+			}
+
+			event.Ack()
+			if event.Metadata[canaryKey] == canaryID {
+				result.Delivery = time.Since(started)
+				return result, nil
+			}
+		case <-ctx.Done():
+			return nil, ErrNoDelivery
+		}
+	}
+}
+
+// Wraps gRPC authorization errors with the specified sentinel so that callers can
+// identify the failed permission with errors.Is.
+func classify(err, denied error) error {
+	switch status.Code(err) {
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return fmt.Errorf("%w: %s", denied, status.Convert(err).Message())
+	default:
+		return err
+	}
+}
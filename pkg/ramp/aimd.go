@@ -0,0 +1,354 @@
+package ramp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// Default AIMD configuration: start with a single in-flight publish, add one every
+// second that the p99 latency is under 100ms and halve the concurrency otherwise.
+const (
+	DefaultStartConcurrency = 1
+	DefaultMaxConcurrency   = 1000
+	DefaultIncrease         = 1.0
+	DefaultDecrease         = 0.5
+	DefaultWindow           = time.Second
+	DefaultAIMDDuration     = time.Minute
+)
+
+// Controller adjusts a concurrency limit using additive increase, multiplicative
+// decrease (AIMD): the limit grows by a constant after every window that meets the
+// latency ceiling and is cut by a factor after every window that is congested.
+type Controller struct {
+	Min      int
+	Max      int
+	Increase float64 // added to the limit after a window that is not congested
+	Decrease float64 // the limit is multiplied by this factor after a congested window
+	limit    float64
+}
+
+// NewController creates an AIMD controller whose limit starts at the specified value.
+func NewController(start, max int, increase, decrease float64) *Controller {
+	return &Controller{Min: 1, Max: max, Increase: increase, Decrease: decrease, limit: float64(start)}
+}
+
+// Limit returns the current concurrency limit.
+func (c *Controller) Limit() int {
+	return int(c.limit)
+}
+
+// Observe the outcome of a window and return the new concurrency limit.
+func (c *Controller) Observe(congested bool) int {
+	if congested {
+		c.limit = math.Floor(c.limit * c.Decrease)
+	} else {
+		c.limit += c.Increase
+	}
+
+	if c.limit < float64(c.Min) {
+		c.limit = float64(c.Min)
+	}
+	if c.Max > 0 && c.limit > float64(c.Max) {
+		c.limit = float64(c.Max)
+	}
+	return c.Limit()
+}
+
+// Window records the outcome of publishing with a fixed concurrency limit.
+type Window struct {
+	Concurrency int              // the maximum number of in-flight publishes
+	Published   uint64           // the number of events published during the window
+	Acked       uint64           // the number of events acked during the window
+	Nacked      uint64           // the number of events nacked during the window
+	Errors      uint64           // the number of events that could not be published
+	Throughput  float64          // the acked events per second over the window
+	Latency     time.Duration    // the publish-to-ack latency at the ceiling percentile
+	Latencies   *stats.Latencies // the distribution of publish-to-ack latencies
+	Congested   bool             // true if the latency exceeded the ceiling or events failed
+}
+
+// Serializes the window into a JSON map with durations as strings.
+func (w *Window) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["concurrency"] = w.Concurrency
+	data["published"] = w.Published
+	data["acked"] = w.Acked
+	data["nacked"] = w.Nacked
+	data["errors"] = w.Errors
+	data["throughput"] = w.Throughput
+	data["latency"] = w.Latency.String()
+	data["latencies"] = w.Latencies
+	data["congested"] = w.Congested
+	return json.Marshal(data)
+}
+
+// AIMD discovers the concurrency that maximizes throughput subject to a latency ceiling
+// by adjusting the number of in-flight publishes rather than the publish rate. Because
+// streaming publishes are limited by the number of unacked events, the concurrency
+// often converges more stably than the rate; the converged concurrency is the mean
+// limit of the windows after the first congestion signal, where the controller
+// oscillates around the server's capacity.
+type AIMD struct {
+	StartConcurrency int
+	MaxConcurrency   int
+	Increase         float64
+	Decrease         float64
+	Window           time.Duration
+	Duration         time.Duration
+	Ceiling          time.Duration // the maximum latency at the percentile for a window to pass
+	Percentile       float64       // the percentile of the publish-to-ack latency checked against the ceiling
+	OnWindow         func(*Window) // called with each window as it closes, e.g. to stream the windows to disk
+
+	opts      *options.Options
+	client    *ensign.Client
+	windows   []*Window
+	latencies *stats.Latencies
+	started   time.Time
+	duration  time.Duration
+	reason    string
+	progress  stats.Progress
+}
+
+func NewAIMD(opts *options.Options) *AIMD {
+	return &AIMD{
+		StartConcurrency: DefaultStartConcurrency,
+		MaxConcurrency:   DefaultMaxConcurrency,
+		Increase:         DefaultIncrease,
+		Decrease:         DefaultDecrease,
+		Window:           DefaultWindow,
+		Duration:         DefaultAIMDDuration,
+		Ceiling:          DefaultSLO,
+		Percentile:       DefaultPercentile,
+		opts:             opts,
+	}
+}
+
+func (b *AIMD) Run(ctx context.Context) (err error) {
+	if b.StartConcurrency < 1 || (b.MaxConcurrency > 0 && b.MaxConcurrency < b.StartConcurrency) {
+		return errors.New("the start concurrency must be positive and no greater than the max concurrency")
+	}
+
+	if b.Increase <= 0 || b.Decrease <= 0 || b.Decrease >= 1 {
+		return errors.New("the increase must be positive and the decrease must be between 0 and 1")
+	}
+
+	if b.Window <= 0 || b.Duration < b.Window {
+		return errors.New("the window must be positive and no longer than the duration")
+	}
+
+	if b.Percentile <= 0 || b.Percentile > 100 {
+		return errors.New("the ceiling percentile must be between 0 and 100")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	ctrl := NewController(b.StartConcurrency, b.MaxConcurrency, b.Increase, b.Decrease)
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	acks := newCollector()
+
+	b.windows = make([]*Window, 0)
+	b.latencies = &stats.Latencies{}
+	b.reason = benchmarks.ExitCompleted
+	b.started = time.Now()
+	b.progress.Start()
+	b.progress.Set("concurrency", uint64(ctrl.Limit()))
+	defer func() {
+		b.duration = time.Since(b.started)
+	}()
+
+	runctx, cancel := context.WithTimeout(ctx, b.Duration)
+	defer cancel()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		acks.run(done)
+	}()
+
+	ticker := time.NewTicker(b.Window)
+	defer ticker.Stop()
+
+	published := uint64(0)
+	window := &Window{Concurrency: ctrl.Limit()}
+	last := b.started
+
+publish:
+	for {
+		if reason := b.opts.Exhausted(b.started, published); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		// Publish while there is room under the concurrency limit, otherwise wait until
+		// in-flight events are resolved or the window closes.
+		if acks.outstanding() < ctrl.Limit() {
+			select {
+			case <-runctx.Done():
+				break publish
+			case now := <-ticker.C:
+				b.close(window, acks, now.Sub(last), ctrl)
+				window, last = &Window{Concurrency: ctrl.Limit()}, now
+			default:
+				event := factory()
+				window.Published++
+				published++
+				b.progress.Add("published", 1)
+				if err = b.client.Publish(b.opts.TopicRef(), event); err != nil {
+					window.Errors++
+					b.progress.Add("errors", 1)
+					continue
+				}
+				acks.add(event)
+			}
+			continue
+		}
+
+		select {
+		case <-runctx.Done():
+			break publish
+		case now := <-ticker.C:
+			b.close(window, acks, now.Sub(last), ctrl)
+			window, last = &Window{Concurrency: ctrl.Limit()}, now
+		case <-acks.freed:
+		}
+	}
+
+	// Stop if the parent context was canceled, otherwise the run is over
+	if err = ctx.Err(); err != nil {
+		b.reason = benchmarks.ExitCanceled
+		close(done)
+		<-stopped
+		return err
+	}
+
+	// Events of the last partial window are included in the overall latencies only
+	acks.drain(stepDrain)
+	close(done)
+	<-stopped
+
+	latencies, _, _ := acks.results()
+	b.latencies.Append(latencies)
+	b.latencies.SetDuration(time.Since(b.started))
+	return nil
+}
+
+// Closes the window, recording its latencies and adjusting the concurrency limit.
+func (b *AIMD) close(window *Window, acks *collector, duration time.Duration, ctrl *Controller) {
+	window.Latencies, window.Acked, window.Nacked = acks.flush()
+	window.Latencies.SetDuration(duration)
+	window.Latency = window.Latencies.Percentile(b.Percentile)
+	window.Throughput = float64(window.Acked) / duration.Seconds()
+	window.Congested = window.Latency > b.Ceiling || window.Nacked > 0 || window.Errors > 0
+
+	b.windows = append(b.windows, window)
+	b.latencies.Append(window.Latencies)
+	if b.OnWindow != nil {
+		b.OnWindow(window)
+	}
+
+	limit := ctrl.Observe(window.Congested)
+	b.progress.Add("windows", 1)
+	b.progress.Set("concurrency", uint64(limit))
+	log.Debug().
+		Int("concurrency", window.Concurrency).
+		Float64("throughput", window.Throughput).
+		Dur("latency", window.Latency).
+		Bool("congested", window.Congested).
+		Int("limit", limit).
+		Msg("aimd window complete")
+}
+
+func (b *AIMD) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	concurrency, throughput, converged := b.Converged()
+
+	results["windows"] = b.windows
+	results["latencies"] = b.latencies
+	results["converged"] = converged
+	results["converged_concurrency"] = concurrency
+	results["converged_throughput"] = throughput
+	results["exit_reason"] = b.reason
+	results["duration"] = b.duration.String()
+
+	experiment := map[string]interface{}{
+		"client_version":    benchmarks.Version(),
+		"endpoint":          b.opts.Endpoint,
+		"topic":             b.opts.Topic,
+		"data_size":         b.opts.DataSize,
+		"start_concurrency": b.StartConcurrency,
+		"max_concurrency":   b.MaxConcurrency,
+		"increase":          b.Increase,
+		"decrease":          b.Decrease,
+		"window":            b.Window.String(),
+		"ceiling":           b.Ceiling.String(),
+		"percentile":        b.Percentile,
+		"guard":             b.opts.Guard(),
+		"minimal_metadata":  b.opts.MinimalMetadata,
+	}
+
+	// The wire size is measured from an event of the workload of the run
+	if event, err := sustain.SampleEvent(b.opts); err == nil {
+		experiment["wire_size"] = sustain.WireSize(event)
+	}
+	results["experiment"] = experiment
+	return results, nil
+}
+
+// Progress returns the number of windows closed and events published so far along with
+// the current concurrency limit of the controller.
+func (b *AIMD) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// Windows returns the outcome of each window of the last run.
+func (b *AIMD) Windows() []*Window {
+	return b.windows
+}
+
+// Converged returns the mean concurrency limit and throughput of the windows after the
+// first congested window. If no window was congested the controller did not find the
+// capacity of the server, so the limit and throughput of the last window are returned.
+func (b *AIMD) Converged() (concurrency, throughput float64, converged bool) {
+	for i, window := range b.windows {
+		if !window.Congested {
+			continue
+		}
+
+		steady := b.windows[i+1:]
+		if len(steady) == 0 {
+			break
+		}
+
+		for _, w := range steady {
+			concurrency += float64(w.Concurrency)
+			throughput += w.Throughput
+		}
+		return concurrency / float64(len(steady)), throughput / float64(len(steady)), true
+	}
+
+	if len(b.windows) > 0 {
+		last := b.windows[len(b.windows)-1]
+		return float64(last.Concurrency), last.Throughput, false
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,51 @@
+package ramp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestController(t *testing.T) {
+	ctrl := NewController(1, 10, 1, 0.5)
+	require.Equal(t, 1, ctrl.Limit())
+
+	// Additive increase while not congested, bounded by the maximum
+	for i := 2; i <= 10; i++ {
+		require.Equal(t, i, ctrl.Observe(false))
+	}
+	require.Equal(t, 10, ctrl.Observe(false), "limit should not exceed the max")
+
+	// Multiplicative decrease when congested, bounded by the minimum
+	require.Equal(t, 5, ctrl.Observe(true))
+	require.Equal(t, 2, ctrl.Observe(true))
+	require.Equal(t, 1, ctrl.Observe(true))
+	require.Equal(t, 1, ctrl.Observe(true), "limit should not drop below the min")
+}
+
+func TestConverged(t *testing.T) {
+	b := &AIMD{}
+	_, _, converged := b.Converged()
+	require.False(t, converged, "no windows")
+
+	b.windows = []*Window{
+		{Concurrency: 1, Throughput: 100},
+		{Concurrency: 2, Throughput: 200},
+	}
+	concurrency, throughput, converged := b.Converged()
+	require.False(t, converged, "no congested windows")
+	require.Equal(t, 2.0, concurrency)
+	require.Equal(t, 200.0, throughput)
+
+	// The steady state is the sawtooth after the first congested window
+	b.windows = append(b.windows,
+		&Window{Concurrency: 3, Throughput: 250, Congested: true},
+		&Window{Concurrency: 1, Throughput: 100},
+		&Window{Concurrency: 2, Throughput: 200},
+		&Window{Concurrency: 3, Throughput: 300, Congested: true},
+	)
+	concurrency, throughput, converged = b.Converged()
+	require.True(t, converged)
+	require.Equal(t, 2.0, concurrency)
+	require.Equal(t, 200.0, throughput)
+}
@@ -0,0 +1,259 @@
+/*
+Package ramp implements a benchmark that publishes at a low rate and increases the rate
+by a fixed increment at every step until the publish-to-ack latency violates the
+specified SLO or the maximum rate is reached. The highest rate at which the SLO was met
+is reported as the maximum sustainable throughput of the server.
+
+The package also implements an AIMD benchmark that complements the rate-based ramp by
+controlling the number of in-flight publishes instead of the publish rate.
+*/
+package ramp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/ratelimit"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// Default ramp configuration: +100 events/sec every 30 seconds until the p99 latency
+// exceeds 100ms or more than 1% of the events in a step fail.
+const (
+	DefaultStartRate    = 100.0
+	DefaultIncrement    = 100.0
+	DefaultMaxRate      = 10000.0
+	DefaultStepDuration = 30 * time.Second
+	DefaultSLO          = 100 * time.Millisecond
+	DefaultPercentile   = 99.0
+	DefaultThreshold    = 0.01
+)
+
+// How long to wait for acks of events published during a step once the step is over.
+const stepDrain = 5 * time.Second
+
+// Ramp publishes at linearly increasing rates to find the highest rate that meets the
+// latency SLO.
+type Ramp struct {
+	StartRate  float64
+	Increment  float64
+	MaxRate    float64
+	Step       time.Duration
+	SLO        time.Duration // the maximum latency at the percentile for a step to pass
+	Percentile float64       // the percentile of the publish-to-ack latency checked against the SLO
+	Threshold  float64       // the maximum fraction of failed events for a step to pass
+
+	opts        *options.Options
+	client      *ensign.Client
+	steps       []*Step
+	sustainable float64 // the highest achieved rate of a step that met the SLO
+	violated    float64 // the target rate of the first step that violated the SLO
+	started     time.Time
+	duration    time.Duration
+	reason      string
+	budget      *workload.Budget
+	progress    stats.Progress
+}
+
+func New(opts *options.Options) *Ramp {
+	return &Ramp{
+		StartRate:  DefaultStartRate,
+		Increment:  DefaultIncrement,
+		MaxRate:    DefaultMaxRate,
+		Step:       DefaultStepDuration,
+		SLO:        DefaultSLO,
+		Percentile: DefaultPercentile,
+		Threshold:  DefaultThreshold,
+		opts:       opts,
+	}
+}
+
+func (b *Ramp) Run(ctx context.Context) (err error) {
+	if b.StartRate <= 0 || b.MaxRate < b.StartRate {
+		return errors.New("the start rate must be positive and no greater than the max rate")
+	}
+
+	if b.Increment <= 0 {
+		return errors.New("the rate increment must be positive")
+	}
+
+	if b.Percentile <= 0 || b.Percentile > 100 {
+		return errors.New("the SLO percentile must be between 0 and 100")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	// The ramp cannot find a limit above the rate at which the client can generate events
+	warmup, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	b.budget = workload.MeasureBudget(workload.DefaultWarmup, b.MaxRate, func() { warmup() })
+	closer.Close()
+	b.budget.Check()
+
+	b.steps = make([]*Step, 0)
+	b.sustainable, b.violated = 0, 0
+	b.reason = benchmarks.ExitCompleted
+	b.started = time.Now()
+	b.progress.Start()
+	defer func() {
+		b.duration = time.Since(b.started)
+	}()
+
+	published := uint64(0)
+	for rate := b.StartRate; rate <= b.MaxRate; rate += b.Increment {
+		if reason := b.opts.Exhausted(b.started, published); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		b.progress.Set("target_rate", uint64(rate))
+		var step *Step
+		if step, err = b.run(ctx, rate); err != nil {
+			b.reason = benchmarks.ExitCanceled
+			return err
+		}
+		b.steps = append(b.steps, step)
+		published += step.Published
+		b.progress.Add("steps", 1)
+
+		log.Info().
+			Float64("target_rate", step.Target).
+			Float64("achieved_rate", step.Achieved).
+			Dur("latency", step.Latency).
+			Uint64("failures", step.Failures()).
+			Bool("violated", step.Violated).
+			Msg("ramp step complete")
+
+		if step.Violated {
+			b.violated = step.Target
+			break
+		}
+
+		if step.Achieved > b.sustainable {
+			b.sustainable = step.Achieved
+			b.progress.Set("sustainable_rate", uint64(step.Achieved))
+		}
+	}
+	return nil
+}
+
+// Publishes at the target rate for the duration of a step and waits for acks.
+func (b *Ramp) run(ctx context.Context, rate float64) (step *Step, err error) {
+	step = &Step{Target: rate}
+	limiter := ratelimit.New(rate, rate/10)
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	acks := newCollector()
+
+	stepctx, cancel := context.WithTimeout(ctx, b.Step)
+	defer cancel()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		acks.run(done)
+	}()
+
+	started := time.Now()
+	for {
+		if _, err = limiter.Wait(stepctx); err != nil {
+			break
+		}
+
+		event := factory()
+		step.Published++
+		b.progress.Add("published", 1)
+		if err = b.client.Publish(b.opts.TopicRef(), event); err != nil {
+			step.Errors++
+			b.progress.Add("errors", 1)
+			continue
+		}
+		acks.add(event)
+	}
+
+	// The step is over when its context expires; any other error is returned
+	if err = ctx.Err(); err != nil {
+		close(done)
+		<-stopped
+		return nil, err
+	}
+
+	acks.drain(stepDrain)
+	close(done)
+	<-stopped
+
+	step.Latencies, step.Acked, step.Nacked = acks.results()
+	step.Latencies.SetDuration(time.Since(started))
+	step.Latency = step.Latencies.Percentile(b.Percentile)
+	step.Achieved = float64(step.Acked) / b.Step.Seconds()
+	step.Violated = step.violates(b.SLO, b.Threshold)
+	return step, nil
+}
+
+func (b *Ramp) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["steps"] = b.steps
+	results["max_sustainable_rate"] = b.sustainable
+	results["violated_rate"] = b.violated
+	results["detected"] = b.violated > 0
+	results["exit_reason"] = b.reason
+	results["duration"] = b.duration.String()
+
+	experiment := map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"data_size":        b.opts.DataSize,
+		"start_rate":       b.StartRate,
+		"increment":        b.Increment,
+		"max_rate":         b.MaxRate,
+		"step":             b.Step.String(),
+		"slo":              b.SLO.String(),
+		"percentile":       b.Percentile,
+		"threshold":        b.Threshold,
+		"guard":            b.opts.Guard(),
+		"generation":       b.budget,
+		"minimal_metadata": b.opts.MinimalMetadata,
+	}
+
+	// The wire size is measured from an event of the workload of the run
+	if event, err := sustain.SampleEvent(b.opts); err == nil {
+		experiment["wire_size"] = sustain.WireSize(event)
+	}
+	results["experiment"] = experiment
+	return results, nil
+}
+
+// Progress returns the number of steps completed and events published so far along with
+// the target rate of the current step and the highest sustainable rate found so far.
+func (b *Ramp) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// Steps returns the outcome of each step of the last run.
+func (b *Ramp) Steps() []*Step {
+	return b.steps
+}
+
+// Sustainable returns the highest rate achieved by a step that met the SLO.
+func (b *Ramp) Sustainable() float64 {
+	return b.sustainable
+}
@@ -0,0 +1,174 @@
+package ramp
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+)
+
+// How often in-flight events are checked for acks while a step is running.
+const ackPoll = time.Millisecond
+
+// Step records the outcome of publishing at a single target rate.
+type Step struct {
+	Target    float64          // the target publish rate in events per second
+	Achieved  float64          // the acked events per second over the step
+	Published uint64           // the number of events published during the step
+	Acked     uint64           // the number of events acked by the server
+	Nacked    uint64           // the number of events nacked by the server
+	Errors    uint64           // the number of events that could not be published
+	Latency   time.Duration    // the publish-to-ack latency at the SLO percentile
+	Latencies *stats.Latencies // the distribution of publish-to-ack latencies
+	Violated  bool             // true if the latency or failures of the step violated the SLO
+}
+
+// Failures returns the number of events that were not acked by the server.
+func (s *Step) Failures() uint64 {
+	return s.Published - s.Acked
+}
+
+// A step violates the SLO if the latency at the percentile exceeds the SLO or if the
+// fraction of events that were not acked exceeds the threshold.
+func (s *Step) violates(slo time.Duration, threshold float64) bool {
+	if s.Latency > slo {
+		return true
+	}
+
+	if s.Published == 0 {
+		return false
+	}
+	return float64(s.Failures())/float64(s.Published) > threshold
+}
+
+// Serializes the step into a JSON map with durations as strings.
+func (s *Step) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["target_rate"] = s.Target
+	data["achieved_rate"] = s.Achieved
+	data["published"] = s.Published
+	data["acked"] = s.Acked
+	data["nacked"] = s.Nacked
+	data["errors"] = s.Errors
+	data["latency"] = s.Latency.String()
+	data["latencies"] = s.Latencies
+	data["violated"] = s.Violated
+	return json.Marshal(data)
+}
+
+// collector records the publish-to-ack latency of in-flight events by polling them
+// for acks concurrently with publishing so that the latency resolution is not bounded
+// by the publish rate of the step.
+type collector struct {
+	sync.Mutex
+	inflight  []*pending
+	latencies *stats.Latencies
+	acked     uint64
+	nacked    uint64
+	freed     chan struct{} // signaled when in-flight events are resolved
+}
+
+type pending struct {
+	event *ensign.Event
+	sent  time.Time
+}
+
+func newCollector() *collector {
+	return &collector{latencies: &stats.Latencies{}, freed: make(chan struct{}, 1)}
+}
+
+// Adds a published event to the in-flight events.
+func (c *collector) add(event *ensign.Event) {
+	c.Lock()
+	defer c.Unlock()
+	c.inflight = append(c.inflight, &pending{event: event, sent: time.Now()})
+}
+
+// Polls the in-flight events for acks until done is closed.
+func (c *collector) run(done <-chan struct{}) {
+	ticker := time.NewTicker(ackPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.collect()
+		case <-done:
+			return
+		}
+	}
+}
+
+// Waits until all in-flight events have been resolved or the timeout has passed.
+func (c *collector) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for c.outstanding() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * ackPoll)
+	}
+}
+
+// Removes acked and nacked events from the in-flight events, recording latencies.
+func (c *collector) collect() {
+	c.Lock()
+	defer c.Unlock()
+
+	pending := c.inflight[:0]
+	for _, p := range c.inflight {
+		if acked, _ := p.event.Acked(); acked {
+			c.acked++
+			c.latencies.Update(time.Since(p.sent))
+			continue
+		}
+
+		if nacked, _ := p.event.Nacked(); nacked {
+			c.nacked++
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	// Clear references to resolved events so they can be garbage collected.
+	resolved := len(pending) < len(c.inflight)
+	for i := len(pending); i < len(c.inflight); i++ {
+		c.inflight[i] = nil
+	}
+	c.inflight = pending
+
+	if resolved {
+		select {
+		case c.freed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *collector) outstanding() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.inflight)
+}
+
+// Returns the latencies and counts of the collected events; events that are still in
+// flight are recorded as timeouts.
+func (c *collector) results() (latencies *stats.Latencies, acked, nacked uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	for range c.inflight {
+		c.latencies.Update(0)
+	}
+	return c.latencies, c.acked, c.nacked
+}
+
+// Returns the latencies and counts of the events resolved since the last flush and
+// resets them; events that are still in flight are not included.
+func (c *collector) flush() (latencies *stats.Latencies, acked, nacked uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	latencies, acked, nacked = c.latencies, c.acked, c.nacked
+	c.latencies, c.acked, c.nacked = &stats.Latencies{}, 0, 0
+	return latencies, acked, nacked
+}
@@ -0,0 +1,26 @@
+package ramp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepViolates(t *testing.T) {
+	slo := 100 * time.Millisecond
+
+	step := &Step{Published: 1000, Acked: 995, Nacked: 5, Latency: 20 * time.Millisecond}
+	require.Equal(t, uint64(5), step.Failures())
+	require.False(t, step.violates(slo, 0.01), "step within the slo and threshold")
+
+	step.Latency = 150 * time.Millisecond
+	require.True(t, step.violates(slo, 0.01), "latency exceeds the slo")
+
+	step.Latency = 20 * time.Millisecond
+	step.Acked = 900
+	require.True(t, step.violates(slo, 0.01), "failures exceed the threshold")
+
+	empty := &Step{}
+	require.False(t, empty.violates(slo, 0.01), "a step with no events does not violate the slo")
+}
@@ -0,0 +1,88 @@
+/*
+Package ratelimit implements a token bucket limiter that is used by benchmarks to pace
+operations, e.g. to limit the number of bytes consumed per second to emulate a slow
+downstream network or to publish events at a fixed rate.
+*/
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that is refilled at a constant rate up to a maximum burst.
+// Requests for more tokens than are available are allowed to go into debt so that
+// requests larger than the burst (e.g. a single large event) are delayed rather than
+// rejected; subsequent requests wait until the debt has been repaid.
+type Limiter struct {
+	sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // maximum number of tokens in the bucket
+	tokens float64 // current number of tokens, negative if in debt
+	last   time.Time
+}
+
+// New creates a limiter that allows rate tokens per second with the specified burst.
+// If burst is zero or less, the burst is equal to one second worth of tokens.
+func New(rate, burst float64) *Limiter {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &Limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Rate returns the number of tokens per second allowed by the limiter.
+func (l *Limiter) Rate() float64 {
+	return l.rate
+}
+
+// Reserve n tokens and return how long the caller must wait before acting.
+func (l *Limiter) Reserve(n int) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// WaitN blocks until n tokens are available or the context is done, returning the
+// amount of time waited. If the context is canceled the reserved tokens are not
+// returned to the bucket.
+func (l *Limiter) WaitN(ctx context.Context, n int) (time.Duration, error) {
+	delay := l.Reserve(n)
+	if delay == 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Wait blocks until a single token is available.
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	return l.WaitN(ctx, 1)
+}
@@ -0,0 +1,46 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := ratelimit.New(1000, 100)
+	require.Equal(t, 1000.0, limiter.Rate())
+
+	// The initial burst should be allowed immediately
+	require.Equal(t, time.Duration(0), limiter.Reserve(100))
+
+	// Requests beyond the burst go into debt and must wait for the tokens to refill
+	delay := limiter.Reserve(500)
+	require.InDelta(t, 500*time.Millisecond, delay, float64(5*time.Millisecond))
+
+	// Requests made while in debt must wait for the debt to be repaid
+	delay = limiter.Reserve(100)
+	require.InDelta(t, 600*time.Millisecond, delay, float64(5*time.Millisecond))
+}
+
+func TestLimiterWait(t *testing.T) {
+	limiter := ratelimit.New(100, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := limiter.Wait(ctx)
+		require.NoError(t, err)
+	}
+
+	// The first token is available immediately, the remaining 5 at 10ms intervals
+	require.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	limiter.Reserve(1000)
+	_, err := limiter.WaitN(ctx, 1)
+	require.ErrorIs(t, err, context.Canceled)
+}
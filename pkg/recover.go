@@ -0,0 +1,74 @@
+package benchmarks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error of a benchmark run that panicked, capturing the value passed
+// to panic and the stack of the goroutine that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recovered returns the panic error of a value returned by recover, capturing the stack
+// of the current goroutine, which must be the goroutine that panicked; a recovered
+// PanicError is returned as is so that it can be re-raised in another goroutine, e.g.
+// from a worker of the run into the goroutine of Run, without losing the original stack.
+func Recovered(r interface{}) *PanicError {
+	if err, ok := r.(*PanicError); ok {
+		return err
+	}
+	return &PanicError{Value: r, Stack: debug.Stack()}
+}
+
+// Safely calls the function, returning a *PanicError if it panics rather than crashing
+// the process so that the partial results of a long run can still be reported.
+func Safely(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Recovered(r)
+		}
+	}()
+	return fn()
+}
+
+// Recover runs the benchmark, recovering a panic of the run so that the data of a long
+// run is not lost. If the run panics, the partial results of the run are collected and
+// returned with the *PanicError; metrics that can be set record the panicked exit reason
+// and the value of the panic. The partial results may be inconsistent so they are also
+// collected safely, and are nil if they could not be collected. The results are only
+// collected if the run panicked, otherwise the error of the run is returned.
+func Recover(ctx context.Context, run func(context.Context) error, results func() (Metrics, error)) (partial Metrics, err error) {
+	err = Safely(func() error { return run(ctx) })
+
+	var panicked *PanicError
+	if !errors.As(err, &panicked) {
+		return nil, err
+	}
+
+	if err = Safely(func() (err error) {
+		partial, err = results()
+		return err
+	}); err != nil {
+		return nil, errors.Join(panicked, fmt.Errorf("could not collect the partial results of the run: %w", err))
+	}
+
+	if m, ok := partial.(setter); ok {
+		m.Set("exit_reason", ExitPanicked)
+		m.Set("panic", fmt.Sprint(panicked.Value))
+	}
+	return partial, panicked
+}
+
+// Implemented by metrics to which measurements can be added, e.g. metrics.Metrics.
+type setter interface {
+	Set(name string, value interface{})
+}
@@ -0,0 +1,226 @@
+/*
+The replay package implements a benchmark that publishes a pre-generated stream of
+events (e.g. a testdata file or events piped from another tool on stdin) to a topic so
+that the exact same event stream can be published across benchmark runs.
+*/
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// The maximum number of unresolved events before the replay checks for acks. Replays
+// are read as a stream so resolved events must be released to keep memory bounded.
+const collectEvery = 4096
+
+// How long to wait for outstanding acks after the stream has been exhausted.
+const drainTimeout = 30 * time.Second
+
+// Replay publishes every event read from the source to the benchmark topic.
+type Replay struct {
+	opts     *options.Options
+	source   *workload.StreamReader
+	client   *ensign.Client
+	inflight []*ensign.Event
+	events   uint64
+	acks     uint64
+	nacks    uint64
+	bytes    uint64
+	started  time.Time
+	duration time.Duration
+	reason   string
+	progress stats.Progress
+}
+
+func New(opts *options.Options, source *workload.StreamReader) *Replay {
+	return &Replay{opts: opts, source: source}
+}
+
+func (b *Replay) Run(ctx context.Context) (err error) {
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+
+	b.inflight = make([]*ensign.Event, 0, collectEvery)
+	b.events, b.acks, b.nacks, b.bytes = 0, 0, 0, 0
+	b.reason = benchmarks.ExitCompleted
+	b.progress.Start()
+	b.started = time.Now()
+	defer func() {
+		b.duration = time.Since(b.started)
+	}()
+
+	topic := b.opts.TopicRef()
+	log.Info().Str("topic", topic).Msg("replay benchmark starting")
+
+replay:
+	for {
+		select {
+		case <-quit:
+			b.reason = benchmarks.ExitInterrupted
+			break replay
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		default:
+		}
+
+		var wrapper *api.EventWrapper
+		if wrapper, err = b.source.Read(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break replay
+			}
+			return err
+		}
+
+		var event *ensign.Event
+		if event, err = convert(wrapper); err != nil {
+			return err
+		}
+
+		if b.opts.MaxBytes > 0 && b.bytes+uint64(len(event.Data)) > b.opts.MaxBytes {
+			b.reason = benchmarks.ExitMaxBytes
+			break replay
+		}
+
+		if reason := b.opts.Exhausted(b.started, b.events); reason != "" {
+			b.reason = reason
+			break replay
+		}
+
+		if err = b.client.Publish(topic, event); err != nil {
+			return err
+		}
+
+		b.events++
+		b.bytes += uint64(len(event.Data))
+		b.progress.Add("published", 1)
+		b.progress.Add("bytes", uint64(len(event.Data)))
+		b.inflight = append(b.inflight, event)
+
+		if b.opts.Operations > 0 && b.events >= b.opts.Operations {
+			break replay
+		}
+
+		if len(b.inflight) >= collectEvery {
+			b.collect()
+		}
+	}
+
+	// Wait for the outstanding events to be acked by the server
+	deadline := time.Now().Add(drainTimeout)
+	for len(b.inflight) > 0 && time.Now().Before(deadline) {
+		b.collect()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	log.Info().
+		Uint64("events", b.events).
+		Uint64("acks", b.acks).
+		Uint64("nacks", b.nacks).
+		Int("unresolved", len(b.inflight)).
+		Str("exit_reason", b.reason).
+		Msg("replay benchmark complete")
+	return nil
+}
+
+// Checks all in-flight events for acks or nacks, releasing resolved events.
+func (b *Replay) collect() {
+	pending := b.inflight[:0]
+	for _, event := range b.inflight {
+		acked, _ := event.Acked()
+		if acked {
+			b.acks++
+			b.progress.Add("acks", 1)
+			continue
+		}
+
+		if nacked, _ := event.Nacked(); nacked {
+			b.nacks++
+			b.progress.Add("nacks", 1)
+			continue
+		}
+		pending = append(pending, event)
+	}
+
+	for i := len(pending); i < len(b.inflight); i++ {
+		b.inflight[i] = nil
+	}
+	b.inflight = pending
+}
+
+// Converts a serialized event wrapper into an event that can be published; fields that
+// are assigned by the server such as the event ID and topic are discarded.
+func convert(wrapper *api.EventWrapper) (_ *ensign.Event, err error) {
+	var event *api.Event
+	if event, err = wrapper.Unwrap(); err != nil {
+		return nil, err
+	}
+
+	out := &ensign.Event{
+		Metadata: event.Metadata,
+		Data:     event.Data,
+		Mimetype: event.Mimetype,
+		Type:     event.Type,
+	}
+
+	if event.Created != nil {
+		out.Created = event.Created.AsTime()
+	}
+	return out, nil
+}
+
+// Progress returns the number of events published and resolved so far.
+func (b *Replay) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+func (b *Replay) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["events"] = b.events
+	results["acks"] = b.acks
+	results["nacks"] = b.nacks
+	results["unresolved"] = len(b.inflight)
+	results["bytes"] = b.bytes
+	results["duration"] = b.duration.String()
+	results["exit_reason"] = b.reason
+
+	if secs := b.duration.Seconds(); secs > 0 {
+		results["throughput"] = float64(b.acks) / secs
+	}
+
+	bandwidth := &stats.Bandwidth{}
+	bandwidth.Sent(b.bytes)
+	bandwidth.SetDuration(b.duration)
+	results["bandwidth"] = bandwidth
+
+	results["experiment"] = map[string]interface{}{
+		"client_version": benchmarks.Version(),
+		"endpoint":       b.opts.Endpoint,
+		"topic":          b.opts.Topic,
+		"topic_id":       b.opts.TopicID,
+		"operations":     b.opts.Operations,
+		"max_bytes":      b.opts.MaxBytes,
+		"guard":          b.opts.Guard(),
+	}
+	return results, nil
+}
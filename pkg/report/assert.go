@@ -0,0 +1,160 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+)
+
+// ErrorRate is the name of the asserted fraction of the events of a run that failed or
+// timed out, which is computed from the failures, timeouts, and events of the run.
+const ErrorRate = "error_rate"
+
+// Assertion is an absolute bound on a metric of a run, e.g. a p99 latency of at most
+// 50ms, that is evaluated after the run so that a benchmark can be used as a CI gate.
+// The metric is the last component of a latency metric, e.g. p99 or throughput, or the
+// ErrorRate. Latency metrics are asserted on the latencies of the run if it reports
+// them, otherwise on every latency summary of the run (e.g. ack_latencies and
+// delivery_latencies) since benchmarks that measure several phases report no overall
+// latency. Durations are compared in seconds.
+type Assertion struct {
+	Metric   string
+	Limit    float64
+	Max      bool // the metric must not exceed the limit, otherwise it must reach it
+	Duration bool // the limit is a duration in seconds
+}
+
+// AssertMax returns an assertion that the duration metric does not exceed the limit.
+func AssertMax(metric string, limit time.Duration) Assertion {
+	return Assertion{Metric: metric, Limit: limit.Seconds(), Max: true, Duration: true}
+}
+
+// Outcome is the result of evaluating an assertion against a reported metric.
+type Outcome struct {
+	Assertion
+	Reported string // the flattened name of the asserted metric
+	Value    float64
+	Found    bool
+	Passed   bool
+}
+
+func (o Outcome) String() string {
+	status := "PASS"
+	if !o.Passed {
+		status = "FAIL"
+	}
+
+	if !o.Found {
+		return fmt.Sprintf("%s %s: not reported by the run (%s)", status, o.Reported, o.bound())
+	}
+	return fmt.Sprintf("%s %s: %s (%s)", status, o.Reported, o.format(o.Value), o.bound())
+}
+
+// Violation returns the violation of a failed assertion to be included in the report.
+func (o Outcome) Violation() Violation {
+	if !o.Found {
+		return Violation{Metric: o.Reported, Message: fmt.Sprintf("was not reported by the run (%s)", o.bound())}
+	}
+	return Violation{Metric: o.Reported, Message: fmt.Sprintf("%s is not %s", o.format(o.Value), o.bound())}
+}
+
+func (o Outcome) bound() string {
+	if o.Max {
+		return "at most " + o.format(o.Limit)
+	}
+	return "at least " + o.format(o.Limit)
+}
+
+func (o Outcome) format(val float64) string {
+	switch {
+	case o.Duration:
+		return FormatDuration(seconds(val))
+	case o.Metric == ErrorRate:
+		return strconv.FormatFloat(val*100, 'f', 2, 64) + "%"
+	default:
+		return Format(o.Metric, val)
+	}
+}
+
+// Assert evaluates the assertions against the metrics of the run, returning an outcome
+// for every asserted metric. An assertion of a metric that the run did not report fails
+// since the run cannot be shown to meet it.
+func Assert(m benchmarks.Metrics, assertions []Assertion) (outcomes []Outcome, err error) {
+	var flat map[string]interface{}
+	if flat, err = Flatten(m); err != nil {
+		return nil, err
+	}
+
+	for _, assertion := range assertions {
+		for _, metric := range asserted(flat, assertion.Metric) {
+			outcome := Outcome{Assertion: assertion, Reported: metric}
+			if assertion.Metric == ErrorRate {
+				outcome.Value, outcome.Found = errorRate(flat)
+			} else {
+				outcome.Value, _, outcome.Found = Numeric(flat[metric])
+			}
+
+			if outcome.Found {
+				if assertion.Max {
+					outcome.Passed = outcome.Value <= assertion.Limit
+				} else {
+					outcome.Passed = outcome.Value >= assertion.Limit
+				}
+			}
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes, nil
+}
+
+// Returns the flattened names of the metrics that the assertion of the metric applies to.
+func asserted(flat map[string]interface{}, metric string) []string {
+	if metric == ErrorRate {
+		return []string{ErrorRate}
+	}
+
+	if _, ok := flat["latencies."+metric]; ok {
+		return []string{"latencies." + metric}
+	}
+
+	var metrics []string
+	for _, key := range Keys(flat) {
+		if summary, last, ok := strings.Cut(key, "."); ok && last == metric && strings.HasSuffix(summary, "_latencies") {
+			metrics = append(metrics, key)
+		}
+	}
+
+	if len(metrics) == 0 {
+		return []string{"latencies." + metric}
+	}
+	return metrics
+}
+
+// Computes the fraction of the events of the run that failed or timed out. An event may
+// time out in each of the phases that a benchmark measures, so the most timeouts of any
+// latency summary are counted.
+func errorRate(flat map[string]interface{}) (_ float64, ok bool) {
+	if rate, _, ok := Numeric(flat[ErrorRate]); ok {
+		return rate, true
+	}
+
+	var events float64
+	if events, _, ok = Numeric(flat["events"]); !ok || events <= 0 {
+		return 0, false
+	}
+
+	failures, _, _ := Numeric(flat["failures"])
+
+	var timeouts float64
+	for key, val := range flat {
+		if summary, last, ok := strings.Cut(key, "."); ok && last == "timeouts" && (summary == "latencies" || strings.HasSuffix(summary, "_latencies")) {
+			if n, _, ok := Numeric(val); ok && n > timeouts {
+				timeouts = n
+			}
+		}
+	}
+	return (failures + timeouts) / events, true
+}
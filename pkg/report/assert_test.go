@@ -0,0 +1,63 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssert(t *testing.T) {
+	assertions := []Assertion{
+		AssertMax("p99", 50*time.Millisecond),
+		{Metric: "throughput", Limit: 5000},
+		{Metric: ErrorRate, Limit: 0.01, Max: true},
+	}
+
+	run := metrics.Metrics{
+		"events":    1000,
+		"failures":  5,
+		"latencies": map[string]interface{}{"p99": "12ms", "throughput": 4500.0, "timeouts": 10},
+		"nodes":     map[string]interface{}{"a": map[string]interface{}{"p99": "80ms"}},
+	}
+
+	outcomes, err := Assert(run, assertions)
+	require.NoError(t, err)
+	require.Len(t, outcomes, 3)
+
+	require.True(t, outcomes[0].Passed)
+	require.Equal(t, "PASS latencies.p99: 12ms (at most 50ms)", outcomes[0].String())
+
+	require.False(t, outcomes[1].Passed)
+	require.Equal(t, "FAIL latencies.throughput: 4,500 events/s (at least 5,000 events/s)", outcomes[1].String())
+	require.Equal(t, Violation{Metric: "latencies.throughput", Message: "4,500 events/s is not at least 5,000 events/s"}, outcomes[1].Violation())
+
+	require.False(t, outcomes[2].Passed)
+	require.InDelta(t, 0.015, outcomes[2].Value, 1e-9)
+	require.Equal(t, "FAIL error_rate: 1.50% (at most 1.00%)", outcomes[2].String())
+
+	// Without an overall latency every latency summary of the run is asserted
+	run = metrics.Metrics{
+		"events":             100,
+		"ack_latencies":      map[string]interface{}{"p99": "40ms", "timeouts": 1},
+		"delivery_latencies": map[string]interface{}{"p99": "60ms", "timeouts": 0},
+	}
+
+	outcomes, err = Assert(run, assertions)
+	require.NoError(t, err)
+	require.Len(t, outcomes, 4)
+	require.Equal(t, "ack_latencies.p99", outcomes[0].Reported)
+	require.True(t, outcomes[0].Passed)
+	require.Equal(t, "delivery_latencies.p99", outcomes[1].Reported)
+	require.False(t, outcomes[1].Passed)
+
+	// Metrics that were not reported fail their assertions
+	require.Equal(t, "latencies.throughput", outcomes[2].Reported)
+	require.False(t, outcomes[2].Found)
+	require.False(t, outcomes[2].Passed)
+	require.Equal(t, "FAIL latencies.throughput: not reported by the run (at least 5,000 events/s)", outcomes[2].String())
+
+	require.True(t, outcomes[3].Passed)
+	require.Equal(t, 0.01, outcomes[3].Value)
+}
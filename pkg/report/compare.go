@@ -0,0 +1,130 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+)
+
+// DefaultThreshold is the maximum percent change in the wrong direction that a compared
+// metric may have before it is considered a regression.
+const DefaultThreshold = 10.0
+
+// Compared metrics are matched by the last component of their flattened name so that
+// nested measurements (e.g. latencies.p99 or blast.latencies.p99) are all compared. A
+// regression is an increase of a latency or a decrease of a throughput.
+var compared = map[string]bool{
+	"throughput":          true,
+	"bandwidth":           true,
+	"sent_mb_per_sec":     true,
+	"received_mb_per_sec": true,
+	"mean":                false,
+	"p50":                 false,
+	"p90":                 false,
+	"p95":                 false,
+	"p99":                 false,
+	"p99_9":               false,
+}
+
+// ComparePresets map the name of a compare preset to the experiment parameter that runs
+// in the results store are grouped by, so that the most recent runs of two versions of
+// the client SDK or of the server can be compared with the same tooling.
+var ComparePresets = map[string]string{
+	"sdk":    "sdk_version",
+	"server": "server_version",
+}
+
+// Thresholds maps compared metrics to the maximum percent regression allowed. Keys are
+// either a full flattened metric name or the last component of the name (e.g. p99);
+// the full name takes precedence and Default is used for any other compared metric.
+type Thresholds struct {
+	Default float64
+	Metrics map[string]float64
+}
+
+// ParseThresholds parses comma separated metric=percent pairs, e.g. "p99=5,throughput=10".
+func ParseThresholds(s string, def float64) (thresholds Thresholds, err error) {
+	thresholds = Thresholds{Default: def, Metrics: make(map[string]float64)}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return Thresholds{}, fmt.Errorf("could not parse threshold %q: expected metric=percent", pair)
+		}
+
+		var percent float64
+		if percent, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(val), "%"), 64); err != nil || percent < 0 {
+			return Thresholds{}, fmt.Errorf("could not parse threshold %q: percent must be a non-negative number", pair)
+		}
+		thresholds.Metrics[strings.TrimSpace(key)] = percent
+	}
+	return thresholds, nil
+}
+
+// Threshold returns the maximum percent regression allowed for the metric.
+func (t Thresholds) Threshold(metric string) float64 {
+	if percent, ok := t.Metrics[metric]; ok {
+		return percent
+	}
+
+	if percent, ok := t.Metrics[name(metric)]; ok {
+		return percent
+	}
+	return t.Default
+}
+
+// Comparison is the change of the throughput and latency metrics between two runs.
+type Comparison struct {
+	Deltas      []Delta     `json:"deltas"`
+	Regressions []Violation `json:"regressions,omitempty"`
+}
+
+// Compare the throughput and latency metrics of the current run to the baseline run,
+// reporting a regression for every metric that changed in the wrong direction by more
+// than its threshold.
+func Compare(current, baseline benchmarks.Metrics, thresholds Thresholds) (_ *Comparison, err error) {
+	var cur, base map[string]interface{}
+	if cur, err = Flatten(current); err != nil {
+		return nil, err
+	}
+
+	if base, err = Flatten(baseline); err != nil {
+		return nil, err
+	}
+
+	comparison := &Comparison{Deltas: make([]Delta, 0)}
+	for _, delta := range Deltas(cur, base) {
+		higherIsBetter, ok := compared[name(delta.Metric)]
+		if !ok {
+			continue
+		}
+		comparison.Deltas = append(comparison.Deltas, delta)
+
+		regression := delta.Percent
+		if higherIsBetter {
+			regression = -regression
+		}
+
+		if threshold := thresholds.Threshold(delta.Metric); regression > threshold && !math.IsNaN(regression) {
+			comparison.Regressions = append(comparison.Regressions, Violation{
+				Metric:  delta.Metric,
+				Message: fmt.Sprintf("regressed by %.2f%% (threshold %.2f%%)", regression, threshold),
+			})
+		}
+	}
+	return comparison, nil
+}
+
+// Returns the last component of a flattened metric name.
+func name(metric string) string {
+	if idx := strings.LastIndex(metric, "."); idx >= 0 {
+		return metric[idx+1:]
+	}
+	return metric
+}
@@ -0,0 +1,53 @@
+package report_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	baseline := metrics.Metrics{
+		"latencies": map[string]interface{}{"mean": "10ms", "p99": "20ms", "throughput": 1000.0, "samples": 100},
+		"bandwidth": 8192.0,
+		"failures":  0,
+	}
+
+	current := metrics.Metrics{
+		"latencies": map[string]interface{}{"mean": "10.5ms", "p99": "30ms", "throughput": 1200.0, "samples": 200},
+		"bandwidth": 4096.0,
+		"failures":  10,
+	}
+
+	thresholds := report.Thresholds{Default: report.DefaultThreshold}
+	comparison, err := report.Compare(current, baseline, thresholds)
+	require.NoError(t, err)
+	require.Len(t, comparison.Deltas, 4, "only throughput and latency metrics are compared")
+
+	// Bandwidth decreased by 50% and the p99 increased by 50%; the mean increase is
+	// within the threshold and the throughput increase is an improvement.
+	require.Len(t, comparison.Regressions, 2)
+	require.Equal(t, "bandwidth", comparison.Regressions[0].Metric)
+	require.Equal(t, "latencies.p99", comparison.Regressions[1].Metric)
+
+	thresholds, err = report.ParseThresholds("p99=60, bandwidth=50%", report.DefaultThreshold)
+	require.NoError(t, err)
+	require.Equal(t, 60.0, thresholds.Threshold("latencies.p99"))
+	require.Equal(t, report.DefaultThreshold, thresholds.Threshold("latencies.mean"))
+
+	comparison, err = report.Compare(current, baseline, thresholds)
+	require.NoError(t, err)
+	require.Empty(t, comparison.Regressions)
+
+	thresholds, err = report.ParseThresholds("latencies.mean=1,mean=50", report.DefaultThreshold)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, thresholds.Threshold("latencies.mean"), "full names take precedence")
+	require.Equal(t, 50.0, thresholds.Threshold("blast.latencies.mean"))
+
+	_, err = report.ParseThresholds("p99", report.DefaultThreshold)
+	require.Error(t, err)
+	_, err = report.ParseThresholds("p99=-1", report.DefaultThreshold)
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Delta is the change in a single metric between a baseline and the current run.
+type Delta struct {
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Change   float64 `json:"change"`  // the absolute change, current - baseline
+	Percent  float64 `json:"percent"` // the change as a percentage of the baseline
+	Duration bool    `json:"-"`       // true if the values are durations in seconds
+}
+
+func (d Delta) String() string {
+	if d.Duration {
+		return fmt.Sprintf("%s: %s -> %s (%+.2f%%)", d.Metric, FormatDuration(seconds(d.Baseline)), FormatDuration(seconds(d.Current)), d.Percent)
+	}
+	return fmt.Sprintf("%s: %s -> %s (%+.2f%%)", d.Metric, Format(d.Metric, d.Baseline), Format(d.Metric, d.Current), d.Percent)
+}
+
+// Deltas computes the change of every numeric metric present in both the current and
+// baseline flattened metrics. Duration strings such as "1.2ms" are compared in
+// seconds. Metrics that are not numeric or only exist in one run are skipped.
+func Deltas(current, baseline map[string]interface{}) []Delta {
+	deltas := make([]Delta, 0, len(current))
+	for _, key := range Keys(current) {
+		cur, isDuration, ok := Numeric(current[key])
+		if !ok {
+			continue
+		}
+
+		base, _, ok := Numeric(baseline[key])
+		if !ok {
+			continue
+		}
+
+		delta := Delta{Metric: key, Baseline: base, Current: cur, Change: cur - base, Duration: isDuration}
+		switch {
+		case base != 0:
+			delta.Percent = (delta.Change / math.Abs(base)) * 100
+		case cur != 0:
+			delta.Percent = math.Inf(int(math.Copysign(1, cur)))
+		}
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+// Numeric converts a flattened metric value to a float64, returning true as the
+// second value if the metric was a duration (converted to seconds) and false as the
+// third value if the metric is not numeric.
+func Numeric(val interface{}) (_ float64, isDuration bool, ok bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, false, true
+	case float32:
+		return float64(v), false, true
+	case int:
+		return float64(v), false, true
+	case int64:
+		return float64(v), false, true
+	case uint64:
+		return float64(v), false, true
+	case time.Duration:
+		return v.Seconds(), true, true
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d.Seconds(), true, true
+		}
+	}
+	return 0, false, false
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
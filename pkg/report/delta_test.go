@@ -0,0 +1,42 @@
+package report_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltas(t *testing.T) {
+	baseline := map[string]interface{}{
+		"latencies.mean":       "10ms",
+		"latencies.throughput": 1000.0,
+		"failures":             0.0,
+		"exit_reason":          "completed",
+		"removed":              12.0,
+	}
+
+	current := map[string]interface{}{
+		"latencies.mean":       "12.5ms",
+		"latencies.throughput": 900.0,
+		"failures":             3.0,
+		"exit_reason":          "completed",
+		"added":                1.0,
+	}
+
+	deltas := report.Deltas(current, baseline)
+	require.Len(t, deltas, 3)
+
+	require.Equal(t, "failures", deltas[0].Metric)
+	require.True(t, math.IsInf(deltas[0].Percent, 1))
+
+	require.Equal(t, "latencies.mean", deltas[1].Metric)
+	require.True(t, deltas[1].Duration)
+	require.InDelta(t, 25.0, deltas[1].Percent, 1e-9)
+	require.Equal(t, "latencies.mean: 10ms -> 12.5ms (+25.00%)", deltas[1].String())
+
+	require.Equal(t, "latencies.throughput", deltas[2].Metric)
+	require.InDelta(t, -10.0, deltas[2].Percent, 1e-9)
+	require.Equal(t, -100.0, deltas[2].Change)
+}
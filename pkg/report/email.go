@@ -0,0 +1,203 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmpl "html/template"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	ttmpl "text/template"
+	"time"
+)
+
+// Environment variables used to configure the SMTP email reporter.
+const (
+	EnvSMTPHost     = "ENBENCH_SMTP_HOST"
+	EnvSMTPPort     = "ENBENCH_SMTP_PORT"
+	EnvSMTPUsername = "ENBENCH_SMTP_USERNAME"
+	EnvSMTPPassword = "ENBENCH_SMTP_PASSWORD"
+	EnvSMTPFrom     = "ENBENCH_SMTP_FROM"
+	EnvSMTPTo       = "ENBENCH_SMTP_TO"
+)
+
+// SMTPConfig specifies how to connect to the mail server and who to send reports to.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPConfigFromEnv loads the SMTP configuration from the environment; the recipients
+// in ENBENCH_SMTP_TO are comma separated.
+func SMTPConfigFromEnv() (conf SMTPConfig, err error) {
+	conf = SMTPConfig{
+		Host:     os.Getenv(EnvSMTPHost),
+		Port:     587,
+		Username: os.Getenv(EnvSMTPUsername),
+		Password: os.Getenv(EnvSMTPPassword),
+		From:     os.Getenv(EnvSMTPFrom),
+	}
+
+	if port := os.Getenv(EnvSMTPPort); port != "" {
+		if conf.Port, err = strconv.Atoi(port); err != nil {
+			return conf, fmt.Errorf("could not parse %s: %w", EnvSMTPPort, err)
+		}
+	}
+
+	for _, addr := range strings.Split(os.Getenv(EnvSMTPTo), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			conf.To = append(conf.To, addr)
+		}
+	}
+
+	return conf, conf.Validate()
+}
+
+func (c SMTPConfig) Validate() error {
+	if c.Host == "" || c.From == "" || len(c.To) == 0 {
+		return errors.New("smtp host, from, and to addresses are required to email reports")
+	}
+	return nil
+}
+
+// Email sends a plain-text and HTML summary of one or more benchmark reports (e.g.
+// all of the runs of a suite) including key metrics, deltas from the baseline run if
+// available, and any violations, for teams that don't monitor Slack or dashboards.
+type Email struct {
+	conf SMTPConfig
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewEmail(conf SMTPConfig) *Email {
+	return &Email{conf: conf, send: smtp.SendMail}
+}
+
+func (e *Email) Write(r *Report) error {
+	return e.Send(r)
+}
+
+// Send a single email summarizing all of the specified reports.
+func (e *Email) Send(reports ...*Report) (err error) {
+	var msg []byte
+	if msg, err = e.Message(reports...); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.conf.Username != "" {
+		auth = smtp.PlainAuth("", e.conf.Username, e.conf.Password, e.conf.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", e.conf.Host, e.conf.Port)
+	return e.send(addr, auth, e.conf.From, e.conf.To, msg)
+}
+
+// Summary is the data used to render a single report in the email templates.
+type Summary struct {
+	Benchmark  string
+	Metrics    [][2]string
+	Deltas     []Delta
+	Violations []Violation
+}
+
+// Message renders the MIME multipart/alternative email message for the reports.
+func (e *Email) Message(reports ...*Report) (_ []byte, err error) {
+	summaries := make([]Summary, 0, len(reports))
+	violations := 0
+	for _, r := range reports {
+		var flat map[string]interface{}
+		if flat, err = Flatten(r.Metrics); err != nil {
+			return nil, err
+		}
+
+		summary := Summary{Benchmark: r.Benchmark, Violations: r.Violations}
+		for _, key := range annotated {
+			if val, ok := flat[key]; ok {
+				summary.Metrics = append(summary.Metrics, [2]string{key, Format(key, val)})
+			}
+		}
+
+		if r.Baseline != nil {
+			var base map[string]interface{}
+			if base, err = Flatten(r.Baseline); err != nil {
+				return nil, err
+			}
+			summary.Deltas = Deltas(flat, base)
+		}
+
+		violations += len(r.Violations)
+		summaries = append(summaries, summary)
+	}
+
+	status := "passed"
+	if violations > 0 {
+		status = fmt.Sprintf("%d violation(s)", violations)
+	}
+
+	buf := &bytes.Buffer{}
+	body := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "From: %s\r\n", e.conf.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(e.conf.To, ", "))
+	fmt.Fprintf(buf, "Subject: [enbench] %d benchmark run(s) %s\r\n", len(summaries), status)
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", body.Boundary())
+
+	for _, part := range []struct {
+		mimetype string
+		render   func(*bytes.Buffer) error
+	}{
+		{"text/plain", func(w *bytes.Buffer) error { return textEmail.Execute(w, summaries) }},
+		{"text/html", func(w *bytes.Buffer) error { return htmlEmail.Execute(w, summaries) }},
+	} {
+		rendered := &bytes.Buffer{}
+		if err = part.render(rendered); err != nil {
+			return nil, err
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.mimetype+"; charset=utf-8")
+		w, err := body.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		w.Write(rendered.Bytes())
+	}
+
+	if err = body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var textEmail = ttmpl.Must(ttmpl.New("text").Parse(`{{ range . }}enbench {{ .Benchmark }} results
+{{ range .Metrics }}  {{ index . 0 }}: {{ index . 1 }}
+{{ end }}{{ if .Deltas }}
+Changes from previous run:
+{{ range .Deltas }}  {{ .String }}
+{{ end }}{{ end }}{{ if .Violations }}
+Violations:
+{{ range .Violations }}  {{ .String }}
+{{ end }}{{ end }}
+{{ end }}`))
+
+var htmlEmail = htmpl.Must(htmpl.New("html").Parse(`<html><body>{{ range . }}
+<h2>enbench {{ .Benchmark }} results</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Metric</th><th>Value</th></tr>{{ range .Metrics }}
+<tr><td>{{ index . 0 }}</td><td>{{ index . 1 }}</td></tr>{{ end }}
+</table>{{ if .Deltas }}
+<h3>Changes from previous run</h3>
+<ul>{{ range .Deltas }}<li>{{ .String }}</li>{{ end }}</ul>{{ end }}{{ if .Violations }}
+<h3>Violations</h3>
+<ul>{{ range .Violations }}<li><strong>{{ .Metric }}</strong> {{ .Message }}</li>{{ end }}</ul>{{ end }}
+{{ end }}</body></html>`))
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmail(t *testing.T) {
+	conf := SMTPConfig{Host: "smtp.example.com", Port: 2525, From: "bench@example.com", To: []string{"team@example.com", "ops@example.com"}}
+	require.NoError(t, conf.Validate())
+	require.Error(t, SMTPConfig{Host: "smtp.example.com"}.Validate())
+
+	var sent []byte
+	email := NewEmail(conf)
+	email.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		require.Equal(t, "smtp.example.com:2525", addr)
+		require.Equal(t, conf.From, from)
+		require.Equal(t, conf.To, to)
+		sent = msg
+		return nil
+	}
+
+	rep := &Report{
+		Benchmark:  "blast",
+		Metrics:    metrics.Metrics{"failures": 2, "latencies": map[string]interface{}{"mean": "12ms"}},
+		Baseline:   metrics.Metrics{"failures": 1, "latencies": map[string]interface{}{"mean": "10ms"}},
+		Violations: []Violation{{Metric: "latencies.mean", Message: "exceeds 11ms budget"}},
+	}
+
+	require.NoError(t, email.Write(rep))
+	msg := string(sent)
+	require.Contains(t, msg, "Subject: [enbench] 1 benchmark run(s) 1 violation(s)")
+	require.Contains(t, msg, "To: team@example.com, ops@example.com")
+	require.Contains(t, msg, "Content-Type: text/plain; charset=utf-8")
+	require.Contains(t, msg, "Content-Type: text/html; charset=utf-8")
+	require.Contains(t, msg, "latencies.mean: 10ms -> 12ms (+20.00%)")
+	require.Contains(t, msg, "<strong>latencies.mean</strong> exceeds 11ms budget")
+}
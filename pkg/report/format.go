@@ -0,0 +1,225 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit is the unit of measure of a metric, used to label its formatted value.
+type Unit string
+
+const (
+	UnitNone         Unit = ""
+	UnitEvents       Unit = "events"
+	UnitEventsPerSec Unit = "events/s"
+	UnitBytes        Unit = "B"
+	UnitBytesPerSec  Unit = "B/s"
+	UnitMBPerSec     Unit = "MB/s" // values are megabytes (1e6 bytes) per second
+	UnitRatio        Unit = "ratio"
+)
+
+// The units of the metrics reported by the benchmarks keyed by the last component of
+// the flattened metric name, which is the JSON name of the field of the results type
+// that reported it, e.g. the throughput of stats.Latencies or the sent_mb_per_sec of
+// stats.Bandwidth. Duration strings are recognized by their value.
+var units = map[string]Unit{
+	"events":               UnitEvents,
+	"acks":                 UnitEvents,
+	"nacks":                UnitEvents,
+	"acked":                UnitEvents,
+	"nacked":               UnitEvents,
+	"failures":             UnitEvents,
+	"timeouts":             UnitEvents,
+	"samples":              UnitEvents,
+	"warmup":               UnitEvents,
+	"cooldown":             UnitEvents,
+	"delivered":            UnitEvents,
+	"undelivered":          UnitEvents,
+	"published":            UnitEvents,
+	"received":             UnitEvents,
+	"missing":              UnitEvents,
+	"duplicates":           UnitEvents,
+	"foreign":              UnitEvents,
+	"uncorrelated":         UnitEvents,
+	"lost":                 UnitEvents,
+	"probes":               UnitEvents,
+	"reads":                UnitEvents,
+	"missed":               UnitEvents,
+	"unacked":              UnitEvents,
+	"reordered":            UnitEvents,
+	"operations":           UnitEvents,
+	"rows":                 UnitEvents,
+	"requests":             UnitEvents,
+	"responses":            UnitEvents,
+	"unanswered":           UnitEvents,
+	"orphaned":             UnitEvents,
+	"throughput":           UnitEventsPerSec,
+	"events_per_sec":       UnitEventsPerSec,
+	"converged_throughput": UnitEventsPerSec,
+	"rows_per_sec":         UnitEventsPerSec,
+	"bytes":                UnitBytes,
+	"bytes_sent":           UnitBytes,
+	"bytes_received":       UnitBytes,
+	"published_bytes":      UnitBytes,
+	"topic_bytes":          UnitBytes,
+	"payload_size":         UnitBytes,
+	"payload_bytes":        UnitBytes,
+	"wire_bytes":           UnitBytes,
+	"wire_size":            UnitBytes,
+	"sent_mb_per_sec":      UnitMBPerSec,
+	"received_mb_per_sec":  UnitMBPerSec,
+	"mb_per_sec":           UnitMBPerSec,
+	"loss_rate":            UnitRatio,
+	"duplicate_rate":       UnitRatio,
+	"reorder_rate":         UnitRatio,
+	"acked_fraction":       UnitRatio,
+	"full_fraction":        UnitRatio,
+	"success_rate":         UnitRatio,
+	"inverted_rate":        UnitRatio,
+	"preserved_fraction":   UnitRatio,
+}
+
+// UnitOf returns the unit of the flattened metric.
+func UnitOf(metric string) Unit {
+	if idx := strings.LastIndexByte(metric, '.'); idx >= 0 {
+		metric = metric[idx+1:]
+	}
+	return units[metric]
+}
+
+// Format returns the human readable value of a flattened metric labeled with its unit:
+// large values are abbreviated with SI suffixes (1.2M events, 85.3 MB/s), other values
+// are grouped with thousands separators, and durations are rounded to four significant
+// digits. The formatting does not depend on the locale of the host so that reports are
+// identical wherever they are generated. Values that are not numeric are unchanged.
+func Format(metric string, val interface{}) string {
+	num, isDuration, ok := Numeric(val)
+	if !ok {
+		return fmt.Sprintf("%v", val)
+	}
+
+	if isDuration {
+		return FormatDuration(seconds(num))
+	}
+
+	switch unit := UnitOf(metric); unit {
+	case UnitNone:
+		return Humanize(num)
+	case UnitBytes, UnitBytesPerSec:
+		return FormatBytes(num) + strings.TrimPrefix(string(unit), "B")
+	case UnitMBPerSec:
+		return FormatBytes(num*1e6) + "/s"
+	case UnitRatio:
+		return strconv.FormatFloat(num*100, 'f', 2, 64) + "%"
+	default:
+		return Humanize(num) + " " + string(unit)
+	}
+}
+
+// The SI prefixes used to abbreviate large values; a value is abbreviated with the
+// largest prefix that it would round to at three significant digits.
+var prefixes = []struct {
+	scale  float64
+	suffix string
+}{
+	{1e12, "T"},
+	{1e9, "G"},
+	{1e6, "M"},
+	{1e3, "k"},
+}
+
+// Values within half of the third significant digit of a prefix round up to it.
+const roundsUp = 0.9995
+
+// Humanize abbreviates values of a hundred thousand or more with an SI suffix and three
+// significant digits (1.2M or 123k), and groups smaller values with thousands separators
+// and at most two decimals (12,345 or 85.25).
+func Humanize(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	if abs := math.Abs(v); abs >= 1e5 {
+		for _, prefix := range prefixes {
+			if abs >= prefix.scale*roundsUp {
+				return significant(v/prefix.scale) + prefix.suffix
+			}
+		}
+	}
+	return Thousands(v, 2)
+}
+
+// FormatBytes abbreviates a number of bytes with decimal SI prefixes and three
+// significant digits (85.3 MB).
+func FormatBytes(v float64) string {
+	abs := math.Abs(v)
+	for _, prefix := range prefixes {
+		if abs >= prefix.scale*roundsUp {
+			return significant(v/prefix.scale) + " " + prefix.suffix + "B"
+		}
+	}
+	return Thousands(v, 1) + " B"
+}
+
+// Formats a value scaled by an SI prefix, i.e. less than 1000, to three significant digits.
+func significant(v float64) string {
+	decimals := 0
+	switch abs := math.Abs(v); {
+	case abs < 10:
+		decimals = 2
+	case abs < 100:
+		decimals = 1
+	}
+	return Thousands(v, decimals)
+}
+
+// Thousands formats the value with comma thousands separators and at most the specified
+// number of decimals, omitting trailing zeros (1,234,567.5).
+func Thousands(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+
+	if s == "-0" {
+		s = "0"
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	sb := &strings.Builder{}
+	sb.WriteString(sign)
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(digit)
+	}
+
+	if hasFrac {
+		sb.WriteByte('.')
+		sb.WriteString(frac)
+	}
+	return sb.String()
+}
+
+// FormatDuration rounds the duration to four significant digits (12.35ms).
+func FormatDuration(d time.Duration) string {
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+
+	round := time.Duration(1)
+	for limit := time.Duration(10000); abs >= limit && limit > 0; limit *= 10 {
+		round *= 10
+	}
+	return d.Round(round).String()
+}
@@ -0,0 +1,73 @@
+package report_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		metric   string
+		val      interface{}
+		expected string
+	}{
+		{"events", uint64(1234567), "1.23M events"},
+		{"events", 1200000.0, "1.2M events"},
+		{"failures", 2, "2 events"},
+		{"latencies.samples", 12345.0, "12,345 events"},
+		{"latencies.throughput", 84.2, "84.2 events/s"},
+		{"latencies.throughput", 999960.0, "1M events/s"},
+		{"bandwidth.sent_mb_per_sec", 85.34, "85.3 MB/s"},
+		{"bandwidth.received_mb_per_sec", 0.5, "500 kB/s"},
+		{"bandwidth.bytes_sent", 2048, "2.05 kB"},
+		{"wire_size", 298.46, "298.5 B"},
+		{"delivery_semantics.loss_rate", 0.0125, "1.25%"},
+		{"latencies.mean", "12.345678ms", "12.35ms"},
+		{"latencies.p99", "1.2ms", "1.2ms"},
+		{"converged_concurrency", 1234.5678, "1,234.57"},
+		{"offered_rate", -1500000.0, "-1.5M"},
+		{"exit_reason", "completed", "completed"},
+		{"converged", true, "true"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, report.Format(tc.metric, tc.val), "unexpected format of %s=%v", tc.metric, tc.val)
+	}
+
+	require.Equal(t, report.UnitMBPerSec, report.UnitOf("bandwidth.sent_mb_per_sec"))
+	require.Equal(t, report.UnitNone, report.UnitOf("exit_reason"))
+}
+
+func TestThousands(t *testing.T) {
+	require.Equal(t, "0", report.Thousands(0, 2))
+	require.Equal(t, "999", report.Thousands(999, 2))
+	require.Equal(t, "1,000", report.Thousands(1000, 2))
+	require.Equal(t, "1,234,567.5", report.Thousands(1234567.5, 2))
+	require.Equal(t, "-12,345.68", report.Thousands(-12345.678, 2))
+	require.Equal(t, "0", report.Thousands(-0.001, 2))
+}
+
+func TestFormatDuration(t *testing.T) {
+	require.Equal(t, "0s", report.FormatDuration(0))
+	require.Equal(t, "999ns", report.FormatDuration(999))
+	require.Equal(t, "12.35ms", report.FormatDuration(12345678))
+	require.Equal(t, "-1.235s", report.FormatDuration(-1234567890))
+	require.Equal(t, "2h30m0s", report.FormatDuration(150*time.Minute))
+}
+
+func TestText(t *testing.T) {
+	rep := &report.Report{
+		Benchmark:  "blast",
+		Metrics:    metrics.Metrics{"events": 1500000, "latencies": map[string]interface{}{"mean": "1.23456ms"}, "experiment": map[string]interface{}{"topic": "benchmarks"}},
+		Violations: []report.Violation{{Metric: "latencies.mean", Message: "exceeds 1ms budget"}},
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, report.NewText(out).Write(rep))
+	require.Equal(t, "enbench blast results\n\nevents          1.5M events\nlatencies.mean  1.235ms\n\n1 violation(s) detected\n  latencies.mean: exceeds 1ms budget\n", out.String())
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Key metrics that are emitted as workflow annotations; all other metrics are only
+// written to the job summary to avoid cluttering the workflow run page.
+var annotated = []string{
+	"latencies.throughput",
+	"latencies.mean",
+	"latencies.p99",
+	"latencies.slowest",
+	"latencies.timeouts",
+	"normalized.latency_per_kb",
+	"normalized.mb_per_sec",
+	"bandwidth.sent_mb_per_sec",
+	"bandwidth.received_mb_per_sec",
+	"failures",
+}
+
+// GitHub writes reports using GitHub Actions workflow command syntax so that key
+// metrics are displayed as notices and violations as errors on the workflow run page.
+// If a summary path is specified (usually $GITHUB_STEP_SUMMARY) then a markdown table
+// of all metrics and violations is appended to the job summary file.
+type GitHub struct {
+	out     io.Writer
+	summary string
+}
+
+func NewGitHub(w io.Writer, summary string) *GitHub {
+	return &GitHub{out: w, summary: summary}
+}
+
+func (g *GitHub) Write(r *Report) (err error) {
+	var flat map[string]interface{}
+	if flat, err = Flatten(r.Metrics); err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("enbench %s", r.Benchmark)
+	metrics := make([]string, 0, len(annotated))
+	for _, key := range annotated {
+		if val, ok := flat[key]; ok {
+			metrics = append(metrics, fmt.Sprintf("%s=%s", key, Format(key, val)))
+		}
+	}
+
+	if len(metrics) > 0 {
+		if err = g.command("notice", title, strings.Join(metrics, ", ")); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range r.Violations {
+		if err = g.command("error", title, v.String()); err != nil {
+			return err
+		}
+	}
+
+	if g.summary != "" {
+		if err = g.writeSummary(r, flat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GitHub) command(level, title, message string) (err error) {
+	_, err = fmt.Fprintf(g.out, "::%s title=%s::%s\n", level, escapeProperty(title), escapeData(message))
+	return err
+}
+
+func (g *GitHub) writeSummary(r *Report, flat map[string]interface{}) (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(g.summary, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "## enbench %s results\n\n", r.Benchmark)
+	if len(r.Violations) > 0 {
+		fmt.Fprintf(sb, "**%d violation(s) detected**\n\n", len(r.Violations))
+		for _, v := range r.Violations {
+			fmt.Fprintf(sb, "- :x: `%s` %s\n", v.Metric, v.Message)
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("| Metric | Value |\n")
+	sb.WriteString("|--------|-------|\n")
+	for _, key := range Keys(flat) {
+		fmt.Fprintf(sb, "| %s | %s |\n", key, Format(key, flat[key]))
+	}
+	sb.WriteString("\n")
+
+	_, err = f.WriteString(sb.String())
+	return err
+}
+
+// Escapes the message of a workflow command as required by the actions toolkit.
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	s = strings.ReplaceAll(s, "\n", "%0A")
+	return s
+}
+
+// Escapes the property values of a workflow command as required by the actions toolkit.
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	s = strings.ReplaceAll(s, ",", "%2C")
+	return s
+}
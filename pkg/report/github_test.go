@@ -0,0 +1,45 @@
+package report_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHub(t *testing.T) {
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	out := &bytes.Buffer{}
+
+	results := make(metrics.Metrics)
+	results["failures"] = 2
+	results["bandwidth"] = map[string]interface{}{"bytes_sent": 2048, "sent_mb_per_sec": 1024.5}
+	results["latencies"] = map[string]interface{}{"mean": "1.2ms", "throughput": 84.2}
+	results["experiment"] = map[string]interface{}{"endpoint": "localhost:5356"}
+
+	rep := &report.Report{
+		Benchmark: "blast",
+		Metrics:   results,
+		Violations: []report.Violation{
+			{Metric: "latencies.mean", Message: "100% over budget\nfailing"},
+		},
+	}
+
+	gh := report.NewGitHub(out, summary)
+	require.NoError(t, gh.Write(rep))
+
+	expected := "::notice title=enbench blast::latencies.throughput=84.2 events/s, latencies.mean=1.2ms, bandwidth.sent_mb_per_sec=1.02 GB/s, failures=2 events\n" +
+		"::error title=enbench blast::latencies.mean: 100%25 over budget%0Afailing\n"
+	require.Equal(t, expected, out.String())
+
+	data, err := os.ReadFile(summary)
+	require.NoError(t, err, "could not read job summary")
+	require.Contains(t, string(data), "## enbench blast results")
+	require.Contains(t, string(data), "| latencies.throughput | 84.2 events/s |")
+	require.Contains(t, string(data), "**1 violation(s) detected**")
+	require.NotContains(t, string(data), "endpoint")
+}
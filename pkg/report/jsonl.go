@@ -0,0 +1,110 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// The types of the records written by the JSON Lines output.
+const (
+	RecordInterval = "interval"
+	RecordResult   = "result"
+)
+
+// JSONL streams the results of a long running benchmark as JSON Lines: one record is
+// appended as each interval of the run is completed and a final record with the
+// metrics of the run is appended by Write. Every record is synced to disk as it is
+// written so that only the current interval is lost if the process crashes. The file
+// is appended to rather than truncated so multiple runs can share a results file; it
+// is never compressed because a compressed stream cannot be read after a crash. This
+// object is thread-safe.
+type JSONL struct {
+	sync.Mutex
+	out       io.Writer
+	file      *os.File
+	benchmark string
+	sequence  uint64
+}
+
+// Record is a single line of the JSON Lines output.
+type Record struct {
+	Benchmark  string      `json:"benchmark"`
+	Type       string      `json:"type"`
+	Sequence   uint64      `json:"sequence,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Interval   interface{} `json:"interval,omitempty"`
+	Metrics    interface{} `json:"metrics,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// OpenJSONL opens the file at the path for appending, creating it if necessary.
+func OpenJSONL(path, benchmark string) (_ *JSONL, err error) {
+	if path == "" {
+		return nil, errors.New("a path is required to append the jsonl output to")
+	}
+
+	var f *os.File
+	if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, fmt.Errorf("could not open jsonl output: %w", err)
+	}
+	return &JSONL{out: f, file: f, benchmark: benchmark}, nil
+}
+
+// NewJSONL writes the JSON Lines of the benchmark to w.
+func NewJSONL(w io.Writer, benchmark string) *JSONL {
+	return &JSONL{out: w, benchmark: benchmark}
+}
+
+// Interval appends a record of a completed interval of the run; the interval is
+// serialized as it would be in the final results, e.g. a window of the run.
+func (j *JSONL) Interval(interval interface{}) error {
+	j.Lock()
+	defer j.Unlock()
+
+	j.sequence++
+	return j.append(&Record{Benchmark: j.benchmark, Type: RecordInterval, Sequence: j.sequence, Interval: interval})
+}
+
+// Write appends the final record with the metrics and violations of the run.
+func (j *JSONL) Write(r *Report) error {
+	j.Lock()
+	defer j.Unlock()
+
+	benchmark := r.Benchmark
+	if benchmark == "" {
+		benchmark = j.benchmark
+	}
+	return j.append(&Record{Benchmark: benchmark, Type: RecordResult, Metrics: r.Metrics, Violations: r.Violations})
+}
+
+// Close the file that the records are appended to.
+func (j *JSONL) Close() error {
+	if j.file != nil {
+		return j.file.Close()
+	}
+	return nil
+}
+
+// Must be called with the lock held.
+func (j *JSONL) append(record *Record) (err error) {
+	record.Timestamp = time.Now()
+
+	var data []byte
+	if data, err = json.Marshal(record); err != nil {
+		return err
+	}
+
+	if _, err = j.out.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		return j.file.Sync()
+	}
+	return nil
+}
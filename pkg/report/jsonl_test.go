@@ -0,0 +1,65 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	_, err := OpenJSONL("", "sustain")
+	require.Error(t, err, "a path is required")
+
+	out, err := OpenJSONL(path, "sustain")
+	require.NoError(t, err)
+	require.NoError(t, out.Interval(map[string]interface{}{"samples": 10}))
+	require.NoError(t, out.Interval(map[string]interface{}{"samples": 12}))
+
+	// Intervals are on disk before the run completes
+	records := readRecords(t, path)
+	require.Len(t, records, 2)
+	require.Equal(t, RecordInterval, records[1].Type)
+	require.Equal(t, uint64(2), records[1].Sequence)
+	require.Equal(t, "sustain", records[1].Benchmark)
+	require.Equal(t, map[string]interface{}{"samples": 12.0}, records[1].Interval)
+
+	rep := &Report{Benchmark: "sustain", Metrics: metrics.Metrics{"events": 22}, Violations: []Violation{{Metric: "events", Message: "too few"}}}
+	require.NoError(t, out.Write(rep))
+	require.NoError(t, out.Close())
+
+	// Reopening the output appends to the previous runs
+	out, err = OpenJSONL(path, "aimd")
+	require.NoError(t, err)
+	require.NoError(t, out.Interval("window"))
+	require.NoError(t, out.Close())
+
+	records = readRecords(t, path)
+	require.Len(t, records, 4)
+	require.Equal(t, RecordResult, records[2].Type)
+	require.Equal(t, map[string]interface{}{"events": 22.0}, records[2].Metrics)
+	require.Equal(t, rep.Violations, records[2].Violations)
+	require.Equal(t, "aimd", records[3].Benchmark)
+	require.Equal(t, uint64(1), records[3].Sequence)
+	require.False(t, records[3].Timestamp.Before(records[0].Timestamp))
+}
+
+func readRecords(t *testing.T, path string) (records []Record) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ResultProcessor receives the final report of a benchmark run before it is written
+// by the configured output mode. Processors can modify the report (e.g. to add derived
+// metrics or violations) or export it to bespoke systems. Custom processors are
+// compiled into a main package that imports this package and registers them, usually
+// from an init function, so that results can be post-processed without forking the CLI.
+type ResultProcessor interface {
+	Process(*Report) error
+}
+
+// ProcessorFunc allows ordinary functions to be registered as result processors.
+type ProcessorFunc func(*Report) error
+
+func (f ProcessorFunc) Process(r *Report) error {
+	return f(r)
+}
+
+var (
+	procmu     sync.RWMutex
+	processors []registered
+)
+
+type registered struct {
+	name string
+	proc ResultProcessor
+}
+
+// Register a named result processor; processors are executed in the order they are
+// registered. Register panics if the name is already in use or the processor is nil.
+func Register(name string, proc ResultProcessor) {
+	procmu.Lock()
+	defer procmu.Unlock()
+
+	if proc == nil {
+		panic("report: cannot register a nil result processor")
+	}
+
+	for _, r := range processors {
+		if r.name == name {
+			panic(fmt.Sprintf("report: result processor %q already registered", name))
+		}
+	}
+	processors = append(processors, registered{name: name, proc: proc})
+}
+
+// Processors returns the names of the registered result processors in order.
+func Processors() []string {
+	procmu.RLock()
+	defer procmu.RUnlock()
+
+	names := make([]string, 0, len(processors))
+	for _, r := range processors {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// Process executes all registered result processors on the report, stopping at the
+// first processor that returns an error.
+func Process(r *Report) (err error) {
+	procmu.RLock()
+	defer procmu.RUnlock()
+
+	for _, p := range processors {
+		if err = p.proc.Process(r); err != nil {
+			return fmt.Errorf("result processor %q failed: %w", p.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package report_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessors(t *testing.T) {
+	calls := make([]string, 0, 2)
+	report.Register("first", report.ProcessorFunc(func(r *report.Report) error {
+		calls = append(calls, "first")
+		r.Metrics.(metrics.Metrics)["derived"] = 42
+		return nil
+	}))
+
+	report.Register("second", report.ProcessorFunc(func(r *report.Report) error {
+		calls = append(calls, "second")
+		if r.Benchmark == "fail" {
+			return errors.New("whoops")
+		}
+		return nil
+	}))
+
+	require.Equal(t, []string{"first", "second"}, report.Processors())
+	require.Panics(t, func() { report.Register("first", report.ProcessorFunc(nil)) })
+
+	rep := &report.Report{Benchmark: "blast", Metrics: make(metrics.Metrics)}
+	require.NoError(t, report.Process(rep))
+	require.Equal(t, []string{"first", "second"}, calls)
+	require.Equal(t, 42, rep.Metrics.Measurement("derived"))
+
+	rep.Benchmark = "fail"
+	require.EqualError(t, report.Process(rep), `result processor "second" failed: whoops`)
+}
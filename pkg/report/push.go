@@ -0,0 +1,113 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PushJob is the job that the metrics of the benchmarks are grouped by on the gateway.
+const PushJob = "enbench"
+
+// PushTimeout bounds how long pushing the metrics of a run may take.
+const PushTimeout = 30 * time.Second
+
+// Pushgateway pushes the numeric metrics of a report to a Prometheus Pushgateway so that
+// the performance of recurring runs can be monitored and alerted on. The metrics of a
+// run replace the metrics previously pushed for the benchmark and labels, which are used
+// as the grouping key. Metrics are named by their flattened name with an enbench prefix,
+// e.g. enbench_latencies_p99_seconds; durations are pushed in seconds.
+type Pushgateway struct {
+	url    string
+	labels map[string]string
+	client *http.Client
+}
+
+func NewPushgateway(url string, labels map[string]string) *Pushgateway {
+	return &Pushgateway{
+		url:    strings.TrimSuffix(url, "/"),
+		labels: labels,
+		client: &http.Client{Timeout: PushTimeout},
+	}
+}
+
+func (p *Pushgateway) Write(r *Report) (err error) {
+	var flat map[string]interface{}
+	if flat, err = Flatten(r.Metrics); err != nil {
+		return err
+	}
+
+	body := &bytes.Buffer{}
+	for _, key := range Keys(flat) {
+		num, isDuration, ok := Numeric(flat[key])
+		if !ok {
+			continue
+		}
+
+		name := PushName(key)
+		if isDuration {
+			name += "_seconds"
+		}
+		fmt.Fprintf(body, "# TYPE %s gauge\n%s %s\n", name, name, strconv.FormatFloat(num, 'g', -1, 64))
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(context.Background(), http.MethodPut, p.endpoint(r.Benchmark), body); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	var rep *http.Response
+	if rep, err = p.client.Do(req); err != nil {
+		return fmt.Errorf("could not push metrics: %w", err)
+	}
+	defer rep.Body.Close()
+
+	if rep.StatusCode < 200 || rep.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(rep.Body, 512))
+		return fmt.Errorf("could not push metrics: %s: %s", rep.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Returns the grouping key url of the metrics of the benchmark.
+func (p *Pushgateway) endpoint(benchmark string) string {
+	path := []string{p.url, "metrics", "job", PushJob, "benchmark", url.PathEscape(benchmark)}
+
+	keys := make([]string, 0, len(p.labels))
+	for key := range p.labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "job" || key == "benchmark" || p.labels[key] == "" {
+			continue
+		}
+		path = append(path, sanitize(key), url.PathEscape(p.labels[key]))
+	}
+	return strings.Join(path, "/")
+}
+
+// PushName returns the Prometheus metric name of the flattened metric.
+func PushName(metric string) string {
+	return PushJob + "_" + sanitize(metric)
+}
+
+// Replaces the characters that are not valid in Prometheus names with underscores.
+func sanitize(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
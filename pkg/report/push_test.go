@@ -0,0 +1,48 @@
+package report
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushgateway(t *testing.T) {
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		path, body = r.URL.EscapedPath(), string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	push := NewPushgateway(srv.URL+"/", map[string]string{"env": "staging", "region": "us east", "job": "ignored"})
+	rep := &Report{
+		Benchmark: "blast",
+		Metrics: metrics.Metrics{
+			"failures":    2,
+			"exit_reason": "completed",
+			"latencies":   map[string]interface{}{"p99": "12ms", "throughput": 4500.5},
+			"experiment":  map[string]interface{}{"operations": 100},
+		},
+	}
+
+	require.NoError(t, push.Write(rep))
+	require.Equal(t, "/metrics/job/enbench/benchmark/blast/env/staging/region/us%20east", path)
+	require.Contains(t, body, "# TYPE enbench_failures gauge\nenbench_failures 2\n")
+	require.Contains(t, body, "enbench_latencies_p99_seconds 0.012\n")
+	require.Contains(t, body, "enbench_latencies_throughput 4500.5\n")
+	require.NotContains(t, body, "exit_reason")
+	require.NotContains(t, body, "experiment")
+
+	// Push failures are reported with the response of the gateway
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "pushed metrics are invalid", http.StatusBadRequest)
+	})
+	require.ErrorContains(t, push.Write(rep), "pushed metrics are invalid")
+}
@@ -0,0 +1,188 @@
+/*
+Package report implements output modes for benchmark results. A report wraps the
+metrics returned by a benchmark along with any violations detected after the run so
+that the results can be written to the console, to disk, or to external systems such
+as the GitHub Actions workflow run pages.
+*/
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+)
+
+// Output modes supported by the reporters in this package.
+const (
+	OutputJSON   = "json"
+	OutputJSONL  = "jsonl"
+	OutputText   = "text"
+	OutputGitHub = "github"
+	OutputEmail  = "email"
+)
+
+// Report is the final output of a benchmark run.
+type Report struct {
+	Benchmark  string             `json:"benchmark"`
+	Metrics    benchmarks.Metrics `json:"metrics"`
+	Violations []Violation        `json:"violations,omitempty"`
+	Baseline   benchmarks.Metrics `json:"-"` // optional previous run to compute deltas from
+}
+
+// Violation describes a metric that failed a gate or assertion after the run.
+type Violation struct {
+	Metric  string `json:"metric"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Metric, v.Message)
+}
+
+// Writer outputs a report in a specific format.
+type Writer interface {
+	Write(*Report) error
+}
+
+// New returns the writer for the specified output mode, writing to stdout.
+func New(mode string) (Writer, error) {
+	switch strings.ToLower(mode) {
+	case "", OutputJSON:
+		return &JSON{out: os.Stdout}, nil
+	case OutputJSONL:
+		return NewJSONL(os.Stdout, ""), nil
+	case OutputText:
+		return NewText(os.Stdout), nil
+	case OutputGitHub:
+		return NewGitHub(os.Stdout, os.Getenv("GITHUB_STEP_SUMMARY")), nil
+	case OutputEmail:
+		conf, err := SMTPConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewEmail(conf), nil
+	default:
+		return nil, fmt.Errorf("unknown output mode %q", mode)
+	}
+}
+
+// JSON writes the metrics of the report as a single line of JSON.
+type JSON struct {
+	out io.Writer
+}
+
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{out: w}
+}
+
+func (j *JSON) Write(r *Report) (err error) {
+	var data []byte
+	if data, err = json.Marshal(r.Metrics); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(j.out, string(data))
+	return err
+}
+
+// Text writes the metrics of the report as a table of formatted values labeled with
+// their units for reading on the console, followed by any violations.
+type Text struct {
+	out io.Writer
+}
+
+func NewText(w io.Writer) *Text {
+	return &Text{out: w}
+}
+
+func (t *Text) Write(r *Report) (err error) {
+	var flat map[string]interface{}
+	if flat, err = Flatten(r.Metrics); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(t.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "enbench %s results\n\n", r.Benchmark)
+	for _, key := range Keys(flat) {
+		fmt.Fprintf(tw, "%s\t%s\n", key, Format(key, flat[key]))
+	}
+
+	if len(r.Violations) > 0 {
+		fmt.Fprintf(tw, "\n%d violation(s) detected\n", len(r.Violations))
+		for _, v := range r.Violations {
+			fmt.Fprintf(tw, "  %s\n", v)
+		}
+	}
+	return tw.Flush()
+}
+
+// LoadMetrics loads the JSON metrics of a previous run from disk, e.g. to use as the
+// baseline for computing deltas. Compressed results files are decompressed.
+func LoadMetrics(path string) (_ metrics.Metrics, err error) {
+	var data []byte
+	if data, err = compress.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	m := make(metrics.Metrics)
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Flatten serializes the metrics to JSON and returns a map of dot-separated metric
+// names to their scalar values so that nested measurements such as latencies can be
+// reported individually. Experiment parameters are not included.
+func Flatten(m benchmarks.Metrics) (_ map[string]interface{}, err error) {
+	var data []byte
+	if data, err = json.Marshal(m); err != nil {
+		return nil, err
+	}
+
+	nested := make(map[string]interface{})
+	if err = json.Unmarshal(data, &nested); err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]interface{})
+	for key, val := range nested {
+		if key == "experiment" {
+			continue
+		}
+		flatten(flat, key, val)
+	}
+	return flat, nil
+}
+
+func flatten(flat map[string]interface{}, prefix string, val interface{}) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flatten(flat, prefix+"."+key, child)
+		}
+	case []interface{}:
+		// Arrays such as raw samples are too large to report individually.
+		return
+	default:
+		flat[prefix] = v
+	}
+}
+
+// Keys returns the sorted keys of a flattened metrics map.
+func Keys(flat map[string]interface{}) []string {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+)
+
+// TrendPoint summarizes a metric across the runs of a group, e.g. the p99 latency of
+// every nightly run against a single server release.
+type TrendPoint struct {
+	Group    string    `json:"group"`
+	Runs     int       `json:"runs"`    // the number of runs in the group that reported the metric
+	Missing  int       `json:"missing"` // the number of runs in the group without the metric
+	First    time.Time `json:"first"`   // the created timestamp of the oldest run in the group
+	Last     time.Time `json:"last"`    // the created timestamp of the newest run in the group
+	Mean     float64   `json:"mean"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Percent  float64   `json:"percent"` // the change of the mean from the previous point
+	Duration bool      `json:"-"`       // true if the values are durations in seconds
+}
+
+// Format a value of the point's metric for display.
+func (p *TrendPoint) Format(val float64) string {
+	if p.Duration {
+		return seconds(val).String()
+	}
+	return fmt.Sprintf("%.4g", val)
+}
+
+// Trend summarizes the metric for each group of runs, ordering the points by the oldest
+// run in each group so that the trend reads release over release. The metric is
+// matched against the flattened metrics of each run by its full name or, if no metric
+// has the full name, by the last component of the name (e.g. p99 matches latencies.p99);
+// if there are several matches the metric with the shortest name is used. Groups in
+// which no run reported the metric are omitted.
+func Trend(groups []*store.Group, metric string) (points []*TrendPoint, err error) {
+	points = make([]*TrendPoint, 0, len(groups))
+	for _, group := range groups {
+		point := &TrendPoint{Group: group.Value, Min: math.Inf(1), Max: math.Inf(-1)}
+		sum := 0.0
+		for _, run := range group.Runs {
+			if point.First.IsZero() || run.Created.Before(point.First) {
+				point.First = run.Created
+			}
+			if run.Created.After(point.Last) {
+				point.Last = run.Created
+			}
+
+			val, isDuration, ok := runMetric(run, metric)
+			if !ok {
+				point.Missing++
+				continue
+			}
+
+			point.Runs++
+			point.Duration = point.Duration || isDuration
+			point.Min = math.Min(point.Min, val)
+			point.Max = math.Max(point.Max, val)
+			sum += val
+		}
+
+		if point.Runs == 0 {
+			continue
+		}
+		point.Mean = sum / float64(point.Runs)
+		points = append(points, point)
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no runs reported the metric %q", metric)
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].First.Before(points[j].First)
+	})
+
+	for i := 1; i < len(points); i++ {
+		if prev := points[i-1].Mean; prev != 0 {
+			points[i].Percent = ((points[i].Mean - prev) / math.Abs(prev)) * 100
+		}
+	}
+	return points, nil
+}
+
+// Returns the numeric value of the metric reported by the run.
+func runMetric(run *store.Run, metric string) (val float64, isDuration bool, ok bool) {
+	if len(run.Metrics) == 0 {
+		return 0, false, false
+	}
+
+	m := make(metrics.Metrics)
+	if err := json.Unmarshal(run.Metrics, &m); err != nil {
+		return 0, false, false
+	}
+
+	flat, err := Flatten(m)
+	if err != nil {
+		return 0, false, false
+	}
+
+	if key, found := match(flat, metric); found {
+		return Numeric(flat[key])
+	}
+	return 0, false, false
+}
+
+// Returns the key of the flattened metric with the full name or, failing that, the
+// shortest key whose last component is the name.
+func match(flat map[string]interface{}, metric string) (key string, ok bool) {
+	if _, ok = flat[metric]; ok {
+		return metric, true
+	}
+
+	for _, candidate := range Keys(flat) {
+		if name(candidate) != metric || strings.Contains(metric, ".") {
+			continue
+		}
+
+		if !ok || len(candidate) < len(key) {
+			key, ok = candidate, true
+		}
+	}
+	return key, ok
+}
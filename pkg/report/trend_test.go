@@ -0,0 +1,50 @@
+package report_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/report"
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrend(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	run := func(age time.Duration, version, metrics string) *store.Run {
+		return &store.Run{Benchmark: "blast", Created: now.Add(-age), ServerVersion: version, Metrics: json.RawMessage(metrics)}
+	}
+
+	runs := []*store.Run{
+		run(time.Hour, "v0.10.0", `{"latencies": {"p99": "3ms"}, "phases": [{"p99": "9ms"}], "nodes": {"node-a": {"p99": "1s"}}}`),
+		run(2*time.Hour, "v0.10.0", `{"latencies": {"p99": "5ms"}}`),
+		run(3*time.Hour, "v0.10.0", `{"events": 100}`),
+		run(48*time.Hour, "v0.9.0", `{"latencies": {"p99": "2ms"}}`),
+		run(72*time.Hour, "v0.8.0", `{"events": 100}`),
+	}
+
+	points, err := report.Trend(store.GroupBy(runs, store.ServerVersion), "p99")
+	require.NoError(t, err)
+	require.Len(t, points, 2, "groups without the metric should be omitted")
+
+	// Points are ordered release over release by the first run of each group
+	require.Equal(t, "v0.9.0", points[0].Group)
+	require.Equal(t, "v0.10.0", points[1].Group)
+
+	// The shortest matching metric (latencies.p99 rather than nodes.node-a.p99) is used
+	require.Equal(t, 2, points[1].Runs)
+	require.Equal(t, 1, points[1].Missing)
+	require.InDelta(t, 0.004, points[1].Mean, 1e-9)
+	require.InDelta(t, 0.003, points[1].Min, 1e-9)
+	require.InDelta(t, 0.005, points[1].Max, 1e-9)
+	require.InDelta(t, 100.0, points[1].Percent, 1e-6)
+	require.Equal(t, "4ms", points[1].Format(points[1].Mean))
+
+	points, err = report.Trend(store.GroupBy(runs, store.ServerVersion), "nodes.node-a.p99")
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+
+	_, err = report.Trend(store.GroupBy(runs, store.ServerVersion), "p50")
+	require.Error(t, err)
+}
@@ -0,0 +1,256 @@
+/*
+The retention package implements a long-running benchmark that publishes beyond the
+retention limits of a topic to determine whether and when publish latency changes as
+the server evicts or compacts old data. Events are published in windows of blasts and
+after each window the size of the topic is queried so that the latency of every window
+can be correlated with the growth of the topic.
+*/
+package retention
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultOvershoot is the multiple of the retention limit published by default.
+const DefaultOvershoot = 2.0
+
+// If the topic grows by less than this fraction of the bytes published in a window,
+// the server is assumed to be evicting or compacting data.
+const evictionGrowth = 0.5
+
+// Retention implements a benchmark that publishes blast windows to a topic until the
+// total volume published exceeds the retention limit of the topic by the overshoot.
+type Retention struct {
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	windows  []*Window
+	started  time.Time
+	duration time.Duration
+	reason   string
+	progress stats.Progress
+	mu       sync.Mutex
+	current  *blast.Blast
+
+	// OnWindow is called with each window once the size of the topic has been checked,
+	// e.g. to stream the windows to disk while the topic is filled.
+	OnWindow func(*Window)
+}
+
+// Window records the publish latencies of a single blast and the size of the topic
+// as reported by the server after the blast completed.
+type Window struct {
+	Index       int              `json:"index"`
+	Published   uint64           `json:"published_bytes"`
+	TopicEvents uint64           `json:"topic_events"`
+	TopicBytes  uint64           `json:"topic_bytes"`
+	Evicting    bool             `json:"evicting"`
+	Latencies   *stats.Latencies `json:"latencies"`
+}
+
+func New(opts *options.Options) *Retention {
+	return &Retention{opts: opts}
+}
+
+func (b *Retention) Run(ctx context.Context) (err error) {
+	if b.opts.Retention == 0 {
+		return errors.New("the retention limit of the topic in bytes is required")
+	}
+
+	overshoot := b.opts.Overshoot
+	if overshoot <= 0 {
+		overshoot = DefaultOvershoot
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	var prev *api.TopicInfo
+	if prev, err = b.client.TopicInfo(ctx, b.topicID); err != nil {
+		return err
+	}
+
+	target := uint64(float64(b.opts.Retention) * overshoot)
+	perWindow := b.opts.Operations * uint64(b.opts.DataSize)
+	published := uint64(0)
+	b.windows = make([]*Window, 0)
+
+	log.Info().
+		Str("topic_id", b.topicID.String()).
+		Uint64("retention", b.opts.Retention).
+		Uint64("target", target).
+		Uint64("topic_bytes", prev.DataSizeBytes).
+		Msg("retention benchmark starting")
+
+	b.started = time.Now()
+	defer func() {
+		b.duration = time.Since(b.started)
+	}()
+
+	b.reason = benchmarks.ExitCompleted
+	b.progress.Start()
+	b.progress.Set("target_bytes", target)
+	for published < target {
+		if err = ctx.Err(); err != nil {
+			b.reason = benchmarks.ExitCanceled
+			return err
+		}
+
+		if b.opts.MaxBytes > 0 && published+perWindow > b.opts.MaxBytes {
+			b.reason = benchmarks.ExitMaxBytes
+			break
+		}
+
+		nevents := uint64(len(b.windows)) * b.opts.Operations
+		if reason := b.opts.Exhausted(b.started, nevents); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		// Each window is limited to the time remaining in the run; the event guard is
+		// enforced on whole windows so that every window publishes the same workload.
+		wopts := *b.opts
+		wopts.MaxEvents = 0
+		if b.opts.MaxDuration > 0 {
+			wopts.MaxDuration = b.opts.MaxDuration - time.Since(b.started)
+		}
+
+		window := blast.New(&wopts)
+		b.mu.Lock()
+		b.current = window
+		b.mu.Unlock()
+
+		if err = window.Run(ctx); err != nil {
+			return err
+		}
+
+		// A window stopped by the duration guard publishes only part of its workload
+		if window.ExitReason() == benchmarks.ExitMaxDuration {
+			b.reason = benchmarks.ExitMaxDuration
+			published += window.Latencies().N() * uint64(b.opts.DataSize)
+		} else {
+			published += perWindow
+		}
+		b.progress.Add("windows", 1)
+		b.progress.Set("published_bytes", published)
+
+		var info *api.TopicInfo
+		if info, err = b.client.TopicInfo(ctx, b.topicID); err != nil {
+			return err
+		}
+
+		w := &Window{
+			Index:       len(b.windows),
+			Published:   published,
+			TopicEvents: info.Events,
+			TopicBytes:  info.DataSizeBytes,
+			Evicting:    info.DataSizeBytes < prev.DataSizeBytes+uint64(float64(perWindow)*evictionGrowth),
+			Latencies:   window.Latencies(),
+		}
+		b.windows = append(b.windows, w)
+		prev = info
+		if b.OnWindow != nil {
+			b.OnWindow(w)
+		}
+
+		log.Info().
+			Int("window", w.Index).
+			Uint64("published_bytes", w.Published).
+			Uint64("topic_bytes", w.TopicBytes).
+			Bool("evicting", w.Evicting).
+			Dur("mean", w.Latencies.Mean()).
+			Msg("retention window completed")
+
+		if b.reason == benchmarks.ExitMaxDuration {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Progress returns the number of windows and bytes published so far, including the
+// progress of the blast window that is currently running.
+func (b *Retention) Progress() map[string]interface{} {
+	snap := b.progress.Snapshot()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.current != nil {
+		snap["window"] = b.current.Progress()
+	}
+	return snap
+}
+
+// Windows returns the blast windows published by the benchmark.
+func (b *Retention) Windows() []*Window {
+	return b.windows
+}
+
+func (b *Retention) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+
+	latencies := &stats.Latencies{}
+	sizes := make([]float64, 0, len(b.windows))
+	means := make([]float64, 0, len(b.windows))
+	firstEviction := -1
+
+	for _, w := range b.windows {
+		latencies.Append(w.Latencies)
+		sizes = append(sizes, float64(w.TopicBytes))
+		means = append(means, w.Latencies.Mean().Seconds())
+
+		if w.Evicting && firstEviction < 0 {
+			firstEviction = w.Index
+		}
+	}
+	latencies.SetDuration(b.duration)
+
+	results["latencies"] = latencies
+	results["normalized"] = stats.Normalize(latencies, b.opts.DataSize)
+	results["windows"] = b.windows
+	results["size_latency_correlation"] = stats.Correlation(sizes, means)
+	results["first_eviction_window"] = firstEviction
+	results["exit_reason"] = b.reason
+
+	results["experiment"] = map[string]interface{}{
+		"client_version": benchmarks.Version(),
+		"endpoint":       b.opts.Endpoint,
+		"topic":          b.opts.Topic,
+		"topic_id":       b.topicID.String(),
+		"operations":     b.opts.Operations,
+		"data_size":      b.opts.DataSize,
+		"retention":      b.opts.Retention,
+		"overshoot":      b.opts.Overshoot,
+		"max_bytes":      b.opts.MaxBytes,
+		"guard":          b.opts.Guard(),
+	}
+
+	return results, nil
+}
@@ -0,0 +1,411 @@
+/*
+Package scale implements a benchmark of topic count scalability. The benchmark creates
+topics in steps until the project has thousands of them and, at every step, measures the
+control plane (the time to create, look up by name, and list topics) and the data plane
+(the latency of a trickle of events published to every topic). Comparing the steps shows
+how per-topic publish latency and topic lookup time scale with the number of topics,
+characterizing the metadata scalability of the server. The topics created by the run
+are destroyed once it is over, measuring how long each topic takes to destroy.
+*/
+package scale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults for the topic scalability benchmark.
+const (
+	DefaultLookups    = 100
+	DefaultTrickle    = 1
+	DefaultAckTimeout = 10 * time.Second
+)
+
+// DefaultSteps are the number of topics in the project at each step of the benchmark.
+var DefaultSteps = []int{100, 1000, 5000}
+
+// Scale creates topics in steps and measures how topic operations and per-topic publish
+// latency change as the number of topics grows.
+type Scale struct {
+	Steps      []int         // the cumulative number of topics created by each step
+	Lookups    int           // the number of topics looked up by name at each step
+	Trickle    int           // the number of events published to every topic at each step
+	AckTimeout time.Duration // how long to wait for the ack of a trickle event
+	Keep       bool          // do not destroy the topics created by the run
+
+	opts      *options.Options
+	client    *ensign.Client
+	prefix    string
+	names     []string
+	topics    []ulid.ULID
+	steps     []*Step
+	destroy   *stats.Latencies
+	destroyed int
+	published uint64
+	duration  time.Duration
+	reason    string
+	progress  stats.Progress
+}
+
+// Step records the measurements taken once the project had the number of topics.
+type Step struct {
+	Topics  int              // the number of topics created by the run when the step was measured
+	Listed  int              // the number of topics in the project that were listed
+	Create  *stats.Latencies // the time to create each of the topics added by the step
+	Lookup  *stats.Latencies // the time to resolve the ID of a topic by name
+	List    time.Duration    // the time to list every topic in the project
+	Publish *stats.Latencies // the time to ack each trickle event; unacked events are timeouts
+	Nacks   uint64
+}
+
+func New(opts *options.Options) *Scale {
+	steps := make([]int, len(DefaultSteps))
+	copy(steps, DefaultSteps)
+
+	return &Scale{
+		Steps:      steps,
+		Lookups:    DefaultLookups,
+		Trickle:    DefaultTrickle,
+		AckTimeout: DefaultAckTimeout,
+		opts:       opts,
+	}
+}
+
+func (b *Scale) Run(ctx context.Context) (err error) {
+	if len(b.Steps) == 0 {
+		return errors.New("specify at least one step of topics to create")
+	}
+
+	for i, n := range b.Steps {
+		if n < 1 || (i > 0 && n <= b.Steps[i-1]) {
+			return errors.New("the steps must be a strictly increasing number of topics")
+		}
+	}
+
+	if b.Lookups < 0 || b.Trickle < 0 {
+		return errors.New("the number of lookups and trickle events must not be negative")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	b.prefix = fmt.Sprintf("enbench-scale-%s", ulid.Make())
+	b.names, b.topics, b.steps = nil, nil, nil
+	b.destroy, b.destroyed, b.published = nil, 0, 0
+	b.reason = benchmarks.ExitCompleted
+	b.progress.Start()
+	b.progress.Set("topics_target", uint64(b.Steps[len(b.Steps)-1]))
+
+	// Topics are destroyed even if the run fails so that they do not accumulate
+	if !b.Keep {
+		defer b.cleanup()
+	}
+
+	rnd := rand.New(rand.NewSource(b.opts.Seed))
+	started := time.Now()
+	defer func() {
+		b.duration = time.Since(started)
+	}()
+
+	for _, n := range b.Steps {
+		if reason := b.opts.Exhausted(started, b.published); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		var step *Step
+		if step, err = b.step(ctx, n, rnd); err != nil {
+			if ctx.Err() != nil {
+				b.reason = benchmarks.ExitCanceled
+			}
+			return fmt.Errorf("step %d topics: %w", n, err)
+		}
+		b.steps = append(b.steps, step)
+
+		log.Info().
+			Int("topics", step.Topics).
+			Dur("create_p50", step.Create.Percentile(50)).
+			Dur("lookup_p50", step.Lookup.Percentile(50)).
+			Dur("list", step.List).
+			Dur("publish_p50", step.Publish.Percentile(50)).
+			Msg("topic scalability step complete")
+	}
+	return nil
+}
+
+// Creates topics until there are n topics, then measures lookups, listing, and the
+// trickle publish latency to every topic.
+func (b *Scale) step(ctx context.Context, n int, rnd *rand.Rand) (step *Step, err error) {
+	step = &Step{Topics: n, Create: &stats.Latencies{}, Lookup: &stats.Latencies{}, Publish: &stats.Latencies{}}
+
+	creating := time.Now()
+	for i := len(b.topics); i < n; i++ {
+		name := fmt.Sprintf("%s-%d", b.prefix, i)
+		sent := time.Now()
+
+		var id string
+		if id, err = b.client.CreateTopic(ctx, name); err != nil {
+			return nil, fmt.Errorf("could not create topic %s: %w", name, err)
+		}
+		step.Create.Update(time.Since(sent))
+
+		var topicID ulid.ULID
+		if topicID, err = ulid.Parse(id); err != nil {
+			return nil, err
+		}
+
+		b.names = append(b.names, name)
+		b.topics = append(b.topics, topicID)
+		b.progress.Set("topics", uint64(len(b.topics)))
+	}
+	step.Create.SetDuration(time.Since(creating))
+
+	looking := time.Now()
+	for i := 0; i < b.Lookups; i++ {
+		name := b.names[rnd.Intn(len(b.names))]
+		sent := time.Now()
+		if _, err = b.client.TopicID(ctx, name); err != nil {
+			return nil, fmt.Errorf("could not look up topic %s: %w", name, err)
+		}
+		step.Lookup.Update(time.Since(sent))
+	}
+	step.Lookup.SetDuration(time.Since(looking))
+
+	listing := time.Now()
+	var topics []*api.Topic
+	if topics, err = b.client.ListTopics(ctx); err != nil {
+		return nil, fmt.Errorf("could not list topics: %w", err)
+	}
+	step.List, step.Listed = time.Since(listing), len(topics)
+
+	if b.Trickle > 0 {
+		if err = b.trickle(ctx, step); err != nil {
+			return nil, err
+		}
+	}
+	return step, nil
+}
+
+// Publishes the trickle events to every topic one at a time, waiting for each ack so
+// that the latency of a topic is measured without contention from the other topics.
+func (b *Scale) trickle(parent context.Context, step *Step) (err error) {
+	var factory blast.EventFactory
+	if factory, err = blast.NewEventFactory(b.opts, b.topics[0]); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var stream api.Ensign_PublishClient
+	if stream, err = b.open(ctx); err != nil {
+		return err
+	}
+	defer stream.CloseSend()
+
+	replies := make(chan *api.PublisherReply, 1)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			rep, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			replies <- rep
+		}
+	}()
+
+	publishing := time.Now()
+	timer := time.NewTimer(b.AckTimeout)
+	defer timer.Stop()
+
+	for round := 0; round < b.Trickle; round++ {
+		for _, topicID := range b.topics {
+			event := factory()
+			event.TopicId = topicID.Bytes()
+
+			sent := time.Now()
+			if err = stream.Send(&api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: event}}); err != nil {
+				return fmt.Errorf("could not publish to topic %s: %w", topicID, err)
+			}
+			b.published++
+			b.progress.Add("published", 1)
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(b.AckTimeout)
+
+		wait:
+			for {
+				select {
+				case rep := <-replies:
+					// Replies to events that timed out earlier are discarded
+					if ack := rep.GetAck(); ack != nil && bytes.Equal(ack.Id, event.LocalId) {
+						step.Publish.Update(time.Since(sent))
+						break wait
+					}
+					if nack := rep.GetNack(); nack != nil && bytes.Equal(nack.Id, event.LocalId) {
+						step.Nacks++
+						break wait
+					}
+				case <-timer.C:
+					step.Publish.Update(0)
+					break wait
+				case err = <-recvErr:
+					return fmt.Errorf("publish stream closed: %w", err)
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+	}
+	step.Publish.SetDuration(time.Since(publishing))
+	return nil
+}
+
+// Opens a publish stream and waits for the server to signal that the stream is ready.
+func (b *Scale) open(ctx context.Context) (stream api.Ensign_PublishClient, err error) {
+	if stream, err = b.client.PublishStream(ctx); err != nil {
+		return nil, err
+	}
+
+	req := &api.PublisherRequest{
+		Embed: &api.PublisherRequest_OpenStream{
+			OpenStream: &api.OpenStream{
+				ClientId: fmt.Sprintf("benchmarks-%s", ulid.Make()),
+			},
+		},
+	}
+
+	if err = stream.Send(req); err != nil {
+		return nil, err
+	}
+
+	var rep *api.PublisherReply
+	if rep, err = stream.Recv(); err != nil {
+		return nil, err
+	}
+
+	if rep.GetReady() == nil {
+		return nil, errors.New("did not get publisher ready message")
+	}
+	return stream, nil
+}
+
+// Destroys the topics created by the run, measuring the time to destroy each topic;
+// topics that could not be destroyed are logged so that they can be removed manually.
+func (b *Scale) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(len(b.topics)+1)*time.Second)
+	defer cancel()
+
+	b.destroy = &stats.Latencies{}
+	destroying := time.Now()
+	for i, topicID := range b.topics {
+		sent := time.Now()
+		if _, err := b.client.DestroyTopic(ctx, topicID.String()); err != nil {
+			log.Warn().Err(err).Str("topic", b.names[i]).Str("topic_id", topicID.String()).Msg("could not destroy topic created by the run")
+			b.destroy.Update(0)
+			continue
+		}
+		b.destroy.Update(time.Since(sent))
+		b.destroyed++
+		b.progress.Set("destroyed", uint64(b.destroyed))
+	}
+	b.destroy.SetDuration(time.Since(destroying))
+}
+
+// Progress returns the number of topics created and events published so far.
+func (b *Scale) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// Returns the ratio of the median of the last step to the median of the first step
+// for each measurement, i.e. how much slower the operation became as topics were added.
+func (b *Scale) growth() map[string]float64 {
+	growth := make(map[string]float64)
+	if len(b.steps) < 2 {
+		return growth
+	}
+
+	first, last := b.steps[0], b.steps[len(b.steps)-1]
+	ratio := func(name string, from, to time.Duration) {
+		if from > 0 {
+			growth[name] = float64(to) / float64(from)
+		}
+	}
+
+	ratio("create", first.Create.Percentile(50), last.Create.Percentile(50))
+	ratio("lookup", first.Lookup.Percentile(50), last.Lookup.Percentile(50))
+	ratio("list", first.List, last.List)
+	ratio("publish", first.Publish.Percentile(50), last.Publish.Percentile(50))
+	return growth
+}
+
+// StepResults returns the measurements of every completed step.
+func (b *Scale) StepResults() []*Step {
+	return b.steps
+}
+
+func (b *Scale) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["steps"] = b.steps
+	results["topics_created"] = len(b.topics)
+	results["events"] = b.published
+	results["growth"] = b.growth()
+	results["duration"] = b.duration.String()
+	results["exit_reason"] = b.reason
+
+	if b.destroy != nil {
+		results["destroy_latencies"] = b.destroy
+		results["topics_destroyed"] = b.destroyed
+	}
+
+	results["experiment"] = map[string]interface{}{
+		"client_version": benchmarks.Version(),
+		"endpoint":       b.opts.Endpoint,
+		"steps":          b.Steps,
+		"lookups":        b.Lookups,
+		"trickle":        b.Trickle,
+		"ack_timeout":    b.AckTimeout.String(),
+		"keep":           b.Keep,
+		"data_size":      b.opts.DataSize,
+		"guard":          b.opts.Guard(),
+		"topic_prefix":   b.prefix,
+	}
+	return results, nil
+}
+
+// Serializes the step into a JSON map with durations as strings.
+func (s *Step) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["topics"] = s.Topics
+	data["listed"] = s.Listed
+	data["create_latencies"] = s.Create
+	data["lookup_latencies"] = s.Lookup
+	data["list_duration"] = s.List.String()
+	data["publish_latencies"] = s.Publish
+	data["nacks"] = s.Nacks
+	return json.Marshal(data)
+}
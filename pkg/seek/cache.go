@@ -0,0 +1,203 @@
+package seek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultCacheDelay is how long the topic is left idle before the cold replay.
+const DefaultCacheDelay = 5 * time.Minute
+
+// Cache compares the replay of events immediately after they were published, when they
+// are likely to be served from the caches of the server, with a cold replay that is
+// more likely to be read from storage, so that the read performance of the storage tier
+// can be told apart from cache hits. The warm replay reads back the events of the fill;
+// after the delay the cold replay either rereads the same events or, if History is set,
+// reads as many of the oldest events of the topic from the cold offset instead.
+type Cache struct {
+	opts    *options.Options
+	topicID ulid.ULID
+	start   uint64 // the number of events in the topic before the fill
+	warm    *Seek
+	cold    *Seek
+	current atomic.Pointer[Seek] // the replay in progress
+	reason  string
+
+	// Delay is how long the topic is left idle between the warm and cold replays.
+	Delay time.Duration
+
+	// History reads an older range of the topic starting at the cold offset for the
+	// cold replay rather than rereading the events of the fill.
+	History bool
+
+	// ColdOffset is the offset of the older range read by the cold replay.
+	ColdOffset uint64
+
+	// FillTimeout is how long to wait for the events of the fill to be acked.
+	FillTimeout time.Duration
+}
+
+func NewCache(opts *options.Options) *Cache {
+	return &Cache{opts: opts, Delay: DefaultCacheDelay, FillTimeout: DefaultFillTimeout}
+}
+
+func (b *Cache) Run(ctx context.Context) (err error) {
+	b.reason = benchmarks.ExitCompleted
+	b.warm, b.cold = nil, nil
+
+	// The head of the topic before the fill is the offset of the warm replay
+	if err = b.resolve(ctx); err != nil {
+		return err
+	}
+
+	if b.History && b.ColdOffset >= b.start {
+		return fmt.Errorf("the topic has %d events before the fill, there is no older history to replay after offset %d", b.start, b.ColdOffset)
+	}
+
+	b.warm = New(b.opts)
+	b.warm.Offset, b.warm.FillTimeout = b.start, b.FillTimeout
+	b.current.Store(b.warm)
+	if err = b.warm.Run(ctx); err != nil {
+		b.reason = b.warm.reason
+		return fmt.Errorf("warm replay: %w", err)
+	}
+
+	log.Info().Dur("delay", b.Delay).Uint64("replayed", b.warm.Replayed()).Msg("warm replay complete, waiting for the cold replay")
+	if b.Delay > 0 {
+		select {
+		case <-time.After(b.Delay):
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+
+	b.cold = New(b.opts)
+	b.cold.Fill, b.cold.Offset, b.cold.Limit = false, b.start, b.warm.Replayed()
+	if b.History {
+		// The older range must not overlap the events of the fill that were just read
+		b.cold.Offset = b.ColdOffset
+		if older := b.start - b.ColdOffset; older < b.cold.Limit {
+			b.cold.Limit = older
+		}
+	}
+
+	b.current.Store(b.cold)
+	if err = b.cold.Run(ctx); err != nil {
+		b.reason = b.cold.reason
+		return fmt.Errorf("cold replay: %w", err)
+	}
+
+	if b.cold.reason != benchmarks.ExitCompleted {
+		b.reason = b.cold.reason
+	} else if b.warm.reason != benchmarks.ExitCompleted {
+		b.reason = b.warm.reason
+	}
+	return nil
+}
+
+// Resolves the topic and the number of events in it before the fill.
+func (b *Cache) resolve(ctx context.Context) (err error) {
+	var client *ensign.Client
+	if client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	var info *api.TopicInfo
+	if info, err = client.TopicInfo(ctx, b.topicID); err != nil {
+		return err
+	}
+	b.start = info.Events
+	return nil
+}
+
+// Progress returns the number of events replayed so far by the replay in progress.
+func (b *Cache) Progress() map[string]interface{} {
+	if replay := b.current.Load(); replay != nil {
+		return replay.Progress()
+	}
+	return map[string]interface{}{}
+}
+
+// Warm returns the replay of the events immediately after the fill.
+func (b *Cache) Warm() *Seek {
+	return b.warm
+}
+
+// Cold returns the replay after the delay.
+func (b *Cache) Cold() *Seek {
+	return b.cold
+}
+
+func (b *Cache) Results() (benchmarks.Metrics, error) {
+	if b.warm == nil {
+		return nil, errors.New("the cache benchmark has not been run")
+	}
+
+	results := make(metrics.Metrics)
+	results["exit_reason"] = b.reason
+
+	for name, replay := range map[string]*Seek{"warm": b.warm, "cold": b.cold} {
+		if replay == nil {
+			continue
+		}
+
+		m, err := replay.Results()
+		if err != nil {
+			return nil, err
+		}
+
+		// The parameters of the replays are reported once with the experiment
+		summary := m.(metrics.Metrics)
+		delete(summary, "experiment")
+		results[name] = summary
+	}
+
+	// The slowdown of the cold replay is the ratio of the warm to the cold throughput
+	if b.cold != nil && b.warm.duration > 0 && b.cold.duration > 0 {
+		warm := float64(b.warm.replayed) / b.warm.duration.Seconds()
+		cold := float64(b.cold.replayed) / b.cold.duration.Seconds()
+		results["cold_slowdown"] = warm / cold
+	}
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"start":            b.start,
+		"delay":            b.Delay.String(),
+		"history":          b.History,
+		"cold_offset":      b.ColdOffset,
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"fill_timeout":     b.FillTimeout.String(),
+	}
+	return results, nil
+}
@@ -0,0 +1,382 @@
+package seek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// TopicPlaceholder is replaced by the name of the benchmark topic in the queries.
+const TopicPlaceholder = "{topic}"
+
+// DefaultQueryRepeat is the number of times each worker runs every query at each
+// concurrency level.
+const DefaultQueryRepeat = 10
+
+var (
+	// DefaultQueries read the whole topic, a page from its start, and a page further in.
+	DefaultQueries = []string{
+		"SELECT * FROM {topic}",
+		"SELECT * FROM {topic} LIMIT 100",
+		"SELECT * FROM {topic} OFFSET 100 LIMIT 100",
+	}
+
+	// DefaultQueryConcurrency is the number of concurrent workers at each level.
+	DefaultQueryConcurrency = []int{1, 2, 4, 8}
+)
+
+// Query runs a set of EnSQL queries against a pre-populated topic to measure the query
+// latency, the time to the first row, and the rows returned per second, stepping the
+// number of workers that run the queries concurrently to show how the query path
+// scales. At each level every worker runs each of the queries in turn the configured
+// number of times. The scaling efficiency of a level is its row throughput relative to
+// the throughput of the first level multiplied by the increase in concurrency, so that
+// perfect scaling is 1. By default the topic is filled before the queries are run.
+type Query struct {
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	filled   uint64
+	nacks    uint64
+	filling  time.Duration
+	levels   []*QueryLevel
+	current  atomic.Pointer[QueryLevel] // the level in progress
+	executed []QueryStats               // the totals of each query over every level
+	duration time.Duration
+	reason   string
+
+	// Queries are the EnSQL queries run by every worker; the topic placeholder is
+	// replaced by the name of the benchmark topic.
+	Queries []string
+
+	// Concurrency is the number of concurrent workers at each level.
+	Concurrency []int
+
+	// Repeat is the number of times each worker runs every query at each level.
+	Repeat int
+
+	// Fill publishes the configured number of events to the topic before the queries.
+	Fill bool
+
+	// FillTimeout is how long to wait for the events of the fill to be acked.
+	FillTimeout time.Duration
+}
+
+// QueryLevel is the measurement of the queries run at a level of concurrency.
+type QueryLevel struct {
+	Concurrency   int              `json:"concurrency"`
+	Queries       uint64           `json:"queries"`
+	Rows          uint64           `json:"rows"`
+	Failures      uint64           `json:"failures"`
+	Duration      string           `json:"duration"`
+	QueriesPerSec float64          `json:"queries_per_sec"`
+	RowsPerSec    float64          `json:"rows_per_sec"`
+	Scaling       float64          `json:"scaling_efficiency,omitempty"`
+	Latencies     *stats.Latencies `json:"query_latencies"`     // from the query to the last row
+	FirstRow      *stats.Latencies `json:"first_row_latencies"` // from the query to the first row
+	duration      time.Duration
+}
+
+// QueryStats are the totals of a query over every level of concurrency.
+type QueryStats struct {
+	Query      string           `json:"query"`
+	Executions uint64           `json:"executions"`
+	Rows       uint64           `json:"rows"`
+	Failures   uint64           `json:"failures"`
+	Latencies  *stats.Latencies `json:"query_latencies"`
+}
+
+func NewQuery(opts *options.Options) *Query {
+	queries := make([]string, len(DefaultQueries))
+	copy(queries, DefaultQueries)
+
+	concurrency := make([]int, len(DefaultQueryConcurrency))
+	copy(concurrency, DefaultQueryConcurrency)
+
+	return &Query{
+		opts:        opts,
+		Queries:     queries,
+		Concurrency: concurrency,
+		Repeat:      DefaultQueryRepeat,
+		Fill:        true,
+		FillTimeout: DefaultFillTimeout,
+	}
+}
+
+func (b *Query) Run(ctx context.Context) (err error) {
+	if len(b.Queries) == 0 {
+		return errors.New("at least one query is required")
+	}
+
+	for _, workers := range b.Concurrency {
+		if workers < 1 {
+			return fmt.Errorf("invalid concurrency %d: at least one worker is required", workers)
+		}
+	}
+
+	if len(b.Concurrency) == 0 || b.Repeat < 1 {
+		return errors.New("at least one concurrency level and repetition is required")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	b.reason = benchmarks.ExitCompleted
+	b.levels = make([]*QueryLevel, 0, len(b.Concurrency))
+	b.executed = make([]QueryStats, len(b.Queries))
+	for i, query := range b.Queries {
+		b.executed[i] = QueryStats{Query: b.query(query), Latencies: &stats.Latencies{}}
+	}
+
+	// The fill is the fill of the seek benchmark so that both read the same history
+	if b.Fill {
+		filler := New(b.opts)
+		filler.client, filler.topicID, filler.FillTimeout = b.client, b.topicID, b.FillTimeout
+		err = filler.fill(ctx)
+		b.filled, b.nacks, b.filling = filler.filled, filler.nacks, filler.filling
+		if err != nil {
+			b.reason = filler.reason
+			return err
+		}
+	}
+
+	started := time.Now()
+	defer func() {
+		b.duration = time.Since(started)
+	}()
+
+	var rows uint64
+	for _, workers := range b.Concurrency {
+		if reason := b.opts.Exhausted(started, rows); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		level := b.run(ctx, workers)
+		rows += level.Rows
+		if ctx.Err() != nil {
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+
+		log.Info().
+			Int("concurrency", workers).
+			Uint64("queries", level.Queries).
+			Uint64("failures", level.Failures).
+			Float64("rows_per_sec", level.RowsPerSec).
+			Msg("query level complete")
+	}
+
+	if rows == 0 {
+		return errors.New("the queries did not return any rows")
+	}
+	return nil
+}
+
+// Runs every query the configured number of times on each of the workers.
+func (b *Query) run(ctx context.Context, workers int) *QueryLevel {
+	level := &QueryLevel{Concurrency: workers, Latencies: &stats.Latencies{}, FirstRow: &stats.Latencies{}}
+	b.current.Store(level)
+
+	var (
+		wg                      sync.WaitGroup
+		queries, rows, failures uint64
+	)
+
+	started := time.Now()
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for r := 0; r < b.Repeat && ctx.Err() == nil; r++ {
+				for q := range b.executed {
+					n, first, latency, err := b.execute(ctx, b.executed[q].Query)
+					atomic.AddUint64(&queries, 1)
+					atomic.AddUint64(&rows, n)
+					atomic.AddUint64(&b.executed[q].Executions, 1)
+					atomic.AddUint64(&b.executed[q].Rows, n)
+
+					if err != nil {
+						atomic.AddUint64(&failures, 1)
+						atomic.AddUint64(&b.executed[q].Failures, 1)
+						log.Debug().Err(err).Str("query", b.executed[q].Query).Msg("query failed")
+						continue
+					}
+
+					level.Latencies.Update(latency)
+					b.executed[q].Latencies.Update(latency)
+					if n > 0 {
+						level.FirstRow.Update(first)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	level.duration = time.Since(started)
+	level.Queries, level.Rows, level.Failures = queries, rows, failures
+	level.Duration = level.duration.String()
+	level.Latencies.SetDuration(level.duration)
+	level.FirstRow.SetDuration(level.duration)
+
+	if secs := level.duration.Seconds(); secs > 0 {
+		level.QueriesPerSec = float64(queries) / secs
+		level.RowsPerSec = float64(rows) / secs
+	}
+
+	// The scaling is relative to the throughput per worker of the first level
+	if len(b.levels) > 0 {
+		if base := b.levels[0]; base.RowsPerSec > 0 {
+			perWorker := base.RowsPerSec / float64(base.Concurrency)
+			level.Scaling = level.RowsPerSec / (perWorker * float64(workers))
+		}
+	} else if level.RowsPerSec > 0 {
+		level.Scaling = 1
+	}
+
+	b.levels = append(b.levels, level)
+	return level
+}
+
+// Runs the query and fetches every row, returning the number of rows, the time to the
+// first row, and the time to the last row. The cursor fetches the first row when it is
+// created so the time to the first row includes the query itself; queries without any
+// results return no rows when the cursor is created.
+func (b *Query) execute(ctx context.Context, query string) (rows uint64, first, latency time.Duration, err error) {
+	started := time.Now()
+
+	var cursor *ensign.QueryCursor
+	if cursor, err = b.client.EnSQL(ctx, &api.Query{Query: query}); err != nil {
+		if errors.Is(err, ensign.ErrNoRows) {
+			return 0, 0, time.Since(started), nil
+		}
+		return 0, 0, 0, err
+	}
+	defer cursor.Close()
+
+	for {
+		if _, err = cursor.FetchOne(); err != nil {
+			if errors.Is(err, ensign.ErrNoRows) {
+				return rows, first, time.Since(started), nil
+			}
+			return rows, first, 0, err
+		}
+
+		if rows == 0 {
+			first = time.Since(started)
+		}
+		rows++
+	}
+}
+
+// Returns the query with the topic placeholder replaced by the name of the topic.
+func (b *Query) query(query string) string {
+	return strings.ReplaceAll(query, TopicPlaceholder, b.opts.Topic)
+}
+
+// Progress returns the queries completed so far at the level in progress.
+func (b *Query) Progress() map[string]interface{} {
+	if level := b.current.Load(); level != nil {
+		return map[string]interface{}{"concurrency": level.Concurrency, "queries": level.Latencies.Count()}
+	}
+	return map[string]interface{}{}
+}
+
+// Levels returns the measurements of the completed levels of concurrency.
+func (b *Query) Levels() []*QueryLevel {
+	return b.levels
+}
+
+func (b *Query) Results() (benchmarks.Metrics, error) {
+	if b.levels == nil {
+		return nil, errors.New("the query benchmark has not been run")
+	}
+
+	var queries, rows, failures uint64
+	latencies, first := &stats.Latencies{}, &stats.Latencies{}
+	for _, level := range b.levels {
+		queries += level.Queries
+		rows += level.Rows
+		failures += level.Failures
+		latencies.Append(level.Latencies)
+		first.Append(level.FirstRow)
+	}
+	latencies.SetDuration(b.duration)
+	first.SetDuration(b.duration)
+
+	results := make(metrics.Metrics)
+	results["events"] = rows
+	results["queries"] = queries
+	results["failed_queries"] = failures
+	results["levels"] = b.levels
+	results["by_query"] = b.executed
+	results["query_latencies"] = latencies
+	results["first_row_latencies"] = first
+	results["duration"] = b.duration.String()
+	results["exit_reason"] = b.reason
+
+	// The level with the highest row throughput
+	var best *QueryLevel
+	for _, level := range b.levels {
+		if best == nil || level.RowsPerSec > best.RowsPerSec {
+			best = level
+		}
+	}
+
+	if best != nil {
+		results["rows_per_sec"] = best.RowsPerSec
+		results["best_concurrency"] = best.Concurrency
+		results["scaling_efficiency"] = b.levels[len(b.levels)-1].Scaling
+	}
+
+	if b.Fill {
+		results["filled"] = b.filled
+		results["nacks"] = b.nacks
+		results["fill_duration"] = b.filling.String()
+	}
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"queries":          b.Queries,
+		"concurrency":      b.Concurrency,
+		"repeat":           b.Repeat,
+		"fill":             b.Fill,
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"fill_timeout":     b.FillTimeout.String(),
+	}
+	return results, nil
+}
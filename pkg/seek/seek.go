@@ -0,0 +1,321 @@
+/*
+Package seek implements a benchmark of the replay path. Rather than delivering events to
+a subscriber as they are published, the benchmark reads the events already committed to
+a topic from a historical offset with an EnSQL query, measuring the time to the first
+replayed event, the replay throughput, and the time taken to catch up to the head of
+the topic as it was when the replay started. By default the topic is filled with a
+fixed number of events before the replay so that there is history to read back.
+*/
+package seek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/sustain"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultFillTimeout is how long to wait for the events of the fill to be acked.
+const DefaultFillTimeout = 30 * time.Second
+
+// How often the fill phase checks in-flight events for acks.
+const ackPoll = time.Millisecond
+
+// Seek replays the history of a topic from an offset and measures how fast it is read.
+type Seek struct {
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	filled   uint64        // the number of events committed by the fill
+	nacks    uint64        // the number of events nacked during the fill
+	filling  time.Duration // the duration of the fill phase
+	head     uint64        // the number of events in the topic when the replay started
+	replayed uint64
+	bytes    uint64
+	fetches  []time.Duration // the time taken to fetch each replayed event
+	first    time.Duration   // the time from the query to the first replayed event
+	duration time.Duration   // the time from the query to the last replayed event
+	reason   string
+	progress stats.Progress
+
+	// Offset is the number of events at the start of the topic that are skipped.
+	Offset uint64
+
+	// Limit is the maximum number of events that are replayed after the offset; if
+	// zero the history is replayed up to the head of the topic.
+	Limit uint64
+
+	// Fill publishes the configured number of events to the topic before the replay.
+	Fill bool
+
+	// FillTimeout is how long to wait for the events of the fill to be acked.
+	FillTimeout time.Duration
+}
+
+func New(opts *options.Options) *Seek {
+	return &Seek{opts: opts, Fill: true, FillTimeout: DefaultFillTimeout}
+}
+
+func (b *Seek) Run(ctx context.Context) (err error) {
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	b.reason = benchmarks.ExitCompleted
+	b.filled, b.nacks, b.filling = 0, 0, 0
+	if b.Fill {
+		if err = b.fill(ctx); err != nil {
+			return err
+		}
+	}
+
+	var info *api.TopicInfo
+	if info, err = b.client.TopicInfo(ctx, b.topicID); err != nil {
+		return err
+	}
+
+	if b.head = info.Events; b.head <= b.Offset {
+		return fmt.Errorf("the topic has %d events, there is no history to replay after offset %d", b.head, b.Offset)
+	}
+
+	log.Info().
+		Str("topic", b.opts.Topic).
+		Str("topic_id", b.topicID.String()).
+		Uint64("head", b.head).
+		Uint64("offset", b.Offset).
+		Msg("replaying topic history")
+
+	return b.replay(ctx)
+}
+
+// Publishes the events to the topic and waits for the server to ack them.
+func (b *Seek) fill(ctx context.Context) (err error) {
+	N := b.opts.Operations
+	if b.opts.MaxEvents > 0 && b.opts.MaxEvents < N {
+		N = b.opts.MaxEvents
+	}
+
+	factory, closer, err := sustain.NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	inflight := make([]*ensign.Event, 0, N)
+
+	started := time.Now()
+	defer func() {
+		b.filling = time.Since(started)
+	}()
+
+	for i := uint64(0); i < N; i++ {
+		event := factory()
+		if err = b.client.Publish(b.topicID.String(), event); err != nil {
+			return err
+		}
+		inflight = append(inflight, event)
+	}
+
+	poll := time.NewTicker(ackPoll)
+	defer poll.Stop()
+	timeout := time.After(b.FillTimeout)
+
+	for len(inflight) > 0 {
+		select {
+		case <-poll.C:
+			pending := inflight[:0]
+			for _, event := range inflight {
+				if acked, _ := event.Acked(); acked {
+					b.filled++
+					continue
+				}
+
+				if nacked, _ := event.Nacked(); nacked {
+					b.nacks++
+					continue
+				}
+				pending = append(pending, event)
+			}
+			inflight = pending
+		case <-timeout:
+			log.Warn().Int("unacked", len(inflight)).Msg("seek fill timeout exceeded")
+			return nil
+		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Reads the history of the topic after the offset with an EnSQL query as fast as
+// possible until the cursor is exhausted or the run limits are reached.
+func (b *Seek) replay(ctx context.Context) (err error) {
+	b.replayed, b.bytes = 0, 0
+	b.fetches = make([]time.Duration, 0, b.expected())
+
+	query := &api.Query{Query: fmt.Sprintf("SELECT * FROM %s", b.opts.Topic)}
+	if b.Offset > 0 {
+		query.Query = fmt.Sprintf("%s OFFSET %d", query.Query, b.Offset)
+	}
+	if b.Limit > 0 {
+		query.Query = fmt.Sprintf("%s LIMIT %d", query.Query, b.Limit)
+	}
+
+	b.progress.Start()
+	b.progress.Set("expected", b.expected())
+	started := time.Now()
+
+	// The cursor fetches the first event when it is created to check for errors
+	var cursor *ensign.QueryCursor
+	if cursor, err = b.client.EnSQL(ctx, query); err != nil {
+		if ctx.Err() != nil {
+			b.reason = benchmarks.ExitCanceled
+		}
+		return err
+	}
+	defer cursor.Close()
+
+	last := started
+	for {
+		if reason := b.opts.Exhausted(started, b.replayed); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		// The cursor returns ErrNoRows once every result has been fetched
+		fetching := time.Now()
+		var event *ensign.Event
+		if event, err = cursor.FetchOne(); err != nil {
+			if errors.Is(err, ensign.ErrNoRows) {
+				err = nil
+				break
+			}
+
+			if ctx.Err() != nil {
+				b.reason = benchmarks.ExitCanceled
+			}
+			return err
+		}
+
+		last = time.Now()
+		if b.replayed == 0 {
+			b.first = last.Sub(started)
+		}
+
+		b.fetches = append(b.fetches, last.Sub(fetching))
+		b.replayed++
+		b.bytes += uint64(len(event.Data))
+		b.progress.Add("replayed", 1)
+	}
+
+	b.duration = last.Sub(started)
+	log.Info().Uint64("replayed", b.replayed).Dur("duration", b.duration).Msg("seek benchmark complete")
+
+	if b.replayed == 0 {
+		return errors.New("no events were replayed from the topic history")
+	}
+	return nil
+}
+
+// Returns the number of events in the history of the topic that should be replayed.
+func (b *Seek) expected() uint64 {
+	if b.head <= b.Offset {
+		return 0
+	}
+
+	if expected := b.head - b.Offset; b.Limit == 0 || expected < b.Limit {
+		return expected
+	}
+	return b.Limit
+}
+
+// Progress returns the number of events replayed so far.
+func (b *Seek) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// FetchLatencies returns the distribution of the time taken to fetch each replayed event.
+func (b *Seek) FetchLatencies() *stats.Latencies {
+	latencies := &stats.Latencies{}
+	latencies.Update(b.fetches...)
+	latencies.SetDuration(b.duration)
+	return latencies
+}
+
+// Replayed returns the number of events read from the topic history.
+func (b *Seek) Replayed() uint64 {
+	return b.replayed
+}
+
+func (b *Seek) Results() (benchmarks.Metrics, error) {
+	expected := b.expected()
+	results := make(metrics.Metrics)
+	results["events"] = b.replayed
+	results["expected"] = expected
+	results["head"] = b.head
+	results["caught_up"] = b.replayed >= expected
+	results["bytes"] = b.bytes
+	results["fetch_latencies"] = b.FetchLatencies()
+	results["time_to_first_event"] = b.first.String()
+	results["catch_up"] = b.duration.String()
+	results["exit_reason"] = b.reason
+
+	if b.replayed < expected {
+		results["missing"] = expected - b.replayed
+	}
+
+	if b.Fill {
+		results["filled"] = b.filled
+		results["nacks"] = b.nacks
+		results["fill_duration"] = b.filling.String()
+	}
+
+	if secs := b.duration.Seconds(); secs > 0 {
+		results["events_per_sec"] = float64(b.replayed) / secs
+	}
+
+	bandwidth := &stats.Bandwidth{}
+	bandwidth.Received(b.bytes)
+	bandwidth.SetDuration(b.duration)
+	results["bandwidth"] = bandwidth
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"offset":           b.Offset,
+		"limit":            b.Limit,
+		"fill":             b.Fill,
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"fill_timeout":     b.FillTimeout.String(),
+	}
+	return results, nil
+}
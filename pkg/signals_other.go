@@ -0,0 +1,9 @@
+//go:build !unix
+
+package benchmarks
+
+// DumpOnSignal does nothing since SIGUSR1 is not available on this platform so interim
+// statistics cannot be dumped.
+func DumpOnSignal(string, Monitor) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package benchmarks
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DumpOnSignal dumps the interim statistics of the benchmark to stderr as a single line
+// of JSON whenever the process receives SIGUSR1, e.g. `kill -USR1 <pid>`, without
+// stopping the run. The returned function stops listening for the signal.
+func DumpOnSignal(name string, mon Monitor) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGUSR1)
+
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				dump := map[string]interface{}{
+					"benchmark": name,
+					"progress":  mon.Progress(),
+				}
+
+				data, err := json.Marshal(dump)
+				if err != nil {
+					log.Error().Err(err).Msg("could not marshal interim statistics")
+					continue
+				}
+				os.Stderr.Write(append(data, '\n'))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
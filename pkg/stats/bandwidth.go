@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Bandwidth tracks the number of bytes sent and received by a benchmark and computes
+// the transfer rates over the externally set duration of the run. Like Latencies, the
+// duration should be set by the measurer once the run is complete so that the time
+// spent setting up or tearing down the run is not included in the rates; without a
+// duration the rates are zero rather than an arbitrary estimate.
+//
+// Publishers count the serialized size of the events they send and subscribers count
+// the size of the payloads they receive. This object is thread-safe.
+type Bandwidth struct {
+	sync.RWMutex
+	sent     uint64
+	received uint64
+	duration time.Duration
+}
+
+// Sent records bytes sent by the benchmark (thread-safe).
+func (b *Bandwidth) Sent(n uint64) {
+	b.Lock()
+	defer b.Unlock()
+	b.sent += n
+}
+
+// Received records bytes received by the benchmark (thread-safe).
+func (b *Bandwidth) Received(n uint64) {
+	b.Lock()
+	defer b.Unlock()
+	b.received += n
+}
+
+// SetDuration sets the duration over which the bytes were transferred.
+func (b *Bandwidth) SetDuration(duration time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	b.duration = duration
+}
+
+// Duration returns the externally set duration of the transfer.
+func (b *Bandwidth) Duration() time.Duration {
+	b.RLock()
+	defer b.RUnlock()
+	return b.duration
+}
+
+// BytesSent returns the total number of bytes sent.
+func (b *Bandwidth) BytesSent() uint64 {
+	b.RLock()
+	defer b.RUnlock()
+	return b.sent
+}
+
+// BytesReceived returns the total number of bytes received.
+func (b *Bandwidth) BytesReceived() uint64 {
+	b.RLock()
+	defer b.RUnlock()
+	return b.received
+}
+
+// SentMBPerSec returns the megabytes (1e6 bytes) sent per second.
+func (b *Bandwidth) SentMBPerSec() float64 {
+	b.RLock()
+	defer b.RUnlock()
+	return b.rate(b.sent)
+}
+
+// ReceivedMBPerSec returns the megabytes (1e6 bytes) received per second.
+func (b *Bandwidth) ReceivedMBPerSec() float64 {
+	b.RLock()
+	defer b.RUnlock()
+	return b.rate(b.received)
+}
+
+func (b *Bandwidth) rate(n uint64) float64 {
+	if b.duration <= 0 {
+		return 0.0
+	}
+	return float64(n) / 1e6 / b.duration.Seconds()
+}
+
+// Append the bytes of another bandwidth to this bandwidth. Bandwidths appended from
+// concurrent workers overlap in time, so the duration is the longest of the durations
+// rather than their sum and the rates are the aggregate rates of the workers.
+func (b *Bandwidth) Append(o *Bandwidth) {
+	if b == o {
+		return
+	}
+
+	o.RLock()
+	defer o.RUnlock()
+	b.Lock()
+	defer b.Unlock()
+
+	b.sent += o.sent
+	b.received += o.received
+	if o.duration > b.duration {
+		b.duration = o.duration
+	}
+}
+
+// Serializes the bandwidth into a JSON map with the rates in MB per second.
+func (b *Bandwidth) MarshalJSON() ([]byte, error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	data := make(map[string]interface{})
+	data["bytes_sent"] = b.sent
+	data["bytes_received"] = b.received
+	data["duration"] = b.duration.String()
+	data["sent_mb_per_sec"] = b.rate(b.sent)
+	data["received_mb_per_sec"] = b.rate(b.received)
+	return json.Marshal(data)
+}
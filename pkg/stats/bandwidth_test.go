@@ -0,0 +1,40 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidth(t *testing.T) {
+	bandwidth := &stats.Bandwidth{}
+	require.Equal(t, 0.0, bandwidth.SentMBPerSec(), "expected no rate without a duration")
+
+	bandwidth.Sent(3e6)
+	bandwidth.Sent(1e6)
+	bandwidth.Received(1e6)
+	require.Equal(t, 0.0, bandwidth.SentMBPerSec(), "expected no rate without a duration")
+
+	bandwidth.SetDuration(2 * time.Second)
+	require.Equal(t, uint64(4e6), bandwidth.BytesSent())
+	require.Equal(t, uint64(1e6), bandwidth.BytesReceived())
+	require.Equal(t, 2.0, bandwidth.SentMBPerSec())
+	require.Equal(t, 0.5, bandwidth.ReceivedMBPerSec())
+
+	data, err := json.Marshal(bandwidth)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"bytes_sent": 4000000, "bytes_received": 1000000, "duration": "2s", "sent_mb_per_sec": 2, "received_mb_per_sec": 0.5}`, string(data))
+
+	// Concurrent workers overlap so the longest duration is used
+	other := &stats.Bandwidth{}
+	other.Sent(2e6)
+	other.SetDuration(time.Second)
+	bandwidth.Append(other)
+	bandwidth.Append(bandwidth)
+	require.Equal(t, uint64(6e6), bandwidth.BytesSent())
+	require.Equal(t, 2*time.Second, bandwidth.Duration())
+	require.Equal(t, 3.0, bandwidth.SentMBPerSec())
+}
@@ -0,0 +1,36 @@
+package stats
+
+import "math"
+
+// Correlation computes the Pearson correlation coefficient of two paired series of
+// samples, e.g. to correlate latency with another measurement taken over the same
+// intervals. If the series have different lengths, only the paired samples are
+// considered. If fewer than two pairs are available or either series has no variance
+// then 0.0 is returned since no correlation can be measured.
+func Correlation(xs, ys []float64) float64 {
+	n := len(xs)
+	if len(ys) < n {
+		n = len(ys)
+	}
+
+	if n < 2 {
+		return 0.0
+	}
+
+	var sx, sy, sxx, syy, sxy float64
+	for i := 0; i < n; i++ {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		syy += ys[i] * ys[i]
+		sxy += xs[i] * ys[i]
+	}
+
+	N := float64(n)
+	num := N*sxy - sx*sy
+	den := math.Sqrt(N*sxx-sx*sx) * math.Sqrt(N*syy-sy*sy)
+	if den == 0 || math.IsNaN(den) {
+		return 0.0
+	}
+	return num / den
+}
@@ -0,0 +1,23 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelation(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5, 6}
+	require.InDelta(t, 1.0, stats.Correlation(xs, []float64{2, 4, 6, 8, 10, 12}), 1e-9)
+	require.InDelta(t, -1.0, stats.Correlation(xs, []float64{6, 5, 4, 3, 2, 1}), 1e-9)
+	require.InDelta(t, 0.919593, stats.Correlation(xs, []float64{1.2, 1.9, 4.1, 3.2, 4.8, 5.0}), 1e-4)
+
+	// Unpaired samples are ignored
+	require.InDelta(t, 1.0, stats.Correlation(xs, []float64{2, 4, 6}), 1e-9)
+
+	// No correlation can be computed
+	require.Equal(t, 0.0, stats.Correlation(nil, nil))
+	require.Equal(t, 0.0, stats.Correlation([]float64{1}, []float64{2}))
+	require.Equal(t, 0.0, stats.Correlation(xs, []float64{3, 3, 3, 3, 3, 3}))
+}
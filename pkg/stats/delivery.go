@@ -0,0 +1,103 @@
+package stats
+
+import "encoding/json"
+
+// Delivery guarantees that may be observed by a run.
+const (
+	ExactlyOnce = "exactly-once"
+	AtLeastOnce = "at-least-once"
+	AtMostOnce  = "at-most-once"
+	NoGuarantee = "none"
+)
+
+// Delivery summarizes the delivery semantics observed by a subscriber: whether every
+// published event was delivered, whether any event was delivered more than once, and
+// whether events were delivered in the order that they were published. It combines the
+// loss, duplication, and ordering checks of a run into a single report section.
+type Delivery struct {
+	Published  uint64 // the number of events committed by the publisher
+	Delivered  uint64 // the number of distinct published events that were delivered
+	Duplicates uint64 // the number of redeliveries of events that were already delivered
+	Reordered  uint64 // the number of events delivered after an event published later
+	Foreign    uint64 // the number of events delivered that were not published by the run
+	highest    uint64 // one more than the highest publish sequence delivered so far
+}
+
+// Deliver records the first delivery of the event with the specified publish sequence,
+// which is the zero-based position of the event in the order it was published.
+func (d *Delivery) Deliver(sequence uint64) {
+	d.Delivered++
+	if sequence+1 < d.highest {
+		d.Reordered++
+		return
+	}
+	d.highest = sequence + 1
+}
+
+// Lost returns the number of published events that were never delivered.
+func (d *Delivery) Lost() uint64 {
+	if d.Delivered >= d.Published {
+		return 0
+	}
+	return d.Published - d.Delivered
+}
+
+// AtLeastOnce is true if every published event was delivered.
+func (d *Delivery) AtLeastOnce() bool {
+	return d.Lost() == 0
+}
+
+// AtMostOnce is true if no event was delivered more than once.
+func (d *Delivery) AtMostOnce() bool {
+	return d.Duplicates == 0
+}
+
+// ExactlyOnce is true if every published event was delivered exactly once.
+func (d *Delivery) ExactlyOnce() bool {
+	return d.AtLeastOnce() && d.AtMostOnce()
+}
+
+// Ordered is true if events were delivered in the order that they were published.
+func (d *Delivery) Ordered() bool {
+	return d.Reordered == 0
+}
+
+// Guarantee returns the strongest delivery guarantee observed by the run. Note that a
+// guarantee observed by a single run is evidence for, not proof of, the guarantee.
+func (d *Delivery) Guarantee() string {
+	switch {
+	case d.ExactlyOnce():
+		return ExactlyOnce
+	case d.AtLeastOnce():
+		return AtLeastOnce
+	case d.AtMostOnce():
+		return AtMostOnce
+	default:
+		return NoGuarantee
+	}
+}
+
+// Serializes the observed delivery semantics into a JSON map.
+func (d *Delivery) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["guarantee"] = d.Guarantee()
+	data["exactly_once"] = d.ExactlyOnce()
+	data["at_least_once"] = d.AtLeastOnce()
+	data["at_most_once"] = d.AtMostOnce()
+	data["ordered"] = d.Ordered()
+	data["published"] = d.Published
+	data["delivered"] = d.Delivered
+	data["lost"] = d.Lost()
+	data["duplicates"] = d.Duplicates
+	data["reordered"] = d.Reordered
+	data["foreign"] = d.Foreign
+
+	if d.Published > 0 {
+		data["loss_rate"] = float64(d.Lost()) / float64(d.Published)
+	}
+	if d.Delivered > 0 {
+		data["duplicate_rate"] = float64(d.Duplicates) / float64(d.Delivered)
+		data["reorder_rate"] = float64(d.Reordered) / float64(d.Delivered)
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,48 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelivery(t *testing.T) {
+	delivery := &stats.Delivery{Published: 5}
+	for _, seq := range []uint64{0, 1, 3, 2, 4} {
+		delivery.Deliver(seq)
+	}
+
+	require.Equal(t, uint64(5), delivery.Delivered)
+	require.Equal(t, uint64(1), delivery.Reordered)
+	require.Zero(t, delivery.Lost())
+	require.True(t, delivery.ExactlyOnce())
+	require.False(t, delivery.Ordered())
+	require.Equal(t, stats.ExactlyOnce, delivery.Guarantee())
+
+	delivery.Duplicates++
+	require.Equal(t, stats.AtLeastOnce, delivery.Guarantee())
+
+	delivery = &stats.Delivery{Published: 3}
+	delivery.Deliver(0)
+	delivery.Deliver(2)
+	require.Equal(t, uint64(1), delivery.Lost())
+	require.True(t, delivery.Ordered())
+	require.Equal(t, stats.AtMostOnce, delivery.Guarantee())
+
+	delivery.Duplicates++
+	require.Equal(t, stats.NoGuarantee, delivery.Guarantee())
+
+	data, err := json.Marshal(delivery)
+	require.NoError(t, err)
+
+	report := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Equal(t, stats.NoGuarantee, report["guarantee"])
+	require.Equal(t, false, report["exactly_once"])
+	require.Equal(t, true, report["ordered"])
+	require.Equal(t, float64(1), report["lost"])
+	require.InDelta(t, 1.0/3.0, report["loss_rate"], 1e-9)
+	require.InDelta(t, 0.5, report["duplicate_rate"], 1e-9)
+}
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DerivedMetrics are computed from the distribution of Latencies and included in their
+// JSON output under the name of each metric; none are reported by default. Like the
+// ReportedPercentiles, they are usually configured by the experiment config.
+var DerivedMetrics []Derived
+
+// Kinds of derived metrics.
+const (
+	DerivedRatio = "ratio" // the ratio of two percentiles, e.g. p99/p50 to measure tail heaviness
+	DerivedApdex = "apdex" // the application performance index for a target latency
+)
+
+// Derived is a metric computed from the distribution of Latencies. A ratio divides the
+// estimate of one percentile by another. The apdex of a threshold T is the fraction of
+// satisfied samples (at most T) plus half the fraction of tolerated samples (at most
+// 4T); timeouts are frustrated samples, so the apdex ranges from 0 to 1.
+type Derived struct {
+	Name        string
+	Kind        string
+	Numerator   float64       // the percentile divided by the denominator of a ratio
+	Denominator float64       // the percentile that divides the numerator of a ratio
+	Threshold   time.Duration // the satisfied latency of an apdex
+}
+
+// ParseDerived parses a derived metric with the name from a ratio of percentiles such as
+// "p99/p50" or an apdex with its threshold such as "apdex:50ms".
+func ParseDerived(name, spec string) (_ Derived, err error) {
+	d := Derived{Name: strings.TrimSpace(name)}
+	if d.Name == "" {
+		return d, fmt.Errorf("derived metric %q must have a name", spec)
+	}
+
+	spec = strings.TrimSpace(spec)
+	if threshold, ok := strings.CutPrefix(spec, DerivedApdex+":"); ok {
+		d.Kind = DerivedApdex
+		if d.Threshold, err = time.ParseDuration(strings.TrimSpace(threshold)); err != nil || d.Threshold <= 0 {
+			return d, fmt.Errorf("derived metric %s: could not parse apdex threshold %q", d.Name, threshold)
+		}
+		return d, nil
+	}
+
+	num, den, ok := strings.Cut(spec, "/")
+	if !ok {
+		return d, fmt.Errorf("derived metric %s: unknown specification %q, expected a ratio such as p99/p50 or apdex:50ms", d.Name, spec)
+	}
+
+	d.Kind = DerivedRatio
+	if d.Numerator, err = parsePercentile(num); err != nil {
+		return d, fmt.Errorf("derived metric %s: %w", d.Name, err)
+	}
+
+	if d.Denominator, err = parsePercentile(den); err != nil {
+		return d, fmt.Errorf("derived metric %s: %w", d.Name, err)
+	}
+	return d, nil
+}
+
+// Parses a percentile such as p99, p99.9, or p99_9; like the names of the reported
+// percentiles, an underscore may be used in place of the decimal point.
+func parsePercentile(s string) (_ float64, err error) {
+	s = strings.TrimSpace(s)
+	digits, ok := strings.CutPrefix(s, "p")
+	if !ok {
+		return 0, fmt.Errorf("could not parse percentile %q, expected e.g. p99", s)
+	}
+	digits = strings.Replace(digits, "_", ".", 1)
+
+	var val float64
+	if val, err = strconv.ParseFloat(digits, 64); err != nil || val <= 0 || val > 100 {
+		return 0, fmt.Errorf("could not parse percentile %q, expected e.g. p99", s)
+	}
+	return val, nil
+}
+
+// Computes the metric from the latencies, returning false if it is undefined, e.g. if
+// there are no samples. Must be called with the lock of the latencies.
+func (d Derived) compute(s *Latencies) (float64, bool) {
+	switch d.Kind {
+	case DerivedRatio:
+		den := s.percentiles.Percentile(d.Denominator)
+		if den <= 0 {
+			return 0, false
+		}
+		return float64(s.percentiles.Percentile(d.Numerator)) / float64(den), true
+	case DerivedApdex:
+		total := s.percentiles.N() + s.timeouts
+		if total == 0 {
+			return 0, false
+		}
+
+		satisfied := s.percentiles.Below(d.Threshold)
+		tolerated := s.percentiles.Below(4*d.Threshold) - satisfied
+		return (float64(satisfied) + float64(tolerated)/2) / float64(total), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,104 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePercentiles(t *testing.T) {
+	percentiles, err := stats.ParsePercentiles([]float64{50, 99.9, 99.99, 100})
+	require.NoError(t, err)
+	require.Equal(t, []stats.ReportedPercentile{{Name: "p50", Value: 50}, {Name: "p99_9", Value: 99.9}, {Name: "p99_99", Value: 99.99}, {Name: "p100", Value: 100}}, percentiles)
+
+	// Percentiles with the same digits have different names
+	tests := []struct {
+		values []float64
+		names  []string
+	}{
+		{[]float64{99.9, 9.99}, []string{"p99_9", "p9_99"}},
+		{[]float64{95, 9.5}, []string{"p95", "p9_5"}},
+		{[]float64{99.99, 9.999}, []string{"p99_99", "p9_999"}},
+		{[]float64{10, 1}, []string{"p10", "p1"}},
+		{[]float64{0.1, 1}, []string{"p0_1", "p1"}},
+	}
+
+	for _, tc := range tests {
+		percentiles, err = stats.ParsePercentiles(tc.values)
+		require.NoError(t, err, "expected %v to be valid", tc.values)
+		for i, name := range tc.names {
+			require.Equal(t, name, percentiles[i].Name)
+			require.Equal(t, tc.values[i], percentiles[i].Value)
+
+			// The name of a reported percentile can be used in a derived metric
+			d, err := stats.ParseDerived("ratio", name+"/p50")
+			require.NoError(t, err)
+			require.Equal(t, tc.values[i], d.Numerator)
+		}
+	}
+
+	for _, values := range [][]float64{nil, {0}, {101}, {50, 50}} {
+		_, err = stats.ParsePercentiles(values)
+		require.Error(t, err, "expected %v to be invalid", values)
+	}
+}
+
+func TestParseDerived(t *testing.T) {
+	d, err := stats.ParseDerived("tail", "p99_9/p50")
+	require.NoError(t, err)
+	require.Equal(t, stats.Derived{Name: "tail", Kind: stats.DerivedRatio, Numerator: 99.9, Denominator: 50}, d)
+
+	d, err = stats.ParseDerived("tail", "p99.9/p50")
+	require.NoError(t, err)
+	require.Equal(t, 99.9, d.Numerator)
+
+	d, err = stats.ParseDerived("apdex", "apdex:50ms")
+	require.NoError(t, err)
+	require.Equal(t, stats.Derived{Name: "apdex", Kind: stats.DerivedApdex, Threshold: 50 * time.Millisecond}, d)
+
+	for _, spec := range []string{"p99", "p99/", "q99/p50", "p0/p50", "p999/p50", "apdex:", "apdex:-1s", "apdex:fast"} {
+		_, err = stats.ParseDerived("metric", spec)
+		require.Error(t, err, "expected %q to be invalid", spec)
+	}
+
+	_, err = stats.ParseDerived("", "p99/p50")
+	require.Error(t, err, "a derived metric must have a name")
+}
+
+func TestDerivedMetrics(t *testing.T) {
+	ratio, err := stats.ParseDerived("tail_ratio", "p99/p50")
+	require.NoError(t, err)
+
+	apdex, err := stats.ParseDerived("apdex", "apdex:10ms")
+	require.NoError(t, err)
+
+	percentiles, err := stats.ParsePercentiles([]float64{50, 75})
+	require.NoError(t, err)
+
+	defaults := stats.ReportedPercentiles
+	stats.ReportedPercentiles, stats.DerivedMetrics = percentiles, []stats.Derived{ratio, apdex}
+	t.Cleanup(func() { stats.ReportedPercentiles, stats.DerivedMetrics = defaults, nil })
+
+	// 2 satisfied, 1 tolerated, 1 frustrated, and 1 timeout
+	latencies := &stats.Latencies{}
+	latencies.Update(5*time.Millisecond, 5*time.Millisecond, 30*time.Millisecond, 100*time.Millisecond, 0)
+
+	data, err := json.Marshal(latencies)
+	require.NoError(t, err)
+
+	summary := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &summary))
+	require.Contains(t, summary, "p75")
+	require.NotContains(t, summary, "p99", "only the configured percentiles should be reported")
+	require.InDelta(t, 0.5, summary["apdex"], 1e-9)
+	require.InDelta(t, 20.0, summary["tail_ratio"], 0.5)
+
+	// Derived metrics are omitted if they are undefined
+	data, err = json.Marshal(&stats.Latencies{})
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "apdex")
+	require.NotContains(t, string(data), "tail_ratio")
+}
@@ -0,0 +1,278 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistogramBuckets are the bucket bounds of the histograms exported by Latencies. If
+// nil, Latencies only report their summary statistics and percentiles. The buckets must
+// be set before any latencies are recorded since histograms with different bounds
+// cannot be merged.
+var HistogramBuckets []time.Duration
+
+// The label of the overflow bucket that counts the samples above the last bound.
+const overflowBucket = "+Inf"
+
+// LinearBuckets returns count bucket bounds starting at start that are width apart.
+func LinearBuckets(start, width time.Duration, count int) []time.Duration {
+	bounds := make([]time.Duration, count)
+	for i := range bounds {
+		bounds[i] = start + time.Duration(i)*width
+	}
+	return bounds
+}
+
+// ExponentialBuckets returns count bucket bounds starting at start where each bound is
+// factor times the previous bound.
+func ExponentialBuckets(start time.Duration, factor float64, count int) []time.Duration {
+	bounds := make([]time.Duration, count)
+	for i := range bounds {
+		bounds[i] = time.Duration(float64(start) * math.Pow(factor, float64(i)))
+	}
+	return bounds
+}
+
+// ParseBuckets parses histogram bucket bounds from a linear specification such as
+// "linear:5ms,5ms,20" (start, width, count), an exponential specification such as
+// "exp:100us,2,20" (start, factor, count), or a comma separated list of bounds such as
+// "1ms,10ms,100ms,1s".
+func ParseBuckets(s string) (bounds []time.Duration, err error) {
+	kind, spec, ok := strings.Cut(s, ":")
+	if !ok {
+		kind, spec = "", s
+	}
+
+	parts := strings.Split(spec, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	switch kind {
+	case "linear", "exp":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%s buckets must be specified with a start, step, and count", kind)
+		}
+
+		var start time.Duration
+		if start, err = time.ParseDuration(parts[0]); err != nil {
+			return nil, fmt.Errorf("could not parse bucket start %q", parts[0])
+		}
+
+		var count int
+		if count, err = strconv.Atoi(parts[2]); err != nil || count < 1 {
+			return nil, fmt.Errorf("could not parse bucket count %q", parts[2])
+		}
+
+		if kind == "linear" {
+			var width time.Duration
+			if width, err = time.ParseDuration(parts[1]); err != nil {
+				return nil, fmt.Errorf("could not parse bucket width %q", parts[1])
+			}
+			bounds = LinearBuckets(start, width, count)
+		} else {
+			var factor float64
+			if factor, err = strconv.ParseFloat(parts[1], 64); err != nil {
+				return nil, fmt.Errorf("could not parse bucket factor %q", parts[1])
+			}
+			bounds = ExponentialBuckets(start, factor, count)
+		}
+	case "":
+		for _, part := range parts {
+			var bound time.Duration
+			if bound, err = time.ParseDuration(part); err != nil {
+				return nil, fmt.Errorf("could not parse bucket bound %q", part)
+			}
+			bounds = append(bounds, bound)
+		}
+	default:
+		return nil, fmt.Errorf("unknown bucket specification %q, must be linear or exp", kind)
+	}
+
+	if err = validBuckets(bounds); err != nil {
+		return nil, err
+	}
+	return bounds, nil
+}
+
+func validBuckets(bounds []time.Duration) error {
+	if len(bounds) == 0 {
+		return errors.New("a histogram must have at least one bucket")
+	}
+
+	for i, bound := range bounds {
+		if bound <= 0 {
+			return errors.New("histogram bucket bounds must be positive")
+		}
+
+		if i > 0 && bound <= bounds[i-1] {
+			return errors.New("histogram bucket bounds must be strictly increasing")
+		}
+	}
+	return nil
+}
+
+// Histogram counts durations in buckets with explicit upper bounds so that the shape of
+// a distribution can be plotted by downstream tooling. Unlike Percentiles, whose buckets
+// are an implementation detail of the percentile estimates, the bounds are chosen by the
+// user and are reported as is. Each bucket counts the samples that are less than or
+// equal to its bound and greater than the previous bound; samples above the last bound
+// are counted in an overflow bucket.
+type Histogram struct {
+	sync.RWMutex
+	bounds []time.Duration
+	counts []uint64 // one more than the bounds for the overflow bucket
+	count  uint64
+}
+
+// NewHistogram creates a histogram with the bucket bounds, which must be positive and
+// strictly increasing.
+func NewHistogram(bounds []time.Duration) (_ *Histogram, err error) {
+	if err = validBuckets(bounds); err != nil {
+		return nil, err
+	}
+
+	return &Histogram{
+		bounds: append([]time.Duration(nil), bounds...),
+		counts: make([]uint64, len(bounds)+1),
+	}, nil
+}
+
+// Update the histogram with one or more durations; non-positive durations are ignored.
+func (h *Histogram) Update(durations ...time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	for _, d := range durations {
+		h.update(d)
+	}
+}
+
+func (h *Histogram) update(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	idx := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	h.counts[idx]++
+	h.count++
+}
+
+// N returns the number of samples in the histogram.
+func (h *Histogram) N() uint64 {
+	h.RLock()
+	defer h.RUnlock()
+	return h.count
+}
+
+// Bounds returns the upper bounds of the buckets, excluding the overflow bucket.
+func (h *Histogram) Bounds() []time.Duration {
+	h.RLock()
+	defer h.RUnlock()
+	return append([]time.Duration(nil), h.bounds...)
+}
+
+// Counts returns the number of samples in each bucket; the last count is the number of
+// samples in the overflow bucket.
+func (h *Histogram) Counts() []uint64 {
+	h.RLock()
+	defer h.RUnlock()
+	return append([]uint64(nil), h.counts...)
+}
+
+// Append the samples of another histogram to this histogram; the histograms must have
+// the same bucket bounds.
+func (h *Histogram) Append(o *Histogram) error {
+	if h == o {
+		return nil
+	}
+
+	o.RLock()
+	defer o.RUnlock()
+	h.Lock()
+	defer h.Unlock()
+
+	if len(h.bounds) != len(o.bounds) {
+		return errors.New("cannot append histograms with different buckets")
+	}
+
+	for i, bound := range h.bounds {
+		if o.bounds[i] != bound {
+			return errors.New("cannot append histograms with different buckets")
+		}
+	}
+
+	for i, count := range o.counts {
+		h.counts[i] += count
+	}
+	h.count += o.count
+	return nil
+}
+
+type histogramBucket struct {
+	Bound string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// MarshalJSON serializes the buckets in order with their upper bound as a duration
+// string, e.g. {"le": "10ms", "count": 42}; the last bucket is the overflow bucket.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	buckets := make([]histogramBucket, 0, len(h.counts))
+	for i, count := range h.counts {
+		bucket := histogramBucket{Bound: overflowBucket, Count: count}
+		if i < len(h.bounds) {
+			bucket.Bound = h.bounds[i].String()
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return json.Marshal(map[string]interface{}{"samples": h.count, "buckets": buckets})
+}
+
+// UnmarshalJSON restores the buckets of a serialized histogram.
+func (h *Histogram) UnmarshalJSON(data []byte) (err error) {
+	state := struct {
+		Buckets []histogramBucket `json:"buckets"`
+	}{}
+
+	if err = json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	if n := len(state.Buckets); n == 0 || state.Buckets[n-1].Bound != overflowBucket {
+		return errors.New("the histogram does not end with an overflow bucket")
+	}
+
+	bounds := make([]time.Duration, 0, len(state.Buckets)-1)
+	counts := make([]uint64, 0, len(state.Buckets))
+	total := uint64(0)
+	for i, bucket := range state.Buckets {
+		if i < len(state.Buckets)-1 {
+			var bound time.Duration
+			if bound, err = time.ParseDuration(bucket.Bound); err != nil {
+				return fmt.Errorf("could not parse bucket bound %q", bucket.Bound)
+			}
+			bounds = append(bounds, bound)
+		}
+		counts = append(counts, bucket.Count)
+		total += bucket.Count
+	}
+
+	if err = validBuckets(bounds); err != nil {
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	h.bounds, h.counts, h.count = bounds, counts, total
+	return nil
+}
@@ -0,0 +1,98 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	hist, err := stats.NewHistogram(stats.LinearBuckets(10*time.Millisecond, 10*time.Millisecond, 3))
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}, hist.Bounds())
+
+	// Bounds are inclusive and samples above the last bound overflow
+	hist.Update(time.Millisecond, 10*time.Millisecond, 11*time.Millisecond, 30*time.Millisecond, time.Second, 0, -1)
+	require.Equal(t, uint64(5), hist.N())
+	require.Equal(t, []uint64{2, 1, 1, 1}, hist.Counts())
+
+	data, err := json.Marshal(hist)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"samples": 5, "buckets": [{"le": "10ms", "count": 2}, {"le": "20ms", "count": 1}, {"le": "30ms", "count": 1}, {"le": "+Inf", "count": 1}]}`, string(data))
+
+	restored := &stats.Histogram{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	require.Equal(t, hist.Bounds(), restored.Bounds())
+	require.Equal(t, hist.Counts(), restored.Counts())
+
+	require.NoError(t, restored.Append(hist))
+	require.Equal(t, uint64(10), restored.N())
+	require.Equal(t, []uint64{4, 2, 2, 2}, restored.Counts())
+
+	other, err := stats.NewHistogram(stats.ExponentialBuckets(time.Millisecond, 10, 3))
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond}, other.Bounds())
+	require.Error(t, other.Append(hist))
+
+	_, err = stats.NewHistogram([]time.Duration{time.Second, time.Millisecond})
+	require.Error(t, err)
+}
+
+func TestParseBuckets(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected []time.Duration
+		err      bool
+	}{
+		{"linear:5ms,5ms,3", []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 15 * time.Millisecond}, false},
+		{"exp:100us, 2, 3", []time.Duration{100 * time.Microsecond, 200 * time.Microsecond, 400 * time.Microsecond}, false},
+		{"1ms,10ms,1s", []time.Duration{time.Millisecond, 10 * time.Millisecond, time.Second}, false},
+		{"linear:5ms,5ms", nil, true},
+		{"linear:0s,5ms,3", nil, true},
+		{"exp:1ms,1,3", nil, true},
+		{"exp:1ms,2,0", nil, true},
+		{"10ms,1ms", nil, true},
+		{"log:1ms,2,3", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tc := range testCases {
+		bounds, err := stats.ParseBuckets(tc.in)
+		if tc.err {
+			require.Error(t, err, "expected %q to be invalid", tc.in)
+			continue
+		}
+		require.NoError(t, err, "could not parse %q", tc.in)
+		require.Equal(t, tc.expected, bounds)
+	}
+}
+
+func TestLatenciesHistogram(t *testing.T) {
+	latencies := &stats.Latencies{}
+	latencies.Update(time.Millisecond)
+	require.Nil(t, latencies.Buckets(), "histograms should only be exported if buckets are configured")
+
+	stats.HistogramBuckets = []time.Duration{time.Millisecond, 10 * time.Millisecond}
+	t.Cleanup(func() { stats.HistogramBuckets = nil })
+
+	latencies = &stats.Latencies{}
+	latencies.Update(time.Millisecond, 5*time.Millisecond, time.Second, 0)
+	require.Equal(t, []uint64{1, 1, 1}, latencies.Buckets().Counts())
+
+	other := &stats.Latencies{}
+	other.Update(2 * time.Millisecond)
+	latencies.Append(other)
+	require.Equal(t, []uint64{1, 2, 1}, latencies.Buckets().Counts())
+
+	data, err := json.Marshal(latencies)
+	require.NoError(t, err)
+
+	exported := struct {
+		Histogram *stats.Histogram `json:"histogram"`
+	}{}
+	require.NoError(t, json.Unmarshal(data, &exported))
+	require.Equal(t, uint64(4), exported.Histogram.N())
+}
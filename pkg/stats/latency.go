@@ -20,8 +20,12 @@ import (
 type Latencies struct {
 	sync.RWMutex
 	Statistics
-	timeouts uint64        // the number of 0 durations (null durations) or timeouts
-	duration time.Duration // externally set duration of the benchmark
+	percentiles Percentiles   // histogram used to estimate the tail of the distribution
+	histogram   *Histogram    // exported histogram if HistogramBuckets are configured
+	timeouts    uint64        // the number of 0 durations (null durations) or timeouts
+	warmup      uint64        // the number of samples excluded as warmup
+	cooldown    uint64        // the number of samples excluded as cooldown
+	duration    time.Duration // externally set duration of the benchmark
 }
 
 // Update the latencies with a duration or durations (thread-safe). If a
@@ -40,9 +44,86 @@ func (s *Latencies) Update(durations ...time.Duration) {
 		}
 
 		s.Statistics.Update(duration.Seconds())
+		s.percentiles.Update(duration)
+		s.histogramUpdate(duration)
 	}
 }
 
+// Records the duration in the exported histogram, which is created with the configured
+// HistogramBuckets when the first sample is recorded. Must be called with the lock.
+func (s *Latencies) histogramUpdate(duration time.Duration) {
+	if s.histogram == nil {
+		if HistogramBuckets == nil {
+			return
+		}
+
+		// Invalid buckets are rejected when they are configured so this should never happen
+		var err error
+		if s.histogram, err = NewHistogram(HistogramBuckets); err != nil {
+			return
+		}
+	}
+	s.histogram.Update(duration)
+}
+
+// Warmup records samples taken while the system under test was warming up (thread-safe).
+// The samples are counted but excluded from the summary statistics and percentiles so
+// that ramp-up effects do not skew the steady state measurements.
+func (s *Latencies) Warmup(durations ...time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.warmup += uint64(len(durations))
+}
+
+// Cooldown records samples taken while the run was draining (thread-safe). Like warmup
+// samples, they are counted but excluded from the summary statistics and percentiles.
+func (s *Latencies) Cooldown(durations ...time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.cooldown += uint64(len(durations))
+}
+
+// UpdateTrimmed updates the latencies with the operations that were started at the
+// offsets from the start of a run of the specified duration; offsets and latencies must
+// be the same length. Operations started within the warmup of the start of the run or
+// within the cooldown of the end of the run are excluded from the summary statistics.
+// Timeouts are always recorded as timeouts since failures are never a warmup effect.
+func (s *Latencies) UpdateTrimmed(warmup, cooldown, duration time.Duration, offsets, latencies []time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, latency := range latencies {
+		switch {
+		case latency == 0:
+			s.timeouts++
+		case offsets[i] < warmup:
+			s.warmup++
+		case cooldown > 0 && offsets[i] >= duration-cooldown:
+			s.cooldown++
+		default:
+			s.Statistics.Update(latency.Seconds())
+			s.percentiles.Update(latency)
+			s.histogramUpdate(latency)
+		}
+	}
+}
+
+// Excluded returns the number of warmup and cooldown samples that are not included in
+// the summary statistics.
+func (s *Latencies) Excluded() (warmup, cooldown uint64) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.warmup, s.cooldown
+}
+
+// Count returns the number of samples recorded including timeouts and the warmup and
+// cooldown samples that are excluded from the summary statistics.
+func (s *Latencies) Count() uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.N() + s.timeouts + s.warmup + s.cooldown
+}
+
 // SetDuration allows an external setting of the duration. This is especially
 // useful in the case where multiple threads are updating the latencies and
 // the internal measurement of total time might double count concurrent
@@ -54,6 +135,13 @@ func (s *Latencies) SetDuration(duration time.Duration) {
 	s.duration = duration
 }
 
+// Duration returns the externally set duration of the benchmark.
+func (s *Latencies) Duration() time.Duration {
+	s.RLock()
+	defer s.RUnlock()
+	return s.duration
+}
+
 // Throughput returns the number of samples per second, measured as the
 // inverse mean: number of samples divided by the total duration in seconds.
 // The duration is computed in two ways:
@@ -148,6 +236,34 @@ func (s *Latencies) stddev() time.Duration {
 	return s.castSeconds(s.Statistics.StdDev())
 }
 
+// Percentile returns the estimated duration below which the specified percent (0-100)
+// of durations fall, e.g. 99 for the p99 latency. Timeouts are not included.
+func (s *Latencies) Percentile(percent float64) time.Duration {
+	return s.percentiles.Percentile(percent)
+}
+
+// Histogram returns a copy of the histogram used to estimate the percentiles so that it
+// can be merged with the histograms of other workers.
+func (s *Latencies) Histogram() *Percentiles {
+	hist := &Percentiles{}
+	hist.Append(&s.percentiles)
+	return hist
+}
+
+// Buckets returns a copy of the exported histogram of the latencies, or nil if no
+// HistogramBuckets were configured when the latencies were recorded.
+func (s *Latencies) Buckets() *Histogram {
+	s.RLock()
+	defer s.RUnlock()
+	if s.histogram == nil {
+		return nil
+	}
+
+	hist, _ := NewHistogram(s.histogram.Bounds())
+	hist.Append(s.histogram)
+	return hist
+}
+
 // Slowest returns the maximum value of durations seen. If no durations have
 // been added to the dataset, then this function returns a zero duration.
 func (s *Latencies) Slowest() time.Duration {
@@ -205,6 +321,26 @@ func (s *Latencies) MarshalJSON() ([]byte, error) {
 	data["throughput"] = s.throughput()
 	data["duration"] = s.duration.String()
 	data["timeouts"] = s.timeouts
+
+	// Excluded samples are only reported if the run was trimmed
+	if s.warmup > 0 || s.cooldown > 0 {
+		data["warmup"] = s.warmup
+		data["cooldown"] = s.cooldown
+	}
+
+	for _, p := range ReportedPercentiles {
+		data[p.Name] = s.percentiles.Percentile(p.Value).String()
+	}
+
+	for _, d := range DerivedMetrics {
+		if val, ok := d.compute(s); ok {
+			data[d.Name] = val
+		}
+	}
+
+	if s.histogram != nil {
+		data["histogram"] = s.histogram
+	}
 	return json.Marshal(data)
 }
 
@@ -212,7 +348,18 @@ func (s *Latencies) MarshalJSON() ([]byte, error) {
 // incrementing the distribution from the other object.
 func (s *Latencies) Append(o *Latencies) {
 	s.Statistics.Append(&o.Statistics)
+	s.percentiles.Append(&o.percentiles)
 	s.timeouts += o.timeouts
+
+	// Histograms with different buckets cannot be merged, the other histogram is dropped
+	if o.histogram != nil {
+		if s.histogram == nil {
+			s.histogram, _ = NewHistogram(o.histogram.Bounds())
+		}
+		s.histogram.Append(o.histogram)
+	}
+	s.warmup += o.warmup
+	s.cooldown += o.cooldown
 }
 
 // Internal Helper Method to cast float64 seconds into a duration
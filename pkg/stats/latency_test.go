@@ -53,6 +53,11 @@ func ExampleLatencies() {
 	//   "duration": "0s",
 	//   "fastest": "41.219436ms",
 	//   "mean": "120.993689ms",
+	//   "p50": "120.911613ms",
+	//   "p90": "141.891849ms",
+	//   "p95": "150.666252ms",
+	//   "p99": "159.983253ms",
+	//   "p99_9": "173.308251ms",
 	//   "range": "167.175236ms",
 	//   "samples": 1000000,
 	//   "slowest": "208.394672ms",
@@ -278,3 +283,61 @@ func BenchmarkLatencies_BulkLoad(b *testing.B) {
 		stats.Update(data...)
 	}
 }
+
+func TestLatenciesTrimmed(t *testing.T) {
+	// One operation is started every 100ms over a one second run
+	offsets := make([]time.Duration, 0, 10)
+	latencies := make([]time.Duration, 0, 10)
+	for i := 0; i < 10; i++ {
+		offsets = append(offsets, time.Duration(i)*100*time.Millisecond)
+		latencies = append(latencies, time.Duration(i+1)*time.Millisecond)
+	}
+
+	// The operation at 100ms times out, which is recorded even though it is warmup
+	latencies[1] = 0
+
+	s := &stats.Latencies{}
+	s.UpdateTrimmed(200*time.Millisecond, 300*time.Millisecond, time.Second, offsets, latencies)
+
+	warmup, cooldown := s.Excluded()
+	require.Equal(t, uint64(1), warmup)
+	require.Equal(t, uint64(3), cooldown)
+	require.Equal(t, uint64(1), s.Timeouts())
+	require.Equal(t, uint64(5), s.N())
+	require.Equal(t, uint64(10), s.Count())
+	require.Equal(t, 3*time.Millisecond, s.Fastest())
+	require.Equal(t, 7*time.Millisecond, s.Slowest())
+
+	// Samples can also be excluded directly and are merged by append
+	o := &stats.Latencies{}
+	o.Warmup(time.Millisecond, time.Millisecond)
+	o.Cooldown(time.Millisecond)
+	s.Append(o)
+
+	warmup, cooldown = s.Excluded()
+	require.Equal(t, uint64(3), warmup)
+	require.Equal(t, uint64(4), cooldown)
+	require.Equal(t, uint64(13), s.Count())
+	require.Equal(t, uint64(5), s.N())
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	report := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Equal(t, float64(3), report["warmup"])
+	require.Equal(t, float64(4), report["cooldown"])
+
+	state := s.State().Latencies()
+	warmup, cooldown = state.Excluded()
+	require.Equal(t, uint64(3), warmup)
+	require.Equal(t, uint64(4), cooldown)
+
+	// Untrimmed latencies do not report excluded samples
+	data, err = json.Marshal(o)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "warmup")
+
+	data, err = json.Marshal(&stats.Latencies{})
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "warmup")
+}
@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Normalized expresses latency and throughput relative to the payload size of the
+// events so that runs with different payload sizes can be compared at a glance. The
+// latency per KB is the mean latency divided by the payload size in kilobytes (1024
+// bytes) and throughput is reported both in events per second and MB per second.
+type Normalized struct {
+	PayloadSize  int64         // the size of the payload of each event in bytes
+	LatencyPerKB time.Duration // the mean latency divided by the payload size in KB
+	EventsPerSec float64       // the number of events per second
+	MBPerSec     float64       // the megabytes (1e6 bytes) of payload per second
+}
+
+// Normalize the latencies by the specified payload size in bytes. If the payload size
+// is zero or less then the latency per KB is not computed.
+func Normalize(latencies *Latencies, payloadSize int64) *Normalized {
+	n := &Normalized{
+		PayloadSize:  payloadSize,
+		EventsPerSec: latencies.Throughput(),
+	}
+
+	if payloadSize > 0 {
+		kb := float64(payloadSize) / 1024
+		n.LatencyPerKB = time.Duration(float64(latencies.Mean()) / kb)
+		n.MBPerSec = n.EventsPerSec * float64(payloadSize) / 1e6
+	}
+	return n
+}
+
+// Serializes the normalized metrics into a JSON map with durations as strings.
+func (n *Normalized) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["payload_size"] = n.PayloadSize
+	data["latency_per_kb"] = n.LatencyPerKB.String()
+	data["events_per_sec"] = n.EventsPerSec
+	data["mb_per_sec"] = n.MBPerSec
+	return json.Marshal(data)
+}
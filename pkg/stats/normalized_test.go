@@ -0,0 +1,31 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	latencies := &stats.Latencies{}
+	for i := 0; i < 100; i++ {
+		latencies.Update(4 * time.Millisecond)
+	}
+	latencies.SetDuration(time.Second)
+
+	norm := stats.Normalize(latencies, 8192)
+	require.Equal(t, 500*time.Microsecond, norm.LatencyPerKB)
+	require.Equal(t, 100.0, norm.EventsPerSec)
+	require.InDelta(t, 0.8192, norm.MBPerSec, 1e-9)
+
+	data, err := json.Marshal(norm)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"payload_size": 8192, "latency_per_kb": "500µs", "events_per_sec": 100, "mb_per_sec": 0.8192}`, string(data))
+
+	empty := stats.Normalize(latencies, 0)
+	require.Equal(t, time.Duration(0), empty.LatencyPerKB)
+	require.Equal(t, 0.0, empty.MBPerSec)
+}
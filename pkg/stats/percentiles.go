@@ -0,0 +1,260 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PercentileAccuracy is the maximum relative error of the percentiles estimated by the
+// Percentiles histogram, e.g. a reported p99 of 100ms is within 1ms of the true p99.
+const PercentileAccuracy = 0.01
+
+// ReportedPercentiles are included in the JSON output of Latencies; they can be replaced
+// by the experiment config, e.g. to standardize reports on a different set of percentiles.
+var ReportedPercentiles = []ReportedPercentile{
+	{"p50", 50},
+	{"p90", 90},
+	{"p95", 95},
+	{"p99", 99},
+	{"p99_9", 99.9},
+}
+
+// ReportedPercentile is a percentile (0-100] reported under its name, e.g. p99_9 for 99.9.
+type ReportedPercentile struct {
+	Name  string
+	Value float64
+}
+
+// ParsePercentiles returns the reported percentiles for the values, naming each by its
+// value with an underscore in place of the decimal point so that names are unique, e.g.
+// p50 for 50, p99_99 for 99.99, and p9_999 for 9.999.
+func ParsePercentiles(values []float64) (_ []ReportedPercentile, err error) {
+	if len(values) == 0 {
+		return nil, errors.New("at least one percentile must be reported")
+	}
+
+	percentiles := make([]ReportedPercentile, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, val := range values {
+		if val <= 0 || val > 100 {
+			return nil, fmt.Errorf("percentile %v must be greater than 0 and at most 100", val)
+		}
+
+		name := "p" + strings.Replace(strconv.FormatFloat(val, 'f', -1, 64), ".", "_", 1)
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("percentile %v is reported more than once", val)
+		}
+		seen[name] = struct{}{}
+		percentiles = append(percentiles, ReportedPercentile{Name: name, Value: val})
+	}
+	return percentiles, nil
+}
+
+var (
+	gamma    = (1 + PercentileAccuracy) / (1 - PercentileAccuracy)
+	logGamma = math.Log(gamma)
+)
+
+// Percentiles is a log-bucketed histogram of durations in the style of HDR histograms
+// that estimates arbitrary percentiles in bounded memory. Each sample is counted in a
+// bucket whose bounds grow geometrically so that every estimate is within the relative
+// PercentileAccuracy of the true value regardless of the range of the distribution.
+// Because buckets are fixed, histograms from concurrent or distributed workers can be
+// merged exactly without losing accuracy. The zero value is ready to use.
+type Percentiles struct {
+	sync.RWMutex
+	buckets map[int]uint64
+	count   uint64
+}
+
+// Update the histogram with one or more durations; non-positive durations are ignored.
+func (p *Percentiles) Update(durations ...time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+	for _, d := range durations {
+		p.update(d)
+	}
+}
+
+func (p *Percentiles) update(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	if p.buckets == nil {
+		p.buckets = make(map[int]uint64)
+	}
+	p.buckets[bucket(d)]++
+	p.count++
+}
+
+// N returns the number of samples in the histogram.
+func (p *Percentiles) N() uint64 {
+	p.RLock()
+	defer p.RUnlock()
+	return p.count
+}
+
+// Percentile returns the estimated duration below which the specified percent (0-100)
+// of samples fall. Zero is returned if there are no samples.
+func (p *Percentiles) Percentile(percent float64) time.Duration {
+	p.RLock()
+	defer p.RUnlock()
+	return p.percentile(percent)
+}
+
+func (p *Percentiles) percentile(percent float64) time.Duration {
+	if p.count == 0 {
+		return 0
+	}
+
+	switch {
+	case percent < 0:
+		percent = 0
+	case percent > 100:
+		percent = 100
+	}
+
+	indices := make([]int, 0, len(p.buckets))
+	for idx := range p.buckets {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	// The rank of the sample at the percentile, using the nearest-rank method
+	rank := uint64(math.Ceil(percent / 100 * float64(p.count)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	seen := uint64(0)
+	for _, idx := range indices {
+		seen += p.buckets[idx]
+		if seen >= rank {
+			return value(idx)
+		}
+	}
+	return value(indices[len(indices)-1])
+}
+
+// Below returns the estimated number of samples less than or equal to the duration;
+// samples in the same bucket as the duration are counted as below it.
+func (p *Percentiles) Below(d time.Duration) uint64 {
+	p.RLock()
+	defer p.RUnlock()
+
+	if d <= 0 {
+		return 0
+	}
+
+	limit, below := bucket(d), uint64(0)
+	for idx, count := range p.buckets {
+		if idx <= limit {
+			below += count
+		}
+	}
+	return below
+}
+
+// Append the samples of another histogram to this histogram.
+func (p *Percentiles) Append(o *Percentiles) {
+	if p == o {
+		return
+	}
+
+	o.RLock()
+	defer o.RUnlock()
+	p.Lock()
+	defer p.Unlock()
+
+	if p.buckets == nil {
+		p.buckets = make(map[int]uint64, len(o.buckets))
+	}
+
+	for idx, count := range o.buckets {
+		p.buckets[idx] += count
+	}
+	p.count += o.count
+}
+
+// Sub returns a histogram of the samples in this histogram that are not in the other
+// histogram, e.g. the samples recorded during an interval from snapshots of a growing
+// histogram taken at the start and end of the interval. The other histogram must be a
+// subset of this histogram; buckets with fewer samples than the other are emptied.
+func (p *Percentiles) Sub(o *Percentiles) *Percentiles {
+	delta := &Percentiles{}
+	if p == o {
+		return delta
+	}
+
+	delta.Append(p)
+	if o == nil {
+		return delta
+	}
+
+	o.RLock()
+	defer o.RUnlock()
+
+	for idx, count := range o.buckets {
+		remaining := delta.buckets[idx]
+		if count > remaining {
+			count = remaining
+		}
+
+		if remaining -= count; remaining == 0 {
+			delete(delta.buckets, idx)
+		} else {
+			delta.buckets[idx] = remaining
+		}
+		delta.count -= count
+	}
+	return delta
+}
+
+// MarshalJSON serializes the buckets of the histogram so that histograms recorded by
+// different processes can be sent to each other and merged exactly with Append.
+func (p *Percentiles) MarshalJSON() ([]byte, error) {
+	p.RLock()
+	defer p.RUnlock()
+	return json.Marshal(map[string]interface{}{"buckets": p.buckets})
+}
+
+// UnmarshalJSON restores the buckets of a serialized histogram.
+func (p *Percentiles) UnmarshalJSON(data []byte) (err error) {
+	state := struct {
+		Buckets map[int]uint64 `json:"buckets"`
+	}{}
+
+	if err = json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.buckets, p.count = make(map[int]uint64, len(state.Buckets)), 0
+	for idx, count := range state.Buckets {
+		if count > 0 {
+			p.buckets[idx] = count
+			p.count += count
+		}
+	}
+	return nil
+}
+
+// Returns the index of the bucket whose range (gamma^(i-1), gamma^i] contains d.
+func bucket(d time.Duration) int {
+	return int(math.Ceil(math.Log(float64(d)) / logGamma))
+}
+
+// Returns the representative value of the bucket, which is within the relative accuracy
+// of every value in the bucket.
+func value(idx int) time.Duration {
+	return time.Duration(2 * math.Pow(gamma, float64(idx)) / (gamma + 1))
+}
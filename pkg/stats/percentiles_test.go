@@ -0,0 +1,137 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentiles(t *testing.T) {
+	data, err := loadLatenciesData()
+	require.NoError(t, err, "could not load test fixture data")
+
+	hist := &stats.Percentiles{}
+	hist.Update(data...)
+	require.Equal(t, uint64(len(data)), hist.N())
+
+	sorted := make([]time.Duration, len(data))
+	copy(sorted, data)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, p := range []float64{1, 25, 50, 75, 90, 95, 99, 99.9, 100} {
+		rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		exact := sorted[rank]
+		require.InEpsilon(t, float64(exact), float64(hist.Percentile(p)), stats.PercentileAccuracy, "p%v not within accuracy", p)
+	}
+}
+
+func TestPercentilesEmpty(t *testing.T) {
+	hist := &stats.Percentiles{}
+	require.Equal(t, time.Duration(0), hist.Percentile(99))
+
+	hist.Update(0, -1)
+	require.Equal(t, uint64(0), hist.N())
+}
+
+func TestPercentilesAppend(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	all, a, b := &stats.Percentiles{}, &stats.Percentiles{}, &stats.Percentiles{}
+
+	for i := 0; i < 10000; i++ {
+		val := time.Duration(random.ExpFloat64() * float64(10*time.Millisecond))
+		all.Update(val)
+		if i%3 == 0 {
+			a.Update(val)
+		} else {
+			b.Update(val)
+		}
+	}
+
+	a.Append(b)
+	require.Equal(t, all.N(), a.N())
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		require.Equal(t, all.Percentile(p), a.Percentile(p))
+	}
+}
+
+func TestPercentilesSub(t *testing.T) {
+	first, all := &stats.Percentiles{}, &stats.Percentiles{}
+	for i := 1; i <= 1000; i++ {
+		all.Update(time.Duration(i) * time.Millisecond)
+		if i <= 400 {
+			first.Update(time.Duration(i) * time.Millisecond)
+		}
+	}
+
+	// The difference of two snapshots of a growing histogram is the interval between them
+	interval := &stats.Percentiles{}
+	for i := 401; i <= 1000; i++ {
+		interval.Update(time.Duration(i) * time.Millisecond)
+	}
+
+	delta := all.Sub(first)
+	require.Equal(t, uint64(600), delta.N())
+	require.Equal(t, uint64(1000), all.N(), "sub must not modify the histogram")
+	for _, p := range []float64{0, 50, 99, 100} {
+		require.Equal(t, interval.Percentile(p), delta.Percentile(p))
+	}
+
+	require.Equal(t, uint64(0), all.Sub(all).N())
+	require.Equal(t, all.N(), all.Sub(nil).N())
+}
+
+func TestPercentilesJSON(t *testing.T) {
+	// Workers with very different distributions: the worst worker p99 is a poor estimate
+	// of the combined p99 whereas the merged histograms are within the accuracy.
+	random := rand.New(rand.NewSource(7))
+	all, fast, slow := &stats.Percentiles{}, &stats.Percentiles{}, &stats.Percentiles{}
+	for i := 0; i < 9000; i++ {
+		val := time.Duration(random.ExpFloat64() * float64(time.Millisecond))
+		all.Update(val)
+		fast.Update(val)
+	}
+	for i := 0; i < 1000; i++ {
+		val := time.Duration(random.ExpFloat64() * float64(50*time.Millisecond))
+		all.Update(val)
+		slow.Update(val)
+	}
+
+	merged := &stats.Percentiles{}
+	for _, worker := range []*stats.Percentiles{fast, slow} {
+		data, err := json.Marshal(worker)
+		require.NoError(t, err)
+
+		restored := &stats.Percentiles{}
+		require.NoError(t, json.Unmarshal(data, restored))
+		require.Equal(t, worker.N(), restored.N())
+		merged.Append(restored)
+	}
+
+	require.Equal(t, all.N(), merged.N())
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		require.Equal(t, all.Percentile(p), merged.Percentile(p))
+	}
+	require.Greater(t, slow.Percentile(99), 2*merged.Percentile(99), "expected the worst worker p99 to overestimate")
+}
+
+func TestLatenciesPercentiles(t *testing.T) {
+	latencies := &stats.Latencies{}
+	for i := 1; i <= 1000; i++ {
+		latencies.Update(time.Duration(i) * time.Millisecond)
+	}
+	latencies.Update(0)
+
+	require.InEpsilon(t, float64(500*time.Millisecond), float64(latencies.Percentile(50)), stats.PercentileAccuracy)
+	require.InEpsilon(t, float64(990*time.Millisecond), float64(latencies.Percentile(99)), stats.PercentileAccuracy)
+
+	other := &stats.Latencies{}
+	other.Update(5 * time.Second)
+	latencies.Append(other)
+	require.InEpsilon(t, float64(5*time.Second), float64(latencies.Percentile(100)), stats.PercentileAccuracy)
+}
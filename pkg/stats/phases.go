@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPhases partitions a run into a ramp-up, steady state, and drain phase.
+var DefaultPhases = []float64{0.1, 0.8, 0.1}
+
+// Phase summarizes the latencies of the operations that were started during a portion
+// of a run, expressed as fractions of the run's duration, so that ramp-up and drain
+// effects can be distinguished from the steady state of the run.
+type Phase struct {
+	Name      string     // start, middle, and end for three phases, otherwise phase_N
+	Start     float64    // the fraction of the run at which the phase begins
+	End       float64    // the fraction of the run at which the phase ends
+	Latencies *Latencies // the latencies of the operations started during the phase
+}
+
+// ParsePhases parses a comma separated list of relative phase widths such as "10,80,10"
+// or "0.25,0.5,0.25". The widths are normalized so that the phases span the whole run.
+func ParsePhases(s string) (fractions []float64, err error) {
+	var total float64
+	for _, part := range strings.Split(s, ",") {
+		var width float64
+		if width, err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return nil, fmt.Errorf("could not parse phase width %q", part)
+		}
+
+		if width <= 0 {
+			return nil, errors.New("phase widths must be positive")
+		}
+
+		fractions = append(fractions, width)
+		total += width
+	}
+
+	for i := range fractions {
+		fractions[i] /= total
+	}
+	return fractions, nil
+}
+
+// SplitPhases partitions the latencies into phases by the offset from the start of the
+// run at which each operation was started; offsets and latencies must be the same
+// length. Operations started outside of the run are assigned to the first or last
+// phase. If no fractions are specified, the DefaultPhases are used.
+func SplitPhases(fractions []float64, duration time.Duration, offsets, latencies []time.Duration) []*Phase {
+	if len(fractions) == 0 {
+		fractions = DefaultPhases
+	}
+
+	phases := make([]*Phase, len(fractions))
+	start := 0.0
+	for i, fraction := range fractions {
+		phases[i] = &Phase{
+			Name:      phaseName(i, len(fractions)),
+			Start:     start,
+			End:       start + fraction,
+			Latencies: &Latencies{},
+		}
+		start += fraction
+	}
+
+	for i, latency := range latencies {
+		idx := len(phases) - 1
+		if duration > 0 {
+			at := float64(offsets[i]) / float64(duration)
+			for j, phase := range phases {
+				if at < phase.End {
+					idx = j
+					break
+				}
+			}
+		}
+		phases[idx].Latencies.Update(latency)
+	}
+
+	// The duration of each phase is used to compute the phase's throughput
+	for _, phase := range phases {
+		phase.Latencies.SetDuration(time.Duration(float64(duration) * (phase.End - phase.Start)))
+	}
+	return phases
+}
+
+func phaseName(i, n int) string {
+	if n == 3 {
+		return [3]string{"start", "middle", "end"}[i]
+	}
+	return fmt.Sprintf("phase_%d", i)
+}
+
+// Serializes the phase into a JSON map with the latencies of the phase.
+func (p *Phase) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["name"] = p.Name
+	data["start"] = p.Start
+	data["end"] = p.End
+	data["latencies"] = p.Latencies
+	return json.Marshal(data)
+}
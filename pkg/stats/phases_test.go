@@ -0,0 +1,67 @@
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePhases(t *testing.T) {
+	fractions, err := stats.ParsePhases("10,80,10")
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{0.1, 0.8, 0.1}, fractions, 1e-9)
+
+	fractions, err = stats.ParsePhases("1, 1")
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{0.5, 0.5}, fractions, 1e-9)
+
+	_, err = stats.ParsePhases("10,zero,10")
+	require.Error(t, err)
+
+	_, err = stats.ParsePhases("10,0,10")
+	require.Error(t, err)
+}
+
+func TestSplitPhases(t *testing.T) {
+	// Operations are started every 10ms over a one second run; operations in the
+	// first and last 100ms are slower than those in the steady state.
+	offsets := make([]time.Duration, 0, 100)
+	latencies := make([]time.Duration, 0, 100)
+	for i := 0; i < 100; i++ {
+		offset := time.Duration(i) * 10 * time.Millisecond
+		latency := time.Millisecond
+		if offset < 100*time.Millisecond || offset >= 900*time.Millisecond {
+			latency = 5 * time.Millisecond
+		}
+		offsets = append(offsets, offset)
+		latencies = append(latencies, latency)
+	}
+
+	// Operations started after the end of the run are assigned to the last phase
+	offsets = append(offsets, 2*time.Second)
+	latencies = append(latencies, 5*time.Millisecond)
+
+	phases := stats.SplitPhases(nil, time.Second, offsets, latencies)
+	require.Len(t, phases, 3)
+
+	require.Equal(t, "start", phases[0].Name)
+	require.Equal(t, uint64(10), phases[0].Latencies.N())
+	require.InDelta(t, 5*time.Millisecond, phases[0].Latencies.Mean(), float64(time.Microsecond))
+	require.Equal(t, 100*time.Millisecond, phases[0].Latencies.Duration())
+
+	require.Equal(t, "middle", phases[1].Name)
+	require.Equal(t, uint64(80), phases[1].Latencies.N())
+	require.InDelta(t, time.Millisecond, phases[1].Latencies.Mean(), float64(time.Microsecond))
+
+	require.Equal(t, "end", phases[2].Name)
+	require.Equal(t, uint64(11), phases[2].Latencies.N())
+	require.InDelta(t, 5*time.Millisecond, phases[2].Latencies.Mean(), float64(time.Microsecond))
+
+	phases = stats.SplitPhases([]float64{0.5, 0.5}, time.Second, offsets, latencies)
+	require.Len(t, phases, 2)
+	require.Equal(t, "phase_0", phases[0].Name)
+	require.Equal(t, uint64(50), phases[0].Latencies.N())
+	require.Equal(t, uint64(51), phases[1].Latencies.N())
+}
@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
+)
+
+// Progress tracks named counters while a benchmark is running so that interim
+// statistics can be reported without stopping the run. Unlike the final results, the
+// counters are safe to read concurrently while the benchmark is updating them. The
+// zero value is ready to use.
+type Progress struct {
+	sync.Mutex
+	started  time.Time
+	counters map[string]uint64
+	clock    clock.Clock
+}
+
+// Start resets the counters and the elapsed time of the benchmark.
+func (p *Progress) Start() {
+	p.Lock()
+	defer p.Unlock()
+	p.started = p.source().Now()
+	p.counters = make(map[string]uint64)
+}
+
+// SetClock sets the clock that times the benchmark, by default the host clock.
+func (p *Progress) SetClock(c clock.Clock) {
+	p.Lock()
+	defer p.Unlock()
+	p.clock = c
+}
+
+// Add n to the named counter.
+func (p *Progress) Add(name string, n uint64) {
+	p.Lock()
+	defer p.Unlock()
+	if p.counters == nil {
+		p.counters = make(map[string]uint64)
+	}
+	p.counters[name] += n
+}
+
+// Set the named counter to the specified value, e.g. for gauges such as queue depth.
+func (p *Progress) Set(name string, n uint64) {
+	p.Lock()
+	defer p.Unlock()
+	if p.counters == nil {
+		p.counters = make(map[string]uint64)
+	}
+	p.counters[name] = n
+}
+
+// Get the current value of the named counter.
+func (p *Progress) Get(name string) uint64 {
+	p.Lock()
+	defer p.Unlock()
+	return p.counters[name]
+}
+
+// Snapshot returns the current value of every counter along with the time elapsed
+// since the benchmark was started and the average rate per second of each counter.
+func (p *Progress) Snapshot() map[string]interface{} {
+	p.Lock()
+	defer p.Unlock()
+
+	snap := make(map[string]interface{}, len(p.counters)+2)
+	if p.started.IsZero() {
+		snap["elapsed"] = time.Duration(0).String()
+	} else {
+		elapsed := p.source().Since(p.started)
+		snap["elapsed"] = elapsed.String()
+
+		rates := make(map[string]float64, len(p.counters))
+		if secs := elapsed.Seconds(); secs > 0 {
+			for name, val := range p.counters {
+				rates[name] = float64(val) / secs
+			}
+		}
+		snap["rates"] = rates
+	}
+
+	for name, val := range p.counters {
+		snap[name] = val
+	}
+	return snap
+}
+
+// Returns the clock of the progress; must be called with the lock held.
+func (p *Progress) source() clock.Clock {
+	if p.clock == nil {
+		return clock.Real
+	}
+	return p.clock
+}
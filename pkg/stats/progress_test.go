@@ -0,0 +1,56 @@
+package stats_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	progress := &stats.Progress{}
+	snap := progress.Snapshot()
+	require.Equal(t, "0s", snap["elapsed"])
+	require.NotContains(t, snap, "rates")
+
+	progress.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				progress.Add("sent", 1)
+				progress.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	progress.Set("inflight", 12)
+	progress.Set("inflight", 7)
+
+	require.Equal(t, uint64(400), progress.Get("sent"))
+	require.Equal(t, uint64(7), progress.Get("inflight"))
+
+	snap = progress.Snapshot()
+	require.Equal(t, uint64(400), snap["sent"])
+	require.Contains(t, snap["rates"], "sent")
+}
+
+func TestProgressClock(t *testing.T) {
+	fake := clock.NewFake(time.Now(), 0)
+	progress := &stats.Progress{}
+	progress.SetClock(fake)
+	progress.Start()
+	progress.Add("sent", 250)
+
+	fake.Advance(2 * time.Second)
+	snap := progress.Snapshot()
+	require.Equal(t, "2s", snap["elapsed"])
+	require.Equal(t, map[string]float64{"sent": 125}, snap["rates"])
+}
@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+)
+
+// QueueDepth is the distribution of the depth of a bounded queue, sampled whenever an
+// item is enqueued, e.g. to show whether the consumers of the queue keep up with its
+// producer. Since the queue is bounded the number of samples at every depth is counted
+// so that the percentiles are exact. Samples at the capacity of the queue mean that the
+// producer was blocked. This object is thread-safe.
+type QueueDepth struct {
+	sync.RWMutex
+	counts  []uint64 // the number of samples at each depth from empty to full
+	samples uint64
+	total   uint64
+	maximum int
+}
+
+func NewQueueDepth(capacity int) *QueueDepth {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &QueueDepth{counts: make([]uint64, capacity+1)}
+}
+
+// Update records the depth of the queue; depths beyond the capacity are recorded as full.
+func (q *QueueDepth) Update(depth int) {
+	q.Lock()
+	defer q.Unlock()
+
+	switch {
+	case depth < 0:
+		depth = 0
+	case depth >= len(q.counts):
+		depth = len(q.counts) - 1
+	}
+
+	q.counts[depth]++
+	q.samples++
+	q.total += uint64(depth)
+	if depth > q.maximum {
+		q.maximum = depth
+	}
+}
+
+// N returns the number of samples recorded.
+func (q *QueueDepth) N() uint64 {
+	q.RLock()
+	defer q.RUnlock()
+	return q.samples
+}
+
+// Percentile returns the smallest depth that the percentage of the samples are at or
+// below, e.g. Percentile(99) for the p99 depth of the queue.
+func (q *QueueDepth) Percentile(p float64) int {
+	q.RLock()
+	defer q.RUnlock()
+	return q.percentile(p)
+}
+
+func (q *QueueDepth) percentile(p float64) int {
+	if q.samples == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(q.samples)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for depth, count := range q.counts {
+		if seen += count; seen >= rank {
+			return depth
+		}
+	}
+	return q.maximum
+}
+
+func (q *QueueDepth) MarshalJSON() ([]byte, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	data := map[string]interface{}{
+		"capacity":      len(q.counts) - 1,
+		"samples":       q.samples,
+		"max":           q.maximum,
+		"mean":          0.0,
+		"full_fraction": 0.0,
+	}
+
+	if q.samples > 0 {
+		data["mean"] = float64(q.total) / float64(q.samples)
+		data["full_fraction"] = float64(q.counts[len(q.counts)-1]) / float64(q.samples)
+	}
+
+	for _, p := range ReportedPercentiles {
+		data[p.Name] = q.percentile(p.Value)
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,37 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueDepth(t *testing.T) {
+	queue := stats.NewQueueDepth(10)
+	require.Zero(t, queue.Percentile(99))
+
+	for i := 0; i < 90; i++ {
+		queue.Update(i % 3)
+	}
+	for i := 0; i < 10; i++ {
+		queue.Update(12)
+	}
+
+	require.Equal(t, uint64(100), queue.N())
+	require.Equal(t, 1, queue.Percentile(50))
+	require.Equal(t, 2, queue.Percentile(90))
+	require.Equal(t, 10, queue.Percentile(99), "depths beyond the capacity are recorded as full")
+
+	data, err := json.Marshal(queue)
+	require.NoError(t, err)
+
+	summary := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &summary))
+	require.Equal(t, 10.0, summary["capacity"])
+	require.Equal(t, 10.0, summary["max"])
+	require.Equal(t, 0.1, summary["full_fraction"])
+	require.InDelta(t, 1.9, summary["mean"], 1e-9)
+	require.Equal(t, 10.0, summary["p99"])
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultReservoirSize is the number of samples retained if no size is specified.
+const DefaultReservoirSize = 100000
+
+// Reservoir maintains a uniform random sample of a fixed size from a stream of
+// durations of unknown length using Algorithm R. Every duration observed has an equal
+// probability of being retained in the reservoir, so the samples can be used for
+// offline distribution analysis (e.g. percentiles or histograms) with known
+// statistical properties while keeping memory bounded regardless of the run length.
+//
+// The primary entry point to the object is via the Update method, where one or more
+// time.Durations can be passed. This object is thread-safe (via a sync.RWMutex).
+type Reservoir struct {
+	sync.RWMutex
+	size    int             // the maximum number of samples to retain
+	seen    uint64          // the total number of samples observed
+	samples []time.Duration // the retained samples
+	rnd     *rand.Rand      // random source used to select replacements
+}
+
+// NewReservoir creates a reservoir that retains at most size samples; if size is
+// zero or less then the DefaultReservoirSize is used.
+func NewReservoir(size int) *Reservoir {
+	return NewSeededReservoir(size, time.Now().UnixNano())
+}
+
+// NewSeededReservoir creates a reservoir whose replacement decisions are generated
+// from the specified seed for reproducible sampling.
+func NewSeededReservoir(size int, seed int64) *Reservoir {
+	if size <= 0 {
+		size = DefaultReservoirSize
+	}
+
+	return &Reservoir{
+		size:    size,
+		samples: make([]time.Duration, 0, size),
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Update the reservoir with a duration or durations (thread-safe).
+func (r *Reservoir) Update(durations ...time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, duration := range durations {
+		r.seen++
+		if len(r.samples) < r.size {
+			r.samples = append(r.samples, duration)
+			continue
+		}
+
+		// Replace a random element with probability size/seen
+		if j := r.rnd.Int63n(int64(r.seen)); j < int64(r.size) {
+			r.samples[j] = duration
+		}
+	}
+}
+
+// Size returns the maximum number of samples retained by the reservoir.
+func (r *Reservoir) Size() int {
+	return r.size
+}
+
+// Seen returns the total number of samples observed by the reservoir.
+func (r *Reservoir) Seen() uint64 {
+	r.RLock()
+	defer r.RUnlock()
+	return r.seen
+}
+
+// Samples returns a copy of the samples currently retained in the reservoir.
+func (r *Reservoir) Samples() []time.Duration {
+	r.RLock()
+	defer r.RUnlock()
+
+	samples := make([]time.Duration, len(r.samples))
+	copy(samples, r.samples)
+	return samples
+}
+
+// Serializes the reservoir into a JSON map with the samples expressed as integer
+// nanoseconds so that they can be loaded without parsing duration strings.
+func (r *Reservoir) MarshalJSON() ([]byte, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	data := make(map[string]interface{})
+	data["size"] = r.size
+	data["seen"] = r.seen
+	data["unit"] = "ns"
+	data["samples"] = r.samples
+	return json.Marshal(data)
+}
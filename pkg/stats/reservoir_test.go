@@ -0,0 +1,42 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservoir(t *testing.T) {
+	data, err := loadLatenciesData()
+	require.NoError(t, err, "could not load test fixture data")
+
+	reservoir := stats.NewSeededReservoir(1000, 42)
+	require.Equal(t, 1000, reservoir.Size())
+
+	reservoir.Update(data...)
+	require.Equal(t, uint64(1000000), reservoir.Seen())
+
+	samples := reservoir.Samples()
+	require.Len(t, samples, 1000)
+
+	// The sample mean should approximate the population mean of 120.993689ms
+	latencies := &stats.Latencies{}
+	latencies.Update(samples...)
+	require.InDelta(t, 120993689, int64(latencies.Mean()), float64(3*time.Millisecond))
+}
+
+func TestReservoirUnfilled(t *testing.T) {
+	reservoir := stats.NewReservoir(0)
+	require.Equal(t, stats.DefaultReservoirSize, reservoir.Size())
+
+	reservoir.Update(time.Millisecond, 2*time.Millisecond, 3*time.Millisecond)
+	require.Equal(t, uint64(3), reservoir.Seen())
+	require.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, reservoir.Samples())
+
+	data, err := json.Marshal(reservoir)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"size": 100000, "seen": 3, "unit": "ns", "samples": [1000000, 2000000, 3000000]}`, string(data))
+}
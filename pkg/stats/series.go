@@ -0,0 +1,238 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// The maximum number of windows kept by a series; once a run outgrows the windows, pairs
+// of adjacent windows are merged and the width of the windows is doubled.
+const seriesWindows = 1024
+
+// The width of the windows of a new series.
+const seriesWidth = time.Millisecond
+
+// Series accumulates the latencies of a run online as operations complete rather than
+// retaining the latency of every operation, so that the memory used by a run is bounded
+// regardless of the number of operations. The summary excludes operations started in
+// the warmup or cooldown of the run exactly as UpdateTrimmed does; since the end of the
+// run is not known until the run is closed, only the samples within the cooldown of the
+// most recent sample are buffered. The latencies are also kept in a bounded number of
+// fixed width windows from which the phases and time series of the run are computed.
+// Samples should be added in about the order that the operations were started, e.g. as
+// the operations are acked; samples that are out of order are buffered in order.
+type Series struct {
+	sync.Mutex
+	warmup    time.Duration
+	cooldown  time.Duration
+	latencies Latencies
+	pending   []sample // samples that may be in the cooldown of the run
+	width     time.Duration
+	windows   []*Latencies
+	closed    bool
+}
+
+type sample struct {
+	offset  time.Duration
+	latency time.Duration
+}
+
+// NewSeries returns a series that excludes operations started within the warmup of the
+// start of the run or within the cooldown of the end of the run from the summary.
+func NewSeries(warmup, cooldown time.Duration) *Series {
+	return &Series{warmup: warmup, cooldown: cooldown, width: seriesWidth}
+}
+
+// Add the latency of an operation started at the offset from the start of the run; a
+// zero latency is recorded as a timeout.
+func (s *Series) Add(offset, latency time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.window(offset).Update(latency)
+
+	switch {
+	case latency == 0:
+		s.latencies.Update(latency)
+	case offset < s.warmup:
+		s.latencies.Warmup(latency)
+	case s.cooldown > 0:
+		// Samples that are older than the cooldown of this sample cannot be in the
+		// cooldown of the run since the run ends after this sample was started.
+		s.buffer(sample{offset, latency})
+		s.flush(offset - s.cooldown)
+	default:
+		s.latencies.Update(latency)
+	}
+}
+
+// Close the series once the run of the specified duration is over, resolving the samples
+// that were buffered in case they were in the cooldown of the run.
+func (s *Series) Close(duration time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.flush(duration - s.cooldown)
+	for _, p := range s.pending {
+		s.latencies.Cooldown(p.latency)
+	}
+	s.pending = nil
+	s.closed = true
+}
+
+// Inserts the sample into the buffer in the order of the offsets of the samples; the
+// samples are nearly in order so the position is found from the end of the buffer.
+func (s *Series) buffer(p sample) {
+	idx := len(s.pending)
+	for idx > 0 && s.pending[idx-1].offset > p.offset {
+		idx--
+	}
+
+	s.pending = append(s.pending, sample{})
+	copy(s.pending[idx+1:], s.pending[idx:])
+	s.pending[idx] = p
+}
+
+// Moves the buffered samples started before the offset into the summary.
+func (s *Series) flush(before time.Duration) {
+	n := 0
+	for n < len(s.pending) && s.pending[n].offset < before {
+		s.latencies.Update(s.pending[n].latency)
+		n++
+	}
+
+	// Compact the buffer once most of it has been flushed so it does not grow forever
+	if s.pending = s.pending[n:]; cap(s.pending) > 2*len(s.pending)+1024 {
+		s.pending = append(make([]sample, 0, 2*len(s.pending)), s.pending...)
+	}
+}
+
+// Returns the window of the offset, merging windows if the offset is past the last one.
+func (s *Series) window(offset time.Duration) *Latencies {
+	if offset < 0 {
+		offset = 0
+	}
+
+	for offset/s.width >= seriesWindows {
+		merged := make([]*Latencies, 0, seriesWindows)
+		for i := 0; i < len(s.windows); i += 2 {
+			window := s.windows[i]
+			if i+1 < len(s.windows) {
+				window.Append(s.windows[i+1])
+			}
+			merged = append(merged, window)
+		}
+		s.windows, s.width = merged, 2*s.width
+	}
+
+	idx := int(offset / s.width)
+	for len(s.windows) <= idx {
+		s.windows = append(s.windows, &Latencies{})
+	}
+	return s.windows[idx]
+}
+
+// Latencies returns a copy of the summary of the series with the duration used to
+// compute the throughput. The series should be closed first so that the summary
+// includes the samples that were buffered for the cooldown.
+func (s *Series) Latencies(duration time.Duration) *Latencies {
+	s.Lock()
+	defer s.Unlock()
+
+	latencies := &Latencies{}
+	latencies.Append(&s.latencies)
+	latencies.SetDuration(duration)
+	return latencies
+}
+
+// All returns the distribution of every sample added to the series including the
+// samples excluded from the summary by the warmup and cooldown.
+func (s *Series) All() *Latencies {
+	s.Lock()
+	defer s.Unlock()
+
+	latencies := &Latencies{}
+	for _, window := range s.windows {
+		latencies.Append(window)
+	}
+	return latencies
+}
+
+// Since returns the distribution of the samples of operations started at or after the
+// offset, e.g. the rolling latency of the most recent operations while the run is in
+// progress. Samples are selected by window so the window that contains the offset is
+// included in full and long runs with wide windows include older operations.
+func (s *Series) Since(offset time.Duration) *Latencies {
+	s.Lock()
+	defer s.Unlock()
+
+	first := 0
+	if offset > 0 {
+		first = int(offset / s.width)
+	}
+
+	latencies := &Latencies{}
+	for i := first; i < len(s.windows); i++ {
+		latencies.Append(s.windows[i])
+	}
+	return latencies
+}
+
+// Phases partitions the series into phases of the specified fractions of the duration
+// of the run like SplitPhases; samples are assigned to phases by the start of their
+// window so the phases are only as precise as the width of the windows.
+func (s *Series) Phases(fractions []float64, duration time.Duration) []*Phase {
+	s.Lock()
+	defer s.Unlock()
+
+	phases := SplitPhases(fractions, duration, nil, nil)
+	for i, window := range s.windows {
+		idx := len(phases) - 1
+		if duration > 0 {
+			at := float64(time.Duration(i)*s.width) / float64(duration)
+			for j, phase := range phases {
+				if at < phase.End {
+					idx = j
+					break
+				}
+			}
+		}
+		phases[idx].Latencies.Append(window)
+	}
+	return phases
+}
+
+// Windows partitions the series into consecutive windows of the specified width like
+// SplitWindows; samples are assigned to windows by the start of their window in the
+// series so the windows are only as precise as the width of the windows of the series.
+// If the width is not positive, the DefaultWindow is used.
+func (s *Series) Windows(width, duration time.Duration) []*Window {
+	s.Lock()
+	defer s.Unlock()
+
+	if width <= 0 {
+		width = DefaultWindow
+	}
+
+	windows := SplitWindows(width, duration, nil, nil)
+	for i, window := range s.windows {
+		idx := int(time.Duration(i) * s.width / width)
+		if idx >= len(windows) {
+			idx = len(windows) - 1
+		}
+		windows[idx].Latencies.Append(window)
+	}
+	return windows
+}
+
+// Width returns the current width of the windows of the series, which doubles each time
+// that the run outgrows the windows.
+func (s *Series) Width() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+	return s.width
+}
@@ -0,0 +1,125 @@
+package stats_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeries(t *testing.T) {
+	// Operations are started every millisecond over a ten second run, which is longer
+	// than the windows of the series can hold without being merged.
+	rnd := rand.New(rand.NewSource(42))
+	offsets := make([]time.Duration, 0, 10000)
+	latencies := make([]time.Duration, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		latency := time.Duration(1+rnd.Intn(5000)) * time.Microsecond
+		if i%500 == 0 {
+			latency = 0
+		}
+		offsets = append(offsets, time.Duration(i)*time.Millisecond)
+		latencies = append(latencies, latency)
+	}
+
+	warmup, cooldown, duration := time.Second, 2*time.Second, 10*time.Second
+	series := stats.NewSeries(warmup, cooldown)
+	for i, latency := range latencies {
+		series.Add(offsets[i], latency)
+	}
+	series.Close(duration)
+
+	// The summary is identical to trimming the retained samples
+	expected := &stats.Latencies{}
+	expected.UpdateTrimmed(warmup, cooldown, duration, offsets, latencies)
+	expected.SetDuration(duration)
+	require.Equal(t, expected.State(), series.Latencies(duration).State())
+
+	// Every sample is included in the distribution of all samples
+	all := series.All()
+	require.Equal(t, uint64(9980), all.N())
+	require.Equal(t, uint64(20), all.Timeouts())
+
+	// The phases are within the precision of the merged windows of the series
+	phases := series.Phases(nil, duration)
+	require.Len(t, phases, 3)
+	require.InDelta(t, 1000, phases[0].Latencies.Count(), 16)
+	require.InDelta(t, 8000, phases[1].Latencies.Count(), 32)
+	require.InDelta(t, 1000, phases[2].Latencies.Count(), 16)
+	require.Equal(t, 8*time.Second, phases[1].Latencies.Duration())
+
+	// The rolling latency of the last second is within the precision of the windows
+	require.InDelta(t, 1000, series.Since(9*time.Second).Count(), 16)
+	require.Equal(t, all.Count(), series.Since(-time.Second).Count())
+}
+
+func TestSeriesPhases(t *testing.T) {
+	// Phases of a run short enough that the windows are never merged are exact
+	offsets := make([]time.Duration, 0, 100)
+	latencies := make([]time.Duration, 0, 100)
+	series := stats.NewSeries(0, 0)
+	for i := 0; i < 100; i++ {
+		offset := time.Duration(i) * 10 * time.Millisecond
+		offsets = append(offsets, offset)
+		latencies = append(latencies, time.Duration(i+1)*time.Microsecond)
+		series.Add(offset, latencies[i])
+	}
+	series.Close(time.Second)
+
+	expected := stats.SplitPhases([]float64{0.25, 0.5, 0.25}, time.Second, offsets, latencies)
+	for i, phase := range series.Phases([]float64{0.25, 0.5, 0.25}, time.Second) {
+		require.Equal(t, expected[i].Latencies.State(), phase.Latencies.State())
+	}
+}
+
+func TestSeriesWindows(t *testing.T) {
+	// Windows of a run short enough that the windows are never merged are exact
+	offsets := make([]time.Duration, 0, 100)
+	latencies := make([]time.Duration, 0, 100)
+	series := stats.NewSeries(0, 0)
+	for i := 0; i < 100; i++ {
+		offset := time.Duration(i) * 9 * time.Millisecond
+		offsets = append(offsets, offset)
+		latencies = append(latencies, time.Duration(i+1)*time.Microsecond)
+		series.Add(offset, latencies[i])
+	}
+	series.Close(time.Second)
+	require.Equal(t, time.Millisecond, series.Width())
+
+	expected := stats.SplitWindows(300*time.Millisecond, time.Second, offsets, latencies)
+	windows := series.Windows(300*time.Millisecond, time.Second)
+	require.Len(t, windows, len(expected))
+	for i, window := range windows {
+		require.Equal(t, expected[i].Start, window.Start)
+		require.Equal(t, expected[i].End, window.End)
+		require.Equal(t, expected[i].Latencies.State(), window.Latencies.State())
+	}
+
+	// The default width is used if none is specified
+	require.Len(t, series.Windows(0, time.Minute), 6)
+}
+
+func TestSeriesOutOfOrder(t *testing.T) {
+	// Operations are acked out of the order that they were started in but are still
+	// classified by when they were started.
+	rnd := rand.New(rand.NewSource(7))
+	offsets := make([]time.Duration, 0, 2000)
+	latencies := make([]time.Duration, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		offsets = append(offsets, time.Duration(i)*time.Millisecond)
+		latencies = append(latencies, time.Duration(1+rnd.Intn(1000))*time.Microsecond)
+	}
+
+	warmup, cooldown, duration := 100*time.Millisecond, 500*time.Millisecond, 2*time.Second
+	series := stats.NewSeries(warmup, cooldown)
+	for _, i := range rnd.Perm(len(offsets)) {
+		series.Add(offsets[i], latencies[i])
+	}
+	series.Close(duration)
+
+	expected := &stats.Latencies{}
+	expected.UpdateTrimmed(warmup, cooldown, duration, offsets, latencies)
+	require.Equal(t, expected.N(), series.Latencies(duration).N())
+}
@@ -0,0 +1,68 @@
+package stats
+
+import "time"
+
+// LatencyState is the complete serializable state of a Latencies distribution so that
+// latencies measured by one process can be sent to another and merged exactly with
+// Append, including the histogram used to estimate percentiles.
+type LatencyState struct {
+	Samples  uint64         `json:"samples"`
+	Total    float64        `json:"total"`
+	Squares  float64        `json:"squares"`
+	Maximum  float64        `json:"maximum"`
+	Minimum  float64        `json:"minimum"`
+	Timeouts uint64         `json:"timeouts"`
+	Warmup   uint64         `json:"warmup,omitempty"`
+	Cooldown uint64         `json:"cooldown,omitempty"`
+	Duration time.Duration  `json:"duration"`
+	Buckets  map[int]uint64 `json:"buckets,omitempty"`
+}
+
+// State returns a copy of the internal state of the latencies (thread-safe).
+func (s *Latencies) State() *LatencyState {
+	s.RLock()
+	defer s.RUnlock()
+	s.Statistics.RLock()
+	defer s.Statistics.RUnlock()
+	s.percentiles.RLock()
+	defer s.percentiles.RUnlock()
+
+	state := &LatencyState{
+		Samples:  s.samples,
+		Total:    s.total,
+		Squares:  s.squares,
+		Maximum:  s.maximum,
+		Minimum:  s.minimum,
+		Timeouts: s.timeouts,
+		Warmup:   s.warmup,
+		Cooldown: s.cooldown,
+		Duration: s.duration,
+		Buckets:  make(map[int]uint64, len(s.percentiles.buckets)),
+	}
+
+	for idx, count := range s.percentiles.buckets {
+		state.Buckets[idx] = count
+	}
+	return state
+}
+
+// Latencies restores the distribution from the serialized state.
+func (st *LatencyState) Latencies() *Latencies {
+	s := &Latencies{}
+	s.samples = st.Samples
+	s.total = st.Total
+	s.squares = st.Squares
+	s.maximum = st.Maximum
+	s.minimum = st.Minimum
+	s.timeouts = st.Timeouts
+	s.warmup = st.Warmup
+	s.cooldown = st.Cooldown
+	s.duration = st.Duration
+
+	s.percentiles.buckets = make(map[int]uint64, len(st.Buckets))
+	for idx, count := range st.Buckets {
+		s.percentiles.buckets[idx] = count
+		s.percentiles.count += count
+	}
+	return s
+}
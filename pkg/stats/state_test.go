@@ -0,0 +1,42 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyState(t *testing.T) {
+	latencies := &stats.Latencies{}
+	for i := 1; i <= 1000; i++ {
+		latencies.Update(time.Duration(i) * time.Microsecond)
+	}
+	latencies.Update(0)
+	latencies.SetDuration(time.Second)
+
+	// The state must survive a round trip through JSON to be sent between processes
+	data, err := json.Marshal(latencies.State())
+	require.NoError(t, err)
+
+	state := &stats.LatencyState{}
+	require.NoError(t, json.Unmarshal(data, state))
+
+	restored := state.Latencies()
+	require.Equal(t, latencies.N(), restored.N())
+	require.Equal(t, latencies.Timeouts(), restored.Timeouts())
+	require.Equal(t, latencies.Mean(), restored.Mean())
+	require.Equal(t, latencies.Fastest(), restored.Fastest())
+	require.Equal(t, latencies.Slowest(), restored.Slowest())
+	require.Equal(t, latencies.Duration(), restored.Duration())
+	require.Equal(t, latencies.Percentile(99), restored.Percentile(99))
+
+	// Restored latencies are merged exactly with Append
+	merged := &stats.Latencies{}
+	merged.Append(restored)
+	merged.Append(state.Latencies())
+	require.Equal(t, 2*latencies.N(), merged.N())
+	require.Equal(t, latencies.Percentile(50), merged.Percentile(50))
+}
@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Tail investigates the long tail of a run: whenever an operation's latency exceeds the
+// threshold a follow-up probe operation is published immediately, and the latency of
+// the probe determines whether the slowness persisted. A slow operation followed by a
+// fast probe is a transient hiccup of that operation; a slow probe opens a degradation
+// window that lasts until a probe is fast again. Only one probe is in flight at a time
+// so that a degraded server is not flooded with probes; slow operations observed while
+// a probe is in flight are counted but not probed. All offsets are from the start of
+// the run. Tail is not safe for concurrent use.
+type Tail struct {
+	Threshold  time.Duration
+	Slow       uint64 // the number of operations slower than the threshold
+	Probed     uint64 // the number of slow operations that were followed by a probe
+	Transient  uint64 // the number of probes that were faster than the threshold
+	Persistent uint64 // the number of probes that were slower than the threshold
+	Failed     uint64 // the number of probes that were nacked or never acked
+	Windows    []*DegradationWindow
+
+	probing bool
+	open    *DegradationWindow
+}
+
+// DegradationWindow is a period of the run during which consecutive probes were slower
+// than the threshold, i.e. the slowness of the operations was not transient.
+type DegradationWindow struct {
+	Start  time.Duration // the offset at which the slow operation that opened the window was sent
+	End    time.Duration // the offset at which the last slow probe of the window was acked
+	Slow   uint64        // the number of slow operations acked during the window
+	Probes uint64        // the number of slow probes during the window
+}
+
+// NewTail returns a tail investigation for operations slower than the threshold.
+func NewTail(threshold time.Duration) *Tail {
+	return &Tail{Threshold: threshold}
+}
+
+// Observe an acked operation that was sent and acked at the offsets from the start of
+// the run; true is returned if the operation was slow and a probe should be published
+// now, in which case the outcome of the probe must be resolved before another probe.
+func (t *Tail) Observe(sent, acked time.Duration) (probe bool) {
+	if acked-sent <= t.Threshold {
+		return false
+	}
+
+	t.Slow++
+	if t.open != nil {
+		t.open.Slow++
+	}
+
+	if t.probing {
+		return false
+	}
+
+	t.Probed++
+	t.probing = true
+	if t.open == nil {
+		// The window opens at the operation that triggered the probe if the probe is slow
+		t.open = &DegradationWindow{Start: sent, Slow: 1}
+	}
+	return true
+}
+
+// Resolve the outcome of the probe published at the sent offset; if ok is false the
+// probe was nacked or never acked and its acked offset is ignored.
+func (t *Tail) Resolve(sent, acked time.Duration, ok bool) {
+	t.probing = false
+	switch {
+	case !ok:
+		t.Failed++
+		t.closeWindow()
+	case acked-sent > t.Threshold:
+		t.Persistent++
+		if t.open == nil {
+			t.open = &DegradationWindow{Start: sent}
+		}
+		t.open.Probes++
+		t.open.End = acked
+	default:
+		t.Transient++
+		t.closeWindow()
+	}
+}
+
+// Records the open window if any of its probes were slow, otherwise the operation that
+// opened the window was a transient hiccup and the window is discarded.
+func (t *Tail) closeWindow() {
+	if t.open != nil && t.open.Probes > 0 {
+		t.Windows = append(t.Windows, t.open)
+	}
+	t.open = nil
+}
+
+// Degraded returns the total duration of the degradation windows, including the window
+// that is still open if its probes were slow.
+func (t *Tail) Degraded() (total time.Duration) {
+	for _, window := range t.windows() {
+		total += window.End - window.Start
+	}
+	return total
+}
+
+// Returns the completed windows and the open window if its probes were slow.
+func (t *Tail) windows() []*DegradationWindow {
+	if t.open != nil && t.open.Probes > 0 {
+		return append(t.Windows[:len(t.Windows):len(t.Windows)], t.open)
+	}
+	return t.Windows
+}
+
+// Serializes the tail investigation into a JSON map with durations as strings.
+func (t *Tail) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["threshold"] = t.Threshold.String()
+	data["slow"] = t.Slow
+	data["probed"] = t.Probed
+	data["transient"] = t.Transient
+	data["persistent"] = t.Persistent
+	data["failed"] = t.Failed
+	data["degraded"] = t.Degraded().String()
+
+	windows := t.windows()
+	data["windows"] = windows
+	data["degradation_windows"] = len(windows)
+	return json.Marshal(data)
+}
+
+// Serializes the window into a JSON map with durations as strings.
+func (w *DegradationWindow) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["start"] = w.Start.String()
+	data["end"] = w.End.String()
+	data["duration"] = (w.End - w.Start).String()
+	data["slow"] = w.Slow
+	data["probes"] = w.Probes
+	return json.Marshal(data)
+}
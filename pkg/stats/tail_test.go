@@ -0,0 +1,62 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTail(t *testing.T) {
+	ms := time.Millisecond
+	tail := stats.NewTail(10 * ms)
+
+	// Fast operations are ignored
+	require.False(t, tail.Observe(0, 5*ms))
+
+	// A slow operation followed by a fast probe is a transient hiccup
+	require.True(t, tail.Observe(10*ms, 30*ms))
+	tail.Resolve(30*ms, 32*ms, true)
+	require.Equal(t, uint64(1), tail.Transient)
+	require.Empty(t, tail.Windows)
+
+	// Slow probes open a degradation window that lasts until a probe is fast again;
+	// slow operations observed while a probe is in flight are not probed.
+	require.True(t, tail.Observe(100*ms, 150*ms))
+	require.False(t, tail.Observe(110*ms, 160*ms))
+	tail.Resolve(150*ms, 180*ms, true)
+	require.True(t, tail.Observe(170*ms, 200*ms))
+	tail.Resolve(200*ms, 250*ms, true)
+	require.Equal(t, 150*ms, tail.Degraded(), "the open window should be included")
+
+	require.True(t, tail.Observe(260*ms, 280*ms))
+	tail.Resolve(280*ms, 281*ms, true)
+	require.Len(t, tail.Windows, 1)
+
+	window := tail.Windows[0]
+	require.Equal(t, 100*ms, window.Start)
+	require.Equal(t, 250*ms, window.End)
+	require.Equal(t, uint64(4), window.Slow, "including the slow operation whose probe was fast")
+	require.Equal(t, uint64(2), window.Probes)
+
+	// Probes that are never acked do not extend a window
+	require.True(t, tail.Observe(300*ms, 400*ms))
+	tail.Resolve(400*ms, 0, false)
+	require.Len(t, tail.Windows, 1)
+
+	require.Equal(t, uint64(6), tail.Slow)
+	require.Equal(t, uint64(5), tail.Probed)
+	require.Equal(t, uint64(2), tail.Transient)
+	require.Equal(t, uint64(2), tail.Persistent)
+	require.Equal(t, uint64(1), tail.Failed)
+
+	data, err := json.Marshal(tail)
+	require.NoError(t, err)
+
+	summary := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &summary))
+	require.Equal(t, "150ms", summary["degraded"])
+	require.Equal(t, 1.0, summary["degradation_windows"])
+}
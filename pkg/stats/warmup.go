@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WarmupPhase records the events published before the measurement of a run started.
+// The events are not included in the latencies or throughput of the run; the phase is
+// reported so that it is clear how much traffic preceded the measurement.
+type WarmupPhase struct {
+	Events   uint64        // the number of events published during the warmup
+	Acked    uint64        // the number of warmup events acked by the server
+	Nacked   uint64        // the number of warmup events nacked by the server
+	Duration time.Duration // the time taken to publish the warmup and resolve its events
+}
+
+// Ack records the response of the server to a warmup event.
+func (w *WarmupPhase) Ack(acked bool) {
+	if acked {
+		w.Acked++
+	} else {
+		w.Nacked++
+	}
+}
+
+// Serializes the warmup into a JSON map with the duration as a string.
+func (w *WarmupPhase) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["events"] = w.Events
+	data["acked"] = w.Acked
+	data["nacked"] = w.Nacked
+	data["duration"] = w.Duration.String()
+	return json.Marshal(data)
+}
@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DefaultWindow is the width of the time series windows if none is specified.
+const DefaultWindow = 10 * time.Second
+
+// Window summarizes the latencies of the operations that were started during a fixed
+// width interval of a run so that a time series of windows shows how the latency and
+// throughput of a long running benchmark degrades over time.
+type Window struct {
+	Start     time.Duration // the offset from the start of the run at which the window begins
+	End       time.Duration // the offset from the start of the run at which the window ends
+	Latencies *Latencies    // the latencies of the operations started during the window
+}
+
+// SplitWindows partitions the latencies into consecutive windows of the specified width
+// by the offset from the start of the run at which each operation was started; offsets
+// and latencies must be the same length. The last window is truncated to the end of the
+// run and operations started outside of the run are assigned to the first or last
+// window. If the width is not positive, the DefaultWindow is used.
+func SplitWindows(width, duration time.Duration, offsets, latencies []time.Duration) []*Window {
+	if width <= 0 {
+		width = DefaultWindow
+	}
+
+	n := 1
+	if duration > width {
+		n = int((duration + width - 1) / width)
+	}
+
+	windows := make([]*Window, n)
+	for i := range windows {
+		windows[i] = &Window{
+			Start:     time.Duration(i) * width,
+			End:       time.Duration(i+1) * width,
+			Latencies: &Latencies{},
+		}
+	}
+
+	if duration > 0 && windows[n-1].End > duration {
+		windows[n-1].End = duration
+	}
+
+	for i, latency := range latencies {
+		idx := 0
+		if offsets[i] > 0 {
+			if idx = int(offsets[i] / width); idx >= n {
+				idx = n - 1
+			}
+		}
+		windows[idx].Latencies.Update(latency)
+	}
+
+	// The duration of each window is used to compute the window's throughput
+	for _, window := range windows {
+		window.Latencies.SetDuration(window.End - window.Start)
+	}
+	return windows
+}
+
+// Serializes the window into a compact JSON map rather than the full latency summary
+// since a long run can have many windows.
+func (w *Window) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["start"] = w.Start.String()
+	data["end"] = w.End.String()
+	data["samples"] = w.Latencies.N()
+	data["timeouts"] = w.Latencies.Timeouts()
+	data["mean"] = w.Latencies.Mean().String()
+	data["p99"] = w.Latencies.Percentile(99).String()
+	data["throughput"] = w.Latencies.Throughput()
+	return json.Marshal(data)
+}
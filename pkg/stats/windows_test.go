@@ -0,0 +1,58 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitWindows(t *testing.T) {
+	// Operations are started every 10ms over a 2.5 second run and degrade over time
+	offsets := make([]time.Duration, 0, 250)
+	latencies := make([]time.Duration, 0, 250)
+	for i := 0; i < 250; i++ {
+		offset := time.Duration(i) * 10 * time.Millisecond
+		offsets = append(offsets, offset)
+		latencies = append(latencies, time.Millisecond*time.Duration(1+offset/time.Second))
+	}
+
+	// Operations started after the end of the run are assigned to the last window
+	offsets = append(offsets, 3*time.Second)
+	latencies = append(latencies, 0)
+
+	windows := stats.SplitWindows(time.Second, 2500*time.Millisecond, offsets, latencies)
+	require.Len(t, windows, 3)
+
+	for i, window := range windows[:2] {
+		require.Equal(t, time.Duration(i)*time.Second, window.Start)
+		require.Equal(t, time.Duration(i+1)*time.Second, window.End)
+		require.Equal(t, uint64(100), window.Latencies.N())
+		require.Equal(t, time.Duration(i+1)*time.Millisecond, window.Latencies.Mean())
+		require.InDelta(t, 100.0, window.Latencies.Throughput(), 1e-9)
+	}
+
+	// The last window is truncated to the end of the run
+	last := windows[2]
+	require.Equal(t, 2500*time.Millisecond, last.End)
+	require.Equal(t, uint64(50), last.Latencies.N())
+	require.Equal(t, uint64(1), last.Latencies.Timeouts())
+	require.InDelta(t, 100.0, last.Latencies.Throughput(), 1e-9)
+
+	data, err := json.Marshal(last)
+	require.NoError(t, err)
+
+	summary := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &summary))
+	require.Equal(t, "2s", summary["start"])
+	require.Equal(t, "3ms", summary["mean"])
+	require.Contains(t, summary, "p99")
+
+	// A run shorter than the window has a single window and the default width is used
+	windows = stats.SplitWindows(0, time.Second, offsets[:10], latencies[:10])
+	require.Len(t, windows, 1)
+	require.Equal(t, time.Second, windows[0].End)
+	require.Equal(t, uint64(10), windows[0].Latencies.N())
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNoBaseline = errors.New("no baseline has been promoted for the benchmark")
+
+// Baseline is the stored run of a benchmark that later runs are gated against, e.g. the
+// latest run that passed all of its regression budgets. The provenance records how the
+// run came to be promoted, such as the baseline it was gated against and the CI job
+// that promoted it.
+type Baseline struct {
+	Benchmark  string            `json:"benchmark"`
+	RunID      string            `json:"run_id"`
+	Previous   string            `json:"previous,omitempty"` // the run that was the baseline before
+	Promoted   time.Time         `json:"promoted"`
+	Provenance map[string]string `json:"provenance,omitempty"`
+}
+
+// Promote the run of the baseline to the baseline of its benchmark, replacing the
+// current baseline in a single transaction so that concurrent gates never observe a
+// partially updated baseline. The run must be in the store and must be a run of the
+// benchmark. The previous baseline and the promotion timestamp are set on the baseline.
+func (s *SQLite) Promote(baseline *Baseline) (err error) {
+	var tx *sql.Tx
+	if tx, err = s.db.Begin(); err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var benchmark string
+	if err = tx.QueryRow("SELECT benchmark FROM runs WHERE id = ?", baseline.RunID).Scan(&benchmark); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if benchmark != baseline.Benchmark {
+		return fmt.Errorf("run %s is a %s run and cannot be the %s baseline", baseline.RunID, benchmark, baseline.Benchmark)
+	}
+
+	var previous sql.NullString
+	if err = tx.QueryRow("SELECT run_id FROM baselines WHERE benchmark = ?", baseline.Benchmark).Scan(&previous); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// Promoting the current baseline again keeps the run it replaced as the previous run
+	if previous.String == baseline.RunID {
+		if err = tx.QueryRow("SELECT previous FROM baselines WHERE benchmark = ?", baseline.Benchmark).Scan(&previous); err != nil {
+			return err
+		}
+	}
+	baseline.Previous = previous.String
+
+	if baseline.Promoted.IsZero() {
+		baseline.Promoted = time.Now()
+	}
+
+	var provenance []byte
+	if len(baseline.Provenance) > 0 {
+		if provenance, err = json.Marshal(baseline.Provenance); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(
+		"INSERT INTO baselines (benchmark, run_id, previous, promoted, provenance) VALUES (?, ?, ?, ?, ?) ON CONFLICT (benchmark) DO UPDATE SET run_id = excluded.run_id, previous = excluded.previous, promoted = excluded.promoted, provenance = excluded.provenance",
+		baseline.Benchmark, baseline.RunID, nullable([]byte(baseline.Previous)), baseline.Promoted.UTC().Format(time.RFC3339Nano), nullable(provenance),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Baseline returns the current baseline of the benchmark.
+func (s *SQLite) Baseline(benchmark string) (_ *Baseline, err error) {
+	var (
+		baseline             = &Baseline{Benchmark: benchmark}
+		previous, provenance sql.NullString
+		promoted             string
+	)
+
+	if err = s.db.QueryRow("SELECT run_id, previous, promoted, provenance FROM baselines WHERE benchmark = ?", benchmark).Scan(&baseline.RunID, &previous, &promoted, &provenance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoBaseline
+		}
+		return nil, err
+	}
+
+	if baseline.Promoted, err = time.Parse(time.RFC3339Nano, promoted); err != nil {
+		return nil, fmt.Errorf("could not parse promoted timestamp of the %s baseline: %w", benchmark, err)
+	}
+
+	if provenance.Valid {
+		if err = json.Unmarshal([]byte(provenance.String), &baseline.Provenance); err != nil {
+			return nil, fmt.Errorf("could not parse provenance of the %s baseline: %w", benchmark, err)
+		}
+	}
+	baseline.Previous = previous.String
+	return baseline, nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Group is the set of runs that recorded the same value of an experiment parameter,
+// e.g. every run of a benchmark that was built with the same client SDK version.
+type Group struct {
+	Value string `json:"value"`
+	Runs  []*Run `json:"runs"` // ordered from newest to oldest
+}
+
+// Latest returns the most recent run in the group.
+func (g *Group) Latest() *Run {
+	return g.Runs[0]
+}
+
+// Experiment returns the value of a parameter recorded in the experiment metadata of the
+// run's metrics, or the empty string if the run did not record the parameter.
+func (r *Run) Experiment(key string) string {
+	// Indexed parameters are read from the run without parsing the metrics
+	switch {
+	case key == ServerVersion && r.ServerVersion != "":
+		return r.ServerVersion
+	case key == ServerID && r.ServerID != "":
+		return r.ServerID
+	}
+
+	var metrics struct {
+		Experiment map[string]interface{} `json:"experiment"`
+	}
+
+	if err := json.Unmarshal(r.Metrics, &metrics); err != nil {
+		return ""
+	}
+
+	val, ok := metrics.Experiment[key]
+	if !ok || val == nil {
+		return ""
+	}
+
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprint(val)
+}
+
+// GroupBy groups the runs by the value of an experiment parameter, ordering the groups
+// by their most recent run so that the first group is the one most recently run. Runs
+// that did not record the parameter (e.g. runs recorded by older versions of the
+// benchmarks) are excluded.
+func GroupBy(runs []*Run, key string) []*Group {
+	sorted := make([]*Run, len(runs))
+	copy(sorted, runs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created.After(sorted[j].Created)
+	})
+
+	groups := make([]*Group, 0)
+	index := make(map[string]*Group)
+	for _, run := range sorted {
+		value := run.Experiment(key)
+		if value == "" {
+			continue
+		}
+
+		group, ok := index[value]
+		if !ok {
+			group = &Group{Value: value}
+			index[value] = group
+			groups = append(groups, group)
+		}
+		group.Runs = append(group.Runs, run)
+	}
+	return groups
+}
@@ -0,0 +1,35 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBy(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	run := func(id string, age time.Duration, metrics string) *store.Run {
+		return &store.Run{ID: id, Benchmark: "blast", Created: now.Add(-age), Metrics: []byte(metrics)}
+	}
+
+	runs := []*store.Run{
+		run("old", 3*time.Hour, `{"experiment": {"sdk_version": "v0.10.0"}}`),
+		run("new", time.Hour, `{"experiment": {"sdk_version": "v0.11.0"}}`),
+		run("mid", 2*time.Hour, `{"experiment": {"sdk_version": "v0.11.0"}}`),
+		run("legacy", 4*time.Hour, `{"experiment": {"client_version": "0.3"}}`),
+		run("invalid", 5*time.Hour, `not json`),
+	}
+
+	groups := store.GroupBy(runs, "sdk_version")
+	require.Len(t, groups, 2, "runs without the parameter should be excluded")
+	require.Equal(t, "v0.11.0", groups[0].Value)
+	require.Equal(t, "new", groups[0].Latest().ID)
+	require.Len(t, groups[0].Runs, 2)
+	require.Equal(t, "v0.10.0", groups[1].Value)
+	require.Equal(t, "old", groups[1].Latest().ID)
+
+	require.Equal(t, "v0.10.0", runs[0].Experiment("sdk_version"))
+	require.Equal(t, "", runs[4].Experiment("sdk_version"))
+}
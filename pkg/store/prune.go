@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// DefaultMaxAge is the default retention period for runs in a results store.
+const DefaultMaxAge = 90 * 24 * time.Hour
+
+var ErrNoPolicy = errors.New("prune policy must specify keep last or max age")
+
+// PrunePolicy describes which runs a results store should retain. Runs are grouped by
+// benchmark and label set; KeepLast retains the N most recent runs in each group and
+// MaxAge removes any runs older than the specified duration. If both are specified, a
+// run is removed if it violates either constraint.
+type PrunePolicy struct {
+	KeepLast int           `json:"keep_last,omitempty" yaml:"keep_last,omitempty"`
+	MaxAge   time.Duration `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+}
+
+// Validate that the policy specifies at least one non-negative retention constraint.
+func (p PrunePolicy) Validate() error {
+	if p.KeepLast < 0 || p.MaxAge < 0 {
+		return errors.New("prune policy values cannot be negative")
+	}
+
+	if p.KeepLast == 0 && p.MaxAge == 0 {
+		return ErrNoPolicy
+	}
+	return nil
+}
+
+// Prune returns the runs that should be removed according to the policy relative to the
+// specified timestamp. The input slice is not modified; the returned runs are ordered
+// from oldest to newest.
+func (p PrunePolicy) Prune(runs []*Run, now time.Time) (remove []*Run) {
+	groups := make(map[string][]*Run)
+	for _, run := range runs {
+		key := run.Benchmark + "|" + run.LabelSet()
+		groups[key] = append(groups[key], run)
+	}
+
+	for _, group := range groups {
+		// Sort the group from newest to oldest
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Created.After(group[j].Created)
+		})
+
+		for i, run := range group {
+			if p.KeepLast > 0 && i >= p.KeepLast {
+				remove = append(remove, run)
+				continue
+			}
+
+			if p.MaxAge > 0 && now.Sub(run.Created) > p.MaxAge {
+				remove = append(remove, run)
+			}
+		}
+	}
+
+	sort.SliceStable(remove, func(i, j int) bool {
+		return remove[i].Created.Before(remove[j].Created)
+	})
+	return remove
+}
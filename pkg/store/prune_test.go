@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	runs := []*store.Run{
+		{ID: "a1", Benchmark: "blast", Labels: map[string]string{"env": "staging"}, Created: now.Add(-1 * day)},
+		{ID: "a2", Benchmark: "blast", Labels: map[string]string{"env": "staging"}, Created: now.Add(-2 * day)},
+		{ID: "a3", Benchmark: "blast", Labels: map[string]string{"env": "staging"}, Created: now.Add(-3 * day)},
+		{ID: "a4", Benchmark: "blast", Labels: map[string]string{"env": "staging"}, Created: now.Add(-120 * day)},
+		{ID: "b1", Benchmark: "blast", Labels: map[string]string{"env": "prod"}, Created: now.Add(-100 * day)},
+		{ID: "c1", Benchmark: "sustain", Labels: map[string]string{"env": "staging"}, Created: now.Add(-5 * day)},
+	}
+
+	ids := func(runs []*store.Run) []string {
+		out := make([]string, 0, len(runs))
+		for _, run := range runs {
+			out = append(out, run.ID)
+		}
+		return out
+	}
+
+	policy := store.PrunePolicy{KeepLast: 2}
+	require.NoError(t, policy.Validate())
+	require.Equal(t, []string{"a4", "a3"}, ids(policy.Prune(runs, now)))
+
+	policy = store.PrunePolicy{MaxAge: store.DefaultMaxAge}
+	require.Equal(t, []string{"a4", "b1"}, ids(policy.Prune(runs, now)))
+
+	policy = store.PrunePolicy{KeepLast: 3, MaxAge: store.DefaultMaxAge}
+	require.Equal(t, []string{"a4", "b1"}, ids(policy.Prune(runs, now)))
+
+	require.ErrorIs(t, store.PrunePolicy{}.Validate(), store.ErrNoPolicy)
+	require.Error(t, store.PrunePolicy{KeepLast: -1}.Validate())
+}
+
+func TestLabelSet(t *testing.T) {
+	run := &store.Run{Labels: map[string]string{"region": "us-east", "env": "staging"}}
+	require.Equal(t, "env=staging,region=us-east", run.LabelSet())
+	require.Equal(t, "", (&store.Run{}).LabelSet())
+}
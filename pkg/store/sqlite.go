@@ -0,0 +1,287 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	_ "modernc.org/sqlite"
+)
+
+var ErrNotFound = errors.New("run not found in results store")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id TEXT PRIMARY KEY,
+	benchmark TEXT NOT NULL,
+	labels TEXT,
+	created TEXT NOT NULL,
+	config TEXT,
+	metrics TEXT,
+	server_version TEXT,
+	server_id TEXT
+);
+CREATE INDEX IF NOT EXISTS runs_benchmark_created ON runs (benchmark, created);
+CREATE TABLE IF NOT EXISTS baselines (
+	benchmark TEXT PRIMARY KEY,
+	run_id TEXT NOT NULL,
+	previous TEXT,
+	promoted TEXT NOT NULL,
+	provenance TEXT
+);
+`
+
+// Columns added to the runs table after the initial schema; stores created by older
+// versions of the benchmarks are migrated by adding the columns and backfilling them
+// from the experiment metadata of the recorded metrics.
+var migrations = []struct {
+	column   string
+	backfill string
+}{
+	{"server_version", "$.experiment.server_version"},
+	{"server_id", "$.experiment.server_id"},
+}
+
+// Indices that depend on migrated columns are created after the migrations are applied.
+const indices = `
+CREATE INDEX IF NOT EXISTS runs_benchmark_server_version ON runs (benchmark, server_version, created);
+CREATE INDEX IF NOT EXISTS runs_benchmark_server_id ON runs (benchmark, server_id, created);
+`
+
+// The columns selected when runs are read from the store.
+const columns = "id, benchmark, labels, created, config, metrics, server_version, server_id"
+
+// DefaultPath returns the location of the results database in the user's config
+// directory, falling back to the current working directory.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "enbench.db"
+	}
+	return filepath.Join(dir, "enbench", "results.db")
+}
+
+// SQLite is a results store that records the history of benchmark runs in a local
+// SQLite database (a pure Go driver is used so the binary does not require cgo).
+type SQLite struct {
+	db *sql.DB
+}
+
+// Open the results database at the specified path, creating it if necessary.
+func Open(path string) (_ *SQLite, err error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("could not create results store directory: %w", err)
+		}
+	}
+
+	var db *sql.DB
+	if db, err = sql.Open("sqlite", path); err != nil {
+		return nil, err
+	}
+
+	if _, err = db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not initialize results store: %w", err)
+	}
+
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate results store: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Adds any columns that are missing from the runs table of an older results store.
+func migrate(db *sql.DB) (err error) {
+	var rows *sql.Rows
+	if rows, err = db.Query("SELECT name FROM pragma_table_info('runs')"); err != nil {
+		return err
+	}
+
+	existing := make(map[string]struct{})
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = struct{}{}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, ok := existing[migration.column]; ok {
+			continue
+		}
+
+		if _, err = db.Exec(fmt.Sprintf("ALTER TABLE runs ADD COLUMN %s TEXT", migration.column)); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("UPDATE runs SET %s = json_extract(metrics, ?) WHERE metrics IS NOT NULL AND json_valid(metrics)", migration.column)
+		if _, err = db.Exec(query, migration.backfill); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(indices)
+	return err
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// Save a run to the store, assigning it an ID and created timestamp if not set.
+func (s *SQLite) Save(run *Run) (err error) {
+	if run.Created.IsZero() {
+		run.Created = time.Now()
+	}
+
+	if run.ID == "" {
+		run.ID = ulid.Make().String()
+	}
+
+	// Index the run by the server it was run against if the benchmark recorded it
+	if run.ServerVersion == "" {
+		run.ServerVersion = run.Experiment(ServerVersion)
+	}
+
+	if run.ServerID == "" {
+		run.ServerID = run.Experiment(ServerID)
+	}
+
+	var labels []byte
+	if len(run.Labels) > 0 {
+		if labels, err = json.Marshal(run.Labels); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO runs ("+columns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		run.ID, run.Benchmark, nullable(labels), run.Created.UTC().Format(time.RFC3339Nano), nullable(run.Config), nullable(run.Metrics),
+		nullable([]byte(run.ServerVersion)), nullable([]byte(run.ServerID)),
+	)
+	return err
+}
+
+// Get a run by its ID or by a unique prefix of its ID.
+func (s *SQLite) Get(id string) (_ *Run, err error) {
+	var rows *sql.Rows
+	if rows, err = s.db.Query("SELECT "+columns+" FROM runs WHERE id LIKE ? LIMIT 2", strings.ToUpper(id)+"%"); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*Run
+	if runs, err = scan(rows); err != nil {
+		return nil, err
+	}
+
+	switch len(runs) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return runs[0], nil
+	default:
+		return nil, fmt.Errorf("run id prefix %q is ambiguous", id)
+	}
+}
+
+// List the runs in the store from newest to oldest. If benchmark is not empty only
+// runs of that benchmark are returned; if limit is positive at most limit runs are
+// returned.
+func (s *SQLite) List(benchmark string, limit int) (_ []*Run, err error) {
+	query := "SELECT " + columns + " FROM runs"
+	args := make([]interface{}, 0, 2)
+	if benchmark != "" {
+		query += " WHERE benchmark = ?"
+		args = append(args, benchmark)
+	}
+
+	query += " ORDER BY created DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	var rows *sql.Rows
+	if rows, err = s.db.Query(query, args...); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scan(rows)
+}
+
+// Delete the runs with the specified IDs from the store.
+func (s *SQLite) Delete(ids ...string) (err error) {
+	var tx *sql.Tx
+	if tx, err = s.db.Begin(); err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err = tx.Exec("DELETE FROM runs WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func scan(rows *sql.Rows) (runs []*Run, err error) {
+	runs = make([]*Run, 0)
+	for rows.Next() {
+		var (
+			run                     = &Run{}
+			labels, config, metrics sql.NullString
+			serverVersion, serverID sql.NullString
+			created                 string
+		)
+
+		if err = rows.Scan(&run.ID, &run.Benchmark, &labels, &created, &config, &metrics, &serverVersion, &serverID); err != nil {
+			return nil, err
+		}
+
+		if run.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, fmt.Errorf("could not parse created timestamp of run %s: %w", run.ID, err)
+		}
+
+		if labels.Valid {
+			if err = json.Unmarshal([]byte(labels.String), &run.Labels); err != nil {
+				return nil, fmt.Errorf("could not parse labels of run %s: %w", run.ID, err)
+			}
+		}
+
+		if config.Valid {
+			run.Config = json.RawMessage(config.String)
+		}
+
+		if metrics.Valid {
+			run.Metrics = json.RawMessage(metrics.String)
+		}
+		run.ServerVersion, run.ServerID = serverVersion.String, serverID.String
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Empty JSON fields are stored as NULL rather than as an empty string.
+func nullable(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
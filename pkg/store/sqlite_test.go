@@ -0,0 +1,139 @@
+package store_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "results", "enbench.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	runs := []*store.Run{
+		{Benchmark: "blast", Created: now.Add(-2 * time.Hour), Metrics: json.RawMessage(`{"events":100}`)},
+		{Benchmark: "sustain", Created: now.Add(-1 * time.Hour), Labels: map[string]string{"env": "staging"}},
+		{Benchmark: "blast", Created: now, Config: json.RawMessage(`{"topic":"benchmarks"}`)},
+	}
+
+	for _, run := range runs {
+		require.NoError(t, db.Save(run))
+		require.NotEmpty(t, run.ID, "an id should be assigned to the run")
+	}
+
+	all, err := db.List("", 0)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	require.Equal(t, runs[2].ID, all[0].ID, "runs should be listed newest first")
+	require.True(t, now.Equal(all[0].Created))
+	require.JSONEq(t, `{"topic":"benchmarks"}`, string(all[0].Config))
+	require.Equal(t, map[string]string{"env": "staging"}, all[1].Labels)
+
+	blasts, err := db.List("blast", 1)
+	require.NoError(t, err)
+	require.Len(t, blasts, 1)
+	require.Equal(t, runs[2].ID, blasts[0].ID)
+
+	run, err := db.Get(runs[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "blast", run.Benchmark)
+	require.JSONEq(t, `{"events":100}`, string(run.Metrics))
+	require.Nil(t, run.Config)
+
+	_, err = db.Get("notarunid")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, db.Delete(runs[0].ID, runs[1].ID))
+	all, err = db.List("", 0)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+}
+
+func TestSQLiteServerIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enbench.db")
+
+	// Create a store with the schema used before runs were indexed by server
+	legacy, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec("CREATE TABLE runs (id TEXT PRIMARY KEY, benchmark TEXT NOT NULL, labels TEXT, created TEXT NOT NULL, config TEXT, metrics TEXT)")
+	require.NoError(t, err)
+	_, err = legacy.Exec(
+		"INSERT INTO runs (id, benchmark, created, metrics) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+		"legacy", "blast", time.Now().UTC().Format(time.RFC3339Nano), `{"experiment": {"server_version": "v0.9.0", "server_id": "node-a"}}`,
+		"broken", "blast", time.Now().UTC().Format(time.RFC3339Nano), `not json`,
+	)
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	db, err := store.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	run, err := db.Get("legacy")
+	require.NoError(t, err)
+	require.Equal(t, "v0.9.0", run.ServerVersion, "existing runs should be backfilled")
+	require.Equal(t, "node-a", run.ServerID)
+
+	run, err = db.Get("broken")
+	require.NoError(t, err)
+	require.Empty(t, run.ServerVersion)
+
+	// New runs are indexed from their experiment metadata when saved
+	run = &store.Run{ID: "NEW", Benchmark: "blast", Metrics: json.RawMessage(`{"experiment": {"server_version": "v0.10.0"}}`)}
+	require.NoError(t, db.Save(run))
+
+	run, err = db.Get("new")
+	require.NoError(t, err)
+	require.Equal(t, "v0.10.0", run.ServerVersion)
+	require.Empty(t, run.ServerID)
+}
+
+func TestBaseline(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "enbench.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	runs := []*store.Run{{Benchmark: "blast"}, {Benchmark: "blast"}, {Benchmark: "sustain"}}
+	for _, run := range runs {
+		require.NoError(t, db.Save(run))
+	}
+
+	_, err = db.Baseline("blast")
+	require.ErrorIs(t, err, store.ErrNoBaseline)
+
+	first := &store.Baseline{Benchmark: "blast", RunID: runs[0].ID, Provenance: map[string]string{"gated_against": ""}}
+	require.NoError(t, db.Promote(first))
+	require.Empty(t, first.Previous)
+	require.False(t, first.Promoted.IsZero())
+
+	second := &store.Baseline{Benchmark: "blast", RunID: runs[1].ID, Provenance: map[string]string{"gated_against": runs[0].ID}}
+	require.NoError(t, db.Promote(second))
+
+	baseline, err := db.Baseline("blast")
+	require.NoError(t, err)
+	require.Equal(t, runs[1].ID, baseline.RunID)
+	require.Equal(t, runs[0].ID, baseline.Previous)
+	require.Equal(t, map[string]string{"gated_against": runs[0].ID}, baseline.Provenance)
+
+	// Promoting the baseline again does not lose the run it replaced
+	require.NoError(t, db.Promote(&store.Baseline{Benchmark: "blast", RunID: runs[1].ID}))
+	baseline, err = db.Baseline("blast")
+	require.NoError(t, err)
+	require.Equal(t, runs[0].ID, baseline.Previous)
+	require.Nil(t, baseline.Provenance)
+
+	// Only stored runs of the benchmark can be promoted
+	require.ErrorIs(t, db.Promote(&store.Baseline{Benchmark: "blast", RunID: "notarunid"}), store.ErrNotFound)
+	require.Error(t, db.Promote(&store.Baseline{Benchmark: "blast", RunID: runs[2].ID}))
+
+	baseline, err = db.Baseline("blast")
+	require.NoError(t, err)
+	require.Equal(t, runs[1].ID, baseline.RunID)
+}
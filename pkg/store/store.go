@@ -0,0 +1,49 @@
+/*
+Package store manages the local history of benchmark runs so that results can be
+tracked across weeks or months of runs without managing loose JSON files.
+*/
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run is the record of a single benchmark execution kept by a results store.
+type Run struct {
+	ID        string            `json:"id"`
+	Benchmark string            `json:"benchmark"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Created   time.Time         `json:"created"`
+	Config    json.RawMessage   `json:"config,omitempty"`  // the options the benchmark was run with
+	Metrics   json.RawMessage   `json:"metrics,omitempty"` // the results reported by the benchmark
+
+	// The server the benchmark was run against, indexed so that runs can be binned by
+	// server release; populated from the experiment metadata when the run is saved.
+	ServerVersion string `json:"server_version,omitempty"`
+	ServerID      string `json:"server_id,omitempty"`
+}
+
+// Experiment parameters that are indexed by the results store.
+const (
+	ServerVersion = "server_version"
+	ServerID      = "server_id"
+)
+
+// LabelSet returns a canonical string representation of the run's labels, sorted by
+// key, so that runs with identical labels can be grouped together.
+func (r *Run) LabelSet() string {
+	keys := make([]string, 0, len(r.Labels))
+	for key := range r.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+r.Labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
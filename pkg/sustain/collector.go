@@ -0,0 +1,178 @@
+package sustain
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rs/zerolog/log"
+)
+
+// How often the collector checks the in-flight events for acks; the ack latencies are
+// measured when the ack is observed so this bounds their resolution.
+const collectPoll = time.Millisecond
+
+// The number of published events that can be queued for the collector before publishing
+// blocks, e.g. if the collector is slowed down by a very large in-flight backlog.
+const collectQueue = 4096
+
+// The collector resolves the acks and nacks of the published events in the background
+// so that the publisher is never blocked checking in-flight events and the publish
+// cadence is kept by the schedule. The collector goroutine owns the in-flight events
+// and records the measurements of the run as events are resolved; the measurements must
+// not be read until the collector has been stopped.
+type collector struct {
+	sustain  *Sustain
+	queue    chan *pending
+	probe    chan struct{} // signals the publisher to send a tail probe
+	inflight []*pending
+	count    atomic.Int64 // the in-flight events including queued events
+	acks     *stats.Latencies
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newCollector(b *Sustain) *collector {
+	c := &collector{
+		sustain:  b,
+		queue:    make(chan *pending, collectQueue),
+		probe:    make(chan struct{}, 1),
+		inflight: make([]*pending, 0),
+		acks:     &stats.Latencies{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Track an event that has been published until it is resolved.
+func (c *collector) Track(p *pending) {
+	c.count.Add(1)
+	c.queue <- p
+}
+
+// Inflight returns the number of published events that have not been resolved.
+func (c *collector) Inflight() int {
+	return int(c.count.Load())
+}
+
+// Stop the collector after checking the remaining in-flight events one more time and
+// wait for it to exit. Events that are still in-flight once the collector has stopped
+// can be read from the in-flight events. Stop can be called multiple times.
+func (c *collector) Stop() {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+func (c *collector) run() {
+	defer close(c.done)
+	poll := time.NewTicker(collectPoll)
+	defer poll.Stop()
+
+	for {
+		select {
+		case p := <-c.queue:
+			c.inflight = append(c.inflight, p)
+		case <-poll.C:
+			c.resolve()
+		case <-c.stop:
+			for {
+				select {
+				case p := <-c.queue:
+					c.inflight = append(c.inflight, p)
+				default:
+					c.resolve()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Checks all in-flight events for acks or nacks, removing any that have been resolved
+// by the server from the in-flight queue and recording the publish-to-ack latency of
+// acked events.
+func (c *collector) resolve() {
+	b := c.sustain
+	probe := false
+	pending := c.inflight[:0]
+	for _, p := range c.inflight {
+		// Acked and Nacked return the same error, e.g. the error of the nack, so the
+		// outcome of the event is only logged once it has been resolved.
+		event := p.event
+		var acked, nacked bool
+		var err error
+		if !p.failed {
+			if acked, err = event.Acked(); !acked {
+				nacked, err = event.Nacked()
+			}
+		}
+
+		if !acked && !nacked && !p.failed && err == nil {
+			pending = append(pending, p)
+			continue
+		}
+
+		switch {
+		case nacked:
+			log.Error().Err(err).Str("id", event.Metadata["local_id"]).Msg("event was nacked")
+		case err != nil:
+			log.Error().Err(err).Str("id", event.Metadata["local_id"]).Msg("could not get ack")
+		}
+		log.Debug().Bool("acked", acked).Bool("nacked", nacked).Str("id", event.Metadata["local_id"]).Msg("publish result")
+
+		sent := p.sent.Sub(b.started)
+		switch {
+		case p.probe:
+			b.tail.Resolve(sent, b.Clock.Since(b.started), acked)
+		case acked:
+			latency := b.Clock.Since(p.sent)
+			b.events++
+			b.series.Add(sent, latency)
+			if b.acked != nil {
+				b.acked.Add(sent, latency)
+			}
+			c.acks.Update(latency)
+			b.progress.Add("acks", 1)
+			if b.window != nil {
+				b.window.Latencies.Update(latency)
+			}
+
+			if b.tail != nil && b.tail.Observe(sent, sent+latency) {
+				probe = true
+			}
+		case nacked:
+			b.failures.Add(1)
+			b.progress.Add("nacks", 1)
+		}
+	}
+
+	// Clear references to resolved events so they can be garbage collected.
+	for i := len(pending); i < len(c.inflight); i++ {
+		c.inflight[i] = nil
+	}
+
+	resolved := len(c.inflight) - len(pending)
+	c.inflight = pending
+	c.count.Add(-int64(resolved))
+	b.progress.Set("inflight", uint64(c.Inflight()))
+
+	if b.window != nil {
+		for elapsed := b.Clock.Since(b.started); elapsed >= b.window.End; {
+			start := b.window.End
+			b.closeWindow(start)
+			b.window = b.newWindow(start)
+		}
+	}
+
+	// Probes are published by the publisher since streams do not allow concurrent sends
+	if probe {
+		select {
+		case c.probe <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package sustain
+
+import "errors"
+
+var (
+	errQuit = errors.New("sustain benchmark interrupted")
+)
@@ -3,24 +3,68 @@ package sustain
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
 	"github.com/rotationalio/go-ensign"
-	api "github.com/rotationalio/go-ensign/api/v1beta1"
-	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/proto"
 )
 
 type EventFactory func() *ensign.Event
 
+// NewEventFactory returns the event factory for the data size, payload or workload, and
+// metadata mode of the options along with the closer of the workload, which must be
+// closed once the events have been generated to release the file of a replayed workload.
+// An error is returned if the payload or workload cannot be created, e.g. if the file
+// of a replayed workload does not exist, rather than publishing a different workload.
+func NewEventFactory(opts *options.Options) (_ EventFactory, _ io.Closer, err error) {
+	if opts.Workload != "" {
+		var gen workload.Generator
+		if gen, err = workload.Get(opts.Workload, opts.Seed); err != nil {
+			return nil, nil, err
+		}
+		return makeWorkloadFactory(gen, opts.MinimalMetadata), workload.Closer(gen), nil
+	}
+
+	var payload workload.Payload
+	if payload, err = workload.NewPayload(opts.Payload, opts.Schema, opts.Seed); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.MinimalMetadata {
+		return makeMinimalEventFactory(int(opts.DataSize), payload), workload.Closer(nil), nil
+	}
+	return makeEventFactory(int(opts.DataSize), payload), workload.Closer(nil), nil
+}
+
+// SampleEvent returns an event generated by the event factory of the options, e.g. to
+// report the wire size of the events of a run, closing the factory once it is generated.
+func SampleEvent(opts *options.Options) (_ *ensign.Event, err error) {
+	var (
+		factory EventFactory
+		closer  io.Closer
+	)
+	if factory, closer, err = NewEventFactory(opts); err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return factory(), nil
+}
+
 func MakeEventFactory(size int) EventFactory {
+	return makeEventFactory(size, &workload.Random{})
+}
+
+// Creates events whose data is generated by the payload.
+func makeEventFactory(size int, payload workload.Payload) EventFactory {
 	count := uint64(0)
 	version := benchmarks.Version()
-	etype := &api.Type{
-		Name:         "Random",
-		MajorVersion: 1,
-	}
+	etype := payload.Type()
+	mime := payload.Mimetype()
 
 	entropy := ulid.Monotonic(rand.Reader, 0)
 	idgen := func() ulid.ULID {
@@ -35,14 +79,14 @@ func MakeEventFactory(size int) EventFactory {
 	return func() *ensign.Event {
 		count++
 		event := &ensign.Event{
-			Data: generateRandomBytes(size),
+			Data: payload.Next(size),
 			Metadata: map[string]string{
 				"app":      "enbench",
 				"counter":  fmt.Sprintf("%x", count),
 				"version":  version,
 				"local_id": idgen().String(),
 			},
-			Mimetype: mimetype.ApplicationOctetStream,
+			Mimetype: mime,
 			Type:     etype,
 			Created:  time.Now(),
 		}
@@ -50,10 +94,66 @@ func MakeEventFactory(size int) EventFactory {
 	}
 }
 
-func generateRandomBytes(n int) (b []byte) {
-	b = make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		panic(err)
+// MakeMinimalEventFactory creates events whose only metadata is the local ID that is
+// used to correlate acks and deliveries with published events.
+func MakeMinimalEventFactory(size int) EventFactory {
+	return makeMinimalEventFactory(size, &workload.Random{})
+}
+
+func makeMinimalEventFactory(size int, payload workload.Payload) EventFactory {
+	entropy := ulid.Monotonic(rand.Reader, 0)
+	etype := payload.Type()
+	mime := payload.Mimetype()
+
+	return func() *ensign.Event {
+		return &ensign.Event{
+			Data:     payload.Next(size),
+			Metadata: map[string]string{"local_id": ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()},
+			Mimetype: mime,
+			Type:     etype,
+			Created:  time.Now(),
+		}
 	}
-	return b
+}
+
+// Creates events from the events generated by the workload; the metadata of the generated
+// events is copied so that the benchmark metadata can be added without modifying it.
+func makeWorkloadFactory(gen workload.Generator, minimal bool) EventFactory {
+	count := uint64(0)
+	version := benchmarks.Version()
+	entropy := ulid.Monotonic(rand.Reader, 0)
+
+	return func() *ensign.Event {
+		generated, err := gen.Next().Unwrap()
+		if err != nil {
+			panic(err)
+		}
+
+		metadata := make(map[string]string, len(generated.Metadata)+4)
+		for key, val := range generated.Metadata {
+			metadata[key] = val
+		}
+
+		count++
+		metadata["local_id"] = ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
+		if !minimal {
+			metadata["app"] = "enbench"
+			metadata["counter"] = fmt.Sprintf("%x", count)
+			metadata["version"] = version
+		}
+
+		return &ensign.Event{
+			Data:     generated.Data,
+			Metadata: metadata,
+			Mimetype: generated.Mimetype,
+			Type:     generated.Type,
+			Created:  time.Now(),
+		}
+	}
+}
+
+// WireSize returns the size in bytes of the serialized event that is stored by Ensign,
+// including the metadata and other event fields in addition to the payload.
+func WireSize(event *ensign.Event) int {
+	return proto.Size(event.Proto())
 }
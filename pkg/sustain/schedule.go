@@ -0,0 +1,86 @@
+package sustain
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+)
+
+// Schedules the events of a sustain run. Without jitter events are published on a
+// regular ticker; with jitter each interval is sampled from the jitter distribution
+// and added to the scheduled time of the previous event rather than to the time that it
+// was published, so that the arrivals are open loop and slow publishes do not lower the
+// offered rate.
+type schedule struct {
+	ticker   *time.Ticker
+	timer    *time.Timer
+	next     time.Time
+	interval time.Duration
+	jitter   options.Jitter
+	rnd      *rand.Rand
+	pending  time.Duration // the interval before the next event
+	gaps     *stats.Latencies
+}
+
+func newSchedule(interval time.Duration, jitter options.Jitter, seed int64) *schedule {
+	if !jitter.Enabled() {
+		return &schedule{ticker: time.NewTicker(interval)}
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	s := &schedule{
+		next:     time.Now(),
+		interval: interval,
+		jitter:   jitter,
+		rnd:      rand.New(rand.NewSource(seed)),
+		gaps:     &stats.Latencies{},
+	}
+	s.timer = time.NewTimer(s.gap())
+	return s
+}
+
+// C returns the channel on which the time of the next event is delivered.
+func (s *schedule) C() <-chan time.Time {
+	if s.ticker != nil {
+		return s.ticker.C
+	}
+	return s.timer.C
+}
+
+// Advance schedules the next event once the current event has been delivered; events
+// that are already overdue are delivered immediately.
+func (s *schedule) Advance() {
+	if s.timer != nil {
+		s.gaps.Update(s.pending)
+		s.timer.Reset(s.gap())
+	}
+}
+
+// Samples the next interval, returning the time until the next event is due.
+func (s *schedule) gap() time.Duration {
+	// A zero interval would be recorded as a timeout by the latencies
+	gap := s.jitter.Next(s.rnd, s.interval)
+	if gap <= 0 {
+		gap = time.Nanosecond
+	}
+
+	s.pending = gap
+	s.next = s.next.Add(gap)
+	if wait := time.Until(s.next); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (s *schedule) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	} else {
+		s.timer.Stop()
+	}
+}
@@ -2,11 +2,21 @@ package sustain
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"time"
 
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/chaos"
+	"github.com/rotationalio/ensign-benchmarks/pkg/clock"
+	"github.com/rotationalio/ensign-benchmarks/pkg/identity"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
 	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
 	"github.com/rotationalio/go-ensign"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -21,14 +31,106 @@ func init() {
 	log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 }
 
+// How often the in-flight events are counted while publishing is paused.
+const backoffPoll = 10 * time.Millisecond
+
+// DefaultDrainTimeout is how long to wait for outstanding acks after publishing stops.
+const DefaultDrainTimeout = 10 * time.Second
+
 // Sustain runs a benchmark that continuously sends events at the server until stopped.
 type Sustain struct {
-	opts   *options.Options
-	client *ensign.Client
+	opts      *options.Options
+	conn      *benchmarks.EnsignClient
+	client    *ensign.Client // the connected client of conn
+	quit      chan os.Signal // interrupts the run when signaled or stopped
+	collector *collector
+	backoffs  uint64
+	inBackoff time.Duration
+	published uint64
+	wire      uint64 // the total serialized size of the published events
+	events    uint64
+	failures  atomic.Uint64 // nacked events and events that could not be published
+	series    *stats.Series
+	acked     *stats.Series    // the acked events only, to compare around token refreshes
+	publishes *stats.Latencies // the time spent publishing each event
+	started   time.Time
+	duration  time.Duration
+	sending   time.Duration
+	reason    string
+	budget    *workload.Budget
+	warmup    stats.WarmupPhase
+	progress  stats.Progress
+	tail      *stats.Tail
+	probes    EventFactory
+	gaps      *stats.Latencies // the scheduled intervals between events
+	window    *stats.Window    // the window streamed to OnWindow when it closes
+
+	// DrainTimeout is how long to wait for outstanding acks after publishing stops;
+	// events that are not acked before the timeout are recorded as timeouts.
+	DrainTimeout time.Duration
+
+	// Tokens authenticates the client if set so that access token refreshes during the
+	// run are recorded; the credentials are checked with a status RPC every TokenCheck
+	// and events sent within the RefreshWindow of a refresh are compared to the others.
+	Tokens        *identity.TokenMonitor
+	TokenCheck    time.Duration
+	RefreshWindow time.Duration
+
+	// Chaos injects faults into the publish stream of the client if set so that the
+	// latency and recovery time of the client can be measured when the stream is slow,
+	// loses events, or is disconnected. Dropped events are recorded as timeouts.
+	Chaos *chaos.Injector
+
+	// OnWindow is called as each window of the configured width closes while the run
+	// progresses, e.g. to stream the time series to disk so that a long run that
+	// crashes does not lose its measurements. Unlike the windows of the results, events
+	// are assigned to the window in which they were resolved rather than sent, so the
+	// timeouts of the events that were never acked are included in the last window.
+	OnWindow func(*stats.Window)
+
+	// Clock timestamps the events of the run and computes their latencies; by default
+	// the host clock, it can be replaced by a fake clock in tests. Events are still
+	// scheduled and drained in real time.
+	Clock clock.Clock
+}
+
+// An event that has been published but not yet acked or nacked by the server.
+type pending struct {
+	event  *ensign.Event
+	sent   time.Time
+	probe  bool // true if the event is a tail probe rather than part of the workload
+	failed bool // true if the probe could not be published, resolving it immediately
 }
 
+// Sustain can be run by the generic benchmark harness.
+var _ benchmarks.Benchmark = &Sustain{}
+
 func New(opts *options.Options) *Sustain {
-	return &Sustain{opts: opts}
+	b := &Sustain{
+		opts:          opts,
+		quit:          make(chan os.Signal, 1),
+		DrainTimeout:  DefaultDrainTimeout,
+		TokenCheck:    DefaultTokenCheck,
+		RefreshWindow: DefaultRefreshWindow,
+		Clock:         clock.Real,
+	}
+
+	b.conn = benchmarks.NewEnsignClient(func() (*ensign.Client, error) {
+		opts := b.opts.Ensign()
+		if b.Tokens != nil {
+			opts = append(opts, b.Tokens.Option())
+		}
+
+		if b.Chaos != nil {
+			opts = append(opts, b.Chaos.Option())
+		}
+		return ensign.New(opts...)
+	})
+	return b
+}
+
+func (b *Sustain) String() string {
+	return "sustain"
 }
 
 // Note: this is prototype trash-pumpkin code.
@@ -38,35 +140,142 @@ func (b *Sustain) Run(ctx context.Context) (err error) {
 	}
 	defer b.Close()
 
-	quit := make(chan os.Signal, 1)
+	// Discard a stop that was requested after the previous run was over
+	select {
+	case <-b.quit:
+	default:
+	}
+
+	quit := b.quit
 	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+
+	// Ensure the client can generate events at the requested rate before publishing
+	var (
+		warmup EventFactory
+		closer io.Closer
+	)
+	if warmup, closer, err = NewEventFactory(b.opts); err != nil {
+		return err
+	}
+	b.budget = workload.MeasureBudget(workload.DefaultWarmup, float64(time.Second)/float64(b.opts.Interval), func() { warmup() })
+	closer.Close()
+	b.budget.Check()
+
+	// Warm up the connection and the server before the measurement starts
+	if err = b.warmUp(ctx); err != nil {
+		b.reason = benchmarks.ExitCanceled
+		return fmt.Errorf("warmup failed: %w", err)
+	}
+
+	var jitter options.Jitter
+	if jitter, err = options.ParseJitter(b.opts.Jitter); err != nil {
+		return err
+	}
 
 	N := b.opts.Operations
 	nevents := uint64(0)
-	ticker := time.NewTicker(b.opts.Interval)
-	factory := MakeEventFactory(int(b.opts.DataSize))
+
+	var factory EventFactory
+	if factory, closer, err = NewEventFactory(b.opts); err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	b.backoffs = 0
+	b.inBackoff = 0
+	b.published, b.wire = 0, 0
+	b.events = 0
+	b.failures.Store(0)
+	b.series, b.acked = stats.NewSeries(b.opts.Warmup, b.opts.Cooldown), nil
+	if b.Tokens != nil {
+		b.acked = stats.NewSeries(0, 0)
+	}
+	b.publishes = &stats.Latencies{}
+	b.reason = benchmarks.ExitCompleted
+
+	// Tail probes are not part of the workload so they are generated separately
+	b.tail, b.probes = nil, nil
+	if b.opts.TailThreshold > 0 {
+		b.tail = stats.NewTail(b.opts.TailThreshold)
+		if b.probes, closer, err = NewEventFactory(b.opts); err != nil {
+			return err
+		}
+		defer closer.Close()
+	}
+
+	b.progress.SetClock(b.Clock)
+	b.progress.Start()
+	b.started = b.Clock.Now()
+	b.window = nil
+	if b.OnWindow != nil {
+		b.window = b.newWindow(0)
+	}
+
+	defer func() {
+		b.duration = b.Clock.Since(b.started)
+		b.series.Close(b.sending)
+		if b.window != nil {
+			b.closeWindow(b.duration)
+			b.window = nil
+		}
+	}()
+
+	// Acks are resolved in the background so that publishing keeps to the schedule; the
+	// collector must be stopped before the measurements of the run are read.
+	b.collector = newCollector(b)
+	defer b.collector.Stop()
+
+	ticker := newSchedule(b.opts.Interval, jitter, b.opts.Seed)
+	b.gaps = ticker.gaps
+	defer ticker.Stop()
+
+	// Expired access tokens are only refreshed when the client makes an RPC
+	if b.Tokens != nil {
+		pctx, cancel := context.WithCancel(ctx)
+		polling := b.pollTokens(pctx)
+		defer func() {
+			cancel()
+			<-polling
+		}()
+	}
 
 sustain:
 	for {
 		select {
-		case <-ticker.C:
-			event := factory()
-			b.client.Publish(b.opts.Topic, event)
-			log.Info().Str("count", event.Metadata["counter"]).Str("id", event.Metadata["local_id"]).Msg("event published")
+		case <-ticker.C():
+			ticker.Advance()
 
-			// Wait for the event to be acked
-			var acked bool
-			if acked, err = event.Acked(); err != nil {
-				log.Error().Err(err).Msg("could not get ack")
+			// If too many events are waiting for acks, pause until the backlog drains
+			if b.opts.Backoff > 0 && uint64(b.collector.Inflight()) > b.opts.Backoff {
+				if err = b.backoff(ctx, quit); err != nil {
+					if err == errQuit {
+						b.reason = benchmarks.ExitInterrupted
+						break sustain
+					}
+					b.reason = benchmarks.ExitCanceled
+					return err
+				}
 			}
 
-			var nacked bool
-			if !acked {
-				if nacked, err = event.Nacked(); err != nil {
-					log.Error().Err(err).Msg("event was nacked")
-				}
+			// An event that could not be published will never be acked so it is counted
+			// as a failure rather than tracked until it times out.
+			event := factory()
+			publishing := b.Clock.Now()
+			if perr := b.client.Publish(b.opts.TopicRef(), event); perr != nil {
+				b.failures.Add(1)
+				b.progress.Add("errors", 1)
+				log.Debug().Err(perr).Str("id", event.Metadata["local_id"]).Msg("could not publish event")
+			} else {
+				sent := b.Clock.Now()
+				b.publishes.Update(sent.Sub(publishing))
+				b.collector.Track(&pending{event: event, sent: sent})
+				b.published += uint64(len(event.Data))
+				b.wire += uint64(WireSize(event))
+				b.progress.Add("published", 1)
+				b.progress.Add("bytes", uint64(len(event.Data)))
+				log.Debug().Str("count", event.Metadata["counter"]).Str("id", event.Metadata["local_id"]).Msg("event published")
 			}
-			log.Debug().Bool("acked", acked).Bool("nacked", nacked).Msg("publish result")
 
 			// Check exit criteria
 			nevents++
@@ -76,33 +285,301 @@ sustain:
 				}
 			}
 
+			if b.opts.MaxBytes > 0 && b.published >= b.opts.MaxBytes {
+				b.reason = benchmarks.ExitMaxBytes
+				break sustain
+			}
+
+			if reason := b.opts.ExhaustedAfter(b.Clock.Since(b.started), nevents); reason != "" {
+				b.reason = reason
+				break sustain
+			}
+
+		case <-b.collector.probe:
+			b.reprobe()
+
 		case <-quit:
+			b.reason = benchmarks.ExitInterrupted
 			break sustain
 
 		case <-ctx.Done():
+			b.reason = benchmarks.ExitCanceled
 			return ctx.Err()
 		}
 	}
+	ticker.Stop()
+	b.sending = b.Clock.Since(b.started)
+
+	// Wait for the outstanding acks so the latest events are included in the results;
+	// any events that are still in-flight after the timeout are recorded as timeouts.
+	if err = b.await(ctx, quit, time.After(b.DrainTimeout)); err != nil && err != errQuit {
+		b.reason = benchmarks.ExitCanceled
+		return err
+	}
+
+	b.collector.Stop()
+	for _, p := range b.collector.inflight {
+		if p.probe {
+			b.tail.Resolve(p.sent.Sub(b.started), 0, false)
+			continue
+		}
+		b.series.Add(p.sent.Sub(b.started), 0)
+		if b.window != nil {
+			b.window.Latencies.Update(0)
+		}
+	}
+	return nil
+}
+
+// Returns an empty window of the configured width that begins at the offset.
+func (b *Sustain) newWindow(start time.Duration) *stats.Window {
+	width := b.opts.Window
+	if width <= 0 {
+		width = stats.DefaultWindow
+	}
+	return &stats.Window{Start: start, End: start + width, Latencies: &stats.Latencies{}}
+}
+
+// Truncates the current window to the offset and passes it to OnWindow.
+func (b *Sustain) closeWindow(end time.Duration) {
+	b.window.End = end
+	b.window.Latencies.SetDuration(end - b.window.Start)
+	b.OnWindow(b.window)
+}
+
+// Publishes a tail probe immediately after a slow event has been acked to find out if
+// the slowness persists; probes are not included in the latencies of the workload.
+func (b *Sustain) reprobe() {
+	event := b.probes()
+	if event.Metadata != nil {
+		event.Metadata["probe"] = "true"
+	}
 
+	// The collector owns the tail so a probe that could not be published is resolved
+	// as failed by the collector rather than here.
+	var failed bool
+	if err := b.client.Publish(b.opts.TopicRef(), event); err != nil {
+		failed = true
+		b.progress.Add("errors", 1)
+		log.Debug().Err(err).Msg("could not publish tail probe")
+	}
+	b.collector.Track(&pending{event: event, sent: b.Clock.Now(), probe: true, failed: failed})
+	b.progress.Add("probes", 1)
+}
+
+// Blocks publishing until all in-flight events have been resolved, recording the
+// amount of time spent in backoff. Returns errQuit if interrupted.
+func (b *Sustain) backoff(ctx context.Context, quit <-chan os.Signal) error {
+	b.backoffs++
+	b.progress.Add("backoffs", 1)
+	started := b.Clock.Now()
+	defer func() {
+		b.inBackoff += b.Clock.Since(started)
+	}()
+
+	log.Warn().Int("inflight", b.collector.Inflight()).Uint64("threshold", b.opts.Backoff).Msg("backing off until in-flight events are acked")
+
+	if err := b.await(ctx, quit, nil); err != nil {
+		return err
+	}
+
+	log.Info().Dur("backoff", b.Clock.Since(started)).Msg("in-flight backlog drained, resuming publishing")
 	return nil
 }
 
+// Blocks until all in-flight events have been resolved by the collector or the timeout
+// channel fires; a nil timeout waits indefinitely. Tail probes are still published while
+// waiting. Returns errQuit if interrupted.
+func (b *Sustain) await(ctx context.Context, quit <-chan os.Signal, timeout <-chan time.Time) error {
+	poll := time.NewTicker(backoffPoll)
+	defer poll.Stop()
+
+	for b.collector.Inflight() > 0 {
+		select {
+		case <-poll.C:
+		case <-b.collector.probe:
+			b.reprobe()
+		case <-timeout:
+			log.Warn().Int("inflight", b.collector.Inflight()).Msg("sustain drain timeout exceeded")
+			return nil
+		case <-quit:
+			return errQuit
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Budget returns the event generation capacity of the client measured during warmup.
+func (b *Sustain) Budget() *workload.Budget {
+	return b.budget
+}
+
+func (b *Sustain) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["events"] = b.events
+	results["failures"] = b.failures.Load()
+
+	latencies := b.Latencies()
+	results["latencies"] = latencies
+	results["normalized"] = stats.Normalize(latencies, b.opts.DataSize)
+	results["phases"] = b.series.Phases(b.opts.Phases, b.sending)
+	if b.collector != nil {
+		results["ack_latencies"] = b.collector.acks
+	}
+	if b.publishes != nil {
+		results["publish_latencies"] = b.publishes
+	}
+	results["windows"] = b.Windows()
+	if b.gaps != nil {
+		results["arrivals"] = b.gaps
+	}
+	results["exit_reason"] = b.reason
+	if b.opts.Warming() {
+		results["warmup"] = &b.warmup
+	}
+	if b.tail != nil {
+		results["tail"] = b.tail
+	}
+	if b.Tokens != nil {
+		results["token_refresh"] = b.TokenRefreshes()
+	}
+	if b.Chaos != nil {
+		results["chaos"] = b.Chaos
+	}
+
+	// Backpressure from the server is reported as time that publishing was paused
+	results["backoffs"] = b.backoffs
+	results["time_in_backoff"] = b.inBackoff.String()
+	results["bandwidth"] = b.Bandwidth()
+	if n := b.progress.Get("published"); n > 0 {
+		results["wire_size"] = float64(b.wire) / float64(n)
+	}
+
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"operations":       b.opts.Operations,
+		"data_size":        b.opts.DataSize,
+		"interval":         b.opts.Interval.String(),
+		"jitter":           b.opts.Jitter,
+		"backoff":          b.opts.Backoff,
+		"max_bytes":        b.opts.MaxBytes,
+		"guard":            b.opts.Guard(),
+		"generation":       b.budget,
+		"payload":          b.opts.Payload,
+		"schema":           b.opts.Schema,
+		"minimal_metadata": b.opts.MinimalMetadata,
+		"warmup_events":    b.opts.WarmupEvents,
+		"warmup_duration":  b.opts.WarmupDuration.String(),
+		"window":           b.opts.Window.String(),
+		"tail_threshold":   b.opts.TailThreshold.String(),
+		"token_check":      b.TokenCheck.String(),
+		"refresh_window":   b.RefreshWindow.String(),
+	}
+	return results, nil
+}
+
+// Latencies returns the distribution of publish-to-ack latencies from the last run,
+// excluding the events published during the warmup and cooldown of the run.
+func (b *Sustain) Latencies() *stats.Latencies {
+	if b.series == nil {
+		return &stats.Latencies{}
+	}
+	return b.series.Latencies(b.duration)
+}
+
+// Bandwidth returns the serialized bytes of the events published by the last run over
+// the time spent publishing them.
+func (b *Sustain) Bandwidth() *stats.Bandwidth {
+	bandwidth := &stats.Bandwidth{}
+	bandwidth.Sent(b.wire)
+	bandwidth.SetDuration(b.sending)
+	return bandwidth
+}
+
+// Windows returns the time series of the publish-to-ack latencies from the last run in
+// windows of the configured width, including the warmup and cooldown of the run.
+func (b *Sustain) Windows() []*stats.Window {
+	if b.series == nil {
+		return stats.SplitWindows(b.opts.Window, b.sending, nil, nil)
+	}
+	return b.series.Windows(b.opts.Window, b.sending)
+}
+
+// Progress returns the number of events published and resolved so far while the
+// benchmark runs.
+func (b *Sustain) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
 func (b *Sustain) Prepare(ctx context.Context) (err error) {
-	// Initialize the client
-	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+	// Initialize the client unless it was connected by the benchmark harness
+	if err = b.conn.Connect(); err != nil {
 		return err
 	}
+	b.client = b.conn.Ensign()
+	return nil
+}
+
+// Stop interrupts the run as if it received an interrupt signal: publishing stops and
+// the outstanding acks are drained before Run returns. Stop does not wait for the run
+// to return; stopping again while the acks are drained stops waiting for them.
+func (b *Sustain) Stop(context.Context) error {
+	select {
+	case b.quit <- os.Interrupt:
+	default:
+	}
 	return nil
 }
 
+// Client returns the client of the benchmark, which is connected when the run is
+// prepared if it has not been connected by the benchmark harness.
+func (b *Sustain) Client() benchmarks.Client {
+	return b.conn
+}
+
+// Workload returns the events of the configured payload or workload to inspect or export
+// them; the run generates its own events so the workload must be prepared and released
+// by the caller.
+func (b *Sustain) Workload() benchmarks.Workload {
+	name := b.opts.Workload
+	if name == "" {
+		if name = b.opts.Payload; name == "" {
+			name = "random"
+		}
+	}
+
+	return &benchmarks.Events[*ensign.Event]{
+		Name: name,
+		N:    b.opts.Operations,
+		Factory: func() (func() *ensign.Event, io.Closer, error) {
+			return NewEventFactory(b.opts)
+		},
+	}
+}
+
 func (b *Sustain) Close() {
 	defer func() {
 		b.client = nil
 	}()
 
-	if err := b.client.Close(); err != nil {
+	var inflight int
+	if b.collector != nil {
+		inflight = b.collector.Inflight()
+	}
+
+	if err := b.conn.Close(); err != nil {
 		log.Error().Err(err).Msg("could not close ensign client")
 	}
 
-	log.Info().Msg("sustain benchmark closed")
+	log.Info().
+		Uint64("backoffs", b.backoffs).
+		Dur("time_in_backoff", b.inBackoff).
+		Int("inflight", inflight).
+		Uint64("published_bytes", b.published).
+		Str("exit_reason", b.reason).
+		Msg("sustain benchmark closed")
 }
@@ -0,0 +1,124 @@
+package sustain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+)
+
+// Defaults for monitoring access token refreshes during a sustain run.
+const (
+	DefaultTokenCheck    = 10 * time.Second
+	DefaultRefreshWindow = time.Second
+)
+
+// TokenRefreshes compares the latency of the events published around access token
+// refreshes with the latency of the other events of the run, to determine if refreshing
+// the credentials of a long running publisher has an observable impact on publishing.
+type TokenRefreshes struct {
+	Refreshes []time.Duration  // the offsets of the refreshes from the start of the run
+	Blocked   *stats.Latencies // how long each refresh blocked the RPC that triggered it
+	Adjacent  *stats.Latencies // events sent within the refresh window of a refresh
+	Baseline  *stats.Latencies // events sent outside of the refresh window of every refresh
+	Window    time.Duration
+	Lifetime  time.Duration // the lifetime of the access tokens issued by Quarterdeck
+	Failures  uint64        // the number of RPCs that could not obtain credentials
+}
+
+// Checks the credentials with status RPCs until the context is canceled; the returned
+// channel is closed once polling has stopped.
+func (b *Sustain) pollTokens(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Tokens.Poll(ctx, b.client, b.TokenCheck)
+	}()
+	return done
+}
+
+// TokenRefreshes returns the access token refreshes observed during the last run and the latency
+// of the events published around them, or nil if tokens were not monitored. An event is
+// adjacent to a refresh if it was sent within the refresh window before the refresh
+// started or after it completed. Events are classified by the windows of the series of
+// acked events, so an event within a series window of a refresh window is adjacent.
+// Timeouts are not assigned to either distribution.
+func (b *Sustain) TokenRefreshes() *TokenRefreshes {
+	if b.Tokens == nil {
+		return nil
+	}
+
+	refreshes := &TokenRefreshes{
+		Refreshes: make([]time.Duration, 0),
+		Blocked:   &stats.Latencies{},
+		Adjacent:  &stats.Latencies{},
+		Baseline:  &stats.Latencies{},
+		Window:    b.RefreshWindow,
+		Failures:  b.Tokens.Failures(),
+	}
+	_, refreshes.Lifetime = b.Tokens.Expires()
+
+	type span struct{ start, end time.Duration }
+	spans := make([]span, 0)
+	for _, refresh := range b.Tokens.Refreshes() {
+		if refresh.At.Before(b.started) {
+			continue
+		}
+
+		offset := refresh.At.Sub(b.started)
+		refreshes.Refreshes = append(refreshes.Refreshes, offset)
+		refreshes.Blocked.Update(refresh.Duration)
+		spans = append(spans, span{offset - b.RefreshWindow, offset + refresh.Duration + b.RefreshWindow})
+	}
+
+	if b.acked == nil {
+		return refreshes
+	}
+
+	for _, window := range b.acked.Windows(b.acked.Width(), b.duration) {
+		adjacent := false
+		for _, s := range spans {
+			if window.Start <= s.end && window.End > s.start {
+				adjacent = true
+				break
+			}
+		}
+
+		if adjacent {
+			refreshes.Adjacent.Append(window.Latencies)
+		} else {
+			refreshes.Baseline.Append(window.Latencies)
+		}
+	}
+	return refreshes
+}
+
+// Impact returns the difference of the median latency of the events adjacent to refreshes
+// and the median latency of the other events; zero if either has no events.
+func (t *TokenRefreshes) Impact() time.Duration {
+	if t.Adjacent.N() == 0 || t.Baseline.N() == 0 {
+		return 0
+	}
+	return t.Adjacent.Percentile(50) - t.Baseline.Percentile(50)
+}
+
+// Serializes the token refreshes into a JSON map with durations as strings.
+func (t *TokenRefreshes) MarshalJSON() ([]byte, error) {
+	offsets := make([]string, 0, len(t.Refreshes))
+	for _, offset := range t.Refreshes {
+		offsets = append(offsets, offset.String())
+	}
+
+	data := make(map[string]interface{})
+	data["refreshes"] = len(t.Refreshes)
+	data["offsets"] = offsets
+	data["blocked"] = t.Blocked
+	data["adjacent_latencies"] = t.Adjacent
+	data["baseline_latencies"] = t.Baseline
+	data["p50_impact"] = t.Impact().String()
+	data["window"] = t.Window.String()
+	data["token_lifetime"] = t.Lifetime.String()
+	data["failures"] = t.Failures
+	return json.Marshal(data)
+}
@@ -0,0 +1,89 @@
+package sustain
+
+import (
+	"context"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	"github.com/rs/zerolog/log"
+)
+
+// The number of warmup events published before waiting for them to be acked.
+const warmupBatch = 100
+
+// Publishes events as fast as possible in batches until the warmup event count or
+// duration is reached so that connection setup and server-side warm-up are not
+// included in the measurements. Each batch is resolved before the next is published so
+// that no warmup events are in flight when the benchmark starts; if a batch is not
+// resolved within the drain timeout the warmup ends early.
+func (b *Sustain) warmUp(ctx context.Context) (err error) {
+	b.warmup = stats.WarmupPhase{}
+	if !b.opts.Warming() {
+		return nil
+	}
+
+	factory, closer, err := NewEventFactory(b.opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	poll := time.NewTicker(backoffPoll)
+	defer poll.Stop()
+
+	started := b.Clock.Now()
+	defer func() {
+		b.warmup.Duration = b.Clock.Since(started)
+	}()
+
+	for !b.opts.WarmedUpAfter(b.Clock.Since(started), b.warmup.Events) {
+		batch := uint64(warmupBatch)
+		if b.opts.WarmupEvents > 0 && b.opts.WarmupEvents-b.warmup.Events < batch {
+			batch = b.opts.WarmupEvents - b.warmup.Events
+		}
+
+		inflight := make([]*ensign.Event, 0, batch)
+		for i := uint64(0); i < batch; i++ {
+			event := factory()
+			if err = b.client.Publish(b.opts.TopicRef(), event); err != nil {
+				return err
+			}
+			inflight = append(inflight, event)
+			b.warmup.Events++
+		}
+
+		timeout := time.After(b.DrainTimeout)
+		for len(inflight) > 0 {
+			select {
+			case <-poll.C:
+				pending := inflight[:0]
+				for _, event := range inflight {
+					if acked, _ := event.Acked(); acked {
+						b.warmup.Ack(true)
+						continue
+					}
+
+					if nacked, _ := event.Nacked(); nacked {
+						b.warmup.Ack(false)
+						continue
+					}
+					pending = append(pending, event)
+				}
+				inflight = pending
+			case <-timeout:
+				log.Warn().Int("inflight", len(inflight)).Msg("sustain warmup events were not acked before the drain timeout")
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	log.Info().
+		Uint64("events", b.warmup.Events).
+		Uint64("nacks", b.warmup.Nacked).
+		Dur("duration", b.Clock.Since(started)).
+		Msg("sustain warmup complete")
+	return nil
+}
@@ -0,0 +1,410 @@
+/*
+Package teardown implements a micro-benchmark of publish stream cancellation. Each trial
+opens a publish stream, sends a burst of events and cancels the context of the stream
+while the acks for the burst are still in flight, measuring how long the client takes
+to tear the stream down and how many of the events sent before the cancellation were
+acked by the server or committed to the topic without the client receiving an ack. The
+results inform the timeouts and retry strategies recommended for SDK users.
+*/
+package teardown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	benchmarks "github.com/rotationalio/ensign-benchmarks/pkg"
+	"github.com/rotationalio/ensign-benchmarks/pkg/blast"
+	"github.com/rotationalio/ensign-benchmarks/pkg/metrics"
+	"github.com/rotationalio/ensign-benchmarks/pkg/options"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults for the cancellation benchmark.
+const (
+	DefaultTrials = 10
+	DefaultBurst  = 100
+	DefaultGrace  = 5 * time.Second
+	DefaultSettle = time.Second
+)
+
+// Teardown cancels publish streams mid-stream and measures how the client and server
+// react to the cancellation.
+type Teardown struct {
+	Trials int           // the number of streams to open and cancel
+	Burst  uint64        // the number of events sent on each stream before it is canceled
+	Delay  time.Duration // how long to wait after the burst is sent before canceling
+	Grace  time.Duration // how long to wait for the stream to tear down after canceling
+	Settle time.Duration // how long to wait for commits before checking the topic
+
+	opts     *options.Options
+	client   *ensign.Client
+	topicID  ulid.ULID
+	trials   []*Trial
+	duration time.Duration
+	reason   string
+	progress stats.Progress
+}
+
+// Trial records the outcome of canceling a single publish stream.
+type Trial struct {
+	Sent         uint64        // the number of events sent before the stream was canceled
+	AckedBefore  uint64        // the number of acks received before the stream was canceled
+	Acked        uint64        // the number of acks received before the stream was torn down
+	Nacked       uint64        // the number of nacks received before the stream was torn down
+	Unresolved   uint64        // the number of sent events without a reply at teardown
+	Committed    uint64        // the number of events added to the topic during the trial
+	Verified     bool          // true if the committed events could be read from the topic info
+	Teardown     time.Duration // the time from the cancellation until the stream returned
+	TornDown     bool          // false if the stream did not return within the grace period
+	Code         string        // the status code returned by the stream after the cancellation
+	SendDuration time.Duration // the time taken to send the burst
+}
+
+// Serializes the trial into a JSON map with durations as strings.
+func (t *Trial) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["sent"] = t.Sent
+	data["acked_before_cancel"] = t.AckedBefore
+	data["acked"] = t.Acked
+	data["nacked"] = t.Nacked
+	data["unresolved"] = t.Unresolved
+	data["torn_down"] = t.TornDown
+	data["teardown"] = t.Teardown.String()
+	data["send_duration"] = t.SendDuration.String()
+	data["code"] = t.Code
+
+	if t.Verified {
+		data["committed"] = t.Committed
+		data["committed_unacked"] = t.CommittedUnacked()
+	}
+	return json.Marshal(data)
+}
+
+// CommittedUnacked returns the number of events that were committed to the topic but
+// whose ack was never received by the client; a client that retries the publish of
+// unacked events after a cancellation would duplicate these events.
+func (t *Trial) CommittedUnacked() uint64 {
+	if t.Committed > t.Acked {
+		return t.Committed - t.Acked
+	}
+	return 0
+}
+
+func New(opts *options.Options) *Teardown {
+	return &Teardown{
+		Trials: DefaultTrials,
+		Burst:  DefaultBurst,
+		Grace:  DefaultGrace,
+		Settle: DefaultSettle,
+		opts:   opts,
+	}
+}
+
+func (b *Teardown) Run(ctx context.Context) (err error) {
+	if b.Trials < 1 || b.Burst < 1 {
+		return errors.New("the number of trials and the burst size must be positive")
+	}
+
+	if b.Grace <= 0 {
+		return errors.New("the teardown grace period must be positive")
+	}
+
+	if b.client, err = ensign.New(b.opts.Ensign()...); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	id := b.opts.TopicID
+	if id == "" {
+		if id, err = b.client.TopicID(ctx, b.opts.Topic); err != nil {
+			return err
+		}
+	}
+
+	if b.topicID, err = ulid.Parse(id); err != nil {
+		return err
+	}
+
+	b.trials = make([]*Trial, 0, b.Trials)
+	b.reason = benchmarks.ExitCompleted
+
+	started := time.Now()
+	b.progress.Start()
+	defer func() {
+		b.duration = time.Since(started)
+	}()
+
+	sent := uint64(0)
+	for i := 0; i < b.Trials; i++ {
+		if reason := b.opts.Exhausted(started, sent); reason != "" {
+			b.reason = reason
+			break
+		}
+
+		var trial *Trial
+		if trial, err = b.trial(ctx); err != nil {
+			if ctx.Err() != nil {
+				b.reason = benchmarks.ExitCanceled
+			}
+			return fmt.Errorf("trial %d: %w", i, err)
+		}
+		b.trials = append(b.trials, trial)
+		sent += trial.Sent
+		b.progress.Add("trials", 1)
+		b.progress.Add("sent", trial.Sent)
+		b.progress.Add("acked", trial.Acked)
+
+		log.Debug().
+			Int("trial", i).
+			Uint64("sent", trial.Sent).
+			Uint64("acked", trial.Acked).
+			Dur("teardown", trial.Teardown).
+			Str("code", trial.Code).
+			Msg("publish stream canceled")
+	}
+
+	log.Info().Int("trials", len(b.trials)).Msg("cancellation benchmark complete")
+	return nil
+}
+
+// Opens a publish stream, sends the burst and cancels the stream while the acks of the
+// burst are in flight, then waits for the stream to tear down.
+func (b *Teardown) trial(parent context.Context) (trial *Trial, err error) {
+	trial = &Trial{}
+	var before uint64
+	if before, err = b.events(parent); err == nil {
+		trial.Verified = true
+	} else {
+		log.Warn().Err(err).Msg("could not read topic info, committed events will not be verified")
+	}
+
+	var factory blast.EventFactory
+	if factory, err = blast.NewEventFactory(b.opts, b.topicID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var stream api.Ensign_PublishClient
+	if stream, err = b.open(ctx); err != nil {
+		return nil, err
+	}
+
+	// Receive replies until the stream returns an error after it is canceled
+	var acked, nacked atomic.Uint64
+	var recvErr error
+	var returned time.Time
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			rep, err := stream.Recv()
+			if err != nil {
+				recvErr, returned = err, time.Now()
+				return
+			}
+
+			switch {
+			case rep.GetAck() != nil:
+				acked.Add(1)
+			case rep.GetNack() != nil:
+				nacked.Add(1)
+			}
+		}
+	}()
+
+	sending := time.Now()
+	for ; trial.Sent < b.Burst; trial.Sent++ {
+		req := &api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: factory()}}
+		if err = stream.Send(req); err != nil {
+			cancel()
+			<-done
+			return nil, fmt.Errorf("send %d: %w", trial.Sent, err)
+		}
+	}
+	trial.SendDuration = time.Since(sending)
+
+	if b.Delay > 0 {
+		time.Sleep(b.Delay)
+	}
+
+	// The stream must still be open when it is canceled to measure the teardown
+	select {
+	case <-done:
+		return nil, fmt.Errorf("publish stream closed before it was canceled: %w", recvErr)
+	default:
+	}
+
+	trial.AckedBefore = acked.Load()
+	canceled := time.Now()
+	cancel()
+
+	grace := time.NewTimer(b.Grace)
+	defer grace.Stop()
+
+	select {
+	case <-done:
+		trial.TornDown = true
+		trial.Teardown = returned.Sub(canceled)
+		trial.Code = status.Code(recvErr).String()
+	case <-grace.C:
+		trial.Teardown = b.Grace
+	}
+
+	trial.Acked, trial.Nacked = acked.Load(), nacked.Load()
+	if replies := trial.Acked + trial.Nacked; replies < trial.Sent {
+		trial.Unresolved = trial.Sent - replies
+	}
+
+	// Wait for the server to commit the events it received before checking the topic
+	if trial.Verified {
+		if b.Settle > 0 {
+			time.Sleep(b.Settle)
+		}
+
+		var after uint64
+		if after, err = b.events(parent); err != nil {
+			log.Warn().Err(err).Msg("could not read topic info, committed events will not be verified")
+			trial.Verified = false
+		} else if after > before {
+			trial.Committed = after - before
+		}
+	}
+	return trial, nil
+}
+
+// Opens a publish stream and waits for the server to signal that the stream is ready.
+func (b *Teardown) open(ctx context.Context) (stream api.Ensign_PublishClient, err error) {
+	if stream, err = b.client.PublishStream(ctx); err != nil {
+		return nil, err
+	}
+
+	req := &api.PublisherRequest{
+		Embed: &api.PublisherRequest_OpenStream{
+			OpenStream: &api.OpenStream{
+				ClientId: fmt.Sprintf("benchmarks-%s", ulid.Make()),
+			},
+		},
+	}
+
+	if err = stream.Send(req); err != nil {
+		return nil, err
+	}
+
+	var rep *api.PublisherReply
+	if rep, err = stream.Recv(); err != nil {
+		return nil, err
+	}
+
+	if rep.GetReady() == nil {
+		return nil, errors.New("did not get publisher ready message")
+	}
+	return stream, nil
+}
+
+// Returns the number of events in the topic. Events published by other clients during
+// the trial are counted as committed so the topic should not be shared.
+func (b *Teardown) events(ctx context.Context) (_ uint64, err error) {
+	var info *api.TopicInfo
+	if info, err = b.client.TopicInfo(ctx, b.topicID); err != nil {
+		return 0, err
+	}
+	return info.Events, nil
+}
+
+func (b *Teardown) Results() (benchmarks.Metrics, error) {
+	results := make(metrics.Metrics)
+	results["trials"] = b.trials
+	results["teardown_latencies"] = b.TeardownLatencies()
+
+	var sent, ackedBefore, acked, unresolved, committed, unacked uint64
+	tornDown, verified := 0, 0
+	codes := make(map[string]uint64)
+	for _, trial := range b.trials {
+		sent += trial.Sent
+		ackedBefore += trial.AckedBefore
+		acked += trial.Acked
+		unresolved += trial.Unresolved
+		if trial.TornDown {
+			tornDown++
+			codes[trial.Code]++
+		}
+
+		if trial.Verified {
+			verified++
+			committed += trial.Committed
+			unacked += trial.CommittedUnacked()
+		}
+	}
+
+	results["sent"] = sent
+	results["acked_before_cancel"] = ackedBefore
+	results["acked"] = acked
+	results["unresolved"] = unresolved
+	results["torn_down"] = tornDown
+	results["hung"] = len(b.trials) - tornDown
+	results["codes"] = codes
+
+	if sent > 0 {
+		results["acked_fraction"] = float64(acked) / float64(sent)
+	}
+
+	if verified > 0 {
+		results["committed"] = committed
+		results["committed_unacked"] = unacked
+	}
+
+	results["exit_reason"] = b.reason
+	results["duration"] = b.duration.String()
+	results["experiment"] = map[string]interface{}{
+		"client_version":   benchmarks.Version(),
+		"endpoint":         b.opts.Endpoint,
+		"topic":            b.opts.Topic,
+		"topic_id":         b.topicID.String(),
+		"resolved_by_id":   b.opts.TopicID != "",
+		"data_size":        b.opts.DataSize,
+		"trials":           b.Trials,
+		"burst":            b.Burst,
+		"delay":            b.Delay.String(),
+		"grace":            b.Grace.String(),
+		"settle":           b.Settle.String(),
+		"guard":            b.opts.Guard(),
+		"minimal_metadata": b.opts.MinimalMetadata,
+	}
+	return results, nil
+}
+
+// TeardownLatencies returns the distribution of the time from canceling a stream until
+// the stream returned; streams that did not tear down are recorded as timeouts.
+func (b *Teardown) TeardownLatencies() *stats.Latencies {
+	latencies := &stats.Latencies{}
+	for _, trial := range b.trials {
+		if trial.TornDown {
+			latencies.Update(trial.Teardown)
+		} else {
+			latencies.Update(0)
+		}
+	}
+	latencies.SetDuration(b.duration)
+	return latencies
+}
+
+// Progress returns the number of trials completed and events sent and acked so far.
+func (b *Teardown) Progress() map[string]interface{} {
+	return b.progress.Snapshot()
+}
+
+// Outcomes returns the outcome of each trial of the last run.
+func (b *Teardown) Outcomes() []*Trial {
+	return b.trials
+}
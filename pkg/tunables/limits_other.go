@@ -0,0 +1,7 @@
+//go:build !unix
+
+package tunables
+
+func fileLimit() *Limit {
+	return nil
+}
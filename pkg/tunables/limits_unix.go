@@ -0,0 +1,14 @@
+//go:build unix
+
+package tunables
+
+import "syscall"
+
+// Returns the open file descriptor limit of the process.
+func fileLimit() *Limit {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return nil
+	}
+	return &Limit{Soft: uint64(rlimit.Cur), Hard: uint64(rlimit.Max)}
+}
@@ -0,0 +1,107 @@
+/*
+Package tunables captures the operating system and runtime settings of the host that
+commonly affect benchmark results, such as GOMAXPROCS, the file descriptor limit, and
+the socket buffer sizes, so that they are recorded with every run. Discrepancies
+between results from different hosts frequently trace back to differences in host
+tuning, which cannot be diagnosed after the fact unless the tuning was recorded.
+*/
+package tunables
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// The root of the kernel parameters, which is only available on Linux. Parameters that
+// cannot be read, e.g. on other platforms or in restricted containers, are omitted.
+var procSys = "/proc/sys"
+
+// Tunables are the settings of the host at the time they were captured.
+type Tunables struct {
+	GOMAXPROCS int          `json:"gomaxprocs"`
+	NumCPU     int          `json:"num_cpu"`
+	GoVersion  string       `json:"go_version"`
+	OS         string       `json:"os"`
+	Arch       string       `json:"arch"`
+	Files      *Limit       `json:"file_limit,omitempty"`
+	Network    *NetworkBufs `json:"network,omitempty"`
+}
+
+// Limit is the soft and hard values of a resource limit of the process.
+type Limit struct {
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
+
+// NetworkBufs are the socket buffer sizes of the kernel in bytes; the TCP buffers are
+// the minimum, default, and maximum sizes used by TCP autotuning.
+type NetworkBufs struct {
+	ReadDefault  uint64   `json:"rmem_default,omitempty"`
+	ReadMax      uint64   `json:"rmem_max,omitempty"`
+	WriteDefault uint64   `json:"wmem_default,omitempty"`
+	WriteMax     uint64   `json:"wmem_max,omitempty"`
+	TCPRead      []uint64 `json:"tcp_rmem,omitempty"`
+	TCPWrite     []uint64 `json:"tcp_wmem,omitempty"`
+	Somaxconn    uint64   `json:"somaxconn,omitempty"`
+}
+
+// Capture the tunables of the host; settings that cannot be read are omitted.
+func Capture() *Tunables {
+	t := &Tunables{
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Files:      fileLimit(),
+	}
+
+	net := &NetworkBufs{
+		ReadDefault:  readUint("net/core/rmem_default"),
+		ReadMax:      readUint("net/core/rmem_max"),
+		WriteDefault: readUint("net/core/wmem_default"),
+		WriteMax:     readUint("net/core/wmem_max"),
+		TCPRead:      readUints("net/ipv4/tcp_rmem"),
+		TCPWrite:     readUints("net/ipv4/tcp_wmem"),
+		Somaxconn:    readUint("net/core/somaxconn"),
+	}
+
+	if net.ReadDefault > 0 || net.WriteDefault > 0 || len(net.TCPRead) > 0 || len(net.TCPWrite) > 0 {
+		t.Network = net
+	}
+	return t
+}
+
+// Reads a single integer kernel parameter, returning zero if it cannot be read.
+func readUint(param string) uint64 {
+	if vals := readUints(param); len(vals) == 1 {
+		return vals[0]
+	}
+	return 0
+}
+
+// Reads a whitespace separated list of integers from a kernel parameter.
+func readUints(param string) []uint64 {
+	data, err := os.ReadFile(filepath.Join(procSys, filepath.FromSlash(param)))
+	if err != nil {
+		return nil
+	}
+
+	fields := strings.Fields(string(data))
+	vals := make([]uint64, 0, len(fields))
+	for _, field := range fields {
+		val, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil
+		}
+		vals = append(vals, val)
+	}
+
+	if len(vals) == 0 {
+		return nil
+	}
+	return vals
+}
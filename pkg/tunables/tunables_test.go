@@ -0,0 +1,57 @@
+package tunables
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	// Use a fake kernel parameter tree so the test does not depend on the host
+	root := t.TempDir()
+	params := map[string]string{
+		"net/core/rmem_default": "212992\n",
+		"net/core/rmem_max":     "4194304\n",
+		"net/core/wmem_default": "212992\n",
+		"net/core/wmem_max":     "not a number\n",
+		"net/ipv4/tcp_rmem":     "4096\t131072\t6291456\n",
+		"net/ipv4/tcp_wmem":     "4096 16384 4194304\n",
+	}
+	for param, val := range params {
+		path := filepath.Join(root, filepath.FromSlash(param))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(val), 0644))
+	}
+
+	defer func(orig string) { procSys = orig }(procSys)
+	procSys = root
+
+	tunables := Capture()
+	require.Equal(t, runtime.GOMAXPROCS(0), tunables.GOMAXPROCS)
+	require.Equal(t, runtime.GOOS, tunables.OS)
+	require.NotNil(t, tunables.Network)
+	require.Equal(t, uint64(212992), tunables.Network.ReadDefault)
+	require.Equal(t, uint64(4194304), tunables.Network.ReadMax)
+	require.Zero(t, tunables.Network.WriteMax, "unparseable parameters should be omitted")
+	require.Zero(t, tunables.Network.Somaxconn, "missing parameters should be omitted")
+	require.Equal(t, []uint64{4096, 131072, 6291456}, tunables.Network.TCPRead)
+	require.Equal(t, []uint64{4096, 16384, 4194304}, tunables.Network.TCPWrite)
+
+	if runtime.GOOS != "windows" {
+		require.NotNil(t, tunables.Files)
+		require.LessOrEqual(t, tunables.Files.Soft, tunables.Files.Hard)
+	}
+
+	// Network buffers are omitted entirely if none of them can be read
+	procSys = filepath.Join(root, "missing")
+	tunables = Capture()
+	require.Nil(t, tunables.Network)
+
+	data, err := json.Marshal(tunables)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "network")
+}
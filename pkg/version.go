@@ -1,6 +1,14 @@
 package benchmarks
 
-import "fmt"
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/rotationalio/go-ensign"
+)
+
+// SDKModule is the module path of the Ensign client SDK used by the benchmarks.
+const SDKModule = "github.com/rotationalio/go-ensign"
 
 // Version component constants for the current build.
 const (
@@ -37,3 +45,33 @@ func Version() string {
 
 	return versionCore
 }
+
+// SDKVersion returns the version of the go-ensign module that the benchmarks were built
+// with so that runs can be compared across SDK upgrades. If the module was replaced the
+// version of the replacement is returned (or its path if the replacement is a local
+// directory); the release version reported by the SDK is returned if no build info is
+// available, e.g. if the binary was built without module support.
+func SDKVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path != SDKModule {
+				continue
+			}
+
+			if dep.Replace != nil {
+				if dep.Replace.Version != "" {
+					return dep.Replace.Version
+				}
+				return dep.Replace.Path
+			}
+			return dep.Version
+		}
+	}
+	return "v" + ensign.Version()
+}
+
+// SDKRelease returns the release version reported by the go-ensign SDK itself, which
+// may include a release level that is not part of the module version.
+func SDKRelease() string {
+	return ensign.Version()
+}
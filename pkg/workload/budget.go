@@ -0,0 +1,80 @@
+package workload
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultWarmup is the number of events generated to measure the cost of a generator.
+const DefaultWarmup = 1000
+
+// Budget records the client-side cost of generating events measured during warmup so
+// that runs where the client could not generate events as fast as the requested publish
+// rate can be identified rather than mistaken for a slow server. The cost is measured
+// as the elapsed time of generating events in a tight loop on a single goroutine, which
+// is the same way events are generated while publishing.
+type Budget struct {
+	Events   int           // the number of events generated during warmup
+	PerEvent time.Duration // the mean time to generate a single event
+	Capacity float64       // the projected number of events per second that can be generated
+	Rate     float64       // the requested publish rate in events per second (0 if unlimited)
+}
+
+// MeasureBudget calls generate n times (or DefaultWarmup times if n is zero or less) to
+// project the generation capacity of the client against the requested publish rate.
+func MeasureBudget(n int, rate float64, generate func()) *Budget {
+	if n <= 0 {
+		n = DefaultWarmup
+	}
+
+	started := time.Now()
+	for i := 0; i < n; i++ {
+		generate()
+	}
+	elapsed := time.Since(started)
+
+	budget := &Budget{Events: n, Rate: rate, PerEvent: elapsed / time.Duration(n)}
+	if elapsed > 0 {
+		budget.Capacity = float64(n) / elapsed.Seconds()
+	}
+	return budget
+}
+
+// Sufficient returns true if the client can generate events at least as fast as the
+// requested publish rate.
+func (b *Budget) Sufficient() bool {
+	return b.Rate <= 0 || b.Capacity <= 0 || b.Capacity >= b.Rate
+}
+
+// Check logs a warning if the generation capacity is below the requested publish rate
+// and returns the result of Sufficient.
+func (b *Budget) Check() bool {
+	if b.Sufficient() {
+		log.Debug().
+			Dur("per_event", b.PerEvent).
+			Float64("capacity", b.Capacity).
+			Float64("rate", b.Rate).
+			Msg("event generation capacity is sufficient for the requested rate")
+		return true
+	}
+
+	log.Warn().
+		Dur("per_event", b.PerEvent).
+		Float64("capacity", b.Capacity).
+		Float64("rate", b.Rate).
+		Msg("client cannot generate events as fast as the requested publish rate; results will be limited by the client")
+	return false
+}
+
+// Serializes the budget into a JSON map with durations as strings.
+func (b *Budget) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	data["events"] = b.Events
+	data["per_event"] = b.PerEvent.String()
+	data["capacity"] = b.Capacity
+	data["rate"] = b.Rate
+	data["sufficient"] = b.Sufficient()
+	return json.Marshal(data)
+}
@@ -0,0 +1,46 @@
+package workload_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	calls := 0
+	slow := func() {
+		calls++
+		time.Sleep(time.Millisecond)
+	}
+
+	// A generator that takes at least 1ms per event cannot sustain 10k events/s
+	budget := workload.MeasureBudget(10, 10000, slow)
+	require.Equal(t, 10, calls)
+	require.Equal(t, 10, budget.Events)
+	require.GreaterOrEqual(t, budget.PerEvent, time.Millisecond)
+	require.Less(t, budget.Capacity, 1000.0)
+	require.False(t, budget.Sufficient())
+	require.False(t, budget.Check())
+
+	// The same generator is sufficient for a low rate or an unlimited rate
+	budget.Rate = 10
+	require.True(t, budget.Sufficient())
+	budget.Rate = 0
+	require.True(t, budget.Sufficient())
+
+	data, err := json.Marshal(budget)
+	require.NoError(t, err)
+
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, true, out["sufficient"])
+	require.Contains(t, out, "per_event")
+
+	// The default warmup is used if the number of events is not specified
+	calls = 0
+	workload.MeasureBudget(0, 0, func() { calls++ })
+	require.Equal(t, workload.DefaultWarmup, calls)
+}
@@ -0,0 +1,82 @@
+package workload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// PayloadIndex is the ground truth of the duplicate payloads in a dataset: it maps the
+// hash of every distinct payload to the number of times it occurs and the positions of
+// the events it occurs in, so that the results of server-side deduplication can be
+// verified without scanning the dataset again.
+type PayloadIndex struct {
+	Events   int                      `json:"events"`
+	Unique   int                      `json:"unique"`
+	Payloads map[string]*PayloadEntry `json:"payloads"`
+}
+
+// PayloadEntry records the occurrences of a payload in the dataset.
+type PayloadEntry struct {
+	Count     int   `json:"count"`
+	Positions []int `json:"positions"` // the zero-based positions of the events in the dataset
+}
+
+func NewPayloadIndex() *PayloadIndex {
+	return &PayloadIndex{Payloads: make(map[string]*PayloadEntry)}
+}
+
+// PayloadHash returns the hex encoded SHA-256 hash of the payload.
+func PayloadHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Add the payload of the event at the next position of the dataset to the index.
+func (x *PayloadIndex) Add(event *api.EventWrapper) (err error) {
+	var unwrapped *api.Event
+	if unwrapped, err = event.Unwrap(); err != nil {
+		return fmt.Errorf("could not index event %d: %w", x.Events, err)
+	}
+
+	hash := PayloadHash(unwrapped.Data)
+	entry, ok := x.Payloads[hash]
+	if !ok {
+		entry = &PayloadEntry{}
+		x.Payloads[hash] = entry
+		x.Unique++
+	}
+
+	entry.Count++
+	entry.Positions = append(entry.Positions, x.Events)
+	x.Events++
+	return nil
+}
+
+// Duplicates returns the number of events whose payload occurred earlier in the
+// dataset, i.e. the number of events that deduplication should remove.
+func (x *PayloadIndex) Duplicates() int {
+	return x.Events - x.Unique
+}
+
+// Write the index as JSON.
+func (x *PayloadIndex) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(x)
+}
+
+// ReadPayloadIndex reads an index written by Write.
+func ReadPayloadIndex(r io.Reader) (x *PayloadIndex, err error) {
+	x = &PayloadIndex{}
+	if err = json.NewDecoder(r).Decode(x); err != nil {
+		return nil, fmt.Errorf("could not read payload index: %w", err)
+	}
+
+	if x.Payloads == nil {
+		x.Payloads = make(map[string]*PayloadEntry)
+	}
+	return x, nil
+}
@@ -0,0 +1,47 @@
+package workload_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadIndex(t *testing.T) {
+	gen := workload.NewRandomDuplicates(5, 0.5, 0.5, workload.NewRand(0))
+	index := workload.NewPayloadIndex()
+	payloads := make([][]byte, 0, 200)
+	for i := 0; i < 200; i++ {
+		event := gen.Next()
+		require.NoError(t, index.Add(event))
+
+		unwrapped, err := event.Unwrap()
+		require.NoError(t, err)
+		payloads = append(payloads, unwrapped.Data)
+	}
+
+	require.Equal(t, 200, index.Events)
+	require.Len(t, index.Payloads, index.Unique)
+	require.Greater(t, index.Duplicates(), 0, "expected the workload to generate duplicates")
+
+	// Every position is recorded under the hash of the payload at that position
+	total := 0
+	for hash, entry := range index.Payloads {
+		require.Len(t, entry.Positions, entry.Count)
+		for _, pos := range entry.Positions {
+			require.Equal(t, hash, workload.PayloadHash(payloads[pos]))
+		}
+		total += entry.Count
+	}
+	require.Equal(t, 200, total)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, index.Write(buf))
+	loaded, err := workload.ReadPayloadIndex(buf)
+	require.NoError(t, err)
+	require.Equal(t, index, loaded)
+
+	_, err = workload.ReadPayloadIndex(bytes.NewBufferString("not json"))
+	require.Error(t, err)
+}
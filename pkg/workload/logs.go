@@ -0,0 +1,223 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Default configuration of the logs workload.
+const (
+	DefaultErrorRate      = 0.01 // the fraction of lines logged at the error level outside of bursts
+	DefaultBurstErrorRate = 0.5  // the fraction of lines logged at the error level during bursts
+	DefaultBurstProb      = 0.001
+	DefaultBurstLength    = 100
+)
+
+// The log levels of the lines generated by the logs workload.
+const (
+	LevelDebug = "DEBUG"
+	LevelInfo  = "INFO"
+	LevelWarn  = "WARN"
+	LevelError = "ERROR"
+)
+
+// The shape of the log stream; the remaining lines that are not errors are split
+// between the debug, info, and warn levels by these weights.
+const (
+	logRate       = 500.0 // lines per second across all services
+	debugWeight   = 0.15
+	warnWeight    = 0.08
+	stackProb     = 0.3 // the probability that an error line includes a stack trace
+	activeReqs    = 16  // the number of requests that are interleaved in the stream
+	reqReuseProb  = 0.8 // the probability that a line belongs to an active request
+	maxExtraPairs = 6
+)
+
+var (
+	logType  = &api.Type{Name: "LogLine", MajorVersion: 1}
+	services = []string{"api", "auth", "billing", "ingest", "scheduler", "search", "worker"}
+
+	messages = map[string][]string{
+		LevelDebug: {
+			"cache lookup",
+			"acquired connection from pool",
+			"parsed request body",
+			"evaluating feature flags",
+		},
+		LevelInfo: {
+			"request started",
+			"request completed",
+			"user authenticated",
+			"job enqueued",
+			"job completed",
+			"configuration reloaded",
+		},
+		LevelWarn: {
+			"slow query",
+			"retrying request to upstream",
+			"rate limit approaching",
+			"deprecated api version used",
+		},
+		LevelError: {
+			"request failed",
+			"could not connect to database",
+			"upstream returned an unexpected status",
+			"panic recovered in handler",
+			"context deadline exceeded",
+		},
+	}
+)
+
+// LogLines generates a stream of plain text application log lines as they would be
+// shipped by a log forwarder: each line has a timestamp, a level, the service that
+// logged it, a request ID that is shared by the interleaved lines of a request, and a
+// message of variable length. Some error lines include a multi-line stack trace.
+// Errors are logged at a low rate except during bursts, which model the incidents that
+// log transports must keep up with, when most lines are errors.
+type LogLines struct {
+	clock          time.Time
+	errorRate      float64
+	burstErrorRate float64
+	burstProb      float64
+	burstLength    int
+	burst          int // the number of lines remaining in the current burst
+	requests       []string
+	rnd            *rand.Rand
+}
+
+func NewLogLines(errorRate float64, rnd *rand.Rand) *LogLines {
+	gen := &LogLines{
+		clock:     time.Now().UTC(),
+		errorRate: errorRate,
+		requests:  make([]string, 0, activeReqs),
+		rnd:       rnd,
+	}
+	gen.SetBursts(DefaultBurstErrorRate, DefaultBurstProb, DefaultBurstLength)
+	return gen
+}
+
+// SetBursts configures the error rate bursts: each line starts a burst of the specified
+// number of lines with the specified probability, and lines in a burst are errors with
+// the burst error rate. Bursts are disabled if the probability is zero.
+func (l *LogLines) SetBursts(errorRate, prob float64, length int) error {
+	if errorRate < 0 || errorRate > 1 || prob < 0 || prob > 1 {
+		return errors.New("the burst error rate and probability must be between 0 and 1")
+	}
+
+	if length < 1 && prob > 0 {
+		return errors.New("bursts must be at least one line long")
+	}
+
+	l.burstErrorRate = errorRate
+	l.burstProb = prob
+	l.burstLength = length
+	return nil
+}
+
+// Next returns the next log line wrapped as an event.
+func (l *LogLines) Next() *api.EventWrapper {
+	l.clock = l.clock.Add(time.Duration(l.rnd.ExpFloat64() / logRate * float64(time.Second)))
+
+	level := l.level()
+	service := services[l.rnd.Intn(len(services))]
+	requestID := l.requestID()
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s %-5s [%s] request_id=%s %s", l.clock.Format(time.RFC3339Nano), level, service, requestID, pick(l.rnd, messages[level]))
+
+	// Vary the length of the messages with key value pairs of context
+	for i := l.rnd.Intn(maxExtraPairs + 1); i > 0; i-- {
+		fmt.Fprintf(sb, " %s=%s", mkKey(l.rnd), mkVal(l.rnd))
+	}
+
+	if level == LevelWarn || level == LevelError {
+		fmt.Fprintf(sb, " duration=%s", time.Duration(math.Round(l.rnd.ExpFloat64()*500))*time.Millisecond)
+	}
+
+	if level == LevelError && flip(l.rnd, stackProb) {
+		stackTrace(l.rnd, sb, service)
+	}
+	sb.WriteByte('\n')
+
+	event := &api.Event{
+		Data: []byte(sb.String()),
+		Metadata: map[string]string{
+			"level":      level,
+			"service":    service,
+			"request_id": requestID,
+		},
+		Mimetype: mimetype.TextPlain,
+		Type:     logType,
+		Created:  timestamppb.New(l.clock),
+	}
+	return wrapEvent(l.rnd, event)
+}
+
+// Bursting returns true if the next line is part of an error rate burst.
+func (l *LogLines) Bursting() bool {
+	return l.burst > 0
+}
+
+func (l *LogLines) level() string {
+	if l.burst == 0 && l.burstProb > 0 && flip(l.rnd, l.burstProb) {
+		l.burst = l.burstLength
+	}
+
+	errorRate := l.errorRate
+	if l.burst > 0 {
+		l.burst--
+		errorRate = l.burstErrorRate
+	}
+
+	if l.rnd.Float64() < errorRate {
+		return LevelError
+	}
+
+	switch r := l.rnd.Float64(); {
+	case r < debugWeight:
+		return LevelDebug
+	case r < debugWeight+warnWeight:
+		return LevelWarn
+	default:
+		return LevelInfo
+	}
+}
+
+// Returns the ID of one of the active requests or starts a new request, replacing a
+// random active request once the maximum number are interleaved.
+func (l *LogLines) requestID() string {
+	if len(l.requests) > 0 && flip(l.rnd, reqReuseProb) {
+		return l.requests[l.rnd.Intn(len(l.requests))]
+	}
+
+	id := fmt.Sprintf("%016x", l.rnd.Uint64())
+	if len(l.requests) < activeReqs {
+		l.requests = append(l.requests, id)
+	} else {
+		l.requests[l.rnd.Intn(len(l.requests))] = id
+	}
+	return id
+}
+
+// Appends a goroutine stack trace of random depth to the log line.
+func stackTrace(r *rand.Rand, sb *strings.Builder, service string) {
+	sb.WriteString("\ngoroutine ")
+	fmt.Fprintf(sb, "%d [running]:", r.Intn(10000)+1)
+	for depth := r.Intn(8) + 2; depth > 0; depth-- {
+		pkg, fn := mkName(r, r.Intn(4)+4), mkName(r, r.Intn(6)+4)
+		fmt.Fprintf(sb, "\ngithub.com/example/%s/pkg/%s.%s(0x%x)", service, pkg, strings.ToUpper(fn[:1])+fn[1:], r.Uint32())
+		fmt.Fprintf(sb, "\n\t/app/pkg/%s/%s.go:%d +0x%x", pkg, fn, r.Intn(900)+10, r.Intn(0x400))
+	}
+}
+
+func pick(r *rand.Rand, choices []string) string {
+	return choices[r.Intn(len(choices))]
+}
@@ -0,0 +1,57 @@
+package workload_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+var logLine = regexp.MustCompile(`^(\S+) (DEBUG|INFO |WARN |ERROR) \[(\w+)\] request_id=([0-9a-f]{16}) `)
+
+func TestLogLines(t *testing.T) {
+	gen, err := workload.Get(workload.LogsWorkload, 0)
+	require.NoError(t, err, "logs workload should be registered")
+
+	logs := gen.(*workload.LogLines)
+	require.NoError(t, logs.SetBursts(1, 0.01, 50))
+
+	var errors, bursting, stacks int
+	for i := 0; i < 5000; i++ {
+		burst := logs.Bursting()
+		wrap := logs.Next()
+		event, err := wrap.Unwrap()
+		require.NoError(t, err)
+		require.Equal(t, mimetype.TextPlain, event.Mimetype)
+
+		line := string(event.Data)
+		require.True(t, strings.HasSuffix(line, "\n"))
+
+		match := logLine.FindStringSubmatch(line)
+		require.NotNil(t, match, "unexpected log line %q", line)
+		require.Equal(t, event.Metadata["level"], strings.TrimSpace(match[2]))
+		require.Equal(t, event.Metadata["service"], match[3])
+		require.Equal(t, event.Metadata["request_id"], match[4])
+
+		if event.Metadata["level"] == workload.LevelError {
+			errors++
+			if strings.Contains(line, "goroutine") {
+				stacks++
+			}
+		} else if burst {
+			bursting++
+		}
+	}
+
+	// Every line in a burst is an error when the burst error rate is 1
+	require.Zero(t, bursting, "only errors should be logged during bursts")
+	require.Greater(t, float64(errors), 5000*workload.DefaultErrorRate)
+	require.Greater(t, stacks, 0)
+
+	require.Error(t, logs.SetBursts(1.5, 0.01, 50))
+	require.Error(t, logs.SetBursts(0.5, 0.01, 0))
+	require.NoError(t, logs.SetBursts(0.5, 0, 0))
+}
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
@@ -18,6 +19,7 @@ import (
 
 var (
 	rnd       *rand.Rand
+	wrapmu    sync.Mutex // guards the sequence of the wrappers of concurrent generators
 	seq       rlid.Sequence
 	topicID   ulid.ULID
 	publisher *api.Publisher
@@ -41,13 +43,20 @@ func init() {
 // details about how to use the specified parameters. Wrapper metadata is set using
 // global defaults, which can be modified using the setter methods.
 func MkWrap(data, kvs string, mime mimetype.MIME, etype, created string) *api.EventWrapper {
-	event := MkEvent(data, kvs, mime, etype, created)
+	return wrapEvent(rnd, MkEvent(data, kvs, mime, etype, created))
+}
+
+// Wraps an event using the global wrapper defaults and the next sequence ID; the commit
+// time of the event is drawn from the random source of the generator.
+func wrapEvent(r *rand.Rand, event *api.Event) *api.EventWrapper {
+	wrapmu.Lock()
 	eventID := seq.Next()
+	wrapmu.Unlock()
 
 	wrap := &api.EventWrapper{
 		Id:          eventID.Bytes(),
 		TopicId:     topicID.Bytes(),
-		Committed:   timestamppb.New(event.Created.AsTime().Add(randDuration(30 * time.Second))),
+		Committed:   timestamppb.New(event.Created.AsTime().Add(randDuration(r, 30*time.Second))),
 		Offset:      uint64(eventID.Sequence()),
 		Epoch:       uint64(0xbb),
 		Region:      pubRegion,
@@ -127,8 +136,12 @@ func MkEvent(data, kvs string, mime mimetype.MIME, etype, created string) *api.E
 
 // Generate random data and convert to base64 without error or panic.
 func MkData(s int) string {
+	return mkData(rnd, s)
+}
+
+func mkData(r *rand.Rand, s int) string {
 	if s == 0 {
-		s = rnd.Intn(4096)
+		s = r.Intn(4096)
 	}
 
 	data := make([]byte, s)
@@ -136,11 +149,22 @@ func MkData(s int) string {
 	return base64.RawStdEncoding.EncodeToString(data)
 }
 
+// NewRand returns a random source for a generator; if the seed is zero the source is
+// seeded from the current time. Sources are not safe for concurrent use.
+func NewRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
 func SetRand(nrnd *rand.Rand) {
 	rnd = nrnd
 }
 
 func SetSequence(nseq rlid.Sequence) {
+	wrapmu.Lock()
+	defer wrapmu.Unlock()
 	seq = nseq
 }
 
@@ -164,6 +188,6 @@ func parseuint32(s string) uint32 {
 	return uint32(num)
 }
 
-func randDuration(max time.Duration) time.Duration {
-	return time.Duration(rnd.Int63n(int64(max)))
+func randDuration(r *rand.Rand, max time.Duration) time.Duration {
+	return time.Duration(r.Int63n(int64(max)))
 }
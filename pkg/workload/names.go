@@ -11,16 +11,29 @@ const (
 )
 
 func MkKey() string {
-	return Name(rnd.Intn(7) + 5)
+	return mkKey(rnd)
 }
 
 func MkVal() string {
-	return Name(rnd.Intn(12) + 4)
+	return mkVal(rnd)
 }
 
 // Name generates a random string of n characters that only contains consonants and
 // vowels. Names are not cryptographically random and are not guaranteed to be unique.
 func Name(n int) string {
+	return mkName(rnd, n)
+}
+
+func mkKey(r *rand.Rand) string {
+	return mkName(r, r.Intn(7)+5)
+}
+
+func mkVal(r *rand.Rand) string {
+	return mkName(r, r.Intn(12)+4)
+}
+
+// Generates a name from the random source.
+func mkName(r *rand.Rand, n int) string {
 	if n < 1 {
 		return ""
 	}
@@ -29,31 +42,23 @@ func Name(n int) string {
 	numConsonants := (n / 2) + 1
 	chars := make([]byte, 0, n)
 	for i := 0; i < numConsonants; i++ {
-		chars = append(chars, randConsonant())
+		chars = append(chars, consonants[r.Intn(len(consonants))])
 	}
 
 	// Select the vowels to use
 	numVowels := n - numConsonants
 	for i := 0; i < numVowels; i++ {
-		chars = append(chars, randVowel())
+		chars = append(chars, vowels[r.Intn(len(vowels))])
 	}
 
 	// Build the result string
 	sb := strings.Builder{}
 	sb.Grow(n)
 	for remain := len(chars); remain > 0; remain-- {
-		idx := rand.Intn(len(chars))
+		idx := r.Intn(len(chars))
 		sb.WriteByte(chars[idx])
 		chars = append(chars[:idx], chars[idx+1:]...)
 	}
 
 	return sb.String()
 }
-
-func randConsonant() byte {
-	return consonants[rnd.Intn(len(consonants))]
-}
-
-func randVowel() byte {
-	return vowels[rnd.Intn(len(vowels))]
-}
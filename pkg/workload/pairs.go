@@ -0,0 +1,281 @@
+package workload
+
+import (
+	"container/heap"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/ensign-benchmarks/pkg/stats"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultPairGap is the mean number of events published between a request and its
+// response by the default pairs workload.
+const DefaultPairGap = 10.0
+
+// The size of the random body of the events of a pair.
+const pairBodySize = 128
+
+// The metadata of the events generated by the pairs workload.
+const (
+	PairKindKey        = "pair"           // either request or response
+	PairCorrelationKey = "correlation_id" // shared by a request and its response
+	PairSequenceKey    = "pair_seq"       // the index of the event in the workload
+)
+
+// The kinds of the events of a pair.
+const (
+	PairRequest  = "request"
+	PairResponse = "response"
+)
+
+var (
+	requestType  = &api.Type{Name: "Request", MajorVersion: 1}
+	responseType = &api.Type{Name: "Response", MajorVersion: 1}
+)
+
+// Pairs generates correlated request and response events to model RPC-over-events
+// usage. Every request is followed by a response with the same correlation ID after a
+// number of intervening events drawn from an exponential distribution with the mean
+// gap, so that at a constant publish rate the response delays are also exponentially
+// distributed. The delay is measured in events rather than time so that workloads that
+// are generated ahead of the run produce the same pairs. Each event records its index
+// in the workload so that a PairAnalyzer can compare the delivered order to the
+// published order. The trailing requests of a finite run may not have a response.
+type Pairs struct {
+	gap     float64
+	index   uint64
+	pending pendingPairs
+	entropy *ulid.MonotonicEntropy
+	rnd     *rand.Rand
+}
+
+// PairEvent is the JSON payload of an event generated by the pairs workload.
+type PairEvent struct {
+	Kind          string `json:"kind"`
+	CorrelationID string `json:"correlation_id"`
+	Sequence      uint64 `json:"seq"`
+	Request       uint64 `json:"request_seq,omitempty"` // the sequence of the request of a response
+	Body          string `json:"body"`
+}
+
+func NewPairs(gap float64, rnd *rand.Rand) *Pairs {
+	if gap < 0 {
+		gap = 0
+	}
+	return &Pairs{gap: gap, entropy: ulid.Monotonic(rnd, 0), rnd: rnd}
+}
+
+// Next returns the response that is due at the current index of the workload or a new
+// request if no response is due.
+func (p *Pairs) Next() *api.EventWrapper {
+	defer func() { p.index++ }()
+
+	event := &PairEvent{Sequence: p.index, Body: mkData(p.rnd, pairBodySize)}
+	if len(p.pending) > 0 && p.pending[0].due <= p.index {
+		request := heap.Pop(&p.pending).(pendingPair)
+		event.Kind, event.CorrelationID, event.Request = PairResponse, request.id, request.seq
+		return p.wrap(event, responseType)
+	}
+
+	event.Kind = PairRequest
+	event.CorrelationID = ulid.MustNew(ulid.Now(), p.entropy).String()
+
+	// The response is published after at least one more event
+	gap := uint64(math.Round(p.rnd.ExpFloat64() * p.gap))
+	heap.Push(&p.pending, pendingPair{id: event.CorrelationID, seq: p.index, due: p.index + 1 + gap})
+	return p.wrap(event, requestType)
+}
+
+func (p *Pairs) wrap(pair *PairEvent, etype *api.Type) *api.EventWrapper {
+	data, err := json.Marshal(pair)
+	if err != nil {
+		panic(err)
+	}
+
+	event := &api.Event{
+		Data: data,
+		Metadata: map[string]string{
+			PairKindKey:        pair.Kind,
+			PairCorrelationKey: pair.CorrelationID,
+			PairSequenceKey:    strconv.FormatUint(pair.Sequence, 10),
+		},
+		Mimetype: mimetype.ApplicationJSON,
+		Type:     etype,
+		Created:  timestamppb.Now(),
+	}
+	return wrapEvent(p.rnd, event)
+}
+
+// A request awaiting its response, ordered by the index that the response is due at.
+type pendingPair struct {
+	id  string
+	seq uint64
+	due uint64
+}
+
+type pendingPairs []pendingPair
+
+func (h pendingPairs) Len() int { return len(h) }
+func (h pendingPairs) Less(i, j int) bool {
+	if h[i].due == h[j].due {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].due < h[j].due
+}
+func (h pendingPairs) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pendingPairs) Push(x interface{}) { *h = append(*h, x.(pendingPair)) }
+func (h *pendingPairs) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// PairAnalyzer measures how faithfully the events of the pairs workload are delivered
+// to a consumer: whether both events of every pair arrive, whether the response
+// arrives after its request, and whether the pair arrives as close together as it was
+// published. The displacement of a pair is the difference between the number of events
+// delivered and published between its request and response. Events that were not
+// generated by the pairs workload are ignored. This object is thread-safe.
+type PairAnalyzer struct {
+	sync.Mutex
+	open         map[string]pairHalf // the pairs of which only one event was delivered
+	closed       map[string]struct{} // the pairs of which both events were delivered
+	delivered    uint64              // the number of pair events delivered
+	requests     uint64
+	responses    uint64
+	pairs        uint64
+	inverted     uint64
+	duplicates   uint64
+	preserved    uint64
+	displacement uint64 // the sum of the displacements of the pairs
+	maxDisplaced uint64
+	separation   stats.Latencies // between the deliveries of the request and response
+}
+
+// A delivered event of a pair that is waiting for the other event.
+type pairHalf struct {
+	kind  string
+	seq   uint64
+	index uint64
+	at    time.Time
+}
+
+func NewPairAnalyzer() *PairAnalyzer {
+	return &PairAnalyzer{open: make(map[string]pairHalf), closed: make(map[string]struct{})}
+}
+
+// Observe the metadata of a delivered event along with when it was delivered.
+func (a *PairAnalyzer) Observe(metadata map[string]string, delivered time.Time) {
+	kind, id := metadata[PairKindKey], metadata[PairCorrelationKey]
+	if (kind != PairRequest && kind != PairResponse) || id == "" {
+		return
+	}
+
+	seq, err := strconv.ParseUint(metadata[PairSequenceKey], 10, 64)
+	if err != nil {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	// Redelivered events do not complete a pair
+	other, open := a.open[id]
+	if _, closed := a.closed[id]; closed || (open && other.kind == kind) {
+		a.duplicates++
+		return
+	}
+
+	half := pairHalf{kind: kind, seq: seq, index: a.delivered, at: delivered}
+	a.delivered++
+
+	if kind == PairRequest {
+		a.requests++
+	} else {
+		a.responses++
+	}
+
+	if !open {
+		a.open[id] = half
+		return
+	}
+	delete(a.open, id)
+	a.closed[id] = struct{}{}
+
+	request, response := other, half
+	if kind == PairRequest {
+		request, response = half, other
+		a.inverted++
+	} else if separation := response.at.Sub(request.at); separation > 0 {
+		a.separation.Update(separation)
+	}
+	a.pairs++
+
+	// The distance between the events of the pair as published and as delivered
+	published := int64(response.seq) - int64(request.seq)
+	observed := int64(response.index) - int64(request.index)
+	displaced := published - observed
+	if displaced < 0 {
+		displaced = -displaced
+	}
+
+	if displaced == 0 && kind == PairResponse {
+		a.preserved++
+	}
+
+	a.displacement += uint64(displaced)
+	if uint64(displaced) > a.maxDisplaced {
+		a.maxDisplaced = uint64(displaced)
+	}
+}
+
+// Pairs returns the number of pairs of which both events were delivered.
+func (a *PairAnalyzer) Pairs() uint64 {
+	a.Lock()
+	defer a.Unlock()
+	return a.pairs
+}
+
+func (a *PairAnalyzer) MarshalJSON() ([]byte, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	// The pairs that are still open are missing either their request or their response
+	var unanswered, orphaned uint64
+	for _, half := range a.open {
+		if half.kind == PairRequest {
+			unanswered++
+		} else {
+			orphaned++
+		}
+	}
+
+	data := map[string]interface{}{
+		"requests":             a.requests,
+		"responses":            a.responses,
+		"pairs":                a.pairs,
+		"inverted":             a.inverted,
+		"unanswered":           unanswered,
+		"orphaned":             orphaned,
+		"duplicates":           a.duplicates,
+		"separation_latencies": &a.separation,
+	}
+
+	if a.pairs > 0 {
+		data["preserved_fraction"] = float64(a.preserved) / float64(a.pairs)
+		data["inverted_rate"] = float64(a.inverted) / float64(a.pairs)
+		data["mean_displacement"] = float64(a.displacement) / float64(a.pairs)
+		data["max_displacement"] = a.maxDisplaced
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,109 @@
+package workload_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairs(t *testing.T) {
+	gen, err := workload.Get(workload.PairsWorkload, 0)
+	require.NoError(t, err, "pairs workload should be registered")
+
+	const n = 10000
+	requests := make(map[string]uint64)
+	metadata := make([]map[string]string, 0, n)
+
+	var responses, gaps uint64
+	for i := 0; i < n; i++ {
+		event, err := gen.Next().Unwrap()
+		require.NoError(t, err)
+		require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+
+		pair := &workload.PairEvent{}
+		require.NoError(t, json.Unmarshal(event.Data, pair))
+		require.Equal(t, event.Metadata[workload.PairKindKey], pair.Kind)
+		require.Equal(t, event.Metadata[workload.PairCorrelationKey], pair.CorrelationID)
+		require.Equal(t, strconv.Itoa(i), event.Metadata[workload.PairSequenceKey])
+		metadata = append(metadata, event.Metadata)
+
+		switch pair.Kind {
+		case workload.PairRequest:
+			require.Equal(t, "Request", event.Type.Name)
+			require.NotContains(t, requests, pair.CorrelationID, "correlation ids should be unique")
+			requests[pair.CorrelationID] = pair.Sequence
+		case workload.PairResponse:
+			// Every response follows its request
+			require.Equal(t, "Response", event.Type.Name)
+			seq, ok := requests[pair.CorrelationID]
+			require.True(t, ok, "response published before its request")
+			require.Equal(t, seq, pair.Request)
+			require.Greater(t, pair.Sequence, seq)
+			delete(requests, pair.CorrelationID)
+			gaps += pair.Sequence - seq - 1
+			responses++
+		default:
+			t.Fatalf("unexpected pair kind %q", pair.Kind)
+		}
+	}
+
+	// The gaps between the requests and responses are roughly the mean gap
+	require.Greater(t, responses, uint64(n/2-100))
+	require.InDelta(t, workload.DefaultPairGap, float64(gaps)/float64(responses), 2.5)
+
+	t.Run("Analyzer", func(t *testing.T) {
+		// Delivering the events in the order they were published preserves every pair
+		analyzer := workload.NewPairAnalyzer()
+		delivered := time.Now()
+		for _, md := range metadata {
+			delivered = delivered.Add(time.Millisecond)
+			analyzer.Observe(md, delivered)
+		}
+		analyzer.Observe(map[string]string{"foo": "bar"}, delivered)
+
+		results := analysis(t, analyzer)
+		require.Equal(t, float64(responses), results["pairs"])
+		require.Equal(t, float64(len(requests)), results["unanswered"])
+		require.Equal(t, 0.0, results["inverted"])
+		require.Equal(t, 0.0, results["orphaned"])
+		require.Equal(t, 1.0, results["preserved_fraction"])
+		require.Equal(t, 0.0, results["max_displacement"])
+
+		// Swapping adjacent events inverts the pairs with adjacent events and displaces others
+		analyzer = workload.NewPairAnalyzer()
+		for i := 0; i+1 < len(metadata); i += 2 {
+			analyzer.Observe(metadata[i+1], delivered)
+			analyzer.Observe(metadata[i], delivered)
+		}
+
+		// Dropping the first event orphans its response and redelivering an event is a duplicate
+		analyzer.Observe(metadata[1], delivered)
+
+		results = analysis(t, analyzer)
+		require.Greater(t, results["inverted"], 0.0)
+		require.Less(t, results["preserved_fraction"], 1.0)
+		require.Greater(t, results["mean_displacement"], 0.0)
+		require.LessOrEqual(t, results["max_displacement"], 2.0)
+		require.Equal(t, 1.0, results["duplicates"])
+
+		analyzer = workload.NewPairAnalyzer()
+		for _, md := range metadata[1:] {
+			analyzer.Observe(md, delivered)
+		}
+		require.Equal(t, 1.0, analysis(t, analyzer)["orphaned"])
+	})
+}
+
+func analysis(t *testing.T, analyzer *workload.PairAnalyzer) map[string]interface{} {
+	data, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+
+	results := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(data, &results))
+	return results
+}
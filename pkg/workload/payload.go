@@ -0,0 +1,329 @@
+package workload
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Payload generators registered by this package.
+const (
+	RandomPayload   = "random"
+	JSONPayload     = "json"
+	CSVPayload      = "csv"
+	ProtobufPayload = "protobuf"
+	TextPayload     = "text"
+)
+
+// DefaultSchema is the schema of the documents generated by the structured payloads if
+// no schema is specified; the text field is padded so documents reach the data size.
+const DefaultSchema = "id:string,user:string,amount:float,quantity:int,active:bool,created:time,note:text"
+
+// Payload generates the data of events so that benchmarks can publish realistic,
+// compressible data with a meaningful mimetype rather than incompressible random bytes.
+// Payloads are not safe for concurrent use; each publisher should have its own.
+type Payload interface {
+	// Next returns the data of the next event, approximately size bytes long.
+	Next(size int) []byte
+
+	// Mimetype returns the mimetype of the generated data.
+	Mimetype() mimetype.MIME
+
+	// Type returns the event type of the generated data.
+	Type() *api.Type
+}
+
+// PayloadFactory creates a payload that generates documents with the schema from the
+// random source; schemas are ignored by unstructured payloads.
+type PayloadFactory func(schema Schema, rnd *rand.Rand) Payload
+
+var payloads = map[string]PayloadFactory{
+	RandomPayload:   func(Schema, *rand.Rand) Payload { return &Random{} },
+	JSONPayload:     func(schema Schema, rnd *rand.Rand) Payload { return &JSON{documents{schema, rnd}} },
+	CSVPayload:      func(schema Schema, rnd *rand.Rand) Payload { return &CSV{documents{schema, rnd}} },
+	ProtobufPayload: func(schema Schema, rnd *rand.Rand) Payload { return &Protobuf{documents{schema, rnd}} },
+	TextPayload:     func(_ Schema, rnd *rand.Rand) Payload { return &Text{rnd: rnd} },
+}
+
+// NewPayload returns the named payload generator with the documents described by the
+// schema (or the default schema if empty). An empty name returns random bytes. If the
+// seed is not zero the payload generates the same data for the same seed.
+func NewPayload(name, schema string, seed int64) (_ Payload, err error) {
+	if name == "" {
+		name = RandomPayload
+	}
+
+	factory, ok := payloads[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload %q", name)
+	}
+
+	if schema == "" {
+		schema = DefaultSchema
+	}
+
+	var fields Schema
+	if fields, err = ParseSchema(schema); err != nil {
+		return nil, err
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return factory(fields, rand.New(rand.NewSource(seed))), nil
+}
+
+// PayloadNames returns the sorted names of the payload generators.
+func PayloadNames() []string {
+	names := make([]string, 0, len(payloads))
+	for name := range payloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Field kinds that can be specified in a payload schema.
+const (
+	StringField = "string"
+	IntField    = "int"
+	FloatField  = "float"
+	BoolField   = "bool"
+	TimeField   = "time"
+	TextField   = "text"
+)
+
+// Field is a named and typed field of the documents generated by structured payloads.
+type Field struct {
+	Name string
+	Kind string
+}
+
+// Schema describes the fields of the generated documents in order.
+type Schema []Field
+
+// ParseSchema parses a comma separated list of name:kind fields, e.g. "id:string,
+// amount:float,note:text". The kinds are string, int, float, bool, time, and text.
+func ParseSchema(s string) (schema Schema, err error) {
+	seen := make(map[string]struct{})
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		name, kind, ok := strings.Cut(part, ":")
+		field := Field{Name: strings.TrimSpace(name), Kind: strings.ToLower(strings.TrimSpace(kind))}
+		if !ok || field.Name == "" {
+			return nil, fmt.Errorf("could not parse schema field %q: expected name:kind", part)
+		}
+
+		switch field.Kind {
+		case StringField, IntField, FloatField, BoolField, TimeField, TextField:
+		default:
+			return nil, fmt.Errorf("could not parse schema field %q: unknown kind %q", part, field.Kind)
+		}
+
+		if _, ok := seen[field.Name]; ok {
+			return nil, fmt.Errorf("schema field %q specified more than once", field.Name)
+		}
+		seen[field.Name] = struct{}{}
+		schema = append(schema, field)
+	}
+
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("schema %q has no fields", s)
+	}
+	return schema, nil
+}
+
+// Random generates incompressible random bytes; the default payload of the benchmarks.
+type Random struct{}
+
+func (*Random) Next(size int) []byte {
+	data := make([]byte, size)
+	if _, err := crand.Read(data); err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (*Random) Mimetype() mimetype.MIME { return mimetype.ApplicationOctetStream }
+func (*Random) Type() *api.Type         { return &api.Type{Name: "Random", MajorVersion: 1} }
+
+// JSON generates a JSON document with the fields of the schema, padding the last text
+// field of the schema so that the document is approximately the data size.
+type JSON struct {
+	documents
+}
+
+func (p *JSON) Next(size int) []byte {
+	doc, pad := p.document()
+	data, _ := json.Marshal(doc)
+	if pad != "" && len(data) < size {
+		doc[pad] = doc[pad].(string) + " " + words(p.rnd, size-len(data)-1)
+		data, _ = json.Marshal(doc)
+	}
+	return data
+}
+
+func (*JSON) Mimetype() mimetype.MIME { return mimetype.ApplicationJSON }
+func (*JSON) Type() *api.Type         { return &api.Type{Name: "Document", MajorVersion: 1} }
+
+// CSV generates rows with the fields of the schema until the data size is reached; at
+// least one row is always generated. A header row is not included.
+type CSV struct {
+	documents
+}
+
+func (p *CSV) Next(size int) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for buf.Len() == 0 || buf.Len() < size {
+		doc, _ := p.document()
+		row := make([]string, 0, len(p.schema))
+		for _, field := range p.schema {
+			row = append(row, format(doc[field.Name]))
+		}
+		w.Write(row)
+		w.Flush()
+	}
+	return buf.Bytes()
+}
+
+func (*CSV) Mimetype() mimetype.MIME { return mimetype.TextCSV }
+func (*CSV) Type() *api.Type         { return &api.Type{Name: "Row", MajorVersion: 1} }
+
+// Protobuf generates a protocol buffers Struct message with the fields of the schema,
+// padding the last text field of the schema so that the message is approximately the
+// data size.
+type Protobuf struct {
+	documents
+}
+
+func (p *Protobuf) Next(size int) []byte {
+	doc, pad := p.document()
+	for key, val := range doc {
+		if ts, ok := val.(time.Time); ok {
+			doc[key] = ts.Format(time.RFC3339Nano)
+		}
+	}
+
+	msg, _ := structpb.NewStruct(doc)
+	data, _ := proto.Marshal(msg)
+	if pad != "" && len(data) < size {
+		msg.Fields[pad] = structpb.NewStringValue(doc[pad].(string) + " " + words(p.rnd, size-len(data)-1))
+		data, _ = proto.Marshal(msg)
+	}
+	return data
+}
+
+func (*Protobuf) Mimetype() mimetype.MIME { return mimetype.ApplicationProtobuf }
+func (*Protobuf) Type() *api.Type         { return &api.Type{Name: "Document", MajorVersion: 1} }
+
+// Text generates highly compressible English-like text from a small vocabulary.
+type Text struct {
+	rnd *rand.Rand
+}
+
+func (p *Text) Next(size int) []byte {
+	return []byte(words(p.rnd, size))
+}
+
+func (*Text) Mimetype() mimetype.MIME { return mimetype.TextPlain }
+func (*Text) Type() *api.Type         { return &api.Type{Name: "Text", MajorVersion: 1} }
+
+// Generates documents with random values for the fields of a schema.
+type documents struct {
+	schema Schema
+	rnd    *rand.Rand
+}
+
+// Returns a document with a value for every field and the name of the last text field
+// that should be padded to reach the data size, if any.
+func (d documents) document() (doc map[string]interface{}, pad string) {
+	doc = make(map[string]interface{}, len(d.schema))
+	for _, field := range d.schema {
+		switch field.Kind {
+		case StringField:
+			doc[field.Name] = identifier(d.rnd, 8+d.rnd.Intn(9))
+		case IntField:
+			doc[field.Name] = d.rnd.Int63n(1000000)
+		case FloatField:
+			doc[field.Name] = math.Round(d.rnd.Float64()*100000) / 100
+		case BoolField:
+			doc[field.Name] = d.rnd.Intn(2) == 1
+		case TimeField:
+			doc[field.Name] = time.Now().UTC()
+		case TextField:
+			doc[field.Name] = words(d.rnd, 16+d.rnd.Intn(32))
+			pad = field.Name
+		}
+	}
+	return doc, pad
+}
+
+// Formats a document value as a CSV cell.
+func format(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// Returns a random lowercase alphanumeric identifier of length n.
+func identifier(rnd *rand.Rand, n int) string {
+	id := make([]byte, n)
+	for i := range id {
+		id[i] = alphanumeric[rnd.Intn(len(alphanumeric))]
+	}
+	return string(id)
+}
+
+// The small vocabulary of the generated text makes it highly compressible.
+var vocabulary = strings.Fields(`
+	the of and to in is was for on that with as by at from it an be this which or are
+	event stream topic server client publish subscribe latency throughput order payment
+	account customer shipped delivered pending review total update record message data
+`)
+
+// Returns space separated words from the vocabulary truncated to exactly n bytes.
+func words(rnd *rand.Rand, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.Grow(n + 16)
+	for sb.Len() < n {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(vocabulary[rnd.Intn(len(vocabulary))])
+	}
+	return sb.String()[:n]
+}
@@ -0,0 +1,86 @@
+package workload_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPayloads(t *testing.T) {
+	const size = 1024
+	testCases := []struct {
+		name     string
+		mimetype mimetype.MIME
+		validate func([]byte) error
+	}{
+		{workload.RandomPayload, mimetype.ApplicationOctetStream, nil},
+		{workload.JSONPayload, mimetype.ApplicationJSON, func(data []byte) error {
+			doc := make(map[string]interface{})
+			return json.Unmarshal(data, &doc)
+		}},
+		{workload.CSVPayload, mimetype.TextCSV, func(data []byte) error {
+			_, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			return err
+		}},
+		{workload.ProtobufPayload, mimetype.ApplicationProtobuf, func(data []byte) error {
+			return proto.Unmarshal(data, &structpb.Struct{})
+		}},
+		{workload.TextPayload, mimetype.TextPlain, nil},
+	}
+
+	for _, tc := range testCases {
+		payload, err := workload.NewPayload(tc.name, "", 42)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.mimetype, payload.Mimetype(), tc.name)
+		require.NotEmpty(t, payload.Type().Name, tc.name)
+
+		data := payload.Next(size)
+		require.InDelta(t, size, len(data), size*0.1, "%s payload should be approximately the data size", tc.name)
+		if tc.validate != nil {
+			require.NoError(t, tc.validate(data), "%s payload could not be parsed", tc.name)
+		}
+
+		// Everything but random bytes should be compressible
+		if tc.name == workload.RandomPayload {
+			require.GreaterOrEqual(t, compressed(t, data), len(data))
+		} else {
+			require.Less(t, compressed(t, data), len(data)*9/10, "%s payload should be compressible", tc.name)
+		}
+	}
+
+	// Seeded payloads generate the same documents
+	a, _ := workload.NewPayload(workload.CSVPayload, "id:string,count:int", 7)
+	b, _ := workload.NewPayload(workload.CSVPayload, "id:string,count:int", 7)
+	require.Equal(t, a.Next(256), b.Next(256))
+
+	_, err := workload.NewPayload("xml", "", 0)
+	require.Error(t, err)
+}
+
+func TestParseSchema(t *testing.T) {
+	schema, err := workload.ParseSchema("id:string, amount:FLOAT,note:text")
+	require.NoError(t, err)
+	require.Equal(t, workload.Schema{{"id", "string"}, {"amount", "float"}, {"note", "text"}}, schema)
+
+	for _, in := range []string{"", "id", ":string", "id:uuid", "id:string,id:int"} {
+		_, err := workload.ParseSchema(in)
+		require.Error(t, err, "expected %q to be invalid", in)
+	}
+}
+
+func compressed(t *testing.T, data []byte) int {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Len()
+}
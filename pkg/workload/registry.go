@@ -0,0 +1,109 @@
+package workload
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Generator produces a stream of wrapped events for a workload.
+type Generator interface {
+	Next() *api.EventWrapper
+}
+
+// Factory creates a new generator with the default configuration of the workload that
+// generates its events from the random source.
+type Factory func(rnd *rand.Rand) Generator
+
+var (
+	regmu    sync.RWMutex
+	registry = make(map[string]Factory)
+)
+
+// Default workload names registered by this package.
+const (
+	RandomDuplicatesWorkload = "duplicates"
+	HotKeysWorkload          = "hotkeys"
+	TickerWorkload           = "ticker"
+	LogsWorkload             = "logs"
+	PairsWorkload            = "pairs"
+)
+
+func init() {
+	Register(RandomDuplicatesWorkload, func(rnd *rand.Rand) Generator { return NewRandomDuplicates(20, 0.6, 0.1, rnd) })
+	Register(HotKeysWorkload, func(rnd *rand.Rand) Generator {
+		gen := NewRandomDuplicates(20, 0.6, 0.1, rnd)
+		gen.SetDistribution(Zipf, DefaultZipfS)
+		return gen
+	})
+	Register(TickerWorkload, func(rnd *rand.Rand) Generator { return NewTicker(DefaultTickerSymbols, rnd) })
+	Register(LogsWorkload, func(rnd *rand.Rand) Generator { return NewLogLines(DefaultErrorRate, rnd) })
+	Register(PairsWorkload, func(rnd *rand.Rand) Generator { return NewPairs(DefaultPairGap, rnd) })
+}
+
+// Register a named workload so that it can be selected by name from the CLI. Register
+// panics if a workload with the same name has already been registered.
+func Register(name string, factory Factory) {
+	regmu.Lock()
+	defer regmu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("workload %q already registered", name))
+	}
+	registry[name] = factory
+}
+
+// Get returns a new generator for the named workload, or a generator that replays the
+// testdata file of a workload name with the file prefix; the file is released by the
+// Closer of the generator. If the seed is not zero the generator generates the same
+// events for the same seed.
+func Get(name string, seed int64) (_ Generator, err error) {
+	if path, ok := strings.CutPrefix(name, FilePrefix); ok {
+		var replay *FileReplay
+		if replay, err = OpenFileReplay(path); err != nil {
+			return nil, err
+		}
+		return replay, nil
+	}
+
+	regmu.RLock()
+	defer regmu.RUnlock()
+
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown workload %q", name)
+	}
+	return factory(NewRand(seed)), nil
+}
+
+// Closer returns the closer of a generator that holds resources that must be released
+// once the workload is over, e.g. the file of a FileReplay, or a closer that does
+// nothing if the generator does not hold any resources.
+func Closer(gen Generator) io.Closer {
+	if closer, ok := gen.(io.Closer); ok {
+		return closer
+	}
+	return nopCloser{}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Names returns the sorted names of all registered workloads.
+func Names() []string {
+	regmu.RLock()
+	defer regmu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
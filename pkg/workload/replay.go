@@ -0,0 +1,106 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rs/zerolog/log"
+)
+
+// FilePrefix selects the workload that replays a testdata file rather than a registered
+// workload, e.g. file:events.pb or file:events.jsonl.zst.
+const FilePrefix = "file:"
+
+// FileReplay is a workload that replays the events of a testdata file in pb or jsonl
+// format so that the exact same event stream is published across benchmark runs. The
+// file is streamed rather than loaded into memory and is replayed from the beginning
+// when it is exhausted so that any number of events can be generated. Close must be
+// called to release the file.
+type FileReplay struct {
+	path   string
+	format string
+	file   io.ReadCloser
+	reader *StreamReader
+	next   *api.EventWrapper
+	loops  int
+}
+
+// OpenFileReplay opens the testdata file at the path; the format is inferred from the
+// extension of the path and defaults to jsonl. An error is returned if the file does not
+// contain any events.
+func OpenFileReplay(path string) (_ *FileReplay, err error) {
+	replay := &FileReplay{path: path, format: FormatFromPath(path)}
+	if replay.format == "" {
+		replay.format = FormatJSONL
+	}
+
+	if err = replay.open(); err != nil {
+		return nil, err
+	}
+
+	if replay.next, err = replay.reader.Read(); err != nil {
+		replay.file.Close()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no events to replay in %s", path)
+		}
+		return nil, fmt.Errorf("could not replay %s: %w", path, err)
+	}
+	return replay, nil
+}
+
+func (r *FileReplay) open() (err error) {
+	if r.file, err = compress.Open(r.path); err != nil {
+		return fmt.Errorf("could not open %s: %w", r.path, err)
+	}
+
+	if r.reader, err = NewStreamReader(r.file, r.format); err != nil {
+		r.file.Close()
+		return err
+	}
+	return nil
+}
+
+// Next returns the next event in the file. If the file is exhausted it is reopened and
+// replayed from the beginning; an event that cannot be read also restarts the replay
+// so that only the events before it are replayed.
+func (r *FileReplay) Next() *api.EventWrapper {
+	if r.next != nil {
+		event := r.next
+		r.next = nil
+		return event
+	}
+
+	event, err := r.reader.Read()
+	if err == nil {
+		return event
+	}
+
+	if !errors.Is(err, io.EOF) {
+		log.Error().Err(err).Str("path", r.path).Msg("could not read event, restarting replay")
+	}
+
+	r.file.Close()
+	if err = r.open(); err != nil {
+		// The file was readable when it was opened so this should never happen
+		panic(err)
+	}
+
+	r.loops++
+	if r.next, err = r.reader.Read(); err != nil {
+		panic(fmt.Errorf("could not restart replay of %s: %w", r.path, err))
+	}
+	return r.Next()
+}
+
+// Loops returns the number of times the file has been replayed from the beginning.
+func (r *FileReplay) Loops() int {
+	return r.loops
+}
+
+// Close the file that is being replayed.
+func (r *FileReplay) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,69 @@
+package workload_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/compress"
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFileReplay(t *testing.T) {
+	gen := workload.NewTicker(4, workload.NewRand(0))
+	events := make([]*api.EventWrapper, 0, 3)
+	for i := 0; i < 3; i++ {
+		events = append(events, gen.Next())
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"events.pb", "events.jsonl.zst", "events.txt"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			format := workload.FormatFromPath(path)
+			if format == "" {
+				format = workload.FormatJSONL
+			}
+			writeEvents(t, path, format, events)
+
+			gen, err := workload.Get(workload.FilePrefix+path, 0)
+			require.NoError(t, err)
+			replay := gen.(*workload.FileReplay)
+			defer workload.Closer(gen).Close()
+
+			// The file is replayed from the beginning when it is exhausted
+			for i := 0; i < 8; i++ {
+				require.True(t, proto.Equal(events[i%len(events)], replay.Next()), "event %d does not match", i)
+			}
+			require.Equal(t, 2, replay.Loops())
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		path := filepath.Join(dir, "empty.pb")
+		writeEvents(t, path, workload.FormatPB, nil)
+
+		_, err := workload.OpenFileReplay(path)
+		require.ErrorContains(t, err, "no events to replay")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := workload.Get(workload.FilePrefix+filepath.Join(dir, "missing.pb"), 0)
+		require.Error(t, err)
+	})
+}
+
+func writeEvents(t *testing.T, path, format string, events []*api.EventWrapper) {
+	f, err := compress.Create(path)
+	require.NoError(t, err)
+
+	writer, err := workload.NewStreamWriter(f, format)
+	require.NoError(t, err)
+	for _, event := range events {
+		require.NoError(t, writer.Write(event))
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, f.Close())
+}
@@ -0,0 +1,183 @@
+package workload
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Serialization formats for streams of events.
+const (
+	FormatJSONL = "jsonl" // newline-delimited protojson event wrappers
+	FormatPB    = "pb"    // varint length-prefixed binary protobuf event wrappers
+	FormatJSON  = "json"  // a pretty-printed JSON array of event wrappers (write only)
+)
+
+// MaxEventSize is the largest serialized event that will be read from a stream.
+const MaxEventSize = 64 * 1024 * 1024
+
+// FormatFromPath infers the stream format from the extension of the path, ignoring a
+// zstd compression extension, returning an empty string if the format cannot be
+// inferred (e.g. when reading from stdin).
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".zst"))) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".pb", ".pbstream":
+		return FormatPB
+	default:
+		return ""
+	}
+}
+
+// StreamReader reads a stream of serialized events, e.g. generated by another tool and
+// piped to stdin, one at a time so that the whole stream is never loaded into memory.
+type StreamReader struct {
+	format string
+	buf    *bufio.Reader
+	line   int
+}
+
+// NewStreamReader returns a reader for the specified format.
+func NewStreamReader(r io.Reader, format string) (*StreamReader, error) {
+	switch format {
+	case FormatJSONL, FormatPB:
+	default:
+		return nil, fmt.Errorf("unknown event stream format %q", format)
+	}
+	return &StreamReader{format: format, buf: bufio.NewReaderSize(r, 64*1024)}, nil
+}
+
+// Read the next event in the stream; returns io.EOF when the stream is exhausted.
+func (s *StreamReader) Read() (event *api.EventWrapper, err error) {
+	event = &api.EventWrapper{}
+	switch s.format {
+	case FormatPB:
+		opts := protodelim.UnmarshalOptions{MaxSize: MaxEventSize}
+		if err = opts.UnmarshalFrom(s.buf, event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("could not read event %d: %w", s.line+1, err)
+		}
+		s.line++
+		return event, nil
+
+	default:
+		for {
+			var line []byte
+			line, err = s.buf.ReadBytes('\n')
+			if err != nil && (err != io.EOF || len(line) == 0) {
+				return nil, err
+			}
+			s.line++
+
+			// Skip blank lines, e.g. a trailing newline at the end of the stream
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+
+			if err = protojson.Unmarshal(line, event); err != nil {
+				return nil, fmt.Errorf("could not parse event on line %d: %w", s.line, err)
+			}
+			return event, nil
+		}
+	}
+}
+
+// StreamWriter writes events to a stream one at a time in any of the stream formats so
+// that large datasets can be generated without holding all of the events in memory.
+// Close must be called to complete the stream; it does not close the underlying writer.
+type StreamWriter struct {
+	format string
+	buf    *bufio.Writer
+	events int
+	pbjson protojson.MarshalOptions
+}
+
+// NewStreamWriter returns a writer for the specified format.
+func NewStreamWriter(w io.Writer, format string) (*StreamWriter, error) {
+	switch format {
+	case FormatJSONL, FormatPB, FormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown event stream format %q", format)
+	}
+
+	return &StreamWriter{
+		format: format,
+		buf:    bufio.NewWriterSize(w, 64*1024),
+		pbjson: protojson.MarshalOptions{UseProtoNames: true},
+	}, nil
+}
+
+// Write the next event to the stream.
+func (s *StreamWriter) Write(event *api.EventWrapper) (err error) {
+	switch s.format {
+	case FormatPB:
+		if _, err = protodelim.MarshalTo(s.buf, event); err != nil {
+			return fmt.Errorf("could not write event %d: %w", s.events+1, err)
+		}
+
+	case FormatJSONL:
+		var data []byte
+		if data, err = s.pbjson.Marshal(event); err != nil {
+			return fmt.Errorf("could not write event %d: %w", s.events+1, err)
+		}
+
+		s.buf.Write(data)
+		if err = s.buf.WriteByte('\n'); err != nil {
+			return err
+		}
+
+	default:
+		// The protojson serialization is not stable so it is normalized as a JSON object
+		// with sorted keys and indented as an element of the array.
+		var data []byte
+		if data, err = s.pbjson.Marshal(event); err != nil {
+			return fmt.Errorf("could not write event %d: %w", s.events+1, err)
+		}
+
+		obj := make(map[string]interface{})
+		if err = json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+
+		if data, err = json.MarshalIndent(obj, "  ", "  "); err != nil {
+			return err
+		}
+
+		sep := ",\n  "
+		if s.events == 0 {
+			sep = "[\n  "
+		}
+
+		s.buf.WriteString(sep)
+		if _, err = s.buf.Write(data); err != nil {
+			return err
+		}
+	}
+
+	s.events++
+	return nil
+}
+
+// Close completes the stream and flushes any buffered events to the underlying writer.
+func (s *StreamWriter) Close() (err error) {
+	if s.format == FormatJSON {
+		end := "\n]\n"
+		if s.events == 0 {
+			end = "[]\n"
+		}
+		s.buf.WriteString(end)
+	}
+	return s.buf.Flush()
+}
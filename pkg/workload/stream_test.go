@@ -0,0 +1,130 @@
+package workload_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStreamReader(t *testing.T) {
+	gen := workload.NewTicker(4, workload.NewRand(0))
+	events := make([]proto.Message, 0, 5)
+	for i := 0; i < 5; i++ {
+		events = append(events, gen.Next())
+	}
+
+	t.Run("JSONL", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		for _, event := range events {
+			data, err := protojson.Marshal(event)
+			require.NoError(t, err)
+			buf.Write(data)
+			buf.WriteString("\n\n")
+		}
+
+		assertStream(t, buf, workload.FormatJSONL, events)
+	})
+
+	t.Run("PB", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		for _, event := range events {
+			_, err := protodelim.MarshalTo(buf, event)
+			require.NoError(t, err)
+		}
+
+		assertStream(t, buf, workload.FormatPB, events)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := workload.NewStreamReader(&bytes.Buffer{}, "csv")
+		require.Error(t, err)
+
+		reader, err := workload.NewStreamReader(strings.NewReader("{\"topic_id\": 42}\n"), workload.FormatJSONL)
+		require.NoError(t, err)
+		_, err = reader.Read()
+		require.ErrorContains(t, err, "line 1")
+	})
+}
+
+func assertStream(t *testing.T, r io.Reader, format string, expected []proto.Message) {
+	reader, err := workload.NewStreamReader(r, format)
+	require.NoError(t, err)
+
+	for _, event := range expected {
+		actual, err := reader.Read()
+		require.NoError(t, err)
+		require.True(t, proto.Equal(event, actual))
+	}
+
+	_, err = reader.Read()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamWriter(t *testing.T) {
+	gen := workload.NewTicker(4, workload.NewRand(0))
+	events := make([]proto.Message, 0, 5)
+	for i := 0; i < 5; i++ {
+		events = append(events, gen.Next())
+	}
+
+	for _, format := range []string{workload.FormatPB, workload.FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer, err := workload.NewStreamWriter(buf, format)
+			require.NoError(t, err)
+
+			for _, event := range events {
+				require.NoError(t, writer.Write(event.(*api.EventWrapper)))
+			}
+			require.NoError(t, writer.Close())
+
+			assertStream(t, buf, format, events)
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		writer, err := workload.NewStreamWriter(buf, workload.FormatJSON)
+		require.NoError(t, err)
+
+		for _, event := range events {
+			require.NoError(t, writer.Write(event.(*api.EventWrapper)))
+		}
+		require.NoError(t, writer.Close())
+
+		var data []map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+		require.Len(t, data, len(events))
+		require.Contains(t, data[0], "topic_id")
+
+		// An empty dataset is still a valid array
+		buf.Reset()
+		writer, _ = workload.NewStreamWriter(buf, workload.FormatJSON)
+		require.NoError(t, writer.Close())
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+		require.Empty(t, data)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := workload.NewStreamWriter(&bytes.Buffer{}, "csv")
+		require.Error(t, err)
+	})
+}
+
+func TestFormatFromPath(t *testing.T) {
+	require.Equal(t, workload.FormatJSONL, workload.FormatFromPath("events.jsonl"))
+	require.Equal(t, workload.FormatJSONL, workload.FormatFromPath("events.NDJSON"))
+	require.Equal(t, workload.FormatPB, workload.FormatFromPath("/tmp/events.pb"))
+	require.Equal(t, workload.FormatPB, workload.FormatFromPath("/tmp/events.pb.zst"))
+	require.Equal(t, "", workload.FormatFromPath("-"))
+	require.Equal(t, "", workload.FormatFromPath("events.pb.json"))
+}
@@ -0,0 +1,145 @@
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultTickerSymbols is the number of symbols generated by the default ticker.
+const DefaultTickerSymbols = 50
+
+// The market session modeled by the ticker workload; the event rate is highest
+// immediately after the open and before the close and lowest in the middle of the day.
+const (
+	sessionLength = 6*time.Hour + 30*time.Minute
+	burstLength   = 30 * time.Minute
+	peakRate      = 2000.0 // ticks per second across all symbols during bursts
+	baseRate      = 200.0  // ticks per second across all symbols at midday
+	volatility    = 0.0005 // standard deviation of the per-tick log return
+)
+
+// Ticker generates a market-data-style stream of price ticks for a set of symbols.
+// Prices follow a geometric random walk and each symbol carries a strictly increasing
+// sequence number in the event metadata so that subscribers can verify per-symbol
+// ordering. Event timestamps follow a simulated market clock with bursty rates at the
+// opening and closing of the session.
+type Ticker struct {
+	symbols []*symbol
+	open    time.Time
+	clock   time.Time
+	rnd     *rand.Rand
+}
+
+type symbol struct {
+	name  string
+	price float64
+	seq   uint64
+}
+
+// Tick is the JSON payload of an event generated by the ticker workload.
+type Tick struct {
+	Symbol    string    `json:"symbol"`
+	Sequence  uint64    `json:"seq"`
+	Price     float64   `json:"price"`
+	Size      uint32    `json:"size"`
+	Timestamp time.Time `json:"ts"`
+}
+
+var tickType = &api.Type{Name: "Tick", MajorVersion: 1}
+
+func NewTicker(nSymbols int, rnd *rand.Rand) *Ticker {
+	now := time.Now().UTC()
+	open := time.Date(now.Year(), now.Month(), now.Day(), 13, 30, 0, 0, time.UTC)
+
+	ticker := &Ticker{
+		symbols: make([]*symbol, 0, nSymbols),
+		open:    open,
+		clock:   open,
+		rnd:     rnd,
+	}
+
+	seen := make(map[string]struct{}, nSymbols)
+	for len(ticker.symbols) < nSymbols {
+		name := strings.ToUpper(mkName(rnd, rnd.Intn(2)+3))
+		if _, ok := seen[name]; ok {
+			continue
+		}
+
+		seen[name] = struct{}{}
+		ticker.symbols = append(ticker.symbols, &symbol{
+			name:  name,
+			price: math.Round((10+rnd.Float64()*490)*100) / 100,
+		})
+	}
+	return ticker
+}
+
+// Next returns the next tick wrapped as an event.
+func (t *Ticker) Next() *api.EventWrapper {
+	// Advance the market clock by an exponentially distributed gap for the current rate
+	t.clock = t.clock.Add(time.Duration(t.rnd.ExpFloat64() / t.Rate() * float64(time.Second)))
+	if t.clock.Sub(t.open) >= sessionLength {
+		t.open = t.open.AddDate(0, 0, 1)
+		t.clock = t.open
+	}
+
+	sym := t.symbols[t.rnd.Intn(len(t.symbols))]
+	sym.seq++
+	sym.price = math.Max(0.01, math.Round(sym.price*math.Exp(t.rnd.NormFloat64()*volatility)*100)/100)
+
+	tick := &Tick{
+		Symbol:    sym.name,
+		Sequence:  sym.seq,
+		Price:     sym.price,
+		Size:      uint32(t.rnd.Intn(10)+1) * 100,
+		Timestamp: t.clock,
+	}
+
+	data, err := json.Marshal(tick)
+	if err != nil {
+		panic(err)
+	}
+
+	event := &api.Event{
+		Data: data,
+		Metadata: map[string]string{
+			"symbol": sym.name,
+			"seq":    fmt.Sprintf("%d", sym.seq),
+		},
+		Mimetype: mimetype.ApplicationJSON,
+		Type:     tickType,
+		Created:  timestamppb.New(t.clock),
+	}
+	return wrapEvent(t.rnd, event)
+}
+
+// Rate returns the current tick rate per second across all symbols according to the
+// time of day of the simulated market clock.
+func (t *Ticker) Rate() float64 {
+	elapsed := t.clock.Sub(t.open)
+	switch {
+	case elapsed < burstLength:
+		return peakRate
+	case elapsed > sessionLength-burstLength:
+		return peakRate
+	default:
+		return baseRate
+	}
+}
+
+// Symbols returns the names of the symbols generated by the ticker.
+func (t *Ticker) Symbols() []string {
+	names := make([]string, 0, len(t.symbols))
+	for _, sym := range t.symbols {
+		names = append(names, sym.name)
+	}
+	return names
+}
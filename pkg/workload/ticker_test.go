@@ -0,0 +1,78 @@
+package workload_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTicker(t *testing.T) {
+	gen, err := workload.Get(workload.TickerWorkload, 0)
+	require.NoError(t, err, "ticker workload should be registered")
+
+	ticker := gen.(*workload.Ticker)
+	require.Len(t, ticker.Symbols(), workload.DefaultTickerSymbols)
+
+	sequences := make(map[string]uint64)
+	for i := 0; i < 5000; i++ {
+		wrap := ticker.Next()
+		event, err := wrap.Unwrap()
+		require.NoError(t, err)
+		require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+
+		tick := &workload.Tick{}
+		require.NoError(t, json.Unmarshal(event.Data, tick))
+		require.Equal(t, tick.Symbol, event.Metadata["symbol"])
+		require.Greater(t, tick.Price, 0.0)
+
+		// Sequences must be strictly increasing per symbol
+		require.Equal(t, sequences[tick.Symbol]+1, tick.Sequence)
+		sequences[tick.Symbol] = tick.Sequence
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	require.Contains(t, workload.Names(), workload.RandomDuplicatesWorkload)
+	require.Contains(t, workload.Names(), workload.TickerWorkload)
+	require.Contains(t, workload.Names(), workload.HotKeysWorkload)
+	require.Contains(t, workload.Names(), workload.LogsWorkload)
+	require.Contains(t, workload.Names(), workload.PairsWorkload)
+	require.Panics(t, func() { workload.Register(workload.TickerWorkload, nil) })
+
+	_, err := workload.Get("notaworkload", 0)
+	require.EqualError(t, err, `unknown workload "notaworkload"`)
+}
+
+func TestSeededWorkload(t *testing.T) {
+	// Generators with the same seed generate the same ticks even when run concurrently
+	ticks := make([][][]byte, 2)
+	done := make(chan struct{})
+	for i := range ticks {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			gen, err := workload.Get(workload.TickerWorkload, 42)
+			if err != nil {
+				return
+			}
+
+			for j := 0; j < 1000; j++ {
+				event, _ := gen.Next().Unwrap()
+				ticks[i] = append(ticks[i], event.Data)
+			}
+		}(i)
+	}
+	<-done
+	<-done
+
+	require.Len(t, ticks[0], 1000)
+	require.Equal(t, ticks[0], ticks[1])
+
+	gen, err := workload.Get(workload.TickerWorkload, 7)
+	require.NoError(t, err)
+	event, err := gen.Next().Unwrap()
+	require.NoError(t, err)
+	require.NotEqual(t, ticks[0][0], event.Data, "a different seed should generate different ticks")
+}
@@ -1,7 +1,9 @@
 package workload
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
 	"time"
 
@@ -9,18 +11,31 @@ import (
 	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
 )
 
+// Distributions that RandomDuplicates can select keys and duplicate data from.
+const (
+	Uniform = "uniform"
+	Zipf    = "zipf"
+)
+
+// DefaultZipfS is the default exponent of the Zipfian distribution; larger exponents
+// concentrate more of the traffic on the hottest keys.
+const DefaultZipfS = 1.2
+
 type RandomDuplicates struct {
 	data           []string
+	keys           []string // the keys in creation order, ranked by popularity if Zipfian
 	keyset         map[string][]string
+	zipfS          float64 // the Zipfian exponent, or zero if keys are selected uniformly
 	newKeyProb     float64
 	duplicateProb  float64
 	versUpdateProb float64
 	prev           time.Time
 	mimetypes      []mimetype.MIME
 	etypes         []*api.Type
+	rnd            *rand.Rand
 }
 
-func NewRandomDuplicates(nKeys int, newKeyProb, duplicateProb float64) *RandomDuplicates {
+func NewRandomDuplicates(nKeys int, newKeyProb, duplicateProb float64, rnd *rand.Rand) *RandomDuplicates {
 	workload := &RandomDuplicates{
 		data:           make([]string, 0),
 		keyset:         make(map[string][]string, nKeys),
@@ -30,38 +45,44 @@ func NewRandomDuplicates(nKeys int, newKeyProb, duplicateProb float64) *RandomDu
 		prev:           time.Now(),
 		mimetypes:      []mimetype.MIME{mimetype.ApplicationOctetStream, mimetype.ApplicationOctetStream, mimetype.ApplicationOctetStream, mimetype.ApplicationOctetStream, mimetype.ApplicationOctetStream, mimetype.MIME_USER_SPECIFIED7},
 		etypes:         []*api.Type{{Name: "RandomData", MajorVersion: 1}},
+		rnd:            rnd,
 	}
 
 	for i := 0; i < nKeys; i++ {
-		workload.keyset[MkKey()] = []string{MkVal()}
+		key := mkKey(rnd)
+		if _, ok := workload.keyset[key]; !ok {
+			workload.keys = append(workload.keys, key)
+		}
+		workload.keyset[key] = []string{mkVal(rnd)}
 	}
 
 	return workload
 }
 
 func (r *RandomDuplicates) Next() *api.EventWrapper {
-	return MkWrap(
+	return wrapEvent(r.rnd, MkEvent(
 		r.Data(),
 		r.Metadata(),
 		r.Mimetype(),
 		r.EventType(),
 		r.Created(),
-	)
+	))
 }
 
 func (r *RandomDuplicates) Data() string {
-	if flip(r.duplicateProb) {
-		i := rnd.Intn(len(r.data))
+	// The first event cannot be a duplicate since there is no data to duplicate yet
+	if len(r.data) > 0 && flip(r.rnd, r.duplicateProb) {
+		i := r.pick(len(r.data))
 		return r.data[i]
 	}
 
-	data := MkData(0)
+	data := mkData(r.rnd, 0)
 	r.data = append(r.data, data)
 	return data
 }
 
 func (r *RandomDuplicates) Metadata() string {
-	n := rnd.Intn(len(r.keyset))
+	n := r.rnd.Intn(len(r.keyset))
 	kvs := make([]string, 0, n)
 	for i := 0; i < n; i++ {
 		kvs = append(kvs, r.KeyVal())
@@ -71,8 +92,8 @@ func (r *RandomDuplicates) Metadata() string {
 
 func (r *RandomDuplicates) KeyVal() string {
 	key := r.RandKey()
-	if flip(r.newKeyProb) {
-		r.keyset[key] = append(r.keyset[key], MkVal())
+	if flip(r.rnd, r.newKeyProb) {
+		r.keyset[key] = append(r.keyset[key], mkVal(r.rnd))
 	}
 
 	val := r.RandVal(key)
@@ -80,27 +101,31 @@ func (r *RandomDuplicates) KeyVal() string {
 }
 
 func (r *RandomDuplicates) RandKey() string {
-	i := rnd.Intn(len(r.keyset))
-	for key := range r.keyset {
-		if i == 0 {
-			return key
-		}
-		i--
-	}
-	panic("iteration ended without finding a random key/val pair")
+	return r.keys[r.pick(len(r.keys))]
 }
 
 func (r *RandomDuplicates) RandVal(key string) string {
-	i := rnd.Intn(len(r.keyset[key]))
+	i := r.pick(len(r.keyset[key]))
 	return r.keyset[key][i]
 }
 
+// Returns an index in [0, n) from the configured distribution. The Zipfian distribution
+// favors the lowest indices, so the oldest keys, values, and data are the hottest.
+func (r *RandomDuplicates) pick(n int) int {
+	if r.zipfS == 0 {
+		return r.rnd.Intn(n)
+	}
+
+	// The data grows as events are generated so the distribution is created per pick
+	return int(rand.NewZipf(r.rnd, r.zipfS, 1, uint64(n-1)).Uint64())
+}
+
 func (r *RandomDuplicates) Mimetype() mimetype.MIME {
 	if len(r.mimetypes) == 1 {
 		return r.mimetypes[0]
 	}
 
-	i := rnd.Intn(len(r.mimetypes))
+	i := r.rnd.Intn(len(r.mimetypes))
 	return r.mimetypes[i]
 }
 
@@ -109,13 +134,13 @@ func (r *RandomDuplicates) EventType() string {
 	if len(r.etypes) == 1 {
 		etype = r.etypes[0]
 	} else {
-		i := rnd.Intn(len(r.etypes))
+		i := r.rnd.Intn(len(r.etypes))
 		etype = r.etypes[i]
 	}
 
 	if r.versUpdateProb > 0 {
-		if flip(r.versUpdateProb) {
-			updateType(etype)
+		if flip(r.rnd, r.versUpdateProb) {
+			updateType(r.rnd, etype)
 		}
 	}
 
@@ -123,7 +148,7 @@ func (r *RandomDuplicates) EventType() string {
 }
 
 func (r *RandomDuplicates) Created() string {
-	r.prev = r.prev.Add(time.Duration(rnd.Int63n(int64(15 * time.Minute))))
+	r.prev = r.prev.Add(time.Duration(r.rnd.Int63n(int64(15 * time.Minute))))
 	return r.prev.Format(time.RFC3339Nano)
 }
 
@@ -139,8 +164,27 @@ func (r *RandomDuplicates) SetVersionUpgradeProbability(prob float64) {
 	r.versUpdateProb = prob
 }
 
-func flip(prob float64) bool {
-	return rnd.Float64() <= prob
+// SetDistribution selects keys, values, and duplicate data either uniformly or from a
+// Zipfian (power-law) distribution with exponent s so that a few hot keys dominate the
+// traffic as in realistic skewed workloads. The exponent must be greater than 1 and is
+// ignored by the uniform distribution.
+func (r *RandomDuplicates) SetDistribution(distribution string, s float64) error {
+	switch distribution {
+	case Uniform:
+		r.zipfS = 0
+	case Zipf:
+		if s <= 1 {
+			return errors.New("the zipf exponent must be greater than 1")
+		}
+		r.zipfS = s
+	default:
+		return fmt.Errorf("unknown distribution %q: expected %s or %s", distribution, Uniform, Zipf)
+	}
+	return nil
+}
+
+func flip(r *rand.Rand, prob float64) bool {
+	return r.Float64() <= prob
 }
 
 var (
@@ -149,8 +193,8 @@ var (
 	patchProb float64 = 0.50
 )
 
-func updateType(etype *api.Type) {
-	spin := rnd.Float64()
+func updateType(r *rand.Rand, etype *api.Type) {
+	spin := r.Float64()
 	switch {
 	case spin <= majorProb:
 		etype.MajorVersion += 1
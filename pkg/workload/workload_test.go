@@ -0,0 +1,46 @@
+package workload_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/ensign-benchmarks/pkg/workload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomDuplicatesDistribution(t *testing.T) {
+	// Returns the fraction of the selected keys that were the hottest key
+	hottest := func(gen *workload.RandomDuplicates) float64 {
+		counts := make(map[string]int)
+		for i := 0; i < 10000; i++ {
+			counts[gen.RandKey()]++
+		}
+
+		max := 0
+		for _, count := range counts {
+			if count > max {
+				max = count
+			}
+		}
+		return float64(max) / 10000
+	}
+
+	gen := workload.NewRandomDuplicates(20, 0.6, 0.1, workload.NewRand(42))
+	require.Less(t, hottest(gen), 0.1, "uniform keys should each be selected about 5% of the time")
+
+	require.NoError(t, gen.SetDistribution(workload.Zipf, workload.DefaultZipfS))
+	require.Greater(t, hottest(gen), 0.25, "the hottest zipfian key should be selected about a third of the time")
+
+	require.NoError(t, gen.SetDistribution(workload.Uniform, 0))
+	require.Less(t, hottest(gen), 0.1)
+
+	require.Error(t, gen.SetDistribution(workload.Zipf, 1.0))
+	require.Error(t, gen.SetDistribution("normal", 2.0))
+
+	// Skewed workloads still generate valid events
+	require.NoError(t, gen.SetDistribution(workload.Zipf, 2.0))
+	for i := 0; i < 100; i++ {
+		event, err := gen.Next().Unwrap()
+		require.NoError(t, err)
+		require.NotEmpty(t, event.Data)
+	}
+}